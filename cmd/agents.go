@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Agent is an agent CLI configured under agents:, which `sandbox run` can
+// launch without a command of its own.
+type Agent struct {
+	Name    string            `yaml:"name"`
+	Command string            `yaml:"command"`           // executable in the container
+	Args    []string          `yaml:"args,omitempty"`    // added before the arguments given on the command line
+	Env     map[string]string `yaml:"env,omitempty"`     // merged over the top-level env for the agent
+	Workdir string            `yaml:"workdir,omitempty"` // as for commands
+
+	// RequireEnv names variables the agent can't start without, such as
+	// its API key. Each must be set in env or by a key stored with set-key.
+	RequireEnv []string `yaml:"require_env,omitempty"`
+
+	// Allow adds firewall entries for the agent's APIs. They are allowed
+	// whenever the agent is configured.
+	Allow []FirewallEntry `yaml:"allow,omitempty"`
+}
+
+// Agent returns the configured agent called name.
+func (c *SandboxConfig) Agent(name string) (Agent, bool) {
+	for _, a := range c.Agents {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Agent{}, false
+}
+
+// AgentNames returns the names of the configured agents, sorted.
+func (c *SandboxConfig) AgentNames() []string {
+	names := make([]string, 0, len(c.Agents))
+	for _, a := range c.Agents {
+		names = append(names, a.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MissingAgentEnv returns the variables in a's require_env that neither
+// cfg's env (with a's env merged over it) nor a stored key sets to a
+// non-empty value.
+func MissingAgentEnv(cfg *SandboxConfig, a Agent) ([]string, error) {
+	if len(a.RequireEnv) == 0 {
+		return nil, nil
+	}
+	resolved, _, err := resolveEnv(cfg.ForCommand(a.Name).Env, cfg.EnvStrict)
+	if err != nil {
+		return nil, err
+	}
+	stored := storedKeyEnv(cfg)
+	var missing []string
+	for _, name := range a.RequireEnv {
+		if resolved[name] == "" && stored[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+func validateAgent(a Agent) error {
+	if strings.TrimSpace(a.Name) == "" {
+		return fmt.Errorf("agent with empty name")
+	}
+	if strings.TrimSpace(a.Command) == "" {
+		return fmt.Errorf("agent %q with empty command", a.Name)
+	}
+	for _, e := range a.Allow {
+		if err := validateFirewallEntry(e); err != nil {
+			return fmt.Errorf("agent %q: %w", a.Name, err)
+		}
+	}
+	return nil
+}
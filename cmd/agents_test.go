@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAgentsConfig(t *testing.T) {
+	t.Run("validation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte(`
+agents:
+  - name: opencode
+    command: opencode
+    args: [--print-logs]
+    env: {OPENCODE_THEME: dark}
+    workdir: app
+    require_env: [OPENAI_API_KEY]
+    allow:
+      - domain: opencode.ai
+  - name: broken
+  - name: opencode
+    command: other
+  - name: badfw
+    command: x
+    allow:
+      - cidr: nope
+`), 0644)
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.AgentNames(); !reflect.DeepEqual(got, []string{"opencode"}) {
+			t.Fatalf("agents = %v, want only the first opencode", got)
+		}
+		a, _ := cfg.Agent("opencode")
+		if a.Command != "opencode" {
+			t.Errorf("command = %q, want the first entry's", a.Command)
+		}
+		want := CommandConfig{Args: []string{"--print-logs"}, Env: map[string]string{"OPENCODE_THEME": "dark"}, Workdir: "app"}
+		if got := cfg.Command("opencode"); !reflect.DeepEqual(got, want) {
+			t.Errorf("Command(opencode) = %+v, want %+v", got, want)
+		}
+		if !domainAllowed("opencode.ai", firewallAllow(cfg)) {
+			t.Error("agent allow entries should be in the firewall allowlist")
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		base := &SandboxConfig{Agents: []Agent{{Name: "a", Command: "a1"}, {Name: "b", Command: "b1"}}}
+		override := &SandboxConfig{Agents: []Agent{{Name: "b", Command: "b2"}, {Name: "c", Command: "c2"}}}
+		got := mergeConfig(base, override).Agents
+		want := []Agent{{Name: "a", Command: "a1"}, {Name: "b", Command: "b2"}, {Name: "c", Command: "c2"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("agents = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("commands entry wins", func(t *testing.T) {
+		cfg := &SandboxConfig{
+			Commands: map[string]CommandConfig{"claude": {Workdir: "x"}},
+			Agents:   []Agent{{Name: "claude", Command: "claude", Workdir: "y"}},
+		}
+		if got := cfg.Command("claude").Workdir; got != "x" {
+			t.Errorf("workdir = %q, want the commands: entry's", got)
+		}
+	})
+}
+
+func TestMissingAgentEnv(t *testing.T) {
+	store := useMemSecretStore(t)
+	store["openai"] = "sk-test"
+
+	cfg := &SandboxConfig{Env: map[string]string{"EMPTY": ""}}
+	a := Agent{
+		Name:       "x",
+		Command:    "x",
+		Env:        map[string]string{"FROM_AGENT": "1"},
+		RequireEnv: []string{"OPENAI_API_KEY", "FROM_AGENT", "EMPTY", "UNSET"},
+	}
+	cfg.Agents = []Agent{a}
+	got, err := MissingAgentEnv(cfg, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"EMPTY", "UNSET"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missing = %v, want %v", got, want)
+	}
+}
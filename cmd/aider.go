@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// gitIdentityVars maps the git config keys read from the host to the
+// variables git reads them from in the container.
+var gitIdentityVars = []struct{ key, author, committer string }{
+	{"user.name", "GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"},
+	{"user.email", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"},
+}
+
+// GitIdentityEnv returns git author and committer variables carrying the
+// identity the host's git uses in dir, so commits made in the container
+// (such as aider's) are attributed as they would be on the host. Variables
+// set in cfg's env are left alone.
+func GitIdentityEnv(cfg *SandboxConfig, dir string) map[string]string {
+	env := make(map[string]string)
+	for _, v := range gitIdentityVars {
+		out, err := exec.Command("git", "-C", dir, "config", "--get", v.key).Output()
+		value := strings.TrimSpace(string(out))
+		if err != nil || value == "" {
+			continue
+		}
+		for _, name := range []string{v.author, v.committer} {
+			if _, set := cfg.Env[name]; !set {
+				env[name] = value
+			}
+		}
+	}
+	return env
+}
+
+// UnreachableKeyProviders returns, by provider name, the API host of each
+// stored model API key (one injected as *_API_KEY) whose provider's API the
+// firewall doesn't allow, so agents that pick a model by the keys they find
+// can warn before failing. The host is taken from the provider's
+// validate_url.
+func UnreachableKeyProviders(cfg *SandboxConfig) map[string]string {
+	providers := keyProviders(cfg)
+	allow := firewallAllow(cfg)
+	missing := make(map[string]string)
+	for name := range storedKeys(cfg) {
+		p := providers[name]
+		if !strings.HasSuffix(p.EnvVar, "_API_KEY") {
+			continue
+		}
+		u, err := url.Parse(p.ValidateURL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		if !domainAllowed(u.Hostname(), allow) {
+			missing[name] = u.Hostname()
+		}
+	}
+	return missing
+}
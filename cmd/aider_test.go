@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestGitIdentityEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "Ada Lovelace"},
+		{"config", "user.email", "ada@example.com"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+
+	cfg := &SandboxConfig{Env: map[string]string{"GIT_AUTHOR_EMAIL": "bot@example.com"}}
+	got := GitIdentityEnv(cfg, dir)
+	want := map[string]string{
+		"GIT_AUTHOR_NAME":     "Ada Lovelace",
+		"GIT_COMMITTER_NAME":  "Ada Lovelace",
+		"GIT_COMMITTER_EMAIL": "ada@example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GitIdentityEnv = %v, want %v", got, want)
+	}
+
+	if got := GitIdentityEnv(&SandboxConfig{}, t.TempDir()); len(got) != 0 {
+		t.Errorf("without a git identity = %v, want none", got)
+	}
+}
+
+func TestUnreachableKeyProviders(t *testing.T) {
+	store := useMemSecretStore(t)
+	store["anthropic"] = "sk-ant"
+	store["openrouter"] = "sk-or"
+	store["github"] = "ghp" // not a model key, so never reported
+
+	cfg := &SandboxConfig{Firewall: FirewallConfig{Allow: []FirewallEntry{{Domain: "api.anthropic.com"}}}}
+	got := UnreachableKeyProviders(cfg)
+	want := map[string]string{"openrouter": "openrouter.ai"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnreachableKeyProviders = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// AuditEntry records one command sandbox ran in a container, as a line of
+// the container's audit log.
+type AuditEntry struct {
+	Time     time.Time `json:"time"` // when the command started
+	Kind     string    `json:"kind"` // AuditExec or AuditHook
+	User     string    `json:"user"`
+	Workdir  string    `json:"workdir"`
+	Command  []string  `json:"command"`
+	ExitCode int       `json:"exit_code"` // -1 if it didn't run to completion
+	Seconds  float64   `json:"seconds"`
+	Error    string    `json:"error,omitempty"` // why it didn't complete
+}
+
+// Audit entry kinds.
+const (
+	AuditExec = "exec" // a command run with sandbox exec, shell, claude and the like
+	AuditHook = "hook" // an on_sync hook
+)
+
+// auditLogPath returns the audit log of container; a variable so tests can
+// keep it out of the user's cache directory.
+var auditLogPath = func(container string) (string, error) {
+	l, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Cache, "logs", container, "audit.jsonl"), nil
+}
+
+// auditResult fills in e's exit code, duration and error from how the
+// command that started at e.Time ended.
+func auditResult(e *AuditEntry, err error, ctxErr error) {
+	e.Seconds = time.Since(e.Time).Round(time.Millisecond).Seconds()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		e.ExitCode, e.Error = -1, "timed out"
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		e.ExitCode = exitErr.ExitCode()
+	default:
+		e.ExitCode, e.Error = -1, err.Error()
+	}
+}
+
+// recordAudit appends e to container's audit log. The log only ever grows
+// and outlives the container, so what ran in a sandbox can be reviewed after
+// it is removed. Recording is best-effort: a failure is warned about but
+// doesn't fail the command.
+func recordAudit(container string, e AuditEntry) {
+	if e.User == "" {
+		e.User = "agent"
+	}
+	e.Workdir, e.Error = Redact(e.Workdir), Redact(e.Error)
+	e.Command = slices.Clone(e.Command)
+	for i, arg := range e.Command {
+		e.Command[i] = Redact(arg)
+	}
+	path, err := auditLogPath(container)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+	if err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err == nil {
+			data, _ := json.Marshal(e)
+			_, err = f.Write(append(data, '\n'))
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	if err != nil {
+		Warnf(WarnContainer, "couldn't write the audit log: %v", err)
+	}
+}
+
+// AuditFilter picks entries out of an audit log. Zero fields match anything.
+type AuditFilter struct {
+	Since  time.Time
+	User   string
+	Kind   string
+	Failed bool // only commands that exited non-zero or didn't complete
+	Limit  int  // only the last Limit matching entries
+}
+
+func (f AuditFilter) match(e AuditEntry) bool {
+	return !e.Time.Before(f.Since) &&
+		(f.User == "" || e.User == f.User) &&
+		(f.Kind == "" || e.Kind == f.Kind) &&
+		(!f.Failed || e.ExitCode != 0)
+}
+
+// ReadAudit returns the entries in container's audit log that match f,
+// oldest first. A sandbox that has never run anything has no entries. Lines
+// that can't be parsed, such as one cut short by a crash, are skipped.
+func ReadAudit(container string, f AuditFilter) ([]AuditEntry, error) {
+	path, err := auditLogPath(container)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if f.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if f.Limit > 0 && len(entries) > f.Limit {
+		entries = entries[len(entries)-f.Limit:]
+	}
+	return entries, nil
+}
+
+// FormatAuditTable renders audit entries as an aligned table, with long
+// commands cut short.
+func FormatAuditTable(entries []AuditEntry) string {
+	const commandWidth = 60
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TIME\tKIND\tUSER\tEXIT\tTOOK\tWORKDIR\tCOMMAND")
+	for _, e := range entries {
+		exit := fmt.Sprint(e.ExitCode)
+		if e.Error != "" {
+			exit = "-"
+		}
+		took := time.Duration(e.Seconds * float64(time.Second))
+		if took >= time.Second {
+			took = took.Round(time.Second)
+		}
+		command := strings.Join(e.Command, " ")
+		if r := []rune(command); len(r) > commandWidth {
+			command = string(r[:commandWidth-1]) + "…"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"),
+			e.Kind, e.User, exit, took, e.Workdir, command)
+	}
+	w.Flush()
+	return sb.String()
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolateAuditLog points audit logs at a temp directory for the rest of the
+// test, returning it.
+func isolateAuditLog(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	orig := auditLogPath
+	auditLogPath = func(container string) (string, error) {
+		return filepath.Join(dir, container, "audit.jsonl"), nil
+	}
+	t.Cleanup(func() { auditLogPath = orig })
+	return dir
+}
+
+func TestAuditLog(t *testing.T) {
+	dir := isolateAuditLog(t)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recordAudit("sandbox-a", AuditEntry{Time: start, Kind: AuditExec, Workdir: "/work", Command: []string{"claude"}})
+	recordAudit("sandbox-a", AuditEntry{Time: start.Add(time.Hour), Kind: AuditHook, User: "root", Workdir: "/work",
+		Command: []string{"sh", "-c", "npm ci"}, ExitCode: 1})
+	recordAudit("sandbox-b", AuditEntry{Time: start, Kind: AuditExec, Command: []string{"bash"}})
+
+	// A line cut short by a crash is skipped.
+	path := filepath.Join(dir, "sandbox-a", "audit.jsonl")
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"time":"2026-03-01T14:00:00Z","ki`)
+	f.Close()
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("audit log mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+
+	all, err := ReadAudit("sandbox-a", AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].User != "agent" || all[1].Command[2] != "npm ci" {
+		t.Fatalf("ReadAudit = %+v", all)
+	}
+	for _, tc := range []struct {
+		name   string
+		filter AuditFilter
+		want   int
+	}{
+		{"since", AuditFilter{Since: start.Add(time.Minute)}, 1},
+		{"user", AuditFilter{User: "agent"}, 1},
+		{"kind", AuditFilter{Kind: AuditHook}, 1},
+		{"failed", AuditFilter{Failed: true}, 1},
+		{"limit", AuditFilter{Limit: 1}, 1},
+		{"none", AuditFilter{User: "root", Kind: AuditExec}, 0},
+	} {
+		got, _ := ReadAudit("sandbox-a", tc.filter)
+		if len(got) != tc.want {
+			t.Errorf("%s: got %d entries, want %d", tc.name, len(got), tc.want)
+		}
+	}
+	if got, _ := ReadAudit("sandbox-a", AuditFilter{Limit: 1}); len(got) == 1 && got[0].Kind != AuditHook {
+		t.Errorf("limit kept %+v, want the newest entry", got[0])
+	}
+	if got, err := ReadAudit("sandbox-none", AuditFilter{}); err != nil || got != nil {
+		t.Errorf("missing log = %v, %v; want no entries", got, err)
+	}
+
+	table := FormatAuditTable(all)
+	if !strings.Contains(table, "sh -c npm ci") || !strings.HasPrefix(table, "TIME") {
+		t.Errorf("table:\n%s", table)
+	}
+}
+
+func TestAuditResult(t *testing.T) {
+	e := AuditEntry{Time: time.Now()}
+	auditResult(&e, exec.Command("sh", "-c", "exit 3").Run(), nil)
+	if e.ExitCode != 3 || e.Error != "" {
+		t.Errorf("exit 3 recorded as %+v", e)
+	}
+
+	e = AuditEntry{Time: time.Now()}
+	auditResult(&e, errors.New("signal: killed"), context.DeadlineExceeded)
+	if e.ExitCode != -1 || e.Error != "timed out" {
+		t.Errorf("timeout recorded as %+v", e)
+	}
+
+	e = AuditEntry{Time: time.Now()}
+	auditResult(&e, exec.Command("/nonexistent/docker").Run(), nil)
+	if e.ExitCode != -1 || e.Error == "" {
+		t.Errorf("failure to start recorded as %+v", e)
+	}
+}
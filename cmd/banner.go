@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// bannerInfo is the environment summary shown when a session opens.
+type bannerInfo struct {
+	Container string
+	Workspace string
+	WorkDir   string
+	Domains   int
+	CIDRs     int
+	Pending   bool
+	ImageAge  time.Duration // 0 when unknown
+}
+
+// PrintBanner prints a compact summary of the sandbox a session is about to
+// attach to, so users with several sandboxes open can tell them apart.
+func PrintBanner(container, sandboxRoot, workDir string, cfg *SandboxConfig) {
+	b := bannerInfo{
+		Container: container,
+		Workspace: sandboxRoot,
+		WorkDir:   workDir,
+		Pending:   SyncPending(container, sandboxRoot, cfg),
+		ImageAge:  containerImageAge(container),
+	}
+	for _, e := range cfg.Firewall.Allow {
+		if e.Domain != "" {
+			b.Domains++
+		}
+		if e.CIDR != "" {
+			b.CIDRs++
+		}
+	}
+	Frontend.Note(formatBanner(b))
+}
+
+// formatBanner renders the banner as two dimmed lines.
+func formatBanner(b bannerInfo) string {
+	where := b.Workspace
+	if b.WorkDir != "" && b.WorkDir != b.Workspace {
+		where = Msg("banner.in_workdir", b.Workspace, b.WorkDir)
+	}
+
+	config := Msg("banner.config_in_sync")
+	if b.Pending {
+		config = Msg("banner.config_pending")
+	}
+	image := Msg("banner.image_unknown")
+	if b.ImageAge > 0 {
+		image = Msg("banner.image_built", formatAge(b.ImageAge))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\033[2m%s · %s\n", b.Container, where)
+	fmt.Fprintf(&sb, "%s\033[0m\n", Msg("banner.summary", b.Domains, b.CIDRs, config, image))
+	return sb.String()
+}
+
+// formatAge renders a duration as a short relative age like "3h ago".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return Msg("age.just_now")
+	case d < time.Hour:
+		return Msg("age.minutes", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return Msg("age.hours", int(d/time.Hour))
+	default:
+		return Msg("age.days", int(d/(24*time.Hour)))
+	}
+}
+
+// containerImageAge returns how long ago the container's image was built, or
+// 0 if it cannot be determined.
+func containerImageAge(container string) time.Duration {
+	imgID, err := exec.Command("docker", "inspect", "-f", "{{.Image}}", container).Output()
+	if err != nil {
+		return 0
+	}
+	out, err := exec.Command("docker", "image", "inspect", "-f", "{{.Created}}", strings.TrimSpace(string(imgID))).Output()
+	if err != nil {
+		return 0
+	}
+	created, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0
+	}
+	return time.Since(created)
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{10 * time.Second, "just now"},
+		{5 * time.Minute, "5m ago"},
+		{3*time.Hour + 20*time.Minute, "3h ago"},
+		{50 * time.Hour, "2d ago"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBanner(t *testing.T) {
+	t.Run("full summary", func(t *testing.T) {
+		out := formatBanner(bannerInfo{
+			Container: "sandbox-myapp",
+			Workspace: "/home/user/myapp",
+			WorkDir:   "/home/user/myapp/sub",
+			Domains:   12,
+			CIDRs:     1,
+			Pending:   true,
+			ImageAge:  49 * time.Hour,
+		})
+		for _, want := range []string{
+			"sandbox-myapp",
+			"/home/user/myapp (in /home/user/myapp/sub)",
+			"12 domains, 1 cidrs",
+			"config: changes pending",
+			"image: built 2d ago",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("banner missing %q:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("workdir equals workspace", func(t *testing.T) {
+		out := formatBanner(bannerInfo{
+			Container: "sandbox-myapp",
+			Workspace: "/home/user/myapp",
+			WorkDir:   "/home/user/myapp",
+		})
+		if strings.Contains(out, "(in ") {
+			t.Errorf("banner should not repeat workdir:\n%s", out)
+		}
+		if !strings.Contains(out, "config: in sync") {
+			t.Errorf("banner missing in-sync state:\n%s", out)
+		}
+		if !strings.Contains(out, "image: unknown") {
+			t.Errorf("banner missing unknown image age:\n%s", out)
+		}
+	})
+}
@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BatchFile is a pipeline of steps that `sandbox batch` runs in order in
+// the sandbox, unattended.
+type BatchFile struct {
+	Steps []BatchStep `yaml:"steps"`
+}
+
+// BatchStep is one step of a batch. Exactly one of Run, Agent or Sync is set.
+type BatchStep struct {
+	Name string `yaml:"name,omitempty"`
+
+	Run    string `yaml:"run,omitempty"`    // shell command, run with sh -c
+	Agent  string `yaml:"agent,omitempty"`  // claude, codex, gemini, aider, or an agents: name
+	Prompt string `yaml:"prompt,omitempty"` // what to ask the agent
+	Sync   bool   `yaml:"sync,omitempty"`   // push config changes into the sandbox
+
+	Timeout         string `yaml:"timeout,omitempty"` // Go duration, e.g. "20m"; empty means no limit
+	ContinueOnError bool   `yaml:"continue_on_error,omitempty"`
+}
+
+// Label names the step in progress and the summary: its name, or what it
+// runs.
+func (s BatchStep) Label() string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.Run != "":
+		return s.Run
+	case s.Agent != "":
+		return s.Agent
+	}
+	return "sync"
+}
+
+// TimeoutDuration returns the step's parsed timeout, or 0 if none is set.
+func (s BatchStep) TimeoutDuration() time.Duration {
+	d, _ := time.ParseDuration(s.Timeout)
+	return d
+}
+
+// LoadBatchFile reads and checks a batch file. Unknown keys are errors, as
+// a misspelt key in an unattended run would otherwise go unnoticed.
+func LoadBatchFile(path string) (*BatchFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var b BatchFile
+	if err := dec.Decode(&b); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(b.Steps) == 0 {
+		return nil, fmt.Errorf("%s: no steps", path)
+	}
+	for i, s := range b.Steps {
+		if err := validateBatchStep(s); err != nil {
+			return nil, fmt.Errorf("%s: step %d: %w", path, i+1, err)
+		}
+	}
+	return &b, nil
+}
+
+func validateBatchStep(s BatchStep) error {
+	kinds := 0
+	for _, set := range []bool{s.Run != "", s.Agent != "", s.Sync} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("set exactly one of run, agent or sync")
+	}
+	if s.Agent != "" && strings.TrimSpace(s.Prompt) == "" {
+		return fmt.Errorf("agent %s needs a prompt", s.Agent)
+	}
+	if s.Prompt != "" && s.Agent == "" {
+		return fmt.Errorf("prompt is only used with agent")
+	}
+	if s.Timeout != "" {
+		if s.Sync {
+			return fmt.Errorf("timeout doesn't apply to sync")
+		}
+		if d, err := time.ParseDuration(s.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", s.Timeout)
+		}
+	}
+	return nil
+}
+
+// batchAgents are the built-in agents' non-interactive forms: the command
+// and flags before the commands.<name> args, then the flag that takes the
+// prompt, if any.
+var batchAgents = map[string]struct{ before, promptFlag []string }{
+	"claude": {[]string{"claude", "--dangerously-skip-permissions"}, []string{"-p"}},
+	"codex":  {[]string{"codex", "exec", "--dangerously-bypass-approvals-and-sandbox"}, nil},
+	"gemini": {[]string{"gemini", "--yolo"}, []string{"-p"}},
+	"aider":  {[]string{"aider", "--yes-always"}, []string{"--message"}},
+}
+
+// batchStepArgs returns the command an agent or run step runs.
+func batchStepArgs(cfg *SandboxConfig, s BatchStep) ([]string, error) {
+	if s.Run != "" {
+		return []string{"sh", "-c", s.Run}, nil
+	}
+	var before, promptFlag []string
+	if b, ok := batchAgents[s.Agent]; ok {
+		before, promptFlag = b.before, b.promptFlag
+	} else {
+		a, ok := cfg.Agent(s.Agent)
+		if !ok {
+			return nil, fmt.Errorf("no agent %q: want claude, codex, gemini, aider or one under agents:", s.Agent)
+		}
+		missing, err := MissingAgentEnv(cfg, a)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("agent %q needs %s", a.Name, strings.Join(missing, ", "))
+		}
+		before = []string{a.Command}
+	}
+	args := append([]string{}, before...)
+	args = append(args, cfg.Command(s.Agent).Args...)
+	args = append(args, promptFlag...)
+	return append(args, s.Prompt), nil
+}
+
+// Batch step results.
+const (
+	BatchOK       = "ok"
+	BatchFailed   = "failed"
+	BatchTimedOut = "timed out"
+	BatchSkipped  = "skipped"
+)
+
+// BatchResult is how one step of a batch went.
+type BatchResult struct {
+	Step     BatchStep
+	Result   string // one of the Batch* results
+	ExitCode int
+	Duration time.Duration
+	Log      string // path of the step's log, "" if it has none
+}
+
+// BatchRun is where and how a batch runs.
+type BatchRun struct {
+	Container   string
+	SandboxRoot string
+	WorkDir     string
+	Config      *SandboxConfig
+	Env         map[string]string // added to every step, e.g. host tool session vars
+	LogDir      string
+}
+
+// RunBatch runs b's steps in order. Each run or agent step's output goes to
+// the terminal and to its own log in r.LogDir. A failing step stops the
+// batch, leaving the rest skipped, unless it sets continue_on_error.
+// Problems with the steps themselves are reported before any step runs.
+func RunBatch(r BatchRun, b *BatchFile) ([]BatchResult, error) {
+	stepArgs := make([][]string, len(b.Steps))
+	for i, s := range b.Steps {
+		if s.Sync {
+			continue
+		}
+		args, err := batchStepArgs(r.Config, s)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, s.Label(), err)
+		}
+		stepArgs[i] = args
+	}
+	if err := os.MkdirAll(r.LogDir, 0755); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(b.Steps))
+	stopped := false
+	for i, s := range b.Steps {
+		results[i] = BatchResult{Step: s, Result: BatchSkipped}
+		if stopped {
+			continue
+		}
+		Frontend.Info(fmt.Sprintf("==> [%d/%d] %s", i+1, len(b.Steps), s.Label()))
+		start := time.Now()
+		if s.Sync {
+			results[i].Result = BatchOK
+			if err := SyncContainer(r.Container, r.SandboxRoot, SyncOptions{}); err != nil {
+				Frontend.Note(fmt.Sprintf("sync failed: %v", err))
+				results[i].Result = BatchFailed
+				results[i].ExitCode = 1
+			}
+		} else {
+			log := filepath.Join(r.LogDir, fmt.Sprintf("%02d-%s.log", i+1, logSlug(s.Label())))
+			results[i].Log = log
+			results[i].Result, results[i].ExitCode = runBatchStep(r, s, stepArgs[i], log)
+		}
+		results[i].Duration = time.Since(start)
+		if results[i].Result != BatchOK && !s.ContinueOnError {
+			stopped = true
+		}
+	}
+	return results, nil
+}
+
+// runBatchStep runs args for s, copying its output to log.
+func runBatchStep(r BatchRun, s BatchStep, args []string, log string) (string, int) {
+	f, err := os.Create(log)
+	if err != nil {
+		Frontend.Note(fmt.Sprintf("cannot create %s: %v", log, err))
+		return BatchFailed, 1
+	}
+	defer f.Close()
+
+	env := make(map[string]string, len(r.Env))
+	for k, v := range r.Env {
+		env[k] = v
+	}
+	if s.Agent == "aider" {
+		for k, v := range GitIdentityEnv(r.Config, r.WorkDir) {
+			env[k] = v
+		}
+	}
+	prev := Frontend
+	Frontend = teeUI{UI: prev, out: io.MultiWriter(prev.Stdout(), f), err: io.MultiWriter(prev.Stderr(), f)}
+	defer func() { Frontend = prev }()
+
+	cfg := r.Config
+	if s.Agent != "" {
+		cfg = cfg.ForCommand(s.Agent)
+	}
+	err = DockerExecBatch(r.Container, r.WorkDir, cfg, env, s.TimeoutDuration(), args...)
+	var exitErr *ExitError
+	switch {
+	case err == nil:
+		return BatchOK, 0
+	case errors.As(err, &exitErr) && exitErr.Code == 124 && s.Timeout != "":
+		return BatchTimedOut, exitErr.Code
+	case errors.As(err, &exitErr):
+		return BatchFailed, exitErr.Code
+	}
+	fmt.Fprintln(f, Redact(err.Error()))
+	prev.Note(err.Error())
+	return BatchFailed, 1
+}
+
+// teeUI sends child process output to other writers, here to both the
+// terminal and a log file.
+type teeUI struct {
+	UI
+	out, err io.Writer
+}
+
+func (t teeUI) Stdout() io.Writer { return t.out }
+func (t teeUI) Stderr() io.Writer { return t.err }
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// logSlug turns a step label into part of a log file name.
+func logSlug(label string) string {
+	slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(label), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	if slug == "" {
+		slug = "step"
+	}
+	return slug
+}
+
+// FormatBatchSummary renders a batch's results as a table.
+func FormatBatchSummary(results []BatchResult) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "STEP\tRESULT\tTIME\tLOG")
+	for i, r := range results {
+		result := r.Result
+		if r.Result == BatchFailed && r.ExitCode != 0 {
+			result = fmt.Sprintf("failed (exit %d)", r.ExitCode)
+		}
+		took, log := "-", "-"
+		if r.Result != BatchSkipped {
+			took = r.Duration.Round(time.Second).String()
+		}
+		if r.Log != "" {
+			log = r.Log
+		}
+		fmt.Fprintf(w, "%d. %s\t%s\t%s\t%s\n", i+1, r.Step.Label(), result, took, log)
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// BatchFailures counts the steps that didn't succeed, skipped ones aside.
+func BatchFailures(results []BatchResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Result == BatchFailed || r.Result == BatchTimedOut {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadBatchFile(t *testing.T) {
+	tests := []struct {
+		name, yaml, wantErr string
+	}{
+		{name: "valid", yaml: "steps:\n  - agent: claude\n    prompt: fix it\n    timeout: 30m\n  - run: go test ./...\n  - sync: true\n"},
+		{name: "no steps", yaml: "steps: []\n", wantErr: "no steps"},
+		{name: "unknown key", yaml: "steps:\n  - run: ls\n    retries: 2\n", wantErr: "retries"},
+		{name: "two kinds", yaml: "steps:\n  - run: ls\n    sync: true\n", wantErr: "exactly one"},
+		{name: "no kind", yaml: "steps:\n  - name: nothing\n", wantErr: "exactly one"},
+		{name: "agent without prompt", yaml: "steps:\n  - agent: claude\n", wantErr: "needs a prompt"},
+		{name: "prompt without agent", yaml: "steps:\n  - run: ls\n    prompt: hi\n", wantErr: "only used with agent"},
+		{name: "bad timeout", yaml: "steps:\n  - run: ls\n    timeout: soon\n", wantErr: "step 1: invalid timeout"},
+		{name: "sync timeout", yaml: "steps:\n  - sync: true\n    timeout: 1m\n", wantErr: "doesn't apply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "batch.yaml")
+			os.WriteFile(path, []byte(tt.yaml), 0644)
+			_, err := LoadBatchFile(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBatchStepArgs(t *testing.T) {
+	cfg := &SandboxConfig{
+		Commands: map[string]CommandConfig{"claude": {Args: []string{"--model", "opus"}}},
+		Agents:   []Agent{{Name: "opencode", Command: "opencode", Args: []string{"run"}}},
+	}
+	tests := []struct {
+		step BatchStep
+		want []string
+	}{
+		{BatchStep{Run: "go test ./..."}, []string{"sh", "-c", "go test ./..."}},
+		{BatchStep{Agent: "claude", Prompt: "fix"}, []string{"claude", "--dangerously-skip-permissions", "--model", "opus", "-p", "fix"}},
+		{BatchStep{Agent: "codex", Prompt: "fix"}, []string{"codex", "exec", "--dangerously-bypass-approvals-and-sandbox", "fix"}},
+		{BatchStep{Agent: "aider", Prompt: "fix"}, []string{"aider", "--yes-always", "--message", "fix"}},
+		{BatchStep{Agent: "opencode", Prompt: "fix"}, []string{"opencode", "run", "fix"}},
+	}
+	for _, tt := range tests {
+		got, err := batchStepArgs(cfg, tt.step)
+		if err != nil {
+			t.Errorf("batchStepArgs(%+v): %v", tt.step, err)
+			continue
+		}
+		if strings.Join(got, "\x00") != strings.Join(tt.want, "\x00") {
+			t.Errorf("batchStepArgs(%+v) = %q, want %q", tt.step, got, tt.want)
+		}
+	}
+	if _, err := batchStepArgs(cfg, BatchStep{Agent: "nope", Prompt: "x"}); err == nil {
+		t.Error("unknown agent should be an error")
+	}
+}
+
+func TestFormatBatchSummary(t *testing.T) {
+	results := []BatchResult{
+		{Step: BatchStep{Name: "Fix tests", Agent: "claude"}, Result: BatchOK, Duration: 90 * time.Second, Log: "/logs/01-fix-tests.log"},
+		{Step: BatchStep{Run: "go test ./..."}, Result: BatchFailed, ExitCode: 2, Duration: time.Second, Log: "/logs/02-go-test.log"},
+		{Step: BatchStep{Sync: true}, Result: BatchSkipped},
+	}
+	got := FormatBatchSummary(results)
+	for _, want := range []string{"1. Fix tests", "1m30s", "failed (exit 2)", "/logs/02-go-test.log", "3. sync"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary missing %q:\n%s", want, got)
+		}
+	}
+	if n := BatchFailures(results); n != 1 {
+		t.Errorf("BatchFailures = %d, want 1", n)
+	}
+	if got := logSlug("Run: go test ./..."); got != "run-go-test" {
+		t.Errorf("logSlug = %q, want run-go-test", got)
+	}
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// caCertDir is where synced ca_certificates go in the container, for
+// update-ca-certificates to add to the trust store. Those built into the
+// image are kept apart, in sandbox-image beside it, so syncing leaves them
+// be.
+const caCertDir = "/usr/local/share/ca-certificates/sandbox"
+
+// caCert is a ca_certificates file as read from the host.
+type caCert struct {
+	Name   string // its file name in caCertDir
+	Data   []byte
+	Source string
+}
+
+func validateCACertificate(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return fmt.Errorf("ca_certificates entry is empty")
+	}
+	if !filepath.IsAbs(expandTilde(path)) {
+		return fmt.Errorf("ca_certificates entry %q must be an absolute path or start with ~/", path)
+	}
+	return nil
+}
+
+// readCACert reads the PEM file at path, which must hold at least one
+// certificate.
+func readCACert(path string) (caCert, error) {
+	src := expandTilde(path)
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return caCert{}, err
+	}
+	found := false
+	for rest := data; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		found = found || block.Type == "CERTIFICATE"
+	}
+	if !found {
+		return caCert{}, fmt.Errorf("%s holds no PEM certificate", path)
+	}
+	// update-ca-certificates only picks up .crt files; the hash keeps two
+	// files of the same name apart.
+	stem := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	return caCert{Name: stem + "-" + sha256Hex(data)[:8] + ".crt", Data: data, Source: src}, nil
+}
+
+// readCACerts reads each of paths, warning about and skipping those that
+// can't be used.
+func readCACerts(paths []string) []caCert {
+	var certs []caCert
+	for _, path := range paths {
+		c, err := readCACert(path)
+		if err != nil {
+			Warnf(WarnSync, "ca_certificates: %v, skipping", err)
+			continue
+		}
+		certs = append(certs, c)
+	}
+	return certs
+}
+
+// caCertItems returns cfg's ca_certificates for syncing into caCertDir. A
+// read-only root filesystem has no writable trust store, so there they are
+// only trusted when built into the image from the global config.
+func caCertItems(cfg *SandboxConfig) []SyncItem {
+	if len(cfg.CACertificates) == 0 {
+		return nil
+	}
+	if readonlyRootfs(cfg) {
+		Warnf(WarnSync, "ca_certificates can't be synced with hardening.readonly_rootfs; set them in the global config to build them into the image")
+		return nil
+	}
+	var items []SyncItem
+	for _, c := range readCACerts(cfg.CACertificates) {
+		items = append(items, SyncItem{
+			Data:   c.Data,
+			Dest:   caCertDir + "/" + c.Name,
+			Mode:   "0644",
+			Owner:  "root:root",
+			Source: c.Source,
+		})
+	}
+	return items
+}
+
+// updateCATrust removes certificates no longer in items from caCertDir and
+// rebuilds the trust store, unless it was last built from the same ones.
+func updateCATrust(container string, items []SyncItem) error {
+	var keep []string
+	for _, item := range items {
+		if strings.HasPrefix(item.Dest, caCertDir+"/") {
+			keep = append(keep, filepath.Base(item.Dest))
+		}
+	}
+	sort.Strings(keep)
+	want := sha256Hex([]byte(strings.Join(keep, "\n")))
+	const script = `dir=$1 want=$2; shift 2
+[ -d "$dir" ] || [ $# -gt 0 ] || exit 0
+[ "$(cat "$dir/.sandbox-hash" 2>/dev/null)" = "$want" ] && exit 0
+mkdir -p "$dir"
+for f in "$dir"/*.crt; do
+	[ -e "$f" ] || continue
+	case " $* " in *" ${f##*/} "*) ;; *) rm -f "$f" ;; esac
+done
+update-ca-certificates --fresh >/dev/null && echo "$want" > "$dir/.sandbox-hash"`
+	out, err := exec.Command("docker", append([]string{"exec", "-u", "root", container,
+		"sh", "-c", script, "sh", caCertDir, want}, keep...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("update CA certificates: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// writeBuildCACerts copies the certificates at paths into
+// dir/ca-certificates, which the Dockerfile adds to the image's trust
+// store. The directory is created even when there are none.
+func writeBuildCACerts(dir string, paths []string) error {
+	certDir := filepath.Join(dir, "ca-certificates")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		return err
+	}
+	for _, c := range readCACerts(paths) {
+		if err := os.WriteFile(filepath.Join(certDir, c.Name), c.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildCACertsHash identifies the global config's ca_certificates, which
+// local image builds add, for imageHash; it is empty when there are none.
+// Unusable files are left out quietly, as the build warns about them.
+func buildCACertsHash() string {
+	var names []string
+	for _, path := range readGlobalSettings().CACertificates {
+		if c, err := readCACert(path); err == nil {
+			names = append(names, c.Name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return sha256Hex([]byte(strings.Join(names, "\n")))
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestCA(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte(name)})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCACertItems(t *testing.T) {
+	useRecordingUI(t)
+	resetWarnings(t)
+	ca := writeTestCA(t, "corp-ca.pem")
+	other := writeTestCA(t, "corp-ca.crt")
+	notPEM := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0644)
+
+	items := caCertItems(&SandboxConfig{CACertificates: []string{ca, other, notPEM}})
+	if len(items) != 2 {
+		t.Fatalf("items = %+v, want the two certificates", items)
+	}
+	for i, src := range []string{ca, other} {
+		if items[i].Source != src || items[i].Owner != "root:root" || !strings.HasPrefix(items[i].Dest, caCertDir+"/corp-ca-") || !strings.HasSuffix(items[i].Dest, ".crt") {
+			t.Errorf("items[%d] = %+v", i, items[i])
+		}
+	}
+	if items[0].Dest == items[1].Dest {
+		t.Errorf("files of the same name both sync to %s", items[0].Dest)
+	}
+	if warningCount() != 1 {
+		t.Errorf("want one warning for the file without a certificate, got %d", warningCount())
+	}
+
+	cfg := &SandboxConfig{CACertificates: []string{ca}}
+	cfg.Hardening.ReadonlyRootfs = true
+	if items := caCertItems(cfg); items != nil {
+		t.Errorf("items = %+v, want none with a read-only root", items)
+	}
+}
+
+func TestMergeCACertificates(t *testing.T) {
+	base := &SandboxConfig{CACertificates: []string{"/etc/corp/root.pem"}}
+	override := &SandboxConfig{CACertificates: []string{"/etc/corp/root.pem", "~/team-ca.pem"}}
+	want := []string{"/etc/corp/root.pem", "~/team-ca.pem"}
+	if got := mergeConfig(base, override).CACertificates; !reflect.DeepEqual(got, want) {
+		t.Errorf("ca_certificates = %v, want %v", got, want)
+	}
+}
+
+func TestWriteBuildCACerts(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeBuildCACerts(dir, []string{writeTestCA(t, "root.pem")}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "ca-certificates"))
+	if err != nil || len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), ".crt") {
+		t.Errorf("build context certificates = %v, %v", entries, err)
+	}
+	empty := t.TempDir()
+	if err := writeBuildCACerts(empty, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(empty, "ca-certificates")); err != nil {
+		t.Errorf("the directory should exist without certificates: %v", err)
+	}
+}
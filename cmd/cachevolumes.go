@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// LabelCaches records the paths a container was created with cache
+// volumes at, joined with ":", as volumes can only be mounted then.
+const LabelCaches = "sandbox.caches"
+
+// LabelCacheOf marks a cache volume with the sandbox it belongs to, so
+// `sandbox rm --caches` can find it.
+const LabelCacheOf = "sandbox.cache"
+
+// cacheVolume is a named volume kept across containers, mounted at Path.
+type cacheVolume struct {
+	Name string
+	Path string // in the container
+	Root string // the workspace or home directory Path is under
+}
+
+func validateCacheVolume(p string) error {
+	rel := strings.TrimSuffix(strings.TrimPrefix(p, "~/"), "/")
+	if rel == "" || !filepath.IsLocal(rel) || filepath.Clean(rel) == "." {
+		return fmt.Errorf("invalid cache_volumes entry %q, want a path inside the workspace or starting with ~/", p)
+	}
+	// Docker splits mount specs on them.
+	if strings.ContainsAny(p, ":,") {
+		return fmt.Errorf("cache_volumes entry %q can't contain ':' or ','", p)
+	}
+	return nil
+}
+
+// cacheVolumeNameRe matches what volume names can't contain.
+var cacheVolumeNameRe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// cacheVolumes returns the volumes for cfg's cache_volumes in the
+// container named, for the workspace at wsPath: entries starting with ~/
+// are in the agent's home directory, the rest in the workspace. Volume
+// names are the container's with the path and a hash of the workspace and
+// the path, so each workspace has its own, even one whose container name
+// another workspace shares.
+func cacheVolumes(cfg *SandboxConfig, name, wsPath string) []cacheVolume {
+	if cfg == nil {
+		return nil
+	}
+	var vols []cacheVolume
+	for _, p := range cfg.CacheVolumes {
+		root, rel := wsPath, p
+		if strings.HasPrefix(p, "~/") {
+			root, rel = "/home/agent", p[2:]
+		}
+		rel = filepath.Clean(rel)
+		slug := strings.Trim(cacheVolumeNameRe.ReplaceAllString(rel, "-"), "-.")
+		vols = append(vols, cacheVolume{
+			Name: name + "-cache-" + slug + "-" + sha256Hex([]byte(wsPath+"\x00"+p))[:8],
+			Path: filepath.Join(root, rel),
+			Root: root,
+		})
+	}
+	return vols
+}
+
+func cacheLabel(vols []cacheVolume) string {
+	paths := make([]string, len(vols))
+	for i, v := range vols {
+		paths[i] = v.Path
+	}
+	return strings.Join(paths, ":")
+}
+
+func cacheArgs(vols []cacheVolume) []string {
+	var args []string
+	for _, v := range vols {
+		args = append(args, "-v", v.Name+":"+v.Path)
+	}
+	return args
+}
+
+// createCacheVolumes creates the volumes that don't exist yet, labelled
+// with the container named. Over a bind-mounted workspace, the mount
+// points are made on the host first, as Docker would make them as root.
+func createCacheVolumes(vols []cacheVolume, name string, bindMounted bool) error {
+	for _, v := range vols {
+		if out, err := exec.Command("docker", "volume", "create",
+			"--label", LabelCacheOf+"="+name, v.Name).CombinedOutput(); err != nil {
+			return fmt.Errorf("create cache volume %s: %v %s", v.Name, err, strings.TrimSpace(string(out)))
+		}
+		if bindMounted && v.Root != "/home/agent" {
+			if err := os.MkdirAll(v.Path, 0755); err != nil {
+				return fmt.Errorf("cache_volumes: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// chownCacheVolumes hands the cache volumes, and any directories Docker
+// made for them, to the agent user. New volumes belong to root. Over a
+// bind-mounted workspace those directories are the host's, made by
+// createCacheVolumes, and are left be. A container created with other
+// cache volumes is left as it is.
+func chownCacheVolumes(container string, vols []cacheVolume, bindMounted bool) error {
+	if containerCacheLabel(container) != cacheLabel(vols) {
+		return nil
+	}
+	var paths []string
+	for _, v := range vols {
+		paths = append(paths, v.Path)
+		if bindMounted && v.Root != "/home/agent" {
+			continue
+		}
+		for p := filepath.Dir(v.Path); strings.HasPrefix(p, v.Root+"/"); p = filepath.Dir(p) {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+	if out, err := exec.Command("docker", append([]string{"exec", "-u", "root", container,
+		"chown", "agent:agent"}, paths...)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("prepare cache volumes: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// containerCacheLabel returns the paths the named container was created
+// with cache volumes at, from its label.
+func containerCacheLabel(container string) string {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelCaches+`"}}`, container).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// warnIfCachesChanged prints a warning if the container was created with
+// cache volumes at other paths than the config now asks for.
+func warnIfCachesChanged(container, want string) {
+	if containerCacheLabel(container) != want {
+		Warnf(WarnContainer, "cache_volumes have changed since this sandbox was created. To apply them, run `sandbox rm <folder>` and then restart.")
+	}
+}
+
+// RemoveCacheVolumes removes the cache volumes of the sandbox named,
+// returning their names.
+func RemoveCacheVolumes(container string) ([]string, error) {
+	out, err := exec.Command("docker", "volume", "ls", "-q", "--filter", "label="+LabelCacheOf+"="+container).Output()
+	if err != nil {
+		return nil, dockerFailed(fmt.Errorf("list cache volumes: %w", err))
+	}
+	vols := strings.Fields(string(out))
+	if len(vols) == 0 {
+		return nil, nil
+	}
+	if out, err := exec.Command("docker", append([]string{"volume", "rm"}, vols...)...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("remove cache volumes: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return vols, nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCacheVolumes(t *testing.T) {
+	cfg := &SandboxConfig{CacheVolumes: []string{"target/", "web/node_modules", "~/.cache/go-build"}}
+	vols := cacheVolumes(cfg, "sandbox-app", "/src/app")
+	wantPaths := []string{"/src/app/target", "/src/app/web/node_modules", "/home/agent/.cache/go-build"}
+	for i, v := range vols {
+		if v.Path != wantPaths[i] {
+			t.Errorf("path %d = %s, want %s", i, v.Path, wantPaths[i])
+		}
+		if !volumeNameRe.MatchString(v.Name) || !strings.HasPrefix(v.Name, "sandbox-app-cache-") {
+			t.Errorf("volume name %q isn't one of the sandbox's", v.Name)
+		}
+	}
+	if !strings.HasPrefix(vols[2].Name, "sandbox-app-cache-cache-go-build-") {
+		t.Errorf("volume name = %s, want the path in it", vols[2].Name)
+	}
+	if got := cacheLabel(vols); got != strings.Join(wantPaths, ":") {
+		t.Errorf("cacheLabel = %s", got)
+	}
+	if other := cacheVolumes(cfg, "sandbox-other", "/src/other"); other[0].Name == vols[0].Name {
+		t.Error("workspaces should have their own volumes")
+	}
+	if other := cacheVolumes(cfg, "sandbox-app", "/work/app"); other[0].Name == vols[0].Name {
+		t.Error("workspaces with the same base name should have their own volumes")
+	}
+	if cacheVolumes(nil, "sandbox-app", "/src/app") != nil {
+		t.Error("no config should mean no volumes")
+	}
+}
+
+func TestValidateCacheVolume(t *testing.T) {
+	for _, p := range []string{"target", "target/", "./node_modules", "~/.cache/go-build"} {
+		if err := validateCacheVolume(p); err != nil {
+			t.Errorf("validateCacheVolume(%q) = %v", p, err)
+		}
+	}
+	for _, p := range []string{"", ".", "~/", "/var/cache", "../target", "a/../../b", "a:b", "a,b"} {
+		if validateCacheVolume(p) == nil {
+			t.Errorf("validateCacheVolume(%q) should fail", p)
+		}
+	}
+}
+
+func TestMergeCacheVolumes(t *testing.T) {
+	base := &SandboxConfig{CacheVolumes: []string{"~/.cache/go-build", "target/"}}
+	override := &SandboxConfig{CacheVolumes: []string{"target/", "node_modules/"}}
+	want := []string{"~/.cache/go-build", "target/", "node_modules/"}
+	if got := mergeConfig(base, override).CacheVolumes; !reflect.DeepEqual(got, want) {
+		t.Errorf("CacheVolumes = %v, want %v", got, want)
+	}
+}
+
+func TestWorkspaceSyncIgnoresCacheVolumes(t *testing.T) {
+	cfg := &SandboxConfig{
+		CacheVolumes: []string{"./target/", "~/.cache/go-build"},
+		Workspace:    WorkspaceConfig{Sync: WorkspaceSyncConfig{Engine: SyncEngineBuiltin}},
+	}
+	want := []string{"/target"}
+	if got := workspaceSyncIgnores(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("workspaceSyncIgnores = %v, want %v", got, want)
+	}
+}
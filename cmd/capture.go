@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// volatileEnv lists variables that differ between any two shells and are
+// never worth capturing.
+var volatileEnv = map[string]bool{
+	"_": true, "PWD": true, "OLDPWD": true, "SHLVL": true, "HOSTNAME": true,
+	"TERM": true, "HOME": true, "USER": true, "LOGNAME": true, "PATH": true,
+	"SANDBOX_SESSION": true, "SANDBOX_HOSTTOOL_PORT": true,
+}
+
+// CaptureContainer compares a running container against a fresh container
+// from the same image and proposes config that would reproduce the
+// differences: on_sync hooks for extra apt and global npm packages, and env
+// entries for variables set in the interactive shell but not in cfg.
+func CaptureContainer(container string, cfg *SandboxConfig) (*SandboxConfig, error) {
+	out, err := exec.Command("docker", "inspect", "-f", "{{.Image}}", container).Output()
+	if err != nil {
+		return nil, fmt.Errorf("inspect container: %w", err)
+	}
+	image := strings.TrimSpace(string(out))
+
+	// Each probe runs in the live container and in a throwaway one.
+	probe := func(user string, args ...string) (live, base string, err error) {
+		l, err := exec.Command("docker", append([]string{"exec", "-u", user, container}, args...)...).Output()
+		if err != nil {
+			return "", "", fmt.Errorf("%s in container: %w", strings.Join(args, " "), err)
+		}
+		b, err := exec.Command("docker", append([]string{"run", "--rm", "-u", user, image}, args...)...).Output()
+		if err != nil {
+			return "", "", fmt.Errorf("%s in fresh container: %w", strings.Join(args, " "), err)
+		}
+		return string(l), string(b), nil
+	}
+
+	proposal := &SandboxConfig{Env: make(map[string]string)}
+
+	live, base, err := probe("root", "apt-mark", "showmanual")
+	if err != nil {
+		return nil, err
+	}
+	if pkgs := diffLines(live, base); len(pkgs) > 0 {
+		proposal.OnSync = append(proposal.OnSync, OnSyncHook{
+			Name: "install apt packages",
+			Cmd:  "apt-get update && apt-get install -y " + strings.Join(pkgs, " "),
+			Root: true,
+		})
+	}
+
+	live, base, err = probe("agent", "npm", "ls", "-g", "--depth=0", "--json")
+	if err != nil {
+		return nil, err
+	}
+	if pkgs := diffStrings(parseNpmGlobals(live), parseNpmGlobals(base)); len(pkgs) > 0 {
+		proposal.OnSync = append(proposal.OnSync, OnSyncHook{
+			Name: "install global npm packages",
+			Cmd:  "npm install -g " + strings.Join(pkgs, " "),
+		})
+	}
+
+	live, base, err = probe("agent", "zsh", "-ic", "env")
+	if err != nil {
+		return nil, err
+	}
+	liveEnv, baseEnv := parseEnvLines(live), parseEnvLines(base)
+	for k, v := range liveEnv {
+		if volatileEnv[k] || baseEnv[k] == v {
+			continue
+		}
+		if _, configured := cfg.Env[k]; configured {
+			continue
+		}
+		proposal.Env[k] = v
+	}
+
+	return proposal, nil
+}
+
+// diffLines returns the non-empty lines of live that are not in base, sorted.
+func diffLines(live, base string) []string {
+	return diffStrings(strings.Fields(live), strings.Fields(base))
+}
+
+// diffStrings returns the entries of live that are not in base, sorted.
+func diffStrings(live, base []string) []string {
+	seen := make(map[string]bool, len(base))
+	for _, s := range base {
+		seen[s] = true
+	}
+	var out []string
+	for _, s := range live {
+		if s != "" && !seen[s] {
+			out = append(out, s)
+			seen[s] = true
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// parseNpmGlobals extracts "name@version" specs from `npm ls -g --json`.
+func parseNpmGlobals(data string) []string {
+	var ls struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal([]byte(data), &ls); err != nil {
+		return nil
+	}
+	var pkgs []string
+	for name, dep := range ls.Dependencies {
+		if dep.Version != "" {
+			name += "@" + dep.Version
+		}
+		pkgs = append(pkgs, name)
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// parseEnvLines parses `env` output into a map. Multi-line values are
+// truncated to their first line.
+func parseEnvLines(data string) map[string]string {
+	env := make(map[string]string)
+	for _, line := range strings.Split(data, "\n") {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || k == "" || strings.ContainsAny(k, " \t") {
+			continue
+		}
+		env[k] = v
+	}
+	return env
+}
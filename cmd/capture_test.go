@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("git\nhtop\nzsh\nvim\n", "git\nzsh\n")
+	want := []string{"htop", "vim"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLines = %v, want %v", got, want)
+	}
+}
+
+func TestParseNpmGlobals(t *testing.T) {
+	got := parseNpmGlobals(`{"dependencies":{"typescript":{"version":"5.4.2"},"corepack":{"version":"0.28.0"}}}`)
+	want := []string{"corepack@0.28.0", "typescript@5.4.2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNpmGlobals = %v, want %v", got, want)
+	}
+	if got := parseNpmGlobals("not json"); got != nil {
+		t.Errorf("parseNpmGlobals(invalid) = %v, want nil", got)
+	}
+}
+
+func TestParseEnvLines(t *testing.T) {
+	env := parseEnvLines("FOO=bar\nURL=http://x/?a=b\ncontinuation line\n")
+	if env["FOO"] != "bar" {
+		t.Errorf("FOO = %q, want bar", env["FOO"])
+	}
+	if env["URL"] != "http://x/?a=b" {
+		t.Errorf("URL = %q, want value with '='", env["URL"])
+	}
+	if len(env) != 2 {
+		t.Errorf("env = %v, want 2 entries", env)
+	}
+}
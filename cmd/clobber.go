@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// syncedHashesPath records, per destination, the hash of the content the
+// last sync wrote there, so later syncs can tell whether the file has been
+// edited in the container since.
+const syncedHashesPath = "/opt/sandbox-synced.json"
+
+// syncedHashes maps each synced destination to sha256Hex of its content.
+type syncedHashes map[string]string
+
+func readSyncedHashes(container string) syncedHashes {
+	hashes := make(syncedHashes)
+	out, err := exec.Command("docker", "exec", container, "cat", syncedHashesPath).Output()
+	if err == nil {
+		json.Unmarshal(out, &hashes)
+	}
+	return hashes
+}
+
+// writeSyncedHashes records items as synced over prev. Large items aren't
+// hashed, so they are left out.
+func writeSyncedHashes(container string, prev syncedHashes, items []SyncItem) error {
+	hashes := make(syncedHashes, len(prev)+len(items))
+	for dest, h := range prev {
+		hashes[dest] = h
+	}
+	for _, item := range items {
+		if !item.Large {
+			hashes[item.Dest] = sha256Hex(item.Data)
+		}
+	}
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	if err := copyToContainer(container, data, syncedHashesPath); err != nil {
+		return fmt.Errorf("write synced hashes: %w", err)
+	}
+	return nil
+}
+
+// SyncConflict is a synced file that has been edited in the container since
+// the last sync, and that the sync would overwrite with different content.
+type SyncConflict struct {
+	Dest          string
+	SourceChanged bool // the new version differs from the last synced one
+	Added         int  // lines in the new version missing from the container's copy
+	Removed       int  // lines in the container's copy missing from the new version
+}
+
+// Summary describes the conflict on one line: what changed on each side
+// since the last sync, and how overwriting would change the container's copy.
+func (c SyncConflict) Summary() string {
+	source := "unchanged"
+	if c.SourceChanged {
+		source = "changed"
+	}
+	return fmt.Sprintf("%s: edited in sandbox, source %s since last sync; overwriting adds %d lines, removes %d",
+		c.Dest, source, c.Added, c.Removed)
+}
+
+// findSyncConflicts compares the container's copies of items with what the
+// last sync wrote. Items the last sync didn't record, large items, and files
+// missing from the container are never conflicts.
+func findSyncConflicts(container string, items []SyncItem, prev syncedHashes) []SyncConflict {
+	var dests []string
+	byDest := make(map[string]SyncItem)
+	for _, item := range items {
+		if _, ok := prev[item.Dest]; ok && !item.Large {
+			dests = append(dests, item.Dest)
+			byDest[item.Dest] = item
+		}
+	}
+	if len(dests) == 0 {
+		return nil
+	}
+	// sha256sum exits non-zero if any file is missing but still prints
+	// the others, so its error is ignored.
+	out, _ := exec.Command("docker", append([]string{"exec", "-u", "root", container, "sha256sum", "--"}, dests...)...).Output()
+	current := parseSha256sum(string(out))
+
+	var conflicts []SyncConflict
+	for _, dest := range dests {
+		item := byDest[dest]
+		cur, ok := current[dest]
+		want := sha256Hex(item.Data)
+		if !ok || cur == prev[dest] || cur == want {
+			continue
+		}
+		c := SyncConflict{Dest: dest, SourceChanged: want != prev[dest]}
+		if data, err := exec.Command("docker", "exec", "-u", "root", container, "cat", dest).Output(); err == nil {
+			c.Added, c.Removed = lineChanges(string(data), string(item.Data))
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts
+}
+
+// lineChanges counts the lines of to that from lacks (added) and the lines
+// of from that to lacks (removed), comparing lines as multisets.
+func lineChanges(from, to string) (added, removed int) {
+	counts := make(map[string]int)
+	for _, l := range strings.Split(from, "\n") {
+		counts[l]++
+	}
+	for _, l := range strings.Split(to, "\n") {
+		if counts[l] > 0 {
+			counts[l]--
+		} else {
+			added++
+		}
+	}
+	for _, n := range counts {
+		removed += n
+	}
+	return added, removed
+}
+
+// resolveSyncConflicts returns items without those whose container copies
+// were edited and may not be overwritten. Conflicts are overwritten with
+// opts.Clobber, or when opts.ConfirmOverwrite approves them. With neither,
+// a forced sync fails, and any other sync leaves them be with a warning.
+func resolveSyncConflicts(container string, items []SyncItem, prev syncedHashes, opts SyncOptions) ([]SyncItem, error) {
+	if opts.Clobber {
+		return items, nil
+	}
+	return applySyncConflicts(items, findSyncConflicts(container, items, prev), opts)
+}
+
+// applySyncConflicts drops the items for conflicts that may not be
+// overwritten, as resolveSyncConflicts describes.
+func applySyncConflicts(items []SyncItem, conflicts []SyncConflict, opts SyncOptions) ([]SyncItem, error) {
+	if len(conflicts) == 0 {
+		return items, nil
+	}
+	if opts.ConfirmOverwrite == nil && opts.Force {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d synced file(s) were edited in the sandbox and would be overwritten:\n", len(conflicts))
+		for _, c := range conflicts {
+			fmt.Fprintf(&b, "  %s\n", c.Summary())
+		}
+		b.WriteString("copy out any changes to keep, then pass --clobber to overwrite them")
+		return nil, fmt.Errorf("%s", b.String())
+	}
+	skip := make(map[string]bool)
+	for _, c := range conflicts {
+		if opts.ConfirmOverwrite == nil {
+			Warnf(WarnSync, "%s; not overwriting it, run `sandbox sync` to resolve", c.Summary())
+			skip[c.Dest] = true
+		} else if !opts.ConfirmOverwrite(c) {
+			skip[c.Dest] = true
+		}
+	}
+	kept := items[:0:0]
+	for _, item := range items {
+		if !skip[item.Dest] {
+			kept = append(kept, item)
+		}
+	}
+	return kept, nil
+}
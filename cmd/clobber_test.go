@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineChanges(t *testing.T) {
+	for _, tc := range []struct {
+		from, to       string
+		added, removed int
+	}{
+		{"a\nb\n", "a\nb\n", 0, 0},
+		{"a\nb\n", "a\nb\nc\n", 1, 0},
+		{"a\nx\nb\n", "a\nb\n", 0, 1},
+		{"a\nb\n", "a\nc\n", 1, 1},
+		{"a\na\n", "a\n", 0, 1},
+	} {
+		added, removed := lineChanges(tc.from, tc.to)
+		if added != tc.added || removed != tc.removed {
+			t.Errorf("lineChanges(%q, %q) = +%d -%d, want +%d -%d", tc.from, tc.to, added, removed, tc.added, tc.removed)
+		}
+	}
+}
+
+func TestSyncConflictSummary(t *testing.T) {
+	c := SyncConflict{Dest: "/home/agent/.zshrc", SourceChanged: true, Added: 2, Removed: 1}
+	got := c.Summary()
+	for _, want := range []string{"/home/agent/.zshrc", "source changed", "adds 2 lines, removes 1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Summary() = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestResolveSyncConflictsWithoutHistory(t *testing.T) {
+	// Files the last sync didn't record can't have been edited since, so
+	// nothing is checked in the container and every item is kept.
+	items := []SyncItem{{Dest: "/a", Data: []byte("a")}, {Dest: "/b", Data: []byte("b")}}
+	got, err := resolveSyncConflicts("no-such-container", items, syncedHashes{}, SyncOptions{Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("kept %d items, want 2", len(got))
+	}
+}
+
+func TestApplySyncConflicts(t *testing.T) {
+	items := []SyncItem{{Dest: "/a", Data: []byte("a")}, {Dest: "/b", Data: []byte("b")}}
+	conflicts := []SyncConflict{{Dest: "/a"}}
+
+	if _, err := applySyncConflicts(items, conflicts, SyncOptions{Force: true}); err == nil || !strings.Contains(err.Error(), "/a") {
+		t.Errorf("forced sync without confirmation: err = %v, want the conflict listed", err)
+	}
+
+	// Automatic syncs can't ask, so they leave the edited file be.
+	useRecordingUI(t)
+	resetWarnings(t)
+	got, err := applySyncConflicts(items, conflicts, SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Dest != "/b" {
+		t.Errorf("automatic sync kept %+v, want only /b", got)
+	}
+	if warningCount() != 1 {
+		t.Errorf("want one warning for the skipped file, got %d", warningCount())
+	}
+
+	got, err = applySyncConflicts(items, conflicts, SyncOptions{Force: true, ConfirmOverwrite: func(SyncConflict) bool { return true }})
+	if err != nil || len(got) != 2 {
+		t.Errorf("confirmed overwrite kept %d items, err %v, want both", len(got), err)
+	}
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"sort"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var aiderCmd = &cobra.Command{
+	Use:   "aider [path] [-- aider-args...]",
+	Short: "Open aider in the sandbox",
+	Long: `Open an interactive aider session with --yes-always, so its confirmations
+are skipped (the container is the sandbox). Pass extra arguments to aider
+after --.
+
+Aider commits as the author git uses for the directory on the host, unless
+GIT_AUTHOR_* or GIT_COMMITTER_* are set in env. It gets the keys stored with
+"sandbox set-key" (anthropic, openai, gemini, openrouter, ...), and warns
+when the firewall doesn't allow a stored key's API. ~/.aider, where it keeps
+OAuth keys, is in ~/.claude/aider in the container, so creds_volume persists
+it.
+
+Examples:
+  sandbox aider
+  sandbox aider ~/proj
+  sandbox aider . -- --model sonnet`,
+	DisableFlagParsing: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return runAgentSession(c, args, agentCLI{name: "aider", tool: "aider", flags: []string{"--yes-always"}, prepare: prepareAider})
+	},
+}
+
+// prepareAider passes the host's git identity and warns about stored keys
+// whose APIs the firewall blocks.
+func prepareAider(cfg *cmd.SandboxConfig, workDir string, env map[string]string) {
+	for k, v := range cmd.GitIdentityEnv(cfg, workDir) {
+		env[k] = v
+	}
+	unreachable := cmd.UnreachableKeyProviders(cfg)
+	names := make([]string, 0, len(unreachable))
+	for name := range unreachable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd.Warnf(cmd.WarnFirewall, "a %s key is stored but firewall.allow doesn't include %s, so aider can't use it", name, unreachable[name])
+	}
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(aiderCmd)
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var attachSession string
+
+var attachCmd = &cobra.Command{
+	Use:   "attach [path]",
+	Short: "Reattach to a detached agent session",
+	Long: `Reattach to an agent session started with --detach, such as
+` + "`sandbox claude --detach`" + `. Detach again with Ctrl-b d; the session keeps
+running until the agent exits.
+
+If more than one detached session is running, pick one with --session.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		sandboxRoot, workDir := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+
+		name := cmd.SandboxContainer(sandboxRoot)
+		if !cmd.IsRunning(name) {
+			return cmd.NotRunningError(sandboxRoot)
+		}
+		running, err := cmd.DetachedSessions(name)
+		if err != nil {
+			return err
+		}
+		session, err := pickDetachedSession(running, attachSession)
+		if err != nil {
+			return err
+		}
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		user, attachArgs := cmd.AttachArgs(session)
+		return cmd.DockerExecAs(user, name, workDir, cfg, nil, attachArgs...)
+	},
+}
+
+// pickDetachedSession chooses the session to attach to from those running:
+// want if given, else the only one.
+func pickDetachedSession(running []string, want string) (string, error) {
+	switch {
+	case want != "":
+		if !slices.Contains(running, want) {
+			return "", fmt.Errorf("no detached %s session is running", want)
+		}
+		return want, nil
+	case len(running) == 0:
+		return "", fmt.Errorf("no detached sessions are running; start one with `sandbox claude --detach`")
+	case len(running) > 1:
+		return "", fmt.Errorf("several detached sessions are running (%s); pick one with --session", strings.Join(running, ", "))
+	}
+	return running[0], nil
+}
+
+func init() {
+	attachCmd.Flags().StringVar(&attachSession, "session", "", "detached session to attach to, e.g. claude")
+	cmd.RootCmd.AddCommand(attachCmd)
+}
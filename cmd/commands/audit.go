@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditSince  time.Duration
+	auditUser   string
+	auditKind   string
+	auditFailed bool
+	auditLimit  int
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit [path]",
+	Short: "Show the commands sandbox has run in a workspace's sandbox",
+	Long: `Show the audit log of a workspace's sandbox: every command sandbox ran in
+it with docker exec (sandbox exec, shell, claude and the rest) and every
+on_sync hook, with who ran it, where, when, how long it took and how it
+exited, oldest first.
+
+The log is kept on the host in logs/<container>/audit.jsonl under sandbox's
+cache directory, one JSON object per line. It is only appended to and isn't
+removed by ` + "`sandbox rm`" + `, so what ran in a sandbox can be reviewed after
+it is gone. Commands run over ssh, and what a shell or agent runs once it
+has started, aren't recorded.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		sandboxRoot, _ := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+		if auditKind != "" && auditKind != cmd.AuditExec && auditKind != cmd.AuditHook {
+			return fmt.Errorf("invalid --kind %q, want %q or %q", auditKind, cmd.AuditExec, cmd.AuditHook)
+		}
+		filter := cmd.AuditFilter{User: auditUser, Kind: auditKind, Failed: auditFailed, Limit: auditLimit}
+		if auditSince > 0 {
+			filter.Since = time.Now().Add(-auditSince)
+		}
+		entries, err := cmd.ReadAudit(cmd.SandboxContainer(sandboxRoot), filter)
+		if err != nil {
+			return err
+		}
+		if cmd.JSONOutput() {
+			if entries == nil {
+				entries = []cmd.AuditEntry{}
+			}
+			return cmd.PrintJSON(entries)
+		}
+		if len(entries) == 0 {
+			fmt.Printf("No audited commands for %s\n", sandboxRoot)
+			return nil
+		}
+		fmt.Print(cmd.FormatAuditTable(entries))
+		return nil
+	},
+}
+
+func init() {
+	auditCmd.Flags().DurationVar(&auditSince, "since", 0, "only commands started within this long, e.g. 24h")
+	auditCmd.Flags().StringVar(&auditUser, "user", "", "only commands run as this user (agent or root)")
+	auditCmd.Flags().StringVar(&auditKind, "kind", "", "only exec or hook entries")
+	auditCmd.Flags().BoolVar(&auditFailed, "failed", false, "only commands that failed")
+	auditCmd.Flags().IntVarP(&auditLimit, "limit", "n", 0, "only the last n matching commands")
+	cmd.RootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var batchLogDir string
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <file.yaml> [path]",
+	Short: "Run a pipeline of steps in the sandbox unattended",
+	Long: `Run the steps in a batch file one after another in the sandbox, for
+unattended workflows such as "fix the tests, then run the linter". Each step
+is one of:
+
+  run: <shell command>             run with sh -c
+  agent: <name>  prompt: <text>    claude, codex, gemini, aider, or an agent
+                                   under agents:, run non-interactively
+  sync: true                       push config changes into the sandbox
+
+Steps can have a name, a timeout such as 20m, and continue_on_error: true.
+A failing step stops the batch unless it sets continue_on_error. Each step's
+output is shown and also written to its own log (under --logs, by default
+a new directory in sandbox's cache), and a summary is printed at the end.
+sandbox exits with status 1 if any step failed or timed out.
+
+Example file:
+
+  steps:
+    - name: fix tests
+      agent: claude
+      prompt: Fix the failing tests in ./pkg/...
+      timeout: 30m
+    - name: test
+      run: go test ./...
+    - name: lint
+      run: golangci-lint run
+      continue_on_error: true`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(_ *cobra.Command, args []string) error {
+		b, err := cmd.LoadBatchFile(args[0])
+		if err != nil {
+			return err
+		}
+		wsPath := "."
+		if len(args) > 1 {
+			wsPath = args[1]
+		}
+		sandboxRoot, workDir := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+
+		name, err := cmd.EnsureRunning(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		logDir := batchLogDir
+		if logDir == "" {
+			layout, err := cmd.ActiveLayout()
+			if err != nil {
+				return err
+			}
+			logDir = filepath.Join(layout.Cache, "batch", name+"-"+time.Now().Format("20060102-150405"))
+		}
+		extraEnv, endSession, err := startHostToolSession(cfg, sandboxRoot)
+		if err != nil {
+			return err
+		}
+		defer endSession()
+
+		results, err := cmd.RunBatch(cmd.BatchRun{
+			Container:   name,
+			SandboxRoot: sandboxRoot,
+			WorkDir:     workDir,
+			Config:      cfg,
+			Env:         extraEnv,
+			LogDir:      logDir,
+		}, b)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		fmt.Print(cmd.FormatBatchSummary(results))
+		if n := cmd.BatchFailures(results); n > 0 {
+			fmt.Printf("%d of %d steps failed\n", n, len(results))
+			return &cmd.ExitError{Code: 1}
+		}
+		return nil
+	},
+}
+
+func init() {
+	batchCmd.Flags().StringVar(&batchLogDir, "logs", "", "directory for step logs (default: a new directory in sandbox's cache)")
+	cmd.RootCmd.AddCommand(batchCmd)
+}
@@ -1,19 +1,87 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 
 	cmd "github.com/franklin-ross/sandbox/cmd"
 	"github.com/spf13/cobra"
 )
 
+var (
+	buildVerbose   bool
+	buildPlatforms []string
+	buildPush      string
+	buildVariant   string
+	buildNoCache   bool
+	buildPull      bool
+)
+
 var buildCmd = &cobra.Command{
-	Use:   "build",
+	Use:   "build [path]",
 	Short: "Force rebuild the sandbox image",
-	Args:  cobra.NoArgs,
+	Long: `Rebuild the sandbox image with BuildKit. Unchanged layers come from Docker's
+build cache, and apt and npm downloads from BuildKit cache mounts, so only
+the layers after a change are redone. image.build_args in the global config
+are passed as --build-arg.
+
+The image is built for image.platform, or the Docker host's own platform,
+as image.variant (full, or minimal without Chromium, Rust and Ruby), and
+tagged per variant and architecture (sandbox:full-amd64,
+sandbox:minimal-arm64). A workspace whose config pins toolchains versions
+gets an image of its own, built for the workspace at path (default the
+current directory). When the global config sets image.pull, that image is
+pulled again instead, but for pinned toolchains. The workspace's
+image.packages are then installed on top, as an image of their own.
+
+Each step is printed as it finishes, with how long it took or whether it
+came from the cache. --no-cache redoes every step, though apt and npm
+downloads still come from the cache mounts, and --pull fetches the latest
+Debian base image first.
+
+--push builds for linux/amd64 and linux/arm64 (or --platform) with buildx
+and pushes the result to a registry, for a team to set as image.pull.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		cfg, err := cmd.LoadConfig(cmd.SandboxRootFor(cmd.ResolvePath(wsPath)))
+		if err != nil && !errors.Is(err, cmd.ErrNoConfig) {
+			return err
+		}
+		opts := cmd.BuildOptions{
+			Verbose:   buildVerbose,
+			Steps:     true,
+			NoCache:   buildNoCache,
+			PullBase:  buildPull,
+			Platforms: buildPlatforms,
+			Push:      buildPush,
+			Variant:   buildVariant,
+		}
+		if cfg != nil {
+			opts.Toolchains, opts.Packages = cfg.Toolchains, cfg.Image.Packages
+		}
+		if opts.Push == "" && cmd.ImagePull() != "" && opts.Toolchains == (cmd.ToolchainsConfig{}) {
+			platform := cmd.ImagePlatform()
+			if len(buildPlatforms) == 1 {
+				platform = buildPlatforms[0]
+			}
+			fmt.Printf("Pulling %s...\n", cmd.ImagePull())
+			if err := cmd.PullImage(platform); err != nil {
+				return err
+			}
+			if len(opts.Packages) > 0 {
+				if err := cmd.BuildPackages(opts); err != nil {
+					return err
+				}
+			}
+			fmt.Println("Done.")
+			return nil
+		}
 		fmt.Println("Building sandbox image...")
-		if err := cmd.BuildImage(cmd.ImageHash()); err != nil {
+		if err := cmd.BuildImage(opts); err != nil {
 			return err
 		}
 		fmt.Println("Done.")
@@ -22,5 +90,11 @@ var buildCmd = &cobra.Command{
 }
 
 func init() {
+	buildCmd.Flags().BoolVarP(&buildVerbose, "verbose", "v", false, "show docker build's full progress output")
+	buildCmd.Flags().StringSliceVar(&buildPlatforms, "platform", nil, "platform to build for, e.g. linux/amd64 (default: image.platform, or the Docker host's)")
+	buildCmd.Flags().StringVar(&buildPush, "push", "", "build for every platform and push to this registry image, e.g. ghcr.io/team/sandbox:latest")
+	buildCmd.Flags().StringVar(&buildVariant, "variant", "", "image variant to build, full or minimal (default: image.variant, or full)")
+	buildCmd.Flags().BoolVar(&buildNoCache, "no-cache", false, "rebuild every layer instead of using Docker's build cache")
+	buildCmd.Flags().BoolVar(&buildPull, "pull", false, "pull the latest base image before building")
 	cmd.RootCmd.AddCommand(buildCmd)
 }
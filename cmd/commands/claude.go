@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	cmd "github.com/franklin-ross/sandbox/cmd"
@@ -9,64 +10,181 @@ import (
 )
 
 var claudeCmd = &cobra.Command{
-	Use:   "claude [path] [-- claude-args...]",
+	Use:   "claude [path] [--resume <id>] [--detach] [-- claude-args...]",
 	Short: "Open Claude Code in the sandbox",
 	Long: `Open an interactive Claude Code session with --dangerously-skip-permissions.
 Pass extra arguments to Claude after --.
 
+--resume picks up a session listed by ` + "`sandbox sessions ls`" + `, by its ID or
+any unique prefix of it.
+
+--detach starts Claude in a tmux session in the sandbox and returns straight
+away. Reattach with ` + "`sandbox attach`" + `, and detach again with Ctrl-b d; the
+session carries on through terminal disconnects and laptop sleeps.
+
 Examples:
   sandbox claude
   sandbox claude ~/proj
+  sandbox claude --resume 3f2a
+  sandbox claude --detach . -- -p "upgrade the dependencies"
   sandbox claude . -- -p "fix the tests"`,
 	DisableFlagParsing: true,
 	RunE: func(c *cobra.Command, args []string) error {
-		for _, a := range args {
-			if a == "-h" || a == "--help" {
-				return c.Help()
+		agent := agentCLI{name: "claude", tool: "claude", flags: []string{"--dangerously-skip-permissions"}}
+		var err error
+		if agent.resume, agent.detach, args, err = splitClaudeArgs(args); err != nil {
+			return err
+		}
+		return runAgentSession(c, args, agent)
+	},
+}
+
+// splitClaudeArgs removes sandbox's own flags from the args before "--":
+// --resume <id> (or --resume=<id>) and --detach.
+func splitClaudeArgs(args []string) (resume string, detach bool, rest []string, err error) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			return resume, detach, append(rest, args[i:]...), nil
+		case a == "--resume":
+			if i+1 == len(args) || args[i+1] == "--" {
+				return "", false, nil, fmt.Errorf("--resume needs a session ID (see `sandbox sessions ls`)")
 			}
+			i++
+			resume = args[i]
+		case strings.HasPrefix(a, "--resume="):
+			resume = strings.TrimPrefix(a, "--resume=")
+		case a == "--detach":
+			detach = true
+		default:
+			rest = append(rest, a)
 		}
+	}
+	return resume, detach, rest, nil
+}
 
-		wsPath, claudeArgs := parseClaudeArgs(args)
-		sandboxRoot, workDir := cmd.ResolveWorkspace(wsPath)
+// agentCLI is an agent that runAgentSession can open a session of.
+type agentCLI struct {
+	name  string   // the key of its commands: entry
+	tool  string   // executable; for configured agents, set from agents:
+	flags []string // always passed first, e.g. to skip permission prompts
 
-		name, err := cmd.EnsureRunning(sandboxRoot)
-		if err != nil {
-			return err
+	// configured agents are looked up under agents: by name.
+	configured bool
+
+	// resume is a Claude session ID, or a prefix of one, to resume.
+	resume string
+	// detach runs the session in tmux for `sandbox attach`.
+	detach bool
+
+	// prepare, if set, runs just before the session starts and may add
+	// variables to env.
+	prepare func(cfg *cmd.SandboxConfig, workDir string, env map[string]string)
+}
+
+// runAgentSession opens an interactive session of an agent CLI in the
+// sandbox: args are parsed by parseSessionArgs, and the CLI is run with its
+// flags, then the commands.<name> args, then the user's args.
+func runAgentSession(c *cobra.Command, args []string, agent agentCLI) error {
+	for _, a := range args {
+		if a == "-h" || a == "--help" {
+			return c.Help()
 		}
+	}
 
-		cfg, err := cmd.LoadConfig(sandboxRoot)
-		if err != nil {
+	wsPath, toolArgs := parseSessionArgs(args)
+	sandboxRoot, workDir := cmd.ResolveWorkspace(wsPath)
+
+	name, err := cmd.EnsureRunning(sandboxRoot)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := cmd.LoadConfig(sandboxRoot)
+	if err != nil {
+		return err
+	}
+	if agent.configured {
+		if agent, err = configuredAgent(cfg, agent); err != nil {
 			return err
 		}
+	}
 
-		var extraEnv map[string]string
-		if len(cfg.HostTools) > 0 {
-			port := cfg.EffectiveHostToolPort()
-			if err := cmd.EnsureHostToolDaemon(port); err != nil {
-				return fmt.Errorf("host tool daemon: %w", err)
-			}
-			sessionID := cmd.GenerateSessionID()
-			if err := cmd.RegisterHostToolSession(port, sessionID, cfg.HostTools, sandboxRoot); err != nil {
-				return fmt.Errorf("register host tool session: %w", err)
-			}
-			defer cmd.UnregisterHostToolSession(port, sessionID)
+	extraEnv, endSession, err := startHostToolSession(cfg, sandboxRoot)
+	if err != nil {
+		return err
+	}
+	// A detached session unregisters itself from inside the container when
+	// it ends.
+	detached := false
+	defer func() {
+		if !detached {
+			endSession()
+		}
+	}()
 
-			extraEnv = map[string]string{
-				"SANDBOX_SESSION":       sessionID,
-				"SANDBOX_HOSTTOOL_PORT": fmt.Sprintf("%d", port),
-			}
+	cc := cfg.Command(agent.name)
+	workDir = cc.Dir(sandboxRoot, workDir)
+	if agent.prepare != nil {
+		if extraEnv == nil {
+			extraEnv = make(map[string]string)
+		}
+		agent.prepare(cfg, workDir, extraEnv)
+	}
+	if agent.resume != "" {
+		sessions, err := cmd.ClaudeSessions(name, workDir)
+		if err != nil {
+			return err
+		}
+		s, err := cmd.FindClaudeSession(sessions, agent.resume)
+		if err != nil {
+			return err
+		}
+		toolArgs = append([]string{"--resume", s.ID}, toolArgs...)
+	}
+	cmd.PrintBanner(name, sandboxRoot, workDir, cfg)
+	execArgs := append([]string{agent.tool}, agent.flags...)
+	execArgs = append(execArgs, cc.Args...)
+	execArgs = append(execArgs, toolArgs...)
+	execArgs, user, cancelLimit := cmd.ApplySessionLimit(name, cfg, execArgs)
+	defer cancelLimit()
+	if agent.detach {
+		if err := startDetached(name, user, workDir, cfg, agent, extraEnv, execArgs); err != nil {
+			return err
 		}
+		detached = true
+		fmt.Printf("%s is running detached; attach with `sandbox attach %s`\n", agent.tool, sandboxRoot)
+		return nil
+	}
+	err = cmd.DockerExecAs(user, name, workDir, cfg.ForCommand(agent.name), extraEnv, execArgs...)
+	cmd.NotifySessionEnded(cfg, name, sandboxRoot, agent.tool, err)
+	return err
+}
 
-		execArgs := []string{"claude", "--dangerously-skip-permissions"}
-		execArgs = append(execArgs, claudeArgs...)
-		return cmd.DockerExec(name, workDir, cfg, extraEnv, execArgs...)
-	},
+// startDetached starts execArgs in a detached tmux session named after the
+// agent, refusing if one is already running.
+func startDetached(container, user, workDir string, cfg *cmd.SandboxConfig, agent agentCLI, env map[string]string, execArgs []string) error {
+	running, err := cmd.DetachedSessions(container)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(running, agent.name) {
+		return fmt.Errorf("a detached %s session is already running; attach to it with `sandbox attach`", agent.tool)
+	}
+	// The timer behind other timeout actions lives in this process, which
+	// is about to exit; a stop timeout runs in the container and still works.
+	if cfg.Limits.SessionTimeoutDuration() > 0 && cfg.Limits.TimeoutAction() != cmd.TimeoutStop {
+		cmd.Warnf(cmd.WarnContainer, "limits.on_timeout %q does not apply to detached sessions", cfg.Limits.TimeoutAction())
+	}
+	return cmd.DockerExecAs(user, container, workDir, cfg.ForCommand(agent.name), env, cmd.DetachedArgs(agent.name, execArgs)...)
 }
 
-// parseClaudeArgs splits args into a workspace path and extra claude flags.
-// Everything after "--" is passed to claude. The first positional arg before
-// "--" (if it doesn't start with "-") is treated as the workspace path.
-func parseClaudeArgs(args []string) (string, []string) {
+// parseSessionArgs splits args into a workspace path and extra flags for the
+// agent CLI (claude or codex). Everything after "--" is passed to the CLI. The
+// first positional arg before "--" (if it doesn't start with "-") is treated
+// as the workspace path.
+func parseSessionArgs(args []string) (string, []string) {
 	var positional []string
 	var claudeArgs []string
 	pastSep := false
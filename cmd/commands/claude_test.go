@@ -53,7 +53,7 @@ func TestParseClaudeArgs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotPath, gotClaude := parseClaudeArgs(tt.args)
+			gotPath, gotClaude := parseSessionArgs(tt.args)
 
 			if tt.wantPath == "." {
 				if !filepath.IsAbs(gotPath) {
@@ -72,7 +72,7 @@ func TestParseClaudeArgs(t *testing.T) {
 
 func TestParseClaudeArgsMultipleSeparators(t *testing.T) {
 	args := []string{"/tmp/proj", "--", "--", "-p", "hello"}
-	path, claudeArgs := parseClaudeArgs(args)
+	path, claudeArgs := parseSessionArgs(args)
 
 	if path != "/tmp/proj" {
 		t.Errorf("path = %q, want /tmp/proj", path)
@@ -86,7 +86,7 @@ func TestParseClaudeArgsMultipleSeparators(t *testing.T) {
 
 func TestParseClaudeArgsOnlySeparator(t *testing.T) {
 	args := []string{"--"}
-	path, claudeArgs := parseClaudeArgs(args)
+	path, claudeArgs := parseSessionArgs(args)
 
 	if !filepath.IsAbs(path) {
 		t.Errorf("path = %q, want absolute", path)
@@ -98,7 +98,7 @@ func TestParseClaudeArgsOnlySeparator(t *testing.T) {
 
 func TestParseClaudeArgsEmptyString(t *testing.T) {
 	args := []string{""}
-	path, claudeArgs := parseClaudeArgs(args)
+	path, claudeArgs := parseSessionArgs(args)
 
 	if !filepath.IsAbs(path) {
 		t.Errorf("path = %q, want absolute", path)
@@ -110,7 +110,7 @@ func TestParseClaudeArgsEmptyString(t *testing.T) {
 
 func TestParseClaudeArgsExtraPositionalIgnored(t *testing.T) {
 	args := []string{"/tmp/proj", "extra-arg", "--", "-p", "hello"}
-	path, claudeArgs := parseClaudeArgs(args)
+	path, claudeArgs := parseSessionArgs(args)
 
 	if path != "/tmp/proj" {
 		t.Errorf("path = %q, want /tmp/proj", path)
@@ -123,7 +123,7 @@ func TestParseClaudeArgsExtraPositionalIgnored(t *testing.T) {
 
 func TestParseClaudeArgsDoesNotTreatFlagAsPath(t *testing.T) {
 	args := []string{"-p", "hello"}
-	path, _ := parseClaudeArgs(args)
+	path, _ := parseSessionArgs(args)
 
 	if strings.HasSuffix(path, "-p") {
 		t.Errorf("flag -p was incorrectly treated as a path: %q", path)
@@ -144,3 +144,50 @@ func stringSliceEqual(a, b []string) bool {
 	}
 	return true
 }
+
+func TestSplitClaudeArgs(t *testing.T) {
+	tests := []struct {
+		args       []string
+		wantResume string
+		wantDetach bool
+		wantRest   []string
+		wantErr    bool
+	}{
+		{args: []string{"~/proj"}, wantRest: []string{"~/proj"}},
+		{args: []string{"--resume", "3f2a", "~/proj"}, wantResume: "3f2a", wantRest: []string{"~/proj"}},
+		{args: []string{"--resume=3f2a", "--", "-p", "go on"}, wantResume: "3f2a", wantRest: []string{"--", "-p", "go on"}},
+		{args: []string{"--detach", ".", "--", "-p", "x"}, wantDetach: true, wantRest: []string{".", "--", "-p", "x"}},
+		{args: []string{".", "--", "--resume", "x", "--detach"}, wantRest: []string{".", "--", "--resume", "x", "--detach"}},
+		{args: []string{"--resume"}, wantErr: true},
+		{args: []string{"--resume", "--", "-p"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		resume, detach, rest, err := splitClaudeArgs(tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitClaudeArgs(%q) succeeded, want error", tt.args)
+			}
+			continue
+		}
+		if err != nil || resume != tt.wantResume || detach != tt.wantDetach || strings.Join(rest, " ") != strings.Join(tt.wantRest, " ") {
+			t.Errorf("splitClaudeArgs(%q) = %q, %v, %q, %v; want %q, %v, %q", tt.args, resume, detach, rest, err, tt.wantResume, tt.wantDetach, tt.wantRest)
+		}
+	}
+}
+
+func TestPickDetachedSession(t *testing.T) {
+	if got, err := pickDetachedSession([]string{"claude"}, ""); err != nil || got != "claude" {
+		t.Errorf("only session: got %q, %v", got, err)
+	}
+	if got, err := pickDetachedSession([]string{"claude", "codex"}, "codex"); err != nil || got != "codex" {
+		t.Errorf("--session codex: got %q, %v", got, err)
+	}
+	for _, tt := range []struct {
+		running []string
+		want    string
+	}{{nil, ""}, {[]string{"claude", "codex"}, ""}, {[]string{"claude"}, "codex"}} {
+		if got, err := pickDetachedSession(tt.running, tt.want); err == nil {
+			t.Errorf("pickDetachedSession(%q, %q) = %q, want error", tt.running, tt.want, got)
+		}
+	}
+}
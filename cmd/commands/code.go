@@ -14,7 +14,12 @@ import (
 var codeCmd = &cobra.Command{
 	Use:   "code [path]",
 	Short: "Open VSCode attached to the sandbox",
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Open VSCode attached to the sandbox container.
+
+Extensions listed under vscode.extensions in the config are installed in the
+container's VSCode server: straight away if VSCode has attached before, and
+otherwise by VSCode as it attaches.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(_ *cobra.Command, args []string) error {
 		wsPath := "."
 		if len(args) > 0 {
@@ -27,6 +32,13 @@ var codeCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		if err := cmd.PrepareVSCode(name, cfg); err != nil {
+			cmd.Warnf(cmd.WarnContainer, "VSCode extensions: %v", err)
+		}
 
 		out, err := exec.Command("docker", "inspect", "-f", "{{.Id}}", name).Output()
 		if err != nil {
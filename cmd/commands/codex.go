@@ -0,0 +1,31 @@
+package commands
+
+import (
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var codexCmd = &cobra.Command{
+	Use:   "codex [path] [-- codex-args...]",
+	Short: "Open OpenAI Codex in the sandbox",
+	Long: `Open an interactive Codex CLI session with approvals and Codex's own
+sandbox turned off (the container is the sandbox). Pass extra arguments to
+Codex after --.
+
+Codex uses OPENAI_API_KEY when one is stored with "sandbox set-key openai";
+otherwise sign in from the session. Its login is kept in ~/.claude/codex in
+the container, so creds_volume persists it along with Claude's.
+
+Examples:
+  sandbox codex
+  sandbox codex ~/proj
+  sandbox codex . -- exec "fix the tests"`,
+	DisableFlagParsing: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return runAgentSession(c, args, agentCLI{name: "codex", tool: "codex", flags: []string{"--dangerously-bypass-approvals-and-sandbox"}})
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(codexCmd)
+}
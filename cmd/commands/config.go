@@ -1,12 +1,18 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
 	cmd "github.com/franklin-ross/sandbox/cmd"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
 )
 
 var configCmd = &cobra.Command{
@@ -15,19 +21,38 @@ var configCmd = &cobra.Command{
 	Long:  `View and manage sandbox configuration files.`,
 }
 
+var configInitWorkspace bool
+
 var configInitCmd = &cobra.Command{
-	Use:   "init",
+	Use:   "init [--workspace [path]]",
 	Short: "Initialize sandbox configuration",
-	Long:  `Create the default sandbox configuration file and home directory.`,
-	Args:  cobra.NoArgs,
+	Long: `Create the default sandbox configuration file and home directory.
+
+With --workspace, scaffold <path>/.sandbox/config.yaml instead (path defaults
+to the current directory). The workspace template adds nothing until
+edited: an empty firewall allowlist plus commented-out sync, env and
+on_sync examples.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(_ *cobra.Command, args []string) error {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("get home directory: %w", err)
+		if configInitWorkspace {
+			wsPath := "."
+			if len(args) > 0 {
+				wsPath = args[0]
+			}
+			return initWorkspaceConfig(cmd.ResolvePath(wsPath))
+		}
+		if len(args) > 0 {
+			return fmt.Errorf("a path is only accepted with --workspace")
 		}
 
-		configPath := filepath.Join(home, ".sandbox", "config.yaml")
-		homePath := filepath.Join(home, ".sandbox", "home")
+		configPath, err := cmd.GlobalConfigPath()
+		if err != nil {
+			return err
+		}
+		homePath, err := cmd.HomeFilesDir()
+		if err != nil {
+			return err
+		}
 		zshrcPath := filepath.Join(homePath, ".zshrc")
 
 		configExists := fileExists(configPath)
@@ -67,12 +92,289 @@ var configInitCmd = &cobra.Command{
 	},
 }
 
+// initWorkspaceConfig writes the workspace config template unless the
+// workspace already has a config.
+func initWorkspaceConfig(wsPath string) error {
+	path := cmd.WorkspaceConfigPath(wsPath)
+	if fileExists(path) {
+		fmt.Printf("Already exists: %s\n", path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(cmd.DefaultWorkspaceConfigYAML), 0644); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	fmt.Printf("Created %s\n", path)
+	return nil
+}
+
+var configCaptureCmd = &cobra.Command{
+	Use:   "capture [path]",
+	Short: "Propose config that reproduces a running sandbox",
+	Long: `Compare a running sandbox against a fresh container from the same image and
+print config that would reproduce the differences: on_sync hooks for extra apt
+and global npm packages, and env entries for variables set in the shell but
+not in config. Review the output and copy what you want into
+<workspace>/.sandbox/config.yaml.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		wsPath = cmd.ResolvePath(wsPath)
+		sandboxRoot, _ := cmd.ResolveWorkspace(wsPath)
+
+		name := cmd.SandboxContainer(sandboxRoot)
+		if !cmd.IsRunning(name) {
+			return cmd.NotRunningError(sandboxRoot)
+		}
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(os.Stderr, "Comparing against a fresh container...")
+		proposal, err := cmd.CaptureContainer(name, cfg)
+		if err != nil {
+			return err
+		}
+		if len(proposal.OnSync) == 0 && len(proposal.Env) == 0 {
+			fmt.Fprintln(os.Stderr, "Nothing to capture; the sandbox matches its image and config.")
+			return nil
+		}
+
+		out, err := yaml.Marshal(proposal)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# Proposed additions for %s\n", cmd.WorkspaceConfigPath(sandboxRoot))
+		fmt.Print(string(out))
+		return nil
+	},
+}
+
+var configEditWorkspace bool
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open a config file in your editor",
+	Long: `Open the global config, or the current workspace's config with --workspace,
+in $VISUAL or $EDITOR (falling back to vi). A missing file starts from the
+default template.
+
+The edit is made on a temporary copy and checked when the editor exits. If
+the config has problems they are listed and you can re-open the editor to fix
+them; the file is only replaced once it is clean.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		sandboxRoot, _ := cmd.ResolveWorkspace(cmd.ResolvePath("."))
+		path := cmd.WorkspaceConfigPath(sandboxRoot)
+		template := cmd.DefaultWorkspaceConfigYAML
+		if !configEditWorkspace {
+			var err error
+			if path, err = cmd.GlobalConfigPath(); err != nil {
+				return err
+			}
+			template = cmd.DefaultConfigYAML
+		}
+		return editConfigFile(path, template, configEditWorkspace)
+	},
+}
+
+// editConfigFile edits a temporary copy of path (or of template if path
+// doesn't exist) until it has no problems, then writes it back.
+func editConfigFile(path, template string, workspace bool) error {
+	original, err := os.ReadFile(path)
+	exists := err == nil
+	switch {
+	case os.IsNotExist(err):
+		original = []byte(template)
+	case err != nil:
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	tmp, err := os.CreateTemp("", "sandbox-config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(original)
+	tmp.Close()
+	if err != nil {
+		return fmt.Errorf("write temp file: %w", err)
+	}
+
+	for {
+		if err := runEditor(tmpPath); err != nil {
+			return fmt.Errorf("editor: %w (your edits are in %s)", err, tmpPath)
+		}
+		edited, err := os.ReadFile(tmpPath)
+		if err != nil {
+			return fmt.Errorf("read edited config: %w", err)
+		}
+		if exists && bytes.Equal(edited, original) {
+			os.Remove(tmpPath)
+			fmt.Println("No changes")
+			return nil
+		}
+
+		problems := cmd.ValidateConfig(edited, workspace)
+		if len(problems) == 0 {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("create config directory: %w", err)
+			}
+			if err := os.WriteFile(path, edited, 0644); err != nil {
+				return fmt.Errorf("write config: %w", err)
+			}
+			os.Remove(tmpPath)
+			fmt.Printf("Updated %s\n", path)
+			return nil
+		}
+
+		fmt.Fprintf(os.Stderr, "%s has problems:\n", path)
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "  %v\n", p)
+		}
+		if !confirm("Edit again?") {
+			return fmt.Errorf("config not saved; your edits are in %s", tmpPath)
+		}
+	}
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, or vi. The variable may include
+// arguments, e.g. "code --wait".
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	c := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return c.Run()
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to yes. It
+// answers no when stdin isn't a terminal.
+func confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [Y/n] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [path]",
+	Short: "Check config files for errors",
+	Long: `Strictly check the global config and the workspace config for path. Unlike
+loading, which warns about invalid entries and carries on without them, this
+reports every problem with its line number: malformed YAML, unknown keys,
+values of the wrong type, invalid CIDRs, ports, modes and owners, and
+anything else loading would skip. Exits non-zero if there are problems.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		sandboxRoot, _ := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+
+		globalPath, err := cmd.GlobalConfigPath()
+		if err != nil {
+			return err
+		}
+		problems, checked := 0, 0
+		for _, f := range []struct {
+			path      string
+			workspace bool
+		}{{globalPath, false}, {cmd.WorkspaceConfigPath(sandboxRoot), true}} {
+			data, err := os.ReadFile(f.path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("read config: %w", err)
+			}
+			checked++
+			errs := cmd.ValidateConfig(data, f.workspace)
+			for _, e := range errs {
+				if e.Line > 0 {
+					fmt.Printf("%s:%d: %s\n", f.path, e.Line, e.Msg)
+				} else {
+					fmt.Printf("%s: %s\n", f.path, e.Msg)
+				}
+			}
+			if len(errs) == 0 {
+				fmt.Printf("%s: ok\n", f.path)
+			}
+			problems += len(errs)
+		}
+		switch {
+		case checked == 0:
+			return &cmd.Error{Kind: cmd.KindConfig, Err: cmd.ErrNoConfig}
+		case problems > 0:
+			return &cmd.Error{Kind: cmd.KindConfig, Err: fmt.Errorf("%d problem(s) found", problems)}
+		}
+		return nil
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show [path]",
+	Short: "Print the effective config for a workspace",
+	Long: `Print the global and workspace configs merged as they are for a sandbox,
+with a comment after each value saying which file it came from. Env values
+that look like credentials are masked; op://, vault: and $VAR references
+are shown as written. With --json, the config and a map of value paths to
+their sources are printed as JSON.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		wsPath = cmd.ResolvePath(wsPath)
+		sandboxRoot, _ := cmd.ResolveWorkspace(wsPath)
+
+		view, err := cmd.ExplainConfig(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		var out []byte
+		if cmd.JSONOutput() {
+			out, err = view.JSON()
+			out = append(out, '\n')
+		} else {
+			out, err = view.YAML()
+		}
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(out)
+		return nil
+	},
+}
+
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
 
 func init() {
+	configInitCmd.Flags().BoolVar(&configInitWorkspace, "workspace", false, "create a workspace config in path (default: current directory)")
 	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configCaptureCmd)
+	configEditCmd.Flags().BoolVar(&configEditWorkspace, "workspace", false, "edit the current workspace's config instead of the global one")
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
 	cmd.RootCmd.AddCommand(configCmd)
 }
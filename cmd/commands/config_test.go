@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeEditor points $EDITOR at a script that replaces the file with content.
+func fakeEditor(t *testing.T, content string) {
+	t.Helper()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "new.yaml"), []byte(content), 0644)
+	script := filepath.Join(dir, "editor")
+	os.WriteFile(script, []byte("#!/bin/sh\ncp "+filepath.Join(dir, "new.yaml")+" \"$1\"\n"), 0755)
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", script)
+}
+
+func TestEditConfigFile(t *testing.T) {
+	t.Run("creates missing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".sandbox", "config.yaml")
+		fakeEditor(t, "env:\n  A: b\n")
+		if err := editConfigFile(path, "# template\n", true); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := os.ReadFile(path)
+		if string(got) != "env:\n  A: b\n" {
+			t.Errorf("config = %q", got)
+		}
+	})
+
+	t.Run("invalid edit not saved", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte("env: {}\n"), 0644)
+		fakeEditor(t, "limits:\n  on_timeout: explode\n")
+		err := editConfigFile(path, "", false)
+		if err == nil || !strings.Contains(err.Error(), "not saved") {
+			t.Fatalf("err = %v, want not saved", err)
+		}
+		got, _ := os.ReadFile(path)
+		if string(got) != "env: {}\n" {
+			t.Errorf("config was overwritten: %q", got)
+		}
+	})
+
+	t.Run("unchanged file left alone", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte("env: {}\n"), 0644)
+		fakeEditor(t, "env: {}\n")
+		if err := editConfigFile(path, "", false); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestInitWorkspaceConfig(t *testing.T) {
+	ws := t.TempDir()
+	if err := initWorkspaceConfig(ws); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(ws, ".sandbox", "config.yaml")
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "allow: []") {
+		t.Errorf("template missing empty allowlist:\n%s", got)
+	}
+
+	os.WriteFile(path, []byte("env: {A: b}\n"), 0644)
+	if err := initWorkspaceConfig(ws); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "env: {A: b}\n" {
+		t.Errorf("existing config overwritten: %q", got)
+	}
+}
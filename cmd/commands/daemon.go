@@ -10,20 +10,32 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var hostToolDaemonPort int
+var (
+	hostToolDaemonPort    int
+	hostToolDaemonMetrics string
+)
 
 var hostToolDaemonCmd = &cobra.Command{
-	Use:    "daemon",
-	Short:  "Run the host tool daemon (internal)",
-	Hidden: true,
+	Use:   "daemon",
+	Short: "Run the host tool daemon",
+	Long: `Run the daemon that executes host tools for sandbox sessions. Sessions start
+it on demand and it exits when the last one ends, so it rarely needs running
+by hand.
+
+With --metrics it also serves Prometheus metrics at http://<addr>/metrics and
+keeps running with no sessions, so it can run as a service. Per sandbox, it
+reports syncs, sync failures and time, sessions and commands started, packets
+the firewall rejected, container restarts, and whether the container is up.
+Sync and exec counts cover what happened while the daemon was running.`,
 	RunE: func(_ *cobra.Command, _ []string) error {
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer stop()
-		return cmd.RunHostToolDaemon(ctx, hostToolDaemonPort)
+		return cmd.RunHostToolDaemon(ctx, hostToolDaemonPort, hostToolDaemonMetrics)
 	},
 }
 
 func init() {
 	hostToolDaemonCmd.Flags().IntVar(&hostToolDaemonPort, "port", cmd.DefaultHostToolPort, "TCP port to listen on")
+	hostToolDaemonCmd.Flags().StringVar(&hostToolDaemonMetrics, "metrics", "", "serve Prometheus metrics on this address, e.g. 127.0.0.1:9848")
 	cmd.RootCmd.AddCommand(hostToolDaemonCmd)
 }
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var dnsBlocked bool
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns [path]",
+	Short: "Show names looked up inside a sandbox",
+	Long: `List the DNS names a running sandbox has looked up through its caching
+resolver, with lookup counts and whether firewall.allow covers each one.
+Use --blocked to list only names that are not on the allowlist, which are
+candidates for new firewall entries.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		wsPath = cmd.ResolvePath(wsPath)
+		sandboxRoot, _ := cmd.ResolveWorkspace(wsPath)
+
+		name := cmd.SandboxContainer(sandboxRoot)
+		if !cmd.IsRunning(name) {
+			return cmd.NotRunningError(sandboxRoot)
+		}
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		queries, err := cmd.DNSQueries(name, cfg)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NAME\tLOOKUPS\tALLOWED")
+		for _, q := range queries {
+			if dnsBlocked && q.Allowed {
+				continue
+			}
+			allowed := "no"
+			if q.Allowed {
+				allowed = "yes"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\n", q.Name, q.Count, allowed)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	dnsCmd.Flags().BoolVar(&dnsBlocked, "blocked", false, "only show names not covered by firewall.allow")
+	cmd.RootCmd.AddCommand(dnsCmd)
+}
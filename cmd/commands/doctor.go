@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [path]",
+	Short: "Check this machine can run sandboxes",
+	Long: `Check that docker is installed and its daemon reachable, that the config for
+path loads cleanly, and that every tool listed under requires is installed at
+a suitable version. Problems are listed with installation hints, and the
+exit status is non-zero if any were found.
+
+Commands that start a sandbox check requires too, and stop before doing
+anything if one isn't met.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		sandboxRoot, _ := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+
+		failed := 0
+		result := doctorResult{Checks: []doctorCheck{}}
+		report := func(what string, err error) {
+			c := doctorCheck{Check: what, OK: err == nil}
+			if err != nil {
+				failed++
+				c.Error = cmd.Redact(err.Error())
+			}
+			result.Checks = append(result.Checks, c)
+			switch {
+			case cmd.JSONOutput():
+			case err != nil:
+				fmt.Printf("FAIL  %s: %v\n", what, err)
+			default:
+				fmt.Printf("ok    %s\n", what)
+			}
+		}
+
+		report("docker", cmd.DockerAvailable())
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		report("config", err)
+		if cfg != nil {
+			for _, r := range cfg.Requires {
+				report(r.Spec, r.Check())
+			}
+		}
+
+		if failed > 0 {
+			result.Error = fmt.Sprintf("%d check(s) failed", failed)
+		}
+		if cmd.JSONOutput() {
+			cmd.PrintJSON(result)
+		}
+		if result.Error != "" {
+			return errors.New(result.Error)
+		}
+		return nil
+	},
+}
+
+// doctorResult is doctor's --json result.
+type doctorResult struct {
+	Checks []doctorCheck `json:"checks"`
+	Error  string        `json:"error,omitempty"`
+}
+
+type doctorCheck struct {
+	Check string `json:"check"` // docker, config or a requires entry
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(doctorCmd)
+}
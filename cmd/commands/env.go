@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var envWorkspace bool
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Edit environment variables in sandbox config",
+	Long: `Edit the env section of the global config, or of the current workspace's
+config with --workspace, without hand-editing YAML. If the sandbox for the
+current directory is running, it is re-synced so new sessions see the change.`,
+}
+
+var envSetCmd = &cobra.Command{
+	Use:   "set KEY=VALUE...",
+	Short: "Set environment variables",
+	Long: `Set one or more environment variables. Values use the same syntax as
+config.yaml, so quote $VAR and op:// references to stop your shell expanding
+them.
+
+Examples:
+  sandbox env set NODE_ENV=development
+  sandbox env set --workspace GITHUB_TOKEN='$GITHUB_TOKEN'`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		set := make(map[string]string, len(args))
+		for _, a := range args {
+			k, v, ok := strings.Cut(a, "=")
+			if !ok || k == "" {
+				return fmt.Errorf("invalid assignment %q, want KEY=VALUE", a)
+			}
+			set[k] = v
+		}
+		return editEnv(set, nil)
+	},
+}
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset KEY...",
+	Short: "Remove environment variables",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return editEnv(nil, args)
+	},
+}
+
+// editEnv applies the edit to the selected config file, then re-syncs the
+// current directory's sandbox if it is running.
+func editEnv(set map[string]string, unset []string) error {
+	sandboxRoot, _ := cmd.ResolveWorkspace(cmd.ResolvePath("."))
+
+	path := cmd.WorkspaceConfigPath(sandboxRoot)
+	if !envWorkspace {
+		var err error
+		if path, err = cmd.GlobalConfigPath(); err != nil {
+			return err
+		}
+	}
+	if err := cmd.EditConfigEnv(path, set, unset); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s\n", path)
+
+	name := cmd.SandboxContainer(sandboxRoot)
+	if !cmd.IsRunning(name) {
+		return nil
+	}
+	if err := cmd.SyncContainer(name, sandboxRoot, cmd.SyncOptions{}); err != nil {
+		return err
+	}
+	fmt.Printf("Synced %s. New sessions get the change; in open shells run: source ~/.sandbox-env\n", name)
+	return nil
+}
+
+func init() {
+	envCmd.PersistentFlags().BoolVarP(&envWorkspace, "workspace", "w", false, "edit the workspace config instead of the global one")
+	envCmd.AddCommand(envSetCmd, envUnsetCmd)
+	cmd.RootCmd.AddCommand(envCmd)
+}
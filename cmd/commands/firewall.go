@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var firewallTopUnused bool
+
+var firewallCmd = &cobra.Command{
+	Use:   "firewall",
+	Short: "Inspect a sandbox's firewall",
+}
+
+var firewallTopCmd = &cobra.Command{
+	Use:   "top [path]",
+	Short: "Show connections per firewall.allow entry",
+	Long: `List how many connections a running sandbox has opened to each
+firewall.allow entry since its rules were last applied, busiest first, and
+how many attempts the firewall rejected. Entries with no connections are
+candidates for removal; --unused lists only those.
+
+Counts come from the firewall's rule counters, which are reset whenever a
+sync applies new rules. Domains are matched by the IPs they last resolved
+to, so an address several domains share counts for each.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		wsPath = cmd.ResolvePath(wsPath)
+		sandboxRoot, _ := cmd.ResolveWorkspace(wsPath)
+
+		name := cmd.SandboxContainer(sandboxRoot)
+		if !cmd.IsRunning(name) {
+			return cmd.NotRunningError(sandboxRoot)
+		}
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		report, err := cmd.FirewallTop(name, cfg)
+		if err != nil {
+			return err
+		}
+		if cmd.JSONOutput() {
+			if report.Entries == nil {
+				report.Entries = []cmd.EgressStat{}
+			}
+			return cmd.PrintJSON(report)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "DESTINATION\tCONNECTIONS")
+		for _, e := range report.Entries {
+			if firewallTopUnused && (e.Connections > 0 || !e.Allowed) {
+				continue
+			}
+			dest := e.Dest
+			if !e.Allowed {
+				dest += " (not in firewall.allow)"
+			}
+			fmt.Fprintf(w, "%s\t%d\n", dest, e.Connections)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if !firewallTopUnused {
+			fmt.Printf("\n%d connection attempts blocked\n", report.Blocked)
+		}
+		return nil
+	},
+}
+
+func init() {
+	firewallTopCmd.Flags().BoolVar(&firewallTopUnused, "unused", false, "only show firewall.allow entries with no connections")
+	firewallCmd.AddCommand(firewallTopCmd)
+	cmd.RootCmd.AddCommand(firewallCmd)
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var forwardCmd = &cobra.Command{
+	Use:   "forward [path] <containerPort>[:hostPort]...",
+	Short: "Forward host ports into a running sandbox",
+	Long: `Forward ports on this machine's 127.0.0.1 into a running sandbox until
+interrupted, for servers the agent started on ports that weren't planned for.
+Unlike published ports, forwards need no restart. The host port defaults to
+the container's; use 0 to pick a free one.
+
+The server in the sandbox must listen on its loopback interface (localhost
+or 0.0.0.0).
+
+Examples:
+  sandbox forward 3000
+  sandbox forward ~/proj 5173:15173 8080`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if _, err := cmd.ParsePortForward(args[0]); err != nil {
+			wsPath, args = args[0], args[1:]
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("no ports to forward")
+		}
+		forwards := make([]cmd.PortForward, len(args))
+		for i, a := range args {
+			f, err := cmd.ParsePortForward(a)
+			if err != nil {
+				return err
+			}
+			forwards[i] = f
+		}
+
+		sandboxRoot, _ := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+		name := cmd.SandboxContainer(sandboxRoot)
+		if !cmd.IsRunning(name) {
+			return cmd.NotRunningError(sandboxRoot)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Fprintln(os.Stderr, "Press Ctrl-C to stop forwarding.")
+		return cmd.Forward(ctx, name, forwards, os.Stdout)
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(forwardCmd)
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var geminiCmd = &cobra.Command{
+	Use:   "gemini [path] [-- gemini-args...]",
+	Short: "Open Gemini CLI in the sandbox",
+	Long: `Open an interactive Gemini CLI session with --yolo, so tool calls aren't
+confirmed (the container is the sandbox). Pass extra arguments to Gemini
+after --.
+
+Gemini uses GEMINI_API_KEY when one is stored with "sandbox set-key gemini".
+Its settings and login live in ~/.gemini, which in the container points into
+~/.claude/gemini, so creds_volume persists them along with Claude's; a key
+in ~/.gemini/.env there is also picked up.
+
+Examples:
+  sandbox gemini
+  sandbox gemini ~/proj
+  sandbox gemini . -- -p "fix the tests"`,
+	DisableFlagParsing: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return runAgentSession(c, args, agentCLI{name: "gemini", tool: "gemini", flags: []string{"--yolo"}})
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(geminiCmd)
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var imagePruneDryRun bool
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage sandbox images",
+}
+
+var imagePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove sandbox images nothing uses",
+	Long: `Remove sandbox images that have been superseded: old builds a rebuild left
+untagged, tags from older sandbox releases, and images built for the
+toolchains or image.packages of workspaces no sandbox uses any more.
+
+Images an existing container was created from are kept, as are the images
+for each variant and platform; remove those with docker rmi.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		pruned, err := cmd.PruneImages(imagePruneDryRun)
+		if err != nil {
+			return err
+		}
+		if cmd.JSONOutput() {
+			if pruned == nil {
+				pruned = []cmd.PrunedImage{}
+			}
+			return cmd.PrintJSON(pruned)
+		}
+		if len(pruned) == 0 {
+			fmt.Println("No sandbox images to remove")
+			return nil
+		}
+		verb := "Removed"
+		if imagePruneDryRun {
+			verb = "Would remove"
+		}
+		failed := 0
+		for _, p := range pruned {
+			if p.Error != "" {
+				cmd.Warnf(cmd.WarnContainer, "cannot remove %s: %s", p.Image, p.Error)
+				failed++
+				continue
+			}
+			fmt.Printf("%s %s (%s, %s)\n", verb, p.Image, p.Reason, p.Size)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d images could not be removed", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	imagePruneCmd.Flags().BoolVar(&imagePruneDryRun, "dry-run", false, "list the images that would be removed without removing them")
+	imageCmd.AddCommand(imagePruneCmd)
+	cmd.RootCmd.AddCommand(imageCmd)
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage API keys stored with set-key",
+}
+
+var keysLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List providers with a stored key",
+	Long: `List the providers that have a key in the host keychain, the variable
+each is injected as, and when it was stored. Key values are never shown.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, args []string) error {
+		cfg, err := cmd.LoadGlobalConfig()
+		if err != nil {
+			return err
+		}
+		keys := cmd.ListKeys(cfg)
+		if len(keys) == 0 {
+			fmt.Println("No keys stored. Use `sandbox set-key [provider]` to add one.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "PROVIDER\tENV VAR\tSTORED")
+		for _, k := range keys {
+			stored := "unknown"
+			if !k.Created.IsZero() {
+				stored = k.Created.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", k.Provider, k.EnvVar, stored)
+		}
+		return w.Flush()
+	},
+}
+
+var rmKeyCmd = &cobra.Command{
+	Use:   "rm-key <provider>",
+	Short: "Remove a stored API key",
+	Long: `Delete a provider's key from the host keychain. Sessions started afterwards
+no longer receive it, and its key_file (if configured) is removed from
+running sandboxes. To rotate a key, run set-key again instead; it replaces
+the stored value.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		cfg, err := cmd.LoadGlobalConfig()
+		if err != nil {
+			return err
+		}
+		p, err := cmd.LookupKeyProvider(cfg, args[0])
+		if err != nil {
+			return err
+		}
+		if err := cmd.RemoveKey(p); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s key\n", p.Name)
+		return nil
+	},
+}
+
+func init() {
+	keysCmd.AddCommand(keysLsCmd)
+	cmd.RootCmd.AddCommand(keysCmd)
+	cmd.RootCmd.AddCommand(rmKeyCmd)
+}
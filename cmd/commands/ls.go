@@ -1,8 +1,8 @@
 package commands
 
 import (
-	"os"
-	"os/exec"
+	"fmt"
+	"time"
 
 	cmd "github.com/franklin-ross/sandbox/cmd"
 	"github.com/spf13/cobra"
@@ -12,14 +12,22 @@ var lsCmd = &cobra.Command{
 	Use:     "ls",
 	Aliases: []string{"list"},
 	Short:   "List running sandboxes",
-	Args:    cobra.NoArgs,
+	Long: `List running sandboxes with how long ago each was synced, whether its
+config has changed since the last sync, and whether its image is outdated.`,
+	Args: cobra.NoArgs,
 	RunE: func(_ *cobra.Command, args []string) error {
-		c := exec.Command("docker", "ps",
-			"--filter", "label="+cmd.LabelSel,
-			"--format", `table {{.Names}}\t{{.Status}}\t{{.Label "`+cmd.LabelWs+`"}}`)
-		c.Stdout = os.Stdout
-		c.Stderr = os.Stderr
-		return c.Run()
+		list, err := cmd.ListSandboxes()
+		if err != nil {
+			return err
+		}
+		if cmd.JSONOutput() {
+			if list == nil {
+				list = []cmd.SandboxStatus{}
+			}
+			return cmd.PrintJSON(list)
+		}
+		fmt.Print(cmd.FormatSandboxTable(list, time.Now()))
+		return nil
 	},
 }
 
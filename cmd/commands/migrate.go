@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [path]",
+	Short: "Replace a legacy ao-sandbox container with a current one",
+	Long: `Replace the container an older ao-sandbox release created for the workspace
+(named ao-sandbox-<dir>) with a current sandbox. The old container is removed
+and a new one started and synced; a credentials volume from the old release
+is copied into the new one. Packages installed by hand in the old container
+are lost, so consider running 'sandbox config capture' first.
+
+Until migrated, legacy containers keep working: commands use them when the
+workspace has no current sandbox, and 'sandbox ls' marks them.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		sandboxRoot, _ := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+		if !cmd.AssumeYes() && !confirm(fmt.Sprintf("Remove the legacy sandbox for %s and start a new one?", sandboxRoot)) {
+			return fmt.Errorf("not migrated; pass --yes to skip this question")
+		}
+		return cmd.MigrateLegacySandbox(sandboxRoot, os.Stdout)
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(migrateCmd)
+}
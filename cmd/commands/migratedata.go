@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	migrateDataFrom   string
+	migrateDataDryRun bool
+)
+
+var migrateDataCmd = &cobra.Command{
+	Use:   "migrate-data [layout]",
+	Short: "Move config and home files to another directory layout",
+	Long: `Move sandbox's per-user files to another layout:
+
+  sandbox  ~/.sandbox for everything
+  xdg      config in $XDG_CONFIG_HOME/sandbox, home files in
+           $XDG_DATA_HOME/sandbox/home, daemon files in $XDG_CACHE_HOME/sandbox
+
+The config, key metadata, locales, home files and daemon directory are moved,
+and references to their old paths in the config are rewritten. Nothing is
+moved if any destination already exists. Running sandboxes pick up the new
+home files at their next sync.
+
+Without a layout, list the layouts and which one is in use. If that isn't
+the build's default and the default has no files yet, offer to move or
+symlink the files there.`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{cmd.LayoutSandbox, cmd.LayoutXDG},
+	RunE: func(_ *cobra.Command, args []string) error {
+		if len(args) == 0 && migrateDataFrom == "" {
+			cmd.CheckLegacyData()
+		}
+		from, err := cmd.ActiveLayout()
+		if err != nil {
+			return err
+		}
+		if migrateDataFrom != "" {
+			if from, err = cmd.LookupLayout(migrateDataFrom); err != nil {
+				return err
+			}
+		}
+		if len(args) == 0 {
+			return listLayouts(from)
+		}
+		to, err := cmd.LookupLayout(args[0])
+		if err != nil {
+			return err
+		}
+		return cmd.MigrateData(from, to, migrateDataDryRun, os.Stdout)
+	},
+}
+
+// listLayouts prints each layout's directories, marking active.
+func listLayouts(active cmd.DataLayout) error {
+	layouts, err := cmd.DataLayouts()
+	if err != nil {
+		return err
+	}
+	for _, l := range layouts {
+		mark := " "
+		if l.Name == active.Name {
+			mark = "*"
+		}
+		fmt.Printf("%s %s\n", mark, l.Name)
+		fmt.Printf("    config: %s\n", l.Config)
+		fmt.Printf("    home:   %s\n", l.Home)
+		fmt.Printf("    cache:  %s\n", l.Cache)
+	}
+	return nil
+}
+
+// promptLegacyData asks on the terminal what to do with files found only in
+// a layout other than the build's default. See cmd.LegacyDataPrompt.
+func promptLegacyData(legacy, target cmd.DataLayout) string {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return ""
+	}
+	fmt.Fprintf(os.Stderr, "sandbox's files are in %s, but this build keeps them in %s.\n", legacy.Config, target.Config)
+	fmt.Fprintf(os.Stderr, "  m  move them to %s\n", target.Config)
+	fmt.Fprintf(os.Stderr, "  s  leave them there and symlink %s to them\n", target.Config)
+	fmt.Fprintf(os.Stderr, "  k  keep using %s and don't ask again\n", legacy.Config)
+	fmt.Fprintf(os.Stderr, "Choice [m/s/k, Enter to decide later]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "m":
+		return cmd.LegacyMove
+	case "s":
+		return cmd.LegacyLink
+	case "k":
+		return cmd.LegacyKeep
+	}
+	return ""
+}
+
+func init() {
+	cmd.LegacyDataPrompt = promptLegacyData
+	migrateDataCmd.Flags().StringVar(&migrateDataFrom, "from", "", "layout to move files from (default: the one in use)")
+	migrateDataCmd.Flags().BoolVarP(&migrateDataDryRun, "dry-run", "n", false, "show what would be moved without moving it")
+	cmd.RootCmd.AddCommand(migrateDataCmd)
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"fmt"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+)
+
+// sandboxResult is the --json result of start, sync, stop, rm and upgrade.
+type sandboxResult struct {
+	Sandbox   string `json:"sandbox"`
+	Workspace string `json:"workspace,omitempty"`
+	// Status is what became of the sandbox: "started", "running" (it already
+	// was), "synced", "stopped", "not-running", "removed", "not-found",
+	// "upgraded" or "current" (its image was up to date).
+	Status string `json:"status"`
+}
+
+// printResult prints r with --json, or else the text format and args make.
+func printResult(r sandboxResult, format string, args ...any) error {
+	if cmd.JSONOutput() {
+		return cmd.PrintJSON(r)
+	}
+	fmt.Printf(format+"\n", args...)
+	return nil
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"strings"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var npmCmd = &cobra.Command{
+	Use:   "npm [npm-args...]",
+	Short: "Run npm in the sandbox",
+	Long: `Run npm inside the sandbox for the current directory, starting and syncing
+it first if needed. All arguments are passed to npm unchanged, and script
+names after "run" are completed from package.json.
+
+Examples:
+  sandbox npm install
+  sandbox npm run test -- --watch`,
+	DisableFlagParsing: true,
+	ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 1 || (args[0] != "run" && args[0] != "run-script") {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		scripts, _ := cmd.PackageScripts(cmd.ResolvePath("."))
+		return completions(scripts, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(c *cobra.Command, args []string) error {
+		if isHelp(args) {
+			return c.Help()
+		}
+		return runInSandbox("npm", cmd.ResolvePath("."), args...)
+	},
+}
+
+var makeCmd = &cobra.Command{
+	Use:   "make [make-args...]",
+	Short: "Run make in the sandbox",
+	Long: `Run make inside the sandbox for the current directory, starting and syncing
+it first if needed. All arguments are passed to make unchanged, and target
+names are completed from the Makefile.
+
+Examples:
+  sandbox make
+  sandbox make test VERBOSE=1`,
+	DisableFlagParsing: true,
+	ValidArgsFunction: func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		targets, _ := cmd.MakeTargets(cmd.ResolvePath("."))
+		return completions(targets, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(c *cobra.Command, args []string) error {
+		if isHelp(args) {
+			return c.Help()
+		}
+		return runInSandbox("make", cmd.ResolvePath("."), args...)
+	},
+}
+
+// runInSandbox execs tool with args in the sandbox for dir with the usual
+// session env and the tool's commands overrides, starting and syncing the
+// sandbox first if needed.
+func runInSandbox(tool, dir string, args ...string) error {
+	sandboxRoot, workDir := cmd.ResolveWorkspace(dir)
+	name, err := cmd.EnsureRunning(sandboxRoot)
+	if err != nil {
+		return err
+	}
+	cfg, err := cmd.LoadConfig(sandboxRoot)
+	if err != nil {
+		return err
+	}
+	extraEnv, endSession, err := startHostToolSession(cfg, sandboxRoot)
+	if err != nil {
+		return err
+	}
+	defer endSession()
+	cc := cfg.Command(tool)
+	execArgs := append([]string{tool}, cc.Args...)
+	execArgs = append(execArgs, args...)
+	return cmd.DockerExec(name, cc.Dir(sandboxRoot, workDir), cfg.ForCommand(tool), extraEnv, execArgs...)
+}
+
+// isHelp reports whether args ask for this command's own help rather than
+// the wrapped tool's: only a lone -h or --help does.
+func isHelp(args []string) bool {
+	return len(args) == 1 && (args[0] == "-h" || args[0] == "--help")
+}
+
+// completions filters names to those starting with prefix.
+func completions(names []string, prefix string) []string {
+	var out []string
+	for _, n := range names {
+		if strings.HasPrefix(n, prefix) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(npmCmd)
+	cmd.RootCmd.AddCommand(makeCmd)
+}
@@ -2,12 +2,16 @@ package commands
 
 import (
 	"fmt"
+	"strings"
 
 	cmd "github.com/franklin-ross/sandbox/cmd"
 	"github.com/spf13/cobra"
 )
 
-var rmName string
+var (
+	rmName   string
+	rmCaches bool
+)
 
 var rmCmd = &cobra.Command{
 	Use:   "rm [path]",
@@ -29,40 +33,50 @@ var rmCmd = &cobra.Command{
 			return fmt.Errorf("this directory uses a parent sandbox at %s\nRun 'sandbox rm' from %s instead", sandboxRoot, sandboxRoot)
 		}
 
-		name := cmd.ContainerName(sandboxRoot)
-		if cmd.ContainerExists(name) {
+		name := cmd.SandboxContainer(sandboxRoot)
+		if cmd.ContainerExists(name) || rmCaches {
 			return removeSandbox(name)
 		}
 
+		result := sandboxResult{Sandbox: name, Workspace: sandboxRoot, Status: "not-found"}
 		if len(args) > 0 && cmd.ContainerExists(args[0]) {
-			fmt.Printf("No sandbox found for path %s\n", wsPath)
-			fmt.Printf("Did you mean: sandbox rm --name %s\n", args[0])
-			return nil
+			return printResult(result, "No sandbox found for path %s\nDid you mean: sandbox rm --name %s", wsPath, args[0])
 		}
-
-		fmt.Printf("No sandbox found for %s\n", wsPath)
-		return nil
+		return printResult(result, "No sandbox found for %s", wsPath)
 	},
 }
 
+// removeSandbox removes the named container and, with --caches, its cache
+// volumes, which are removed even when the container already is.
 func removeSandbox(name string) error {
-	if !cmd.ContainerExists(name) {
-		fmt.Printf("No sandbox named %s found\n", name)
-		return nil
+	exists := cmd.ContainerExists(name)
+	if exists {
+		if err := cmd.RemoveContainer(name); err != nil {
+			return err
+		}
 	}
-	if cmd.IsRunning(name) {
-		if err := cmd.DockerRun("stop", name); err != nil {
-			return fmt.Errorf("stop container: %w", err)
+	var caches []string
+	if rmCaches {
+		var err error
+		if caches, err = cmd.RemoveCacheVolumes(name); err != nil {
+			return err
 		}
 	}
-	if err := cmd.DockerRun("rm", name); err != nil {
-		return fmt.Errorf("remove container: %w", err)
+	result := sandboxResult{Sandbox: name, Status: "removed"}
+	switch {
+	case !exists && len(caches) == 0:
+		result.Status = "not-found"
+		return printResult(result, "No sandbox named %s found", name)
+	case !exists:
+		return printResult(result, "Cache volumes of %s removed: %s", name, strings.Join(caches, ", "))
+	case len(caches) > 0:
+		return printResult(result, "Sandbox %s removed, with its cache volumes: %s", name, strings.Join(caches, ", "))
 	}
-	fmt.Printf("Sandbox %s removed\n", name)
-	return nil
+	return printResult(result, "Sandbox %s removed", name)
 }
 
 func init() {
 	rmCmd.Flags().StringVarP(&rmName, "name", "n", "", "remove sandbox by container name instead of path")
+	rmCmd.Flags().BoolVar(&rmCaches, "caches", false, "also remove the sandbox's cache_volumes")
 	cmd.RootCmd.AddCommand(rmCmd)
 }
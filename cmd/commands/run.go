@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run [<agent>] [path] [--timeout <duration>] [-- args...]",
+	Short: "Run a command or a configured agent in the sandbox",
+	Long: `With an agent, open an interactive session of an agent CLI defined in the
+config's agents: section, the same way sandbox claude opens Claude: its args
+come first, then any arguments after --. The session gets the top-level env
+with the agent's env merged over it, and keys stored with "sandbox set-key".
+The agent's allow entries are added to the firewall at sync.
+
+Otherwise run the command after -- in the sandbox, for scripts and CI: no
+TTY is allocated, the command's stdout and stderr pass through unmodified
+(sandbox's own messages go to stderr), and sandbox exits with the command's
+exit status. --timeout stops the command after a duration such as 10m, with
+exit status 124. A path that has the same name as an agent needs a ./ prefix.
+
+Without arguments, list the configured agents.
+
+Examples:
+  sandbox run opencode
+  sandbox run opencode ~/proj -- --model gpt-5
+  sandbox run -- go test ./...
+  sandbox run ~/proj --timeout 20m -- make ci`,
+	DisableFlagParsing: true,
+	ValidArgsFunction:  completeAgents,
+	RunE: func(c *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return listAgents()
+		}
+		if args[0] == "-h" || args[0] == "--help" {
+			return c.Help()
+		}
+		r, err := parseRunArgs(args)
+		if err != nil {
+			return err
+		}
+		if !r.isCommand(isConfiguredAgent) {
+			if r.timeout > 0 {
+				return fmt.Errorf("--timeout only applies to commands run with sandbox run -- <cmd>")
+			}
+			return runAgentSession(c, args[1:], agentCLI{name: args[0], configured: true})
+		}
+		if len(r.command) == 0 {
+			return fmt.Errorf("nothing to run: give the command after --")
+		}
+		wsPath := "."
+		if len(r.positional) > 0 {
+			wsPath = r.positional[0]
+		}
+		return runCommand(wsPath, r.timeout, r.command)
+	},
+}
+
+// runArgs are the arguments to sandbox run, split at "--".
+type runArgs struct {
+	positional []string // before "--", without --timeout
+	timeout    time.Duration
+	sep        bool     // whether there was a "--"
+	command    []string // after "--"
+}
+
+func parseRunArgs(args []string) (runArgs, error) {
+	var r runArgs
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		value := ""
+		switch {
+		case a == "--":
+			r.sep = true
+			r.command = args[i+1:]
+			return r, nil
+		case a == "--timeout":
+			if i+1 == len(args) {
+				return r, fmt.Errorf("--timeout needs a duration, such as 10m")
+			}
+			i++
+			value = args[i]
+		case strings.HasPrefix(a, "--timeout="):
+			value = strings.TrimPrefix(a, "--timeout=")
+		default:
+			r.positional = append(r.positional, a)
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return r, fmt.Errorf("invalid --timeout %q: want a positive duration such as 10m", value)
+		}
+		r.timeout = d
+	}
+	return r, nil
+}
+
+// isCommand reports whether the args run a command rather than an agent:
+// there is a "--", and before it at most a path, which isn't an agent's name.
+func (r runArgs) isCommand(isAgent func(string) bool) bool {
+	if !r.sep || len(r.positional) > 1 {
+		return false
+	}
+	return len(r.positional) == 0 || !isAgent(r.positional[0])
+}
+
+// isConfiguredAgent reports whether name is an agent in the config for the
+// current directory.
+func isConfiguredAgent(name string) bool {
+	cfg, err := cmd.LoadConfig(cmd.SandboxRootFor(cmd.ResolvePath(".")))
+	if err != nil {
+		return false
+	}
+	_, ok := cfg.Agent(name)
+	return ok
+}
+
+// runCommand runs command in the sandbox for wsPath without a TTY. The
+// command's exit status comes back as a *cmd.ExitError.
+func runCommand(wsPath string, timeout time.Duration, command []string) error {
+	// Keep stdout for the command's output.
+	cmd.Frontend = stderrInfoUI{cmd.Frontend}
+
+	sandboxRoot, workDir := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+	name, err := cmd.EnsureRunning(sandboxRoot)
+	if err != nil {
+		return err
+	}
+	cfg, err := cmd.LoadConfig(sandboxRoot)
+	if err != nil {
+		return err
+	}
+	extraEnv, endSession, err := startHostToolSession(cfg, sandboxRoot)
+	if err != nil {
+		return err
+	}
+	defer endSession()
+	return cmd.DockerExecBatch(name, workDir, cfg, extraEnv, timeout, command...)
+}
+
+// stderrInfoUI reports progress on stderr instead of stdout.
+type stderrInfoUI struct {
+	cmd.UI
+}
+
+func (u stderrInfoUI) Info(msg string) { u.Note(msg) }
+
+// configuredAgent fills in agent from the agents: entry of the same name,
+// failing if there is none or a required variable isn't set.
+func configuredAgent(cfg *cmd.SandboxConfig, agent agentCLI) (agentCLI, error) {
+	a, ok := cfg.Agent(agent.name)
+	if !ok {
+		if len(cfg.Agents) == 0 {
+			return agent, fmt.Errorf("no agent %q: there are no agents in the config", agent.name)
+		}
+		return agent, fmt.Errorf("no agent %q in the config (have %s)", agent.name, strings.Join(cfg.AgentNames(), ", "))
+	}
+	missing, err := cmd.MissingAgentEnv(cfg, a)
+	if err != nil {
+		return agent, err
+	}
+	if len(missing) > 0 {
+		return agent, fmt.Errorf("agent %q needs %s; set it in env or store a key with sandbox set-key", a.Name, strings.Join(missing, ", "))
+	}
+	agent.tool = a.Command
+	return agent, nil
+}
+
+// listAgents prints the agents configured for the current directory.
+func listAgents() error {
+	cfg, err := cmd.LoadConfig(cmd.SandboxRootFor(cmd.ResolvePath(".")))
+	if err != nil {
+		return err
+	}
+	if len(cfg.Agents) == 0 {
+		fmt.Println("No agents configured. Add them under agents: in the config.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "AGENT\tCOMMAND")
+	for _, name := range cfg.AgentNames() {
+		a, _ := cfg.Agent(name)
+		fmt.Fprintf(w, "%s\t%s\n", name, strings.Join(append([]string{a.Command}, a.Args...), " "))
+	}
+	return w.Flush()
+}
+
+// completeAgents offers the configured agents' names as the first argument.
+func completeAgents(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	cfg, err := cmd.LoadConfig(cmd.SandboxRootFor(cmd.ResolvePath(".")))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var out []string
+	for _, name := range cfg.AgentNames() {
+		if strings.HasPrefix(name, toComplete) {
+			out = append(out, name)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(runCmd)
+}
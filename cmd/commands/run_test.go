@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRunArgs(t *testing.T) {
+	isAgent := func(name string) bool { return name == "opencode" }
+	tests := []struct {
+		args        []string
+		wantCommand bool
+		wantPath    string
+		wantTimeout time.Duration
+		wantRun     []string
+	}{
+		{args: []string{"opencode"}},
+		{args: []string{"opencode", "--", "--model", "x"}},
+		{args: []string{"opencode", "~/proj", "--", "--model", "x"}},
+		{args: []string{"--", "go", "test"}, wantCommand: true, wantRun: []string{"go", "test"}},
+		{args: []string{"~/proj", "--", "make"}, wantCommand: true, wantPath: "~/proj", wantRun: []string{"make"}},
+		{args: []string{"--timeout", "10m", "./opencode", "--", "ls"}, wantCommand: true, wantPath: "./opencode", wantTimeout: 10 * time.Minute, wantRun: []string{"ls"}},
+		{args: []string{"--timeout=90s", "--", "sleep", "--timeout", "5"}, wantCommand: true, wantTimeout: 90 * time.Second, wantRun: []string{"sleep", "--timeout", "5"}},
+	}
+	for _, tt := range tests {
+		r, err := parseRunArgs(tt.args)
+		if err != nil {
+			t.Errorf("parseRunArgs(%q): %v", tt.args, err)
+			continue
+		}
+		if got := r.isCommand(isAgent); got != tt.wantCommand {
+			t.Errorf("parseRunArgs(%q).isCommand = %v, want %v", tt.args, got, tt.wantCommand)
+			continue
+		}
+		if !tt.wantCommand {
+			continue
+		}
+		path := ""
+		if len(r.positional) > 0 {
+			path = r.positional[0]
+		}
+		if path != tt.wantPath || r.timeout != tt.wantTimeout || strings.Join(r.command, " ") != strings.Join(tt.wantRun, " ") {
+			t.Errorf("parseRunArgs(%q) = path %q, timeout %v, command %q; want %q, %v, %q",
+				tt.args, path, r.timeout, r.command, tt.wantPath, tt.wantTimeout, tt.wantRun)
+		}
+	}
+
+	for _, args := range [][]string{{"--timeout"}, {"--timeout", "soon", "--", "ls"}, {"--timeout=-1m", "--", "ls"}} {
+		if _, err := parseRunArgs(args); err == nil {
+			t.Errorf("parseRunArgs(%q) succeeded, want error", args)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"os"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check sandboxes work end to end on this machine",
+	Long: `Build a small test image, start a disposable sandbox from it, and check
+that commands run inside it, that a sync delivers files and env, and that
+the firewall lets an allowlisted host (github.com) through while blocking
+another (example.com). The sandbox is removed afterwards, whatever the
+outcome. Exits non-zero if any step failed.
+
+This needs network access. Your config isn't used, but the files in
+~/.sandbox/home are synced as they would be into a real sandbox.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return cmd.Selftest(os.Stdout)
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(selftestCmd)
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage agent sessions stored in sandboxes",
+}
+
+var sessionsLsCmd = &cobra.Command{
+	Use:     "ls [path]",
+	Aliases: []string{"list"},
+	Short:   "List Claude sessions for a workspace",
+	Long: `List the Claude Code sessions started in a workspace's sandbox, most
+recently used first, with Claude's summary of each or its first prompt.
+Sessions are kept in the sandbox's ~/.claude, so they survive ` + "`sandbox rm`" + `
+when creds_volume is set.
+
+Resume one with ` + "`sandbox claude --resume <id>`" + `; any unique prefix of the ID
+will do.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		sandboxRoot, workDir := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+
+		name := cmd.SandboxContainer(sandboxRoot)
+		if !cmd.IsRunning(name) {
+			return cmd.NotRunningError(sandboxRoot)
+		}
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		// Claude files sessions under the directory it runs in.
+		workDir = cfg.Command("claude").Dir(sandboxRoot, workDir)
+		sessions, err := cmd.ClaudeSessions(name, workDir)
+		if err != nil {
+			return err
+		}
+		if cmd.JSONOutput() {
+			if sessions == nil {
+				sessions = []cmd.ClaudeSession{}
+			}
+			return cmd.PrintJSON(sessions)
+		}
+		if len(sessions) == 0 {
+			fmt.Printf("No Claude sessions in %s\n", workDir)
+			return nil
+		}
+		fmt.Print(cmd.FormatSessionTable(sessions, time.Now()))
+		return nil
+	},
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsLsCmd)
+	cmd.RootCmd.AddCommand(sessionsCmd)
+}
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	setKeyNoValidate bool
+	setKeyFromEnv    string
+	setKeyFromFile   string
+)
+
+var setKeyCmd = &cobra.Command{
+	Use:   "set-key [provider]",
+	Short: "Store an API key in the host keychain",
+	Long: `Store an API key in the macOS Keychain or the Linux Secret Service
+(via secret-tool). Stored keys are read at exec time and injected into
+sandbox sessions as environment variables; they are never written to disk
+in plaintext. The provider defaults to "anthropic".
+
+Built-in providers are anthropic, openai, gemini, openrouter and github;
+more can be added under key_providers in the global config. Keys are
+checked against the provider's API before being stored unless
+--no-validate is given.
+
+Without a terminal the key is read from stdin, so it can be piped in by
+scripts; --from-env and --from-file read it from an environment variable
+or file instead.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		name := "anthropic"
+		if len(args) > 0 {
+			name = args[0]
+		}
+		cfg, err := cmd.LoadGlobalConfig()
+		if err != nil {
+			return err
+		}
+		p, err := cmd.LookupKeyProvider(cfg, name)
+		if err != nil {
+			return err
+		}
+
+		key, err := keyInput(p)
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			return fmt.Errorf("no key entered")
+		}
+		if !setKeyNoValidate {
+			if err := cmd.ValidateKey(p, key); err != nil {
+				return err
+			}
+		}
+		if err := cmd.SetKey(p, key); err != nil {
+			return fmt.Errorf("store key: %w", err)
+		}
+		fmt.Printf("Stored %s key; sessions will receive it as %s\n", p.Name, p.EnvVar)
+		return nil
+	},
+}
+
+// keyInput reads the key from --from-env, --from-file, piped stdin, or an
+// interactive prompt, in that order of precedence.
+func keyInput(p cmd.KeyProvider) (string, error) {
+	switch {
+	case setKeyFromEnv != "" && setKeyFromFile != "":
+		return "", fmt.Errorf("--from-env and --from-file are mutually exclusive")
+	case setKeyFromEnv != "":
+		v, ok := os.LookupEnv(setKeyFromEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", setKeyFromEnv)
+		}
+		return strings.TrimSpace(v), nil
+	case setKeyFromFile != "":
+		return readKeyFrom(setKeyFromFile)
+	case !term.IsTerminal(int(os.Stdin.Fd())):
+		return readKeyStream(os.Stdin)
+	default:
+		return readKey(fmt.Sprintf("Enter %s API key: ", p.Name))
+	}
+}
+
+// readKeyFrom reads a key from a file, or from stdin when path is "-".
+func readKeyFrom(path string) (string, error) {
+	if path == "-" {
+		return readKeyStream(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("read key: %w", err)
+	}
+	defer f.Close()
+	return readKeyStream(f)
+}
+
+// readKeyStream reads a key from r, ignoring surrounding whitespace.
+func readKeyStream(r io.Reader) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("read key: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// readKey prompts for a key without echoing it.
+func readKey(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read key: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func init() {
+	setKeyCmd.Flags().StringVar(&setKeyFromEnv, "from-env", "", "read the key from this environment variable")
+	setKeyCmd.Flags().StringVar(&setKeyFromFile, "from-file", "", "read the key from this file (\"-\" for stdin)")
+	setKeyCmd.Flags().BoolVar(&setKeyNoValidate, "no-validate", false, "store the key without checking it against the provider")
+	cmd.RootCmd.AddCommand(setKeyCmd)
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+)
+
+func TestKeyInput(t *testing.T) {
+	p := cmd.KeyProvider{Name: "test", EnvVar: "TEST_KEY"}
+	reset := func() { setKeyFromEnv, setKeyFromFile = "", "" }
+	t.Cleanup(reset)
+
+	t.Run("from env", func(t *testing.T) {
+		reset()
+		t.Setenv("SANDBOX_TEST_KEY_SRC", "  sk-env\n")
+		setKeyFromEnv = "SANDBOX_TEST_KEY_SRC"
+		if got, err := keyInput(p); err != nil || got != "sk-env" {
+			t.Errorf("got (%q, %v), want sk-env", got, err)
+		}
+	})
+
+	t.Run("unset env errors", func(t *testing.T) {
+		reset()
+		setKeyFromEnv = "SANDBOX_TEST_KEY_UNSET"
+		if _, err := keyInput(p); err == nil {
+			t.Error("unset variable should error")
+		}
+	})
+
+	t.Run("from file", func(t *testing.T) {
+		reset()
+		path := filepath.Join(t.TempDir(), "key")
+		os.WriteFile(path, []byte("sk-file\n"), 0600)
+		setKeyFromFile = path
+		if got, err := keyInput(p); err != nil || got != "sk-file" {
+			t.Errorf("got (%q, %v), want sk-file", got, err)
+		}
+	})
+
+	t.Run("both flags", func(t *testing.T) {
+		reset()
+		setKeyFromEnv, setKeyFromFile = "A", "b"
+		if _, err := keyInput(p); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+			t.Errorf("err = %v, want mutually exclusive", err)
+		}
+	})
+}
+
+func TestReadKeyStream(t *testing.T) {
+	got, err := readKeyStream(strings.NewReader("sk-piped\n"))
+	if err != nil || got != "sk-piped" {
+		t.Errorf("got (%q, %v), want sk-piped", got, err)
+	}
+}
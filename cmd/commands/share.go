@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share [path]",
+	Short: "Expose a sandbox's ports to teammates over Tailscale or a tunnel",
+	Long: `Expose the container ports listed in share.ports so a teammate on another
+machine can pair on a running session. Sharing is off unless the config sets
+share.via:
+
+  tailscale  listen on this machine's tailnet address and relay connections
+             into the sandbox; needs the tailscale CLI on the host
+  tunnel     open SSH reverse forwards from the sandbox to share.tunnel.host,
+             which the firewall then allows; the sandbox needs an SSH key
+             the tunnel host accepts, e.g. synced to ~/.ssh
+
+Starts and syncs the sandbox if needed, then shares until interrupted.
+To pair in a terminal, teammates can SSH to this machine (for example with
+Tailscale SSH) and attach to the same tmux session through 'sandbox shell'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		sandboxRoot, _ := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		if cfg.Share.Via == "" {
+			return fmt.Errorf("sharing is off for %s; set share.via and share.ports in the sandbox config", sandboxRoot)
+		}
+		name, err := cmd.EnsureRunning(sandboxRoot)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Fprintln(os.Stderr, "Press Ctrl-C to stop sharing.")
+		return cmd.Share(ctx, name, cfg.Share, os.Stdout)
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(shareCmd)
+}
@@ -31,25 +31,53 @@ func runShell(wsPath string) error {
 		return err
 	}
 
-	var extraEnv map[string]string
-	if len(cfg.HostTools) > 0 {
-		port := cfg.EffectiveHostToolPort()
-		if err := cmd.EnsureHostToolDaemon(port); err != nil {
-			return fmt.Errorf("host tool daemon: %w", err)
-		}
-		sessionID := cmd.GenerateSessionID()
-		if err := cmd.RegisterHostToolSession(port, sessionID, cfg.HostTools, sandboxRoot); err != nil {
-			return fmt.Errorf("register host tool session: %w", err)
-		}
-		defer cmd.UnregisterHostToolSession(port, sessionID)
+	extraEnv, endSession, err := startHostToolSession(cfg, sandboxRoot)
+	if err != nil {
+		return err
+	}
+	defer endSession()
+
+	cc := cfg.Command("shell")
+	workDir = cc.Dir(sandboxRoot, workDir)
+	cmd.PrintBanner(name, sandboxRoot, workDir, cfg)
+	return cmd.DockerExec(name, workDir, cfg.ForCommand("shell"), extraEnv, append([]string{"/bin/zsh"}, cc.Args...)...)
+}
 
-		extraEnv = map[string]string{
-			"SANDBOX_SESSION":       sessionID,
-			"SANDBOX_HOSTTOOL_PORT": fmt.Sprintf("%d", port),
+// startHostToolSession registers a host tool session for the workspace when
+// cfg defines host tools or forwards gpg-agent, returning the env vars that
+// point the sandbox at it and a func that ends the session.
+func startHostToolSession(cfg *cmd.SandboxConfig, sandboxRoot string) (map[string]string, func(), error) {
+	if !cfg.UsesHostToolDaemon() {
+		return nil, func() {}, nil
+	}
+	port := cfg.EffectiveHostToolPort()
+	if err := cmd.EnsureHostToolDaemon(port); err != nil {
+		return nil, nil, fmt.Errorf("host tool daemon: %w", err)
+	}
+	sessionID := cmd.GenerateSessionID()
+	if err := cmd.RegisterHostToolSession(port, sessionID, cfg.HostTools, sandboxRoot); err != nil {
+		return nil, nil, fmt.Errorf("register host tool session: %w", err)
+	}
+	if cfg.GPG.Forward {
+		if err := forwardGPGAgent(port, sessionID, sandboxRoot); err != nil {
+			cmd.Warnf(cmd.WarnContainer, "gpg-agent forwarding: %v", err)
 		}
 	}
+	env := map[string]string{
+		"SANDBOX_SESSION":       sessionID,
+		"SANDBOX_HOSTTOOL_PORT": fmt.Sprintf("%d", port),
+	}
+	return env, func() { cmd.UnregisterHostToolSession(port, sessionID) }, nil
+}
 
-	return cmd.DockerExec(name, workDir, cfg, extraEnv, "/bin/zsh")
+// forwardGPGAgent starts the sandbox's gpg-agent relay and lets it reach
+// the host's gpg-agent for the session.
+func forwardGPGAgent(port int, sessionID, sandboxRoot string) error {
+	token, err := cmd.StartGPGRelay(cmd.SandboxContainer(sandboxRoot), port)
+	if err != nil {
+		return err
+	}
+	return cmd.AllowGPGAgent(port, sessionID, token)
 }
 
 func init() {
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var sshConfig bool
+
+var sshCmd = &cobra.Command{
+	Use:   "ssh [path] [-- command...]",
+	Short: "Log in to the sandbox over SSH",
+	Long: `Log in to the sandbox over SSH as the agent user, in the current directory,
+or run a command there. Needs ssh.enabled in the sandbox config; the server
+is published on the host's loopback interface only, and accepts a key that
+sandbox creates and syncs in.
+
+--config prints a Host entry for ~/.ssh/config instead, so editors and tools
+that work over SSH can connect to the sandbox by its container name.`,
+	Args: func(c *cobra.Command, args []string) error {
+		if n := c.ArgsLenAtDash(); n > 1 || (n < 0 && len(args) > 1) {
+			return fmt.Errorf("accepts at most one path before --")
+		}
+		return nil
+	},
+	RunE: func(c *cobra.Command, args []string) error {
+		wsPath := "."
+		var command []string
+		if n := c.ArgsLenAtDash(); n >= 0 {
+			command = args[n:]
+			args = args[:n]
+		}
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		sandboxRoot, workDir := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+
+		cfg, err := cmd.LoadConfig(sandboxRoot)
+		if err != nil && !errors.Is(err, cmd.ErrNoConfig) {
+			return err
+		}
+		if cfg == nil || !cfg.SSH.Enabled {
+			return fmt.Errorf("ssh is off; set ssh.enabled: true in the sandbox config")
+		}
+		name, err := cmd.EnsureRunning(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		addr, err := cmd.SSHAddr(name)
+		if err != nil {
+			return err
+		}
+		key, err := cmd.SSHKeyPath()
+		if err != nil {
+			return err
+		}
+		if sshConfig {
+			fmt.Print(cmd.FormatSSHConfig(name, addr, key))
+			return nil
+		}
+
+		sshArgs := cmd.SSHArgs(addr, key, workDir, command)
+		ssh := exec.Command(sshArgs[0], sshArgs[1:]...)
+		ssh.Stdin = os.Stdin
+		ssh.Stdout = os.Stdout
+		ssh.Stderr = os.Stderr
+		if err := ssh.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return &cmd.ExitError{Code: exitErr.ExitCode()}
+			}
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	sshCmd.Flags().BoolVar(&sshConfig, "config", false, "print a Host entry for ~/.ssh/config instead of connecting")
+	cmd.RootCmd.AddCommand(sshCmd)
+}
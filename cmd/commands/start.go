@@ -17,17 +17,15 @@ var startCmd = &cobra.Command{
 	RunE: func(_ *cobra.Command, args []string) error {
 		if startName != "" {
 			if cmd.IsRunning(startName) {
-				fmt.Printf("Sandbox %s already running\n", startName)
-				return nil
+				return printResult(sandboxResult{Sandbox: startName, Status: "running"}, "Sandbox %s already running", startName)
 			}
 			if !cmd.ContainerExists(startName) {
-				return fmt.Errorf("no sandbox named %s found", startName)
+				return &cmd.Error{Kind: cmd.KindNoContainer, Err: fmt.Errorf("no sandbox named %s found", startName)}
 			}
 			if err := cmd.DockerRun("start", startName); err != nil {
 				return fmt.Errorf("start container: %w", err)
 			}
-			fmt.Printf("Sandbox %s started\n", startName)
-			return nil
+			return printResult(sandboxResult{Sandbox: startName, Status: "started"}, "Sandbox %s started", startName)
 		}
 
 		wsPath := "."
@@ -37,12 +35,15 @@ var startCmd = &cobra.Command{
 		wsPath = cmd.ResolvePath(wsPath)
 		sandboxRoot, _ := cmd.ResolveWorkspace(wsPath)
 
+		status := "started"
+		if cmd.IsRunning(cmd.SandboxContainer(sandboxRoot)) {
+			status = "running"
+		}
 		name, err := cmd.EnsureRunning(sandboxRoot)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Sandbox %s running for %s\n", name, sandboxRoot)
-		return nil
+		return printResult(sandboxResult{Sandbox: name, Workspace: sandboxRoot, Status: status}, "Sandbox %s running for %s", name, sandboxRoot)
 	},
 }
 
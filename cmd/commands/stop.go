@@ -16,14 +16,12 @@ var stopCmd = &cobra.Command{
 	RunE: func(_ *cobra.Command, args []string) error {
 		if stopName != "" {
 			if !cmd.IsRunning(stopName) {
-				fmt.Printf("No sandbox named %s running\n", stopName)
-				return nil
+				return printResult(sandboxResult{Sandbox: stopName, Status: "not-running"}, "No sandbox named %s running", stopName)
 			}
-			if err := cmd.DockerRun("stop", stopName); err != nil {
-				return fmt.Errorf("stop container: %w", err)
+			if err := cmd.StopContainer(stopName); err != nil {
+				return err
 			}
-			fmt.Printf("Sandbox %s stopped\n", stopName)
-			return nil
+			return printResult(sandboxResult{Sandbox: stopName, Status: "stopped"}, "Sandbox %s stopped", stopName)
 		}
 
 		wsPath := "."
@@ -37,16 +35,14 @@ var stopCmd = &cobra.Command{
 			return fmt.Errorf("this directory uses a parent sandbox at %s\nRun 'sandbox stop' from %s instead", sandboxRoot, sandboxRoot)
 		}
 
-		name := cmd.ContainerName(sandboxRoot)
+		name := cmd.SandboxContainer(sandboxRoot)
 		if !cmd.IsRunning(name) {
-			fmt.Printf("No sandbox running for %s\n", sandboxRoot)
-			return nil
+			return printResult(sandboxResult{Sandbox: name, Workspace: sandboxRoot, Status: "not-running"}, "No sandbox running for %s", sandboxRoot)
 		}
-		if err := cmd.DockerRun("stop", name); err != nil {
-			return fmt.Errorf("stop container: %w", err)
+		if err := cmd.StopContainer(name); err != nil {
+			return err
 		}
-		fmt.Printf("Sandbox %s stopped\n", name)
-		return nil
+		return printResult(sandboxResult{Sandbox: name, Workspace: sandboxRoot, Status: "stopped"}, "Sandbox %s stopped", name)
 	},
 }
 
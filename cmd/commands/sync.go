@@ -1,17 +1,38 @@
 package commands
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strings"
 
 	cmd "github.com/franklin-ross/sandbox/cmd"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	syncStrictSecrets bool
+	syncClobber       bool
 )
 
 var syncCmd = &cobra.Command{
 	Use:   "sync [path]",
 	Short: "Force-sync all files into a sandbox",
-	Long:  `Push all configured files into a sandbox container, even if they haven't changed. Starts the sandbox if not running.`,
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Push all configured files into a sandbox container, even if they haven't changed. Starts the sandbox if not running.
+
+Synced files and env_files are scanned for likely credentials (private key
+and cloud credential files, known token formats, and high-entropy values
+assigned to secret-sounding names). Findings are warnings unless
+--strict-secrets is given or the config sets strict_secrets, in which case
+the sync is refused. secret_allow in the config exempts known-safe findings.
+
+Synced files the agent has edited inside the sandbox since the last sync are
+not overwritten silently. Each is listed with whether its source changed too
+and how many lines overwriting would add and remove; on a terminal you are
+asked about each one, and declined files keep the sandbox's version.
+Otherwise the sync fails unless --clobber is given.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(_ *cobra.Command, args []string) error {
 		wsPath := "."
 		if len(args) > 0 {
@@ -24,14 +45,47 @@ var syncCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		if err := cmd.SyncContainer(name, sandboxRoot, true); err != nil {
+		opts := cmd.SyncOptions{Force: true, StrictSecrets: syncStrictSecrets, Clobber: syncClobber}
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			opts.ConfirmOverwrite = confirmOverwrite
+		}
+		if err := cmd.SyncContainer(name, sandboxRoot, opts); err != nil {
 			return err
 		}
-		fmt.Println("Sync complete")
-		return nil
+		return printResult(sandboxResult{Sandbox: name, Workspace: sandboxRoot, Status: "synced"}, "Sync complete")
 	},
 }
 
+// confirmOverwrite asks whether to overwrite a file edited in the sandbox,
+// defaulting to no.
+func confirmOverwrite(c cmd.SyncConflict) bool {
+	fmt.Fprintf(os.Stderr, "%s\nOverwrite the sandbox's copy? [y/N] ", c.Summary())
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// promptRootHook asks on the terminal whether to run a root on_sync hook,
+// defaulting to no. See cmd.RootHookPrompt.
+func promptRootHook(wsPath string, hook cmd.OnSyncHook) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return false
+	}
+	label := ""
+	if hook.Name != "" {
+		label = fmt.Sprintf(" %q", hook.Name)
+	}
+	fmt.Fprintf(os.Stderr, "on_sync hook%s runs as root in the sandbox for %s:\n  %s\n", label, wsPath, hook.Cmd)
+	fmt.Fprintf(os.Stderr, "Run it, and don't ask again for this command? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func init() {
+	cmd.RootHookPrompt = promptRootHook
+
+	syncCmd.Flags().BoolVar(&syncClobber, "clobber", false, "overwrite synced files edited inside the sandbox without asking")
+	syncCmd.Flags().BoolVar(&syncStrictSecrets, "strict-secrets", false, "refuse to sync files that look like they contain credentials")
 	cmd.RootCmd.AddCommand(syncCmd)
 }
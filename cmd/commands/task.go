@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var taskDir string
+
+var taskCmd = &cobra.Command{
+	Use:   "task [target...] [-- task-args...]",
+	Short: "Run a Taskfile target in the sandbox",
+	Long: `Run task (https://taskfile.dev) inside the sandbox, in the current directory
+or the one given with --dir, with the same env as sandbox sessions. Without
+a target task runs its default task. Arguments after -- reach the task as
+{{.CLI_ARGS}}.
+
+Target names are completed from the Taskfile, including those of included
+Taskfiles.
+
+Examples:
+  sandbox task
+  sandbox task test
+  sandbox task -d ~/proj lint build
+  sandbox task run -- --port 8080`,
+	ValidArgsFunction: completeTaskTargets,
+	RunE: func(c *cobra.Command, args []string) error {
+		dir := cmd.ResolvePath(taskDir)
+		sandboxRoot, workDir := cmd.ResolveWorkspace(dir)
+		if cmd.FindTaskfile(workDir, sandboxRoot) == "" {
+			return fmt.Errorf("no Taskfile found in %s", workDir)
+		}
+		return runInSandbox("task", dir, taskArgs(args, c.ArgsLenAtDash())...)
+	},
+}
+
+// taskArgs builds task's arguments, restoring the "--" cobra strips so
+// trailing arguments still reach the task as CLI_ARGS.
+func taskArgs(args []string, dash int) []string {
+	if dash < 0 {
+		return args
+	}
+	out := append([]string{}, args[:dash]...)
+	out = append(out, "--")
+	return append(out, args[dash:]...)
+}
+
+// completeTaskTargets offers the targets of the Taskfile that would run.
+func completeTaskTargets(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	dir := cmd.ResolvePath(taskDir)
+	root := cmd.SandboxRootFor(dir)
+	path := cmd.FindTaskfile(dir, root)
+	if path == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	targets, err := cmd.TaskfileTargets(path)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var out []string
+	for _, t := range targets {
+		if !strings.HasPrefix(t.Name, toComplete) {
+			continue
+		}
+		if t.Desc != "" {
+			out = append(out, t.Name+"\t"+t.Desc)
+		} else {
+			out = append(out, t.Name)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	taskCmd.Flags().StringVarP(&taskDir, "dir", "d", ".", "directory to run task in")
+	cmd.RootCmd.AddCommand(taskCmd)
+}
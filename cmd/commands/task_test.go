@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTaskArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		dash int
+		want string
+	}{
+		{nil, -1, "task"},
+		{[]string{"lint", "build"}, -1, "task lint build"},
+		{[]string{"run", "--port", "8080"}, 1, "task run -- --port 8080"},
+		{[]string{"x"}, 0, "task -- x"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(append([]string{"task"}, taskArgs(tt.args, tt.dash)...), " "); got != tt.want {
+			t.Errorf("taskArgs(%q, %d) = %q, want %q", tt.args, tt.dash, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [path]",
+	Short: "Update the sandbox image and recreate the sandbox on it",
+	Long: `Bring the workspace's sandbox image up to date, rebuilding it or pulling
+image.pull again, and recreate the sandbox from it if it runs an older one.
+A running sandbox is started and synced again; a stopped one is just
+removed, to be created on its next start.
+
+As with sandbox rm, only the workspace and a creds_volume are kept.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		sandboxRoot, _ := cmd.ResolveWorkspace(cmd.ResolvePath(wsPath))
+		if err := cmd.DockerAvailable(); err != nil {
+			return err
+		}
+		name := cmd.SandboxContainer(sandboxRoot)
+		upgraded, err := cmd.UpgradeSandbox(sandboxRoot)
+		if err != nil {
+			return err
+		}
+		result := sandboxResult{Sandbox: name, Workspace: sandboxRoot, Status: "current"}
+		if upgraded {
+			result.Status = "upgraded"
+			return printResult(result, "Sandbox %s upgraded", name)
+		}
+		return printResult(result, "Sandbox image for %s is up to date", sandboxRoot)
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(upgradeCmd)
+}
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [path]",
+	Short: "Check a sandbox's firewall and helper scripts for tampering",
+	Long: `Compare the sha256 of the firewall script, resource scope helper and host
+tool helper inside a running sandbox against the copies embedded in this
+binary. Modified or
+missing scripts are reported and replaced with pristine copies, and the
+firewall is re-applied. Exits non-zero if anything had been modified.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		wsPath := "."
+		if len(args) > 0 {
+			wsPath = args[0]
+		}
+		wsPath = cmd.ResolvePath(wsPath)
+		sandboxRoot, _ := cmd.ResolveWorkspace(wsPath)
+
+		name := cmd.SandboxContainer(sandboxRoot)
+		if !cmd.IsRunning(name) {
+			return cmd.NotRunningError(sandboxRoot)
+		}
+		checks, err := cmd.VerifyScripts(name)
+		if err != nil {
+			return err
+		}
+
+		result := verifyResult{Sandbox: name, Scripts: []scriptStatus{}}
+		tampered := 0
+		for _, c := range checks {
+			s := scriptStatus{Path: c.Path, Status: "ok"}
+			switch {
+			case c.OK():
+			case c.Got == "":
+				tampered++
+				s.Status = "missing"
+			default:
+				tampered++
+				s.Status = "modified"
+			}
+			result.Scripts = append(result.Scripts, s)
+			if !cmd.JSONOutput() {
+				label := s.Status
+				if label != "ok" {
+					label = strings.ToUpper(label)
+				}
+				fmt.Printf("%-9s %s\n", label, c.Path)
+			}
+		}
+		if tampered > 0 {
+			if err = cmd.RestoreScripts(name, checks); err == nil {
+				result.Restored = true
+				err = &cmd.Error{Kind: cmd.KindFirewall, Err: fmt.Errorf("%d script(s) had been modified inside %s; restored pristine copies", tampered, name)}
+			}
+		}
+		if cmd.JSONOutput() {
+			if err != nil {
+				result.Error = cmd.Redact(err.Error())
+			}
+			cmd.PrintJSON(result)
+		}
+		return err
+	},
+}
+
+// verifyResult is verify's --json result.
+type verifyResult struct {
+	Sandbox  string         `json:"sandbox"`
+	Scripts  []scriptStatus `json:"scripts"`
+	Restored bool           `json:"restored"` // modified scripts were replaced
+	Error    string         `json:"error,omitempty"`
+}
+
+type scriptStatus struct {
+	Path   string `json:"path"`
+	Status string `json:"status"` // "ok", "missing" or "modified"
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var (
+	whichDir    string
+	whichToHost bool
+)
+
+var whichCmd = &cobra.Command{
+	Use:   "which <path>",
+	Short: "Map a host path to its path in the sandbox, or back",
+	Long: `Print where the running sandbox sees a host path, using the container's
+mounts, and check that it exists there. With --to-host, map a path in the
+container back to the host instead. The sandbox is the one for the current
+directory, or for --dir.
+
+Only mounted paths map: files synced into the container (such as those in
+/home/agent) are copies, and paths in a volume have no host path. Exits
+non-zero when the path doesn't map or doesn't exist in the container.
+
+Examples:
+  sandbox which src/main.go
+  docker exec sandbox-proj cat "$(sandbox which go.mod)"
+  sandbox which --to-host /home/user/proj/build/out.log`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		sandboxRoot := cmd.SandboxRootFor(cmd.ResolvePath(whichDir))
+		name := cmd.SandboxContainer(sandboxRoot)
+		if !cmd.IsRunning(name) {
+			return cmd.NotRunningError(sandboxRoot)
+		}
+		mounts, err := cmd.ContainerMounts(name)
+		if err != nil {
+			return err
+		}
+
+		if whichToHost {
+			p := filepath.Clean(args[0])
+			if !filepath.IsAbs(p) {
+				return fmt.Errorf("%s: container paths must be absolute", args[0])
+			}
+			hostPath, ok := cmd.ContainerToHost(mounts, p)
+			if !ok {
+				if v, ok := cmd.VolumeFor(mounts, p); ok {
+					return fmt.Errorf("%s is in volume %s, which has no host path", p, v.Name)
+				}
+				return fmt.Errorf("%s is not mounted from the host", p)
+			}
+			fmt.Println(hostPath)
+			if !cmd.ExistsInContainer(name, p) {
+				return fmt.Errorf("%s does not exist in %s", p, name)
+			}
+			return nil
+		}
+
+		hostPath := cmd.ResolvePath(args[0])
+		p, ok := cmd.HostToContainer(mounts, hostPath)
+		if !ok {
+			return fmt.Errorf("%s is not mounted into %s", hostPath, name)
+		}
+		fmt.Println(p)
+		if !cmd.ExistsInContainer(name, p) {
+			return fmt.Errorf("%s does not exist in %s", p, name)
+		}
+		return nil
+	},
+}
+
+func init() {
+	whichCmd.Flags().StringVarP(&whichDir, "dir", "d", ".", "directory whose sandbox to use")
+	whichCmd.Flags().BoolVar(&whichToHost, "to-host", false, "map a container path to the host")
+	cmd.RootCmd.AddCommand(whichCmd)
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	cmd "github.com/franklin-ross/sandbox/cmd"
+	"github.com/spf13/cobra"
+)
+
+var workspaceSyncCmd = &cobra.Command{
+	Use:   "workspace-sync <container>",
+	Short: "Run the built-in workspace sync for a sandbox",
+	Long: `Keep a sandbox's copy of the workspace and the host's in step, for
+workspace.sync.engine: builtin. Starting the sandbox runs it in the
+background, and it exits when the sandbox stops; stopping the sandbox asks
+it to sync one last time first. It logs to workspace-sync/<container>.log
+in the cache directory.`,
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return cmd.RunBuiltinSync(ctx, args[0])
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(workspaceSyncCmd)
+}
@@ -1,11 +1,19 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,56 +23,426 @@ const DefaultHostToolPort = 9847
 
 // SandboxConfig holds the user-editable sandbox configuration.
 type SandboxConfig struct {
-	Sync         []SyncRule        `yaml:"sync"`
-	Env          map[string]string `yaml:"env"`
-	Firewall     FirewallConfig    `yaml:"firewall"`
-	OnSync       []OnSyncHook      `yaml:"on_sync"`
-	HostTools    []HostTool        `yaml:"host_tools"`
-	HostToolPort int               `yaml:"host_tool_port"`
+	Sync           []SyncRule          `yaml:"sync,omitempty"`
+	Env            map[string]string   `yaml:"env,omitempty"`
+	EnvFiles       []string            `yaml:"env_files,omitempty"`
+	EnvStrict      bool                `yaml:"env_strict,omitempty"`
+	Firewall       FirewallConfig      `yaml:"firewall,omitempty"`
+	Hosts          []HostEntry         `yaml:"hosts,omitempty"`
+	DNS            DNSConfig           `yaml:"dns,omitempty"`
+	Proxy          ProxyConfig         `yaml:"proxy,omitempty"`
+	CACertificates []string            `yaml:"ca_certificates,omitempty"` // host PEM files of extra CAs to trust
+	CacheVolumes   []string            `yaml:"cache_volumes,omitempty"`   // paths kept in volumes that outlive the container, e.g. "target/"
+	OnSync         []OnSyncHook        `yaml:"on_sync,omitempty"`
+	HostTools      []HostTool          `yaml:"host_tools,omitempty"`
+	HostToolPort   int                 `yaml:"host_tool_port,omitempty"`
+	KeyProviders   []KeyProvider       `yaml:"key_providers,omitempty"` // honoured in the global config only
+	Limits         LimitsConfig        `yaml:"limits,omitempty"`
+	Transfer       TransferConfig      `yaml:"transfer,omitempty"`
+	CredsVolume    string              `yaml:"creds_volume,omitempty"` // "shared", "workspace" or a volume name; empty keeps credentials in the container
+	SecretPatterns []SecretPattern     `yaml:"secret_patterns,omitempty"`
+	SecretAllow    []SecretAllow       `yaml:"secret_allow,omitempty"`
+	StrictSecrets  bool                `yaml:"strict_secrets,omitempty"` // refuse to sync files that look like they contain credentials
+	Requires       []Requirement       `yaml:"requires,omitempty"`
+	Share          ShareConfig         `yaml:"share,omitempty"`
+	SSH            SSHConfig           `yaml:"ssh,omitempty"`
+	VSCode         VSCodeConfig        `yaml:"vscode,omitempty"`
+	GPG            GPGConfig           `yaml:"gpg,omitempty"`
+	Git            GitConfig           `yaml:"git,omitempty"`
+	Security       SecurityConfig      `yaml:"security,omitempty"`
+	Hardening      HardeningConfig     `yaml:"hardening,omitempty"`
+	Resources      ResourcesConfig     `yaml:"resources,omitempty"`
+	Workspace      WorkspaceConfig     `yaml:"workspace,omitempty"`
+	Notifications  NotificationsConfig `yaml:"notifications,omitempty"`
+	Docker         DockerConfig        `yaml:"docker,omitempty"` // honoured in the global config only
+	Image          ImageConfig         `yaml:"image,omitempty"`  // honoured in the global config only
+	Toolchains     ToolchainsConfig    `yaml:"toolchains,omitempty"`
+
+	// HostClaude copies the host's Claude settings, global CLAUDE.md and
+	// custom slash commands into the container on sync.
+	HostClaude bool `yaml:"host_claude,omitempty"`
+
+	// Commands holds per-command overrides, keyed by CommandNames.
+	Commands map[string]CommandConfig `yaml:"commands,omitempty"`
+
+	// Agents are agent CLIs launched with `sandbox run <name>`.
+	Agents []Agent `yaml:"agents,omitempty"`
+
+	// Profiles are named overlays selected with --profile or
+	// SANDBOX_PROFILE. Honoured in the global config only.
+	Profiles map[string]*SandboxConfig `yaml:"profiles,omitempty"`
+}
+
+// Session timeout actions for LimitsConfig.OnTimeout.
+const (
+	TimeoutStop  = "stop"
+	TimeoutPause = "pause"
+)
+
+// LimitsConfig bounds how long unattended sessions may run.
+type LimitsConfig struct {
+	SessionTimeout string `yaml:"session_timeout,omitempty"` // Go duration, e.g. "8h"; empty means no limit
+	OnTimeout      string `yaml:"on_timeout,omitempty"`      // "stop" (default) or "pause"
+
+	// SessionMemory and SessionCPUs set ceilings for the cgroup scope
+	// `sandbox claude` sessions run in.
+	SessionMemory string  `yaml:"session_memory,omitempty"`
+	SessionCPUs   float64 `yaml:"session_cpus,omitempty"`
+}
+
+// CommandNames are the keys accepted under commands: sandbox commands that
+// run something in the container, and "hooks" for on_sync hooks.
+var CommandNames = []string{"claude", "codex", "gemini", "aider", "shell", "npm", "make", "task", "hooks"}
+
+// CommandConfig overrides settings for one command, so users needn't wrap
+// the CLI in shell aliases.
+type CommandConfig struct {
+	// Args are added before the arguments given on the command line (for
+	// the agent CLIs, after the flag that skips permission prompts). Not used
+	// for hooks.
+	Args []string `yaml:"args,omitempty"`
+
+	// Env is merged over the top-level env for the command. Hooks get only
+	// this env.
+	Env map[string]string `yaml:"env,omitempty"`
+
+	// Workdir is where the command runs when started from the workspace
+	// root; relative paths are relative to it. Hooks always run there.
+	Workdir string `yaml:"workdir,omitempty"`
+}
+
+// Command returns the overrides for the named command. For a configured
+// agent, these are its args, env and workdir.
+func (c *SandboxConfig) Command(name string) CommandConfig {
+	if cc, ok := c.Commands[name]; ok {
+		return cc
+	}
+	if a, ok := c.Agent(name); ok {
+		return CommandConfig{Args: a.Args, Env: a.Env, Workdir: a.Workdir}
+	}
+	return CommandConfig{}
+}
+
+// ForCommand returns cfg with the named command's env merged over Env, for
+// passing to DockerExec. cfg is returned as is if the command sets no env.
+func (c *SandboxConfig) ForCommand(name string) *SandboxConfig {
+	cc := c.Command(name)
+	if len(cc.Env) == 0 {
+		return c
+	}
+	out := *c
+	out.Env = make(map[string]string, len(c.Env)+len(cc.Env))
+	for k, v := range c.Env {
+		out.Env[k] = v
+	}
+	for k, v := range cc.Env {
+		out.Env[k] = v
+	}
+	return &out
+}
+
+// Dir returns the directory to run the command in: workDir if the user is
+// in a subdirectory of sandboxRoot, else the configured workdir, else
+// sandboxRoot.
+func (cc CommandConfig) Dir(sandboxRoot, workDir string) string {
+	if workDir != sandboxRoot || cc.Workdir == "" {
+		return workDir
+	}
+	if filepath.IsAbs(cc.Workdir) {
+		return cc.Workdir
+	}
+	return filepath.Join(sandboxRoot, cc.Workdir)
+}
+
+// Ways ShareConfig.Via exposes a sandbox.
+const (
+	ShareTailscale = "tailscale"
+	ShareTunnel    = "tunnel"
+)
+
+// ShareConfig opts a sandbox in to `sandbox share`, which exposes container
+// ports to teammates on another machine.
+type ShareConfig struct {
+	Via    string        `yaml:"via,omitempty"`    // "tailscale" or "tunnel"; empty disables sharing
+	Ports  []int         `yaml:"ports,omitempty"`  // container ports to expose
+	Tunnel *TunnelConfig `yaml:"tunnel,omitempty"` // required when via is "tunnel"
+}
+
+// SSHConfig runs an SSH server in the sandbox, for `sandbox ssh` and editors
+// that connect over SSH. Both fields take effect when the container is
+// created.
+type SSHConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	Port    int  `yaml:"port,omitempty"` // host port on 127.0.0.1; 0 lets Docker pick one
+}
+
+// TunnelConfig is an SSH server the container opens reverse forwards on.
+type TunnelConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port,omitempty"` // SSH port, default 22
+	User string `yaml:"user,omitempty"`
+}
+
+// SSHPort returns the tunnel's SSH port, defaulting to 22.
+func (t *TunnelConfig) SSHPort() int {
+	if t.Port == 0 {
+		return 22
+	}
+	return t.Port
+}
+
+// Defaults for TransferConfig.
+const (
+	DefaultLargeFile = 64 << 20
+	DefaultChunkSize = 8 << 20
+)
+
+// TransferConfig controls how sync copies large files. Sizes are byte
+// counts with an optional K, M or G suffix.
+type TransferConfig struct {
+	LargeFile string `yaml:"large_file,omitempty"` // sync rule files at least this big are sent in resumable chunks; default 64M
+	ChunkSize string `yaml:"chunk_size,omitempty"` // default 8M
+	MaxRate   string `yaml:"max_rate,omitempty"`   // bytes per second for large files; empty means unlimited
+}
+
+// sizes returns the transfer settings in bytes, with defaults applied.
+// Invalid values are dropped at parse time, so parse errors are ignored.
+func (t TransferConfig) sizes() (largeFile, chunkSize, maxRate int64) {
+	largeFile, chunkSize = DefaultLargeFile, DefaultChunkSize
+	if n, err := parseSize(t.LargeFile); err == nil && n > 0 {
+		largeFile = n
+	}
+	if n, err := parseSize(t.ChunkSize); err == nil && n > 0 {
+		chunkSize = n
+	}
+	maxRate, _ = parseSize(t.MaxRate)
+	return largeFile, chunkSize, maxRate
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix (powers
+// of 1024). An empty string is 0.
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if !memoryLimitRe.MatchString(s) {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 512K, 8M or 2G)", s)
+	}
+	shift := 0
+	switch s[len(s)-1] {
+	case 'K', 'k':
+		shift = 10
+	case 'M', 'm':
+		shift = 20
+	case 'G', 'g':
+		shift = 30
+	}
+	if shift > 0 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n << shift, nil
+}
+
+func validateTransfer(t TransferConfig) error {
+	for _, f := range []struct{ name, value string }{
+		{"large_file", t.LargeFile}, {"chunk_size", t.ChunkSize}, {"max_rate", t.MaxRate},
+	} {
+		if _, err := parseSize(f.value); err != nil {
+			return fmt.Errorf("transfer.%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// ResourceLimits are CPU and memory ceilings for a cgroup scope inside the
+// container. Memory is a byte count with an optional K, M or G suffix.
+type ResourceLimits struct {
+	Memory string
+	CPUs   float64
+}
+
+// IsZero reports whether no ceiling is set.
+func (r ResourceLimits) IsZero() bool {
+	return r.Memory == "" && r.CPUs == 0
+}
+
+// memoryLimitRe matches memory sizes accepted by cgroup v2's memory.max.
+var memoryLimitRe = regexp.MustCompile(`^[0-9]+[KMGkmg]?$`)
+
+// validResources reports whether memory and cpus are acceptable ceilings,
+// describing the problem otherwise.
+func validResources(memory string, cpus float64) error {
+	if memory != "" && !memoryLimitRe.MatchString(memory) {
+		return fmt.Errorf("invalid memory %q (want e.g. 512M or 2G)", memory)
+	}
+	if cpus < 0 {
+		return fmt.Errorf("invalid cpus %v", cpus)
+	}
+	return nil
+}
+
+// SessionTimeoutDuration returns the parsed session timeout, or 0 if none is
+// set. Like hook timeouts, invalid values are dropped at parse time.
+func (l LimitsConfig) SessionTimeoutDuration() time.Duration {
+	d, _ := time.ParseDuration(l.SessionTimeout)
+	return d
+}
+
+// TimeoutAction returns what to do when a session hits its timeout,
+// defaulting to "stop".
+func (l LimitsConfig) TimeoutAction() string {
+	if l.OnTimeout == "" {
+		return TimeoutStop
+	}
+	return l.OnTimeout
+}
+
+// workspaceLimits returns l, from a workspace config, without the settings
+// that would lift global's, the global config's, warning about each. The
+// agent can write the workspace config, so it may only shorten the session
+// timeout and lower the ceilings; on_timeout is the global config's.
+func workspaceLimits(l, global LimitsConfig) LimitsConfig {
+	if l.SessionTimeout != "" && global.SessionTimeout != "" && l.SessionTimeoutDuration() > global.SessionTimeoutDuration() {
+		Warnf(WarnConfig, "limits.session_timeout can only be shortened in a workspace config, ignoring %q", l.SessionTimeout)
+		l.SessionTimeout = ""
+	}
+	if l.OnTimeout != "" {
+		Warnf(WarnConfig, "limits.on_timeout is only read from the global config, ignoring the workspace's")
+		l.OnTimeout = ""
+	}
+	if l.SessionMemory != "" && global.SessionMemory != "" {
+		have, _ := parseSize(l.SessionMemory)
+		limit, _ := parseSize(global.SessionMemory)
+		if have > limit {
+			Warnf(WarnConfig, "limits.session_memory can only be lowered in a workspace config, ignoring %q", l.SessionMemory)
+			l.SessionMemory = ""
+		}
+	}
+	if l.SessionCPUs != 0 && global.SessionCPUs != 0 && l.SessionCPUs > global.SessionCPUs {
+		Warnf(WarnConfig, "limits.session_cpus can only be lowered in a workspace config, ignoring %v", l.SessionCPUs)
+		l.SessionCPUs = 0
+	}
+	return l
 }
 
 // HostTool describes a command the agent can trigger on the host.
 type HostTool struct {
 	Name        string `yaml:"name"`
-	Description string `yaml:"description"`
+	Description string `yaml:"description,omitempty"`
 	Cmd         string `yaml:"cmd"`
 }
 
 // OnSyncHook describes a command to run inside the container after sync.
 type OnSyncHook struct {
-	Cmd  string `yaml:"cmd"`
-	Name string `yaml:"name"`
-	Root bool   `yaml:"root"`
+	Cmd       string `yaml:"cmd"`
+	Name      string `yaml:"name,omitempty"`
+	Root      bool   `yaml:"root,omitempty"`
+	Timeout   string `yaml:"timeout,omitempty"`    // Go duration, e.g. "5m"; empty means no limit
+	OnFailure string `yaml:"on_failure,omitempty"` // "fail" (default), "warn", or "retry"
+
+	// Memory and CPUs set ceilings for the hook's own cgroup scope, e.g.
+	// "2G" and 1.5. See ResourceLimits.
+	Memory string  `yaml:"memory,omitempty"`
+	CPUs   float64 `yaml:"cpus,omitempty"`
+
+	// WhenChanged limits the hook to syncs where a matching file changed.
+	// Patterns starting with "/" or "~/" match container paths in the sync
+	// manifest; others are globs relative to the workspace root.
+	WhenChanged []string `yaml:"when_changed,omitempty"`
 }
 
-// SyncRule describes a file to sync into the container.
+// Hook failure policies.
+const (
+	HookFailureFail  = "fail"
+	HookFailureWarn  = "warn"
+	HookFailureRetry = "retry"
+)
+
+// TimeoutDuration returns the parsed hook timeout, or 0 if none is set.
+// Timeouts are validated at parse time, so an invalid value here is treated
+// as no limit.
+func (h OnSyncHook) TimeoutDuration() time.Duration {
+	d, _ := time.ParseDuration(h.Timeout)
+	return d
+}
+
+// FailurePolicy returns the hook's on_failure policy, defaulting to "fail".
+func (h OnSyncHook) FailurePolicy() string {
+	if h.OnFailure == "" {
+		return HookFailureFail
+	}
+	return h.OnFailure
+}
+
+// SyncRule describes a file to sync into the container. Src is a doublestar
+// glob; Exclude lists patterns for matches to skip.
 type SyncRule struct {
-	Src   string `yaml:"src"`
-	Dest  string `yaml:"dest"`
-	Mode  string `yaml:"mode"`
-	Owner string `yaml:"owner"`
+	Src     string   `yaml:"src"`
+	Dest    string   `yaml:"dest"`
+	Mode    string   `yaml:"mode,omitempty"`
+	Owner   string   `yaml:"owner,omitempty"` // "user" or "user:group"; must exist in the container
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	// CreateUser creates the owner's user and group in the container if
+	// they don't already exist, for custom images with service accounts.
+	CreateUser bool `yaml:"create_user,omitempty"`
 }
 
 // FirewallConfig holds firewall allowlist rules.
 type FirewallConfig struct {
-	Allow []FirewallEntry `yaml:"allow"`
+	Allow           []FirewallEntry `yaml:"allow,omitempty"`
+	PrivateNetworks bool            `yaml:"private_networks,omitempty"` // allow the RFC 1918 ranges and Docker's bridge subnet
 }
 
 // FirewallEntry describes a single firewall allowlist entry.
 type FirewallEntry struct {
-	Domain string `yaml:"domain"`
-	CIDR   string `yaml:"cidr"`
-	Ports  []int  `yaml:"ports"`
+	Domain string `yaml:"domain,omitempty"`
+	CIDR   string `yaml:"cidr,omitempty"`
+	Ports  []int  `yaml:"ports,omitempty"`
 }
 
 // SyncItem is an internal type used by the sync pipeline.
 type SyncItem struct {
-	Data  []byte
-	Dest  string
-	Mode  string // "0644" or "0755"
-	Owner string // "root:root" or "agent:agent"
+	Data       []byte
+	Dest       string
+	Mode       string // "0644" or "0755"
+	Owner      string // "user:group", e.g. "root:root" or "agent:agent"
+	Source     string // host path the data came from; empty for generated items
+	CreateUser bool   // create Owner in the container if missing
+
+	// Large items are streamed from Source in resumable chunks instead of
+	// being held in Data. Stamp identifies the version of Source.
+	Large bool
+	Size  int64
+	Stamp string
 }
 
+// DefaultWorkspaceConfigYAML is the starting point for a new workspace config.
+// It adds nothing until edited, so the global config applies as-is.
+const DefaultWorkspaceConfigYAML = `# Workspace sandbox configuration, merged over ~/.sandbox/config.yaml.
+# Run 'sandbox config show' to see the result.
+
+# Domains this project needs on top of the global allowlist.
+firewall:
+  allow: []
+  #  - domain: api.example.com
+
+# sync:
+#   - src: ~/.config/myapp/config.toml
+#     dest: ~/.config/myapp/config.toml
+
+# env:
+#   NODE_ENV: development
+
+# on_sync:
+#   - cmd: npm install
+#     when_changed: [package.json, package-lock.json]
+`
+
 const DefaultConfigYAML = `# Sandbox configuration
 # Global: ~/.sandbox/config.yaml
 # Per-workspace: <workspace>/.sandbox/config.yaml
@@ -76,6 +454,12 @@ sync:
 
 env: {}
 
+# Load env from dotenv files (relative paths are from the workspace root).
+# Later files override earlier ones; keys in env above take precedence.
+# env_files:
+#   - .env
+#   - .env.local
+
 firewall:
   allow:
     # Claude API
@@ -84,6 +468,20 @@ firewall:
     - domain: statsig.anthropic.com
     - domain: sentry.io
 
+    # OpenAI API (sandbox codex)
+    - domain: api.openai.com
+    - domain: auth.openai.com
+    - domain: chatgpt.com
+
+    # Gemini API (sandbox gemini)
+    - domain: generativelanguage.googleapis.com
+    - domain: cloudcode-pa.googleapis.com
+    - domain: oauth2.googleapis.com
+
+    # Other model APIs (sandbox aider)
+    - domain: openrouter.ai
+    - domain: api.deepseek.com
+
     # npm / yarn / pnpm
     - domain: registry.npmjs.org
     - domain: registry.yarnpkg.com
@@ -136,9 +534,18 @@ firewall:
 # on_sync:
 #   - cmd: npm install
 #     name: install deps
+#     timeout: 5m
+#     on_failure: retry
+#     when_changed: [package.json, package-lock.json]
 #   - cmd: chmod 600 ~/.ssh/*
 #     root: true
 
+# Host tools sandbox commands need; checked before a sandbox starts and by
+# 'sandbox doctor'.
+# requires:
+#   - docker>=24
+#   - git
+
 # host_tools:
 #   - name: deploy
 #     description: Deploy the app to staging
@@ -158,35 +565,144 @@ func parseConfigFile(path string) (*SandboxConfig, error) {
 		return nil, err
 	}
 
+	cfg, warnings, err := parseConfig(data)
+	if err != nil {
+		err = configParseError(path, err)
+		if !flagIgnoreConfigErrors {
+			return nil, fmt.Errorf("%w\nfix the file, or pass --ignore-config-errors to continue without it", err)
+		}
+		Warnf(WarnConfig, "%v", err)
+		Warnf(WarnConfig, "ignoring %s", path)
+		return &SandboxConfig{}, nil
+	}
+	for _, w := range warnings {
+		Warnf(WarnConfig, "%s", w)
+	}
+	return cfg, nil
+}
+
+// ErrNoConfig is returned by LoadConfig when neither the global nor the
+// workspace config exists.
+var ErrNoConfig = errors.New("no sandbox config found; run 'sandbox config init' to create one")
+
+// flagIgnoreConfigErrors is set by the global --ignore-config-errors flag.
+var flagIgnoreConfigErrors bool
+
+// configParseError describes why the config at path couldn't be decoded,
+// with a path:line prefix for each problem.
+func configParseError(path string, err error) error {
+	msgs := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	}
+	var lines []string
+	for _, msg := range msgs {
+		e := yamlError(msg)
+		if e.Line == 0 {
+			lines = append(lines, fmt.Sprintf("%s: %s", path, e.Msg))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s:%d: %s", path, e.Line, e.Msg))
+		}
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// parseConfig decodes and validates config YAML. Invalid entries are dropped
+// or reset, and each is described in warnings; err is only set when data
+// can't be decoded at all.
+func parseConfig(data []byte) (*SandboxConfig, []string, error) {
 	var cfg SandboxConfig
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "warning: failed to parse %s: %v\n", path, err)
-		return &SandboxConfig{}, nil
+		return nil, nil, err
+	}
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
 	}
+	sanitizeConfig(&cfg, warn)
+	for name, p := range cfg.Profiles {
+		if p == nil {
+			// An empty profile is valid: it just selects the global config.
+			cfg.Profiles[name] = &SandboxConfig{}
+			continue
+		}
+		if len(p.Profiles) > 0 || len(p.KeyProviders) > 0 || p.Image.set() {
+			warn("profile %q: profiles, key_providers and image can't be set in a profile, ignoring them", name)
+			p.Profiles, p.KeyProviders, p.Image = nil, nil, ImageConfig{}
+		}
+		sanitizeConfig(p, func(format string, args ...any) {
+			warn("profile %q: "+format, append([]any{name}, args...)...)
+		})
+	}
+	return &cfg, warnings, nil
+}
 
+// sanitizeConfig drops or resets invalid entries in cfg, describing each
+// through warn.
+func sanitizeConfig(cfg *SandboxConfig, warn func(format string, args ...any)) {
 	// Validate firewall entries
 	var valid []FirewallEntry
 	for _, e := range cfg.Firewall.Allow {
-		if validateFirewallEntry(e) {
-			valid = append(valid, e)
+		if err := validateFirewallEntry(e); err != nil {
+			warn("%v, skipping", err)
+			continue
 		}
+		valid = append(valid, e)
 	}
 	cfg.Firewall.Allow = valid
 
+	// Validate hosts
+	var validHosts []HostEntry
+	for _, h := range cfg.Hosts {
+		if err := validateHostEntry(h); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		validHosts = append(validHosts, h)
+	}
+	cfg.Hosts = validHosts
+
+	if err := validateDNS(cfg.DNS); err != nil {
+		warn("%v, ignoring dns", err)
+		cfg.DNS = DNSConfig{}
+	}
+
+	if err := validateProxy(cfg.Proxy); err != nil {
+		warn("%v, ignoring proxy", err)
+		cfg.Proxy = ProxyConfig{}
+	}
+
+	var validCerts []string
+	for _, path := range cfg.CACertificates {
+		if err := validateCACertificate(path); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		validCerts = append(validCerts, path)
+	}
+	cfg.CACertificates = validCerts
+
+	var validCaches []string
+	for _, p := range cfg.CacheVolumes {
+		if err := validateCacheVolume(p); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		validCaches = append(validCaches, p)
+	}
+	cfg.CacheVolumes = validCaches
+
 	// Validate host_tools
 	seenTools := make(map[string]bool)
 	var validTools []HostTool
 	for _, ht := range cfg.HostTools {
-		if strings.TrimSpace(ht.Name) == "" {
-			fmt.Fprintf(os.Stderr, "warning: host_tool with empty name, skipping\n")
-			continue
-		}
-		if strings.TrimSpace(ht.Cmd) == "" {
-			fmt.Fprintf(os.Stderr, "warning: host_tool %q with empty cmd, skipping\n", ht.Name)
+		if err := validateHostTool(ht); err != nil {
+			warn("%v, skipping", err)
 			continue
 		}
 		if seenTools[ht.Name] {
-			fmt.Fprintf(os.Stderr, "warning: duplicate host_tool %q, skipping\n", ht.Name)
+			warn("duplicate host_tool %q, skipping", ht.Name)
 			continue
 		}
 		seenTools[ht.Name] = true
@@ -194,61 +710,631 @@ func parseConfigFile(path string) (*SandboxConfig, error) {
 	}
 	cfg.HostTools = validTools
 
+	// Validate key providers
+	var validProviders []KeyProvider
+	for _, p := range cfg.KeyProviders {
+		if err := validateKeyProvider(p); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		validProviders = append(validProviders, p)
+	}
+	cfg.KeyProviders = validProviders
+
+	// Validate sync rules
+	var validRules []SyncRule
+	for _, r := range cfg.Sync {
+		if err := validateSyncRule(r); err != nil {
+			warn("sync rule for %s: %v, skipping", r.Src, err)
+			continue
+		}
+		validRules = append(validRules, r)
+	}
+	cfg.Sync = validRules
+
 	// Validate on_sync hooks
 	var validHooks []OnSyncHook
 	for _, h := range cfg.OnSync {
-		if strings.TrimSpace(h.Cmd) == "" {
-			fmt.Fprintf(os.Stderr, "warning: on_sync hook with empty cmd, skipping\n")
+		if err := validateHook(h); err != nil {
+			warn("%v, skipping", err)
 			continue
 		}
 		validHooks = append(validHooks, h)
 	}
 	cfg.OnSync = validHooks
 
-	return &cfg, nil
+	// Validate requires
+	var validReqs []Requirement
+	for _, r := range cfg.Requires {
+		if _, err := r.parse(); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		validReqs = append(validReqs, r)
+	}
+	cfg.Requires = validReqs
+
+	// Validate secret_allow
+	var validAllows []SecretAllow
+	for _, a := range cfg.SecretAllow {
+		if err := validateSecretAllow(a); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		validAllows = append(validAllows, a)
+	}
+	cfg.SecretAllow = validAllows
+
+	// Validate workspace masks
+	var validMasks []string
+	for _, m := range cfg.Workspace.Mask {
+		if err := validateMask(m); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		validMasks = append(validMasks, m)
+	}
+	cfg.Workspace.Mask = validMasks
+	if err := validateWorkspaceSync(cfg.Workspace.Sync); err != nil {
+		warn("%v, ignoring workspace.sync", err)
+		cfg.Workspace.Sync = WorkspaceSyncConfig{}
+	}
+
+	// Validate notifications
+	var validWebhooks []Webhook
+	for _, w := range cfg.Notifications.Webhooks {
+		if err := validateWebhook(w); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		validWebhooks = append(validWebhooks, w)
+	}
+	cfg.Notifications.Webhooks = validWebhooks
+
+	// Validate docker
+	if err := validateDocker(cfg.Docker); err != nil {
+		warn("%v, ignoring it", err)
+		cfg.Docker.Context = ""
+	}
+
+	// Validate image
+	if cfg.Image.Platform != "" {
+		if err := validateImagePlatform(cfg.Image.Platform); err != nil {
+			warn("%v, ignoring it", err)
+			cfg.Image.Platform = ""
+		}
+	}
+	if err := validateImage(ImageConfig{Pull: cfg.Image.Pull}); err != nil {
+		warn("%v, ignoring it", err)
+		cfg.Image.Pull = ""
+	}
+	if err := validateImageVariant(cfg.Image.Variant); err != nil {
+		warn("%v, ignoring it", err)
+		cfg.Image.Variant = ""
+	}
+	var packages []string
+	for _, p := range cfg.Image.Packages {
+		if err := validateImagePackage(p); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		packages = append(packages, p)
+	}
+	cfg.Image.Packages = packages
+	if err := validateToolchains(cfg.Toolchains); err != nil {
+		warn("%v, using the default versions", err)
+		cfg.Toolchains = ToolchainsConfig{}
+	}
+	for name := range cfg.Image.BuildArgs {
+		if err := validateBuildArg(name); err != nil {
+			warn("%v, skipping", err)
+			delete(cfg.Image.BuildArgs, name)
+		}
+	}
+
+	// Validate creds_volume
+	if err := validateCredsVolume(cfg.CredsVolume); err != nil {
+		warn("%v, ignoring", err)
+		cfg.CredsVolume = ""
+	}
+
+	// Validate transfer
+	if err := validateTransfer(cfg.Transfer); err != nil {
+		warn("%v, using defaults", err)
+		cfg.Transfer = TransferConfig{}
+	}
+
+	// Validate commands
+	for name, cc := range cfg.Commands {
+		if err := validateCommand(name, cc); err != nil {
+			warn("%v, ignoring", err)
+			delete(cfg.Commands, name)
+		}
+	}
+
+	// Validate agents
+	seenAgents := make(map[string]bool)
+	var validAgents []Agent
+	for _, a := range cfg.Agents {
+		if err := validateAgent(a); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		if seenAgents[a.Name] {
+			warn("duplicate agent %q, skipping", a.Name)
+			continue
+		}
+		seenAgents[a.Name] = true
+		validAgents = append(validAgents, a)
+	}
+	cfg.Agents = validAgents
+
+	// Validate share
+	if err := validateShare(cfg.Share); err != nil {
+		warn("%v, sharing disabled", err)
+		cfg.Share = ShareConfig{}
+	}
+
+	// Validate vscode extensions
+	var validExts []string
+	for _, id := range cfg.VSCode.Extensions {
+		if err := validateExtensionID(id); err != nil {
+			warn("%v, skipping", err)
+			continue
+		}
+		validExts = append(validExts, id)
+	}
+	cfg.VSCode.Extensions = validExts
+
+	// Validate ssh
+	if err := validateSSH(cfg.SSH); err != nil {
+		warn("%v, letting Docker pick", err)
+		cfg.SSH.Port = 0
+	}
+
+	// Validate security
+	if err := validateSecurity(cfg.Security); err != nil {
+		warn("%v, using the default", err)
+		cfg.Security = SecurityConfig{}
+	}
+
+	// Validate resources
+	if err := validateResources(cfg.Resources); err != nil {
+		warn("%v, not limiting disk", err)
+		cfg.Resources.Disk = ""
+	}
+
+	// Validate git
+	if err := validateGit(cfg.Git); err != nil {
+		warn("%v, ignoring the git section", err)
+		cfg.Git = GitConfig{}
+	}
+
+	// Validate limits
+	if err := validateSessionTimeout(cfg.Limits.SessionTimeout); err != nil {
+		warn("%v, ignoring", err)
+		cfg.Limits.SessionTimeout = ""
+	}
+	if err := validResources(cfg.Limits.SessionMemory, cfg.Limits.SessionCPUs); err != nil {
+		warn("limits: %v, ignoring session ceilings", err)
+		cfg.Limits.SessionMemory, cfg.Limits.SessionCPUs = "", 0
+	}
+	if err := validateOnTimeout(cfg.Limits.OnTimeout); err != nil {
+		warn("%v, using %q", err, TimeoutStop)
+		cfg.Limits.OnTimeout = ""
+	}
+}
+
+// splitOwner splits an owner spec ("user" or "user:group") into its parts.
+// group is empty when only a user is given.
+func splitOwner(owner string) (user, group string, err error) {
+	user, group, hasGroup := strings.Cut(owner, ":")
+	if user == "" || (hasGroup && group == "") || strings.Contains(group, ":") {
+		return "", "", fmt.Errorf("invalid owner %q, want \"user\" or \"user:group\"", owner)
+	}
+	return user, group, nil
 }
 
-func validateFirewallEntry(e FirewallEntry) bool {
+func validateFirewallEntry(e FirewallEntry) error {
 	hasDomain := e.Domain != ""
 	hasCIDR := e.CIDR != ""
-	if hasDomain == hasCIDR {
-		if hasDomain {
-			fmt.Fprintf(os.Stderr, "warning: firewall entry has both domain and cidr, skipping\n")
-		} else {
-			fmt.Fprintf(os.Stderr, "warning: firewall entry has neither domain nor cidr, skipping\n")
+	switch {
+	case hasDomain && hasCIDR:
+		return fmt.Errorf("firewall entry has both domain and cidr")
+	case !hasDomain && !hasCIDR:
+		return fmt.Errorf("firewall entry has neither domain nor cidr")
+	}
+	if hasCIDR {
+		if _, _, err := net.ParseCIDR(e.CIDR); err != nil && net.ParseIP(e.CIDR) == nil {
+			return fmt.Errorf("firewall entry has invalid cidr %q", e.CIDR)
 		}
-		return false
 	}
-	return true
+	for _, port := range e.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("firewall entry for %s%s has invalid port %d", e.Domain, e.CIDR, port)
+		}
+	}
+	return nil
 }
 
-func LoadConfig(wsPath string) (*SandboxConfig, error) {
-	home, err := os.UserHomeDir()
+// syncModeRe matches the modes chmod accepts: octal ("0644") or symbolic
+// ("u+x,go-w").
+var syncModeRe = regexp.MustCompile(`^(0?[0-7]{3,4}|[ugoa]*[-+=][rwxXst]*(,[ugoa]*[-+=][rwxXst]*)*)$`)
+
+func validateSyncRule(r SyncRule) error {
+	if strings.HasPrefix(r.Src, "!") {
+		return fmt.Errorf("src can't be a !pattern; list patterns to skip under exclude")
+	}
+	if r.Mode != "" && !syncModeRe.MatchString(r.Mode) {
+		return fmt.Errorf("invalid mode %q, want e.g. \"0644\"", r.Mode)
+	}
+	if r.Owner != "" {
+		if _, _, err := splitOwner(r.Owner); err != nil {
+			return err
+		}
+	}
+	if r.Dest != "" {
+		return checkSyncDest(expandContainerTilde(r.Dest))
+	}
+	return nil
+}
+
+func validateHostTool(ht HostTool) error {
+	if strings.TrimSpace(ht.Name) == "" {
+		return fmt.Errorf("host_tool with empty name")
+	}
+	if strings.TrimSpace(ht.Cmd) == "" {
+		return fmt.Errorf("host_tool %q with empty cmd", ht.Name)
+	}
+	return nil
+}
+
+func validateKeyProvider(p KeyProvider) error {
+	if strings.TrimSpace(p.Name) == "" || strings.TrimSpace(p.EnvVar) == "" {
+		return fmt.Errorf("key provider %q needs both name and env_var", p.Name)
+	}
+	if p.KeyFile != "" {
+		if err := checkSyncDest(expandContainerTilde(p.KeyFile)); err != nil {
+			return fmt.Errorf("key provider %q key_file: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateHook(h OnSyncHook) error {
+	if strings.TrimSpace(h.Cmd) == "" {
+		return fmt.Errorf("on_sync hook with empty cmd")
+	}
+	if h.Timeout != "" {
+		if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("on_sync hook %q has invalid timeout %q", h.Cmd, h.Timeout)
+		}
+	}
+	if err := validResources(h.Memory, h.CPUs); err != nil {
+		return fmt.Errorf("on_sync hook %q has %v", h.Cmd, err)
+	}
+	switch h.OnFailure {
+	case "", HookFailureFail, HookFailureWarn, HookFailureRetry:
+	default:
+		return fmt.Errorf("on_sync hook %q has invalid on_failure %q", h.Cmd, h.OnFailure)
+	}
+	return nil
+}
+
+func validateCredsVolume(v string) error {
+	if v != "" && v != CredsShared && v != CredsWorkspace && !volumeNameRe.MatchString(v) {
+		return fmt.Errorf("invalid creds_volume %q", v)
+	}
+	return nil
+}
+
+func validateCommand(name string, cc CommandConfig) error {
+	if !slices.Contains(CommandNames, name) {
+		return fmt.Errorf("unknown command %q under commands (want one of %s)", name, strings.Join(CommandNames, ", "))
+	}
+	if name == "hooks" && len(cc.Args) > 0 {
+		return fmt.Errorf("commands.hooks can't set args")
+	}
+	return nil
+}
+
+func validateSSH(s SSHConfig) error {
+	if s.Port < 0 || s.Port > 65535 {
+		return fmt.Errorf("invalid ssh.port %d", s.Port)
+	}
+	return nil
+}
+
+func validateShare(s ShareConfig) error {
+	switch s.Via {
+	case "":
+		if len(s.Ports) > 0 || s.Tunnel != nil {
+			return fmt.Errorf("share needs via: tailscale or via: tunnel")
+		}
+		return nil
+	case ShareTailscale, ShareTunnel:
+	default:
+		return fmt.Errorf("invalid share.via %q, want %q or %q", s.Via, ShareTailscale, ShareTunnel)
+	}
+	if len(s.Ports) == 0 {
+		return fmt.Errorf("share has no ports")
+	}
+	for _, p := range s.Ports {
+		if p < 1 || p > 65535 {
+			return fmt.Errorf("share has invalid port %d", p)
+		}
+	}
+	if s.Via == ShareTunnel {
+		if s.Tunnel == nil || s.Tunnel.Host == "" {
+			return fmt.Errorf("share via tunnel needs tunnel.host")
+		}
+		if p := s.Tunnel.Port; p < 0 || p > 65535 {
+			return fmt.Errorf("share has invalid tunnel.port %d", p)
+		}
+	} else if s.Tunnel != nil {
+		return fmt.Errorf("share.tunnel is only used with via: tunnel")
+	}
+	return nil
+}
+
+func validateSessionTimeout(t string) error {
+	if t == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(t); err != nil || d <= 0 {
+		return fmt.Errorf("invalid limits.session_timeout %q", t)
+	}
+	return nil
+}
+
+func validateOnTimeout(action string) error {
+	switch action {
+	case "", TimeoutStop, TimeoutPause:
+		return nil
+	}
+	return fmt.Errorf("invalid limits.on_timeout %q", action)
+}
+
+// GlobalConfigPath returns the path of the user-level config file.
+func GlobalConfigPath() (string, error) {
+	l, err := ActiveLayout()
 	if err != nil {
-		return nil, fmt.Errorf("get home directory: %w", err)
+		return "", err
 	}
+	return filepath.Join(l.Config, "config.yaml"), nil
+}
 
-	global, err := parseConfigFile(filepath.Join(home, ".sandbox", "config.yaml"))
+// LoadGlobalConfig loads only the user-level config, returning an empty config
+// if it doesn't exist.
+func LoadGlobalConfig() (*SandboxConfig, error) {
+	path, err := GlobalConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfigFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("load global config: %w", err)
 	}
+	if cfg == nil {
+		cfg = &SandboxConfig{}
+	}
+	return cfg, nil
+}
+
+// globalSettings are the global config sections that apply to every
+// sandbox, and so are needed apart from any workspace's config.
+type globalSettings struct {
+	Docker         DockerConfig `yaml:"docker"`
+	Image          ImageConfig  `yaml:"image"`
+	Proxy          ProxyConfig  `yaml:"proxy"`           // for image builds
+	CACertificates []string     `yaml:"ca_certificates"` // for image builds
+	Profiles       map[string]struct {
+		Docker DockerConfig `yaml:"docker"`
+	} `yaml:"profiles"`
+}
 
-	ws, err := parseConfigFile(filepath.Join(wsPath, ".sandbox", "config.yaml"))
+// readGlobalSettings reads the global config's globalSettings, quietly:
+// the file's problems are reported when a command loads it in full.
+func readGlobalSettings() globalSettings {
+	var s globalSettings
+	path, err := GlobalConfigPath()
 	if err != nil {
-		return nil, fmt.Errorf("load workspace config: %w", err)
+		return s
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s
+	}
+	yaml.Unmarshal(data, &s)
+	return s
+}
+
+// WorkspaceConfigPath returns the path of a workspace's config file.
+func WorkspaceConfigPath(wsPath string) string {
+	return filepath.Join(wsPath, ".sandbox", "config.yaml")
+}
+
+func LoadConfig(wsPath string) (*SandboxConfig, error) {
+	layers, err := loadConfigLayers(wsPath)
+	if err != nil {
+		return nil, withKind(KindConfig, err)
+	}
+	cfg := layers.merged()
+	applyEnvFiles(cfg, wsPath)
+	return cfg, nil
+}
+
+// configLayers holds the two config files LoadConfig merges. Either may be
+// nil when its file doesn't exist, but not both.
+type configLayers struct {
+	GlobalPath    string
+	WorkspacePath string
+	Global        *SandboxConfig // with the active profile applied
+	Workspace     *SandboxConfig
+
+	Profile     string   // active profile, or ""
+	ProfileKeys []string // top-level keys the profile replaced
+}
+
+func loadConfigLayers(wsPath string) (configLayers, error) {
+	globalPath, err := GlobalConfigPath()
+	if err != nil {
+		return configLayers{}, err
+	}
+	layers := configLayers{GlobalPath: globalPath, WorkspacePath: WorkspaceConfigPath(wsPath)}
+
+	layers.Global, err = parseConfigFile(globalPath)
+	if err != nil {
+		return configLayers{}, fmt.Errorf("load global config: %w", err)
+	}
+
+	layers.Workspace, err = parseConfigFile(layers.WorkspacePath)
+	if err != nil {
+		return configLayers{}, fmt.Errorf("load workspace config: %w", err)
+	}
+	// Key providers decide where stored credentials are injected, so a
+	// checked-in workspace config must not be able to redirect them.
+	if ws := layers.Workspace; ws != nil && len(ws.KeyProviders) > 0 {
+		Warnf(WarnConfig, "key_providers is only read from the global config, ignoring workspace entries")
+		ws.KeyProviders = nil
+	}
+	if ws := layers.Workspace; ws != nil && ws.Docker != (DockerConfig{}) {
+		Warnf(WarnConfig, "docker is only read from the global config, ignoring the workspace's")
+		ws.Docker = DockerConfig{}
+	}
+	if ws := layers.Workspace; ws != nil && ws.Image.globalOnly().set() {
+		Warnf(WarnConfig, "image is only read from the global config but for image.packages, ignoring the workspace's")
+		ws.Image = ImageConfig{Packages: ws.Image.Packages}
+	}
+	if ws := layers.Workspace; ws != nil {
+		dropWorkspaceSecretRefs("env", ws.Env)
+		for name, cc := range ws.Commands {
+			dropWorkspaceSecretRefs("commands."+name+".env", cc.Env)
+		}
+		for _, a := range ws.Agents {
+			dropWorkspaceSecretRefs("agents."+a.Name+".env", a.Env)
+		}
+	}
+	// Forwarding hands the sandbox the host's gpg-agent and signing key,
+	// which the agent can't be allowed to opt itself into.
+	if ws := layers.Workspace; ws != nil && ws.GPG.Forward {
+		Warnf(WarnConfig, "gpg.forward is only read from the global config, ignoring the workspace's")
+		ws.GPG = GPGConfig{}
+	}
+	// A shared or named credentials volume would mount another project's
+	// login, so a workspace may only ask for its own.
+	if ws := layers.Workspace; ws != nil && ws.CredsVolume != "" && ws.CredsVolume != CredsWorkspace {
+		Warnf(WarnConfig, "creds_volume can only be %q in a workspace config, ignoring %q", CredsWorkspace, ws.CredsVolume)
+		ws.CredsVolume = ""
+	}
+	// sshd needs capabilities beyond the base set, which only the global
+	// config can grant.
+	if ws := layers.Workspace; ws != nil && ws.SSH.Enabled {
+		Warnf(WarnConfig, "ssh.enabled is only read from the global config, ignoring the workspace's")
+		ws.SSH.Enabled = false
+	}
+	// The host's Claude settings and CLAUDE.md are the user's to share.
+	if ws := layers.Workspace; ws != nil && ws.HostClaude {
+		Warnf(WarnConfig, "host_claude is only read from the global config, ignoring the workspace's")
+		ws.HostClaude = false
+	}
+	// Allowing a finding skips the secret scan for it, so the agent
+	// mustn't be able to allow itself the files it wants synced.
+	if ws := layers.Workspace; ws != nil && len(ws.SecretAllow) > 0 {
+		Warnf(WarnConfig, "secret_allow is only read from the global config, ignoring workspace entries")
+		ws.SecretAllow = nil
+	}
+	// Webhook URLs are expanded on the host, so a workspace could have the
+	// host send its variables or secrets anywhere.
+	if ws := layers.Workspace; ws != nil && len(ws.Notifications.Webhooks) > 0 {
+		Warnf(WarnConfig, "notifications.webhooks is only read from the global config, ignoring workspace entries")
+		ws.Notifications.Webhooks = nil
+	}
+	if ws := layers.Workspace; ws != nil && len(ws.Profiles) > 0 {
+		Warnf(WarnConfig, "profiles are only read from the global config, ignoring workspace entries")
+		ws.Profiles = nil
+	}
+
+	if layers.Global == nil && layers.Workspace == nil {
+		return configLayers{}, ErrNoConfig
+	}
+
+	if name := ActiveProfile(); name != "" {
+		var profiles map[string]*SandboxConfig
+		if layers.Global != nil {
+			profiles = layers.Global.Profiles
+		}
+		profile, ok := profiles[name]
+		if !ok {
+			return configLayers{}, fmt.Errorf("unknown profile %q (known: %v)", name, profileNames(profiles))
+		}
+		layers.Profile = name
+		layers.ProfileKeys = applyProfile(layers.Global, profile)
+	}
+	if layers.Global != nil {
+		layers.Global.Profiles = nil
 	}
+	// After the profile, which may change the global seccomp profile and
+	// limits the workspace's are checked against.
+	if ws := layers.Workspace; ws != nil {
+		var global SandboxConfig
+		if layers.Global != nil {
+			global = *layers.Global
+		}
+		ws.Security = workspaceSecurity(ws.Security, global.Security)
+		ws.Limits = workspaceLimits(ws.Limits, global.Limits)
+	}
+	return layers, nil
+}
 
-	if global == nil && ws == nil {
-		return nil, fmt.Errorf("no sandbox config found; run 'sandbox config init' to create one")
+// flagProfile is set by the global --profile flag.
+var flagProfile string
+
+// ActiveProfile returns the profile selected with --profile or, failing that,
+// SANDBOX_PROFILE. "" means none.
+func ActiveProfile() string {
+	if flagProfile != "" {
+		return flagProfile
 	}
+	return os.Getenv("SANDBOX_PROFILE")
+}
 
-	if global == nil {
-		return ws, nil
+func profileNames(profiles map[string]*SandboxConfig) []string {
+	names := make([]string, 0, len(profiles))
+	for n := range profiles {
+		names = append(names, n)
 	}
-	if ws == nil {
-		return global, nil
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile replaces each top-level section of base that profile sets, so
+// a profile can narrow the global config as well as extend it. It returns
+// the YAML keys of the replaced sections.
+func applyProfile(base, profile *SandboxConfig) []string {
+	var keys []string
+	bv, pv := reflect.ValueOf(base).Elem(), reflect.ValueOf(profile).Elem()
+	for i := 0; i < pv.NumField(); i++ {
+		if pv.Field(i).IsZero() {
+			continue
+		}
+		bv.Field(i).Set(pv.Field(i))
+		key, _, _ := strings.Cut(pv.Type().Field(i).Tag.Get("yaml"), ",")
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// merged returns the workspace config layered over the global one.
+func (l configLayers) merged() *SandboxConfig {
+	switch {
+	case l.Global == nil:
+		return l.Workspace
+	case l.Workspace == nil:
+		return l.Global
+	default:
+		return mergeConfig(l.Global, l.Workspace)
 	}
-	return mergeConfig(global, ws), nil
 }
 
 func mergeConfig(base, override *SandboxConfig) *SandboxConfig {
@@ -287,6 +1373,53 @@ func mergeConfig(base, override *SandboxConfig) *SandboxConfig {
 	// Firewall: additive
 	result.Firewall.Allow = append(result.Firewall.Allow, base.Firewall.Allow...)
 	result.Firewall.Allow = append(result.Firewall.Allow, override.Firewall.Allow...)
+	result.Firewall.PrivateNetworks = base.Firewall.PrivateNetworks || override.Firewall.PrivateNetworks
+
+	// Hosts: override replaces base by host name
+	hostMap := make(map[string]HostEntry)
+	var hostOrder []string
+	for _, h := range append(slices.Clone(base.Hosts), override.Hosts...) {
+		if _, exists := hostMap[h.Host]; !exists {
+			hostOrder = append(hostOrder, h.Host)
+		}
+		hostMap[h.Host] = h
+	}
+	for _, host := range hostOrder {
+		result.Hosts = append(result.Hosts, hostMap[host])
+	}
+
+	// DNS: workspace servers and search domains each replace the global ones
+	result.DNS = base.DNS
+	if len(override.DNS.Servers) > 0 {
+		result.DNS.Servers = override.DNS.Servers
+	}
+	if len(override.DNS.Search) > 0 {
+		result.DNS.Search = override.DNS.Search
+	}
+
+	// Proxy: workspace proxies win when set; no_proxy is additive
+	result.Proxy = base.Proxy
+	if override.Proxy.HTTP != "" {
+		result.Proxy.HTTP = override.Proxy.HTTP
+	}
+	if override.Proxy.HTTPS != "" {
+		result.Proxy.HTTPS = override.Proxy.HTTPS
+	}
+	result.Proxy.NoProxy = append(slices.Clone(base.Proxy.NoProxy), override.Proxy.NoProxy...)
+
+	// CACertificates: additive, each file once
+	for _, path := range append(slices.Clone(base.CACertificates), override.CACertificates...) {
+		if !slices.Contains(result.CACertificates, path) {
+			result.CACertificates = append(result.CACertificates, path)
+		}
+	}
+
+	// CacheVolumes: additive, each path once
+	for _, p := range append(slices.Clone(base.CacheVolumes), override.CacheVolumes...) {
+		if !slices.Contains(result.CacheVolumes, p) {
+			result.CacheVolumes = append(result.CacheVolumes, p)
+		}
+	}
 
 	// OnSync: additive (global first, then workspace)
 	result.OnSync = append(result.OnSync, base.OnSync...)
@@ -311,6 +1444,212 @@ func mergeConfig(base, override *SandboxConfig) *SandboxConfig {
 		result.HostTools = append(result.HostTools, toolMap[name])
 	}
 
+	// EnvFiles: additive (global first, then workspace)
+	result.EnvFiles = append(result.EnvFiles, base.EnvFiles...)
+	result.EnvFiles = append(result.EnvFiles, override.EnvFiles...)
+
+	// KeyProviders: global only (LoadConfig drops workspace entries)
+	result.KeyProviders = base.KeyProviders
+
+	// Docker and Image: global only (LoadConfig drops the workspace's)
+	result.Docker = base.Docker
+	result.Image = base.Image
+
+	// Image.Packages: additive; duplicates are dropped
+	result.Image.Packages = nil
+	for _, p := range append(slices.Clone(base.Image.Packages), override.Image.Packages...) {
+		if !slices.Contains(result.Image.Packages, p) {
+			result.Image.Packages = append(result.Image.Packages, p)
+		}
+	}
+
+	// Toolchains: workspace overrides global per field
+	result.Toolchains = base.Toolchains
+	if override.Toolchains.Node != "" {
+		result.Toolchains.Node = override.Toolchains.Node
+	}
+	if override.Toolchains.Go != "" {
+		result.Toolchains.Go = override.Toolchains.Go
+	}
+	if override.Toolchains.Rust != "" {
+		result.Toolchains.Rust = override.Toolchains.Rust
+	}
+	if override.Toolchains.Python != "" {
+		result.Toolchains.Python = override.Toolchains.Python
+	}
+	if override.Toolchains.Ruby != "" {
+		result.Toolchains.Ruby = override.Toolchains.Ruby
+	}
+
+	// Limits: workspace overrides global per field, which LoadConfig only
+	// allows when it tightens them
+	result.Limits = base.Limits
+	if override.Limits.SessionTimeout != "" {
+		result.Limits.SessionTimeout = override.Limits.SessionTimeout
+	}
+	if override.Limits.OnTimeout != "" {
+		result.Limits.OnTimeout = override.Limits.OnTimeout
+	}
+	if override.Limits.SessionMemory != "" {
+		result.Limits.SessionMemory = override.Limits.SessionMemory
+	}
+	if override.Limits.SessionCPUs != 0 {
+		result.Limits.SessionCPUs = override.Limits.SessionCPUs
+	}
+
+	// CredsVolume: workspace overrides global (LoadConfig drops any
+	// workspace value but "workspace")
+	result.CredsVolume = base.CredsVolume
+	if override.CredsVolume != "" {
+		result.CredsVolume = override.CredsVolume
+	}
+
+	// Transfer: workspace overrides global per field
+	result.Transfer = base.Transfer
+	if override.Transfer.LargeFile != "" {
+		result.Transfer.LargeFile = override.Transfer.LargeFile
+	}
+	if override.Transfer.ChunkSize != "" {
+		result.Transfer.ChunkSize = override.Transfer.ChunkSize
+	}
+	if override.Transfer.MaxRate != "" {
+		result.Transfer.MaxRate = override.Transfer.MaxRate
+	}
+
+	// Commands: workspace overrides global per command and field; env
+	// merges per key
+	if len(base.Commands)+len(override.Commands) > 0 {
+		result.Commands = make(map[string]CommandConfig)
+	}
+	for name, cc := range base.Commands {
+		result.Commands[name] = cc
+	}
+	for name, o := range override.Commands {
+		cc := result.Commands[name]
+		if len(o.Args) > 0 {
+			cc.Args = o.Args
+		}
+		if o.Workdir != "" {
+			cc.Workdir = o.Workdir
+		}
+		if len(o.Env) > 0 {
+			env := make(map[string]string, len(cc.Env)+len(o.Env))
+			for k, v := range cc.Env {
+				env[k] = v
+			}
+			for k, v := range o.Env {
+				env[k] = v
+			}
+			cc.Env = env
+		}
+		result.Commands[name] = cc
+	}
+
+	// Agents: override replaces base by name (like host_tools)
+	agentMap := make(map[string]Agent)
+	var agentOrder []string
+	for _, a := range base.Agents {
+		if _, exists := agentMap[a.Name]; !exists {
+			agentOrder = append(agentOrder, a.Name)
+		}
+		agentMap[a.Name] = a
+	}
+	for _, a := range override.Agents {
+		if _, exists := agentMap[a.Name]; !exists {
+			agentOrder = append(agentOrder, a.Name)
+		}
+		agentMap[a.Name] = a
+	}
+	for _, name := range agentOrder {
+		result.Agents = append(result.Agents, agentMap[name])
+	}
+
+	// SSH: enabled by the global config only (LoadConfig drops the
+	// workspace's); a workspace port wins
+	result.SSH.Enabled = base.SSH.Enabled
+	result.SSH.Port = base.SSH.Port
+	if override.SSH.Port != 0 {
+		result.SSH.Port = override.SSH.Port
+	}
+
+	// GPG: global only (LoadConfig drops the workspace's)
+	result.GPG = base.GPG
+
+	// Security: a workspace seccomp profile wins when set, which LoadConfig
+	// only allows when it tightens the global one; the rest is global only
+	result.Security = base.Security
+	if override.Security.Seccomp != "" {
+		result.Security.Seccomp = override.Security.Seccomp
+	}
+
+	// Hardening: on if either config turns it on
+	result.Hardening.ReadonlyRootfs = base.Hardening.ReadonlyRootfs || override.Hardening.ReadonlyRootfs
+
+	// Resources: workspace overrides global per field
+	result.Resources = base.Resources
+	if override.Resources.Disk != "" {
+		result.Resources.Disk = override.Resources.Disk
+	}
+
+	// Git: workspace source, name and email win; credential helpers are additive
+	result.Git = base.Git
+	if override.Git.Source != "" {
+		result.Git.Source = override.Git.Source
+	}
+	if override.Git.Name != "" {
+		result.Git.Name = override.Git.Name
+	}
+	if override.Git.Email != "" {
+		result.Git.Email = override.Git.Email
+	}
+	result.Git.CredentialHelpers = append(append([]string(nil), base.Git.CredentialHelpers...), override.Git.CredentialHelpers...)
+
+	// Share: workspace replaces global as a whole
+	result.Share = base.Share
+	if override.Share.Via != "" {
+		result.Share = override.Share
+	}
+
+	// SecretPatterns: additive
+	result.SecretPatterns = append(result.SecretPatterns, base.SecretPatterns...)
+	result.SecretPatterns = append(result.SecretPatterns, override.SecretPatterns...)
+
+	// SecretAllow: global only (LoadConfig drops the workspace's)
+	result.SecretAllow = append(result.SecretAllow, base.SecretAllow...)
+
+	// Workspace masks: additive
+	result.Workspace.Mask = append(append([]string(nil), base.Workspace.Mask...), override.Workspace.Mask...)
+
+	// Workspace sync: workspace engine and conflicts win when set; ignore is
+	// additive
+	result.Workspace.Sync = base.Workspace.Sync
+	if override.Workspace.Sync.Engine != "" {
+		result.Workspace.Sync.Engine = override.Workspace.Sync.Engine
+	}
+	if override.Workspace.Sync.Conflicts != "" {
+		result.Workspace.Sync.Conflicts = override.Workspace.Sync.Conflicts
+	}
+	result.Workspace.Sync.Ignore = append(slices.Clone(base.Workspace.Sync.Ignore), override.Workspace.Sync.Ignore...)
+
+	// Notification webhooks: global only (LoadConfig drops the workspace's)
+	result.Notifications.Webhooks = slices.Clone(base.Notifications.Webhooks)
+
+	// StrictSecrets: enabled if either config enables it
+	result.StrictSecrets = base.StrictSecrets || override.StrictSecrets
+
+	// VSCode extensions: additive
+	result.VSCode.Extensions = append(append([]string(nil), base.VSCode.Extensions...), override.VSCode.Extensions...)
+
+	// Requires: additive
+	result.Requires = append(result.Requires, base.Requires...)
+	result.Requires = append(result.Requires, override.Requires...)
+
+	// EnvStrict: enabled if either config enables it
+	result.EnvStrict = base.EnvStrict || override.EnvStrict
+
+	// HostClaude: global only (LoadConfig drops the workspace's)
+	result.HostClaude = base.HostClaude
+
 	// HostToolPort: workspace overrides global
 	result.HostToolPort = base.HostToolPort
 	if override.HostToolPort != 0 {
@@ -328,36 +1667,31 @@ func (c *SandboxConfig) EffectiveHostToolPort() int {
 	return DefaultHostToolPort
 }
 
-func generateEnvFile(env map[string]string) []byte {
+// UsesHostToolDaemon reports whether sessions go through the host tool
+// daemon: to run host tools, or to reach the host's gpg-agent.
+func (c *SandboxConfig) UsesHostToolDaemon() bool {
+	return len(c.HostTools) > 0 || c.GPG.Forward
+}
+
+func generateEnvFile(env map[string]string, strict bool) ([]byte, error) {
 	if len(env) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	var b strings.Builder
-	keys := make([]string, 0, len(env))
-	for k := range env {
-		keys = append(keys, k)
+	resolved, keys, err := resolveEnv(env, strict)
+	if err != nil {
+		return nil, err
 	}
-	sort.Strings(keys)
-
+	var b strings.Builder
 	for _, k := range keys {
-		v := env[k]
-		if strings.HasPrefix(v, "$") {
-			hostVar := v[1:]
-			expanded := os.Getenv(hostVar)
-			if expanded == "" {
-				continue
-			}
-			v = expanded
-		}
-		b.WriteString(fmt.Sprintf("export %s=%s\n", k, shellQuote(v)))
+		b.WriteString(fmt.Sprintf("export %s=%s\n", k, shellQuote(resolved[k])))
 	}
 
 	out := b.String()
 	if out == "" {
-		return nil
+		return nil, nil
 	}
-	return []byte(out)
+	return []byte(out), nil
 }
 
 func expandTilde(p string) string {
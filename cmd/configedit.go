@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EditConfigEnv sets and removes keys in the env map of the config file at
+// path, creating the file if needed. The file is edited as a YAML node tree so
+// comments and the order of other settings survive the rewrite.
+func EditConfigEnv(path string, set map[string]string, unset []string) error {
+	doc, err := readConfigNode(path)
+	if err != nil {
+		return err
+	}
+	root := doc.Content[0]
+
+	env := mappingValue(root, "env")
+	if env == nil {
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "env"},
+			&yaml.Node{Kind: yaml.MappingNode})
+		env = root.Content[len(root.Content)-1]
+	}
+	if env.Kind != yaml.MappingNode {
+		// "env:" with no value parses as a null scalar.
+		*env = yaml.Node{Kind: yaml.MappingNode}
+	}
+
+	for _, k := range sortedKeys(set) {
+		if v := mappingValue(env, k); v != nil {
+			v.Kind, v.Tag, v.Value, v.Style = yaml.ScalarNode, "!!str", set[k], 0
+			continue
+		}
+		env.Content = append(env.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: k},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: set[k]})
+	}
+	for _, k := range unset {
+		for i := 0; i < len(env.Content); i += 2 {
+			if env.Content[i].Value == k {
+				env.Content = append(env.Content[:i], env.Content[i+2:]...)
+				break
+			}
+		}
+	}
+	// A flow-style "env: {}" would otherwise render new keys inline.
+	if len(env.Content) > 0 {
+		env.Style = 0
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encode %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// readConfigNode parses a config file into a document node whose single
+// child is a mapping. A missing or empty file yields an empty mapping.
+func readConfigNode(path string) (*yaml.Node, error) {
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: top level is not a mapping", path)
+	}
+	return &doc, nil
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditConfigEnv(t *testing.T) {
+	t.Run("set preserves comments and other keys", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte(`# my config
+env:
+  A: "1"
+firewall:
+  allow:
+    - domain: example.com # keep me
+`), 0644)
+
+		if err := EditConfigEnv(path, map[string]string{"A": "2", "B": "$HOST_B"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		for _, want := range []string{"# my config", "# keep me", "domain: example.com"} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("missing %q after edit:\n%s", want, data)
+			}
+		}
+
+		cfg, err := parseConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Env["A"] != "2" || cfg.Env["B"] != "$HOST_B" {
+			t.Errorf("env = %v, want A=2 B=$HOST_B", cfg.Env)
+		}
+	})
+
+	t.Run("flow-style empty env", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte("env: {}\n"), 0644)
+
+		if err := EditConfigEnv(path, map[string]string{"FOO": "bar"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		if !strings.Contains(string(data), "\n  FOO: bar") {
+			t.Errorf("expected block-style env:\n%s", data)
+		}
+	})
+
+	t.Run("unset removes key", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte("env:\n  A: x\n  B: y\n"), 0644)
+
+		if err := EditConfigEnv(path, nil, []string{"A", "MISSING"}); err != nil {
+			t.Fatal(err)
+		}
+		cfg, _ := parseConfigFile(path)
+		if _, ok := cfg.Env["A"]; ok {
+			t.Error("A should be removed")
+		}
+		if cfg.Env["B"] != "y" {
+			t.Errorf("B = %q, want y", cfg.Env["B"])
+		}
+	})
+
+	t.Run("creates missing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".sandbox", "config.yaml")
+
+		if err := EditConfigEnv(path, map[string]string{"FOO": "bar"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := parseConfigFile(path)
+		if err != nil || cfg == nil {
+			t.Fatalf("parse created file: %v", err)
+		}
+		if cfg.Env["FOO"] != "bar" {
+			t.Errorf("FOO = %q, want bar", cfg.Env["FOO"])
+		}
+	})
+}
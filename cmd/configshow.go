@@ -0,0 +1,331 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Provenance labels for ConfigView.Sources.
+const (
+	SourceGlobal    = "global"
+	SourceWorkspace = "workspace"
+)
+
+// maskedValue replaces secret env values in ConfigView output.
+const maskedValue = "********"
+
+// ConfigView is the effective config for a workspace along with where each
+// value came from.
+type ConfigView struct {
+	GlobalPath     string
+	WorkspacePath  string
+	GlobalFound    bool
+	WorkspaceFound bool
+
+	// Profile is the active profile, or "".
+	Profile string
+
+	// Config is the merged config with secret env values masked.
+	Config *SandboxConfig
+
+	// Sources maps YAML paths such as "env.FOO", "sync[0]" or
+	// "limits.session_timeout" to SourceGlobal, SourceWorkspace,
+	// "profile <name>" or "env file <path>".
+	Sources map[string]string
+}
+
+// ExplainConfig loads and merges the config for wsPath as LoadConfig does,
+// recording the origin of each value.
+func ExplainConfig(wsPath string) (*ConfigView, error) {
+	layers, err := loadConfigLayers(wsPath)
+	if err != nil {
+		return nil, err
+	}
+	cfg := layers.merged()
+	envFrom := applyEnvFiles(cfg, wsPath)
+	view := &ConfigView{
+		GlobalPath:     layers.GlobalPath,
+		WorkspacePath:  layers.WorkspacePath,
+		GlobalFound:    layers.Global != nil,
+		WorkspaceFound: layers.Workspace != nil,
+		Profile:        layers.Profile,
+		Config:         cfg,
+		Sources:        configSources(layers, cfg, envFrom),
+	}
+	maskSecretEnv(cfg)
+	return view, nil
+}
+
+// configSources attributes each value in cfg to a layer, following the same
+// rules as mergeConfig.
+func configSources(l configLayers, cfg *SandboxConfig, envFrom map[string]string) map[string]string {
+	g, w := l.Global, l.Workspace
+	if g == nil {
+		g = &SandboxConfig{}
+	}
+	if w == nil {
+		w = &SandboxConfig{}
+	}
+	src := make(map[string]string)
+	pick := func(fromWorkspace bool) string {
+		if fromWorkspace {
+			return SourceWorkspace
+		}
+		return SourceGlobal
+	}
+	// Additive lists hold the global entries first.
+	additive := func(prefix string, n, fromGlobal int) {
+		for i := 0; i < n; i++ {
+			src[fmt.Sprintf("%s[%d]", prefix, i)] = pick(i >= fromGlobal)
+		}
+	}
+
+	for k := range cfg.Env {
+		if f, ok := envFrom[k]; ok {
+			src["env."+k] = "env file " + f
+			continue
+		}
+		_, inWs := w.Env[k]
+		src["env."+k] = pick(inWs)
+	}
+	for name := range cfg.Commands {
+		_, inWs := w.Commands[name]
+		src["commands."+name] = pick(inWs)
+	}
+	for i, r := range cfg.Sync {
+		inWs := false
+		for _, wr := range w.Sync {
+			inWs = inWs || wr.Dest == r.Dest
+		}
+		src[fmt.Sprintf("sync[%d]", i)] = pick(inWs)
+	}
+	for i, ht := range cfg.HostTools {
+		inWs := false
+		for _, wt := range w.HostTools {
+			inWs = inWs || wt.Name == ht.Name
+		}
+		src[fmt.Sprintf("host_tools[%d]", i)] = pick(inWs)
+	}
+	for i, h := range cfg.Hosts {
+		inWs := false
+		for _, wh := range w.Hosts {
+			inWs = inWs || wh.Host == h.Host
+		}
+		src[fmt.Sprintf("hosts[%d]", i)] = pick(inWs)
+	}
+	for i, a := range cfg.Agents {
+		inWs := false
+		for _, wa := range w.Agents {
+			inWs = inWs || wa.Name == a.Name
+		}
+		src[fmt.Sprintf("agents[%d]", i)] = pick(inWs)
+	}
+	additive("env_files", len(cfg.EnvFiles), len(g.EnvFiles))
+	additive("firewall.allow", len(cfg.Firewall.Allow), len(g.Firewall.Allow))
+	additive("proxy.no_proxy", len(cfg.Proxy.NoProxy), len(g.Proxy.NoProxy))
+	additive("ca_certificates", len(cfg.CACertificates), len(g.CACertificates))
+	additive("cache_volumes", len(cfg.CacheVolumes), len(g.CacheVolumes))
+	additive("on_sync", len(cfg.OnSync), len(g.OnSync))
+	additive("secret_patterns", len(cfg.SecretPatterns), len(g.SecretPatterns))
+	additive("secret_allow", len(cfg.SecretAllow), len(g.SecretAllow))
+	additive("workspace.mask", len(cfg.Workspace.Mask), len(g.Workspace.Mask))
+	additive("workspace.sync.ignore", len(cfg.Workspace.Sync.Ignore), len(g.Workspace.Sync.Ignore))
+	additive("notifications.webhooks", len(cfg.Notifications.Webhooks), len(g.Notifications.Webhooks))
+	additive("requires", len(cfg.Requires), len(g.Requires))
+	additive("vscode.extensions", len(cfg.VSCode.Extensions), len(g.VSCode.Extensions))
+	additive("security.cap_add", len(cfg.Security.CapAdd), len(g.Security.CapAdd))
+	additive("git.credential_helpers", len(cfg.Git.CredentialHelpers), len(g.Git.CredentialHelpers))
+	additive("key_providers", len(cfg.KeyProviders), len(cfg.KeyProviders))
+
+	scalar := func(path string, set, setInWs bool) {
+		if set {
+			src[path] = pick(setInWs)
+		}
+	}
+	scalar("env_strict", cfg.EnvStrict, !g.EnvStrict)
+	scalar("strict_secrets", cfg.StrictSecrets, !g.StrictSecrets)
+	scalar("firewall.private_networks", cfg.Firewall.PrivateNetworks, !g.Firewall.PrivateNetworks)
+	scalar("dns.servers", len(cfg.DNS.Servers) > 0, len(w.DNS.Servers) > 0)
+	scalar("dns.search", len(cfg.DNS.Search) > 0, len(w.DNS.Search) > 0)
+	scalar("proxy.http", cfg.Proxy.HTTP != "", w.Proxy.HTTP != "")
+	scalar("proxy.https", cfg.Proxy.HTTPS != "", w.Proxy.HTTPS != "")
+	scalar("host_claude", cfg.HostClaude, !g.HostClaude)
+	scalar("host_tool_port", cfg.HostToolPort != 0, w.HostToolPort != 0)
+	scalar("creds_volume", cfg.CredsVolume != "", w.CredsVolume != "")
+	scalar("transfer.large_file", cfg.Transfer.LargeFile != "", w.Transfer.LargeFile != "")
+	scalar("transfer.chunk_size", cfg.Transfer.ChunkSize != "", w.Transfer.ChunkSize != "")
+	scalar("transfer.max_rate", cfg.Transfer.MaxRate != "", w.Transfer.MaxRate != "")
+	scalar("workspace.sync.engine", cfg.Workspace.Sync.Engine != "", w.Workspace.Sync.Engine != "")
+	scalar("workspace.sync.conflicts", cfg.Workspace.Sync.Conflicts != "", w.Workspace.Sync.Conflicts != "")
+	scalar("share", cfg.Share.Via != "", w.Share.Via != "")
+	scalar("ssh.enabled", cfg.SSH.Enabled, !g.SSH.Enabled)
+	scalar("ssh.port", cfg.SSH.Port != 0, w.SSH.Port != 0)
+	scalar("gpg.forward", cfg.GPG.Forward, !g.GPG.Forward)
+	scalar("security.seccomp", cfg.Security.Seccomp != "", w.Security.Seccomp != "")
+	scalar("security.apparmor", cfg.Security.AppArmor != "", w.Security.AppArmor != "")
+	scalar("security.new_privileges", cfg.Security.NewPrivileges, !g.Security.NewPrivileges)
+	scalar("hardening.readonly_rootfs", cfg.Hardening.ReadonlyRootfs, !g.Hardening.ReadonlyRootfs)
+	scalar("resources.disk", cfg.Resources.Disk != "", w.Resources.Disk != "")
+	scalar("docker.context", cfg.Docker.Context != "", false)
+	scalar("image.build_args", len(cfg.Image.BuildArgs) > 0, false)
+	scalar("image.platform", cfg.Image.Platform != "", false)
+	scalar("image.pull", cfg.Image.Pull != "", false)
+	scalar("image.variant", cfg.Image.Variant != "", false)
+	additive("image.packages", len(cfg.Image.Packages), len(g.Image.Packages))
+	scalar("image.check_updates", cfg.Image.CheckUpdates, false)
+	scalar("toolchains.node", cfg.Toolchains.Node != "", w.Toolchains.Node != "")
+	scalar("toolchains.go", cfg.Toolchains.Go != "", w.Toolchains.Go != "")
+	scalar("toolchains.rust", cfg.Toolchains.Rust != "", w.Toolchains.Rust != "")
+	scalar("toolchains.python", cfg.Toolchains.Python != "", w.Toolchains.Python != "")
+	scalar("toolchains.ruby", cfg.Toolchains.Ruby != "", w.Toolchains.Ruby != "")
+	scalar("git.source", cfg.Git.Source != "", w.Git.Source != "")
+	scalar("git.name", cfg.Git.Name != "", w.Git.Name != "")
+	scalar("git.email", cfg.Git.Email != "", w.Git.Email != "")
+	scalar("limits.session_timeout", cfg.Limits.SessionTimeout != "", w.Limits.SessionTimeout != "")
+	scalar("limits.on_timeout", cfg.Limits.OnTimeout != "", w.Limits.OnTimeout != "")
+	scalar("limits.session_memory", cfg.Limits.SessionMemory != "", w.Limits.SessionMemory != "")
+	scalar("limits.session_cpus", cfg.Limits.SessionCPUs != 0, w.Limits.SessionCPUs != 0)
+
+	// Global values in sections the profile replaced came from the profile.
+	for _, key := range l.ProfileKeys {
+		for path, s := range src {
+			if s == SourceGlobal && topLevelKey(path) == key {
+				src[path] = "profile " + l.Profile
+			}
+		}
+	}
+	return src
+}
+
+// topLevelKey returns the first element of a source path.
+func topLevelKey(path string) string {
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// secretEnvNameRe matches env names that suggest a credential.
+var secretEnvNameRe = regexp.MustCompile(`(?i)(key|token|secret|passw(or)?d|credential)`)
+
+// maskSecretEnv replaces literal env values that look like credentials.
+// References (op://, vault:, $VAR) are left visible as they hold no secret
+// themselves.
+func maskSecretEnv(cfg *SandboxConfig) {
+	detectors := secretDetectors(cfg)
+	mask := func(env map[string]string) {
+		for k, v := range env {
+			if v == "" || isSecretRef(v) || (strings.HasPrefix(v, "$") && !strings.HasPrefix(v, "$$")) {
+				continue
+			}
+			secret := secretEnvNameRe.MatchString(k)
+			for _, detect := range detectors {
+				secret = secret || detect(k+"="+v) != ""
+			}
+			if secret {
+				env[k] = maskedValue
+			}
+		}
+	}
+	mask(cfg.Env)
+	for _, cc := range cfg.Commands {
+		mask(cc.Env)
+	}
+	for _, a := range cfg.Agents {
+		mask(a.Env)
+	}
+}
+
+// YAML renders the config with a comment after each value naming its source.
+func (v *ConfigView) YAML() ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(v.Config); err != nil {
+		return nil, err
+	}
+	annotateSources(&doc, "", v.Sources)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# global:    %s%s\n", v.GlobalPath, notFound(v.GlobalFound))
+	fmt.Fprintf(&buf, "# workspace: %s%s\n", v.WorkspacePath, notFound(v.WorkspaceFound))
+	if v.Profile != "" {
+		fmt.Fprintf(&buf, "# profile:   %s\n", v.Profile)
+	}
+	enc := yaml.NewEncoder(&buf)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func notFound(found bool) string {
+	if found {
+		return ""
+	}
+	return " (not found)"
+}
+
+// annotateSources walks an encoded config, attaching sources[path] as a line
+// comment to each node whose path has one.
+func annotateSources(n *yaml.Node, path string, sources map[string]string) {
+	if src, ok := sources[path]; ok && path != "" {
+		if n.Kind == yaml.MappingNode && len(n.Content) > 0 {
+			n.Content[0].LineComment = src
+		} else {
+			n.LineComment = src
+		}
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			child := n.Content[i].Value
+			if path != "" {
+				child = path + "." + child
+			}
+			annotateSources(n.Content[i+1], child, sources)
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			annotateSources(item, fmt.Sprintf("%s[%d]", path, i), sources)
+		}
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			annotateSources(c, path, sources)
+		}
+	}
+}
+
+// JSON renders the config, keyed by its YAML field names, alongside the
+// source of each value.
+func (v *ConfigView) JSON() ([]byte, error) {
+	// Round-trip through YAML so the keys match the config file.
+	data, err := yaml.Marshal(v.Config)
+	if err != nil {
+		return nil, err
+	}
+	var config map[string]any
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	out := struct {
+		GlobalConfig    string            `json:"global_config"`
+		WorkspaceConfig string            `json:"workspace_config"`
+		Profile         string            `json:"profile,omitempty"`
+		Config          map[string]any    `json:"config"`
+		Sources         map[string]string `json:"sources"`
+	}{v.GlobalPath, v.WorkspacePath, v.Profile, config, v.Sources}
+	if !v.GlobalFound {
+		out.GlobalConfig = ""
+	}
+	if !v.WorkspaceFound {
+		out.WorkspaceConfig = ""
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainConfig(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte(`env:
+  NODE_ENV: development
+  API_TOKEN: hunter2
+  DB_URL: op://dev/db/url
+firewall:
+  allow:
+    - domain: global.example.com
+limits:
+  session_timeout: 8h
+`), 0644)
+
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte(`env:
+  NODE_ENV: test
+env_files: [.env]
+firewall:
+  allow:
+    - domain: ws.example.com
+limits:
+  session_memory: 2G
+`), 0644)
+	os.WriteFile(filepath.Join(ws, ".env"), []byte("FROM_FILE=1\n"), 0644)
+
+	view, err := ExplainConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("sources", func(t *testing.T) {
+		want := map[string]string{
+			"env.NODE_ENV":           SourceWorkspace,
+			"env.API_TOKEN":          SourceGlobal,
+			"env.FROM_FILE":          "env file .env",
+			"env_files[0]":           SourceWorkspace,
+			"firewall.allow[0]":      SourceGlobal,
+			"firewall.allow[1]":      SourceWorkspace,
+			"limits.session_timeout": SourceGlobal,
+			"limits.session_memory":  SourceWorkspace,
+		}
+		for path, src := range want {
+			if got := view.Sources[path]; got != src {
+				t.Errorf("Sources[%q] = %q, want %q", path, got, src)
+			}
+		}
+	})
+
+	t.Run("secrets masked", func(t *testing.T) {
+		if got := view.Config.Env["API_TOKEN"]; got != maskedValue {
+			t.Errorf("API_TOKEN = %q, want it masked", got)
+		}
+		if got := view.Config.Env["DB_URL"]; got != "op://dev/db/url" {
+			t.Errorf("DB_URL = %q, references should be shown as written", got)
+		}
+		if got := view.Config.Env["NODE_ENV"]; got != "test" {
+			t.Errorf("NODE_ENV = %q, want %q", got, "test")
+		}
+	})
+
+	t.Run("yaml annotations", func(t *testing.T) {
+		out, err := view.YAML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"NODE_ENV: test # workspace", "domain: global.example.com # global", "FROM_FILE: \"1\" # env file .env"} {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("output missing %q:\n%s", want, out)
+			}
+		}
+		if strings.Contains(string(out), "hunter2") {
+			t.Errorf("output leaks a secret:\n%s", out)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out, err := view.JSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Config  map[string]any    `json:"config"`
+			Sources map[string]string `json:"sources"`
+		}
+		if err := json.Unmarshal(out, &got); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := got.Config["env"]; !ok {
+			t.Errorf("config should use YAML keys, got %v", got.Config)
+		}
+		if got.Sources["env.NODE_ENV"] != SourceWorkspace {
+			t.Errorf("sources = %v", got.Sources)
+		}
+	})
+}
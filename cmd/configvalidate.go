@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError is a problem found by ValidateConfig. Line is 0 when the
+// problem can't be tied to a line.
+type ConfigError struct {
+	Line int
+	Msg  string
+}
+
+func (e ConfigError) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// ValidateConfig strictly checks config data, reporting every problem rather
+// than skipping invalid entries as loading does: malformed YAML, unknown keys,
+// type mismatches, and entries that loading would drop or ignore. workspace
+// marks a workspace config, in which key_providers are not honoured. Errors
+// are sorted by line.
+func ValidateConfig(data []byte, workspace bool) []ConfigError {
+	var errs []ConfigError
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var cfg SandboxConfig
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			// Syntax errors stop the decoder, so there is nothing more to check.
+			return []ConfigError{yamlError(err.Error())}
+		}
+		for _, msg := range typeErr.Errors {
+			errs = append(errs, yamlError(msg))
+		}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []ConfigError{yamlError(err.Error())}
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		if len(doc.Content) > 0 {
+			errs = append(errs, ConfigError{Line: doc.Content[0].Line, Msg: "top level is not a mapping"})
+		}
+		return errs
+	}
+
+	add := func(n *yaml.Node, err error) {
+		if err != nil {
+			errs = append(errs, ConfigError{Line: n.Line, Msg: err.Error()})
+		}
+	}
+	scope := globalScope
+	if workspace {
+		scope = workspaceScope
+	}
+	validateSections(doc.Content[0], scope, add)
+
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+	return errs
+}
+
+// Where a config mapping being validated appears.
+const (
+	globalScope = iota
+	workspaceScope
+	profileScope
+)
+
+// validateSections checks each top-level section of a config mapping,
+// reporting problems through add.
+func validateSections(root *yaml.Node, scope int, add func(*yaml.Node, error)) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "firewall":
+			if allow := mappingValue(val, "allow"); allow != nil {
+				eachItem(allow, func(item *yaml.Node, e FirewallEntry) { add(item, validateFirewallEntry(e)) })
+			}
+		case "hosts":
+			seen := make(map[string]bool)
+			eachItem(val, func(item *yaml.Node, h HostEntry) {
+				add(item, validateHostEntry(h))
+				if h.Host != "" && seen[h.Host] {
+					add(item, fmt.Errorf("duplicate hosts entry %q", h.Host))
+				}
+				seen[h.Host] = true
+			})
+		case "dns":
+			var d DNSConfig
+			if val.Decode(&d) == nil {
+				add(val, validateDNS(d))
+			}
+		case "proxy":
+			var p ProxyConfig
+			if val.Decode(&p) == nil {
+				add(val, validateProxy(p))
+			}
+		case "ca_certificates":
+			eachItem(val, func(item *yaml.Node, path string) { add(item, validateCACertificate(path)) })
+		case "cache_volumes":
+			eachItem(val, func(item *yaml.Node, path string) { add(item, validateCacheVolume(path)) })
+		case "sync":
+			eachItem(val, func(item *yaml.Node, r SyncRule) {
+				if err := validateSyncRule(r); err != nil {
+					add(item, fmt.Errorf("sync rule for %s: %w", r.Src, err))
+				}
+			})
+		case "host_tools":
+			seen := make(map[string]bool)
+			eachItem(val, func(item *yaml.Node, ht HostTool) {
+				add(item, validateHostTool(ht))
+				if ht.Name != "" && seen[ht.Name] {
+					add(item, fmt.Errorf("duplicate host_tool %q", ht.Name))
+				}
+				seen[ht.Name] = true
+			})
+		case "host_tool_port":
+			var port int
+			if val.Decode(&port) == nil && (port < 1 || port > 65535) {
+				add(val, fmt.Errorf("invalid host_tool_port %d", port))
+			}
+		case "key_providers":
+			switch scope {
+			case workspaceScope:
+				add(key, fmt.Errorf("key_providers is only read from the global config"))
+			case profileScope:
+				add(key, fmt.Errorf("key_providers can't be set in a profile"))
+			}
+			eachItem(val, func(item *yaml.Node, p KeyProvider) { add(item, validateKeyProvider(p)) })
+		case "on_sync":
+			eachItem(val, func(item *yaml.Node, h OnSyncHook) { add(item, validateHook(h)) })
+		case "creds_volume":
+			add(val, validateCredsVolume(val.Value))
+			if scope == workspaceScope && val.Value != "" && val.Value != CredsWorkspace {
+				add(val, fmt.Errorf("creds_volume can only be %q in a workspace config", CredsWorkspace))
+			}
+		case "transfer":
+			var tc TransferConfig
+			if val.Decode(&tc) == nil {
+				add(val, validateTransfer(tc))
+			}
+		case "commands":
+			if val.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(val.Content); j += 2 {
+				var cc CommandConfig
+				if val.Content[j+1].Decode(&cc) == nil {
+					add(val.Content[j], validateCommand(val.Content[j].Value, cc))
+				}
+			}
+		case "agents":
+			seen := make(map[string]bool)
+			eachItem(val, func(item *yaml.Node, a Agent) {
+				add(item, validateAgent(a))
+				if a.Name != "" && seen[a.Name] {
+					add(item, fmt.Errorf("duplicate agent %q", a.Name))
+				}
+				seen[a.Name] = true
+			})
+		case "vscode":
+			var v VSCodeConfig
+			if val.Decode(&v) == nil {
+				for _, id := range v.Extensions {
+					add(val, validateExtensionID(id))
+				}
+			}
+		case "security":
+			var s SecurityConfig
+			if val.Decode(&s) == nil {
+				add(val, validateSecurity(s))
+			}
+		case "notifications":
+			if scope == workspaceScope {
+				add(key, fmt.Errorf("notifications is only read from the global config"))
+			}
+			var n NotificationsConfig
+			if val.Decode(&n) == nil {
+				for _, w := range n.Webhooks {
+					add(val, validateWebhook(w))
+				}
+			}
+		case "workspace":
+			var w WorkspaceConfig
+			if val.Decode(&w) == nil {
+				for _, m := range w.Mask {
+					add(val, validateMask(m))
+				}
+				add(val, validateWorkspaceSync(w.Sync))
+			}
+		case "resources":
+			var r ResourcesConfig
+			if val.Decode(&r) == nil {
+				add(val, validateResources(r))
+			}
+		case "git":
+			var g GitConfig
+			if val.Decode(&g) == nil {
+				add(val, validateGit(g))
+			}
+		case "ssh":
+			var s SSHConfig
+			if val.Decode(&s) == nil {
+				add(val, validateSSH(s))
+			}
+		case "share":
+			var sh ShareConfig
+			if val.Decode(&sh) == nil {
+				add(val, validateShare(sh))
+			}
+		case "limits":
+			var l LimitsConfig
+			if val.Decode(&l) != nil {
+				continue
+			}
+			at := func(field string) *yaml.Node {
+				if n := mappingValue(val, field); n != nil {
+					return n
+				}
+				return val
+			}
+			add(at("session_timeout"), validateSessionTimeout(l.SessionTimeout))
+			add(at("on_timeout"), validateOnTimeout(l.OnTimeout))
+			if err := validResources(l.SessionMemory, 0); err != nil {
+				add(at("session_memory"), fmt.Errorf("limits: %w", err))
+			}
+			if err := validResources("", l.SessionCPUs); err != nil {
+				add(at("session_cpus"), fmt.Errorf("limits: %w", err))
+			}
+		case "requires":
+			eachItem(val, func(item *yaml.Node, r Requirement) {
+				_, err := r.parse()
+				add(item, err)
+			})
+		case "docker":
+			if scope == workspaceScope {
+				add(key, fmt.Errorf("docker is only read from the global config"))
+			}
+			var d DockerConfig
+			if val.Decode(&d) == nil {
+				add(val, validateDocker(d))
+			}
+		case "image":
+			switch scope {
+			case workspaceScope:
+				if val.Kind == yaml.MappingNode {
+					for j := 0; j+1 < len(val.Content); j += 2 {
+						if k := val.Content[j]; k.Value != "packages" {
+							add(k, fmt.Errorf("image.%s is only read from the global config", k.Value))
+						}
+					}
+				}
+			case profileScope:
+				add(key, fmt.Errorf("image can't be set in a profile"))
+			}
+			var c ImageConfig
+			if val.Decode(&c) == nil {
+				add(val, validateImage(c))
+			}
+		case "toolchains":
+			var tc ToolchainsConfig
+			if val.Decode(&tc) == nil {
+				add(val, validateToolchains(tc))
+			}
+		case "profiles":
+			switch scope {
+			case workspaceScope:
+				add(key, fmt.Errorf("profiles are only read from the global config"))
+			case profileScope:
+				add(key, fmt.Errorf("profiles can't be set in a profile"))
+			}
+			if val.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(val.Content); j += 2 {
+				if p := val.Content[j+1]; p.Kind == yaml.MappingNode {
+					validateSections(p, profileScope, func(n *yaml.Node, err error) {
+						if err != nil {
+							add(n, fmt.Errorf("profile %q: %w", val.Content[j].Value, err))
+						}
+					})
+				}
+			}
+		case "secret_patterns":
+			eachItem(val, func(item *yaml.Node, p SecretPattern) {
+				if _, err := regexp.Compile(p.Regex); err != nil {
+					add(item, fmt.Errorf("secret pattern %q: %w", p.Name, err))
+				}
+			})
+		case "secret_allow":
+			if scope == workspaceScope {
+				add(key, fmt.Errorf("secret_allow is only read from the global config"))
+			}
+			eachItem(val, func(item *yaml.Node, a SecretAllow) {
+				add(item, validateSecretAllow(a))
+			})
+		}
+	}
+
+}
+
+// eachItem decodes each item of a sequence node into T and calls fn with it.
+// Items that don't decode are skipped, as the strict decode reports them.
+func eachItem[T any](seq *yaml.Node, fn func(item *yaml.Node, v T)) {
+	if seq.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, item := range seq.Content {
+		var v T
+		if item.Decode(&v) == nil {
+			fn(item, v)
+		}
+	}
+}
+
+// yamlLineRe extracts the line number from yaml.v3 error messages.
+var yamlLineRe = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// yamlTypeRe matches the Go type names yaml.v3 appends to unknown field
+// errors, which mean nothing to someone editing the file.
+var yamlTypeRe = regexp.MustCompile(` in type [\w.]+$`)
+
+// yamlError converts a yaml.v3 error message into a ConfigError.
+func yamlError(msg string) ConfigError {
+	msg = yamlTypeRe.ReplaceAllString(msg, "")
+	if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return ConfigError{Line: line, Msg: msg[len(m[0]):]}
+	}
+	return ConfigError{Msg: msg}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("defaults are clean", func(t *testing.T) {
+		if errs := ValidateConfig([]byte(DefaultConfigYAML), false); len(errs) != 0 {
+			t.Errorf("default config: %v", errs)
+		}
+		if errs := ValidateConfig([]byte(DefaultWorkspaceConfigYAML), true); len(errs) != 0 {
+			t.Errorf("default workspace config: %v", errs)
+		}
+		if errs := ValidateConfig(nil, false); len(errs) != 0 {
+			t.Errorf("empty config: %v", errs)
+		}
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		errs := ValidateConfig([]byte("env:\n  A: [oops\n"), false)
+		if len(errs) != 1 || errs[0].Line == 0 {
+			t.Errorf("errs = %v, want one error with a line", errs)
+		}
+	})
+
+	tests := []struct {
+		name, yaml string
+		line       int
+		want       string
+	}{
+		{"unknown key", "env: {}\nfirwall:\n  allow: []\n", 2, "field firwall not found"},
+		{"type mismatch", "host_tool_port: lots\n", 1, "cannot unmarshal"},
+		{"invalid cidr", "firewall:\n  allow:\n    - domain: a.com\n    - cidr: 10.0.0.0/99\n", 4, "invalid cidr"},
+		{"invalid port", "firewall:\n  allow:\n    - domain: a.com\n      ports: [0]\n", 3, "invalid port 0"},
+		{"invalid hosts ip", "hosts:\n  - host: git.corp\n    ip: 10.0.0.300\n", 2, "invalid ip"},
+		{"duplicate hosts", "hosts:\n  - host: git.corp\n    ip: 10.0.0.3\n  - host: git.corp\n    ip: 10.0.0.4\n", 4, "duplicate hosts entry"},
+		{"invalid dns server", "dns:\n  servers: [dns.corp]\n", 2, "invalid dns server"},
+		{"invalid proxy", "proxy:\n  http: proxy.corp:3128\n", 2, "invalid proxy URL"},
+		{"relative ca certificate", "ca_certificates:\n  - corp-ca.pem\n", 2, "absolute path"},
+		{"cache volume outside workspace", "cache_volumes:\n  - ../target\n", 2, "cache_volumes entry"},
+		{"unknown sync engine", "workspace:\n  sync:\n    engine: rsync\n", 2, "workspace.sync.engine"},
+		{"invalid mode", "sync:\n  - src: a\n    dest: b\n    mode: rw\n", 2, "invalid mode"},
+		{"invalid owner", "sync:\n  - src: a\n    dest: b\n    owner: 'a:'\n", 2, "invalid owner"},
+		{"negated src", "sync:\n  - src: '!*.lock'\n    dest: b\n", 2, "list patterns to skip under exclude"},
+		{"protected dest", "sync:\n  - src: a\n    dest: /opt/init-firewall.sh\n", 2, "sandbox manages it"},
+		{"invalid limit", "limits:\n  session_timeout: 8h\n  on_timeout: explode\n", 3, "on_timeout"},
+		{"duplicate host tool", "host_tools:\n  - {name: a, cmd: x}\n  - {name: a, cmd: y}\n", 3, "duplicate"},
+		{"bad requirement", "requires:\n  - git\n  - docker>>24\n", 3, "invalid requirement"},
+		{"bad secret pattern", "secret_patterns:\n  - {name: x, regex: '('}\n", 2, "secret pattern"},
+		{"bad profile entry", "profiles:\n  work:\n    firewall:\n      allow:\n        - cidr: nope\n", 5, `profile "work": firewall entry has invalid cidr`},
+		{"nested profile", "profiles:\n  work:\n    profiles: {}\n", 3, "can't be set in a profile"},
+		{"share without host", "share:\n  via: tunnel\n  ports: [3000]\n", 2, "needs tunnel.host"},
+		{"unknown command", "commands:\n  bash:\n    args: [-l]\n", 2, "unknown command \"bash\""},
+		{"bad transfer size", "transfer:\n  max_rate: fast\n", 2, "transfer.max_rate"},
+		{"agent without command", "agents:\n  - {name: a, command: x}\n  - name: b\n", 3, "empty command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateConfig([]byte(tt.yaml), false)
+			if len(errs) != 1 {
+				t.Fatalf("errs = %v, want one", errs)
+			}
+			if errs[0].Line != tt.line || !strings.Contains(errs[0].Msg, tt.want) {
+				t.Errorf("got %v, want line %d containing %q", errs[0], tt.line, tt.want)
+			}
+		})
+	}
+
+	t.Run("key_providers in workspace", func(t *testing.T) {
+		data := []byte("key_providers:\n  - {name: x, env_var: X}\n")
+		if errs := ValidateConfig(data, false); len(errs) != 0 {
+			t.Errorf("global: %v", errs)
+		}
+		if errs := ValidateConfig(data, true); len(errs) != 1 {
+			t.Errorf("workspace: errs = %v, want one", errs)
+		}
+	})
+	t.Run("creds_volume in workspace", func(t *testing.T) {
+		if errs := ValidateConfig([]byte("creds_volume: workspace\n"), true); len(errs) != 0 {
+			t.Errorf("workspace value: %v", errs)
+		}
+		data := []byte("creds_volume: shared\n")
+		if errs := ValidateConfig(data, false); len(errs) != 0 {
+			t.Errorf("global: %v", errs)
+		}
+		if errs := ValidateConfig(data, true); len(errs) != 1 {
+			t.Errorf("workspace: errs = %v, want one", errs)
+		}
+	})
+	t.Run("image in workspace", func(t *testing.T) {
+		data := []byte("image:\n  packages: [imagemagick]\n  variant: minimal\n")
+		errs := ValidateConfig(data, true)
+		if len(errs) != 1 || errs[0].Line != 3 || !strings.Contains(errs[0].Msg, "image.variant") {
+			t.Errorf("workspace: errs = %v, want one about image.variant", errs)
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// DataLayout is a set of directories holding sandbox's per-user files.
+// Config, Home and Cache may be the same directory.
+type DataLayout struct {
+	Name   string
+	Config string // config.yaml, keys.json and locales/
+	Home   string // files synced into the container's home directory
+	Cache  string // the host tool daemon's pid and log files
+}
+
+// Names of the supported layouts.
+const (
+	LayoutSandbox = "sandbox" // everything under ~/.sandbox
+	LayoutXDG     = "xdg"     // split across the XDG base directories
+)
+
+// defaultLayout is used when no layout has files yet. Packagers can change
+// it at build time, e.g. for Homebrew:
+//
+//	go build -ldflags "-X github.com/franklin-ross/sandbox/cmd.defaultLayout=xdg"
+var defaultLayout = LayoutSandbox
+
+// DataLayouts returns every supported layout, in the order they are
+// searched for existing files.
+func DataLayouts() ([]DataLayout, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("get home directory: %w", err)
+	}
+	xdg := func(env, fallback string) string {
+		if dir := os.Getenv(env); filepath.IsAbs(dir) {
+			return filepath.Join(dir, "sandbox")
+		}
+		return filepath.Join(home, fallback, "sandbox")
+	}
+	dotSandbox := filepath.Join(home, ".sandbox")
+	return []DataLayout{
+		{Name: LayoutSandbox, Config: dotSandbox, Home: filepath.Join(dotSandbox, "home"), Cache: dotSandbox},
+		{Name: LayoutXDG,
+			Config: xdg("XDG_CONFIG_HOME", ".config"),
+			Home:   filepath.Join(xdg("XDG_DATA_HOME", filepath.Join(".local", "share")), "home"),
+			Cache:  xdg("XDG_CACHE_HOME", ".cache"),
+		},
+	}, nil
+}
+
+// LookupLayout returns the layout called name.
+func LookupLayout(name string) (DataLayout, error) {
+	layouts, err := DataLayouts()
+	if err != nil {
+		return DataLayout{}, err
+	}
+	var names []string
+	for _, l := range layouts {
+		if l.Name == name {
+			return l, nil
+		}
+		names = append(names, l.Name)
+	}
+	return DataLayout{}, fmt.Errorf("unknown layout %q (known: %s)", name, strings.Join(names, ", "))
+}
+
+// ActiveLayout returns the first layout with a config or home directory,
+// falling back to defaultLayout when there are none yet.
+func ActiveLayout() (DataLayout, error) {
+	layouts, err := DataLayouts()
+	if err != nil {
+		return DataLayout{}, err
+	}
+	for _, l := range layouts {
+		if l.InUse() {
+			return l, nil
+		}
+	}
+	return LookupLayout(defaultLayout)
+}
+
+// InUse reports whether any of the layout's files exist.
+func (l DataLayout) InUse() bool {
+	for _, item := range l.items() {
+		if _, err := os.Lstat(item.path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// dataItem is a file or directory a layout places somewhere.
+type dataItem struct {
+	name string
+	path string
+}
+
+// items lists the layout's files and directories. Every layout has the same
+// items in the same order.
+func (l DataLayout) items() []dataItem {
+	return []dataItem{
+		{"config", filepath.Join(l.Config, "config.yaml")},
+		{"key metadata", filepath.Join(l.Config, "keys.json")},
+		{"locales", filepath.Join(l.Config, "locales")},
+		{"plugins", filepath.Join(l.Config, "plugins")},
+		{"home", l.Home},
+		{"daemon", filepath.Join(l.Cache, "daemon")},
+		{"logs", filepath.Join(l.Cache, "logs")},
+	}
+}
+
+// HomeFilesDir returns the directory whose contents are synced into the
+// container's home directory.
+func HomeFilesDir() (string, error) {
+	l, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	return l.Home, nil
+}
+
+// MigrateData moves sandbox's files from one layout to another, rewriting
+// references to the old directories in the moved config. Nothing is moved if
+// any destination already exists. With dryRun it only reports what it would
+// do.
+func MigrateData(from, to DataLayout, dryRun bool, out io.Writer) error {
+	if from.Name == to.Name {
+		return fmt.Errorf("already using the %s layout", to.Name)
+	}
+	if pid := daemonPid(filepath.Join(from.Cache, "daemon", "daemon.pid")); pid != 0 {
+		return fmt.Errorf("the host tool daemon (pid %d) is running; end sandbox sessions first", pid)
+	}
+
+	src, dst := from.items(), to.items()
+	var moves []int
+	for i := range src {
+		if _, err := os.Lstat(src[i].path); err != nil {
+			continue
+		}
+		if _, err := os.Lstat(dst[i].path); err == nil {
+			return fmt.Errorf("%s already exists; move it aside first", dst[i].path)
+		}
+		moves = append(moves, i)
+	}
+	if len(moves) == 0 {
+		fmt.Fprintf(out, "Nothing to migrate from %s\n", from.Name)
+		return nil
+	}
+
+	verb := "Moved"
+	if dryRun {
+		verb = "Would move"
+	}
+	for _, i := range moves {
+		if !dryRun {
+			if err := movePath(src[i].path, dst[i].path); err != nil {
+				return fmt.Errorf("move %s: %w", src[i].name, err)
+			}
+		}
+		fmt.Fprintf(out, "%s %s: %s -> %s\n", verb, src[i].name, src[i].path, dst[i].path)
+	}
+	if dryRun {
+		return nil
+	}
+
+	cfgPath := dst[0].path
+	var renamed [][2]string
+	for _, i := range moves {
+		renamed = append(renamed, [2]string{src[i].path, dst[i].path})
+	}
+	if changed, err := rewriteDataPaths(cfgPath, renamed); err != nil {
+		return fmt.Errorf("update config paths: %w", err)
+	} else if changed {
+		fmt.Fprintf(out, "Updated paths in %s\n", cfgPath)
+	}
+
+	// Remove the old directories if the move emptied them. os.Remove leaves
+	// anything else the user kept there.
+	os.Remove(filepath.Join(from.Config, legacyKeepMarker))
+	for _, dir := range []string{from.Home, filepath.Dir(from.Home), from.Config, from.Cache} {
+		if os.Remove(dir) == nil {
+			fmt.Fprintf(out, "Removed empty %s\n", dir)
+		}
+	}
+	return nil
+}
+
+// rewriteDataPaths replaces references to moved paths in the config at
+// path, in both absolute and ~/ forms. It reports whether anything changed.
+func rewriteDataPaths(path string, renamed [][2]string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false, err
+	}
+	tilde := func(p string) string {
+		if rel, err := filepath.Rel(home, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return "~/" + filepath.ToSlash(rel)
+		}
+		return p
+	}
+	var pairs []string
+	for _, r := range renamed {
+		pairs = append(pairs, r[0], r[1], tilde(r[0]), tilde(r[1]))
+	}
+	updated := strings.NewReplacer(pairs...).Replace(string(data))
+	if updated == string(data) {
+		return false, nil
+	}
+	return true, os.WriteFile(path, []byte(updated), 0644)
+}
+
+// movePath renames src to dst, creating dst's parent. Across filesystems it
+// copies and then removes src.
+func movePath(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	err := os.Rename(src, dst)
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) || !errors.Is(linkErr.Err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies a file or directory, preserving modes and symlinks.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// daemonPid returns the pid recorded in a host tool daemon pid file if that
+// process is still alive, or 0.
+func daemonPid(pidFile string) int {
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil || proc.Signal(syscall.Signal(0)) != nil {
+		return 0
+	}
+	return pid
+}
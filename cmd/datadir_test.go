@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestActiveLayout(t *testing.T) {
+	setup := func(t *testing.T) string {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("XDG_DATA_HOME", "")
+		t.Setenv("XDG_CACHE_HOME", "")
+		return home
+	}
+
+	t.Run("defaults to sandbox", func(t *testing.T) {
+		home := setup(t)
+		l, err := ActiveLayout()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Name != LayoutSandbox || l.Home != filepath.Join(home, ".sandbox", "home") {
+			t.Errorf("layout = %+v", l)
+		}
+	})
+
+	t.Run("detects sandbox before xdg", func(t *testing.T) {
+		home := setup(t)
+		os.MkdirAll(filepath.Join(home, ".sandbox", "home"), 0755)
+		os.MkdirAll(filepath.Join(home, ".config", "sandbox"), 0755)
+		os.WriteFile(filepath.Join(home, ".config", "sandbox", "config.yaml"), nil, 0644)
+		l, _ := ActiveLayout()
+		if l.Name != LayoutSandbox {
+			t.Errorf("layout = %s, want sandbox", l.Name)
+		}
+		path, _ := GlobalConfigPath()
+		if want := filepath.Join(home, ".sandbox", "config.yaml"); path != want {
+			t.Errorf("GlobalConfigPath() = %s, want %s", path, want)
+		}
+	})
+
+	t.Run("detects xdg with env overrides", func(t *testing.T) {
+		setup(t)
+		cfgHome := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", cfgHome)
+		os.MkdirAll(filepath.Join(cfgHome, "sandbox"), 0755)
+		os.WriteFile(filepath.Join(cfgHome, "sandbox", "config.yaml"), nil, 0644)
+		l, _ := ActiveLayout()
+		if l.Name != LayoutXDG || l.Config != filepath.Join(cfgHome, "sandbox") {
+			t.Errorf("layout = %+v", l)
+		}
+	})
+
+	t.Run("unknown layout", func(t *testing.T) {
+		setup(t)
+		if _, err := LookupLayout("opt"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestMigrateData(t *testing.T) {
+	setup := func(t *testing.T) (home string, from, to DataLayout) {
+		home = t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("XDG_DATA_HOME", "")
+		t.Setenv("XDG_CACHE_HOME", "")
+		from, _ = LookupLayout(LayoutSandbox)
+		to, _ = LookupLayout(LayoutXDG)
+		os.MkdirAll(filepath.Join(from.Home, ".ssh"), 0755)
+		os.WriteFile(filepath.Join(from.Home, ".ssh", "config"), []byte("Host *\n"), 0600)
+		os.WriteFile(filepath.Join(from.Config, "config.yaml"), []byte(`
+sync:
+  - src: ~/.sandbox/home/.gitconfig
+    dest: /home/agent/.gitconfig
+  - src: `+filepath.Join(from.Home, "notes")+`
+    dest: /home/agent/notes
+`), 0644)
+		return home, from, to
+	}
+
+	t.Run("moves files and rewrites paths", func(t *testing.T) {
+		home, from, to := setup(t)
+		var out bytes.Buffer
+		if err := MigrateData(from, to, false, &out); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(to.Home, ".ssh", "config")); err != nil {
+			t.Errorf("home files not moved: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(to.Config, "config.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "src: ~/.local/share/sandbox/home/.gitconfig") ||
+			!strings.Contains(string(data), filepath.Join(to.Home, "notes")) {
+			t.Errorf("config paths not rewritten:\n%s", data)
+		}
+		if _, err := os.Stat(filepath.Join(home, ".sandbox")); !os.IsNotExist(err) {
+			t.Errorf("old directory left behind: %v\n%s", err, out.String())
+		}
+		if l, _ := ActiveLayout(); l.Name != LayoutXDG {
+			t.Errorf("active layout = %s after migration", l.Name)
+		}
+	})
+
+	t.Run("dry run moves nothing", func(t *testing.T) {
+		_, from, to := setup(t)
+		var out bytes.Buffer
+		if err := MigrateData(from, to, true, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), "Would move home") {
+			t.Errorf("output = %q", out.String())
+		}
+		if to.InUse() {
+			t.Error("dry run created files")
+		}
+	})
+
+	t.Run("refuses to overwrite", func(t *testing.T) {
+		_, from, to := setup(t)
+		os.MkdirAll(to.Home, 0755)
+		err := MigrateData(from, to, false, &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("err = %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(from.Config, "config.yaml")); err != nil {
+			t.Error("source moved despite the conflict")
+		}
+	})
+}
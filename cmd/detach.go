@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// detachedSocketPrefix names the tmux sockets of detached sessions in the
+// container's /tmp. Each session gets its own tmux server, started by the
+// exec that detaches it, so the session inherits that exec's environment.
+const detachedSocketPrefix = "/tmp/sandbox-tmux-"
+
+// detachedScript runs the session's command, then unregisters its host
+// tool session from the daemon, which the detaching command couldn't wait
+// around to do.
+const detachedScript = `"$@"
+status=$?
+if [ -n "$SANDBOX_SESSION" ]; then
+	printf '{"type":"unregister","session":"%s"}\n' "$SANDBOX_SESSION" \
+		>"/dev/tcp/host.docker.internal/${SANDBOX_HOSTTOOL_PORT:-9847}" 2>/dev/null
+fi
+exit $status`
+
+// DetachedArgs wraps args to run in a new detached tmux session called
+// name, which `sandbox attach` can attach to later.
+func DetachedArgs(name string, args []string) []string {
+	wrapped := []string{"tmux", "-S", detachedSocketPrefix + name, "new-session", "-d", "-s", name,
+		"bash", "-c", detachedScript, name}
+	return append(wrapped, args...)
+}
+
+// DetachedSessions returns the names of the detached sessions still
+// running in container, sorted. Sockets left by sessions that have ended
+// are ignored.
+func DetachedSessions(container string) ([]string, error) {
+	const script = `for s in "$1"*; do
+	[ -S "$s" ] && tmux -S "$s" has-session 2>/dev/null && echo "${s#$1}"
+done; true`
+	out, err := exec.Command("docker", "exec", "-u", "root", container,
+		"sh", "-c", script, "sh", detachedSocketPrefix).Output()
+	if err != nil {
+		return nil, fmt.Errorf("list detached sessions: %w", err)
+	}
+	names := strings.Fields(string(out))
+	sort.Strings(names)
+	return names, nil
+}
+
+// AttachArgs returns the command that attaches to the detached session
+// called name. It runs as root, which tmux lets attach to any user's
+// server, as sessions with resource limits run their server as root.
+func AttachArgs(name string) (user string, args []string) {
+	return "root", []string{"tmux", "-S", detachedSocketPrefix + name, "attach-session"}
+}
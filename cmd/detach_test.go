@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetachedArgs(t *testing.T) {
+	got := DetachedArgs("claude", []string{"claude", "-p", "hi"})
+	want := []string{"tmux", "-S", "/tmp/sandbox-tmux-claude", "new-session", "-d", "-s", "claude",
+		"bash", "-c", detachedScript, "claude", "claude", "-p", "hi"}
+	if strings.Join(got, "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("DetachedArgs = %q, want %q", got, want)
+	}
+}
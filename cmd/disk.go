@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LabelDisk records how a container's disk limit is enforced and its size,
+// e.g. "storage-opt=20G", so a changed config can be pointed out.
+const LabelDisk = "sandbox.disk"
+
+// ResourcesConfig bounds what the container as a whole may use.
+type ResourcesConfig struct {
+	// Disk caps what the container can write outside its volumes and the
+	// workspace, e.g. "20G".
+	Disk string `yaml:"disk,omitempty"`
+}
+
+// Ways a disk limit is enforced, as recorded in LabelDisk.
+const (
+	DiskStorageOpt = "storage-opt" // the storage driver sizes the writable layer
+	DiskWatch      = "watch"       // sandbox measures it during execs
+)
+
+func validateResources(r ResourcesConfig) error {
+	if r.Disk == "" {
+		return nil
+	}
+	if _, err := parseSize(r.Disk); err != nil {
+		return fmt.Errorf("resources.disk: %w", err)
+	}
+	return nil
+}
+
+// storageOptDrivers are the storage drivers that can size a container's
+// writable layer with --storage-opt. overlay2 can too, but only on xfs
+// mounted with pquota.
+var storageOptDrivers = []string{"btrfs", "devicemapper", "windowsfilter", "zfs"}
+
+// storageOptSupported reports whether the Docker host's storage driver can
+// size containers. A variable so tests can stand in for the host.
+var storageOptSupported = func() bool {
+	out, err := exec.Command("docker", "info", "-f",
+		`{{.Driver}}{{range .DriverStatus}}|{{index . 0}}={{index . 1}}{{end}}`).Output()
+	if err != nil {
+		return false
+	}
+	return driverSizesContainers(string(out))
+}
+
+// driverSizesContainers reports whether the driver described by info, as
+// storageOptSupported formats it, supports --storage-opt size.
+func driverSizesContainers(info string) bool {
+	driver, status, _ := strings.Cut(strings.TrimSpace(info), "|")
+	if slices.Contains(storageOptDrivers, driver) {
+		return true
+	}
+	return driver == "overlay2" && slices.Contains(strings.Split(status, "|"), "Backing Filesystem=xfs")
+}
+
+// diskLabel returns the LabelDisk value for cfg's disk limit enforced by
+// how, or "" when there is no limit.
+func diskLabel(cfg *SandboxConfig, how string) string {
+	if cfg == nil || cfg.Resources.Disk == "" {
+		return ""
+	}
+	return how + "=" + cfg.Resources.Disk
+}
+
+// diskEnforcement picks how cfg's disk limit is enforced on this Docker
+// host.
+func diskEnforcement(cfg *SandboxConfig) string {
+	if cfg == nil || cfg.Resources.Disk == "" {
+		return ""
+	}
+	if storageOptSupported() {
+		return DiskStorageOpt
+	}
+	return DiskWatch
+}
+
+// diskRunArgs returns the docker run arguments recording, and with
+// DiskStorageOpt applying, cfg's disk limit.
+func diskRunArgs(cfg *SandboxConfig, how string) []string {
+	label := diskLabel(cfg, how)
+	if label == "" {
+		return nil
+	}
+	args := []string{"--label", LabelDisk + "=" + label}
+	if how == DiskStorageOpt {
+		args = append(args, "--storage-opt", "size="+cfg.Resources.Disk)
+	}
+	return args
+}
+
+// isStorageOptError reports whether docker run's stderr says the storage
+// driver refused --storage-opt, as overlay2 does on xfs without pquota.
+func isStorageOptError(stderr string) bool {
+	return strings.Contains(stderr, "storage-opt") || strings.Contains(stderr, "storage option")
+}
+
+// warnIfDiskChanged warns if the container was created with a different disk
+// limit than the config now asks for. It can only be set when a container is
+// created, so only the size is compared, not how it is enforced.
+func warnIfDiskChanged(container string, cfg *SandboxConfig) {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelDisk+`"}}`, container).Output()
+	if err != nil {
+		return
+	}
+	_, have, _ := strings.Cut(strings.TrimSpace(string(out)), "=")
+	want := ""
+	if cfg != nil {
+		want = cfg.Resources.Disk
+	}
+	if have != want {
+		Warnf(WarnContainer, "resources.disk has changed since this sandbox was created. To apply it, run `sandbox rm <folder>` and then restart.")
+	}
+}
+
+// diskPollInterval is how often a watched container's writable layer is
+// measured.
+var diskPollInterval = time.Minute
+
+// containerDiskUsage returns the size of the container's writable layer.
+// A variable so tests can stand in for Docker.
+var containerDiskUsage = func(container string) (int64, error) {
+	out, err := exec.Command("docker", "inspect", "--size", "-f", "{{.SizeRw}}", container).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// watchedDiskLimit returns the limit sandbox enforces itself for container,
+// or 0 if the storage driver enforces it or there is none.
+func watchedDiskLimit(container string) int64 {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelDisk+`"}}`, container).Output()
+	if err != nil {
+		return 0
+	}
+	how, size, _ := strings.Cut(strings.TrimSpace(string(out)), "=")
+	if how != DiskWatch {
+		return 0
+	}
+	n, _ := parseSize(size)
+	return n
+}
+
+// watchDisk measures container's writable layer every diskPollInterval
+// while an exec runs, when its storage driver can't cap it. Past the limit
+// the container is paused, stopping whatever is filling the disk before
+// the Docker host runs out, and the user is notified. The returned func
+// stops watching.
+func watchDisk(container string, cfg *SandboxConfig) func() {
+	if cfg == nil || cfg.Resources.Disk == "" {
+		return func() {}
+	}
+	limit := watchedDiskLimit(container)
+	if limit <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		tick := time.NewTicker(diskPollInterval)
+		defer tick.Stop()
+		for {
+			if used, err := containerDiskUsage(container); err == nil && used > limit {
+				pauseOverDisk(container, used, limit)
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// pauseOverDisk pauses a container that has written more than its limit.
+func pauseOverDisk(container string, used, limit int64) {
+	if err := exec.Command("docker", "pause", container).Run(); err != nil {
+		notify(fmt.Sprintf("%s has written %s, over its disk limit of %s, but pausing it failed: %v", container, formatSize(used), formatSize(limit), err))
+		return
+	}
+	notify(fmt.Sprintf("%s has written %s, over its disk limit of %s; paused it. Run `docker unpause %s` and free some space, or `sandbox rm` it.",
+		container, formatSize(used), formatSize(limit), container))
+}
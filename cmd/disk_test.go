@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDriverSizesContainers(t *testing.T) {
+	for info, want := range map[string]bool{
+		"overlay2|Backing Filesystem=xfs|Supports d_type=true":   true,
+		"overlay2|Backing Filesystem=extfs|Supports d_type=true": false,
+		"zfs|Zpool=tank": true,
+		"btrfs\n":        true,
+		"vfs":            false,
+		"":               false,
+	} {
+		if got := driverSizesContainers(info); got != want {
+			t.Errorf("driverSizesContainers(%q) = %v, want %v", info, got, want)
+		}
+	}
+}
+
+func TestDiskRunArgs(t *testing.T) {
+	if args := diskRunArgs(&SandboxConfig{}, DiskStorageOpt); args != nil {
+		t.Errorf("no limit gave args %q", args)
+	}
+	cfg := &SandboxConfig{Resources: ResourcesConfig{Disk: "20G"}}
+	if got, want := diskRunArgs(cfg, DiskStorageOpt), []string{"--label", LabelDisk + "=storage-opt=20G", "--storage-opt", "size=20G"}; !slices.Equal(got, want) {
+		t.Errorf("storage-opt args = %q, want %q", got, want)
+	}
+	if got, want := diskRunArgs(cfg, DiskWatch), []string{"--label", LabelDisk + "=watch=20G"}; !slices.Equal(got, want) {
+		t.Errorf("watch args = %q, want %q", got, want)
+	}
+
+	orig := storageOptSupported
+	t.Cleanup(func() { storageOptSupported = orig })
+	storageOptSupported = func() bool { return false }
+	if got := diskEnforcement(cfg); got != DiskWatch {
+		t.Errorf("diskEnforcement without driver support = %q, want %q", got, DiskWatch)
+	}
+	if got := diskEnforcement(&SandboxConfig{}); got != "" {
+		t.Errorf("diskEnforcement without a limit = %q", got)
+	}
+}
+
+func TestValidateResources(t *testing.T) {
+	for _, d := range []string{"", "512M", "20G", "20g"} {
+		if err := validateResources(ResourcesConfig{Disk: d}); err != nil {
+			t.Errorf("validateResources(%q): %v", d, err)
+		}
+	}
+	for _, d := range []string{"20GB", "lots", "-1G"} {
+		if err := validateResources(ResourcesConfig{Disk: d}); err == nil {
+			t.Errorf("validateResources(%q) succeeded, want error", d)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// dnsLogPath is where the container's stub resolver logs queried names.
+const dnsLogPath = "/var/log/sandbox-dns.log"
+
+// DNSQuery is a name looked up through a sandbox's stub resolver.
+type DNSQuery struct {
+	Name    string
+	Count   int
+	Allowed bool // covered by a firewall.allow domain entry
+}
+
+// DNSQueries reads the container's resolver log and returns each queried name
+// with how often it was looked up and whether the allowlist covers it, sorted
+// by name.
+func DNSQueries(container string, cfg *SandboxConfig) ([]DNSQuery, error) {
+	out, err := exec.Command("docker", "exec", container, "cat", dnsLogPath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("read DNS log (is the image up to date?): %w", err)
+	}
+	counts := parseDNSLog(string(out))
+	queries := make([]DNSQuery, 0, len(counts))
+	for name, n := range counts {
+		queries = append(queries, DNSQuery{
+			Name:    name,
+			Count:   n,
+			Allowed: domainAllowed(name, firewallAllow(cfg)),
+		})
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i].Name < queries[j].Name })
+	return queries, nil
+}
+
+// parseDNSLog counts queried names in dnsmasq's log, whose query lines look
+// like "Jan  2 15:04:05 dnsmasq[42]: query[A] example.com from 127.0.0.1".
+// Names are lowercased and reverse lookups are ignored.
+func parseDNSLog(data string) map[string]int {
+	counts := make(map[string]int)
+	for _, line := range strings.Split(data, "\n") {
+		i := strings.Index(line, " query[")
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(line[i:])
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSuffix(fields[1], "."))
+		if strings.HasSuffix(name, ".arpa") {
+			continue
+		}
+		counts[name]++
+	}
+	return counts
+}
+
+// domainAllowed reports whether name exactly matches a domain entry in allow.
+func domainAllowed(name string, allow []FirewallEntry) bool {
+	for _, e := range allow {
+		if strings.EqualFold(strings.TrimSuffix(e.Domain, "."), name) {
+			return true
+		}
+	}
+	return false
+}
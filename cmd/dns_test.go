@@ -0,0 +1,33 @@
+package cmd
+
+import "testing"
+
+func TestParseDNSLog(t *testing.T) {
+	log := `Oct 16 12:00:00 dnsmasq[42]: started, version 2.89 cachesize 1000
+Oct 16 12:00:01 dnsmasq[42]: query[A] registry.npmjs.org from 127.0.0.1
+Oct 16 12:00:01 dnsmasq[42]: forwarded registry.npmjs.org to 127.0.0.11
+Oct 16 12:00:01 dnsmasq[42]: query[AAAA] Registry.NPMjs.org. from 127.0.0.1
+Oct 16 12:00:02 dnsmasq[42]: query[A] github.com from 127.0.0.1
+Oct 16 12:00:03 dnsmasq[42]: query[PTR] 1.0.0.127.in-addr.arpa from 127.0.0.1
+`
+	got := parseDNSLog(log)
+	if got["registry.npmjs.org"] != 2 {
+		t.Errorf("registry.npmjs.org = %d, want 2", got["registry.npmjs.org"])
+	}
+	if got["github.com"] != 1 {
+		t.Errorf("github.com = %d, want 1", got["github.com"])
+	}
+	if len(got) != 2 {
+		t.Errorf("got %v, want only 2 names", got)
+	}
+}
+
+func TestDomainAllowed(t *testing.T) {
+	allow := []FirewallEntry{{Domain: "GitHub.com"}, {CIDR: "10.0.0.0/8"}}
+	if !domainAllowed("github.com", allow) {
+		t.Error("github.com should be allowed")
+	}
+	if domainAllowed("api.github.com", allow) {
+		t.Error("subdomains are not covered by an exact domain entry")
+	}
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// flagOffline is set by the global --offline flag.
+var flagOffline bool
+
+// dnsLookupTimeout bounds each firewall domain lookup, so a disconnected
+// laptop doesn't stall a sync.
+const dnsLookupTimeout = 5 * time.Second
+
+// cachedResolution is the last successful lookup of a firewall domain.
+// Until it expires, by its records' TTL, the domain isn't looked up again.
+type cachedResolution struct {
+	IPs      []string  `json:"ips"`
+	Resolved time.Time `json:"resolved"`
+	Expires  time.Time `json:"expires"`
+}
+
+// dnsCachePath is where firewall domains' last resolutions are kept.
+func dnsCachePath() (string, error) {
+	l, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Cache, "dns-cache.json"), nil
+}
+
+// lookupHost resolves a domain on the host, returning its IPs and how long
+// they may be cached. A variable so tests can stand in for DNS.
+var lookupHost = resolveWithTTL
+
+// domainResolver resolves the firewall's domains for one sync. Domains
+// whose cached lookup hasn't expired aren't looked up again; for the rest
+// it falls back to the cached IPs of the last successful lookup when DNS
+// fails. With --offline, or once a lookup times out, it stops asking DNS
+// and uses the cache for the rest. It is safe for concurrent lookups.
+type domainResolver struct {
+	mu      sync.Mutex
+	offline bool
+	cache   map[string]cachedResolution
+	changed bool
+	stale   []string // domains given cached IPs
+}
+
+func newDomainResolver() *domainResolver {
+	return &domainResolver{offline: flagOffline, cache: loadDNSCache()}
+}
+
+// loadDNSCache reads the last resolutions of firewall domains; a missing or
+// unreadable cache is empty.
+func loadDNSCache() map[string]cachedResolution {
+	cache := make(map[string]cachedResolution)
+	if path, err := dnsCachePath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &cache)
+		}
+	}
+	return cache
+}
+
+// lookup returns domain's IPs, from DNS or the cache.
+func (r *domainResolver) lookup(domain string) ([]string, error) {
+	r.mu.Lock()
+	c, cached := r.cache[domain]
+	offline := r.offline
+	r.mu.Unlock()
+	if cached && time.Now().Before(c.Expires) {
+		return c.IPs, nil
+	}
+	if !offline {
+		ips, ttl, err := lookupHost(domain)
+		if err == nil {
+			now := time.Now()
+			r.mu.Lock()
+			r.cache[domain] = cachedResolution{IPs: ips, Resolved: now, Expires: now.Add(ttl)}
+			r.changed = true
+			r.mu.Unlock()
+			return ips, nil
+		}
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary) || errors.Is(err, context.DeadlineExceeded) {
+			// DNS is unreachable, not just missing this name: asking
+			// about the rest would only stall.
+			r.mu.Lock()
+			r.offline = true
+			r.mu.Unlock()
+		}
+		if !cached {
+			return nil, err
+		}
+	}
+	if !cached {
+		return nil, errors.New("offline, and no cached IPs")
+	}
+	r.mu.Lock()
+	r.stale = append(r.stale, domain)
+	r.mu.Unlock()
+	return c.IPs, nil
+}
+
+// finish saves the cache and warns about the domains given cached IPs.
+func (r *domainResolver) finish() {
+	if len(r.stale) > 0 {
+		oldest := time.Now()
+		for _, d := range r.stale {
+			if t := r.cache[d].Resolved; t.Before(oldest) {
+				oldest = t
+			}
+		}
+		sort.Strings(r.stale)
+		Warnf(WarnFirewall, "DNS unavailable, using cached IPs (oldest from %s) for %s",
+			oldest.Format(time.RFC3339), strings.Join(r.stale, ", "))
+	}
+	if !r.changed {
+		return
+	}
+	path, err := dnsCachePath()
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(r.cache, "", "  ")
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, data, 0644)
+}
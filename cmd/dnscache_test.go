@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDNS stands in for DNS for the test: domains in up resolve, the rest
+// fail with err.
+func fakeDNS(t *testing.T, up map[string][]string, err error) *[]string {
+	t.Helper()
+	var asked []string
+	prev := lookupHost
+	lookupHost = func(domain string) ([]string, time.Duration, error) {
+		asked = append(asked, domain)
+		if ips, ok := up[domain]; ok {
+			return ips, 0, nil
+		}
+		return nil, 0, err
+	}
+	t.Cleanup(func() { lookupHost = prev })
+	return &asked
+}
+
+func TestDomainResolverFallsBackToCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	resetWarnings(t)
+	ui := useRecordingUI(t)
+
+	fakeDNS(t, map[string][]string{"a.example": {"1.2.3.4"}, "b.example": {"5.6.7.8"}}, nil)
+	r := newDomainResolver()
+	r.lookup("a.example")
+	r.lookup("b.example")
+	r.finish()
+	if len(ui.warnings) != 0 {
+		t.Fatalf("warnings = %q, want none while DNS works", ui.warnings)
+	}
+
+	timeout := &net.DNSError{Err: "i/o timeout", Name: "a.example", IsTimeout: true}
+	asked := fakeDNS(t, nil, timeout)
+	r = newDomainResolver()
+	ips, err := r.lookup("a.example")
+	if err != nil || !slices.Equal(ips, []string{"1.2.3.4"}) {
+		t.Errorf("lookup = %q, %v, want the cached 1.2.3.4", ips, err)
+	}
+	if _, err := r.lookup("b.example"); err != nil {
+		t.Errorf("lookup b.example: %v", err)
+	}
+	if _, err := r.lookup("c.example"); err == nil {
+		t.Error("lookup of a domain never resolved should fail offline")
+	}
+	if len(*asked) != 1 {
+		t.Errorf("asked DNS about %q, want only the first domain after a timeout", *asked)
+	}
+	r.finish()
+	if len(ui.warnings) != 1 || !strings.Contains(ui.warnings[0], "a.example, b.example") {
+		t.Errorf("warnings = %q, want one naming the domains given cached IPs", ui.warnings)
+	}
+}
+
+func TestDomainResolverOffline(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	resetWarnings(t)
+	useRecordingUI(t)
+
+	fakeDNS(t, map[string][]string{"a.example": {"1.2.3.4"}}, nil)
+	r := newDomainResolver()
+	r.lookup("a.example")
+	r.finish()
+
+	flagOffline = true
+	t.Cleanup(func() { flagOffline = false })
+	asked := fakeDNS(t, nil, errors.New("unexpected lookup"))
+	r = newDomainResolver()
+	if ips, err := r.lookup("a.example"); err != nil || !slices.Equal(ips, []string{"1.2.3.4"}) {
+		t.Errorf("lookup = %q, %v, want the cached 1.2.3.4", ips, err)
+	}
+	if len(*asked) != 0 {
+		t.Errorf("asked DNS about %q with --offline", *asked)
+	}
+}
+
+func TestDomainResolverNotFound(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	resetWarnings(t)
+	useRecordingUI(t)
+
+	notFound := &net.DNSError{Err: "no such host", Name: "x", IsNotFound: true}
+	asked := fakeDNS(t, map[string][]string{"b.example": {"5.6.7.8"}}, notFound)
+	r := newDomainResolver()
+	if _, err := r.lookup("a.example"); err == nil {
+		t.Error("lookup of an unknown domain should fail")
+	}
+	if _, err := r.lookup("b.example"); err != nil {
+		t.Errorf("lookup after a missing name: %v", err)
+	}
+	if len(*asked) != 2 {
+		t.Errorf("asked DNS about %q, want both: a missing name isn't an outage", *asked)
+	}
+}
+
+func TestDomainResolverHonorsTTL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	resetWarnings(t)
+	useRecordingUI(t)
+
+	asked := fakeDNS(t, nil, nil)
+	lookupHost = func(domain string) ([]string, time.Duration, error) {
+		*asked = append(*asked, domain)
+		if domain == "short.example" {
+			return []string{"1.1.1.1"}, 0, nil
+		}
+		return []string{"2.2.2.2"}, time.Hour, nil
+	}
+	r := newDomainResolver()
+	r.lookup("short.example")
+	r.lookup("long.example")
+	r.finish()
+
+	r = newDomainResolver()
+	if ips, err := r.lookup("long.example"); err != nil || !slices.Equal(ips, []string{"2.2.2.2"}) {
+		t.Errorf("lookup = %q, %v, want the cached 2.2.2.2", ips, err)
+	}
+	r.lookup("short.example")
+	want := []string{"short.example", "long.example", "short.example"}
+	if !slices.Equal(*asked, want) {
+		t.Errorf("asked DNS about %q, want %q: only expired entries again", *asked, want)
+	}
+}
+
+func TestParseDNSAnswer(t *testing.T) {
+	msg, err := dnsQuery(0x1234, "www.example.com", dnsTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.BigEndian.PutUint16(msg[2:], 0x8180) // response, no error
+	binary.BigEndian.PutUint16(msg[6:], 3)
+	// www.example.com CNAME example.com, which has two A records.
+	msg = append(msg, 0xc0, 12, 0, 5, 0, 1, 0, 0, 0x0e, 0x10, 0, 2, 0xc0, 16)
+	msg = append(msg, 0xc0, 16, 0, 1, 0, 1, 0, 0, 0x01, 0x2c, 0, 4, 93, 184, 216, 34)
+	msg = append(msg, 0xc0, 16, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 93, 184, 216, 35)
+
+	ips, ttl, err := parseDNSAnswer(msg, 0x1234, dnsTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ips, []string{"93.184.216.34", "93.184.216.35"}) {
+		t.Errorf("ips = %q", ips)
+	}
+	if ttl != time.Minute {
+		t.Errorf("ttl = %v, want the lowest record's 1m0s", ttl)
+	}
+	if _, _, err := parseDNSAnswer(msg, 0x4321, dnsTypeA); err == nil {
+		t.Error("an answer to another query should be rejected")
+	}
+	if _, _, err := parseDNSAnswer(msg[:len(msg)-3], 0x1234, dnsTypeA); err == nil {
+		t.Error("a truncated answer should be rejected")
+	}
+}
+
+func TestResolveAllowedInParallel(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	resetWarnings(t)
+	useRecordingUI(t)
+
+	var mu sync.Mutex
+	running, most := 0, 0
+	prev := lookupHost
+	lookupHost = func(domain string) ([]string, time.Duration, error) {
+		mu.Lock()
+		running++
+		most = max(most, running)
+		mu.Unlock()
+		// Later domains answer sooner.
+		n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(domain, "d"), ".example"))
+		time.Sleep(time.Duration(20-n) * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return []string{fmt.Sprintf("10.0.0.%d", n)}, time.Hour, nil
+	}
+	t.Cleanup(func() { lookupHost = prev })
+
+	var allow []FirewallEntry
+	for i := range 20 {
+		allow = append(allow, FirewallEntry{Domain: fmt.Sprintf("d%d.example", i)})
+	}
+	allow = append(allow, FirewallEntry{CIDR: "10.1.0.0/16"})
+	domains, cidrs := resolveAllowed(allow, nil)
+	if len(domains) != 20 || len(cidrs) != 1 {
+		t.Fatalf("got %d domains and %d CIDRs, want 20 and 1", len(domains), len(cidrs))
+	}
+	for i, d := range domains {
+		if want := fmt.Sprintf("10.0.0.%d", i); len(d.v4) != 1 || d.v4[0] != want {
+			t.Errorf("domains[%d] = %q, want %s: results should keep allow's order", i, d.v4, want)
+		}
+	}
+	if most < 2 || most > dnsWorkers {
+		t.Errorf("%d lookups ran at once, want between 2 and %d", most, dnsWorkers)
+	}
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDNSTTL is how long a lookup is trusted when the system
+	// resolver answered it, which doesn't say for how long.
+	defaultDNSTTL = 5 * time.Minute
+	// maxDNSTTL caps record TTLs, so a renumbered host is picked up within
+	// a day whatever its records claim.
+	maxDNSTTL = 24 * time.Hour
+
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+)
+
+// errNoTTL means a lookup couldn't be made directly; the system resolver is
+// asked instead.
+var errNoTTL = errors.New("no direct answer")
+
+// resolveWithTTL looks domain up on the host, returning its IPs and how
+// long they may be cached for. It asks the nameservers in
+// /etc/resolv.conf directly, as only their answers carry TTLs, and falls
+// back to the system resolver (with defaultDNSTTL) when that fails, for
+// resolvers set up some other way such as split DNS on a VPN.
+func resolveWithTTL(domain string) ([]string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+	ips, ttl, err := queryNameservers(ctx, domain, resolvConfNameservers())
+	if err == nil {
+		return ips, min(ttl, maxDNSTTL), nil
+	}
+	ips, err = net.DefaultResolver.LookupHost(ctx, domain)
+	return ips, defaultDNSTTL, err
+}
+
+// resolvConfNameservers returns the nameservers /etc/resolv.conf lists.
+func resolvConfNameservers() []string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var servers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+// queryNameservers asks the first of servers that answers for domain's A
+// and AAAA records, returning the addresses and the lowest of their TTLs.
+func queryNameservers(ctx context.Context, domain string, servers []string) ([]string, time.Duration, error) {
+	for _, server := range servers {
+		var ips []string
+		var ttl time.Duration
+		failed := false
+		for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+			got, t, err := queryDNS(ctx, server, domain, qtype)
+			if err != nil {
+				failed = true
+				break
+			}
+			if len(got) > 0 && (len(ips) == 0 || t < ttl) {
+				ttl = t
+			}
+			ips = append(ips, got...)
+		}
+		if !failed && len(ips) > 0 {
+			return ips, ttl, nil
+		}
+	}
+	return nil, 0, errNoTTL
+}
+
+// queryDNS sends one query over UDP and returns the answer's addresses of
+// type qtype and the lowest of their TTLs.
+func queryDNS(ctx context.Context, server, domain string, qtype uint16) ([]string, time.Duration, error) {
+	id := uint16(rand.Uint32())
+	query, err := dnsQuery(id, domain, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(server, "53"))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	// Half the lookup's time at most, leaving the rest to ask the system
+	// resolver.
+	deadline := time.Now().Add(dnsLookupTimeout / 2)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+	if _, err := conn.Write(query); err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, 1232)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	return parseDNSAnswer(buf[:n], id, qtype)
+}
+
+// dnsQuery encodes a recursive query for domain's records of type qtype.
+func dnsQuery(id uint16, domain string, qtype uint16) ([]byte, error) {
+	msg := make([]byte, 12, 12+len(domain)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // recursion desired
+	binary.BigEndian.PutUint16(msg[4:], 1)      // one question
+	for _, label := range strings.Split(strings.TrimSuffix(domain, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, errNoTTL
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, 1) // class IN
+	return msg, nil
+}
+
+// parseDNSAnswer reads the addresses of type qtype out of a response to
+// query id. CNAMEs leading to them are skipped over; a truncated or failed
+// response is an error.
+func parseDNSAnswer(msg []byte, id, qtype uint16) ([]string, time.Duration, error) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg) != id {
+		return nil, 0, errNoTTL
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&0x8000 == 0 || flags&0x0200 != 0 || flags&0x000f != 0 {
+		return nil, 0, errNoTTL
+	}
+	qd, an := binary.BigEndian.Uint16(msg[4:]), binary.BigEndian.Uint16(msg[6:])
+	off := 12
+	for range qd {
+		if off = skipDNSName(msg, off); off < 0 || off+4 > len(msg) {
+			return nil, 0, errNoTTL
+		}
+		off += 4
+	}
+	var ips []string
+	var ttl time.Duration
+	for range an {
+		if off = skipDNSName(msg, off); off < 0 || off+10 > len(msg) {
+			return nil, 0, errNoTTL
+		}
+		typ := binary.BigEndian.Uint16(msg[off:])
+		t := time.Duration(binary.BigEndian.Uint32(msg[off+4:])) * time.Second
+		size := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+size > len(msg) {
+			return nil, 0, errNoTTL
+		}
+		if typ == qtype && (size == net.IPv4len || size == net.IPv6len) {
+			if len(ips) == 0 || t < ttl {
+				ttl = t
+			}
+			ips = append(ips, net.IP(msg[off:off+size]).String())
+		}
+		off += size
+	}
+	return ips, ttl, nil
+}
+
+// skipDNSName returns the offset just past the name at off, or -1 if it
+// runs off the end of msg.
+func skipDNSName(msg []byte, off int) int {
+	for off < len(msg) {
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			return off + 1
+		case n&0xc0 == 0xc0: // compressed: a pointer ends the name
+			if off+2 > len(msg) {
+				return -1
+			}
+			return off + 2
+		default:
+			off += 1 + n
+		}
+	}
+	return -1
+}
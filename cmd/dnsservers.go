@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// LabelDNS records the DNS servers and search domains a container was
+// created with, so changes since can be pointed out.
+const LabelDNS = "sandbox.dns"
+
+// DNSConfig sets the resolvers the container uses instead of the host's,
+// for networks with internal resolvers.
+type DNSConfig struct {
+	Servers []string `yaml:"servers,omitempty"` // IPs; the firewall only allows DNS to these
+	Search  []string `yaml:"search,omitempty"`  // search domains
+}
+
+func validateDNS(d DNSConfig) error {
+	for _, s := range d.Servers {
+		if ip := net.ParseIP(s); ip == nil || ip.IsUnspecified() {
+			return fmt.Errorf("invalid dns server %q, want an IP address", s)
+		}
+	}
+	for _, s := range d.Search {
+		if !hostNameRe.MatchString(s) {
+			return fmt.Errorf("invalid dns search domain %q", s)
+		}
+	}
+	return nil
+}
+
+// dnsLabel describes cfg's DNS settings for LabelDNS.
+func dnsLabel(cfg *SandboxConfig) string {
+	if cfg == nil || (len(cfg.DNS.Servers) == 0 && len(cfg.DNS.Search) == 0) {
+		return ""
+	}
+	return strings.Join(cfg.DNS.Servers, ",") + ";" + strings.Join(cfg.DNS.Search, ",")
+}
+
+// dnsArgs returns the docker run arguments pointing the container at cfg's
+// DNS servers and search domains.
+func dnsArgs(cfg *SandboxConfig) []string {
+	if cfg == nil {
+		return nil
+	}
+	var args []string
+	for _, s := range cfg.DNS.Servers {
+		args = append(args, "--dns", s)
+	}
+	for _, s := range cfg.DNS.Search {
+		args = append(args, "--dns-search", s)
+	}
+	return args
+}
+
+// warnIfDNSChanged prints a warning if the container was created with other
+// DNS settings than the config now has. Docker writes /etc/resolv.conf
+// when a container is created.
+func warnIfDNSChanged(container, want string) {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelDNS+`"}}`, container).Output()
+	if err != nil {
+		return
+	}
+	if have := strings.TrimSpace(string(out)); have != want {
+		Warnf(WarnContainer, "dns has changed since this sandbox was created. To apply it, run `sandbox rm <folder>` and then restart.")
+	}
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeDNS(t *testing.T) {
+	base := &SandboxConfig{DNS: DNSConfig{Servers: []string{"10.0.0.2"}, Search: []string{"corp.example.com"}}}
+	override := &SandboxConfig{DNS: DNSConfig{Servers: []string{"10.9.0.2", "10.9.0.3"}}}
+	merged := mergeConfig(base, override)
+	if !slices.Equal(merged.DNS.Servers, []string{"10.9.0.2", "10.9.0.3"}) {
+		t.Errorf("servers = %q, want the workspace's", merged.DNS.Servers)
+	}
+	if !slices.Equal(merged.DNS.Search, []string{"corp.example.com"}) {
+		t.Errorf("search = %q, want the global one", merged.DNS.Search)
+	}
+
+	want := []string{"--dns", "10.9.0.2", "--dns", "10.9.0.3", "--dns-search", "corp.example.com"}
+	if args := dnsArgs(merged); !slices.Equal(args, want) {
+		t.Errorf("dnsArgs = %q, want %q", args, want)
+	}
+	if label := dnsLabel(merged); label != "10.9.0.2,10.9.0.3;corp.example.com" {
+		t.Errorf("dnsLabel = %q", label)
+	}
+	if dnsLabel(&SandboxConfig{}) != "" {
+		t.Error("dnsLabel should be empty without dns settings")
+	}
+}
+
+func TestValidateDNS(t *testing.T) {
+	if err := validateDNS(DNSConfig{Servers: []string{"10.0.0.2", "fd00::53"}, Search: []string{"corp.example.com"}}); err != nil {
+		t.Errorf("validateDNS = %v", err)
+	}
+	for _, bad := range []DNSConfig{{Servers: []string{"dns.corp"}}, {Servers: []string{"0.0.0.0"}}, {Search: []string{"a b"}}} {
+		if validateDNS(bad) == nil {
+			t.Errorf("validateDNS(%+v) should fail", bad)
+		}
+	}
+}
@@ -2,16 +2,23 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"crypto/sha256"
 	_ "embed"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 //go:embed image/Dockerfile
@@ -21,99 +28,355 @@ var (
 	imageName = "sandbox"
 	LabelSel  = "sandbox.managed=true"
 	LabelWs   = "sandbox.workspace"
+	// LabelCreds records the credentials volume a container was created with.
+	LabelCreds = "sandbox.creds-volume"
 )
 
+// Special creds_volume values.
+const (
+	CredsShared    = "shared"    // one volume for every sandbox that opts in
+	CredsWorkspace = "workspace" // a volume per workspace
+)
+
+// sharedCredsVolume is the volume used by creds_volume: shared.
+const sharedCredsVolume = "sandbox-creds"
+
+// volumeNameRe matches valid Docker volume names.
+var volumeNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// credsVolume returns the Docker volume mounted at /home/agent/.claude for
+// wsPath, or "" when credentials stay in the container. A workspace's own
+// volume is named with a hash of its full path, as container names only
+// use the directory's name.
+func credsVolume(cfg *SandboxConfig, wsPath string) string {
+	if cfg == nil {
+		return ""
+	}
+	switch cfg.CredsVolume {
+	case "":
+		return ""
+	case CredsShared:
+		return sharedCredsVolume
+	case CredsWorkspace:
+		return ContainerName(wsPath) + "-creds-" + sha256Hex([]byte(wsPath))[:8]
+	default:
+		return cfg.CredsVolume
+	}
+}
+
+// warnIfCredsChanged prints a warning if the container was created with a
+// different credentials volume than the config now asks for. Volumes can only
+// be attached when a container is created.
+func warnIfCredsChanged(container, want string) {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelCreds+`"}}`, container).Output()
+	if err != nil {
+		return
+	}
+	if have := strings.TrimSpace(string(out)); have != want {
+		Warnf(WarnContainer, "creds_volume has changed since this sandbox was created. To apply it, run `sandbox rm <folder>` and then restart.")
+	}
+}
+
 // EnsureStarted makes sure the container is running, creating or restarting it
 // as needed. It does NOT sync — callers handle that.
 func EnsureStarted(wsPath string) (string, error) {
-	name := ContainerName(wsPath)
+	name := SandboxContainer(wsPath)
+
+	// The config may not exist yet; that just means no credentials volume.
+	// One that doesn't parse must stop us, or the sandbox would start
+	// without its firewall allowlist.
+	cfg, err := LoadConfig(wsPath)
+	if err != nil && !errors.Is(err, ErrNoConfig) {
+		return "", err
+	}
+	if err := CheckRequirements(cfg); err != nil {
+		return "", err
+	}
+	creds := credsVolume(cfg, wsPath)
+	ssh := sshPublishSpec(cfg)
+	worktrees := linkedWorktrees(wsPath)
+	masks := maskPaths(cfg, append([]string{wsPath}, worktrees...))
+	if workspaceSyncEngine(cfg) != "" {
+		// The container has its own copy of the workspace, which masked
+		// paths are left out of, and linked worktrees aren't mounted
+		// beside it.
+		worktrees, masks = nil, nil
+	}
+	security := securityLabel(cfg)
+	hosts := hostsLabel(cfg)
+	dns := dnsLabel(cfg)
+	caches := cacheVolumes(cfg, name, wsPath)
+
+	image := sandboxImageRef(cfg)
 
 	if IsRunning(name) || ContainerExists(name) {
-		warnIfStale(name)
+		warnIfStale(name, cfg)
+		if !IsLegacyContainer(name) {
+			warnIfCredsChanged(name, creds)
+			warnIfSSHChanged(name, ssh)
+			warnIfWorktreesChanged(name, worktrees)
+			warnIfSecurityChanged(name, security)
+			warnIfDiskChanged(name, cfg)
+			warnIfMaskChanged(name, masks)
+			warnIfHostsChanged(name, hosts)
+			warnIfDNSChanged(name, dns)
+			warnIfWorkspaceSyncChanged(name, workspaceSyncEngine(cfg))
+			warnIfCachesChanged(name, cacheLabel(caches))
+		}
 	}
 
 	if IsRunning(name) {
+		if err := ensureWorkspaceSync(cfg, name, wsPath); err != nil {
+			return "", err
+		}
 		return name, nil
 	}
 
 	// Restart a stopped container
 	if ContainerExists(name) {
-		fmt.Printf("Restarting sandbox for %s...\n", wsPath)
+		if err := checkWorktreeMounts(name); err != nil {
+			return "", err
+		}
+		if err := runPlugins(PluginEvent{Event: PluginPreStart, Workspace: wsPath, Container: name}); err != nil {
+			return "", err
+		}
+		Frontend.Info(Msg("sandbox.restarting", wsPath))
 		if err := DockerRun("start", name); err != nil {
-			return "", fmt.Errorf("restart container: %w", err)
+			return "", dockerFailed(fmt.Errorf("restart container: %w", err))
 		}
+		if !IsLegacyContainer(name) {
+			if err := matchAgentIDs(name); err != nil {
+				return "", err
+			}
+			if err := chownCacheVolumes(name, caches, workspaceSyncEngine(cfg) == ""); err != nil {
+				return "", err
+			}
+		}
+		// Firewall rules and the DNS cache don't survive a restart.
+		if err := initFirewall(cfg, name, wsPath); err != nil {
+			return "", err
+		}
+		if err := ensureWorkspaceSync(cfg, name, wsPath); err != nil {
+			return "", err
+		}
+		if ssh != "" {
+			if err := startSSHD(name, readonlyRootfs(cfg)); err != nil {
+				return "", err
+			}
+		}
+		notifyWebhooks(cfg, WebhookEvent{Event: EventStarted, Sandbox: name, Workspace: wsPath})
 		return name, nil
 	}
 
-	if err := ensureImage(); err != nil {
+	if err := ensureImage(cfg); err != nil {
+		return "", dockerFailed(err)
+	}
+	if creds != "" {
+		if err := adoptLegacyVolume(creds, image); err != nil {
+			return "", err
+		}
+	}
+
+	if err := runPlugins(PluginEvent{Event: PluginPreStart, Workspace: wsPath, Container: name}); err != nil {
+		return "", err
+	}
+
+	secArgs, cleanupSecArgs, err := securityArgs(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer cleanupSecArgs()
+	if err := createCacheVolumes(caches, name, workspaceSyncEngine(cfg) == ""); err != nil {
 		return "", err
 	}
 
-	fmt.Printf("Starting sandbox for %s...\n", wsPath)
-	cmd := exec.Command("docker", "run", "-d",
+	Frontend.Info(Msg("sandbox.starting", wsPath))
+	runArgs := []string{"run", "-d",
 		"--name", name,
 		"--hostname", name,
 		"--label", LabelSel,
-		"--label", LabelWs+"="+wsPath,
-		"--cap-add", "NET_ADMIN",
-		"--security-opt", "no-new-privileges",
-		"-v", wsPath+":"+wsPath,
-		"-w", wsPath,
-		imageName)
-	// cmd.Stderr = os.Stderr
-	err := cmd.Run()
+		"--label", LabelWs + "=" + wsPath,
+		"--label", LabelCreds + "=" + creds,
+		"--label", LabelSSH + "=" + ssh,
+		"--label", LabelWorktrees + "=" + strings.Join(worktrees, ":"),
+		"--label", LabelSecurity + "=" + security,
+		"--label", LabelMask + "=" + strings.Join(masks, ":"),
+		"--label", LabelHosts + "=" + hosts,
+		"--label", LabelDNS + "=" + dns,
+		"--label", LabelCaches + "=" + cacheLabel(caches),
+		"--label", LabelFirewallHash + "=" + sha256Hex(firewallScript),
+	}
+	runArgs = append(runArgs, workspaceSyncArgs(cfg, wsPath)...)
+	runArgs = append(runArgs, secArgs...)
+	if creds != "" {
+		runArgs = append(runArgs, "-v", creds+":/home/agent/.claude")
+	}
+	if ssh != "" {
+		runArgs = append(runArgs, "-p", ssh)
+	}
+	// Linked worktrees outside the root share this sandbox, so they are
+	// mounted at their host paths too.
+	for _, w := range worktrees {
+		runArgs = append(runArgs, "-v", w+":"+w)
+	}
+	runArgs = append(runArgs, maskArgs(masks)...)
+	runArgs = append(runArgs, cacheArgs(caches)...)
+	runArgs = append(runArgs, hostsArgs(cfg)...)
+	runArgs = append(runArgs, dnsArgs(cfg)...)
+	runArgs = append(runArgs, "-w", wsPath)
+	how := diskEnforcement(cfg)
+	stderr, err := runContainer(image, append(runArgs, diskRunArgs(cfg, how)...))
+	if err != nil && how == DiskStorageOpt && isStorageOptError(stderr) {
+		// overlay2 on xfs only sizes containers when mounted with pquota,
+		// which docker info doesn't show.
+		stderr, err = runContainer(image, append(runArgs, diskRunArgs(cfg, DiskWatch)...))
+	}
 	if err != nil {
-		return "", fmt.Errorf("start container: %w", err)
+		return "", dockerFailed(fmt.Errorf("start container: %w %s", err, stderr))
+	}
+	if err := matchAgentIDs(name); err != nil {
+		return "", err
+	}
+	if err := chownCacheVolumes(name, caches, workspaceSyncEngine(cfg) == ""); err != nil {
+		return "", err
 	}
 
-	// Initialise the firewall as root. The container defaults to the
-	// unprivileged "agent" user, so we exec as root explicitly.
-	if err := exec.Command("docker", "exec", "-u", "root", name, "/opt/init-firewall.sh").Run(); err != nil {
-		return "", fmt.Errorf("init firewall: %w", err)
+	if err := initFirewall(cfg, name, wsPath); err != nil {
+		return "", err
+	}
+	if err := ensureWorkspaceSync(cfg, name, wsPath); err != nil {
+		return "", err
+	}
+	if ssh != "" {
+		if err := startSSHD(name, readonlyRootfs(cfg)); err != nil {
+			return "", err
+		}
 	}
 
+	notifyWebhooks(cfg, WebhookEvent{Event: EventStarted, Sandbox: name, Workspace: wsPath})
 	return name, nil
 }
 
+// initFirewall initialises the firewall as root. The container defaults to
+// the unprivileged "agent" user, so we exec as root explicitly.
+func initFirewall(cfg *SandboxConfig, name, wsPath string) error {
+	// With a read-only root, /opt is a tmpfs that starts out empty.
+	if readonlyRootfs(cfg) {
+		if err := copyToContainer(name, firewallScript, "/opt/init-firewall.sh"); err != nil {
+			return &Error{Kind: KindFirewall, Err: fmt.Errorf("copy firewall script: %w", err)}
+		}
+	}
+	if err := exec.Command("docker", "exec", "-u", "root", name, "/opt/init-firewall.sh").Run(); err != nil {
+		notifyWebhooks(cfg, WebhookEvent{Event: EventFirewallFailed, Sandbox: name, Workspace: wsPath, Error: err.Error()})
+		return &Error{Kind: KindFirewall, Err: fmt.Errorf("init firewall: %w", err)}
+	}
+	return nil
+}
+
+// runContainer creates and starts the sandbox container from image with
+// docker run args, returning what docker printed on stderr.
+func runContainer(image string, args []string) (string, error) {
+	cmd := exec.Command("docker", append(append([]string(nil), args...), "--platform", ImagePlatform(), image)...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return strings.TrimSpace(stderr.String()), err
+}
+
 // EnsureRunning starts the container if needed and syncs files into it.
 func EnsureRunning(wsPath string) (string, error) {
 	name, err := EnsureStarted(wsPath)
 	if err != nil {
 		return "", err
 	}
-	if err := SyncContainer(name, wsPath, false); err != nil {
+	if err := SyncContainer(name, wsPath, SyncOptions{}); err != nil {
 		return "", err
 	}
-	fmt.Println("Sandbox ready")
+	verifyAndRestore(name)
+	Frontend.Info(Msg("sandbox.ready"))
 	return name, nil
 }
 
 // ImageHash returns a hash of all inputs that affect the built image.
 func ImageHash() string {
+	return imageHash(ImageVariant(), ImagePlatform(), ToolchainsConfig{})
+}
+
+// imageScripts are the embedded scripts the Dockerfile copies in, by file
+// name in the build context.
+func imageScripts() map[string][]byte {
+	return map[string][]byte{
+		"init-firewall.sh": firewallScript,
+		"sandbox-scope":    scopeScript,
+		"hosttool-mcp":     hosttoolMCPScript,
+		"gpg-agent-relay":  gpgRelayScript,
+	}
+}
+
+// imageHash is ImageHash for the variant's image built for platform with
+// tc's versions.
+func imageHash(variant, platform string, tc ToolchainsConfig) string {
 	h := sha256.New()
 	h.Write(dockerfile)
-	h.Write(firewallScript)
-	h.Write([]byte(fmt.Sprintf("uid=%d", os.Getuid())))
+	scripts := imageScripts()
+	for _, name := range slices.Sorted(maps.Keys(scripts)) {
+		h.Write(scripts[name])
+	}
+	uid, gid := hostIDs()
+	h.Write([]byte(fmt.Sprintf("uid=%d,gid=%d,platform=%s,variant=%s", uid, gid, platform, variant)))
+	for _, arg := range imageBuildArgs(tc) {
+		h.Write([]byte("\n" + arg))
+	}
+	if certs := buildCACertsHash(); certs != "" {
+		h.Write([]byte("\nca_certificates=" + certs))
+	}
 	return hex.EncodeToString(h.Sum(nil))[:16]
 }
 
-func ensureImage() error {
-	hash := ImageHash()
-	if imageExists() {
+// ensureImage builds or pulls the image a sandbox with cfg starts from,
+// unless it is up to date.
+func ensureImage(cfg *SandboxConfig) error {
+	tc := configToolchains(cfg)
+	if err := ensureBaseImage(tc); err != nil {
+		return err
+	}
+	if cfg != nil && len(cfg.Image.Packages) > 0 {
+		return ensurePackages(imageRef(ImageVariant(), ImagePlatform(), tc), cfg.Image.Packages, BuildOptions{}, false)
+	}
+	return nil
+}
+
+// ensureBaseImage is ensureImage for the sandbox image with tc's versions,
+// before any image.packages.
+func ensureBaseImage(tc ToolchainsConfig) error {
+	variant, platform := ImageVariant(), ImagePlatform()
+	// A pulled image is kept until `sandbox build` pulls it again.
+	if pull := ImagePull(); usesPulledImage(tc) {
+		if imageExists(variant, platform, tc) {
+			return nil
+		}
+		Frontend.Info(Msg("image.pulling", pull, platform))
+		return PullImage(platform)
+	}
+	hash := imageHash(variant, platform, tc)
+	if imageExists(variant, platform, tc) {
 		// Check if the image was built from the same inputs.
 		out, err := exec.Command("docker", "inspect", "-f",
-			`{{index .Config.Labels "sandbox.image.hash"}}`, imageName).Output()
+			`{{index .Config.Labels "sandbox.image.hash"}}`, imageRef(variant, platform, tc)).Output()
 		if err == nil && strings.TrimSpace(string(out)) == hash {
 			return nil
 		}
-		fmt.Println("Sandbox image outdated, rebuilding...")
+		Frontend.Info(Msg("image.outdated"))
 	} else {
-		fmt.Println("Building sandbox image (first time)...")
+		Frontend.Info(Msg("image.building"))
 	}
-	return BuildImage(hash)
+	return BuildImage(BuildOptions{Toolchains: tc})
 }
 
-func BuildImage(hash string) error {
+// BuildImage builds the sandbox image with BuildKit, whose cache mounts
+// keep apt and npm downloads between builds, of image.variant for
+// image.platform unless opts say otherwise.
+func BuildImage(opts BuildOptions) error {
 	dir, err := os.MkdirTemp("", "sandbox-build-*")
 	if err != nil {
 		return fmt.Errorf("mkdtemp: %w", err)
@@ -123,17 +386,96 @@ func BuildImage(hash string) error {
 	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), dockerfile, 0644); err != nil {
 		return err
 	}
-	if err := os.WriteFile(filepath.Join(dir, "init-firewall.sh"), firewallScript, 0755); err != nil {
+	for name, data := range imageScripts() {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0755); err != nil {
+			return err
+		}
+	}
+	// The global config's CA certificates only go into local builds: a
+	// pushed image is shared beyond this host's network.
+	var caCerts []string
+	if opts.Push == "" {
+		caCerts = readGlobalSettings().CACertificates
+	}
+	if err := writeBuildCACerts(dir, caCerts); err != nil {
+		return err
+	}
+	platforms := opts.Platforms
+	if len(platforms) == 0 {
+		platforms = []string{ImagePlatform()}
+		if opts.Push != "" {
+			platforms = pushPlatforms
+		}
+	}
+	if len(platforms) > 1 && opts.Push == "" {
+		return fmt.Errorf("only --push can build for several platforms")
+	}
+	for _, p := range platforms {
+		if err := validateImagePlatform(p); err != nil {
+			return err
+		}
+	}
+	variant := opts.Variant
+	if variant == "" {
+		variant = ImageVariant()
+	}
+	if err := validateImageVariant(variant); err != nil {
+		return err
+	}
+	args := []string{"build", "--progress=plain", "--platform", strings.Join(platforms, ","),
+		"--build-arg", "VARIANT=" + variant}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	if opts.PullBase {
+		args = append(args, "--pull")
+	}
+	if opts.Push != "" {
+		// A multi-platform image can only go to a registry, through buildx.
+		args = append([]string{"buildx"}, append(args, "--push", "-t", opts.Push)...)
+	} else {
+		uid, gid := hostIDs()
+		args = append(args,
+			"--build-arg", fmt.Sprintf("HOST_UID=%d", uid),
+			"--build-arg", fmt.Sprintf("HOST_GID=%d", gid),
+			"--label", "sandbox.image.hash="+imageHash(variant, platforms[0], opts.Toolchains),
+			"-t", imageRef(variant, platforms[0], opts.Toolchains))
+	}
+	if err := validateToolchains(opts.Toolchains); err != nil {
 		return err
 	}
-	cmd := exec.Command("docker", "build",
-		"--progress=plain",
-		"--build-arg", fmt.Sprintf("HOST_UID=%d", os.Getuid()),
-		"--label", "sandbox.image.hash="+hash,
-		"-t", imageName, dir)
+	args = append(args, proxyBuildArgs()...)
+	for _, arg := range imageBuildArgs(opts.Toolchains) {
+		args = append(args, "--build-arg", arg)
+	}
+	cmd := exec.Command("docker", append(args, dir)...)
+	if err := runBuild(cmd, opts); err != nil {
+		return err
+	}
+	if len(opts.Packages) > 0 && opts.Push == "" {
+		return ensurePackages(imageRef(variant, platforms[0], opts.Toolchains), opts.Packages, opts, true)
+	}
+	return nil
+}
+
+// runBuild runs a docker build command, showing its progress as opts ask.
+func runBuild(cmd *exec.Cmd, opts BuildOptions) error {
+	// The Dockerfiles' cache mounts need BuildKit, which older Docker
+	// Engines only use when asked.
+	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+
+	if opts.Verbose {
+		cmd.Stdout = Frontend.Stderr()
+		cmd.Stderr = Frontend.Stderr()
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("docker build: %w", err)
+		}
+		return nil
+	}
 
 	// Show build progress as a single updating status line.
 	// Docker build with --progress=plain outputs steps to stderr.
+	progress := &buildProgress{steps: opts.Steps, headers: make(map[string]string)}
 	stdout, _ := cmd.StdoutPipe()
 	stderr, _ := cmd.StderrPipe()
 	if err := cmd.Start(); err != nil {
@@ -142,12 +484,12 @@ func BuildImage(hash string) error {
 	go func() {
 		s := bufio.NewScanner(stdout)
 		for s.Scan() {
-			showBuildStep(s.Text())
+			progress.line(s.Text())
 		}
 	}()
 	s := bufio.NewScanner(stderr)
 	for s.Scan() {
-		showBuildStep(s.Text())
+		progress.line(s.Text())
 	}
 	syncStatusDone()
 	if err := cmd.Wait(); err != nil {
@@ -157,31 +499,117 @@ func BuildImage(hash string) error {
 }
 
 func DockerExec(container, workdir string, cfg *SandboxConfig, extraEnv map[string]string, args ...string) error {
-	cmdArgs := []string{"exec", "-it", "-w", workdir}
+	return DockerExecAs("", container, workdir, cfg, extraEnv, args...)
+}
+
+// DockerExecAs is DockerExec as a specific container user; "" means the
+// image's default user.
+func DockerExecAs(user, container, workdir string, cfg *SandboxConfig, extraEnv map[string]string, args ...string) error {
+	return dockerExec(context.Background(), true, user, container, workdir, cfg, extraEnv, args...)
+}
+
+// DockerExecBatch runs args in the container for a script rather than a
+// person: no TTY is allocated, so stdin, stdout and stderr pass through
+// unmodified but for known secrets being redacted (see Redact). A timeout
+// above zero stops the command after that long, with exit status 124 as
+// timeout(1) gives.
+func DockerExecBatch(container, workdir string, cfg *SandboxConfig, extraEnv map[string]string, timeout time.Duration, args ...string) error {
+	return DockerExecContext(context.Background(), container, workdir, cfg, extraEnv, timeout, args...)
+}
+
+// DockerExecContext is DockerExecBatch, also ending the docker client when
+// ctx is done. That doesn't end the command in the container; give it a
+// timeout for that.
+func DockerExecContext(ctx context.Context, container, workdir string, cfg *SandboxConfig, extraEnv map[string]string, timeout time.Duration, args ...string) error {
+	if timeout > 0 {
+		args = killAfterTimeout(timeout, args)
+	}
+	return dockerExec(ctx, false, "", container, workdir, cfg, extraEnv, args...)
+}
+
+// killAfterTimeout prefixes args with a timeout(1) invocation that stops
+// them after d, and kills them if they are still running sessionKillGrace
+// later. timeout(1) takes seconds, not Go durations.
+func killAfterTimeout(d time.Duration, args []string) []string {
+	return append([]string{"timeout", fmt.Sprintf("--kill-after=%ds", int(sessionKillGrace.Seconds())),
+		strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"}, args...)
+}
+
+// ExitError is returned when a command run in the container exits with a
+// non-zero status. Execute exits with the same status.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func dockerExec(ctx context.Context, tty bool, user, container, workdir string, cfg *SandboxConfig, extraEnv map[string]string, args ...string) error {
+	if err := runPlugins(PluginEvent{Event: PluginPreExec, Container: container, Command: args, User: user}); err != nil {
+		return err
+	}
+	cmdArgs := []string{"exec", "-i", "-w", workdir}
+	if tty {
+		cmdArgs[1] = "-it"
+	}
+	if user != "" {
+		cmdArgs = append(cmdArgs, "-u", user)
+	}
 
 	// Pass through TERM so colors work in the container shell
-	if term := os.Getenv("TERM"); term != "" {
+	if term := os.Getenv("TERM"); term != "" && tty {
 		cmdArgs = append(cmdArgs, "-e", "TERM="+term)
 	}
 
+	// Config env values may be secrets, so pass them through the docker
+	// client's environment ("-e KEY") rather than on its command line where
+	// they would show up in the host's process list.
+	var secretEnv []string
 	if cfg != nil && len(cfg.Env) > 0 {
-		keys := make([]string, 0, len(cfg.Env))
-		for k := range cfg.Env {
-			keys = append(keys, k)
+		resolved, keys, err := resolveEnv(cfg.Env, cfg.EnvStrict)
+		if err != nil {
+			return err
 		}
-		sort.Strings(keys)
-
 		for _, k := range keys {
-			v := cfg.Env[k]
-			if strings.HasPrefix(v, "$") {
-				expanded := os.Getenv(v[1:])
-				if expanded == "" {
-					continue
-				}
-				v = expanded
+			cmdArgs = append(cmdArgs, "-e", k)
+			secretEnv = append(secretEnv, k+"="+resolved[k])
+		}
+	}
+
+	// Proxy URLs may carry a password, so they go the same way. Explicit
+	// config env takes precedence.
+	if cfg != nil {
+		proxyEnv := cfg.Proxy.env()
+		names := make([]string, 0, len(proxyEnv))
+		for k := range proxyEnv {
+			if _, set := cfg.Env[k]; !set {
+				names = append(names, k)
 			}
-			cmdArgs = append(cmdArgs, "-e", k+"="+v)
 		}
+		sort.Strings(names)
+		for _, k := range names {
+			cmdArgs = append(cmdArgs, "-e", k)
+			secretEnv = append(secretEnv, k+"="+proxyEnv[k])
+		}
+	}
+
+	// Keys stored with set-key live in the host keychain and are only ever
+	// injected at exec time. Explicit config env takes precedence.
+	storedKeys := storedKeyEnv(cfg)
+	keyNames := make([]string, 0, len(storedKeys))
+	for k := range storedKeys {
+		if cfg != nil {
+			if _, set := cfg.Env[k]; set {
+				continue
+			}
+		}
+		keyNames = append(keyNames, k)
+	}
+	sort.Strings(keyNames)
+	for _, k := range keyNames {
+		cmdArgs = append(cmdArgs, "-e", k)
+		secretEnv = append(secretEnv, k+"="+storedKeys[k])
 	}
 
 	// Extra env vars (e.g. session-specific host tool vars)
@@ -199,58 +627,112 @@ func DockerExec(container, workdir string, cfg *SandboxConfig, extraEnv map[stri
 	cmdArgs = append(cmdArgs, container)
 	cmdArgs = append(cmdArgs, args...)
 
-	cmd := exec.Command("docker", cmdArgs...)
+	reportMetric(cfg, metricEvent{Kind: metricExec, Sandbox: container})
+
+	cmd := exec.CommandContext(ctx, "docker", cmdArgs...)
+	cmd.Env = append(os.Environ(), secretEnv...)
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = Frontend.Stdout()
+	cmd.Stderr = Frontend.Stderr()
+	if !tty {
+		// Without a TTY the output can be scrubbed of secrets on its way
+		// through. A TTY session's output has to go straight to the
+		// terminal.
+		stdout, stderr := newRedactWriter(cmd.Stdout), newRedactWriter(cmd.Stderr)
+		defer stdout.Close()
+		defer stderr.Close()
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+	}
+	entry := AuditEntry{Time: time.Now(), Kind: AuditExec, User: user, Workdir: workdir, Command: args}
+	stopWatch := watchDisk(container, cfg)
 	err := cmd.Run()
+	stopWatch()
+	auditResult(&entry, err, ctx.Err())
+	recordAudit(container, entry)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			os.Exit(exitErr.ExitCode())
+			return &ExitError{Code: exitErr.ExitCode()}
 		}
 		return fmt.Errorf("exec: %w", err)
 	}
 	return nil
 }
 
-
 // buildStepRe matches Docker build step lines like "#8 0.123 ..." or "#8 RUN ..."
 var buildStepRe = regexp.MustCompile(`^#\d+\s+(?:\d+\.\d+\s+)?(.+)`)
 
 // ansiRe strips ANSI escape sequences (cursor moves, clears, colors, etc.)
 var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]|\x1b\].*?\x07|\x1bc`)
 
-// showBuildStep parses a Docker build output line and shows a condensed status.
-func showBuildStep(line string) {
+// buildHeaderRe matches the line starting a Dockerfile step, like
+// "#8 [ 3/14] RUN ...", and buildDoneRe the one ending it.
+var (
+	buildHeaderRe = regexp.MustCompile(`^#(\d+) (\[[^\]]*\d+/\d+\] .+)`)
+	buildDoneRe   = regexp.MustCompile(`^#(\d+) (?:DONE (\d+\.\d+)s|CACHED)$`)
+)
+
+// buildProgress condenses docker build's plain progress output into a
+// status line naming what is being done and, with steps, a line for each
+// Dockerfile step as it finishes.
+type buildProgress struct {
+	steps   bool
+	mu      sync.Mutex
+	headers map[string]string // step headers by BuildKit's step number
+}
+
+// line handles a line of docker build's output.
+func (p *buildProgress) line(line string) {
 	line = ansiRe.ReplaceAllString(line, "")
 	line = strings.TrimSpace(line)
 	if line == "" {
 		return
 	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m := buildHeaderRe.FindStringSubmatch(line); m != nil {
+		p.headers[m[1]] = truncateStep(m[2])
+	}
+	if m := buildDoneRe.FindStringSubmatch(line); m != nil && p.steps {
+		if header, ok := p.headers[m[1]]; ok {
+			took := "cached"
+			if m[2] != "" {
+				took = m[2] + "s"
+			}
+			syncStatusDone()
+			Frontend.Info(header + "  " + took)
+			delete(p.headers, m[1])
+			return
+		}
+	}
 	// Show RUN/COPY/FROM commands as the status
 	if m := buildStepRe.FindStringSubmatch(line); m != nil {
-		text := m[1]
-		// Truncate long lines
-		if len(text) > 72 {
-			text = text[:72] + "..."
-		}
-		syncStatus(text)
+		syncStatus(truncateStep(m[1]))
+	}
+}
+
+// truncateStep shortens a build step to fit the status line.
+func truncateStep(text string) string {
+	if len(text) > 72 {
+		return text[:72] + "..."
 	}
+	return text
 }
 
-// warnIfStale prints a warning if the container was created from an older image.
-func warnIfStale(container string) {
+// imageOutdated reports whether the container was created from an image other
+// than the current one of image. It returns false if either cannot be inspected.
+func imageOutdated(container, image string) bool {
 	ctrImage, err := exec.Command("docker", "inspect", "-f", "{{.Image}}", container).Output()
 	if err != nil {
-		return
+		return false
 	}
-	imgID, err := exec.Command("docker", "inspect", "-f", "{{.Id}}", imageName).Output()
+	imgID, err := exec.Command("docker", "inspect", "-f", "{{.Id}}", image).Output()
 	if err != nil {
-		return
-	}
-	if strings.TrimSpace(string(ctrImage)) != strings.TrimSpace(string(imgID)) {
-		fmt.Fprintf(os.Stderr, "warning: this project is using an outdated container. To update, run `sandbox rm <folder>` and then restart.\n")
+		return false
 	}
+	return strings.TrimSpace(string(ctrImage)) != strings.TrimSpace(string(imgID))
 }
 
 func IsRunning(name string) bool {
@@ -265,14 +747,70 @@ func ContainerExists(name string) bool {
 	return exec.Command("docker", "inspect", name).Run() == nil
 }
 
-func imageExists() bool {
-	return exec.Command("docker", "image", "inspect", imageName).Run() == nil
+// DockerAvailable reports whether the docker CLI is installed and can reach a
+// daemon.
+func DockerAvailable() error {
+	if _, err := lookPath("docker"); err != nil {
+		return &Error{Kind: KindDocker, Err: fmt.Errorf("docker is not installed; %s", Requirement{Spec: "docker"}.hint("docker"))}
+	}
+	out, err := exec.Command("docker", "info", "--format", "{{.ServerVersion}}").CombinedOutput()
+	if err != nil {
+		daemon := "the docker daemon"
+		if c := os.Getenv("DOCKER_CONTEXT"); c != "" {
+			daemon += " of context " + c
+		}
+		return &Error{Kind: KindDocker, Err: fmt.Errorf("cannot reach %s: %s", daemon, strings.TrimSpace(string(out)))}
+	}
+	return nil
+}
+
+// imageExists reports whether the variant's sandbox image for platform
+// with tc's versions exists.
+func imageExists(variant, platform string, tc ToolchainsConfig) bool {
+	return exec.Command("docker", "image", "inspect", imageRef(variant, platform, tc)).Run() == nil
+}
+
+// StopContainer stops the named container, then tells plugins.
+func StopContainer(name string) error {
+	stopWorkspaceSync(name)
+	if err := DockerRun("stop", name); err != nil {
+		return fmt.Errorf("stop container: %w", err)
+	}
+	runPlugins(PluginEvent{Event: PluginPostStop, Container: name})
+	return nil
+}
+
+// containerWorkspace returns the workspace the named container was created
+// for, or "" if it can't be read.
+func containerWorkspace(name string) string {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelWs+`"}}`, name).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// RemoveContainer stops and removes the named container. -v also removes
+// its anonymous volumes, such as those of a read-only root; named volumes,
+// like the credentials volume, are kept.
+func RemoveContainer(name string) error {
+	engine := containerSyncEngine(name)
+	if IsRunning(name) {
+		if err := StopContainer(name); err != nil {
+			return err
+		}
+	}
+	if err := DockerRun("rm", "-v", name); err != nil {
+		return fmt.Errorf("remove container: %w", err)
+	}
+	endWorkspaceSync(name, engine)
+	return nil
 }
 
 func DockerRun(args ...string) error {
 	cmd := exec.Command("docker", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = Frontend.Stdout()
+	cmd.Stderr = Frontend.Stderr()
 	return cmd.Run()
 }
 
@@ -310,7 +848,7 @@ func zshTheme() string {
 func ResolvePath(p string) string {
 	abs, err := filepath.Abs(p)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "sandbox: resolve path: %v\n", err)
+		fmt.Fprintf(Frontend.Stderr(), "sandbox: resolve path: %v\n", err)
 		os.Exit(1)
 	}
 	return abs
@@ -318,39 +856,96 @@ func ResolvePath(p string) string {
 
 // FindSandboxRoot walks up from startPath looking for a directory containing
 // .sandbox/. The user-level ~/.sandbox/ is excluded since it holds global
-// config, not a workspace sandbox.
+// config, not a workspace sandbox. A linked git worktree without a .sandbox/
+// of its own continues the search from its main working tree, so worktrees
+// outside the repository share its sandbox.
 func FindSandboxRoot(startPath string) string {
+	root, _ := findSandboxRoot(startPath)
+	return root
+}
+
+// findSandboxRoot is FindSandboxRoot, also returning every directory the
+// search looked in.
+func findSandboxRoot(startPath string) (string, []string) {
 	home, _ := os.UserHomeDir()
 	dir := startPath
+	followed := false
+	var searched []string
 	for {
+		searched = append(searched, dir)
 		candidate := filepath.Join(dir, ".sandbox")
 		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
 			if home == "" || dir != home {
-				return dir
+				return dir, searched
 			}
 		}
+		if main := worktreeMain(dir); main != "" && !followed {
+			dir, followed = main, true
+			continue
+		}
 		parent := filepath.Dir(dir)
 		if parent == dir {
-			return ""
+			return "", searched
 		}
 		dir = parent
 	}
 }
 
-// ResolveWorkspace determines the sandbox root and working directory for a
-// command. It walks up from path looking for a parent with .sandbox/. When
-// --here is set the given path is used directly.
-// Returns (sandboxRoot, workDir).
-func ResolveWorkspace(path string) (string, string) {
-	if flagHere {
-		return path, path
+// gitWorkTreeRoot returns the top of the git working tree enclosing
+// startPath, or "" if there is none. The home directory and / are never
+// returned, so a dotfiles repository doesn't put all of home in a sandbox.
+func gitWorkTreeRoot(startPath string) string {
+	home, _ := os.UserHomeDir()
+	for dir := startPath; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			if dir == home || filepath.Dir(dir) == dir {
+				return ""
+			}
+			return dir
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
 	}
-	root := FindSandboxRoot(path)
+}
+
+// findWorkspaceRoot is findSandboxRoot, falling back to the top of the
+// enclosing git working tree when no .sandbox/ is found, so running from a
+// subdirectory still mounts the whole project.
+func findWorkspaceRoot(startPath string) (string, []string) {
+	root, searched := findSandboxRoot(startPath)
 	if root == "" {
-		return path, path
+		root = gitWorkTreeRoot(startPath)
 	}
+	return root, searched
+}
+
+// ResolveWorkspace determines the sandbox root and working directory for a
+// command. It walks up from path looking for a parent with .sandbox/, then
+// for the enclosing git repository. When --here is set the given path is
+// used directly.
+// Returns (sandboxRoot, workDir).
+func ResolveWorkspace(path string) (string, string) {
+	root := SandboxRootFor(path)
 	if root != path {
-		fmt.Printf("Using parent sandbox at %s\n", root)
+		if info, err := os.Stat(filepath.Join(root, ".sandbox")); err == nil && info.IsDir() {
+			Frontend.Info(Msg("sandbox.parent", root))
+		} else {
+			Frontend.Info(Msg("sandbox.git_root", root))
+		}
 	}
 	return root, path
 }
+
+// SandboxRootFor returns the sandbox root ResolveWorkspace would pick for
+// path, without announcing a parent sandbox. Results are cached between
+// invocations; see cachedSandboxRoot.
+func SandboxRootFor(path string) string {
+	if flagHere {
+		return path
+	}
+	if root := cachedSandboxRoot(path); root != "" {
+		return root
+	}
+	return path
+}
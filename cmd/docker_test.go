@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestContainerName(t *testing.T) {
@@ -192,6 +193,7 @@ func TestFindSandboxRoot(t *testing.T) {
 }
 
 func TestResolveWorkspace(t *testing.T) {
+	isolateRootCache(t)
 	t.Run("no parent sandbox", func(t *testing.T) {
 		dir := t.TempDir()
 		child := filepath.Join(dir, "project")
@@ -300,3 +302,39 @@ func TestNoDockerInDocker(t *testing.T) {
 		t.Error("docker.go must not use --privileged — it enables Docker-in-Docker and full host access")
 	}
 }
+
+func TestCredsVolume(t *testing.T) {
+	tests := []struct {
+		setting string
+		want    string
+	}{
+		{"", ""},
+		{CredsShared, "sandbox-creds"},
+		{CredsWorkspace, "sandbox-myapp-creds-" + sha256Hex([]byte("/src/myapp"))[:8]},
+		{"work-creds", "work-creds"},
+	}
+	for _, tt := range tests {
+		cfg := &SandboxConfig{CredsVolume: tt.setting}
+		if got := credsVolume(cfg, "/src/myapp"); got != tt.want {
+			t.Errorf("creds_volume %q: got %q, want %q", tt.setting, got, tt.want)
+		}
+	}
+	if got := credsVolume(nil, "/src/myapp"); got != "" {
+		t.Errorf("nil config: got %q, want none", got)
+	}
+	ws := &SandboxConfig{CredsVolume: CredsWorkspace}
+	if credsVolume(ws, "/src/myapp") == credsVolume(ws, "/work/myapp") {
+		t.Error("workspaces with the same directory name share a credentials volume")
+	}
+}
+
+func TestKillAfterTimeout(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		90 * time.Second:        "timeout --kill-after=30s 90s make",
+		1500 * time.Millisecond: "timeout --kill-after=30s 1.5s make",
+	} {
+		if got := strings.Join(killAfterTimeout(d, []string{"make"}), " "); got != want {
+			t.Errorf("killAfterTimeout(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
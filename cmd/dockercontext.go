@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/spf13/cobra"
+)
+
+// DockerConfig chooses the Docker daemon sandboxes live on.
+type DockerConfig struct {
+	// Context names a docker context, e.g. "colima", "orbstack" or one for
+	// a remote machine. Honoured in the global config and profiles only:
+	// every sandbox command has to look on the same daemon to find them.
+	Context string `yaml:"context,omitempty"`
+}
+
+var dockerContextName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.+-]*$`)
+
+func validateDocker(d DockerConfig) error {
+	if d.Context != "" && !dockerContextName.MatchString(d.Context) {
+		return fmt.Errorf("invalid docker.context %q", d.Context)
+	}
+	return nil
+}
+
+// flagDockerContext is set by the global --context flag.
+var flagDockerContext string
+
+// DockerContext returns the docker context sandboxes live on: --context,
+// else docker.context from the global config and active profile, else ""
+// for Docker's own choice (DOCKER_CONTEXT or `docker context use`).
+func DockerContext() string {
+	if flagDockerContext != "" {
+		return flagDockerContext
+	}
+	return globalDockerContext()
+}
+
+// globalDockerContext returns docker.context from the global config, as
+// the active profile leaves it.
+func globalDockerContext() string {
+	s := readGlobalSettings()
+	d := s.Docker
+	if p, ok := s.Profiles[ActiveProfile()]; ok && p.Docker.Context != "" {
+		d = p.Docker
+	}
+	if validateDocker(d) != nil {
+		return ""
+	}
+	return d.Context
+}
+
+// UseDockerContext points every docker command sandbox runs at the named
+// context, through DOCKER_CONTEXT. DOCKER_HOST would win over it, so it is
+// unset. "" leaves Docker's own choice alone.
+func UseDockerContext(name string) {
+	if name == "" {
+		return
+	}
+	if os.Getenv("DOCKER_HOST") != "" {
+		Warnf(WarnContainer, "ignoring DOCKER_HOST, as docker context %s is configured", name)
+		os.Unsetenv("DOCKER_HOST")
+	}
+	os.Setenv("DOCKER_CONTEXT", name)
+}
+
+func init() {
+	cobra.OnInitialize(func() { UseDockerContext(DockerContext()) })
+}
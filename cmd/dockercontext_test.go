@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDockerContext(t *testing.T) {
+	resetWarnings(t)
+	useRecordingUI(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SANDBOX_PROFILE", "")
+	os.MkdirAll(filepath.Join(home, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(home, ".sandbox", "config.yaml"), []byte(`
+docker:
+  context: colima
+profiles:
+  remote:
+    docker:
+      context: build-box
+  plain:
+    env:
+      A: "1"
+`), 0644)
+
+	if got := DockerContext(); got != "colima" {
+		t.Errorf("DockerContext = %q, want colima", got)
+	}
+	t.Setenv("SANDBOX_PROFILE", "remote")
+	if got := DockerContext(); got != "build-box" {
+		t.Errorf("with a profile setting it, DockerContext = %q, want build-box", got)
+	}
+	t.Setenv("SANDBOX_PROFILE", "plain")
+	if got := DockerContext(); got != "colima" {
+		t.Errorf("with a profile not setting it, DockerContext = %q, want colima", got)
+	}
+	flagDockerContext = "orbstack"
+	t.Cleanup(func() { flagDockerContext = "" })
+	if got := DockerContext(); got != "orbstack" {
+		t.Errorf("with --context, DockerContext = %q, want orbstack", got)
+	}
+
+	// A workspace can't move its sandbox to another daemon.
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("docker:\n  context: elsewhere\n"), 0644)
+	t.Setenv("SANDBOX_PROFILE", "")
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Docker.Context != "colima" {
+		t.Errorf("merged docker.context = %q, want the global colima", cfg.Docker.Context)
+	}
+}
+
+func TestUseDockerContext(t *testing.T) {
+	resetWarnings(t)
+	r := useRecordingUI(t)
+	t.Setenv("DOCKER_HOST", "tcp://10.0.0.2:2375")
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	UseDockerContext("")
+	if os.Getenv("DOCKER_HOST") == "" || os.Getenv("DOCKER_CONTEXT") != "" {
+		t.Error("an empty context should leave the environment alone")
+	}
+
+	UseDockerContext("colima")
+	if got := os.Getenv("DOCKER_CONTEXT"); got != "colima" {
+		t.Errorf("DOCKER_CONTEXT = %q, want colima", got)
+	}
+	if _, set := os.LookupEnv("DOCKER_HOST"); set {
+		t.Error("DOCKER_HOST would win over the context, want it unset")
+	}
+	if len(r.warnings) != 1 {
+		t.Errorf("warnings = %q, want one about DOCKER_HOST", r.warnings)
+	}
+}
+
+func TestValidateDocker(t *testing.T) {
+	for _, ok := range []string{"", "colima", "desktop-linux", "my_ctx.2"} {
+		if err := validateDocker(DockerConfig{Context: ok}); err != nil {
+			t.Errorf("validateDocker(%q) = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"-x", "a b", "../ctx"} {
+		if validateDocker(DockerConfig{Context: bad}) == nil {
+			t.Errorf("validateDocker(%q) should fail", bad)
+		}
+	}
+}
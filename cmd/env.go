@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// opCommand is the 1Password CLI binary. Tests point it at a fake.
+var opCommand = "op"
+
+// secretCache memoises secret lookups for the life of the process, so a
+// session that syncs and then execs only asks each secret manager once.
+var secretCache = struct {
+	sync.Mutex
+	values map[string]string
+}{values: make(map[string]string)}
+
+// resolveEnvValue expands a config env value on the host. Supported forms:
+//
+//	op://v/i/f          read from 1Password via `op read`
+//	vault:path#field    read from HashiCorp Vault (see readVault)
+//	anything else       host variables interpolated (see interpolateEnv)
+//
+// ok is false when the value cannot be resolved (unset host variable, failed
+// secret lookup); callers omit the variable rather than setting it empty.
+func resolveEnvValue(v string) (resolved string, ok bool) {
+	switch {
+	case strings.HasPrefix(v, "op://"):
+		return readCachedSecret(v, func() (string, error) { return readOnePassword(v) })
+	case strings.HasPrefix(v, "vault:"):
+		return readCachedSecret(v, func() (string, error) { return readVault(strings.TrimPrefix(v, "vault:")) })
+	default:
+		expanded, missing := interpolateEnv(v)
+		return expanded, len(missing) == 0
+	}
+}
+
+// isSecretRef reports whether v is read from a secret manager rather than
+// interpolated.
+func isSecretRef(v string) bool {
+	return strings.HasPrefix(v, "op://") || strings.HasPrefix(v, "vault:")
+}
+
+// dropWorkspaceSecretRefs removes the secret references from env, a
+// workspace config's env for where, warning about each. The agent can write
+// the workspace config, and the host would read whatever it names.
+func dropWorkspaceSecretRefs(where string, env map[string]string) {
+	for k, v := range env {
+		if isSecretRef(v) {
+			Warnf(WarnConfig, "%s.%s: secret references are only read from the global config, ignoring the workspace's", where, k)
+			delete(env, k)
+		}
+	}
+}
+
+// interpolateEnv expands host environment references in v:
+//
+//	$VAR                the variable's value
+//	${VAR}              the same, for a name followed by name characters
+//	${VAR:-fallback}    fallback when VAR is unset or empty
+//	$$                  a literal $
+//
+// Any other $, such as one before a digit or at the end, is literal.
+// missing lists the variables that were unset or empty and had no fallback.
+func interpolateEnv(v string) (expanded string, missing []string) {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] != '$' || i+1 == len(v) {
+			b.WriteByte(v[i])
+			continue
+		}
+		name, fallback, hasFallback, n := "", "", false, 0
+		switch c := v[i+1]; {
+		case c == '$':
+			b.WriteByte('$')
+			i++
+			continue
+		case c == '{':
+			end := strings.IndexByte(v[i+2:], '}')
+			if end >= 0 {
+				name, fallback, hasFallback = strings.Cut(v[i+2:i+2+end], ":-")
+			}
+			n = 2 + end
+		case isEnvNameByte(c, true):
+			n = 2
+			for n < len(v)-i && isEnvNameByte(v[i+n], false) {
+				n++
+			}
+			name, n = v[i+1:i+n], n-1
+		}
+		if !isEnvName(name) {
+			b.WriteByte('$')
+			continue
+		}
+		if val := os.Getenv(name); val != "" {
+			b.WriteString(val)
+		} else if hasFallback {
+			b.WriteString(fallback)
+		} else {
+			missing = append(missing, name)
+		}
+		i += n
+	}
+	return b.String(), missing
+}
+
+// escapeEnvLiteral returns v written so interpolateEnv gives it back as it
+// is, for values that must not be interpolated.
+func escapeEnvLiteral(v string) string {
+	return strings.ReplaceAll(v, "$", "$$")
+}
+
+// isEnvName reports whether name is a valid variable name.
+func isEnvName(name string) bool {
+	if name == "" || !isEnvNameByte(name[0], true) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isEnvNameByte(name[i], false) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEnvNameByte reports whether c may appear in a variable name; digits are
+// not allowed first.
+func isEnvNameByte(c byte, first bool) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (!first && c >= '0' && c <= '9')
+}
+
+// readCachedSecret returns the cached value for ref, calling read on a miss.
+// Failures are reported once as a warning and not cached, so a later call
+// (e.g. after `op signin`) can succeed.
+func readCachedSecret(ref string, read func() (string, error)) (string, bool) {
+	secretCache.Lock()
+	defer secretCache.Unlock()
+	if v, ok := secretCache.values[ref]; ok {
+		return v, true
+	}
+	v, err := read()
+	if err != nil {
+		Warnf(WarnEnv, "cannot resolve %s: %v", ref, err)
+		return "", false
+	}
+	secretCache.values[ref] = v
+	return v, true
+}
+
+// readOnePassword reads a secret reference with the 1Password CLI.
+func readOnePassword(ref string) (string, error) {
+	out, err := exec.Command(opCommand, "read", "--no-newline", ref).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("op read: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("op read: %w", err)
+	}
+	return string(out), nil
+}
+
+// readVault reads one field of a Vault secret. ref is "path#field", where
+// path is the full API path under /v1/ (e.g. "secret/data/ci" for a KV v2
+// mount). The server and token come from VAULT_ADDR and VAULT_TOKEN, falling
+// back to the token file written by `vault login`.
+func readVault(ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid vault reference %q, want vault:path#field", ref)
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			data, _ := os.ReadFile(filepath.Join(home, ".vault-token"))
+			token = strings.TrimSpace(string(data))
+		}
+	}
+	if token == "" {
+		return "", fmt.Errorf("no Vault token; set VAULT_TOKEN or run 'vault login'")
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		// Vault answers 403 for expired or revoked tokens as well as for
+		// missing policy, and the fix is usually the same.
+		return "", fmt.Errorf("vault: permission denied reading %s; your token may have expired, run 'vault login'", path)
+	case http.StatusNotFound:
+		return "", fmt.Errorf("vault: no secret at %s", path)
+	default:
+		return "", fmt.Errorf("vault: %s reading %s", resp.Status, path)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("vault: decode response: %w", err)
+	}
+	// KV v2 nests the secret under data.data; KV v1 and most other engines
+	// put fields directly under data.
+	fields := body.Data
+	if inner, ok := body.Data["data"].(map[string]interface{}); ok {
+		fields = inner
+	}
+	val, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("vault: secret %s has no field %q", path, field)
+	}
+	str, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("vault: field %q of %s is not a string", field, path)
+	}
+	return str, nil
+}
+
+// applyEnvFiles merges the variables from cfg.EnvFiles into cfg.Env. Files
+// are read in order so later files override earlier ones, but keys set
+// explicitly in env always win. Relative paths are resolved against the
+// workspace root. Unreadable files are reported and skipped. sources maps
+// each key added to the env_files entry it came from.
+func applyEnvFiles(cfg *SandboxConfig, wsPath string) (sources map[string]string) {
+	if len(cfg.EnvFiles) == 0 {
+		return nil
+	}
+	fromFiles := make(map[string]string)
+	fileOf := make(map[string]string)
+	for _, f := range cfg.EnvFiles {
+		path := expandTilde(f)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(wsPath, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			Warnf(WarnEnv, "cannot read env file %s: %v", f, err)
+			continue
+		}
+		_, inWorkspace := pathWithin(filepath.Clean(path), wsPath)
+		env, literal := parseDotEnvQuoted(string(data))
+		for k, v := range env {
+			if literal[k] {
+				v = escapeEnvLiteral(v)
+			} else if inWorkspace && isSecretRef(v) {
+				// As for the workspace config, which the agent can also write.
+				Warnf(WarnEnv, "%s: %s: secret references are only read from env files outside the workspace, ignoring it", f, k)
+				continue
+			}
+			fromFiles[k] = v
+			fileOf[k] = f
+		}
+	}
+	if cfg.Env == nil {
+		cfg.Env = make(map[string]string)
+	}
+	sources = make(map[string]string)
+	for k, v := range fromFiles {
+		if _, set := cfg.Env[k]; !set {
+			cfg.Env[k] = v
+			sources[k] = fileOf[k]
+		}
+	}
+	return sources
+}
+
+// parseDotEnv parses dotenv-format text: KEY=VALUE lines with optional
+// "export " prefixes, blank lines and # comments ignored. Single-quoted values
+// are taken literally; double-quoted values support \n, \" and \\ escapes;
+// unquoted values have trailing " #comments" stripped.
+func parseDotEnv(data string) map[string]string {
+	env, _ := parseDotEnvQuoted(data)
+	return env
+}
+
+// parseDotEnvQuoted is parseDotEnv, also returning the keys whose values
+// were single-quoted, which are never interpolated.
+func parseDotEnvQuoted(data string) (env map[string]string, literal map[string]bool) {
+	env = make(map[string]string)
+	literal = make(map[string]bool)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		literal[k] = false
+		switch {
+		case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+			v = v[1 : len(v)-1]
+			literal[k] = true
+		case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+			v = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(v[1 : len(v)-1])
+		default:
+			if i := strings.Index(v, " #"); i >= 0 {
+				v = strings.TrimSpace(v[:i])
+			}
+		}
+		env[k] = v
+	}
+	return env, literal
+}
+
+// resolveEnv resolves every value in env, dropping unresolvable entries, and
+// returns the resolved map with its keys sorted. With strict set, a value that
+// references an unset host variable is an error instead of being dropped.
+func resolveEnv(env map[string]string, strict bool) (map[string]string, []string, error) {
+	resolved := make(map[string]string, len(env))
+	keys := make([]string, 0, len(env))
+	for _, k := range sortedKeys(env) {
+		v := env[k]
+		if strict && !isSecretRef(v) {
+			if _, missing := interpolateEnv(v); len(missing) > 0 {
+				return nil, nil, fmt.Errorf("env %s: host variable %s is not set", k, missing[0])
+			}
+		}
+		r, ok := resolveEnvValue(v)
+		if !ok {
+			if _, missing := interpolateEnv(v); !isSecretRef(v) && len(missing) > 0 {
+				Warnf(WarnEnv, "env %s: host variable %s is not set, leaving %s out", k, missing[0], k)
+			} else {
+				Warnf(WarnEnv, "env %s: %s couldn't be read, leaving %s out", k, v, k)
+			}
+			continue
+		}
+		if isSecretEnv(k, v, r) {
+			addRedaction(r)
+		}
+		resolved[k] = r
+		keys = append(keys, k)
+	}
+	return resolved, keys, nil
+}
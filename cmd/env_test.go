@@ -0,0 +1,383 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeOp installs a stub 1Password CLI that prints "secret-for:<ref>" for
+// refs under op://vault/ and fails for anything else. It returns the path of
+// a file that records one line per invocation.
+func fakeOp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := `#!/bin/sh
+echo "$3" >> ` + calls + `
+case "$3" in
+  op://vault/*) printf 'secret-for:%s' "$3" ;;
+  *) echo "item not found" >&2; exit 1 ;;
+esac
+`
+	path := filepath.Join(dir, "op")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	orig := opCommand
+	opCommand = path
+	t.Cleanup(func() { opCommand = orig })
+
+	secretCache.Lock()
+	secretCache.values = make(map[string]string)
+	secretCache.Unlock()
+	return calls
+}
+
+func TestResolveEnvValue(t *testing.T) {
+	t.Run("literal", func(t *testing.T) {
+		got, ok := resolveEnvValue("plain")
+		if !ok || got != "plain" {
+			t.Errorf("got (%q, %v), want (plain, true)", got, ok)
+		}
+	})
+
+	t.Run("host variable", func(t *testing.T) {
+		t.Setenv("TEST_SANDBOX_ENV_VAR", "from-host")
+		got, ok := resolveEnvValue("$TEST_SANDBOX_ENV_VAR")
+		if !ok || got != "from-host" {
+			t.Errorf("got (%q, %v), want (from-host, true)", got, ok)
+		}
+	})
+
+	t.Run("1password reference", func(t *testing.T) {
+		fakeOp(t)
+		got, ok := resolveEnvValue("op://vault/api/key")
+		if !ok || got != "secret-for:op://vault/api/key" {
+			t.Errorf("got (%q, %v), want resolved secret", got, ok)
+		}
+	})
+
+	t.Run("1password failure omitted", func(t *testing.T) {
+		fakeOp(t)
+		if _, ok := resolveEnvValue("op://other/api/key"); ok {
+			t.Error("failed op read should not resolve")
+		}
+	})
+
+	t.Run("1password lookups cached", func(t *testing.T) {
+		calls := fakeOp(t)
+		resolveEnvValue("op://vault/api/key")
+		resolveEnvValue("op://vault/api/key")
+		data, _ := os.ReadFile(calls)
+		if n := strings.Count(string(data), "\n"); n != 1 {
+			t.Errorf("op called %d times, want 1", n)
+		}
+	})
+}
+
+func TestGenerateEnvFileOnePassword(t *testing.T) {
+	fakeOp(t)
+	out, err := generateEnvFile(map[string]string{
+		"API_KEY": "op://vault/api/key",
+		"MISSING": "op://other/api/key",
+	}, true)
+	if err != nil {
+		t.Fatalf("secret lookups should not fail strict mode: %v", err)
+	}
+	data := string(out)
+	if !strings.Contains(data, "export API_KEY='secret-for:op://vault/api/key'") {
+		t.Errorf("env file missing resolved secret:\n%s", data)
+	}
+	if strings.Contains(data, "MISSING") {
+		t.Errorf("env file should omit unresolvable secret:\n%s", data)
+	}
+}
+
+// fakeVault starts a Vault-like server with a KV v2 secret at secret/data/ci
+// that only accepts the token "good-token".
+func fakeVault(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "good-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/ci":
+			w.Write([]byte(`{"data":{"data":{"token":"ci-secret"},"metadata":{}}}`))
+		case "/v1/kv/legacy":
+			w.Write([]byte(`{"data":{"token":"v1-secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "good-token")
+
+	secretCache.Lock()
+	secretCache.values = make(map[string]string)
+	secretCache.Unlock()
+}
+
+func TestReadVault(t *testing.T) {
+	t.Run("kv v2 field", func(t *testing.T) {
+		fakeVault(t)
+		got, err := readVault("secret/data/ci#token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "ci-secret" {
+			t.Errorf("got %q, want ci-secret", got)
+		}
+	})
+
+	t.Run("kv v1 field", func(t *testing.T) {
+		fakeVault(t)
+		got, err := readVault("kv/legacy#token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "v1-secret" {
+			t.Errorf("got %q, want v1-secret", got)
+		}
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		fakeVault(t)
+		t.Setenv("VAULT_TOKEN", "expired")
+		_, err := readVault("secret/data/ci#token")
+		if err == nil || !strings.Contains(err.Error(), "vault login") {
+			t.Errorf("err = %v, want hint to run vault login", err)
+		}
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		fakeVault(t)
+		if _, err := readVault("secret/data/ci#nope"); err == nil {
+			t.Error("missing field should error")
+		}
+	})
+
+	t.Run("malformed reference", func(t *testing.T) {
+		fakeVault(t)
+		if _, err := readVault("secret/data/ci"); err == nil {
+			t.Error("reference without #field should error")
+		}
+	})
+
+	t.Run("resolved through env values", func(t *testing.T) {
+		fakeVault(t)
+		got, ok := resolveEnvValue("vault:secret/data/ci#token")
+		if !ok || got != "ci-secret" {
+			t.Errorf("got (%q, %v), want (ci-secret, true)", got, ok)
+		}
+	})
+}
+
+func TestParseDotEnv(t *testing.T) {
+	got := parseDotEnv(`# comment
+A=1
+export B=two
+C="line\nbreak"
+D='$literal'
+E=value # trailing comment
+
+malformed
+F=
+`)
+	want := map[string]string{
+		"A": "1",
+		"B": "two",
+		"C": "line\nbreak",
+		"D": "$literal",
+		"E": "value",
+		"F": "",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d keys, want %d: %v", len(got), len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestApplyEnvFiles(t *testing.T) {
+	t.Run("later files override earlier", func(t *testing.T) {
+		ws := t.TempDir()
+		os.WriteFile(filepath.Join(ws, ".env"), []byte("A=base\nB=base\n"), 0644)
+		os.WriteFile(filepath.Join(ws, ".env.local"), []byte("B=local\n"), 0644)
+
+		cfg := &SandboxConfig{EnvFiles: []string{".env", ".env.local"}}
+		applyEnvFiles(cfg, ws)
+		if cfg.Env["A"] != "base" || cfg.Env["B"] != "local" {
+			t.Errorf("env = %v, want A=base B=local", cfg.Env)
+		}
+	})
+
+	t.Run("explicit env wins", func(t *testing.T) {
+		ws := t.TempDir()
+		os.WriteFile(filepath.Join(ws, ".env"), []byte("A=file\n"), 0644)
+
+		cfg := &SandboxConfig{
+			Env:      map[string]string{"A": "explicit"},
+			EnvFiles: []string{".env"},
+		}
+		applyEnvFiles(cfg, ws)
+		if cfg.Env["A"] != "explicit" {
+			t.Errorf("A = %q, want explicit", cfg.Env["A"])
+		}
+	})
+
+	t.Run("tilde path", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		os.WriteFile(filepath.Join(home, "shared.env"), []byte("S=shared\n"), 0644)
+
+		cfg := &SandboxConfig{EnvFiles: []string{"~/shared.env"}}
+		applyEnvFiles(cfg, t.TempDir())
+		if cfg.Env["S"] != "shared" {
+			t.Errorf("S = %q, want shared", cfg.Env["S"])
+		}
+	})
+
+	t.Run("secret references only outside the workspace", func(t *testing.T) {
+		useRecordingUI(t)
+		home, ws := t.TempDir(), t.TempDir()
+		t.Setenv("HOME", home)
+		os.WriteFile(filepath.Join(home, "shared.env"), []byte("S=op://vault/shared/key\n"), 0644)
+		os.WriteFile(filepath.Join(ws, ".env"), []byte("W=op://Private/aws/secret\nV=vault:secret/data/prod#password\n"), 0644)
+
+		cfg := &SandboxConfig{EnvFiles: []string{"~/shared.env", ".env"}}
+		applyEnvFiles(cfg, ws)
+		_, w := cfg.Env["W"]
+		_, v := cfg.Env["V"]
+		if w || v || cfg.Env["S"] != "op://vault/shared/key" {
+			t.Errorf("env = %v, want only the reference from outside the workspace", cfg.Env)
+		}
+	})
+
+	t.Run("missing file skipped", func(t *testing.T) {
+		cfg := &SandboxConfig{EnvFiles: []string{"nope.env"}}
+		applyEnvFiles(cfg, t.TempDir())
+		if len(cfg.Env) != 0 {
+			t.Errorf("env = %v, want empty", cfg.Env)
+		}
+	})
+}
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Setenv("SANDBOX_TEST_HOST", "example.com")
+	t.Setenv("SANDBOX_TEST_EMPTY", "")
+
+	tests := []struct {
+		in      string
+		want    string
+		missing []string
+	}{
+		{"plain", "plain", nil},
+		{"$SANDBOX_TEST_HOST", "example.com", nil},
+		{"${SANDBOX_TEST_HOST}", "example.com", nil},
+		{"https://${SANDBOX_TEST_HOST}/api", "https://example.com/api", nil},
+		{"https://$SANDBOX_TEST_HOST/api", "https://example.com/api", nil},
+		{"$SANDBOX_TEST_HOST:8080", "example.com:8080", nil},
+		{"${SANDBOX_TEST_HOST}_x", "example.com_x", nil},
+		{"${SANDBOX_TEST_HOST}:8080", "example.com:8080", nil},
+		{"${SANDBOX_TEST_UNSET:-fallback}", "fallback", nil},
+		{"${SANDBOX_TEST_EMPTY:-fallback}", "fallback", nil},
+		{"${SANDBOX_TEST_HOST:-fallback}", "example.com", nil},
+		{"ab$SANDBOX_TEST_UNSET", "ab", []string{"SANDBOX_TEST_UNSET"}},
+		{"pa$$word", "pa$word", nil},
+		{"$$$$", "$$", nil},
+		{"$${SANDBOX_TEST_HOST}", "${SANDBOX_TEST_HOST}", nil},
+		{"$$SANDBOX_TEST_HOST", "$SANDBOX_TEST_HOST", nil},
+		{"https://$$SANDBOX_TEST_HOST/api", "https://$SANDBOX_TEST_HOST/api", nil},
+		{"${not a name}", "${not a name}", nil},
+		{"cost: $5", "cost: $5", nil},
+		{"trailing $", "trailing $", nil},
+		{"${unterminated", "${unterminated", nil},
+		{"a${SANDBOX_TEST_UNSET}-b", "a-b", []string{"SANDBOX_TEST_UNSET"}},
+		{"$SANDBOX_TEST_UNSET", "", []string{"SANDBOX_TEST_UNSET"}},
+		{"${SANDBOX_TEST_EMPTY}", "", []string{"SANDBOX_TEST_EMPTY"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, missing := interpolateEnv(tt.in)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if strings.Join(missing, ",") != strings.Join(tt.missing, ",") {
+				t.Errorf("missing = %v, want %v", missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestEscapeEnvLiteral(t *testing.T) {
+	t.Setenv("SANDBOX_TEST_HOST", "example.com")
+	for _, v := range []string{"plain", "$SANDBOX_TEST_HOST", "$$SANDBOX_TEST_HOST", "${SANDBOX_TEST_HOST}", "$${x}", "a$b${c}", "pa$$", "cost: $5"} {
+		if got, _ := interpolateEnv(escapeEnvLiteral(v)); got != v {
+			t.Errorf("interpolateEnv(escapeEnvLiteral(%q)) = %q", v, got)
+		}
+	}
+}
+
+func TestApplyEnvFilesSingleQuoted(t *testing.T) {
+	t.Setenv("SANDBOX_TEST_HOST", "example.com")
+	ws := t.TempDir()
+	os.WriteFile(filepath.Join(ws, ".env"), []byte("A='${SANDBOX_TEST_HOST}'\nB=\"${SANDBOX_TEST_HOST}\"\nC='$SANDBOX_TEST_HOST'\n"), 0644)
+	cfg := &SandboxConfig{EnvFiles: []string{".env"}}
+	applyEnvFiles(cfg, ws)
+	resolved, _, err := resolveEnv(cfg.Env, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "${SANDBOX_TEST_HOST}", "B": "example.com", "C": "$SANDBOX_TEST_HOST"}
+	for k, v := range want {
+		if resolved[k] != v {
+			t.Errorf("%s = %q, want %q", k, resolved[k], v)
+		}
+	}
+}
+
+func TestResolveEnvWarnsWhenDropping(t *testing.T) {
+	resetWarnings(t)
+	r := useRecordingUI(t)
+	resolved, _, _ := resolveEnv(map[string]string{"URL": "${SANDBOX_TEST_UNSET_HOST}"}, false)
+	if _, ok := resolved["URL"]; ok {
+		t.Error("URL should be left out")
+	}
+	if len(r.warnings) != 1 || !strings.Contains(r.warnings[0], "URL") {
+		t.Errorf("warnings = %q, want one naming URL", r.warnings)
+	}
+}
+
+func TestResolveEnvStrict(t *testing.T) {
+	env := map[string]string{
+		"URL":   "https://${SANDBOX_TEST_UNSET_HOST}/api",
+		"PLAIN": "value",
+	}
+
+	t.Run("lenient drops unset", func(t *testing.T) {
+		resolved, keys, err := resolveEnv(env, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(keys) != 1 || resolved["PLAIN"] != "value" {
+			t.Errorf("resolved = %v, want only PLAIN", resolved)
+		}
+	})
+
+	t.Run("strict errors on unset", func(t *testing.T) {
+		_, _, err := resolveEnv(env, true)
+		if err == nil || !strings.Contains(err.Error(), "SANDBOX_TEST_UNSET_HOST") {
+			t.Errorf("err = %v, want mention of SANDBOX_TEST_UNSET_HOST", err)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind classifies a failure, so scripts can tell them apart by exit
+// status and callers of the package with ErrorKindOf.
+type ErrorKind string
+
+const (
+	KindConfig      ErrorKind = "config"       // a config file is missing or invalid
+	KindDocker      ErrorKind = "docker"       // docker isn't installed or its daemon can't be reached
+	KindNoContainer ErrorKind = "no-container" // the sandbox doesn't exist or isn't running
+	KindSync        ErrorKind = "sync"         // syncing into the sandbox failed
+	KindHook        ErrorKind = "hook"         // an on_sync hook or a plugin failed
+	KindFirewall    ErrorKind = "firewall"     // the firewall couldn't be set up, or had been tampered with
+)
+
+// Exit statuses. A command run in the sandbox that fails exits with its own
+// status instead, and ExitWarnings is 3.
+const (
+	ExitFailure     = 1 // any other failure
+	ExitConfig      = 10
+	ExitDocker      = 11
+	ExitNoContainer = 12
+	ExitSync        = 13
+	ExitHook        = 14
+	ExitFirewall    = 15
+)
+
+var kindExitCodes = map[ErrorKind]int{
+	KindConfig:      ExitConfig,
+	KindDocker:      ExitDocker,
+	KindNoContainer: ExitNoContainer,
+	KindSync:        ExitSync,
+	KindHook:        ExitHook,
+	KindFirewall:    ExitFirewall,
+}
+
+// Error is a failure of a known kind.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// withKind marks err as a failure of kind, unless it is nil or already has
+// a kind: the innermost is the most specific, e.g. a failed hook failing a
+// sync is a hook failure.
+func withKind(kind ErrorKind, err error) error {
+	if err == nil || ErrorKindOf(err) != "" {
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// ErrorKindOf returns the kind of err, or "" if it has none.
+func ErrorKindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return ""
+}
+
+// ExitCode returns the status a command failing with err exits with.
+func ExitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	if code, ok := kindExitCodes[ErrorKindOf(err)]; ok {
+		return code
+	}
+	return ExitFailure
+}
+
+// NotRunningError is the error for a command that needs the sandbox for
+// wsPath running when it isn't. If Docker is the reason, it says so.
+func NotRunningError(wsPath string) error {
+	if err := DockerAvailable(); err != nil {
+		return err
+	}
+	return &Error{Kind: KindNoContainer, Err: fmt.Errorf("no sandbox running for %s", wsPath)}
+}
+
+// dockerFailed explains err from a docker command by Docker being
+// unavailable, if it is.
+func dockerFailed(err error) error {
+	if err == nil {
+		return nil
+	}
+	if dockerErr := DockerAvailable(); dockerErr != nil {
+		return dockerErr
+	}
+	return err
+}
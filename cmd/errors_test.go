@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	hookErr := withKind(KindHook, errors.New("on_sync hook failed"))
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain", errors.New("boom"), ExitFailure},
+		{"in container", fmt.Errorf("exec: %w", &ExitError{Code: 42}), 42},
+		{"kind", &Error{Kind: KindDocker, Err: errors.New("no daemon")}, ExitDocker},
+		{"wrapped kind", fmt.Errorf("start: %w", &Error{Kind: KindFirewall, Err: errors.New("x")}), ExitFirewall},
+		// The innermost kind is kept: a hook failing a sync is a hook failure.
+		{"innermost", withKind(KindSync, hookErr), ExitHook},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	if withKind(KindSync, nil) != nil {
+		t.Error("withKind(nil) should be nil")
+	}
+}
+
+func TestLoadConfigErrorKind(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("firewall: [\n"), 0644)
+
+	_, err := LoadConfig(ws)
+	if ErrorKindOf(err) != KindConfig || ExitCode(err) != ExitConfig {
+		t.Errorf("LoadConfig error = %v (kind %q), want a config error", err, ErrorKindOf(err))
+	}
+}
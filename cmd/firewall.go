@@ -4,9 +4,10 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"net"
-	"os"
 	"os/exec"
+	"slices"
 	"strings"
+	"sync"
 )
 
 // resolvedEntry holds a firewall entry with its pre-resolved IPs split by family.
@@ -22,24 +23,150 @@ type resolveResult struct {
 	cidrs   []FirewallEntry
 }
 
+// privateNetworks are the RFC 1918 ranges firewall.private_networks allows.
+var privateNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+
+// dockerBridgeSubnets returns the IPv4 subnets of Docker's default bridge
+// network, which sandboxes are attached to. A variable so tests can stand
+// in for Docker.
+var dockerBridgeSubnets = sync.OnceValue(func() []string {
+	out, err := exec.Command("docker", "network", "inspect", "bridge", "-f",
+		"{{range .IPAM.Config}}{{.Subnet}} {{end}}").Output()
+	if err != nil {
+		return nil
+	}
+	var subnets []string
+	for _, s := range strings.Fields(string(out)) {
+		if ip, _, err := net.ParseCIDR(s); err == nil && ip.To4() != nil {
+			subnets = append(subnets, s)
+		}
+	}
+	return subnets
+})
+
+// privateNetworkEntries returns the entries firewall.private_networks
+// expands to: the RFC 1918 ranges, and Docker's bridge subnet if it lies
+// outside them.
+func privateNetworkEntries() []FirewallEntry {
+	var entries []FirewallEntry
+	var ranges []*net.IPNet
+	for _, c := range privateNetworks {
+		_, n, _ := net.ParseCIDR(c)
+		ranges = append(ranges, n)
+		entries = append(entries, FirewallEntry{CIDR: c})
+	}
+	for _, s := range dockerBridgeSubnets() {
+		ip, n, _ := net.ParseCIDR(s)
+		ones, _ := n.Mask.Size()
+		if !slices.ContainsFunc(ranges, func(r *net.IPNet) bool {
+			rOnes, _ := r.Mask.Size()
+			return r.Contains(ip) && rOnes <= ones
+		}) {
+			entries = append(entries, FirewallEntry{CIDR: n.String()})
+		}
+	}
+	return entries
+}
+
+// firewallAllow returns the entries the firewall allows: the configured
+// allowlist, the private networks if firewall.private_networks is set, the
+// hosts entries' IPs, the proxies, each configured agent's entries and, when
+// the sandbox is shared over a reverse tunnel, the tunnel's SSH server.
+func firewallAllow(cfg *SandboxConfig) []FirewallEntry {
+	allow := cfg.Firewall.Allow
+	if cfg.Firewall.PrivateNetworks {
+		allow = append(allow[:len(allow):len(allow)], privateNetworkEntries()...)
+	}
+	allow = append(allow[:len(allow):len(allow)], hostsFirewallEntries(cfg)...)
+	allow = append(allow[:len(allow):len(allow)], proxyFirewallEntries(cfg)...)
+	for _, a := range cfg.Agents {
+		allow = append(allow[:len(allow):len(allow)], a.Allow...)
+	}
+	allow = append(allow[:len(allow):len(allow)], vscodeFirewallEntries(cfg)...)
+	if cfg.Share.Via == ShareTunnel && cfg.Share.Tunnel != nil {
+		t := cfg.Share.Tunnel
+		allow = append(allow[:len(allow):len(allow)], FirewallEntry{Domain: t.Host, Ports: []int{t.SSHPort()}})
+	}
+	return allow
+}
+
 // resolveFirewallEntries resolves all domain entries and returns per-entry IP
-// lists. CIDR entries are returned as-is. Note: host.docker.internal (for
-// host tools) is resolved separately inside the container via resolveHostGateway.
+// lists, from the DNS cache when DNS fails. CIDR entries are returned as-is.
+// Note: host.docker.internal (for host tools) is resolved separately inside
+// the container via resolveHostGateway.
 func resolveFirewallEntries(cfg *SandboxConfig) (domains []resolvedEntry, cidrs []FirewallEntry) {
-	for _, e := range cfg.Firewall.Allow {
+	return resolveAllowed(firewallAllow(cfg), nil)
+}
+
+// resolveFirewallEntriesAsync starts DNS resolution in a background goroutine.
+// Progress sends each domain name just before its lookup begins, so callers can
+// display which domains are currently being resolved. Both channels are closed
+// when resolution is complete.
+func resolveFirewallEntriesAsync(cfg *SandboxConfig) (result <-chan resolveResult, progress <-chan string) {
+	resultCh := make(chan resolveResult, 1)
+	allow := firewallAllow(cfg)
+	progressCh := make(chan string, len(allow))
+
+	go func() {
+		defer close(resultCh)
+		defer close(progressCh)
+		domains, cidrs := resolveAllowed(allow, progressCh)
+		resultCh <- resolveResult{domains: domains, cidrs: cidrs}
+	}()
+
+	return resultCh, progressCh
+}
+
+// dnsWorkers is how many firewall domains are looked up at once.
+const dnsWorkers = 8
+
+// resolveAllowed looks up allow's domain entries, dnsWorkers at a time, and
+// returns them in allow's order so the rules, and their hash, don't depend
+// on which lookup finished first. Domains that don't resolve are left out
+// with a warning. If progress isn't nil, each domain is sent to it as its
+// lookup begins; it must have room for all of them.
+func resolveAllowed(allow []FirewallEntry, progress chan<- string) (domains []resolvedEntry, cidrs []FirewallEntry) {
+	r := newDomainResolver()
+	defer r.finish()
+
+	type lookup struct {
+		ips []string
+		err error
+	}
+	results := make([]lookup, len(allow))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range dnsWorkers {
+		wg.Go(func() {
+			for i := range jobs {
+				if progress != nil {
+					progress <- allow[i].Domain
+				}
+				results[i].ips, results[i].err = r.lookup(allow[i].Domain)
+			}
+		})
+	}
+	for i, e := range allow {
+		if e.Domain != "" {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, e := range allow {
 		if e.Domain != "" {
 			ports := e.Ports
 			if len(ports) == 0 {
 				ports = []int{80, 443}
 			}
-			ips, err := net.LookupHost(e.Domain)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "warning: cannot resolve %s: %v\n", e.Domain, err)
+			if err := results[i].err; err != nil {
+				Warnf(WarnFirewall, "cannot resolve %s: %v", e.Domain, err)
 				continue
 			}
 			var re resolvedEntry
 			re.ports = ports
-			for _, ip := range ips {
+			for _, ip := range results[i].ips {
 				parsed := net.ParseIP(ip)
 				if parsed == nil || parsed.IsUnspecified() {
 					continue
@@ -59,59 +186,6 @@ func resolveFirewallEntries(cfg *SandboxConfig) (domains []resolvedEntry, cidrs
 	return domains, cidrs
 }
 
-// resolveFirewallEntriesAsync starts DNS resolution in a background goroutine.
-// Progress sends each domain name just before its lookup begins, so callers can
-// display which domain is currently being resolved. Both channels are closed
-// when resolution is complete.
-func resolveFirewallEntriesAsync(cfg *SandboxConfig) (result <-chan resolveResult, progress <-chan string) {
-	resultCh := make(chan resolveResult, 1)
-	progressCh := make(chan string, len(cfg.Firewall.Allow))
-
-	go func() {
-		defer close(resultCh)
-		defer close(progressCh)
-
-		var domains []resolvedEntry
-		var cidrs []FirewallEntry
-
-		for _, e := range cfg.Firewall.Allow {
-			if e.Domain != "" {
-				progressCh <- e.Domain
-				ports := e.Ports
-				if len(ports) == 0 {
-					ports = []int{80, 443}
-				}
-				ips, err := net.LookupHost(e.Domain)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "warning: cannot resolve %s: %v\n", e.Domain, err)
-					continue
-				}
-				var re resolvedEntry
-				re.ports = ports
-				for _, ip := range ips {
-					parsed := net.ParseIP(ip)
-					if parsed == nil || parsed.IsUnspecified() {
-						continue
-					}
-					if parsed.To4() != nil {
-						re.v4 = append(re.v4, ip)
-					} else {
-						re.v6 = append(re.v6, ip)
-					}
-				}
-				domains = append(domains, re)
-			}
-			if e.CIDR != "" {
-				cidrs = append(cidrs, e)
-			}
-		}
-
-		resultCh <- resolveResult{domains: domains, cidrs: cidrs}
-	}()
-
-	return resultCh, progressCh
-}
-
 // resolveHostGateway resolves host.docker.internal from inside the running
 // container and returns a resolvedEntry for the given port. This hostname only
 // resolves inside Docker containers (not on the host), so we use docker exec.
@@ -141,8 +215,9 @@ func resolveHostGateway(container string, port int) *resolvedEntry {
 }
 
 // writeRestoreRules writes an iptables-restore format ruleset for one address
-// family. isV6 controls the REJECT target (icmp vs icmp6).
-func writeRestoreRules(b *strings.Builder, domains []resolvedEntry, cidrs []FirewallEntry, isV6 bool) {
+// family. isV6 controls the REJECT target (icmp vs icmp6). DNS is allowed to
+// any server, or only to dnsServers (of this family) if there are any.
+func writeRestoreRules(b *strings.Builder, domains []resolvedEntry, cidrs []FirewallEntry, dnsServers []string, isV6 bool) {
 	b.WriteString("*filter\n")
 	b.WriteString(":INPUT ACCEPT [0:0]\n")
 	b.WriteString(":FORWARD ACCEPT [0:0]\n")
@@ -150,14 +225,24 @@ func writeRestoreRules(b *strings.Builder, domains []resolvedEntry, cidrs []Fire
 
 	b.WriteString("-A OUTPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT\n")
 	b.WriteString("-A OUTPUT -o lo -j ACCEPT\n")
-	b.WriteString("-A OUTPUT -p udp --dport 53 -j ACCEPT\n")
-	b.WriteString("-A OUTPUT -p tcp --dport 53 -j ACCEPT\n")
 
 	mask := "/32"
 	if isV6 {
 		mask = "/128"
 	}
 
+	if len(dnsServers) == 0 {
+		b.WriteString("-A OUTPUT -p udp --dport 53 -j ACCEPT\n")
+		b.WriteString("-A OUTPUT -p tcp --dport 53 -j ACCEPT\n")
+	}
+	for _, s := range dnsServers {
+		if ip := net.ParseIP(s); ip == nil || (ip.To4() == nil) != isV6 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("-A OUTPUT -d %s%s -p udp --dport 53 -j ACCEPT\n", s, mask))
+		b.WriteString(fmt.Sprintf("-A OUTPUT -d %s%s -p tcp --dport 53 -j ACCEPT\n", s, mask))
+	}
+
 	for _, re := range domains {
 		ips := re.v4
 		if isV6 {
@@ -189,13 +274,14 @@ func writeRestoreRules(b *strings.Builder, domains []resolvedEntry, cidrs []Fire
 }
 
 // buildFirewallRules generates iptables-restore format rulesets from
-// pre-resolved entries. Used by the sync pipeline after async resolution.
-func buildFirewallRules(domains []resolvedEntry, cidrs []FirewallEntry) (v4, v6 []byte) {
+// pre-resolved entries, allowing DNS only to dnsServers if there are any.
+// Used by the sync pipeline after async resolution.
+func buildFirewallRules(domains []resolvedEntry, cidrs []FirewallEntry, dnsServers []string) (v4, v6 []byte) {
 	var b4 strings.Builder
-	writeRestoreRules(&b4, domains, cidrs, false)
+	writeRestoreRules(&b4, domains, cidrs, dnsServers, false)
 
 	var b6 strings.Builder
-	writeRestoreRules(&b6, domains, cidrs, true)
+	writeRestoreRules(&b6, domains, cidrs, dnsServers, true)
 
 	return []byte(b4.String()), []byte(b6.String())
 }
@@ -205,7 +291,7 @@ func buildFirewallRules(domains []resolvedEntry, cidrs []FirewallEntry) (v4, v6
 // synchronously — the sync pipeline uses resolveFirewallEntriesAsync instead.
 func generateFirewallRules(cfg *SandboxConfig) (v4, v6 []byte) {
 	domains, cidrs := resolveFirewallEntries(cfg)
-	return buildFirewallRules(domains, cidrs)
+	return buildFirewallRules(domains, cidrs, cfg.DNS.Servers)
 }
 
 // firewallConfigHash returns a deterministic hash of the firewall configuration
@@ -213,13 +299,16 @@ func generateFirewallRules(cfg *SandboxConfig) (v4, v6 []byte) {
 // skip check to work without network access.
 func firewallConfigHash(cfg *SandboxConfig) []byte {
 	h := sha256.New()
-	for _, e := range cfg.Firewall.Allow {
+	for _, e := range firewallAllow(cfg) {
 		h.Write([]byte(e.Domain))
 		h.Write([]byte(e.CIDR))
 		for _, p := range e.Ports {
 			fmt.Fprintf(h, "%d", p)
 		}
 	}
+	for _, s := range cfg.DNS.Servers {
+		fmt.Fprintf(h, "dns:%s", s)
+	}
 	// Include host tool port so changes trigger firewall re-sync.
 	if len(cfg.HostTools) > 0 {
 		fmt.Fprintf(h, "hosttool:%d", cfg.EffectiveHostToolPort())
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -11,7 +12,7 @@ func TestBuildFirewallRules(t *testing.T) {
 		domains := []resolvedEntry{
 			{v4: []string{"1.2.3.4"}, ports: []int{80, 443}},
 		}
-		v4, _ := buildFirewallRules(domains, nil)
+		v4, _ := buildFirewallRules(domains, nil, nil)
 		rules := string(v4)
 		if !strings.Contains(rules, "-A OUTPUT -d 1.2.3.4/32 -p tcp --dport 80 -j ACCEPT") {
 			t.Errorf("missing v4 port 80 rule:\n%s", rules)
@@ -25,7 +26,7 @@ func TestBuildFirewallRules(t *testing.T) {
 		domains := []resolvedEntry{
 			{v6: []string{"::1"}, ports: []int{443}},
 		}
-		_, v6 := buildFirewallRules(domains, nil)
+		_, v6 := buildFirewallRules(domains, nil, nil)
 		rules := string(v6)
 		if !strings.Contains(rules, "-A OUTPUT -d ::1/128 -p tcp --dport 443 -j ACCEPT") {
 			t.Errorf("missing v6 rule:\n%s", rules)
@@ -39,7 +40,7 @@ func TestBuildFirewallRules(t *testing.T) {
 		cidrs := []FirewallEntry{
 			{CIDR: "172.16.0.0/12"},
 		}
-		v4, _ := buildFirewallRules(domains, cidrs)
+		v4, _ := buildFirewallRules(domains, cidrs, nil)
 		rules := string(v4)
 		if !strings.Contains(rules, "-A OUTPUT -d 10.0.0.1/32 -p tcp --dport 443 -j ACCEPT") {
 			t.Errorf("missing domain rule:\n%s", rules)
@@ -53,7 +54,7 @@ func TestBuildFirewallRules(t *testing.T) {
 		domains := []resolvedEntry{
 			{v4: []string{"1.2.3.4"}, ports: []int{80}},
 		}
-		_, v6 := buildFirewallRules(domains, nil)
+		_, v6 := buildFirewallRules(domains, nil, nil)
 		rules := string(v6)
 		if strings.Contains(rules, "1.2.3.4") {
 			t.Errorf("v6 rules should not contain v4 address:\n%s", rules)
@@ -62,6 +63,8 @@ func TestBuildFirewallRules(t *testing.T) {
 }
 
 func TestResolveFirewallEntriesAsync(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
 	t.Run("resolves localhost and sends progress", func(t *testing.T) {
 		cfg := &SandboxConfig{
 			Firewall: FirewallConfig{
@@ -157,6 +160,29 @@ func TestResolveFirewallEntriesAsync(t *testing.T) {
 	})
 }
 
+func TestFirewallAllowShareTunnel(t *testing.T) {
+	cfg := &SandboxConfig{
+		Firewall: FirewallConfig{Allow: []FirewallEntry{{Domain: "example.com"}}},
+		Share: ShareConfig{
+			Via:    ShareTunnel,
+			Ports:  []int{3000},
+			Tunnel: &TunnelConfig{Host: "pair.example.com", Port: 2222},
+		},
+	}
+	want := []FirewallEntry{{Domain: "example.com"}, {Domain: "pair.example.com", Ports: []int{2222}}}
+	if got := firewallAllow(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("firewallAllow = %+v, want %+v", got, want)
+	}
+	if len(cfg.Firewall.Allow) != 1 {
+		t.Errorf("firewallAllow modified the configured allowlist: %+v", cfg.Firewall.Allow)
+	}
+
+	cfg.Share = ShareConfig{Via: ShareTailscale, Ports: []int{3000}}
+	if got := firewallAllow(cfg); len(got) != 1 {
+		t.Errorf("tailscale sharing should add no firewall entries, got %+v", got)
+	}
+}
+
 func TestFirewallConfigHash(t *testing.T) {
 	t.Run("same config produces same hash", func(t *testing.T) {
 		cfg := &SandboxConfig{
@@ -215,3 +241,75 @@ func TestFirewallConfigHash(t *testing.T) {
 		}
 	})
 }
+
+func TestFirewallAllowPrivateNetworks(t *testing.T) {
+	prev := dockerBridgeSubnets
+	t.Cleanup(func() { dockerBridgeSubnets = prev })
+
+	cfg := &SandboxConfig{Firewall: FirewallConfig{PrivateNetworks: true}}
+	dockerBridgeSubnets = func() []string { return []string{"172.17.0.0/16"} }
+	want := []FirewallEntry{{CIDR: "10.0.0.0/8"}, {CIDR: "172.16.0.0/12"}, {CIDR: "192.168.0.0/16"}}
+	if got := firewallAllow(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("firewallAllow = %+v, want %+v with the bridge inside 172.16.0.0/12", got, want)
+	}
+
+	dockerBridgeSubnets = func() []string { return []string{"100.64.0.0/24"} }
+	want = append(want, FirewallEntry{CIDR: "100.64.0.0/24"})
+	if got := firewallAllow(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("firewallAllow = %+v, want %+v", got, want)
+	}
+}
+
+func TestEgressReport(t *testing.T) {
+	save := `# Generated by iptables-save
+*filter
+:OUTPUT ACCEPT [0:0]
+[900:81000] -A OUTPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+[3:180] -A OUTPUT -o lo -j ACCEPT
+[4:240] -A OUTPUT -d 140.82.112.3/32 -p tcp -m tcp --dport 443 -j ACCEPT
+[1:60] -A OUTPUT -d 140.82.112.3/32 -p tcp -m tcp --dport 80 -j ACCEPT
+[2:120] -A OUTPUT -d 10.0.0.0/8 -j ACCEPT
+[5:300] -A OUTPUT -d 192.168.65.254/32 -p tcp -m tcp --dport 7777 -j ACCEPT
+[0:0] -A OUTPUT -d 151.101.0.223/32 -p tcp -m tcp --dport 443 -j ACCEPT
+[7:420] -A OUTPUT -j REJECT --reject-with icmp-port-unreachable
+COMMIT
+`
+	rules := parseRuleCounters(save)
+	allow := []FirewallEntry{{Domain: "github.com"}, {Domain: "pypi.org"}, {CIDR: "10.1.2.3/8"}}
+	cache := map[string]cachedResolution{
+		"github.com": {IPs: []string{"140.82.112.3"}},
+		"pypi.org":   {IPs: []string{"151.101.0.223"}},
+	}
+	report := egressReport(rules, allow, cache)
+
+	want := &EgressReport{
+		Entries: []EgressStat{
+			{Dest: "github.com", Connections: 5, Allowed: true},
+			{Dest: "192.168.65.254/32", Connections: 5},
+			{Dest: "10.1.2.3/8", Connections: 2, Allowed: true},
+			{Dest: "pypi.org", Connections: 0, Allowed: true},
+		},
+		Blocked: 7,
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("egressReport = %+v, want %+v", report, want)
+	}
+}
+
+func TestBuildFirewallRulesDNSServers(t *testing.T) {
+	v4, v6 := buildFirewallRules(nil, nil, []string{"10.0.0.2", "fd00::53"})
+	for _, want := range []string{
+		"-A OUTPUT -d 10.0.0.2/32 -p udp --dport 53 -j ACCEPT\n",
+		"-A OUTPUT -d 10.0.0.2/32 -p tcp --dport 53 -j ACCEPT\n",
+	} {
+		if !strings.Contains(string(v4), want) {
+			t.Errorf("v4 rules missing %q:\n%s", want, v4)
+		}
+	}
+	if strings.Contains(string(v4), "-A OUTPUT -p udp --dport 53") || strings.Contains(string(v4), "fd00::53") {
+		t.Errorf("v4 rules should only allow DNS to the v4 server:\n%s", v4)
+	}
+	if !strings.Contains(string(v6), "-A OUTPUT -d fd00::53/128 -p udp --dport 53 -j ACCEPT\n") || strings.Contains(string(v6), "10.0.0.2") {
+		t.Errorf("v6 rules should only allow DNS to the v6 server:\n%s", v6)
+	}
+}
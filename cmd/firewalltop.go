@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EgressStat is how many connections a sandbox has opened to one
+// firewall.allow entry, or to an address no entry accounts for.
+type EgressStat struct {
+	Dest        string `json:"dest"` // the entry's domain or CIDR, or an address
+	Connections int64  `json:"connections"`
+	Allowed     bool   `json:"allowed"` // an allow entry; false for other addresses the rules accept
+}
+
+// EgressReport is the firewall's counters for a sandbox, since its rules
+// were last applied.
+type EgressReport struct {
+	Entries []EgressStat `json:"entries"`
+	Blocked int64        `json:"blocked"` // connection attempts rejected
+}
+
+// ruleCounter is an OUTPUT rule's packet count, as iptables-save -c shows.
+type ruleCounter struct {
+	dest    string // canonical CIDR, "" for rules without a destination
+	target  string
+	packets int64
+}
+
+// FirewallTop reads the firewall's counters in the container and totals
+// the connections to each allow entry, busiest first, with unused entries
+// last. Packets of established connections are accepted before the
+// per-destination rules, so those count new connections only. Domain
+// entries are matched by the IPs they last resolved to on the host.
+func FirewallTop(container string, cfg *SandboxConfig) (*EgressReport, error) {
+	out, err := exec.Command("docker", "exec", "-u", "root", container, "iptables-save", "-c", "-t", "filter").Output()
+	if err != nil {
+		return nil, fmt.Errorf("read firewall counters: %w", err)
+	}
+	rules := parseRuleCounters(string(out))
+	// The IPv6 rules are optional, as Docker may have IPv6 off.
+	if out, err := exec.Command("docker", "exec", "-u", "root", container, "ip6tables-save", "-c", "-t", "filter").Output(); err == nil {
+		rules = append(rules, parseRuleCounters(string(out))...)
+	}
+	return egressReport(rules, firewallAllow(cfg), loadDNSCache()), nil
+}
+
+// egressReport attributes rules' counts to the allow entries they were
+// generated from. An address several domains resolved to counts for each.
+func egressReport(rules []ruleCounter, allow []FirewallEntry, cache map[string]cachedResolution) *EgressReport {
+	report := &EgressReport{}
+	byDest := make(map[string]int64)
+	var order []string
+	for _, r := range rules {
+		switch {
+		case r.target == "REJECT":
+			report.Blocked += r.packets
+		case r.target == "ACCEPT" && r.dest != "":
+			if _, ok := byDest[r.dest]; !ok {
+				order = append(order, r.dest)
+			}
+			byDest[r.dest] += r.packets
+		}
+	}
+
+	claimed := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, e := range allow {
+		label, dests := e.CIDR, []string{canonicalCIDR(e.CIDR)}
+		if e.Domain != "" {
+			label, dests = e.Domain, nil
+			for _, ip := range cache[e.Domain].IPs {
+				dests = append(dests, canonicalCIDR(ip))
+			}
+		}
+		if seen[label] {
+			continue
+		}
+		seen[label] = true
+		stat := EgressStat{Dest: label, Allowed: true}
+		for _, d := range dests {
+			if n, ok := byDest[d]; ok {
+				stat.Connections += n
+				claimed[d] = true
+			}
+		}
+		report.Entries = append(report.Entries, stat)
+	}
+	for _, d := range order {
+		if !claimed[d] {
+			report.Entries = append(report.Entries, EgressStat{Dest: d, Connections: byDest[d]})
+		}
+	}
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		return report.Entries[i].Connections > report.Entries[j].Connections
+	})
+	return report
+}
+
+// parseRuleCounters reads the OUTPUT rules of iptables-save -c output, whose
+// lines look like "[12:720] -A OUTPUT -d 1.2.3.4/32 -p tcp --dport 443 -j ACCEPT".
+func parseRuleCounters(out string) []ruleCounter {
+	var rules []ruleCounter
+	for _, line := range strings.Split(out, "\n") {
+		counts, rule, ok := strings.Cut(strings.TrimPrefix(line, "["), "] ")
+		if !ok || !strings.HasPrefix(line, "[") {
+			continue
+		}
+		fields := strings.Fields(rule)
+		if len(fields) < 2 || fields[0] != "-A" || fields[1] != "OUTPUT" {
+			continue
+		}
+		packets, _, _ := strings.Cut(counts, ":")
+		n, err := strconv.ParseInt(packets, 10, 64)
+		if err != nil {
+			continue
+		}
+		r := ruleCounter{packets: n}
+		for i := 2; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "-d":
+				r.dest = canonicalCIDR(fields[i+1])
+			case "-j":
+				r.target = fields[i+1]
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// canonicalCIDR writes an address or CIDR the way iptables-save shows it,
+// so rules and entries can be compared: a lone address gets a full-length
+// mask and host bits are cleared.
+func canonicalCIDR(s string) string {
+	if !strings.Contains(s, "/") {
+		if ip := net.ParseIP(s); ip != nil {
+			if ip.To4() != nil {
+				return ip.String() + "/32"
+			}
+			return ip.String() + "/128"
+		}
+		return s
+	}
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n.String()
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PortForward maps a port on the host's loopback interface to a port in
+// the container.
+type PortForward struct {
+	Container int
+	Host      int // 0 picks a free port
+}
+
+// ParsePortForward parses "<containerPort>[:hostPort]". Without a host
+// port, the container's port number is used on the host too.
+func ParsePortForward(spec string) (PortForward, error) {
+	cport, hport, hasHost := strings.Cut(spec, ":")
+	f := PortForward{}
+	var err error
+	if f.Container, err = strconv.Atoi(cport); err != nil || f.Container < 1 || f.Container > 65535 {
+		return f, fmt.Errorf("invalid container port in %q", spec)
+	}
+	f.Host = f.Container
+	if hasHost {
+		if f.Host, err = strconv.Atoi(hport); err != nil || f.Host < 0 || f.Host > 65535 {
+			return f, fmt.Errorf("invalid host port in %q", spec)
+		}
+	}
+	return f, nil
+}
+
+// Forward listens on 127.0.0.1 for each forward and relays connections to
+// the container until ctx is done, printing where each port can be reached
+// to out. Unlike ports published with docker run, forwards can be added to
+// a running container.
+func Forward(ctx context.Context, container string, forwards []PortForward, out io.Writer) error {
+	var listeners []net.Listener
+	ports := make([]int, len(forwards))
+	for i, f := range forwards {
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(f.Host))
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, l)
+		ports[i] = f.Container
+		fmt.Fprintf(out, "Forwarding %s to port %d\n", l.Addr(), f.Container)
+	}
+	relayListeners(ctx, container, listeners, ports)
+	return nil
+}
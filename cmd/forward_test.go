@@ -0,0 +1,31 @@
+package cmd
+
+import "testing"
+
+func TestParsePortForward(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    PortForward
+		wantErr bool
+	}{
+		{spec: "3000", want: PortForward{Container: 3000, Host: 3000}},
+		{spec: "3000:13000", want: PortForward{Container: 3000, Host: 13000}},
+		{spec: "5173:0", want: PortForward{Container: 5173, Host: 0}},
+		{spec: "0", wantErr: true},
+		{spec: "http", wantErr: true},
+		{spec: "3000:", wantErr: true},
+		{spec: "3000:70000", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePortForward(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePortForward(%q) = %+v, want error", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePortForward(%q) = %+v, %v; want %+v", tt.spec, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// GitConfig controls the git config sync writes into the container, so
+// commits made there carry the user's identity rather than the container's.
+type GitConfig struct {
+	Source            string   `yaml:"source,omitempty"`             // "host" (default) or "config"
+	Name              string   `yaml:"name,omitempty"`               // overrides the host's user.name
+	Email             string   `yaml:"email,omitempty"`              // overrides the host's user.email
+	CredentialHelpers []string `yaml:"credential_helpers,omitempty"` // set in addition to the host's safe ones
+}
+
+// Git config sources.
+const (
+	GitSourceHost   = "host"   // the host's global git config, then this section
+	GitSourceConfig = "config" // only this section
+)
+
+// gitConfigPath is where the derived config goes. Being the system config,
+// a .gitconfig in the home files overrides it rather than being replaced.
+// With hardening.readonly_rootfs it goes to readonlyGitConfigPath instead.
+const gitConfigPath = "/etc/gitconfig"
+
+func validateGit(g GitConfig) error {
+	switch g.Source {
+	case "", GitSourceHost, GitSourceConfig:
+	default:
+		return fmt.Errorf("invalid git.source %q, want %q or %q", g.Source, GitSourceHost, GitSourceConfig)
+	}
+	for _, v := range append([]string{g.Name, g.Email}, g.CredentialHelpers...) {
+		if strings.ContainsAny(v, "\n\r") {
+			return fmt.Errorf("git values can't contain newlines: %q", v)
+		}
+	}
+	return nil
+}
+
+// gitSigningKeys are copied from the host only with gpg.forward, since
+// signing needs the host's agent.
+var gitSigningKeys = []string{"user.signingkey", "commit.gpgsign", "tag.gpgsign"}
+
+// hostGitConfig returns the host's global git config value for key, or ""
+// if it isn't set. A variable so tests can avoid the host's config.
+var hostGitConfig = func(key string) string {
+	out, err := exec.Command("git", "config", "--global", "--includes", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// hostCredentialHelpers returns the host's global credential.helper values.
+var hostCredentialHelpers = func() []string {
+	out, err := exec.Command("git", "config", "--global", "--includes", "--get-all", "credential.helper").Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n")
+}
+
+// safeCredentialHelper reports whether a host credential helper can be
+// carried into the container. Only cache qualifies: helpers backed by the
+// host's keychain can't reach it from the container, store keeps
+// credentials in plain text, and anything else is a host program.
+func safeCredentialHelper(helper string) bool {
+	name, _, _ := strings.Cut(strings.TrimSpace(helper), " ")
+	return name == "cache"
+}
+
+// gitConfigFile builds the git config synced to gitConfigPath from cfg and,
+// unless git.source is "config", the host's global git config. It returns
+// nil when there is nothing to set.
+func gitConfigFile(cfg *SandboxConfig) []byte {
+	g := cfg.Git
+	values := make(map[string]string)
+	var helpers []string
+	if g.Source != GitSourceConfig {
+		keys := []string{"user.name", "user.email"}
+		if cfg.GPG.Forward {
+			if f := hostGitConfig("gpg.format"); f == "" || f == "openpgp" {
+				keys = append(keys, gitSigningKeys...)
+			}
+		}
+		for _, key := range keys {
+			if v := hostGitConfig(key); v != "" && !strings.ContainsAny(v, "\n\r") {
+				values[key] = v
+			}
+		}
+		for _, h := range hostCredentialHelpers() {
+			if safeCredentialHelper(h) {
+				helpers = append(helpers, strings.TrimSpace(h))
+			}
+		}
+	}
+	if g.Name != "" {
+		values["user.name"] = g.Name
+	}
+	if g.Email != "" {
+		values["user.email"] = g.Email
+	}
+	for _, h := range g.CredentialHelpers {
+		if !slices.Contains(helpers, h) {
+			helpers = append(helpers, h)
+		}
+	}
+	if len(values) == 0 && len(helpers) == 0 {
+		return nil
+	}
+
+	sections := make(map[string][]string)
+	for key, v := range values {
+		section, name, _ := strings.Cut(key, ".")
+		sections[section] = append(sections[section], fmt.Sprintf("\t%s = %s\n", name, gitQuote(v)))
+	}
+	for _, h := range helpers {
+		sections["credential"] = append(sections["credential"], fmt.Sprintf("\thelper = %s\n", gitQuote(h)))
+	}
+	names := make([]string, 0, len(sections))
+	for s := range sections {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# Written by sandbox sync; see git in the sandbox config.\n")
+	b.WriteString("# ~/.gitconfig overrides it; edits here are lost at the next sync.\n")
+	for _, s := range names {
+		lines := sections[s]
+		if s != "credential" {
+			sort.Strings(lines)
+		}
+		fmt.Fprintf(&b, "[%s]\n%s", s, strings.Join(lines, ""))
+	}
+	return []byte(b.String())
+}
+
+// gitQuote quotes v as a git config value.
+func gitQuote(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\t", `\t`).Replace(v) + `"`
+}
+
+// gitConfigItems returns the sync item for the container's git config, or
+// nil when there is nothing to set.
+func gitConfigItems(cfg *SandboxConfig) []SyncItem {
+	data := gitConfigFile(cfg)
+	if data == nil {
+		return nil
+	}
+	dest := gitConfigPath
+	if readonlyRootfs(cfg) {
+		dest = readonlyGitConfigPath
+	}
+	return []SyncItem{{Data: data, Dest: dest, Mode: "0644", Owner: "root:root"}}
+}
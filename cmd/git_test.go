@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// useHostGit stands in for the host's global git config.
+func useHostGit(t *testing.T, values map[string]string, helpers ...string) {
+	t.Helper()
+	origConfig, origHelpers := hostGitConfig, hostCredentialHelpers
+	hostGitConfig = func(key string) string { return values[key] }
+	hostCredentialHelpers = func() []string { return helpers }
+	t.Cleanup(func() { hostGitConfig, hostCredentialHelpers = origConfig, origHelpers })
+}
+
+func TestGitConfigFile(t *testing.T) {
+	host := map[string]string{
+		"user.name":       "Ada Lovelace",
+		"user.email":      "ada@example.com",
+		"user.signingkey": "ABCD1234",
+		"commit.gpgsign":  "true",
+	}
+
+	t.Run("host identity and safe helpers", func(t *testing.T) {
+		useHostGit(t, host, "osxkeychain", "cache --timeout=3600", "store", "!/opt/homebrew/bin/gh auth git-credential")
+		got := string(gitConfigFile(&SandboxConfig{}))
+		want := `# Written by sandbox sync; see git in the sandbox config.
+# ~/.gitconfig overrides it; edits here are lost at the next sync.
+[credential]
+	helper = "cache --timeout=3600"
+[user]
+	email = "ada@example.com"
+	name = "Ada Lovelace"
+`
+		if got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("signing with gpg.forward", func(t *testing.T) {
+		useHostGit(t, host)
+		got := string(gitConfigFile(&SandboxConfig{GPG: GPGConfig{Forward: true}}))
+		want := `[commit]
+	gpgsign = "true"
+[user]
+	email = "ada@example.com"
+	name = "Ada Lovelace"
+	signingkey = "ABCD1234"
+`
+		if !strings.HasSuffix(got, want) {
+			t.Errorf("got:\n%s\nwant it to end with:\n%s", got, want)
+		}
+	})
+
+	t.Run("config overrides and source config", func(t *testing.T) {
+		useHostGit(t, host, "cache")
+		cfg := &SandboxConfig{Git: GitConfig{Source: GitSourceConfig, Email: `bot "x"@example.com`, CredentialHelpers: []string{"cache"}}}
+		got := string(gitConfigFile(cfg))
+		want := `[credential]
+	helper = "cache"
+[user]
+	email = "bot \"x\"@example.com"
+`
+		if !strings.HasSuffix(got, want) {
+			t.Errorf("got:\n%s\nwant it to end with:\n%s", got, want)
+		}
+	})
+
+	t.Run("nothing to set", func(t *testing.T) {
+		useHostGit(t, nil)
+		if got := gitConfigItems(&SandboxConfig{}); got != nil {
+			t.Errorf("gitConfigItems = %v, want nil", got)
+		}
+	})
+}
+
+func TestGitConfigMergeAndValidate(t *testing.T) {
+	cfg := mergeConfig(
+		&SandboxConfig{Git: GitConfig{Name: "Ada", Email: "ada@example.com", CredentialHelpers: []string{"cache"}}},
+		&SandboxConfig{Git: GitConfig{Email: "ada@work.example", CredentialHelpers: []string{"cache --timeout=60"}}},
+	)
+	if cfg.Git.Name != "Ada" || cfg.Git.Email != "ada@work.example" || len(cfg.Git.CredentialHelpers) != 2 {
+		t.Errorf("merged git = %+v", cfg.Git)
+	}
+	if err := validateGit(GitConfig{Source: "laptop"}); err == nil {
+		t.Error("unknown source should be invalid")
+	}
+	if err := validateGit(GitConfig{Name: "Ada\n[core]"}); err == nil {
+		t.Error("a newline in a value should be invalid")
+	}
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// GPGConfig forwards the host's gpg-agent into the sandbox, so commits made
+// there can be signed with the host's keys without the secret keys ever
+// leaving the host.
+type GPGConfig struct {
+	Forward bool `yaml:"forward,omitempty"`
+}
+
+// In-container paths used by gpg forwarding.
+const (
+	gpgHome           = "/home/agent/.gnupg"
+	gpgRelayPath      = "/usr/local/bin/gpg-agent-relay"
+	gpgRelayTokenPath = "/tmp/sandbox-gpg-relay.token"
+	gpgPubringPath    = gpgHome + "/sandbox-pubring.gpg"
+	gpgOwnertrustPath = gpgHome + "/sandbox-ownertrust.txt"
+)
+
+// gpgSyncItems returns the relay script and the host's public keyring and
+// ownertrust for syncing, or nil when gpg.forward is off. Secret keys stay
+// in the host's agent, which the relay reaches through the host tool daemon.
+func gpgSyncItems(cfg *SandboxConfig) []SyncItem {
+	if !cfg.GPG.Forward {
+		return nil
+	}
+	var items []SyncItem
+	if !readonlyRootfs(cfg) {
+		// With a read-only root, the image's copy is used.
+		items = append(items, SyncItem{Data: gpgRelayScript, Dest: gpgRelayPath, Mode: "0755", Owner: "root:root"})
+	}
+	pub, err := exec.Command("gpg", "--batch", "--export").Output()
+	if err != nil {
+		Warnf(WarnSync, "export GPG public keys: %v; the sandbox won't know the host's keys", err)
+		return items
+	}
+	trust, _ := exec.Command("gpg", "--batch", "--export-ownertrust").Output()
+	return append(items,
+		SyncItem{Data: pub, Dest: gpgPubringPath, Mode: "0644", Owner: "agent:agent"},
+		SyncItem{Data: trust, Dest: gpgOwnertrustPath, Mode: "0644", Owner: "agent:agent"},
+	)
+}
+
+// importGPGKeyring imports the synced public keyring and ownertrust into
+// the agent user's keyring. Sync creates ~/.gnupg as root, so it is handed
+// to the agent user first.
+func importGPGKeyring(container string) error {
+	if out, err := exec.Command("docker", "exec", "-u", "root", container, "sh", "-c",
+		`chown agent:agent "$1" && chmod 700 "$1"`, "sh", gpgHome).CombinedOutput(); err != nil {
+		return fmt.Errorf("prepare %s: %v %s", gpgHome, err, strings.TrimSpace(string(out)))
+	}
+	const script = `[ ! -s "$1" ] || gpg --batch --quiet --no-autostart --import "$1" || exit
+[ ! -s "$2" ] || gpg --batch --quiet --import-ownertrust "$2"`
+	if out, err := exec.Command("docker", "exec", "-u", "agent", container, "sh", "-c", script,
+		"sh", gpgPubringPath, gpgOwnertrustPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("import GPG public keys: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// StartGPGRelay starts the container's gpg-agent relay if it isn't already
+// running, and returns the token it presents to the host tool daemon. The
+// token is created with the relay's first start and lasts as long as the
+// container.
+func StartGPGRelay(container string, port int) (string, error) {
+	const script = `umask 077
+[ -s "$1" ] || head -c 16 /dev/urandom | od -An -tx1 | tr -d ' \n' > "$1"
+cat "$1"`
+	out, err := exec.Command("docker", "exec", "-u", "agent", container, "sh", "-c", script,
+		"sh", gpgRelayTokenPath).Output()
+	if err != nil {
+		return "", fmt.Errorf("create gpg relay token: %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("create gpg relay token: empty token")
+	}
+	if out, err := exec.Command("docker", "exec", "-d", "-u", "agent",
+		"-e", "SANDBOX_HOSTTOOL_PORT="+strconv.Itoa(port),
+		container, gpgRelayPath).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("start gpg relay: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return token, nil
+}
+
+// AllowGPGAgent lets connections presenting token reach the host's
+// gpg-agent for as long as the session is registered.
+func AllowGPGAgent(port int, sessionID, token string) error {
+	return sendHostToolMessage(port, hostToolMessage{
+		Type:    "gpg-allow",
+		Session: sessionID,
+		Token:   token,
+	})
+}
+
+// gpgExtraSocket returns the host gpg-agent's extra socket, starting the
+// agent if needed. The extra socket is the one gpg-agent provides for
+// remote use: it refuses commands that export or delete secret keys.
+// A variable so tests can avoid GnuPG.
+var gpgExtraSocket = func() (string, error) {
+	if _, err := lookPath("gpgconf"); err != nil {
+		return "", fmt.Errorf("gpg forwarding needs GnuPG on the host: %w", err)
+	}
+	if out, err := exec.Command("gpgconf", "--launch", "gpg-agent").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("start gpg-agent: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	out, err := exec.Command("gpgconf", "--list-dirs", "agent-extra-socket").Output()
+	if err != nil {
+		return "", fmt.Errorf("find gpg-agent extra socket: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (d *HostToolDaemon) handleGPGAllow(conn net.Conn, msg hostToolMessage) {
+	d.mu.Lock()
+	sess, ok := d.sessions[msg.Session]
+	if ok {
+		sess.gpgToken = msg.Token
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		d.log.Printf("gpg-allow: unknown session %q", msg.Session)
+		json.NewEncoder(conn).Encode(hostToolResponse{ExitCode: 1, Output: fmt.Sprintf("unknown session %q", msg.Session)})
+		return
+	}
+	d.log.Printf("session %s forwards gpg-agent", msg.Session)
+	json.NewEncoder(conn).Encode(hostToolResponse{OK: true})
+}
+
+// gpgAllowed reports whether a registered session forwards gpg-agent to
+// relays presenting token.
+func (d *HostToolDaemon) gpgAllowed(token string) bool {
+	if token == "" {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, sess := range d.sessions {
+		if subtle.ConstantTimeCompare([]byte(sess.gpgToken), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGPGAgent connects conn to the host's gpg-agent extra socket once
+// the relay's token checks out, and copies between them until either side
+// closes. The relay waits for the response line before sending anything, so
+// nothing is left unread in handleConn's buffer.
+func (d *HostToolDaemon) handleGPGAgent(conn net.Conn, msg hostToolMessage) {
+	if !d.gpgAllowed(msg.Token) {
+		d.log.Printf("gpg-agent from %s: no session forwards gpg-agent to this relay", conn.RemoteAddr())
+		json.NewEncoder(conn).Encode(hostToolResponse{ExitCode: 1, Output: "gpg-agent forwarding is off for this sandbox"})
+		return
+	}
+	path, err := gpgExtraSocket()
+	if err != nil {
+		d.log.Printf("gpg-agent: %v", err)
+		json.NewEncoder(conn).Encode(hostToolResponse{ExitCode: 1, Output: err.Error()})
+		return
+	}
+	agent, err := net.Dial("unix", path)
+	if err != nil {
+		d.log.Printf("gpg-agent: %v", err)
+		json.NewEncoder(conn).Encode(hostToolResponse{ExitCode: 1, Output: "connect to gpg-agent: " + err.Error()})
+		return
+	}
+	defer agent.Close()
+	if err := json.NewEncoder(conn).Encode(hostToolResponse{OK: true}); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(agent, conn); done <- struct{}{} }()
+	go func() { io.Copy(conn, agent); done <- struct{}{} }()
+	<-done
+}
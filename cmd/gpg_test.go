@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeGPGAgent serves a socket that greets each connection like gpg-agent
+// and echoes the lines it receives, standing in for the extra socket.
+func fakeGPGAgent(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "S.gpg-agent.extra")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				fmt.Fprintln(conn, "OK Pleased to meet you")
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					fmt.Fprintln(conn, "OK "+s.Text())
+				}
+			}()
+		}
+	}()
+	orig := gpgExtraSocket
+	gpgExtraSocket = func() (string, error) { return path, nil }
+	t.Cleanup(func() { gpgExtraSocket = orig })
+}
+
+// dialGPGAgent asks the daemon for the agent with token, returning the
+// daemon's response and the connection for the agent protocol.
+func dialGPGAgent(t *testing.T, port int, token string) (hostToolResponse, *bufio.Reader, net.Conn) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	data, _ := json.Marshal(hostToolMessage{Type: "gpg-agent", Token: token})
+	conn.Write(append(data, '\n'))
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	var resp hostToolResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatalf("bad response %q: %v", line, err)
+	}
+	return resp, r, conn
+}
+
+func TestDaemonGPGAgent(t *testing.T) {
+	fakeGPGAgent(t)
+	port, _ := startTestDaemon(t)
+
+	if resp, _, _ := dialGPGAgent(t, port, "secret"); resp.OK {
+		t.Fatal("relay reached the agent before any session allowed it")
+	}
+	if err := AllowGPGAgent(port, "no-such-session", "secret"); err == nil {
+		t.Error("allowing an unknown session should fail")
+	}
+
+	if err := RegisterHostToolSession(port, "s1", nil, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AllowGPGAgent(port, "s1", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _, _ := dialGPGAgent(t, port, "wrong"); resp.OK {
+		t.Error("wrong token reached the agent")
+	}
+
+	resp, r, conn := dialGPGAgent(t, port, "secret")
+	if !resp.OK {
+		t.Fatalf("gpg-agent refused: %s", resp.Output)
+	}
+	if line, _ := r.ReadString('\n'); line != "OK Pleased to meet you\n" {
+		t.Errorf("greeting = %q", line)
+	}
+	fmt.Fprintln(conn, "GETINFO version")
+	if line, _ := r.ReadString('\n'); line != "OK GETINFO version\n" {
+		t.Errorf("reply = %q", line)
+	}
+
+	UnregisterHostToolSession(port, "s1")
+	if resp, _, _ := dialGPGAgent(t, port, "secret"); resp.OK {
+		t.Error("relay reached the agent after its session ended")
+	}
+}
+
+func TestGPGConfig(t *testing.T) {
+	cfg := mergeConfig(&SandboxConfig{GPG: GPGConfig{Forward: true}}, &SandboxConfig{})
+	if !cfg.GPG.Forward || !cfg.UsesHostToolDaemon() {
+		t.Errorf("global gpg.forward should carry through the merge: %+v", cfg.GPG)
+	}
+	if (&SandboxConfig{}).UsesHostToolDaemon() {
+		t.Error("an empty config shouldn't use the host tool daemon")
+	}
+	if items := gpgSyncItems(&SandboxConfig{}); items != nil {
+		t.Errorf("gpg.forward off should sync nothing, got %d items", len(items))
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// flagYes is the global --yes flag: it approves root on_sync hooks without
+// asking, and answers yes to commands' confirmations, for automation.
+var flagYes bool
+
+// AssumeYes reports whether --yes was given.
+func AssumeYes() bool { return flagYes }
+
+// RootHookPrompt asks whether to run a root on_sync hook that hasn't been
+// approved for the workspace at wsPath. It is nil when there is no one to
+// ask.
+var RootHookPrompt func(wsPath string, hook OnSyncHook) bool
+
+// rootHookApprovalsPath returns the file approved root hooks are kept in; a
+// variable so tests can keep it out of the user's cache directory. It is
+// kept on the host rather than in the workspace, so a repository can't
+// approve its own hooks.
+var rootHookApprovalsPath = func() (string, error) {
+	l, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Cache, "root-hooks.json"), nil
+}
+
+// rootHookHash identifies a root hook by its command, so editing the
+// command needs approving again.
+func rootHookHash(hook OnSyncHook) string {
+	sum := sha256.Sum256([]byte(hook.Cmd))
+	return hex.EncodeToString(sum[:])
+}
+
+// readRootHookApprovals returns the approved root hook hashes by workspace.
+func readRootHookApprovals() map[string][]string {
+	approvals := make(map[string][]string)
+	if path, err := rootHookApprovalsPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &approvals)
+		}
+	}
+	return approvals
+}
+
+// approveRootHook checks that a root hook may run in the workspace at
+// wsPath. Hooks approved there before may; otherwise --yes approves it for
+// this run, or RootHookPrompt asks and the answer is remembered if yes.
+func approveRootHook(wsPath string, hook OnSyncHook) error {
+	hash := rootHookHash(hook)
+	approvals := readRootHookApprovals()
+	if slices.Contains(approvals[wsPath], hash) || flagYes {
+		return nil
+	}
+	syncStatusDone()
+	if RootHookPrompt == nil || !RootHookPrompt(wsPath, hook) {
+		return errors.New("not approved to run as root in this workspace; approve it when asked on a terminal, or pass --yes")
+	}
+	approvals[wsPath] = append(approvals[wsPath], hash)
+	path, err := rootHookApprovalsPath()
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+	if err == nil {
+		data, _ := json.MarshalIndent(approvals, "", "  ")
+		err = os.WriteFile(path, append(data, '\n'), 0600)
+	}
+	if err != nil {
+		Warnf(WarnSync, "couldn't remember the approval of on_sync hook %q: %v", hook.Cmd, err)
+	}
+	return nil
+}
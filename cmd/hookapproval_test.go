@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useRootHookPrompt keeps approvals in a temp file and answers root hook
+// prompts with answer, returning a count of the times it was asked.
+func useRootHookPrompt(t *testing.T, answer bool) *int {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cache", "root-hooks.json")
+	origPath, origPrompt, origYes := rootHookApprovalsPath, RootHookPrompt, flagYes
+	asked := new(int)
+	rootHookApprovalsPath = func() (string, error) { return path, nil }
+	RootHookPrompt = func(string, OnSyncHook) bool { *asked++; return answer }
+	t.Cleanup(func() { rootHookApprovalsPath, RootHookPrompt, flagYes = origPath, origPrompt, origYes })
+	return asked
+}
+
+func TestApproveRootHook(t *testing.T) {
+	hook := OnSyncHook{Cmd: "apt-get install -y jq", Root: true}
+
+	t.Run("approval is remembered per workspace and command", func(t *testing.T) {
+		asked := useRootHookPrompt(t, true)
+		for range 2 {
+			if err := approveRootHook("/ws/a", hook); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if *asked != 1 {
+			t.Errorf("asked %d times, want once", *asked)
+		}
+		approveRootHook("/ws/b", hook)
+		approveRootHook("/ws/a", OnSyncHook{Cmd: "apt-get install -y curl", Root: true})
+		if *asked != 3 {
+			t.Errorf("asked %d times, want again for another workspace and command", *asked)
+		}
+		path, _ := rootHookApprovalsPath()
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("approvals file: %v", err)
+		}
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		useRootHookPrompt(t, false)
+		if err := approveRootHook("/ws/a", hook); err == nil {
+			t.Error("a declined hook should fail")
+		}
+		RootHookPrompt = nil
+		if err := approveRootHook("/ws/a", hook); err == nil {
+			t.Error("with no one to ask, the hook should fail")
+		}
+	})
+
+	t.Run("--yes approves without remembering", func(t *testing.T) {
+		asked := useRootHookPrompt(t, false)
+		flagYes = true
+		if err := approveRootHook("/ws/a", hook); err != nil || *asked != 0 {
+			t.Errorf("approveRootHook with --yes = %v after %d prompts", err, *asked)
+		}
+		if got := readRootHookApprovals(); len(got) != 0 {
+			t.Errorf("--yes recorded approvals %v", got)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// hostOnlyClaudeSettings are host settings that run host programs, so are
+// left out of the container's settings.
+var hostOnlyClaudeSettings = []string{"apiKeyHelper", "awsAuthRefresh", "awsCredentialExport"}
+
+// hostClaudeDir returns the host's Claude config directory: CLAUDE_CONFIG_DIR
+// if set, as Claude Code itself honours it, else ~/.claude.
+func hostClaudeDir() (string, error) {
+	if dir := os.Getenv("CLAUDE_CONFIG_DIR"); dir != "" {
+		return expandTilde(dir), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".claude"), nil
+}
+
+// hostClaudeSettings returns the host's settings.json without the settings
+// that only work on the host, or nil if there is none.
+func hostClaudeSettings() map[string]interface{} {
+	dir, err := hostClaudeDir()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "settings.json"))
+	if err != nil {
+		return nil
+	}
+	var settings map[string]interface{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		Warnf(WarnSync, "cannot parse host Claude settings: %v", err)
+		return nil
+	}
+	for _, k := range hostOnlyClaudeSettings {
+		delete(settings, k)
+	}
+	return settings
+}
+
+// hostClaudeItems returns sync items copying the host's global CLAUDE.md and
+// custom slash commands into the agent's ~/.claude. Destinations in have
+// (files from the sandbox home directory) are skipped, so those win without
+// a conflict warning.
+func hostClaudeItems(have map[string]bool) ([]SyncItem, error) {
+	dir, err := hostClaudeDir()
+	if err != nil {
+		return nil, nil
+	}
+	var items []SyncItem
+	add := func(path, rel string) error {
+		dest := "/home/agent/.claude/" + filepath.ToSlash(rel)
+		if have[dest] {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		items = append(items, SyncItem{Data: data, Dest: dest, Mode: "0644", Owner: "agent:agent", Source: path})
+		return nil
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, "CLAUDE.md")); err == nil && info.Mode().IsRegular() {
+		if err := add(filepath.Join(dir, "CLAUDE.md"), "CLAUDE.md"); err != nil {
+			return nil, err
+		}
+	}
+	commands := filepath.Join(dir, "commands")
+	if info, err := os.Stat(commands); err == nil && info.IsDir() {
+		err := filepath.Walk(commands, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			return add(path, rel)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// mergeSettings merges src into dst: nested objects are merged key by key,
+// anything else in src replaces the value in dst.
+func mergeSettings(dst, src map[string]interface{}) {
+	for k, v := range src {
+		sub, ok := v.(map[string]interface{})
+		existing, isMap := dst[k].(map[string]interface{})
+		if ok && isMap {
+			mergeSettings(existing, sub)
+			continue
+		}
+		dst[k] = v
+	}
+}
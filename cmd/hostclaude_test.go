@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeSettings(t *testing.T) {
+	dst := map[string]interface{}{
+		"model": "host",
+		"permissions": map[string]interface{}{
+			"allow": []interface{}{"Bash(ls)"},
+			"deny":  []interface{}{"Read(.env)"},
+		},
+	}
+	mergeSettings(dst, map[string]interface{}{
+		"model":       "sandbox",
+		"permissions": map[string]interface{}{"allow": []interface{}{"Bash(*)"}},
+	})
+	want := map[string]interface{}{
+		"model": "sandbox",
+		"permissions": map[string]interface{}{
+			"allow": []interface{}{"Bash(*)"},
+			"deny":  []interface{}{"Read(.env)"},
+		},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("merged = %v, want %v", dst, want)
+	}
+}
+
+func TestHostClaudeItems(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLAUDE_CONFIG_DIR", dir)
+	write := func(rel, content string) {
+		path := filepath.Join(dir, rel)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("CLAUDE.md", "be brief")
+	write("commands/review.md", "review it")
+	write("commands/git/pr.md", "open a PR")
+	write("settings.json", `{"apiKeyHelper": "op read x", "model": "opus"}`)
+
+	items, err := hostClaudeItems(map[string]bool{"/home/agent/.claude/commands/review.md": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, item := range items {
+		got[item.Dest] = string(item.Data)
+	}
+	want := map[string]string{
+		"/home/agent/.claude/CLAUDE.md":          "be brief",
+		"/home/agent/.claude/commands/git/pr.md": "open a PR",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+
+	settings := hostClaudeSettings()
+	if _, ok := settings["apiKeyHelper"]; ok {
+		t.Error("host-only apiKeyHelper should be dropped")
+	}
+	if settings["model"] != "opus" {
+		t.Errorf("model = %v, want opus", settings["model"])
+	}
+}
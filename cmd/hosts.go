@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// LabelHosts records the hosts entries a container was created with, as
+// "name=ip" joined with ",", so entries changed since can be pointed out.
+const LabelHosts = "sandbox.hosts"
+
+// HostEntry is an /etc/hosts line in the container, for an internal service
+// that isn't in public DNS. The firewall allows its IP on Ports, 80 and 443
+// if unset, as for a domain entry.
+type HostEntry struct {
+	Host  string `yaml:"host"`
+	IP    string `yaml:"ip"`
+	Ports []int  `yaml:"ports,omitempty"`
+}
+
+var hostNameRe = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+func validateHostEntry(h HostEntry) error {
+	if !hostNameRe.MatchString(h.Host) {
+		return fmt.Errorf("invalid hosts entry name %q", h.Host)
+	}
+	if ip := net.ParseIP(h.IP); ip == nil || ip.IsUnspecified() {
+		return fmt.Errorf("hosts entry %s has invalid ip %q", h.Host, h.IP)
+	}
+	for _, port := range h.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("hosts entry %s has invalid port %d", h.Host, port)
+		}
+	}
+	return nil
+}
+
+// hostsFirewallEntries returns the firewall entries allowing cfg's hosts
+// entries.
+func hostsFirewallEntries(cfg *SandboxConfig) []FirewallEntry {
+	var entries []FirewallEntry
+	for _, h := range cfg.Hosts {
+		ports := h.Ports
+		if len(ports) == 0 {
+			ports = []int{80, 443}
+		}
+		entries = append(entries, FirewallEntry{CIDR: h.IP, Ports: ports})
+	}
+	return entries
+}
+
+// hostsLabel describes cfg's hosts entries for LabelHosts.
+func hostsLabel(cfg *SandboxConfig) string {
+	if cfg == nil {
+		return ""
+	}
+	var pairs []string
+	for _, h := range cfg.Hosts {
+		pairs = append(pairs, h.Host+"="+h.IP)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// hostsArgs returns the docker run arguments adding cfg's hosts entries to
+// the container's /etc/hosts.
+func hostsArgs(cfg *SandboxConfig) []string {
+	if cfg == nil {
+		return nil
+	}
+	var args []string
+	for _, h := range cfg.Hosts {
+		args = append(args, "--add-host", h.Host+":"+h.IP)
+	}
+	return args
+}
+
+// warnIfHostsChanged prints a warning if the container was created with
+// other hosts entries than the config now has. Docker writes /etc/hosts
+// when a container is created.
+func warnIfHostsChanged(container, want string) {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelHosts+`"}}`, container).Output()
+	if err != nil {
+		return
+	}
+	if have := strings.TrimSpace(string(out)); have != want {
+		Warnf(WarnContainer, "hosts has changed since this sandbox was created. To apply it, run `sandbox rm <folder>` and then restart.")
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestMergeHosts(t *testing.T) {
+	base := &SandboxConfig{Hosts: []HostEntry{
+		{Host: "git.corp", IP: "10.0.0.3"},
+		{Host: "registry.corp", IP: "10.0.0.4"},
+	}}
+	override := &SandboxConfig{Hosts: []HostEntry{
+		{Host: "git.corp", IP: "10.0.9.3", Ports: []int{22, 443}},
+		{Host: "wiki.corp", IP: "10.0.0.5"},
+	}}
+	merged := mergeConfig(base, override)
+	want := []HostEntry{
+		{Host: "git.corp", IP: "10.0.9.3", Ports: []int{22, 443}},
+		{Host: "registry.corp", IP: "10.0.0.4"},
+		{Host: "wiki.corp", IP: "10.0.0.5"},
+	}
+	if !reflect.DeepEqual(merged.Hosts, want) {
+		t.Errorf("hosts = %+v, want %+v", merged.Hosts, want)
+	}
+}
+
+func TestHostsArgsAndFirewall(t *testing.T) {
+	cfg := &SandboxConfig{Hosts: []HostEntry{
+		{Host: "git.corp", IP: "10.0.0.3", Ports: []int{22}},
+		{Host: "wiki.corp", IP: "fd00::5"},
+	}}
+	wantArgs := []string{"--add-host", "git.corp:10.0.0.3", "--add-host", "wiki.corp:fd00::5"}
+	if args := hostsArgs(cfg); !slices.Equal(args, wantArgs) {
+		t.Errorf("hostsArgs = %q, want %q", args, wantArgs)
+	}
+	if label := hostsLabel(cfg); label != "git.corp=10.0.0.3,wiki.corp=fd00::5" {
+		t.Errorf("hostsLabel = %q", label)
+	}
+	want := []FirewallEntry{{CIDR: "10.0.0.3", Ports: []int{22}}, {CIDR: "fd00::5", Ports: []int{80, 443}}}
+	if got := firewallAllow(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("firewallAllow = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidateHostEntry(t *testing.T) {
+	if err := validateHostEntry(HostEntry{Host: "git.corp.example.com", IP: "10.0.0.3"}); err != nil {
+		t.Errorf("validateHostEntry = %v", err)
+	}
+	for _, bad := range []HostEntry{{Host: "-x", IP: "10.0.0.3"}, {Host: "a b", IP: "10.0.0.3"}, {Host: "x", IP: "0.0.0.0"}, {Host: "x", IP: "10.0.0.3", Ports: []int{70000}}} {
+		if validateHostEntry(bad) == nil {
+			t.Errorf("validateHostEntry(%+v) should fail", bad)
+		}
+	}
+}
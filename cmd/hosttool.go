@@ -21,14 +21,14 @@ import (
 
 // hostToolPidFile returns the path to the daemon PID file.
 func hostToolPidFile() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".sandbox", "daemon", "daemon.pid")
+	l, _ := ActiveLayout()
+	return filepath.Join(l.Cache, "daemon", "daemon.pid")
 }
 
 // hostToolLogFile returns the path to the daemon log file.
 func hostToolLogFile() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".sandbox", "daemon", "daemon.log")
+	l, _ := ActiveLayout()
+	return filepath.Join(l.Cache, "daemon", "daemon.log")
 }
 
 // GenerateSessionID returns a random 8-byte hex string.
@@ -41,11 +41,13 @@ func GenerateSessionID() string {
 // --- Protocol types ---
 
 type hostToolMessage struct {
-	Type    string     `json:"type"`              // "register", "execute", "unregister"
-	Session string     `json:"session"`           // session ID
-	Command string     `json:"command,omitempty"` // for execute
-	Tools   []HostTool `json:"tools,omitempty"`   // for register
-	Workdir string     `json:"workdir,omitempty"` // for register
+	Type    string       `json:"type"`              // "register", "execute", "unregister", "metric", "gpg-allow", "gpg-agent"
+	Session string       `json:"session"`           // session ID
+	Command string       `json:"command,omitempty"` // for execute
+	Tools   []HostTool   `json:"tools,omitempty"`   // for register
+	Workdir string       `json:"workdir,omitempty"` // for register
+	Metric  *metricEvent `json:"metric,omitempty"`  // for metric
+	Token   string       `json:"token,omitempty"`   // for gpg-allow and gpg-agent
 }
 
 type hostToolResponse struct {
@@ -59,6 +61,7 @@ type hostToolResponse struct {
 type sessionEntry struct {
 	commands map[string]string // name → cmd
 	workdir  string
+	gpgToken string // token the sandbox's gpg-agent relay presents, if forwarding
 }
 
 // --- Daemon ---
@@ -72,12 +75,18 @@ type HostToolDaemon struct {
 	cancel   context.CancelFunc
 	done     chan struct{} // closed when serve() returns
 	log      *log.Logger
+	metrics  *metricsRegistry
+	stayUp   bool // keep serving after the last session unregisters
 }
 
 // RunHostToolDaemon creates a TCP listener and serves until the context is
 // cancelled or the last session unregisters. This blocks and is intended to
 // be the main loop of the daemon process.
-func RunHostToolDaemon(ctx context.Context, port int) error {
+//
+// If metricsAddr is set it also serves Prometheus metrics at
+// http://<metricsAddr>/metrics, and keeps running with no sessions so the
+// metrics stay available.
+func RunHostToolDaemon(ctx context.Context, port int, metricsAddr string) error {
 	logFile := hostToolLogFile()
 	os.MkdirAll(filepath.Dir(logFile), 0755)
 	// Truncate if over 1 MB.
@@ -105,6 +114,16 @@ func RunHostToolDaemon(ctx context.Context, port int) error {
 		cancel:   cancel,
 		done:     make(chan struct{}),
 		log:      logger,
+		metrics:  newMetricsRegistry(),
+		stayUp:   metricsAddr != "",
+	}
+	if metricsAddr != "" {
+		if err := d.metrics.serveMetrics(ctx, metricsAddr); err != nil {
+			listener.Close()
+			cancel()
+			return fmt.Errorf("metrics: %w", err)
+		}
+		logger.Printf("serving metrics on %s/metrics", metricsAddr)
 	}
 
 	// Write PID file with binary mtime so clients can detect stale daemons.
@@ -159,6 +178,15 @@ func (d *HostToolDaemon) handleConn(ctx context.Context, conn net.Conn) {
 		d.handleExecute(ctx, conn, msg)
 	case "unregister":
 		d.handleUnregister(conn, msg)
+	case "gpg-allow":
+		d.handleGPGAllow(conn, msg)
+	case "gpg-agent":
+		d.handleGPGAgent(conn, msg)
+	case "metric":
+		if msg.Metric != nil {
+			d.metrics.record(*msg.Metric)
+		}
+		json.NewEncoder(conn).Encode(hostToolResponse{OK: true})
 	default:
 		d.log.Printf("unknown message type %q from %s", msg.Type, conn.RemoteAddr())
 		json.NewEncoder(conn).Encode(hostToolResponse{ExitCode: 1, Output: "unknown message type: " + msg.Type})
@@ -196,7 +224,7 @@ func (d *HostToolDaemon) handleUnregister(conn net.Conn, msg hostToolMessage) {
 
 	json.NewEncoder(conn).Encode(hostToolResponse{OK: true})
 
-	if remaining == 0 {
+	if remaining == 0 && !d.stayUp {
 		// Last session gone — shut down after a grace period so a quick
 		// restart doesn't have to re-launch the daemon.
 		go func() {
@@ -34,7 +34,7 @@ func startTestDaemon(t *testing.T) (int, context.CancelFunc) {
 		// Tiny race window: bind the port in RunHostToolDaemon.
 		// Signal ready once we know it's started (or errored).
 		close(ready)
-		RunHostToolDaemon(ctx, port)
+		RunHostToolDaemon(ctx, port, "")
 	}()
 	<-ready
 
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// defaultAgentID is the agent user's UID and GID when the host's can't be
+// used.
+const defaultAgentID = 1000
+
+// hostIDs returns the UID and GID the agent user should have, so files it
+// writes to the bind-mounted workspace belong to the invoking user. Root and
+// hosts without numeric IDs (Windows) get defaultAgentID, since the image
+// can't have a second user 0. A variable so tests can stand in for the host.
+var hostIDs = func() (uid, gid int) {
+	uid, gid = os.Getuid(), os.Getgid()
+	if uid <= 0 {
+		uid = defaultAgentID
+	}
+	if gid <= 0 {
+		gid = defaultAgentID
+	}
+	return uid, gid
+}
+
+// matchAgentIDsScript gives the agent user and group the UID and GID in $1
+// and $2 if they differ, then hands it back its home directory. usermod
+// refuses while the container's own process runs as agent, so the entries
+// are edited directly. It prints "changed" when it changed anything.
+const matchAgentIDsScript = `set -e
+uid=$1 gid=$2
+[ "$(id -u agent)" = "$uid" ] && [ "$(id -g agent)" = "$gid" ] && exit 0
+sed -i "s/^agent:\([^:]*\):[0-9]*:[0-9]*:/agent:\1:$uid:$gid:/" /etc/passwd
+sed -i "s/^agent:\([^:]*\):[0-9]*:/agent:\1:$gid:/" /etc/group
+chown -R -h agent:agent /home/agent
+echo changed`
+
+// matchAgentIDs makes the container's agent user match hostIDs. The image is
+// built with them, so this only does anything for a container from an image
+// built for another user, such as one shared between users of a Docker host.
+// Changing them takes a while, as everything in the home directory has to be
+// handed over.
+func matchAgentIDs(container string) error {
+	uid, gid := hostIDs()
+	out, err := exec.Command("docker", "exec", "-u", "root", container, "sh", "-c", matchAgentIDsScript,
+		"sh", strconv.Itoa(uid), strconv.Itoa(gid)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("match agent user to host user: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	if strings.HasSuffix(strings.TrimSpace(string(out)), "changed") {
+		Frontend.Note(fmt.Sprintf("Changed the sandbox's agent user to UID %d and GID %d to match yours", uid, gid))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchAgentIDsScript(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	os.Mkdir(bin, 0755)
+	// The image as built: agent is 1000:1000.
+	os.WriteFile(filepath.Join(bin, "id"), []byte("#!/bin/sh\necho 1000\n"), 0755)
+	os.WriteFile(filepath.Join(bin, "chown"), []byte("#!/bin/sh\necho \"chown $*\" >> "+filepath.Join(dir, "chowned")+"\n"), 0755)
+	passwd := "root:x:0:0:root:/root:/bin/bash\nagent:x:1000:1000::/home/agent:/bin/zsh\nagentx:x:1001:1001::/home/agentx:/bin/sh\n"
+	group := "root:x:0:\ndialout:x:20:\nagent:x:1000:\n"
+	os.WriteFile(filepath.Join(dir, "passwd"), []byte(passwd), 0644)
+	os.WriteFile(filepath.Join(dir, "group"), []byte(group), 0644)
+
+	script := strings.ReplaceAll(matchAgentIDsScript, "/etc/", dir+"/")
+	run := func(uid, gid string) string {
+		t.Helper()
+		c := exec.Command("sh", "-c", script, "sh", uid, gid)
+		c.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"))
+		out, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("script: %v %s", err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if out := run("1000", "1000"); out != "" {
+		t.Errorf("matching IDs printed %q, want nothing", out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "chowned")); err == nil {
+		t.Error("matching IDs shouldn't chown the home directory")
+	}
+
+	if out := run("501", "20"); out != "changed" {
+		t.Errorf("script printed %q, want changed", out)
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, "passwd"))
+	if want := strings.Replace(passwd, "agent:x:1000:1000:", "agent:x:501:20:", 1); string(got) != want {
+		t.Errorf("passwd =\n%s\nwant\n%s", got, want)
+	}
+	got, _ = os.ReadFile(filepath.Join(dir, "group"))
+	if want := strings.Replace(group, "agent:x:1000:", "agent:x:20:", 1); string(got) != want {
+		t.Errorf("group =\n%s\nwant\n%s", got, want)
+	}
+	if chowned, _ := os.ReadFile(filepath.Join(dir, "chowned")); string(chowned) != "chown -R -h agent:agent /home/agent\n" {
+		t.Errorf("chown calls = %q", chowned)
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed locales/*.yaml
+var localeFiles embed.FS
+
+// defaultLocale is the catalog every other falls back to.
+const defaultLocale = "en"
+
+var (
+	catalogOnce sync.Once
+	catalog     map[string]string
+)
+
+// Msg returns the message for key in the user's locale, formatted with args.
+// Keys missing from the locale fall back to English, and keys missing from
+// English are returned as-is so a gap shows up rather than an empty string.
+func Msg(key string, args ...any) string {
+	catalogOnce.Do(func() { catalog = loadCatalog(userLocales()) })
+	format, ok := catalog[key]
+	if !ok {
+		format = key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// userLocales returns the locales to try, most specific first, from
+// SANDBOX_LANG or the usual POSIX variables. "de_DE.UTF-8" yields de_DE and
+// then de.
+func userLocales() []string {
+	var lang string
+	for _, v := range []string{"SANDBOX_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if lang = os.Getenv(v); lang != "" {
+			break
+		}
+	}
+	lang, _, _ = strings.Cut(lang, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	if lang == "" || lang == "C" || lang == "POSIX" {
+		return nil
+	}
+	locales := []string{lang}
+	if base, _, ok := strings.Cut(lang, "_"); ok {
+		locales = append(locales, base)
+	}
+	return locales
+}
+
+// loadCatalog merges the English catalog with those for locales, least
+// specific first so the most specific wins.
+func loadCatalog(locales []string) map[string]string {
+	msgs := make(map[string]string)
+	mergeLocale(msgs, defaultLocale)
+	for i := len(locales) - 1; i >= 0; i-- {
+		if locales[i] != defaultLocale {
+			mergeLocale(msgs, locales[i])
+		}
+	}
+	return msgs
+}
+
+// mergeLocale adds a locale's messages to msgs: first the built-in catalog,
+// then any in the config directory's locales/, so teams can add or fix translations
+// without a new release.
+func mergeLocale(msgs map[string]string, locale string) {
+	if data, err := localeFiles.ReadFile("locales/" + locale + ".yaml"); err == nil {
+		parseLocale(msgs, data, locale)
+	}
+	l, err := ActiveLayout()
+	if err != nil {
+		return
+	}
+	path := filepath.Join(l.Config, "locales", locale+".yaml")
+	if data, err := os.ReadFile(path); err == nil {
+		parseLocale(msgs, data, path)
+	}
+}
+
+func parseLocale(msgs map[string]string, data []byte, name string) {
+	var m map[string]string
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		Warnf(WarnConfig, "locale %s: %v, skipping", name, err)
+		return
+	}
+	for k, v := range m {
+		msgs[k] = v
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestUserLocales(t *testing.T) {
+	for _, v := range []string{"SANDBOX_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		t.Setenv(v, "")
+	}
+	t.Setenv("LANG", "de_DE.UTF-8")
+	if got := strings.Join(userLocales(), ","); got != "de_DE,de" {
+		t.Errorf("LANG: locales = %q, want de_DE,de", got)
+	}
+	t.Setenv("SANDBOX_LANG", "fr")
+	if got := strings.Join(userLocales(), ","); got != "fr" {
+		t.Errorf("SANDBOX_LANG: locales = %q, want fr", got)
+	}
+	t.Setenv("SANDBOX_LANG", "C")
+	if got := userLocales(); got != nil {
+		t.Errorf("C locale: locales = %q, want none", got)
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".sandbox", "locales")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "de.yaml"), []byte(`sandbox.ready: "Sandbox bereit"`), 0644)
+	os.WriteFile(filepath.Join(dir, "de_AT.yaml"), []byte(`sandbox.ready: "Sandbox fertig"`), 0644)
+
+	msgs := loadCatalog([]string{"de_CH", "de"})
+	if got := msgs["sandbox.ready"]; got != "Sandbox bereit" {
+		t.Errorf("sandbox.ready = %q, want user translation", got)
+	}
+	if got := msgs["sandbox.syncing"]; got != "Syncing sandbox..." {
+		t.Errorf("sandbox.syncing = %q, want English fallback", got)
+	}
+	if got := loadCatalog([]string{"de_AT", "de"})["sandbox.ready"]; got != "Sandbox fertig" {
+		t.Errorf("sandbox.ready = %q, most specific locale should win", got)
+	}
+}
+
+// TestCatalogComplete checks every key passed to Msg in the source has an
+// English message, and that translations only use English keys with the same
+// format verbs.
+func TestCatalogComplete(t *testing.T) {
+	en := make(map[string]string)
+	data, _ := localeFiles.ReadFile("locales/en.yaml")
+	if err := yaml.Unmarshal(data, &en); err != nil {
+		t.Fatal(err)
+	}
+
+	keyRe := regexp.MustCompile(`Msg\("([^"]+)"`)
+	for _, dir := range []string{".", "commands"} {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+		for _, f := range files {
+			if strings.HasSuffix(f, "_test.go") {
+				continue
+			}
+			src, _ := os.ReadFile(f)
+			for _, m := range keyRe.FindAllStringSubmatch(string(src), -1) {
+				if _, ok := en[m[1]]; !ok {
+					t.Errorf("%s: message %q missing from locales/en.yaml", f, m[1])
+				}
+			}
+		}
+	}
+
+	verbRe := regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z]`)
+	verbs := func(s string) string {
+		var kinds []string
+		for _, v := range verbRe.FindAllString(s, -1) {
+			kinds = append(kinds, v[len(v)-1:])
+		}
+		return strings.Join(kinds, "")
+	}
+	fs.WalkDir(localeFiles, "locales", func(path string, d fs.DirEntry, err error) error {
+		if d.IsDir() || path == "locales/en.yaml" {
+			return nil
+		}
+		data, _ := localeFiles.ReadFile(path)
+		var msgs map[string]string
+		if err := yaml.Unmarshal(data, &msgs); err != nil {
+			t.Errorf("%s: %v", path, err)
+			return nil
+		}
+		for k, v := range msgs {
+			want, ok := en[k]
+			if !ok {
+				t.Errorf("%s: unknown key %q", path, k)
+				continue
+			}
+			if a, b := verbs(v), verbs(want); len(a) != len(b) {
+				t.Errorf("%s: %q uses verbs %q, English uses %q", path, k, a, b)
+			}
+		}
+		return nil
+	})
+}
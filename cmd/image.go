@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ImageConfig customises how the sandbox image is built. Honoured in the
+// global config only, as every sandbox shares the one image.
+type ImageConfig struct {
+	// BuildArgs are passed to docker build as --build-arg, e.g. GO_VERSION
+	// to install another Go release. Changing them rebuilds the image.
+	BuildArgs map[string]string `yaml:"build_args,omitempty"`
+
+	// Platform is what the image is built or pulled for, e.g. "linux/amd64"
+	// to run amd64-only tools under emulation on Apple Silicon. Default the
+	// Docker host's own.
+	Platform string `yaml:"platform,omitempty"`
+
+	// Pull is a registry image to pull instead of building one, e.g. one
+	// a team publishes for every architecture with `sandbox build --push`.
+	Pull string `yaml:"pull,omitempty"`
+
+	// Variant is VariantFull (the default) or VariantMinimal.
+	Variant string `yaml:"variant,omitempty"`
+
+	// Packages are apt packages installed on top of the image, e.g.
+	// imagemagick. Unlike the rest of image, a workspace can add to them.
+	Packages []string `yaml:"packages,omitempty"`
+
+	// CheckUpdates asks image.pull's registry, at most once a day, whether
+	// a newer image has been published since it was pulled.
+	CheckUpdates bool `yaml:"check_updates,omitempty"`
+}
+
+// Image variants. The minimal one leaves out Chromium, Rust and Ruby, for
+// workspaces that only need the rest.
+const (
+	VariantFull    = "full"
+	VariantMinimal = "minimal"
+)
+
+// set reports whether any of c is.
+func (c ImageConfig) set() bool {
+	return len(c.BuildArgs) > 0 || c.Platform != "" || c.Pull != "" || c.Variant != "" || len(c.Packages) > 0 || c.CheckUpdates
+}
+
+// globalOnly returns c without what a workspace may set.
+func (c ImageConfig) globalOnly() ImageConfig {
+	c.Packages = nil
+	return c
+}
+
+// reservedBuildArgs are set by sandbox or BuildKit, not the config.
+var reservedBuildArgs = []string{"HOST_UID", "HOST_GID", "TARGETARCH", "VARIANT"}
+
+var buildArgName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func validateBuildArg(name string) error {
+	if !buildArgName.MatchString(name) {
+		return fmt.Errorf("invalid image.build_args name %q", name)
+	}
+	if slices.Contains(reservedBuildArgs, name) {
+		return fmt.Errorf("image.build_args can't set %s, sandbox sets it", name)
+	}
+	return nil
+}
+
+// imagePlatformRe matches the platforms sandbox images can be for.
+var imagePlatformRe = regexp.MustCompile(`^linux/[a-z0-9]+(/v[0-9]+)?$`)
+
+func validateImagePlatform(p string) error {
+	if !imagePlatformRe.MatchString(p) {
+		return fmt.Errorf("invalid image.platform %q, want e.g. linux/amd64 or linux/arm64", p)
+	}
+	return nil
+}
+
+// aptPackageRe matches an apt package, optionally with a version.
+var aptPackageRe = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+(=[A-Za-z0-9.+~:-]+)?$`)
+
+func validateImagePackage(p string) error {
+	if !aptPackageRe.MatchString(p) {
+		return fmt.Errorf("invalid image.packages entry %q, want an apt package such as imagemagick", p)
+	}
+	return nil
+}
+
+func validateImageVariant(v string) error {
+	switch v {
+	case "", VariantFull, VariantMinimal:
+		return nil
+	}
+	return fmt.Errorf("invalid image.variant %q, want %s or %s", v, VariantFull, VariantMinimal)
+}
+
+func validateImage(c ImageConfig) error {
+	if c.Platform != "" {
+		if err := validateImagePlatform(c.Platform); err != nil {
+			return err
+		}
+	}
+	if err := validateImageVariant(c.Variant); err != nil {
+		return err
+	}
+	if strings.ContainsAny(c.Pull, " \t") {
+		return fmt.Errorf("invalid image.pull %q, want an image reference", c.Pull)
+	}
+	for _, p := range c.Packages {
+		if err := validateImagePackage(p); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(c.BuildArgs))
+	for name := range c.BuildArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := validateBuildArg(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageBuildArgs returns the global config's image.build_args, with tc's
+// versions over them, as docker build --build-arg values, sorted so they
+// hash the same every time. Invalid ones are skipped; loading the config
+// warns about them.
+func imageBuildArgs(tc ToolchainsConfig) []string {
+	values := make(map[string]string)
+	for name, value := range readGlobalSettings().Image.BuildArgs {
+		if validateBuildArg(name) == nil {
+			values[name] = value
+		}
+	}
+	if validateToolchains(tc) == nil {
+		for name, value := range tc.buildArgs() {
+			values[name] = value
+		}
+	}
+	var args []string
+	for name, value := range values {
+		args = append(args, name+"="+value)
+	}
+	sort.Strings(args)
+	return args
+}
+
+// BuildOptions control BuildImage.
+type BuildOptions struct {
+	// Verbose passes docker build's progress through in full, instead of
+	// summarising it as a status line.
+	Verbose bool
+
+	// Steps also prints a line for each Dockerfile step as it finishes,
+	// with how long it took or whether it was cached.
+	Steps bool
+
+	// NoCache rebuilds every layer rather than reusing Docker's build
+	// cache. BuildKit's cache mounts are kept.
+	NoCache bool
+
+	// PullBase pulls the latest base image the Dockerfile is built FROM.
+	PullBase bool
+
+	// Platforms overrides image.platform. Only a push can build several.
+	Platforms []string
+
+	// Variant overrides image.variant.
+	Variant string
+
+	// Toolchains pins the versions built in, tagging the image apart from
+	// the default one.
+	Toolchains ToolchainsConfig
+
+	// Packages are installed on top of the image, as an image of their
+	// own. A push leaves them out.
+	Packages []string
+
+	// Push builds with buildx for Platforms, by default pushPlatforms, and
+	// pushes the result to this registry reference instead of tagging a
+	// local image. The host's IDs aren't baked in, as sandboxes remap them.
+	Push string
+}
+
+// nativePlatform returns the Docker host's platform, e.g. "linux/arm64" on
+// Apple Silicon. A variable so tests can stand in for the host.
+var nativePlatform = sync.OnceValue(func() string {
+	out, err := exec.Command("docker", "version", "-f", "{{.Server.Os}}/{{.Server.Arch}}").Output()
+	if err != nil {
+		return "linux/amd64"
+	}
+	return strings.TrimSpace(string(out))
+})
+
+// ImagePlatform returns the platform the sandbox image is for: the global
+// config's image.platform, or else the Docker host's.
+func ImagePlatform() string {
+	if p := readGlobalSettings().Image.Platform; p != "" && validateImagePlatform(p) == nil {
+		return p
+	}
+	return nativePlatform()
+}
+
+// ImageVariant returns the global config's image.variant, VariantFull
+// unless it says otherwise.
+func ImageVariant() string {
+	if v := readGlobalSettings().Image.Variant; v != "" && validateImageVariant(v) == nil {
+		return v
+	}
+	return VariantFull
+}
+
+// imageRef returns the local tag of the sandbox image of variant for
+// platform with tc's versions, e.g. "sandbox:full-arm64", or
+// "sandbox:full-arm64-1f2e3d4c" with versions pinned. Each has its own, so
+// several images can sit side by side and switching image.variant,
+// image.platform or toolchains doesn't rebuild the other.
+func imageRef(variant, platform string, tc ToolchainsConfig) string {
+	arch := strings.TrimPrefix(platform, "linux/")
+	ref := imageName + ":" + variant + "-" + strings.ReplaceAll(arch, "/", "-")
+	if tag := tc.tag(); tag != "" {
+		ref += "-" + tag
+	}
+	return ref
+}
+
+// sandboxImageRef is the tag a sandbox with cfg is started from.
+func sandboxImageRef(cfg *SandboxConfig) string {
+	base := imageRef(ImageVariant(), ImagePlatform(), configToolchains(cfg))
+	if cfg != nil && len(cfg.Image.Packages) > 0 {
+		return packagesRef(base, cfg.Image.Packages)
+	}
+	return base
+}
+
+// packagesRef returns the tag of base with packages installed on top, e.g.
+// "sandbox:full-amd64-pkgs-5e6f7a8b". Sandboxes with the same packages
+// share it.
+func packagesRef(base string, packages []string) string {
+	return base + "-pkgs-" + sha256Hex([]byte(strings.Join(packages, "\n")))[:8]
+}
+
+// packagesDockerfile installs packages on top of base. apt's downloads
+// are a BuildKit cache mount, as in the sandbox image.
+func packagesDockerfile(base string, packages []string) string {
+	return fmt.Sprintf(`# syntax=docker/dockerfile:1
+FROM %s
+USER root
+RUN --mount=type=cache,target=/var/cache/apt,sharing=locked \
+    --mount=type=cache,target=/var/lib/apt/lists,sharing=locked \
+    apt-get update && apt-get install -y --no-install-recommends %s
+USER agent
+`, base, strings.Join(packages, " "))
+}
+
+// ensurePackages builds base with packages installed on top, as opts ask,
+// unless it is already built on the current base and force is false. The
+// image keeps base's sandbox.image.hash label, so a sandbox started from
+// it can still tell when base is out of date.
+func ensurePackages(base string, packages []string, opts BuildOptions, force bool) error {
+	id, err := exec.Command("docker", "inspect", "-f", "{{.Id}}", base).Output()
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", base, err)
+	}
+	ref := packagesRef(base, packages)
+	df := packagesDockerfile(base, packages)
+	hash := sha256Hex([]byte(strings.TrimSpace(string(id)) + "\n" + df))[:16]
+	if !force {
+		out, err := exec.Command("docker", "inspect", "-f",
+			`{{index .Config.Labels "sandbox.image.packages-hash"}}`, ref).Output()
+		if err == nil && strings.TrimSpace(string(out)) == hash {
+			return nil
+		}
+	}
+	Frontend.Info(Msg("image.packages", strings.Join(packages, ", ")))
+	args := []string{"build", "--progress=plain", "--platform", ImagePlatform(),
+		"--label", "sandbox.image.packages-hash=" + hash, "-t", ref}
+	// --pull would look for base in a registry; it is only ever local.
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	args = append(args, proxyBuildArgs()...)
+	cmd := exec.Command("docker", append(args, "-")...)
+	cmd.Stdin = strings.NewReader(df)
+	return runBuild(cmd, opts)
+}
+
+// ImagePull returns the global config's image.pull, or "" if sandbox builds
+// its image.
+func ImagePull() string {
+	return readGlobalSettings().Image.Pull
+}
+
+// BuildPackages rebuilds the image with opts' packages on top of the
+// already built or pulled sandbox image, for opts.Variant and image.platform.
+func BuildPackages(opts BuildOptions) error {
+	variant := opts.Variant
+	if variant == "" {
+		variant = ImageVariant()
+	}
+	return ensurePackages(imageRef(variant, ImagePlatform(), opts.Toolchains), opts.Packages, opts, true)
+}
+
+// PullImage pulls the global config's image.pull for platform and tags it
+// as the sandbox image for that platform. image.variant should say which
+// variant it is. Sandboxes that pin toolchains build their own instead.
+func PullImage(platform string) error {
+	ref := ImagePull()
+	if ref == "" {
+		return fmt.Errorf("image.pull isn't set in the global config")
+	}
+	pull := exec.Command("docker", "pull", "--platform", platform, ref)
+	pull.Stdout = Frontend.Stderr()
+	pull.Stderr = Frontend.Stderr()
+	if err := pull.Run(); err != nil {
+		return fmt.Errorf("docker pull %s: %w", ref, err)
+	}
+	if out, err := exec.Command("docker", "tag", ref, imageRef(ImageVariant(), platform, ToolchainsConfig{})).CombinedOutput(); err != nil {
+		return fmt.Errorf("docker tag: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// pushPlatforms are what `sandbox build --push` builds by default: what
+// Docker hosts on Intel and ARM machines, Apple Silicon included, run.
+var pushPlatforms = []string{"linux/amd64", "linux/arm64"}
@@ -8,6 +8,54 @@ set -euo pipefail
 # atomically via iptables-restore / ip6tables-restore.
 # ============================================================
 
+DNS_LOG=/var/log/sandbox-dns.log
+UPSTREAM_RESOLV=/opt/sandbox-upstream-resolv.conf
+
+# Start a caching stub resolver on 127.0.0.1 that forwards to the resolvers
+# Docker configured, logging every queried name. Images built before dnsmasq
+# was added simply keep using the upstream resolvers directly.
+start_dns_cache() {
+    command -v dnsmasq >/dev/null || return 1
+    if ! pgrep -x dnsmasq >/dev/null; then
+        # Docker regenerates resolv.conf on start, so re-capture upstreams
+        # unless it already points at us.
+        if ! grep -q '^nameserver 127\.0\.0\.1$' /etc/resolv.conf; then
+            grep '^nameserver' /etc/resolv.conf > "$UPSTREAM_RESOLV"
+        fi
+        [ -s "$UPSTREAM_RESOLV" ] || return 1
+        touch "$DNS_LOG"
+        chown dnsmasq "$DNS_LOG"
+        chmod 644 "$DNS_LOG"
+        dnsmasq --user=dnsmasq --listen-address=127.0.0.1 --bind-interfaces \
+            --resolv-file="$UPSTREAM_RESOLV" --cache-size=1000 \
+            --log-queries --log-facility="$DNS_LOG" || return 1
+    fi
+    # resolv.conf is bind-mounted by Docker, so rewrite it in place. With a
+    # read-only root it is mounted read-only, and lookups go upstream.
+    { grep -v '^nameserver' /etc/resolv.conf || true; echo "nameserver 127.0.0.1"; } > /tmp/resolv.conf.sandbox
+    if ! cat /tmp/resolv.conf.sandbox > /etc/resolv.conf 2>/dev/null; then
+        rm -f /tmp/resolv.conf.sandbox
+        return 1
+    fi
+    rm -f /tmp/resolv.conf.sandbox
+}
+
+# Restrict outbound DNS to the stub resolver, so every lookup is cached and
+# logged. Lookups from other processes still reach it over loopback. The
+# DNS rules may name servers (dns.servers), so each is rewritten in place
+# rather than replaced.
+pin_dns() {
+    local ipt=$1
+    "$ipt-save" -t filter \
+        | sed -E 's/^(-A OUTPUT (-d [^ ]+ )?-p (udp|tcp) -m (udp|tcp) --dport 53) -j ACCEPT$/\1 -m owner --uid-owner dnsmasq -j ACCEPT/' \
+        | "$ipt-restore"
+}
+
+DNS_CACHE=0
+if start_dns_cache; then
+    DNS_CACHE=1
+fi
+
 if [ -f /opt/sandbox-firewall-rules.sh ]; then
     iptables-restore < /opt/sandbox-firewall-rules.sh
 else
@@ -32,4 +80,9 @@ else
     ip6tables -A OUTPUT -j REJECT --reject-with icmp6-port-unreachable
 fi
 
+if [ "$DNS_CACHE" = 1 ]; then
+    pin_dns iptables
+    pin_dns ip6tables
+fi
+
 echo "Firewall initialized."
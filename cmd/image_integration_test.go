@@ -36,12 +36,15 @@ func startIntegrationContainer(t *testing.T) string {
 	// Remove any leftover from a previous failed run.
 	exec.Command("docker", "rm", "-f", name).Run()
 
-	// --cap-add NET_ADMIN is required for the entrypoint firewall (iptables).
-	out, err := exec.Command("docker", "run", "-d",
-		"--name", name,
-		"--cap-add", "NET_ADMIN",
-		"--security-opt", "no-new-privileges",
-		integrationImage).CombinedOutput()
+	// Run with the confinement real sandboxes get, which keeps NET_ADMIN
+	// for the entrypoint firewall (iptables).
+	secArgs, cleanup, err := securityArgs(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	args := append([]string{"run", "-d", "--name", name}, secArgs...)
+	out, err := exec.Command("docker", append(args, integrationImage)...).CombinedOutput()
 	if err != nil {
 		t.Fatalf("docker run: %v\n%s", err, out)
 	}
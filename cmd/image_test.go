@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestImageBuildArgs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".sandbox"), 0755)
+	write := func(config string) {
+		os.WriteFile(filepath.Join(home, ".sandbox", "config.yaml"), []byte(config), 0644)
+	}
+
+	write("env:\n  A: \"1\"\n")
+	before := ImageHash()
+	if args := imageBuildArgs(ToolchainsConfig{}); args != nil {
+		t.Errorf("imageBuildArgs = %q, want none", args)
+	}
+
+	write(`
+image:
+  build_args:
+    NODE_OPTIONS: --max-old-space-size=4096
+    GO_VERSION: "1.24.1"
+    HOST_UID: "0"
+`)
+	want := []string{"GO_VERSION=1.24.1", "NODE_OPTIONS=--max-old-space-size=4096"}
+	if args := imageBuildArgs(ToolchainsConfig{}); !slices.Equal(args, want) {
+		t.Errorf("imageBuildArgs = %q, want %q without the reserved HOST_UID", args, want)
+	}
+	if ImageHash() == before {
+		t.Error("changing image.build_args should change the image hash")
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	if err := validateImage(ImageConfig{BuildArgs: map[string]string{"GO_VERSION": "1.24.1", "_X": ""}}); err != nil {
+		t.Errorf("validateImage = %v", err)
+	}
+	for _, bad := range []string{"HOST_GID", "TARGETARCH", "1X", "A-B"} {
+		if validateImage(ImageConfig{BuildArgs: map[string]string{bad: "x"}}) == nil {
+			t.Errorf("validateImage(%q) should fail", bad)
+		}
+	}
+}
+
+func TestImageConfigIsGlobalOnly(t *testing.T) {
+	resetWarnings(t)
+	useRecordingUI(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(home, ".sandbox", "config.yaml"), []byte("image:\n  build_args:\n    GO_VERSION: \"1.24.1\"\n"), 0644)
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("image:\n  build_args:\n    GO_VERSION: \"1.20\"\n"), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Image.BuildArgs["GO_VERSION"]; got != "1.24.1" {
+		t.Errorf("GO_VERSION = %q, want the global 1.24.1", got)
+	}
+	if warningCount() != 1 {
+		t.Errorf("warnings = %d, want one about the workspace's image section", warningCount())
+	}
+}
+
+func TestImagePlatform(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".sandbox"), 0755)
+	prev := nativePlatform
+	nativePlatform = func() string { return "linux/arm64" }
+	t.Cleanup(func() { nativePlatform = prev })
+
+	if got := ImagePlatform(); got != "linux/arm64" {
+		t.Errorf("ImagePlatform = %q, want the host's linux/arm64", got)
+	}
+	if got := sandboxImageRef(nil); got != "sandbox:full-arm64" {
+		t.Errorf("sandboxImageRef = %q, want sandbox:full-arm64", got)
+	}
+	native := ImageHash()
+
+	os.WriteFile(filepath.Join(home, ".sandbox", "config.yaml"), []byte("image:\n  platform: linux/amd64\n"), 0644)
+	if got := ImagePlatform(); got != "linux/amd64" {
+		t.Errorf("ImagePlatform = %q, want the configured linux/amd64", got)
+	}
+	if ImageHash() == native {
+		t.Error("images for different platforms should hash differently")
+	}
+	if got := imageRef(VariantFull, "linux/arm/v7", ToolchainsConfig{}); got != "sandbox:full-arm-v7" {
+		t.Errorf("imageRef(linux/arm/v7) = %q", got)
+	}
+}
+
+func TestBuildImagePlatforms(t *testing.T) {
+	if err := BuildImage(BuildOptions{Platforms: []string{"linux/amd64", "linux/arm64"}}); err == nil {
+		t.Error("a local build for two platforms should fail")
+	}
+	if err := BuildImage(BuildOptions{Platforms: []string{"windows/amd64"}, Push: "registry.example/sandbox"}); err == nil {
+		t.Error("an unsupported platform should fail")
+	}
+}
+
+func TestImageVariant(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".sandbox"), 0755)
+	prev := nativePlatform
+	nativePlatform = func() string { return "linux/amd64" }
+	t.Cleanup(func() { nativePlatform = prev })
+
+	if got := ImageVariant(); got != VariantFull {
+		t.Errorf("ImageVariant = %q, want full by default", got)
+	}
+	full := ImageHash()
+
+	os.WriteFile(filepath.Join(home, ".sandbox", "config.yaml"), []byte("image:\n  variant: minimal\n"), 0644)
+	if got := sandboxImageRef(nil); got != "sandbox:minimal-amd64" {
+		t.Errorf("sandboxImageRef = %q, want sandbox:minimal-amd64", got)
+	}
+	if ImageHash() == full {
+		t.Error("the variants should hash differently")
+	}
+	if validateImage(ImageConfig{Variant: "tiny"}) == nil {
+		t.Error("validateImage should reject an unknown variant")
+	}
+	if BuildImage(BuildOptions{Variant: "tiny"}) == nil {
+		t.Error("BuildImage should reject an unknown variant")
+	}
+}
+
+func TestImagePackages(t *testing.T) {
+	resetWarnings(t)
+	useRecordingUI(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(home, ".sandbox", "config.yaml"), []byte("image:\n  packages: [jq, protobuf-compiler]\n"), 0644)
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("image:\n  packages: [imagemagick, jq, \"bad pkg\"]\n"), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"jq", "protobuf-compiler", "imagemagick"}
+	if !slices.Equal(cfg.Image.Packages, want) {
+		t.Errorf("packages = %q, want %q", cfg.Image.Packages, want)
+	}
+	if warningCount() != 1 {
+		t.Errorf("warnings = %d, want one about the invalid package only", warningCount())
+	}
+
+	ref := sandboxImageRef(cfg)
+	if base := sandboxImageRef(nil); !strings.HasPrefix(ref, base+"-pkgs-") {
+		t.Errorf("sandboxImageRef = %q, want %s with a packages tag", ref, base)
+	}
+	if ref == packagesRef(sandboxImageRef(nil), []string{"jq"}) {
+		t.Error("different packages should get different images")
+	}
+	df := packagesDockerfile("sandbox:full-amd64", want)
+	if !strings.Contains(df, "FROM sandbox:full-amd64\n") || !strings.Contains(df, "install -y --no-install-recommends jq protobuf-compiler imagemagick\n") {
+		t.Errorf("packagesDockerfile = %q", df)
+	}
+}
+
+func TestBuildProgress(t *testing.T) {
+	output := []string{
+		"#1 [internal] load build definition from Dockerfile",
+		"#1 DONE 0.0s",
+		"#5 [ 2/14] RUN apt-get update",
+		"#5 0.412 Get:1 http://deb.debian.org/debian bookworm InRelease",
+		"#5 DONE 12.3s",
+		"#6 [ 3/14] RUN curl -fsSL https://go.dev/dl/go1.23.6.linux-amd64.tar.gz",
+		"#6 CACHED",
+	}
+	for _, steps := range []bool{false, true} {
+		r := useRecordingUI(t)
+		p := &buildProgress{steps: steps, headers: make(map[string]string)}
+		for _, line := range output {
+			p.line(line)
+		}
+		var want []string
+		if steps {
+			want = []string{"[ 2/14] RUN apt-get update  12.3s", "[ 3/14] RUN curl -fsSL https://go.dev/dl/go1.23.6.linux-amd64.tar.gz  cached"}
+		}
+		if !slices.Equal(r.infos, want) {
+			t.Errorf("steps=%v: infos = %q, want %q", steps, r.infos, want)
+		}
+		if !slices.Contains(r.statuses, "Get:1 http://deb.debian.org/debian bookworm InRelease") {
+			t.Errorf("steps=%v: statuses = %q, want the step's output", steps, r.statuses)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// PrunedImage is a sandbox image PruneImages removed, or would have.
+type PrunedImage struct {
+	Image  string `json:"image"` // its tag, or ID if it has none
+	Size   string `json:"size"`
+	Reason string `json:"reason"` // "superseded" or "unused"
+	Error  string `json:"error,omitempty"`
+}
+
+// sandboxImage is a row of `docker images`.
+type sandboxImage struct {
+	ID, Repository, Tag, Size string
+}
+
+func (i sandboxImage) dangling() bool {
+	return i.Repository == "<none>" || i.Tag == "<none>"
+}
+
+func (i sandboxImage) ref() string {
+	if i.dangling() {
+		return i.ID
+	}
+	return i.Repository + ":" + i.Tag
+}
+
+var (
+	// baseImageTagRe matches the tags of sandbox images as built: a variant
+	// and architecture, e.g. "full-amd64".
+	baseImageTagRe = regexp.MustCompile(`^(full|minimal)-[a-z0-9]+(-v[0-9]+)?$`)
+	// derivedImageTagRe matches the tags of images built for the
+	// toolchains or image.packages of some sandboxes.
+	derivedImageTagRe = regexp.MustCompile(`-[0-9a-f]{8}$`)
+)
+
+// PruneImages removes sandbox images nothing needs: old builds a rebuild
+// left untagged, tags from older sandbox releases, and images built for
+// the toolchains or packages of workspaces that no sandbox uses any more.
+// Images an existing container was created from are kept, as are the
+// images for every variant and platform. With dryRun nothing is removed.
+func PruneImages(dryRun bool) ([]PrunedImage, error) {
+	images, err := listSandboxImages()
+	if err != nil {
+		return nil, err
+	}
+	used, err := containerImageIDs()
+	if err != nil {
+		return nil, err
+	}
+	prune := pruneCandidates(images, used, wantedImageRefs())
+	if dryRun {
+		return prune, nil
+	}
+	for i := range prune {
+		if out, err := exec.Command("docker", "rmi", prune[i].Image).CombinedOutput(); err != nil {
+			prune[i].Error = strings.TrimSpace(string(out))
+		}
+	}
+	return prune, nil
+}
+
+// pruneCandidates returns which of images to remove, tagged images first
+// so that old builds they were derived from can go too. used holds the
+// IDs of images containers were created from, and wanted the tags the
+// sandboxes of known workspaces would be started from.
+func pruneCandidates(images []sandboxImage, used, wanted map[string]bool) []PrunedImage {
+	var tagged, dangling []PrunedImage
+	for _, img := range images {
+		if used[img.ID] {
+			continue
+		}
+		switch {
+		case img.dangling():
+			dangling = append(dangling, PrunedImage{Image: img.ref(), Size: img.Size, Reason: "superseded"})
+		case derivedImageTagRe.MatchString(img.Tag):
+			if !wanted[img.ref()] {
+				tagged = append(tagged, PrunedImage{Image: img.ref(), Size: img.Size, Reason: "unused"})
+			}
+		case !baseImageTagRe.MatchString(img.Tag):
+			tagged = append(tagged, PrunedImage{Image: img.ref(), Size: img.Size, Reason: "superseded"})
+		}
+	}
+	return append(tagged, dangling...)
+}
+
+// listSandboxImages lists the images sandbox built or pulled, tagged or
+// not.
+func listSandboxImages() ([]sandboxImage, error) {
+	out, err := exec.Command("docker", "images", "--no-trunc",
+		"--filter", "label=sandbox.image.hash",
+		"--format", "{{.ID}}\t{{.Repository}}\t{{.Tag}}\t{{.Size}}").Output()
+	if err != nil {
+		return nil, dockerFailed(fmt.Errorf("list images: %w", err))
+	}
+	var images []sandboxImage
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) != 4 || (f[1] != imageName && f[1] != "<none>") {
+			continue
+		}
+		images = append(images, sandboxImage{ID: f[0], Repository: f[1], Tag: f[2], Size: f[3]})
+	}
+	return images, nil
+}
+
+// containerImageIDs returns the IDs of the images every container, sandbox
+// or not, was created from.
+func containerImageIDs() (map[string]bool, error) {
+	out, err := exec.Command("docker", "ps", "-aq").Output()
+	if err != nil {
+		return nil, dockerFailed(fmt.Errorf("list containers: %w", err))
+	}
+	ids := make(map[string]bool)
+	containers := strings.Fields(string(out))
+	if len(containers) == 0 {
+		return ids, nil
+	}
+	out, err = exec.Command("docker", append([]string{"inspect", "-f", "{{.Image}}"}, containers...)...).Output()
+	if err != nil {
+		return nil, dockerFailed(fmt.Errorf("inspect containers: %w", err))
+	}
+	for _, id := range strings.Fields(string(out)) {
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// wantedImageRefs returns the images the sandboxes of workspaces that
+// still exist would be started from, and the images those are built on.
+func wantedImageRefs() map[string]bool {
+	wanted := make(map[string]bool)
+	out, err := exec.Command("docker", "ps", "-a", "--filter", "label="+LabelSel,
+		"--format", `{{.Label "`+LabelWs+`"}}`).Output()
+	if err != nil {
+		return wanted
+	}
+	for _, ws := range strings.Fields(string(out)) {
+		if _, err := os.Stat(ws); err != nil {
+			continue
+		}
+		cfg, err := LoadConfig(ws)
+		if err != nil && !errors.Is(err, ErrNoConfig) {
+			continue
+		}
+		wanted[sandboxImageRef(cfg)] = true
+		wanted[imageRef(ImageVariant(), ImagePlatform(), configToolchains(cfg))] = true
+	}
+	return wanted
+}
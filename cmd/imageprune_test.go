@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPruneCandidates(t *testing.T) {
+	images := []sandboxImage{
+		{ID: "sha256:a", Repository: "sandbox", Tag: "full-amd64"},
+		{ID: "sha256:b", Repository: "sandbox", Tag: "minimal-arm-v7"},
+		{ID: "sha256:c", Repository: "sandbox", Tag: "latest"},
+		{ID: "sha256:d", Repository: "<none>", Tag: "<none>"},
+		{ID: "sha256:e", Repository: "<none>", Tag: "<none>"},
+		{ID: "sha256:f", Repository: "sandbox", Tag: "full-amd64-1f2e3d4c"},
+		{ID: "sha256:g", Repository: "sandbox", Tag: "full-amd64-pkgs-5e6f7a8b"},
+		{ID: "sha256:h", Repository: "sandbox", Tag: "full-amd64-1f2e3d4c-pkgs-00112233"},
+	}
+	used := map[string]bool{"sha256:e": true, "sha256:h": true}
+	wanted := map[string]bool{"sandbox:full-amd64-1f2e3d4c": true}
+
+	var got []string
+	for _, p := range pruneCandidates(images, used, wanted) {
+		got = append(got, p.Image+" "+p.Reason)
+	}
+	want := []string{
+		"sandbox:latest superseded",
+		"sandbox:full-amd64-pkgs-5e6f7a8b unused",
+		"sha256:d superseded",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("pruneCandidates = %q, want %q", got, want)
+	}
+}
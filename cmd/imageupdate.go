@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// updateCheckInterval is how often CheckUpdates asks the registry.
+const updateCheckInterval = 24 * time.Hour
+
+// usesPulledImage reports whether sandboxes with tc start from image.pull
+// rather than an image built here.
+func usesPulledImage(tc ToolchainsConfig) bool {
+	return ImagePull() != "" && tc == (ToolchainsConfig{})
+}
+
+// imageStale reports whether the container, of a sandbox with cfg, runs an
+// older image than it would be started from now: one since rebuilt or
+// pulled again, one whose inputs have changed since it was built, or with
+// image.check_updates, one superseded in its registry.
+func imageStale(container string, cfg *SandboxConfig) bool {
+	if imageOutdated(container, sandboxImageRef(cfg)) {
+		return true
+	}
+	tc := configToolchains(cfg)
+	if usesPulledImage(tc) {
+		return readGlobalSettings().Image.CheckUpdates && pulledImageSuperseded()
+	}
+	out, err := exec.Command("docker", "inspect", "-f",
+		`{{index .Config.Labels "sandbox.image.hash"}}`, container).Output()
+	if err != nil {
+		return false
+	}
+	have := strings.TrimSpace(string(out))
+	return have != "" && have != imageHash(ImageVariant(), ImagePlatform(), tc)
+}
+
+// warnIfStale prints a one-line notice if the container runs an older image
+// than the sandbox would now be started from.
+func warnIfStale(container string, cfg *SandboxConfig) {
+	if imageStale(container, cfg) {
+		Warnf(WarnContainer, "sandbox image out of date — run `sandbox upgrade`")
+	}
+}
+
+// registryDigest returns the digest image.pull's reference has in its
+// registry. A variable so tests can stand in for the registry.
+var registryDigest = func(ref string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "docker", "buildx", "imagetools", "inspect",
+		"--format", "{{.Manifest.Digest}}", ref).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// pulledDigests returns the registry digests the local image pulled from
+// image.pull has. A variable so tests can stand in for Docker.
+var pulledDigests = func() []string {
+	out, err := exec.Command("docker", "image", "inspect", "-f",
+		`{{join .RepoDigests "\n"}}`, imageRef(ImageVariant(), ImagePlatform(), ToolchainsConfig{})).Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(out))
+}
+
+// updateCheckPath is where the registry's last answer is kept, so it is
+// asked at most once per updateCheckInterval.
+func updateCheckPath() (string, error) {
+	l, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Cache, "image-update-check"), nil
+}
+
+// pulledImageSuperseded reports whether image.pull's registry has an image
+// other than the one last pulled. The registry's answer is reused for a
+// day; an unreachable registry counts as no update.
+func pulledImageSuperseded() bool {
+	path, err := updateCheckPath()
+	if err != nil {
+		return false
+	}
+	ref := ImagePull()
+	var digest string
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < updateCheckInterval {
+		// ref@digest, so a changed image.pull is asked about straight away.
+		data, _ := os.ReadFile(path)
+		if r, d, ok := strings.Cut(strings.TrimSpace(string(data)), "@"); ok && r == ref {
+			digest = d
+		}
+	}
+	if digest == "" {
+		d, err := registryDigest(ref)
+		if err != nil || d == "" {
+			return false
+		}
+		digest = d
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(ref+"@"+digest+"\n"), 0644)
+	}
+	for _, have := range pulledDigests() {
+		if strings.HasSuffix(have, "@"+digest) {
+			return false
+		}
+	}
+	return true
+}
+
+// UpgradeSandbox brings the image the workspace's sandbox starts from up
+// to date, building it or pulling image.pull again, and recreates the
+// sandbox from it if it runs an older one. A running sandbox is started
+// and synced again; a stopped one is left to start next time. It returns
+// whether the sandbox was recreated.
+func UpgradeSandbox(wsPath string) (bool, error) {
+	cfg, err := LoadConfig(wsPath)
+	if err != nil && !errors.Is(err, ErrNoConfig) {
+		return false, err
+	}
+	if usesPulledImage(configToolchains(cfg)) {
+		Frontend.Info(Msg("image.pulling", ImagePull(), ImagePlatform()))
+		if err := PullImage(ImagePlatform()); err != nil {
+			return false, dockerFailed(err)
+		}
+		// The registry's answer is stale now.
+		if path, err := updateCheckPath(); err == nil {
+			os.Remove(path)
+		}
+	}
+	if err := ensureImage(cfg); err != nil {
+		return false, dockerFailed(err)
+	}
+	name := SandboxContainer(wsPath)
+	if !ContainerExists(name) || !imageStale(name, cfg) {
+		return false, nil
+	}
+	running := IsRunning(name)
+	if err := RemoveContainer(name); err != nil {
+		return false, err
+	}
+	if running {
+		if _, err := EnsureRunning(wsPath); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPulledImageSuperseded(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(home, ".sandbox", "config.yaml"), []byte("image:\n  pull: ghcr.io/team/sandbox:latest\n  check_updates: true\n"), 0644)
+
+	asked, latest := 0, "sha256:new"
+	prevRegistry, prevPulled := registryDigest, pulledDigests
+	registryDigest = func(ref string) (string, error) {
+		asked++
+		return latest, nil
+	}
+	pulledDigests = func() []string { return []string{"ghcr.io/team/sandbox@sha256:old"} }
+	t.Cleanup(func() { registryDigest, pulledDigests = prevRegistry, prevPulled })
+
+	if !pulledImageSuperseded() {
+		t.Error("a new digest in the registry should supersede the pulled image")
+	}
+	latest = "sha256:old"
+	if !pulledImageSuperseded() || asked != 1 {
+		t.Errorf("asked the registry %d times, want its answer reused for a day", asked)
+	}
+
+	path, _ := updateCheckPath()
+	old := time.Now().Add(-2 * updateCheckInterval)
+	os.Chtimes(path, old, old)
+	if pulledImageSuperseded() || asked != 2 {
+		t.Errorf("after a day, want the registry asked again (asked %d times) and the image current", asked)
+	}
+
+	// A different image.pull is asked about straight away.
+	os.WriteFile(filepath.Join(home, ".sandbox", "config.yaml"), []byte("image:\n  pull: ghcr.io/other/sandbox:latest\n"), 0644)
+	pulledImageSuperseded()
+	if asked != 3 {
+		t.Errorf("asked the registry %d times, want 3 after image.pull changed", asked)
+	}
+}
@@ -48,6 +48,15 @@ func useTestImage(t *testing.T) {
 	t.Cleanup(func() { imageName = orig })
 }
 
+// testImageRef is the per-platform tag the code under test runs the test
+// image by, as useTestImage leaves imageRef.
+func testImageRef() string {
+	orig := imageName
+	defer func() { imageName = orig }()
+	imageName = testImageName
+	return sandboxImageRef(nil)
+}
+
 // buildTestImage builds a minimal alpine-based image for fast integration tests.
 func buildTestImage(t *testing.T) {
 	t.Helper()
@@ -59,7 +68,7 @@ func buildTestImage(t *testing.T) {
 
 	cmd := exec.Command("docker", "build",
 		"--label", "sandbox.image.hash="+ImageHash(),
-		"-t", testImageName, dir)
+		"-t", testImageName, "-t", testImageRef(), dir)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
@@ -67,7 +76,7 @@ func buildTestImage(t *testing.T) {
 	}
 
 	t.Cleanup(func() {
-		exec.Command("docker", "rmi", "-f", testImageName).Run()
+		exec.Command("docker", "rmi", "-f", testImageName, testImageRef()).Run()
 	})
 }
 
@@ -118,20 +127,20 @@ func TestImageExists(t *testing.T) {
 	useTestImage(t)
 
 	// Before building, image should not exist
-	if imageExists() {
+	if imageExists(ImageVariant(), ImagePlatform(), ToolchainsConfig{}) {
 		// Clean up stale test image
-		exec.Command("docker", "rmi", "-f", testImageName).Run()
+		exec.Command("docker", "rmi", "-f", testImageName, testImageRef()).Run()
 	}
-	if imageExists() {
-		t.Fatal("imageExists() = true before build")
+	if imageExists(ImageVariant(), ImagePlatform(), ToolchainsConfig{}) {
+		t.Fatal("imageExists(ImageVariant(), ImagePlatform(), ToolchainsConfig{}) = true before build")
 	}
 
 	// Build it
 	buildTestImage(t)
 
 	// Now it should exist
-	if !imageExists() {
-		t.Fatal("imageExists() = false after build")
+	if !imageExists(ImageVariant(), ImagePlatform(), ToolchainsConfig{}) {
+		t.Fatal("imageExists(ImageVariant(), ImagePlatform(), ToolchainsConfig{}) = false after build")
 	}
 }
 
@@ -422,7 +431,7 @@ func startHostToolDaemon(t *testing.T, tools []HostTool) (sessionID string, port
 	l.Close()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	go RunHostToolDaemon(ctx, port)
+	go RunHostToolDaemon(ctx, port, "")
 	t.Cleanup(cancel)
 
 	// Wait for daemon to be ready.
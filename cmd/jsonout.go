@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// flagJSON is the global --json flag: commands that support it print one
+// JSON result on stdout instead of text, for scripts and editors.
+var flagJSON bool
+
+// JSONOutput reports whether --json was given.
+func JSONOutput() bool { return flagJSON }
+
+// jsonOut is where JSON results go: stdout as it was before --json moved
+// everything else to stderr.
+var jsonOut io.Writer
+
+// jsonPrinted records that a result was printed, so a command that fails
+// after reporting its failure in it doesn't get a second, error-only one.
+var jsonPrinted bool
+
+// PrintJSON prints v as the command's JSON result.
+func PrintJSON(v any) error {
+	w := jsonOut
+	if w == nil {
+		w = Frontend.Stdout()
+	}
+	jsonPrinted = true
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// JSONError is the result of a command that failed with --json.
+type JSONError struct {
+	Error    string    `json:"error"`
+	Kind     ErrorKind `json:"kind,omitempty"`
+	ExitCode int       `json:"exit_code"`
+}
+
+// printJSONError prints err as the command's result, unless the command
+// already printed one.
+func printJSONError(err error) {
+	if jsonPrinted {
+		return
+	}
+	PrintJSON(JSONError{Error: Redact(err.Error()), Kind: ErrorKindOf(err), ExitCode: ExitCode(err)})
+}
+
+// jsonUI is Frontend under --json: progress and the output of commands run
+// along the way go to stderr, leaving stdout to the result.
+type jsonUI struct{ UI }
+
+func (u jsonUI) Info(msg string)   { u.Note(msg) }
+func (u jsonUI) Stdout() io.Writer { return u.UI.Stderr() }
+
+// useJSONOutput switches Frontend to jsonUI once flags are parsed.
+func useJSONOutput() {
+	if !flagJSON {
+		return
+	}
+	jsonOut = Frontend.Stdout()
+	Frontend = jsonUI{Frontend}
+}
+
+func init() {
+	cobra.OnInitialize(useJSONOutput)
+}
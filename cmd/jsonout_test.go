@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// useJSON turns on --json for the test, over a recordingUI.
+func useJSON(t *testing.T) *recordingUI {
+	t.Helper()
+	r := useRecordingUI(t)
+	flagJSON = true
+	t.Cleanup(func() { flagJSON, jsonOut, jsonPrinted = false, nil, false })
+	useJSONOutput()
+	return r
+}
+
+func TestJSONOutputKeepsStdoutForResult(t *testing.T) {
+	r := useJSON(t)
+
+	Frontend.Info("Starting sandbox")
+	fmt.Fprintln(Frontend.Stdout(), "docker output")
+	if err := PrintJSON(map[string]string{"sandbox": "sandbox-x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(r.out.Bytes(), &got); err != nil {
+		t.Fatalf("stdout isn't one JSON value: %v\n%s", err, r.out.String())
+	}
+	if got["sandbox"] != "sandbox-x" {
+		t.Errorf("result = %v", got)
+	}
+	if len(r.infos) != 0 || len(r.notes) != 1 || r.notes[0] != "Starting sandbox" {
+		t.Errorf("infos = %q, notes = %q, want progress as a note", r.infos, r.notes)
+	}
+	if !strings.Contains(r.err.String(), "docker output") {
+		t.Errorf("stderr = %q, want the command output", r.err.String())
+	}
+}
+
+func TestPrintJSONError(t *testing.T) {
+	isolateRedactions(t)
+	r := useJSON(t)
+	addRedaction("s3cret-token")
+
+	printJSONError(&Error{Kind: KindDocker, Err: fmt.Errorf("auth failed with s3cret-token")})
+	var got JSONError
+	if err := json.Unmarshal(r.out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ExitCode != ExitDocker || got.Kind != KindDocker || strings.Contains(got.Error, "s3cret") {
+		t.Errorf("error = %+v", got)
+	}
+
+	// A command that reported its failure in its result gets no second one.
+	r.out.Reset()
+	printJSONError(fmt.Errorf("again"))
+	if r.out.Len() != 0 {
+		t.Errorf("printed %q after a result", r.out.String())
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keychainService is the service name sandbox keys are stored under.
+const keychainService = "sandbox"
+
+// errKeyNotFound is returned by SecretStore.Get when no key is stored.
+var errKeyNotFound = errors.New("key not found")
+
+// SecretStore stores API keys in the host OS credential store, so keys never
+// sit in a plaintext file that could be lifted from a Docker volume.
+type SecretStore interface {
+	Set(account, secret string) error
+	Get(account string) (string, error)
+	Delete(account string) error
+}
+
+// secretStore is the store used by set-key and session env injection. Tests
+// replace it with an in-memory fake.
+var secretStore SecretStore = platformSecretStore()
+
+func platformSecretStore() SecretStore {
+	if runtime.GOOS == "darwin" {
+		return macKeychain{}
+	}
+	return secretService{}
+}
+
+// macKeychain stores secrets as generic passwords in the macOS login
+// keychain via the security CLI.
+type macKeychain struct{}
+
+func (macKeychain) Set(account, secret string) error {
+	// Feed the command through "security -i" on stdin so the secret doesn't
+	// appear in the process list as a -w argument would.
+	c := exec.Command("security", "-i")
+	c.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		shellQuote(keychainService), shellQuote(account), shellQuote(secret)))
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("keychain: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (macKeychain) Get(account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+			return "", errKeyNotFound
+		}
+		return "", fmt.Errorf("keychain: %w", err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (macKeychain) Delete(account string) error {
+	if err := exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", account).Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+			return errKeyNotFound
+		}
+		return fmt.Errorf("keychain: %w", err)
+	}
+	return nil
+}
+
+// secretService stores secrets via the freedesktop Secret Service (GNOME
+// Keyring, KWallet) using libsecret's secret-tool CLI.
+type secretService struct{}
+
+func (secretService) Set(account, secret string) error {
+	c := exec.Command("secret-tool", "store", "--label", "sandbox: "+account,
+		"service", keychainService, "account", account)
+	c.Stdin = strings.NewReader(secret)
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (secretService) Get(account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keychainService, "account", account).Output()
+	if err != nil {
+		// A missing secret-tool means nothing can have been stored.
+		if _, ok := err.(*exec.ExitError); ok || errors.Is(err, exec.ErrNotFound) {
+			return "", errKeyNotFound
+		}
+		return "", fmt.Errorf("secret-tool: %w", err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (secretService) Delete(account string) error {
+	if err := exec.Command("secret-tool", "clear", "service", keychainService, "account", account).Run(); err != nil {
+		return fmt.Errorf("secret-tool: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeyProvider describes an API credential that set-key can store.
+type KeyProvider struct {
+	Name            string            `yaml:"name"`
+	EnvVar          string            `yaml:"env_var"`                    // variable the key is injected as in sandbox sessions
+	KeyFile         string            `yaml:"key_file,omitempty"`         // optional container path the key is also written to on sync
+	ValidateURL     string            `yaml:"validate_url,omitempty"`     // optional URL that must answer 2xx for a valid key
+	ValidateHeaders map[string]string `yaml:"validate_headers,omitempty"` // request headers; "{key}" is replaced with the key
+}
+
+// builtinKeyProviders lists the credentials set-key knows about out of the
+// box, by name. Entries in the global config's key_providers are added to
+// these and replace built-ins with the same name.
+var builtinKeyProviders = map[string]KeyProvider{
+	"anthropic": {
+		Name:        "anthropic",
+		EnvVar:      "ANTHROPIC_API_KEY",
+		ValidateURL: "https://api.anthropic.com/v1/models",
+		ValidateHeaders: map[string]string{
+			"x-api-key":         "{key}",
+			"anthropic-version": "2023-06-01",
+		},
+	},
+	"openai": {
+		Name:            "openai",
+		EnvVar:          "OPENAI_API_KEY",
+		ValidateURL:     "https://api.openai.com/v1/models",
+		ValidateHeaders: map[string]string{"Authorization": "Bearer {key}"},
+	},
+	"gemini": {
+		Name:            "gemini",
+		EnvVar:          "GEMINI_API_KEY",
+		ValidateURL:     "https://generativelanguage.googleapis.com/v1beta/models",
+		ValidateHeaders: map[string]string{"x-goog-api-key": "{key}"},
+	},
+	"openrouter": {
+		Name:            "openrouter",
+		EnvVar:          "OPENROUTER_API_KEY",
+		ValidateURL:     "https://openrouter.ai/api/v1/key",
+		ValidateHeaders: map[string]string{"Authorization": "Bearer {key}"},
+	},
+	"github": {
+		Name:            "github",
+		EnvVar:          "GH_TOKEN",
+		ValidateURL:     "https://api.github.com/user",
+		ValidateHeaders: map[string]string{"Authorization": "Bearer {key}"},
+	},
+}
+
+// keyProviders returns the built-in providers merged with those configured in
+// cfg, by name. cfg may be nil.
+func keyProviders(cfg *SandboxConfig) map[string]KeyProvider {
+	providers := make(map[string]KeyProvider, len(builtinKeyProviders))
+	for name, p := range builtinKeyProviders {
+		providers[name] = p
+	}
+	if cfg != nil {
+		for _, p := range cfg.KeyProviders {
+			providers[p.Name] = p
+		}
+	}
+	return providers
+}
+
+// LookupKeyProvider returns the provider with the given name.
+func LookupKeyProvider(cfg *SandboxConfig, name string) (KeyProvider, error) {
+	providers := keyProviders(cfg)
+	p, ok := providers[name]
+	if !ok {
+		return KeyProvider{}, fmt.Errorf("unknown provider %q (known: %v)", name, keyProviderNames(providers))
+	}
+	return p, nil
+}
+
+func keyProviderNames(providers map[string]KeyProvider) []string {
+	names := make([]string, 0, len(providers))
+	for n := range providers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetKey stores a provider's key in the OS credential store and records when
+// it was stored.
+func SetKey(p KeyProvider, key string) error {
+	if err := secretStore.Set(p.Name, key); err != nil {
+		return err
+	}
+	meta := readKeyMeta()
+	meta[p.Name] = time.Now().UTC()
+	return writeKeyMeta(meta)
+}
+
+// StoredKey describes a stored key without its value.
+type StoredKey struct {
+	Provider string
+	EnvVar   string
+	Created  time.Time // zero if the key predates metadata tracking
+}
+
+// ListKeys returns the providers that have a stored key, sorted by name.
+func ListKeys(cfg *SandboxConfig) []StoredKey {
+	providers := keyProviders(cfg)
+	meta := readKeyMeta()
+	stored := storedKeys(cfg)
+	var list []StoredKey
+	for _, name := range keyProviderNames(providers) {
+		if _, ok := stored[name]; !ok {
+			continue
+		}
+		list = append(list, StoredKey{Provider: name, EnvVar: providers[name].EnvVar, Created: meta[name]})
+	}
+	return list
+}
+
+// RemoveKey deletes a provider's key from the credential store and removes
+// its key_file, if any, from running sandboxes. Sessions started afterwards
+// no longer receive it.
+func RemoveKey(p KeyProvider) error {
+	if err := secretStore.Delete(p.Name); err != nil {
+		if errors.Is(err, errKeyNotFound) {
+			return fmt.Errorf("no %s key is stored", p.Name)
+		}
+		return err
+	}
+	meta := readKeyMeta()
+	if _, ok := meta[p.Name]; ok {
+		delete(meta, p.Name)
+		if err := writeKeyMeta(meta); err != nil {
+			return err
+		}
+	}
+	if p.KeyFile == "" {
+		return nil
+	}
+	sandboxes, err := ListSandboxes()
+	if err != nil {
+		return err
+	}
+	dest := expandContainerTilde(p.KeyFile)
+	for _, s := range sandboxes {
+		if err := exec.Command("docker", "exec", "-u", "root", s.Name, "rm", "-f", dest).Run(); err != nil {
+			Warnf(WarnKeys, "cannot remove %s from %s: %v", dest, s.Name, err)
+		}
+	}
+	return nil
+}
+
+// keyMetaPath is where key creation times are recorded. It never holds key
+// values.
+func keyMetaPath() (string, error) {
+	l, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Config, "keys.json"), nil
+}
+
+// readKeyMeta returns recorded key creation times by provider. A missing or
+// unreadable file yields an empty map.
+func readKeyMeta() map[string]time.Time {
+	meta := make(map[string]time.Time)
+	path, err := keyMetaPath()
+	if err != nil {
+		return meta
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return meta
+	}
+	json.Unmarshal(data, &meta)
+	return meta
+}
+
+func writeKeyMeta(meta map[string]time.Time) error {
+	path, err := keyMetaPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("write key metadata: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write key metadata: %w", err)
+	}
+	return nil
+}
+
+// keyValidateTimeout bounds how long ValidateKey waits for the provider.
+const keyValidateTimeout = 10 * time.Second
+
+// ValidateKey checks key against the provider's validation URL. Providers
+// without one accept any key.
+func ValidateKey(p KeyProvider, key string) error {
+	if p.ValidateURL == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keyValidateTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.ValidateURL, nil)
+	if err != nil {
+		return fmt.Errorf("validate %s key: %w", p.Name, err)
+	}
+	for h, v := range p.ValidateHeaders {
+		req.Header.Set(h, strings.ReplaceAll(v, "{key}", key))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("validate %s key: %w", p.Name, err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s rejected the key (%s)", p.Name, resp.Status)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("validate %s key: %s returned %s", p.Name, p.ValidateURL, resp.Status)
+	}
+	return nil
+}
+
+// storedKeys returns the stored key for every provider that has one, by
+// provider name. Providers without a key are skipped; other lookup failures
+// are reported as warnings.
+func storedKeys(cfg *SandboxConfig) map[string]string {
+	providers := keyProviders(cfg)
+	keys := make(map[string]string)
+	for _, name := range keyProviderNames(providers) {
+		key, err := secretStore.Get(name)
+		if err != nil {
+			if !errors.Is(err, errKeyNotFound) {
+				Warnf(WarnKeys, "cannot read %s key: %v", name, err)
+			}
+			continue
+		}
+		addRedaction(key)
+		keys[name] = key
+	}
+	return keys
+}
+
+// storedKeyEnv returns env vars for every provider with a stored key, for
+// injection into sandbox sessions.
+func storedKeyEnv(cfg *SandboxConfig) map[string]string {
+	providers := keyProviders(cfg)
+	env := make(map[string]string)
+	for name, key := range storedKeys(cfg) {
+		env[providers[name].EnvVar] = key
+	}
+	return env
+}
+
+// keyProvidersSource marks sync items holding stored keys.
+const keyProvidersSource = "key_providers"
+
+// storedKeyFiles returns sync items writing stored keys to the key_file of
+// providers that set one. Files are readable only by the agent user.
+func storedKeyFiles(cfg *SandboxConfig) []SyncItem {
+	providers := keyProviders(cfg)
+	var items []SyncItem
+	for name, key := range storedKeys(cfg) {
+		p := providers[name]
+		if p.KeyFile == "" {
+			continue
+		}
+		items = append(items, SyncItem{
+			Data:   []byte(key + "\n"),
+			Dest:   expandContainerTilde(p.KeyFile),
+			Mode:   "0600",
+			Owner:  "agent:agent",
+			Source: keyProvidersSource,
+		})
+	}
+	return items
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memSecretStore is an in-memory SecretStore for tests.
+type memSecretStore map[string]string
+
+func (m memSecretStore) Set(account, secret string) error {
+	m[account] = secret
+	return nil
+}
+
+func (m memSecretStore) Get(account string) (string, error) {
+	v, ok := m[account]
+	if !ok {
+		return "", errKeyNotFound
+	}
+	return v, nil
+}
+
+func (m memSecretStore) Delete(account string) error {
+	if _, ok := m[account]; !ok {
+		return errKeyNotFound
+	}
+	delete(m, account)
+	return nil
+}
+
+// useMemSecretStore swaps the package secret store for an in-memory one and
+// points HOME at a temp dir so key metadata stays out of the real one.
+func useMemSecretStore(t *testing.T) memSecretStore {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	store := make(memSecretStore)
+	orig := secretStore
+	secretStore = store
+	t.Cleanup(func() { secretStore = orig })
+	return store
+}
+
+func TestLookupKeyProvider(t *testing.T) {
+	t.Run("built-in", func(t *testing.T) {
+		p, err := LookupKeyProvider(nil, "anthropic")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.EnvVar != "ANTHROPIC_API_KEY" {
+			t.Errorf("env var = %q, want ANTHROPIC_API_KEY", p.EnvVar)
+		}
+		for _, name := range []string{"openai", "gemini", "openrouter", "github"} {
+			if _, err := LookupKeyProvider(nil, name); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := LookupKeyProvider(nil, "nope"); err == nil {
+			t.Error("unknown provider should error")
+		}
+	})
+
+	t.Run("configured provider", func(t *testing.T) {
+		cfg := &SandboxConfig{KeyProviders: []KeyProvider{
+			{Name: "internal", EnvVar: "INTERNAL_TOKEN"},
+			{Name: "github", EnvVar: "GITHUB_TOKEN"},
+		}}
+		p, err := LookupKeyProvider(cfg, "internal")
+		if err != nil || p.EnvVar != "INTERNAL_TOKEN" {
+			t.Errorf("got (%+v, %v), want INTERNAL_TOKEN", p, err)
+		}
+		p, _ = LookupKeyProvider(cfg, "github")
+		if p.EnvVar != "GITHUB_TOKEN" {
+			t.Errorf("github env var = %q, config should override built-in", p.EnvVar)
+		}
+	})
+}
+
+func TestStoredKeyEnv(t *testing.T) {
+	t.Run("no keys stored", func(t *testing.T) {
+		useMemSecretStore(t)
+		if env := storedKeyEnv(nil); len(env) != 0 {
+			t.Errorf("env = %v, want empty", env)
+		}
+	})
+
+	t.Run("stored key injected", func(t *testing.T) {
+		store := useMemSecretStore(t)
+		p, _ := LookupKeyProvider(nil, "anthropic")
+		if err := SetKey(p, "sk-test"); err != nil {
+			t.Fatal(err)
+		}
+		if store["anthropic"] != "sk-test" {
+			t.Fatalf("store = %v, want anthropic key", store)
+		}
+		env := storedKeyEnv(nil)
+		if env["ANTHROPIC_API_KEY"] != "sk-test" {
+			t.Errorf("ANTHROPIC_API_KEY = %q, want sk-test", env["ANTHROPIC_API_KEY"])
+		}
+	})
+}
+
+func TestStoredKeyFiles(t *testing.T) {
+	store := useMemSecretStore(t)
+	store["internal"] = "tok"
+	store["anthropic"] = "sk-test"
+	cfg := &SandboxConfig{KeyProviders: []KeyProvider{
+		{Name: "internal", EnvVar: "INTERNAL_TOKEN", KeyFile: "~/.config/internal/token"},
+	}}
+
+	items := storedKeyFiles(cfg)
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1 (only providers with key_file)", len(items))
+	}
+	if items[0].Dest != "/home/agent/.config/internal/token" || items[0].Mode != "0600" {
+		t.Errorf("item = %+v, want 0600 file under /home/agent", items[0])
+	}
+	if string(items[0].Data) != "tok\n" {
+		t.Errorf("data = %q, want key", items[0].Data)
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	p := KeyProvider{
+		Name:            "test",
+		EnvVar:          "TEST_KEY",
+		ValidateURL:     srv.URL,
+		ValidateHeaders: map[string]string{"Authorization": "Bearer {key}"},
+	}
+
+	if err := ValidateKey(p, "good"); err != nil {
+		t.Errorf("good key: %v", err)
+	}
+	if err := ValidateKey(p, "bad"); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("bad key err = %v, want rejection", err)
+	}
+	if err := ValidateKey(KeyProvider{Name: "plain"}, "anything"); err != nil {
+		t.Errorf("provider without validate_url should accept any key: %v", err)
+	}
+}
+
+func TestListAndRemoveKeys(t *testing.T) {
+	store := useMemSecretStore(t)
+	store["github"] = "legacy" // stored before metadata existed
+
+	before := time.Now().Add(-time.Second)
+	p, _ := LookupKeyProvider(nil, "openai")
+	if err := SetKey(p, "sk-openai"); err != nil {
+		t.Fatal(err)
+	}
+
+	list := ListKeys(nil)
+	if len(list) != 2 || list[0].Provider != "github" || list[1].Provider != "openai" {
+		t.Fatalf("list = %+v, want github and openai", list)
+	}
+	if !list[0].Created.IsZero() {
+		t.Errorf("legacy key created = %v, want unknown", list[0].Created)
+	}
+	if list[1].Created.Before(before) || list[1].EnvVar != "OPENAI_API_KEY" {
+		t.Errorf("openai entry = %+v, want recent creation time", list[1])
+	}
+
+	if err := RemoveKey(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store["openai"]; ok {
+		t.Error("key still stored after RemoveKey")
+	}
+	if _, ok := readKeyMeta()["openai"]; ok {
+		t.Error("metadata still recorded after RemoveKey")
+	}
+	if err := RemoveKey(p); err == nil || !strings.Contains(err.Error(), "no openai key") {
+		t.Errorf("second remove err = %v, want not stored", err)
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The earlier ao-sandbox packaging named containers "ao-sandbox-<dir>" and
+// volumes "ao-sandbox-…", and labelled containers under "ao-sandbox.".
+// Containers from it are still managed, so upgrading doesn't leave a
+// workspace with two sandboxes.
+const (
+	legacyPrefix   = "ao-"
+	LegacyLabelSel = "ao-sandbox.managed=true"
+	LegacyLabelWs  = "ao-sandbox.workspace"
+)
+
+// legacyName returns what the ao-sandbox packaging called a container or
+// volume now named name, or "" for names it didn't generate.
+func legacyName(name string) string {
+	if !strings.HasPrefix(name, "sandbox-") {
+		return ""
+	}
+	return legacyPrefix + name
+}
+
+// IsLegacyContainer reports whether name is an ao-sandbox container.
+func IsLegacyContainer(name string) bool {
+	return strings.HasPrefix(name, legacyPrefix+"sandbox-")
+}
+
+// legacyNoted is set once the legacy container hint has been printed.
+var legacyNoted bool
+
+// legacyContainer returns the legacy ao-sandbox container made for wsPath,
+// or "" if there is none. Containers are named after the workspace's base
+// name, so one of another workspace with the same name, told apart by its
+// workspace label, doesn't count.
+func legacyContainer(wsPath string) string {
+	legacy := legacyName(ContainerName(wsPath))
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LegacyLabelWs+`"}}`, legacy).Output()
+	if err != nil || strings.TrimSpace(string(out)) != wsPath {
+		return ""
+	}
+	return legacy
+}
+
+// SandboxContainer returns the container for wsPath. That is
+// ContainerName(wsPath) unless only a legacy ao-sandbox container exists for
+// the workspace, in which case the legacy one is used.
+func SandboxContainer(wsPath string) string {
+	name := ContainerName(wsPath)
+	if ContainerExists(name) {
+		return name
+	}
+	legacy := legacyContainer(wsPath)
+	if legacy == "" {
+		return name
+	}
+	if !legacyNoted {
+		legacyNoted = true
+		Frontend.Note(fmt.Sprintf("note: using %s, created by an older version of sandbox. Run `sandbox migrate %s` to replace it.", legacy, filepath.Base(wsPath)))
+	}
+	return legacy
+}
+
+// adoptLegacyVolume copies a legacy credentials volume into vol before a new
+// container first mounts it, with the sandbox image, so stored logins survive
+// the upgrade. The legacy volume is left in place.
+func adoptLegacyVolume(vol, image string) error {
+	legacy := legacyName(vol)
+	if legacy == "" || volumeExists(vol) || !volumeExists(legacy) {
+		return nil
+	}
+	Frontend.Info(fmt.Sprintf("Copying credentials from %s to %s", legacy, vol))
+	out, err := exec.Command("docker", "run", "--rm", "-u", "root",
+		"-v", legacy+":/from:ro", "-v", vol+":/to",
+		"--entrypoint", "cp", image, "-a", "/from/.", "/to/").CombinedOutput()
+	if err != nil {
+		exec.Command("docker", "volume", "rm", vol).Run()
+		return fmt.Errorf("copy %s to %s: %s", legacy, vol, strings.TrimSpace(string(out)))
+	}
+	Frontend.Info(fmt.Sprintf("Once you're happy with the new sandbox, remove the old volume with `docker volume rm %s`", legacy))
+	return nil
+}
+
+func volumeExists(name string) bool {
+	return exec.Command("docker", "volume", "inspect", name).Run() == nil
+}
+
+// MigrateLegacySandbox replaces the legacy ao-sandbox container for wsPath
+// with a current one, carrying over its credentials volume. Anything
+// installed by hand in the old container is lost; its workspace files are
+// untouched.
+func MigrateLegacySandbox(wsPath string, out io.Writer) error {
+	name := ContainerName(wsPath)
+	legacy := legacyContainer(wsPath)
+	if legacy == "" {
+		return fmt.Errorf("no legacy sandbox for %s", wsPath)
+	}
+	if ContainerExists(name) {
+		return fmt.Errorf("both %s and legacy %s exist for %s; remove the one you don't want with `sandbox rm --name`", name, legacy, wsPath)
+	}
+	if IsRunning(legacy) {
+		if err := DockerRun("stop", legacy); err != nil {
+			return fmt.Errorf("stop %s: %w", legacy, err)
+		}
+	}
+	if err := DockerRun("rm", legacy); err != nil {
+		return fmt.Errorf("remove %s: %w", legacy, err)
+	}
+	fmt.Fprintf(out, "Removed %s\n", legacy)
+	if _, err := EnsureRunning(wsPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Sandbox %s running for %s\n", name, wsPath)
+	return nil
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLegacyName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"sandbox-myproj", "ao-sandbox-myproj"},
+		{"sandbox-myproj-creds", "ao-sandbox-myproj-creds"},
+		{"sandbox-creds", "ao-sandbox-creds"},
+		{"my-volume", ""},
+	}
+	for _, tt := range tests {
+		if got := legacyName(tt.name); got != tt.want {
+			t.Errorf("legacyName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if !IsLegacyContainer(legacyName(ContainerName("/src/app"))) {
+		t.Error("legacy container not recognised")
+	}
+	if IsLegacyContainer(ContainerName("/src/ao-sandbox-app")) {
+		t.Error("current container mistaken for a legacy one")
+	}
+}
+
+func TestFormatSandboxTableLegacy(t *testing.T) {
+	out := FormatSandboxTable([]SandboxStatus{
+		{Name: "ao-sandbox-a", Status: "Up 2 hours", Workspace: "/src/a", Legacy: true},
+	}, time.Now())
+	if !strings.Contains(out, "ao-sandbox-a (legacy)") {
+		t.Errorf("legacy sandbox not marked:\n%s", out)
+	}
+}
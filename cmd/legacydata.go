@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Answers to LegacyDataPrompt.
+const (
+	LegacyMove = "move" // move the files to the default layout
+	LegacyLink = "link" // symlink the default layout's directories to them
+	LegacyKeep = "keep" // keep using them and stop asking
+)
+
+// legacyKeepMarker in the legacy config directory records LegacyKeep.
+const legacyKeepMarker = ".no-migrate"
+
+// LegacyDataPrompt asks what to do when sandbox's files are only in a
+// layout other than the build's default, such as ~/.sandbox under a package
+// built for XDG, returning one of the Legacy answers or "" to decide later.
+// It is nil when there is no one to ask.
+var LegacyDataPrompt func(legacy, target DataLayout) string
+
+// CheckLegacyData looks for files outside the build's default layout. If
+// another layout is in use besides the active one and the two configs
+// differ it warns, since only the active one is read. If the active layout
+// isn't the default and the default has no files, it offers, through
+// LegacyDataPrompt, to move or symlink them there. Only `sandbox
+// migrate-data` runs it, so other commands never stop to ask.
+func CheckLegacyData() {
+	layouts, err := DataLayouts()
+	if err != nil {
+		return
+	}
+	active, err := ActiveLayout()
+	if err != nil {
+		return
+	}
+	activeCfg := filepath.Join(active.Config, "config.yaml")
+	for _, l := range layouts {
+		if l.Name == active.Name || !l.InUse() {
+			continue
+		}
+		if cfg := filepath.Join(l.Config, "config.yaml"); filesDiffer(cfg, activeCfg) {
+			Warnf(WarnConfig, "%s and %s both exist and differ; only %s is read. Remove or merge the other one.", cfg, activeCfg, activeCfg)
+		}
+	}
+
+	legacy := active
+	target, err := LookupLayout(defaultLayout)
+	if err != nil || legacy.Name == target.Name || target.InUse() {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(legacy.Config, legacyKeepMarker)); err == nil {
+		return
+	}
+	answer := ""
+	if LegacyDataPrompt != nil {
+		answer = LegacyDataPrompt(legacy, target)
+	}
+	switch answer {
+	case LegacyMove:
+		err = MigrateData(legacy, target, false, Frontend.Stderr())
+	case LegacyLink:
+		err = LinkData(legacy, target)
+	case LegacyKeep:
+		err = os.WriteFile(filepath.Join(legacy.Config, legacyKeepMarker), nil, 0644)
+	default:
+		Frontend.Note(fmt.Sprintf("note: using %s, outside this build's default layout. Run `sandbox migrate-data %s` to move sandbox's files there.", legacy.Config, target.Name))
+	}
+	if err != nil {
+		Warnf(WarnConfig, "%v; still using %s", err, legacy.Config)
+	}
+}
+
+// filesDiffer reports whether a and b both exist with different contents.
+func filesDiffer(a, b string) bool {
+	da, errA := os.ReadFile(a)
+	db, errB := os.ReadFile(b)
+	return errA == nil && errB == nil && !bytes.Equal(da, db)
+}
+
+// LinkData points to's directories at from's files with symlinks, so both
+// layouts find the same files. None of to's directories may exist, other
+// than empty ones.
+func LinkData(from, to DataLayout) error {
+	links := [][2]string{{to.Config, from.Config}}
+	if to.Home != filepath.Join(to.Config, "home") {
+		links = append(links, [2]string{to.Home, from.Home})
+	}
+	if to.Cache != to.Config {
+		links = append(links, [2]string{filepath.Join(to.Cache, "daemon"), filepath.Join(from.Cache, "daemon")})
+	}
+	for _, l := range links {
+		link, target := l[0], l[1]
+		// Link targets must exist, or later writes through them fail.
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		os.Remove(link) // only succeeds if it is an empty directory
+		if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+			return err
+		}
+		if err := os.Symlink(target, link); err != nil {
+			return fmt.Errorf("link %s: %w", link, err)
+		}
+		Frontend.Note(fmt.Sprintf("Linked %s -> %s", link, target))
+	}
+	return nil
+}
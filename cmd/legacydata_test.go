@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckLegacyData(t *testing.T) {
+	setup := func(t *testing.T, answer string) (legacy DataLayout, asked *int) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("XDG_DATA_HOME", "")
+		t.Setenv("XDG_CACHE_HOME", "")
+		origDefault := defaultLayout
+		defaultLayout = LayoutXDG
+		legacy, _ = LookupLayout(LayoutSandbox)
+		os.MkdirAll(legacy.Home, 0755)
+		os.WriteFile(filepath.Join(legacy.Config, "config.yaml"), []byte("env:\n  FROM: legacy\n"), 0644)
+
+		asked = new(int)
+		origPrompt := LegacyDataPrompt
+		LegacyDataPrompt = func(_, _ DataLayout) string {
+			*asked++
+			return answer
+		}
+		t.Cleanup(func() {
+			LegacyDataPrompt = origPrompt
+			defaultLayout = origDefault
+		})
+		return legacy, asked
+	}
+
+	t.Run("move", func(t *testing.T) {
+		legacy, _ := setup(t, LegacyMove)
+		CheckLegacyData()
+		cfg, err := LoadGlobalConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Env["FROM"] != "legacy" {
+			t.Errorf("env = %v", cfg.Env)
+		}
+		if l, _ := ActiveLayout(); l.Name != LayoutXDG {
+			t.Errorf("active layout = %s, want xdg", l.Name)
+		}
+		if legacy.InUse() {
+			t.Error("legacy files left behind")
+		}
+	})
+
+	t.Run("link", func(t *testing.T) {
+		legacy, _ := setup(t, LegacyLink)
+		CheckLegacyData()
+		xdg, _ := LookupLayout(LayoutXDG)
+		if target, err := os.Readlink(xdg.Config); err != nil || target != legacy.Config {
+			t.Errorf("%s links to %q (%v), want %s", xdg.Config, target, err, legacy.Config)
+		}
+		// Both layouts now see the same files, which isn't a conflict.
+		CheckLegacyData()
+		if path, _ := GlobalConfigPath(); path != filepath.Join(legacy.Config, "config.yaml") {
+			t.Errorf("GlobalConfigPath() = %s", path)
+		}
+	})
+
+	t.Run("keep stops asking", func(t *testing.T) {
+		legacy, asked := setup(t, LegacyKeep)
+		CheckLegacyData()
+		CheckLegacyData()
+		if *asked != 1 {
+			t.Errorf("asked %d times, want 1", *asked)
+		}
+		if l, _ := ActiveLayout(); l.Name != LayoutSandbox {
+			t.Errorf("active layout = %s, want sandbox", l.Name)
+		}
+		if _, err := os.Stat(filepath.Join(legacy.Config, legacyKeepMarker)); err != nil {
+			t.Error("keep marker not written")
+		}
+	})
+
+	t.Run("later leaves files", func(t *testing.T) {
+		legacy, asked := setup(t, "")
+		CheckLegacyData()
+		if *asked != 1 {
+			t.Errorf("asked %d times, want 1", *asked)
+		}
+		if l, _ := ActiveLayout(); l.Name != LayoutSandbox || !legacy.InUse() {
+			t.Errorf("active layout = %s, want sandbox", l.Name)
+		}
+	})
+
+	t.Run("loading the config doesn't ask", func(t *testing.T) {
+		_, asked := setup(t, LegacyMove)
+		if _, err := LoadConfig(t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		if *asked != 0 {
+			t.Error("LoadConfig asked about the layout")
+		}
+	})
+
+	t.Run("not asked when the default layout is in use", func(t *testing.T) {
+		_, asked := setup(t, LegacyMove)
+		useRecordingUI(t)
+		resetWarnings(t)
+		xdg, _ := LookupLayout(LayoutXDG)
+		os.MkdirAll(xdg.Config, 0755)
+		os.WriteFile(filepath.Join(xdg.Config, "config.yaml"), []byte("env: {}\n"), 0644)
+		CheckLegacyData()
+		if *asked != 0 {
+			t.Error("asked despite the default layout being in use")
+		}
+		if warningCount() != 1 {
+			t.Errorf("warnings = %d, want 1 for the differing configs", warningCount())
+		}
+	})
+}
+
+func TestFilesDiffer(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	os.WriteFile(a, []byte("x"), 0644)
+	os.WriteFile(b, []byte("y"), 0644)
+	os.WriteFile(c, []byte("x"), 0644)
+	if !filesDiffer(a, b) {
+		t.Error("a and b differ")
+	}
+	if filesDiffer(a, c) {
+		t.Error("a and c are the same")
+	}
+	if filesDiffer(a, filepath.Join(dir, "missing")) {
+		t.Error("a missing file isn't a difference")
+	}
+}
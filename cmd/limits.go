@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// sessionKillGrace is how long a stopped session gets to exit after SIGTERM
+// before it is killed.
+const sessionKillGrace = 30 * time.Second
+
+// ApplySessionLimit enforces cfg's session limits on a session about to run
+// args in container, returning the args and container user to run them with.
+//
+// With a session timeout and the "stop" action, args are wrapped in timeout(1)
+// inside the container so the session is terminated even if the host client
+// goes away. With "pause", the whole container is paused from the host when
+// the limit is reached. Either way the user is notified. Memory and CPU
+// ceilings run the session in its own cgroup scope via sandbox-scope, which
+// needs root and drops back to the agent user. The returned cancel func must
+// be called when the session ends.
+func ApplySessionLimit(container string, cfg *SandboxConfig, args []string) ([]string, string, func()) {
+	user := ""
+	limit := cfg.Limits.SessionTimeoutDuration()
+	action := cfg.Limits.TimeoutAction()
+	if limit > 0 && action == TimeoutStop {
+		args = wrapWithTimeout(limit, args)
+	}
+	resources := ResourceLimits{Memory: cfg.Limits.SessionMemory, CPUs: cfg.Limits.SessionCPUs}
+	if !resources.IsZero() {
+		args = scopedArgs(fmt.Sprintf("session-%d", time.Now().UnixNano()), "agent", resources, args)
+		user = "root"
+	}
+	if limit <= 0 {
+		return args, user, func() {}
+	}
+
+	timer := time.AfterFunc(limit, func() {
+		if action == TimeoutPause {
+			if err := exec.Command("docker", "pause", container).Run(); err != nil {
+				notify(fmt.Sprintf("session limit of %s reached but pausing %s failed: %v", limit, container, err))
+				return
+			}
+			notify(fmt.Sprintf("session limit of %s reached; paused %s. Run `docker unpause %s` to resume.", limit, container, container))
+			return
+		}
+		notify(fmt.Sprintf("session limit of %s reached; stopping the session in %s.", limit, container))
+	})
+	return args, user, func() { timer.Stop() }
+}
+
+// wrapWithTimeout prefixes args with a timeout(1) invocation. --foreground
+// keeps the interactive TTY working.
+func wrapWithTimeout(limit time.Duration, args []string) []string {
+	wrapped := []string{"timeout", "--foreground",
+		fmt.Sprintf("--kill-after=%ds", int(sessionKillGrace.Seconds())),
+		fmt.Sprintf("%ds", int(limit.Seconds()))}
+	return append(wrapped, args...)
+}
+
+// notify tells the user about a session event, on the terminal and as a
+// desktop notification where one is available.
+func notify(msg string) {
+	Frontend.Alert(msg)
+	switch {
+	case runtime.GOOS == "darwin":
+		exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title \"sandbox\"", msg)).Run()
+	default:
+		if path, err := exec.LookPath("notify-send"); err == nil {
+			exec.Command(path, "sandbox", msg).Run()
+		}
+	}
+}
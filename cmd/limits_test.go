@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplySessionLimit(t *testing.T) {
+	args := []string{"claude", "--dangerously-skip-permissions"}
+
+	t.Run("no limit", func(t *testing.T) {
+		got, _, cancel := ApplySessionLimit("ctr", &SandboxConfig{}, args)
+		defer cancel()
+		if strings.Join(got, " ") != strings.Join(args, " ") {
+			t.Errorf("args = %v, want unchanged", got)
+		}
+	})
+
+	t.Run("stop wraps in timeout", func(t *testing.T) {
+		cfg := &SandboxConfig{Limits: LimitsConfig{SessionTimeout: "8h"}}
+		got, _, cancel := ApplySessionLimit("ctr", cfg, args)
+		defer cancel()
+		want := "timeout --foreground --kill-after=30s 28800s claude --dangerously-skip-permissions"
+		if strings.Join(got, " ") != want {
+			t.Errorf("args = %q, want %q", strings.Join(got, " "), want)
+		}
+	})
+
+	t.Run("pause leaves args alone", func(t *testing.T) {
+		cfg := &SandboxConfig{Limits: LimitsConfig{SessionTimeout: "1h", OnTimeout: TimeoutPause}}
+		got, _, cancel := ApplySessionLimit("ctr", cfg, args)
+		defer cancel()
+		if got[0] != "claude" {
+			t.Errorf("args = %v, pause should not wrap the command", got)
+		}
+	})
+}
+
+func TestApplySessionLimitResources(t *testing.T) {
+	cfg := &SandboxConfig{Limits: LimitsConfig{SessionMemory: "4G", SessionCPUs: 2}}
+	got, user, cancel := ApplySessionLimit("ctr", cfg, []string{"claude"})
+	defer cancel()
+	if user != "root" {
+		t.Errorf("user = %q, want root so sandbox-scope can create the scope", user)
+	}
+	joined := strings.Join(got, " ")
+	if !strings.HasPrefix(joined, scopeScriptPath) || !strings.Contains(joined, "--user agent --memory 4G --cpus 2 -- claude") {
+		t.Errorf("args = %q, want claude wrapped in sandbox-scope", joined)
+	}
+}
+
+func TestLimitsConfig(t *testing.T) {
+	l := LimitsConfig{SessionTimeout: "90m"}
+	if l.SessionTimeoutDuration() != 90*time.Minute {
+		t.Errorf("duration = %v, want 90m", l.SessionTimeoutDuration())
+	}
+	if l.TimeoutAction() != TimeoutStop {
+		t.Errorf("action = %q, want stop by default", l.TimeoutAction())
+	}
+}
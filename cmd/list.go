@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// SandboxStatus describes a running sandbox and how fresh it is relative to
+// its workspace config and the current image.
+type SandboxStatus struct {
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	Workspace     string     `json:"workspace"`
+	LastSync      *time.Time `json:"last_sync"` // nil if never synced
+	ConfigChanged bool       `json:"config_changed"`
+	ImageOutdated bool       `json:"image_outdated"`
+	Legacy        bool       `json:"legacy,omitempty"` // created by the ao-sandbox packaging
+}
+
+// ListSandboxes returns the status of every running sandbox container,
+// including legacy ao-sandbox ones.
+func ListSandboxes() ([]SandboxStatus, error) {
+	var result []SandboxStatus
+	for _, labels := range [][2]string{{LabelSel, LabelWs}, {LegacyLabelSel, LegacyLabelWs}} {
+		list, err := listSandboxes(labels[0], labels[1])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, list...)
+	}
+	return result, nil
+}
+
+// listSandboxes returns the running containers with label sel, reading the
+// workspace from label ws.
+func listSandboxes(sel, ws string) ([]SandboxStatus, error) {
+	out, err := exec.Command("docker", "ps",
+		"--filter", "label="+sel,
+		"--format", `{{.Names}}\t{{.Status}}\t{{.Label "`+ws+`"}}`).Output()
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+
+	var result []SandboxStatus
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		s := SandboxStatus{
+			Name:      fields[0],
+			Status:    fields[1],
+			Workspace: fields[2],
+			LastSync:  lastSyncTime(fields[0]),
+			Legacy:    IsLegacyContainer(fields[0]),
+		}
+		cfg, err := LoadConfig(s.Workspace)
+		if err == nil {
+			s.ConfigChanged = SyncPending(s.Name, s.Workspace, cfg)
+		}
+		s.ImageOutdated = imageStale(s.Name, cfg)
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// lastSyncTime returns when the container's sync hash was last written, or nil
+// if it has never been synced.
+func lastSyncTime(container string) *time.Time {
+	out, err := exec.Command("docker", "exec", container, "stat", "-c", "%Y", syncStatePath).Output()
+	if err != nil {
+		return nil
+	}
+	secs, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return nil
+	}
+	t := time.Unix(secs, 0)
+	return &t
+}
+
+// FormatSandboxTable renders sandbox statuses as an aligned table, with sync
+// ages relative to now.
+func FormatSandboxTable(list []SandboxStatus, now time.Time) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMES\tSTATUS\tWORKSPACE\tSYNCED\tCONFIG\tIMAGE")
+	for _, s := range list {
+		synced := "never"
+		if s.LastSync != nil {
+			synced = formatAge(now.Sub(*s.LastSync))
+		}
+		config := "in sync"
+		if s.ConfigChanged {
+			config = "changed"
+		}
+		image := "current"
+		if s.ImageOutdated {
+			image = "outdated"
+		}
+		name := s.Name
+		if s.Legacy {
+			name += " (legacy)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, s.Status, s.Workspace, synced, config, image)
+	}
+	w.Flush()
+	return sb.String()
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatSandboxTable(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	synced := now.Add(-5 * time.Minute)
+	out := FormatSandboxTable([]SandboxStatus{
+		{Name: "sandbox-a", Status: "Up 2 hours", Workspace: "/src/a", LastSync: &synced},
+		{Name: "sandbox-b", Status: "Up 1 day", Workspace: "/src/b", ConfigChanged: true, ImageOutdated: true},
+	}, now)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), out)
+	}
+	if !strings.HasPrefix(lines[0], "NAMES") {
+		t.Errorf("missing header:\n%s", out)
+	}
+	for _, want := range []string{"5m ago", "in sync", "current"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("row a missing %q: %s", want, lines[1])
+		}
+	}
+	for _, want := range []string{"never", "changed", "outdated"} {
+		if !strings.Contains(lines[2], want) {
+			t.Errorf("row b missing %q: %s", want, lines[2])
+		}
+	}
+}
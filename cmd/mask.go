@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LabelMask records the workspace paths a container was created with masks
+// over, joined with ":", so paths masked since can be pointed out.
+const LabelMask = "sandbox.mask"
+
+// WorkspaceConfig controls what of the workspace the container sees.
+type WorkspaceConfig struct {
+	// Mask lists files and directories, relative to the workspace root,
+	// hidden from the container behind empty read-only mounts, e.g. ".env"
+	// or "secrets/".
+	Mask []string `yaml:"mask,omitempty"`
+
+	// Sync copies the workspace into the container and keeps the copies
+	// in step, in place of the bind mount.
+	Sync WorkspaceSyncConfig `yaml:"sync,omitempty"`
+}
+
+func validateMask(m string) error {
+	clean := filepath.Clean(strings.TrimSuffix(m, "/"))
+	if m == "" || filepath.IsAbs(m) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("invalid workspace.mask entry %q, want a path inside the workspace", m)
+	}
+	// Docker splits mount specs on them.
+	if strings.ContainsAny(m, ":,") {
+		return fmt.Errorf("workspace.mask entry %q can't contain ':' or ','", m)
+	}
+	return nil
+}
+
+// maskPaths returns the host paths of cfg's workspace.mask entries under
+// each of roots, the workspace and its linked worktrees. Entries that don't
+// exist are skipped, as a mount would create them; an entry ending in "/"
+// must be a directory. Symlinks are skipped too, since Docker would follow
+// them, possibly out of the workspace.
+func maskPaths(cfg *SandboxConfig, roots []string) []string {
+	if cfg == nil {
+		return nil
+	}
+	var paths []string
+	for _, root := range roots {
+		for _, m := range cfg.Workspace.Mask {
+			path := filepath.Join(root, filepath.Clean(m))
+			info, err := os.Lstat(path)
+			switch {
+			case err != nil:
+				continue
+			case info.Mode()&os.ModeSymlink != 0:
+				Warnf(WarnConfig, "workspace.mask: %s is a symlink, not masking it; mask its target instead", path)
+			case strings.HasSuffix(m, "/") && !info.IsDir():
+				Warnf(WarnConfig, "workspace.mask: %s isn't a directory, not masking it", path)
+			default:
+				paths = append(paths, path)
+			}
+		}
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
+// maskArgs returns the docker run arguments hiding paths: an empty
+// read-only tmpfs over each directory and /dev/null over each file.
+func maskArgs(paths []string) []string {
+	var args []string
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			args = append(args, "--tmpfs", p+":ro,mode=0555")
+		} else {
+			args = append(args, "-v", "/dev/null:"+p+":ro")
+		}
+	}
+	return args
+}
+
+// warnIfMaskChanged warns about paths in want that the container doesn't
+// mask, because they were added to workspace.mask or created since it was.
+// Mounts can only be added when a container is created.
+func warnIfMaskChanged(container string, want []string) {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelMask+`"}}`, container).Output()
+	if err != nil {
+		return
+	}
+	have := strings.Split(strings.TrimSpace(string(out)), ":")
+	var missing []string
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			missing = append(missing, w)
+		}
+	}
+	if len(missing) > 0 {
+		Warnf(WarnContainer, "path(s) %s aren't masked in this sandbox, so the agent can read them. To mask them, run `sandbox rm <folder>` and then restart.",
+			strings.Join(missing, ", "))
+	}
+}
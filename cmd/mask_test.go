@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestValidateMask(t *testing.T) {
+	for _, m := range []string{".env", "secrets/", "config/prod.yaml"} {
+		if err := validateMask(m); err != nil {
+			t.Errorf("validateMask(%q): %v", m, err)
+		}
+	}
+	for _, m := range []string{"", ".", "./", "..", "../other", "/etc/passwd", "a:b", "a,b"} {
+		if err := validateMask(m); err == nil {
+			t.Errorf("validateMask(%q) succeeded, want error", m)
+		}
+	}
+}
+
+func TestMaskPaths(t *testing.T) {
+	ws, wt := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(ws, ".env"), []byte("TOKEN=x\n"), 0600)
+	os.WriteFile(filepath.Join(wt, ".env"), []byte("TOKEN=x\n"), 0600)
+	os.Mkdir(filepath.Join(ws, "secrets"), 0700)
+	os.WriteFile(filepath.Join(ws, "notes"), nil, 0644)
+	os.Symlink(filepath.Join(ws, ".env"), filepath.Join(ws, "link"))
+
+	cfg := &SandboxConfig{Workspace: WorkspaceConfig{Mask: []string{".env", "secrets/", "notes/", "link", "missing", ".env"}}}
+	got := maskPaths(cfg, []string{ws, wt})
+	want := []string{filepath.Join(ws, ".env"), filepath.Join(ws, "secrets"), filepath.Join(wt, ".env")}
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("maskPaths = %q, want %q", got, want)
+	}
+
+	args := maskArgs([]string{filepath.Join(ws, ".env"), filepath.Join(ws, "secrets")})
+	wantArgs := []string{"-v", "/dev/null:" + filepath.Join(ws, ".env") + ":ro", "--tmpfs", filepath.Join(ws, "secrets") + ":ro,mode=0555"}
+	if !slices.Equal(args, wantArgs) {
+		t.Errorf("maskArgs = %q, want %q", args, wantArgs)
+	}
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric event kinds sent to the daemon.
+const (
+	metricSync = "sync"
+	metricExec = "exec"
+)
+
+// metricEvent is something a sandbox command did, reported to the daemon so
+// it can be counted on /metrics.
+type metricEvent struct {
+	Kind    string  `json:"kind"`    // metricSync or metricExec
+	Sandbox string  `json:"sandbox"` // container name
+	Seconds float64 `json:"seconds,omitempty"`
+	Failed  bool    `json:"failed,omitempty"`
+}
+
+// sandboxCounters are the event counts the daemon has seen for one sandbox.
+type sandboxCounters struct {
+	syncs        uint64
+	syncFailures uint64
+	syncSeconds  float64
+	execs        uint64
+}
+
+// metricsRegistry accumulates reported events by sandbox for the lifetime
+// of the daemon.
+type metricsRegistry struct {
+	mu        sync.Mutex
+	bySandbox map[string]*sandboxCounters
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{bySandbox: make(map[string]*sandboxCounters)}
+}
+
+func (r *metricsRegistry) record(ev metricEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.bySandbox[ev.Sandbox]
+	if c == nil {
+		c = &sandboxCounters{}
+		r.bySandbox[ev.Sandbox] = c
+	}
+	switch ev.Kind {
+	case metricSync:
+		c.syncs++
+		c.syncSeconds += ev.Seconds
+		if ev.Failed {
+			c.syncFailures++
+		}
+	case metricExec:
+		c.execs++
+	}
+}
+
+// containerStats are read from Docker for each running sandbox at scrape
+// time.
+type containerStats struct {
+	Restarts       int
+	FirewallBlocks uint64 // packets hitting the firewall's REJECT rules
+}
+
+// sandboxStats returns stats for every running sandbox container; a
+// variable so tests can avoid Docker.
+var sandboxStats = liveSandboxStats
+
+func liveSandboxStats() map[string]containerStats {
+	stats := make(map[string]containerStats)
+	for _, sel := range []string{LabelSel, LegacyLabelSel} {
+		out, err := exec.Command("docker", "ps", "--filter", "label="+sel, "--format", "{{.Names}}").Output()
+		if err != nil {
+			continue
+		}
+		for _, name := range strings.Fields(string(out)) {
+			var s containerStats
+			if out, err := exec.Command("docker", "inspect", "-f", "{{.RestartCount}}", name).Output(); err == nil {
+				s.Restarts, _ = strconv.Atoi(strings.TrimSpace(string(out)))
+			}
+			if out, err := exec.Command("docker", "exec", "-u", "root", name, "sh", "-c",
+				"iptables -L OUTPUT -v -x -n; ip6tables -L OUTPUT -v -x -n").Output(); err == nil {
+				s.FirewallBlocks = rejectedPackets(string(out))
+			}
+			stats[name] = s
+		}
+	}
+	return stats
+}
+
+// rejectedPackets sums the packet counts of REJECT rules in `iptables -L -v
+// -x -n` output.
+func rejectedPackets(listing string) uint64 {
+	var total uint64
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[2] != "REJECT" {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			total += n
+		}
+	}
+	return total
+}
+
+// writeMetrics renders the registry and live container stats in the
+// Prometheus text exposition format.
+func (r *metricsRegistry) writeMetrics(w io.Writer, live map[string]containerStats) {
+	r.mu.Lock()
+	counters := make(map[string]sandboxCounters, len(r.bySandbox))
+	for name, c := range r.bySandbox {
+		counters[name] = *c
+	}
+	r.mu.Unlock()
+
+	var sandboxes []string
+	for name := range counters {
+		sandboxes = append(sandboxes, name)
+	}
+	for name := range live {
+		if _, dup := counters[name]; !dup {
+			sandboxes = append(sandboxes, name)
+		}
+	}
+	sort.Strings(sandboxes)
+
+	metric := func(name, typ, help string, value func(sandbox string) (string, bool)) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, sb := range sandboxes {
+			if v, ok := value(sb); ok {
+				fmt.Fprintf(w, "%s{sandbox=%q} %s\n", name, sb, v)
+			}
+		}
+	}
+	counter := func(sb string, f func(sandboxCounters) string) (string, bool) {
+		c, ok := counters[sb]
+		return f(c), ok
+	}
+	fromLive := func(sb string, f func(containerStats) string) (string, bool) {
+		s, ok := live[sb]
+		return f(s), ok
+	}
+	u := func(n uint64) string { return strconv.FormatUint(n, 10) }
+
+	metric("sandbox_syncs_total", "counter", "Syncs that pushed changes into the sandbox.", func(sb string) (string, bool) {
+		return counter(sb, func(c sandboxCounters) string { return u(c.syncs) })
+	})
+	metric("sandbox_sync_failures_total", "counter", "Syncs that returned an error.", func(sb string) (string, bool) {
+		return counter(sb, func(c sandboxCounters) string { return u(c.syncFailures) })
+	})
+	metric("sandbox_sync_duration_seconds_total", "counter", "Time spent in syncs.", func(sb string) (string, bool) {
+		return counter(sb, func(c sandboxCounters) string { return strconv.FormatFloat(c.syncSeconds, 'g', -1, 64) })
+	})
+	metric("sandbox_execs_total", "counter", "Shells, sessions and commands started in the sandbox.", func(sb string) (string, bool) {
+		return counter(sb, func(c sandboxCounters) string { return u(c.execs) })
+	})
+	metric("sandbox_firewall_blocks_total", "counter", "Outbound packets rejected by the firewall since the container started.", func(sb string) (string, bool) {
+		return fromLive(sb, func(s containerStats) string { return u(s.FirewallBlocks) })
+	})
+	metric("sandbox_container_restarts_total", "counter", "Times Docker restarted the container.", func(sb string) (string, bool) {
+		return fromLive(sb, func(s containerStats) string { return strconv.Itoa(s.Restarts) })
+	})
+	metric("sandbox_up", "gauge", "Whether the sandbox container is running.", func(sb string) (string, bool) {
+		if _, ok := live[sb]; ok {
+			return "1", true
+		}
+		return "0", true
+	})
+}
+
+// serveMetrics serves /metrics on addr until ctx is done.
+func (r *metricsRegistry) serveMetrics(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.writeMetrics(w, sandboxStats())
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go srv.Serve(listener)
+	return nil
+}
+
+// reportMetric tells the daemon on cfg's host tool port about ev. It is
+// best-effort: when no daemon is listening the event is dropped.
+func reportMetric(cfg *SandboxConfig, ev metricEvent) {
+	port := DefaultHostToolPort
+	if cfg != nil {
+		port = cfg.EffectiveHostToolPort()
+	}
+	sendHostToolMessage(port, hostToolMessage{Type: "metric", Metric: &ev})
+}
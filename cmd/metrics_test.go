@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRejectedPackets(t *testing.T) {
+	listing := `Chain OUTPUT (policy ACCEPT 0 packets, 0 bytes)
+    pkts      bytes target     prot opt in     out     source               destination
+     120     9000 ACCEPT     all  --  *      lo      0.0.0.0/0            0.0.0.0/0
+       3      180 REJECT     all  --  *      *       0.0.0.0/0            0.0.0.0/0            reject-with icmp-port-unreachable
+Chain OUTPUT (policy ACCEPT 0 packets, 0 bytes)
+    pkts      bytes target     prot opt in     out     source               destination
+       2      160 REJECT     all      *      *       ::/0                 ::/0                 reject-with icmp6-port-unreachable
+`
+	if got := rejectedPackets(listing); got != 5 {
+		t.Errorf("rejectedPackets = %d, want 5", got)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	r := newMetricsRegistry()
+	r.record(metricEvent{Kind: metricSync, Sandbox: "sandbox-a", Seconds: 1.5})
+	r.record(metricEvent{Kind: metricSync, Sandbox: "sandbox-a", Seconds: 0.5, Failed: true})
+	r.record(metricEvent{Kind: metricExec, Sandbox: "sandbox-a"})
+	r.record(metricEvent{Kind: metricExec, Sandbox: "sandbox-gone"})
+
+	var b strings.Builder
+	r.writeMetrics(&b, map[string]containerStats{
+		"sandbox-a": {Restarts: 1, FirewallBlocks: 7},
+		"sandbox-b": {},
+	})
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE sandbox_syncs_total counter\n",
+		`sandbox_syncs_total{sandbox="sandbox-a"} 2` + "\n",
+		`sandbox_sync_failures_total{sandbox="sandbox-a"} 1` + "\n",
+		`sandbox_sync_duration_seconds_total{sandbox="sandbox-a"} 2` + "\n",
+		`sandbox_execs_total{sandbox="sandbox-gone"} 1` + "\n",
+		`sandbox_firewall_blocks_total{sandbox="sandbox-a"} 7` + "\n",
+		`sandbox_container_restarts_total{sandbox="sandbox-a"} 1` + "\n",
+		`sandbox_up{sandbox="sandbox-b"} 1` + "\n",
+		`sandbox_up{sandbox="sandbox-gone"} 0` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `sandbox_syncs_total{sandbox="sandbox-b"}`) {
+		t.Errorf("sandbox-b has no events, so no syncs_total series:\n%s", out)
+	}
+}
+
+func TestDaemonMetrics(t *testing.T) {
+	orig := sandboxStats
+	sandboxStats = func() map[string]containerStats { return map[string]containerStats{"sandbox-x": {}} }
+	t.Cleanup(func() { sandboxStats = orig })
+
+	port := findFreePort(t)
+	metricsAddr := fmt.Sprintf("127.0.0.1:%d", findFreePort(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go RunHostToolDaemon(ctx, port, metricsAddr)
+
+	var err error
+	for i := 0; i < 40; i++ {
+		if err = sendHostToolMessage(port, hostToolMessage{
+			Type:   "metric",
+			Metric: &metricEvent{Kind: metricExec, Sandbox: "sandbox-x"},
+		}); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("report metric: %v", err)
+	}
+
+	resp, err := http.Get("http://" + metricsAddr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `sandbox_execs_total{sandbox="sandbox-x"} 1`) {
+		t.Errorf("metrics missing exec count:\n%s", body)
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plugin events, each passed to plugins as their first argument.
+const (
+	PluginPreStart = "pre-start" // before a sandbox is created or restarted
+	PluginPostSync = "post-sync" // after files and firewall rules are synced
+	PluginPreExec  = "pre-exec"  // before a command runs in a sandbox
+	PluginPostStop = "post-stop" // after a sandbox is stopped
+)
+
+// PluginEvent is the JSON payload a plugin reads from stdin.
+type PluginEvent struct {
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Workspace string    `json:"workspace,omitempty"`
+	Container string    `json:"container"`
+	// For pre-exec, the command about to run and its container user.
+	Command []string `json:"command,omitempty"`
+	User    string   `json:"user,omitempty"`
+}
+
+// pluginTimeout bounds each plugin run, so a hung plugin can't hold up
+// every sandbox command.
+const pluginTimeout = 30 * time.Second
+
+// pluginsDir returns the directory plugins are run from. A variable so
+// tests can use a temp dir.
+var pluginsDir = func() (string, error) {
+	l, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Config, "plugins"), nil
+}
+
+// plugins returns the executables in pluginsDir, sorted by name so a
+// numeric prefix sets the order. Hidden files are skipped, so a plugin can
+// be turned off by renaming it.
+func plugins() []string {
+	dir, err := pluginsDir()
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, e.Name()))
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// runPlugins runs each plugin with e's event name as its argument and e as
+// JSON on stdin, passing its output through. A plugin failing a pre-
+// event stops whatever was about to happen, so plugins can veto starts and
+// execs; failures after the fact are only warned about.
+func runPlugins(e PluginEvent) error {
+	paths := plugins()
+	if len(paths) == 0 {
+		return nil
+	}
+	e.Time = time.Now()
+	if e.Workspace == "" {
+		e.Workspace = containerWorkspace(e.Container)
+	}
+	command := make([]string, len(e.Command))
+	for i, arg := range e.Command {
+		command[i] = Redact(arg)
+	}
+	e.Command = command
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		err := runPlugin(path, e.Event, payload)
+		if err == nil {
+			continue
+		}
+		if strings.HasPrefix(e.Event, "pre-") {
+			return &Error{Kind: KindHook, Err: fmt.Errorf("plugin %s stopped %s: %v", filepath.Base(path), e.Event, err)}
+		}
+		Warnf(WarnContainer, "plugin %s failed on %s: %v", filepath.Base(path), e.Event, err)
+	}
+	return nil
+}
+
+// runPlugin runs one plugin for event.
+func runPlugin(path, event string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, event)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = Frontend.Stderr()
+	cmd.Stderr = Frontend.Stderr()
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", pluginTimeout)
+	}
+	return err
+}
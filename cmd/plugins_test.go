@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// usePluginsDir runs plugins from a temp dir, returning it.
+func usePluginsDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	orig := pluginsDir
+	pluginsDir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { pluginsDir = orig })
+	return dir
+}
+
+func TestRunPlugins(t *testing.T) {
+	isolateRedactions(t)
+	dir := usePluginsDir(t)
+	log := filepath.Join(t.TempDir(), "log")
+	record := "#!/bin/sh\necho \"$0 $1\" >> " + log + "\ncat >> " + log + "\necho >> " + log + "\n"
+	os.WriteFile(filepath.Join(dir, "20-record"), []byte(record), 0755)
+	os.WriteFile(filepath.Join(dir, "10-record"), []byte(record), 0755)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0644)
+	os.WriteFile(filepath.Join(dir, ".off"), []byte("#!/bin/sh\nexit 1\n"), 0755)
+
+	addRedaction("s3cret-token")
+	err := runPlugins(PluginEvent{Event: PluginPreExec, Workspace: "/ws", Container: "sandbox-ws", Command: []string{"curl", "-H", "s3cret-token"}, User: "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(readFile(t, log)), "\n")
+	if len(lines) != 4 || lines[0] != filepath.Join(dir, "10-record")+" pre-exec" || lines[2] != filepath.Join(dir, "20-record")+" pre-exec" {
+		t.Fatalf("plugins ran as:\n%s", strings.Join(lines, "\n"))
+	}
+	var e PluginEvent
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Event != PluginPreExec || e.Workspace != "/ws" || e.Container != "sandbox-ws" || e.User != "root" || e.Time.IsZero() {
+		t.Errorf("payload = %+v", e)
+	}
+	if strings.Join(e.Command, " ") != "curl -H [redacted]" {
+		t.Errorf("command = %q, want secrets redacted", e.Command)
+	}
+
+	os.WriteFile(filepath.Join(dir, "30-veto"), []byte("#!/bin/sh\necho no VPN >&2\nexit 1\n"), 0755)
+	if err := runPlugins(PluginEvent{Event: PluginPreStart, Workspace: "/ws", Container: "sandbox-ws"}); err == nil || !strings.Contains(err.Error(), "30-veto") {
+		t.Errorf("failing pre-start plugin: %v, want it to stop the start", err)
+	}
+	if err := runPlugins(PluginEvent{Event: PluginPostStop, Workspace: "/ws", Container: "sandbox-ws"}); err != nil {
+		t.Errorf("failing post-stop plugin: %v, want only a warning", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
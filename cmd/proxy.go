@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ProxyConfig routes the container's traffic through an HTTP proxy, for
+// networks that only reach the internet through one.
+type ProxyConfig struct {
+	HTTP    string   `yaml:"http,omitempty"`     // proxy URL for http://, e.g. "http://proxy.corp:3128"
+	HTTPS   string   `yaml:"https,omitempty"`    // proxy URL for https://; defaults to http's
+	NoProxy []string `yaml:"no_proxy,omitempty"` // hosts and domains to reach directly
+}
+
+// defaultNoProxy are always reached directly: the container itself.
+var defaultNoProxy = []string{"localhost", "127.0.0.1", "::1"}
+
+func validateProxy(p ProxyConfig) error {
+	for _, raw := range []string{p.HTTP, p.HTTPS} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" || !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) {
+			return fmt.Errorf("invalid proxy URL %q, want e.g. http://proxy.corp:3128", redactProxyURL(raw))
+		}
+		if port := u.Port(); port != "" {
+			if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				return fmt.Errorf("proxy URL %q has invalid port %s", redactProxyURL(raw), port)
+			}
+		}
+	}
+	if p.HTTP == "" && p.HTTPS == "" && len(p.NoProxy) > 0 {
+		return fmt.Errorf("proxy.no_proxy is set without proxy.http or proxy.https")
+	}
+	return nil
+}
+
+// redactProxyURL hides the password a proxy URL may carry.
+func redactProxyURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return raw
+}
+
+func (p ProxyConfig) https() string {
+	if p.HTTPS != "" {
+		return p.HTTPS
+	}
+	return p.HTTP
+}
+
+// env returns the proxy variables for p, in both the upper and lower case
+// spellings tools look for, or nil if no proxy is set.
+func (p ProxyConfig) env() map[string]string {
+	if p.HTTP == "" && p.HTTPS == "" {
+		return nil
+	}
+	env := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			env[name] = value
+			env[strings.ToLower(name)] = value
+		}
+	}
+	set("HTTP_PROXY", p.HTTP)
+	set("HTTPS_PROXY", p.https())
+	set("NO_PROXY", strings.Join(append(slices.Clone(defaultNoProxy), p.NoProxy...), ","))
+	return env
+}
+
+// proxyFirewallEntries returns the firewall entries allowing cfg's proxies,
+// each on its port.
+func proxyFirewallEntries(cfg *SandboxConfig) []FirewallEntry {
+	var entries []FirewallEntry
+	seen := make(map[string]bool)
+	for _, raw := range []string{cfg.Proxy.HTTP, cfg.Proxy.HTTPS} {
+		u, err := url.Parse(raw)
+		if raw == "" || err != nil || u.Hostname() == "" {
+			continue
+		}
+		port, _ := strconv.Atoi(u.Port())
+		if port == 0 {
+			port = map[string]int{"http": 80, "https": 443}[u.Scheme]
+			if port == 0 {
+				port = 1080 // socks
+			}
+		}
+		key := u.Hostname() + ":" + strconv.Itoa(port)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		e := FirewallEntry{Domain: u.Hostname(), Ports: []int{port}}
+		if net.ParseIP(u.Hostname()) != nil {
+			e = FirewallEntry{CIDR: u.Hostname(), Ports: []int{port}}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// proxyBuildArgs returns the docker build arguments passing the global
+// config's proxy to image builds. Docker doesn't keep these proxy
+// arguments in the image or let them invalidate its cache.
+func proxyBuildArgs() []string {
+	env := readGlobalSettings().Proxy.env()
+	var args []string
+	for _, name := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"} {
+		if v, ok := env[name]; ok {
+			args = append(args, "--build-arg", name+"="+v)
+		}
+	}
+	return args
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// redactedText stands in for a secret value in output.
+const redactedText = "[redacted]"
+
+// minRedactLen is the length of the shortest value redacted. Shorter ones
+// would match too much ordinary output to be worth hiding.
+const minRedactLen = 6
+
+// redactions holds the secret values this process has seen, scrubbed from
+// everything it prints or records.
+var redactions struct {
+	sync.RWMutex
+	values   []string // longest first
+	replacer *strings.Replacer
+}
+
+// isSecretEnv reports whether the env var k, configured as v and resolved
+// to value, holds a secret: it is read from a secret manager, its name
+// suggests a credential, or its value looks like a token. Other values,
+// like NODE_ENV=development, are left alone so output stays readable.
+func isSecretEnv(k, v, value string) bool {
+	return isSecretRef(v) || secretEnvNameRe.MatchString(k) ||
+		(len(value) >= 20 && !strings.ContainsAny(value, " /") && shannonEntropy(value) >= 4.0)
+}
+
+// addRedaction adds a secret value to be scrubbed from output.
+func addRedaction(v string) {
+	v = strings.TrimSpace(v)
+	if len(v) < minRedactLen {
+		return
+	}
+	redactions.Lock()
+	defer redactions.Unlock()
+	if slices.Contains(redactions.values, v) {
+		return
+	}
+	redactions.values = append(redactions.values, v)
+	// The replacer tries its pairs in order, so longest first keeps a
+	// secret that contains another from being only partly replaced.
+	slices.SortFunc(redactions.values, func(a, b string) int { return len(b) - len(a) })
+	pairs := make([]string, 0, 2*len(redactions.values))
+	for _, s := range redactions.values {
+		pairs = append(pairs, s, redactedText)
+	}
+	redactions.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with every known secret value replaced.
+func Redact(s string) string {
+	redactions.RLock()
+	defer redactions.RUnlock()
+	if redactions.replacer == nil {
+		return s
+	}
+	return redactions.replacer.Replace(s)
+}
+
+// redactWriter scrubs secrets from a stream on its way to w. A write ending
+// partway into what may be a secret holds that tail back until the next
+// write, or Close, shows whether it is one.
+type redactWriter struct {
+	w       io.Writer
+	pending []byte
+}
+
+func newRedactWriter(w io.Writer) *redactWriter {
+	return &redactWriter{w: w}
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	buf := append(r.pending, p...)
+	cut := len(buf) - heldBack(buf)
+	r.pending = slices.Clone(buf[cut:])
+	if cut > 0 {
+		if _, err := io.WriteString(r.w, Redact(string(buf[:cut]))); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close writes out anything held back.
+func (r *redactWriter) Close() error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(r.w, Redact(string(r.pending)))
+	r.pending = nil
+	return err
+}
+
+// heldBack returns the length of the longest tail of buf that is the start,
+// but not the whole, of a secret value.
+func heldBack(buf []byte) int {
+	redactions.RLock()
+	defer redactions.RUnlock()
+	held := 0
+	for _, s := range redactions.values {
+		for n := min(len(s)-1, len(buf)); n > held; n-- {
+			if string(buf[len(buf)-n:]) == s[:n] {
+				held = n
+				break
+			}
+		}
+	}
+	return held
+}
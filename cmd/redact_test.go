@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// isolateRedactions starts the test with no known secrets and restores the
+// previous ones afterwards.
+func isolateRedactions(t *testing.T) {
+	t.Helper()
+	redactions.Lock()
+	values, replacer := redactions.values, redactions.replacer
+	redactions.values, redactions.replacer = nil, nil
+	redactions.Unlock()
+	t.Cleanup(func() {
+		redactions.Lock()
+		redactions.values, redactions.replacer = values, replacer
+		redactions.Unlock()
+	})
+}
+
+func TestRedact(t *testing.T) {
+	isolateRedactions(t)
+	if got := Redact("nothing known yet"); got != "nothing known yet" {
+		t.Errorf("Redact = %q", got)
+	}
+	addRedaction("ghp_abcdef123456")
+	addRedaction("ghp_abcdef123456_more")
+	addRedaction("short")
+	got := Redact("token ghp_abcdef123456_more and ghp_abcdef123456, short")
+	if want := "token [redacted] and [redacted], short"; got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+}
+
+func TestRedactWriter(t *testing.T) {
+	isolateRedactions(t)
+	addRedaction("sk-secret-value")
+
+	var out bytes.Buffer
+	w := newRedactWriter(&out)
+	for _, chunk := range []string{"key=sk-sec", "ret-value\nprompt> sk-", "other\nends with sk-se"} {
+		w.Write([]byte(chunk))
+	}
+	if got := out.String(); strings.Contains(got, "sk-se") {
+		t.Errorf("partial secret written before it could be checked: %q", got)
+	}
+	w.Close()
+	if got, want := out.String(), "key=[redacted]\nprompt> sk-other\nends with sk-se"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestResolveEnvAddsRedactions(t *testing.T) {
+	isolateRedactions(t)
+	t.Setenv("HOST_TOKEN", "Zx81qLm0Vb7Rt3Ks9Pw2Yd6Hn4Jc")
+	resolveEnv(map[string]string{
+		"NODE_ENV":     "development",
+		"DB_PASSWORD":  "hunter2hunter2",
+		"UPSTREAM_REF": "$HOST_TOKEN",
+		"GOFLAGS":      "-mod=mod -trimpath",
+	}, false)
+	got := Redact("development hunter2hunter2 Zx81qLm0Vb7Rt3Ks9Pw2Yd6Hn4Jc -mod=mod -trimpath")
+	if want := "development [redacted] [redacted] -mod=mod -trimpath"; got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Requirement is a host tool that must be installed, optionally at a minimum
+// version. In config it is written as "tool", "tool>=1.2", or a mapping with
+// a custom installation hint:
+//
+//	requires:
+//	  - docker>=24
+//	  - git
+//	  - tool: terraform>=1.6
+//	    hint: brew install terraform
+type Requirement struct {
+	Spec string
+	Hint string
+}
+
+// requirementSpec is a parsed Requirement.Spec.
+type requirementSpec struct {
+	Tool    string
+	Op      string // one of >=, >, <=, <, =; empty for any version
+	Version string
+}
+
+// requirementRe splits "tool>=1.2" into its parts.
+var requirementRe = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._+-]*)\s*(?:(>=|<=|==|=|>|<)\s*(\d+(?:\.\d+)*))?\s*$`)
+
+func (r Requirement) parse() (requirementSpec, error) {
+	m := requirementRe.FindStringSubmatch(r.Spec)
+	if m == nil {
+		return requirementSpec{}, fmt.Errorf("invalid requirement %q, want e.g. \"git\" or \"docker>=24\"", r.Spec)
+	}
+	op := m[2]
+	if op == "==" {
+		op = "="
+	}
+	return requirementSpec{Tool: m[1], Op: op, Version: m[3]}, nil
+}
+
+func (r *Requirement) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*r = Requirement{Spec: n.Value}
+		return nil
+	}
+	var v struct {
+		Tool string `yaml:"tool"`
+		Hint string `yaml:"hint"`
+	}
+	if err := n.Decode(&v); err != nil {
+		return err
+	}
+	*r = Requirement{Spec: v.Tool, Hint: v.Hint}
+	return nil
+}
+
+func (r Requirement) MarshalYAML() (any, error) {
+	if r.Hint == "" {
+		return r.Spec, nil
+	}
+	return map[string]string{"tool": r.Spec, "hint": r.Hint}, nil
+}
+
+// requirementHints are installation hints for tools sandbox commonly needs,
+// by tool and then by GOOS ("" for any).
+var requirementHints = map[string]map[string]string{
+	"docker": {
+		"darwin": "install Docker Desktop (https://docs.docker.com/desktop/setup/install/mac-install/) or `brew install --cask docker`",
+		"linux":  "install Docker Engine: https://docs.docker.com/engine/install/",
+	},
+	"git": {
+		"darwin": "run `xcode-select --install` or `brew install git`",
+		"linux":  "install git with your package manager, e.g. `sudo apt install git`",
+	},
+	"code": {
+		"": "install VS Code, then run \"Shell Command: Install 'code' command in PATH\" from the command palette",
+	},
+	"op": {
+		"": "install the 1Password CLI: https://developer.1password.com/docs/cli/get-started/",
+	},
+	"vault": {
+		"": "install the Vault CLI: https://developer.hashicorp.com/vault/install",
+	},
+	"secret-tool": {
+		"linux": "install libsecret-tools, e.g. `sudo apt install libsecret-tools`",
+	},
+	"task": {
+		"": "install Task: https://taskfile.dev/installation/",
+	},
+}
+
+// hint returns how to install tool, or "".
+func (r Requirement) hint(tool string) string {
+	if r.Hint != "" {
+		return r.Hint
+	}
+	hints := requirementHints[tool]
+	if h, ok := hints[runtime.GOOS]; ok {
+		return h
+	}
+	return hints[""]
+}
+
+// toolVersion runs `tool --version` and returns the first version number in
+// its output.
+var toolVersion = func(tool string) (string, error) {
+	out, err := exec.Command(tool, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s --version: %w", tool, err)
+	}
+	v := versionRe.FindString(string(out))
+	if v == "" {
+		return "", fmt.Errorf("no version in `%s --version` output", tool)
+	}
+	return v, nil
+}
+
+var versionRe = regexp.MustCompile(`\d+(\.\d+)+|\d+`)
+
+// lookPath is exec.LookPath, replaceable in tests.
+var lookPath = exec.LookPath
+
+// Check reports whether the tool is installed at a matching version, with an
+// installation hint if not.
+func (r Requirement) Check() error {
+	spec, err := r.parse()
+	if err != nil {
+		return err
+	}
+	fail := func(msg string) error {
+		if h := r.hint(spec.Tool); h != "" {
+			msg += "; " + h
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	if _, err := lookPath(spec.Tool); err != nil {
+		return fail(fmt.Sprintf("%s is required but not installed", r.Spec))
+	}
+	if spec.Op == "" {
+		return nil
+	}
+	have, err := toolVersion(spec.Tool)
+	if err != nil {
+		return fail(fmt.Sprintf("%s is required but its version could not be read: %v", r.Spec, err))
+	}
+	if !versionSatisfies(have, spec.Op, spec.Version) {
+		return fail(fmt.Sprintf("%s is required but %s is installed", r.Spec, have))
+	}
+	return nil
+}
+
+// versionSatisfies compares dotted numeric versions, treating missing
+// components as zero.
+func versionSatisfies(have, op, want string) bool {
+	c := compareVersions(have, want)
+	switch op {
+	case ">=":
+		return c >= 0
+	case ">":
+		return c > 0
+	case "<=":
+		return c <= 0
+	case "<":
+		return c < 0
+	case "=":
+		// "=24" accepts any 24.x.
+		return compareVersions(truncateVersion(have, want), want) == 0
+	}
+	return true
+}
+
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// truncateVersion cuts v to as many components as like has.
+func truncateVersion(v, like string) string {
+	parts := strings.Split(v, ".")
+	if n := strings.Count(like, ".") + 1; len(parts) > n {
+		parts = parts[:n]
+	}
+	return strings.Join(parts, ".")
+}
+
+// CheckRequirements checks every requirement in cfg, returning an error that
+// lists all unmet ones. cfg may be nil.
+func CheckRequirements(cfg *SandboxConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	var problems []string
+	for _, r := range cfg.Requires {
+		if err := r.Check(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%s", problems[0])
+	}
+	return fmt.Errorf("missing requirements:\n  %s", strings.Join(problems, "\n  "))
+}
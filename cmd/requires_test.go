@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fakeTools stubs tool lookup so only the given tools exist, at the given
+// versions.
+func fakeTools(t *testing.T, versions map[string]string) {
+	t.Helper()
+	origLook, origVersion := lookPath, toolVersion
+	t.Cleanup(func() { lookPath, toolVersion = origLook, origVersion })
+	lookPath = func(tool string) (string, error) {
+		if _, ok := versions[tool]; ok {
+			return "/usr/bin/" + tool, nil
+		}
+		return "", errors.New("not found")
+	}
+	toolVersion = func(tool string) (string, error) {
+		return versions[tool], nil
+	}
+}
+
+func TestRequirementCheck(t *testing.T) {
+	fakeTools(t, map[string]string{"docker": "24.0.7", "git": "2.39.2"})
+
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{"git", ""},
+		{"docker>=24", ""},
+		{"docker>=24.1", "24.0.7 is installed"},
+		{"docker=24", ""},
+		{"docker<24", "24.0.7 is installed"},
+		{"git==2.39.2", ""},
+		{"code", "not installed; install VS Code"},
+		{"docker>>24", "invalid requirement"},
+	}
+	for _, tt := range tests {
+		err := Requirement{Spec: tt.spec}.Check()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.spec, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tt.spec, err, tt.wantErr)
+		}
+	}
+
+	t.Run("custom hint", func(t *testing.T) {
+		err := Requirement{Spec: "terraform", Hint: "brew install terraform"}.Check()
+		if err == nil || !strings.HasSuffix(err.Error(), "; brew install terraform") {
+			t.Errorf("err = %v, want custom hint", err)
+		}
+	})
+}
+
+func TestCheckRequirements(t *testing.T) {
+	fakeTools(t, map[string]string{"git": "2.39.2"})
+	var cfg SandboxConfig
+	if err := yaml.Unmarshal([]byte("requires:\n  - git\n  - docker>=24\n  - tool: terraform\n    hint: get it\n"), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckRequirements(&cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"docker>=24 is required", "terraform is required but not installed; get it"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "git") {
+		t.Errorf("error %q mentions a met requirement", err)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -10,20 +11,41 @@ import (
 var flagHere bool
 
 var RootCmd = &cobra.Command{
-	Use:          "sandbox",
-	Short:        "Manage sandboxed Claude Code containers",
-	Long:         `Create, manage, and interact with Docker-based sandbox containers for Claude Code.`,
+	Use:           "sandbox",
+	Short:         "Manage sandboxed Claude Code containers",
+	Long:          `Create, manage, and interact with Docker-based sandbox containers for Claude Code.`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
 }
 
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	err := RootCmd.Execute()
+	// The summary goes before the error so the error stays last.
+	writeWarningSummary(Frontend.Stderr())
+	if err != nil && flagJSON {
+		printJSONError(err)
+	}
+	// A command in the container already reported its own failure.
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+	if err != nil {
+		fmt.Fprintln(Frontend.Stderr(), Redact(err.Error()))
+		os.Exit(ExitCode(err))
+	}
+	if flagStrictWarnings && warningCount() > 0 {
+		os.Exit(ExitWarnings)
 	}
 }
 
 func init() {
+	RootCmd.PersistentFlags().StringVar(&flagProfile, "profile", "", "apply this profile from the global config (default: $SANDBOX_PROFILE)")
+	RootCmd.PersistentFlags().BoolVar(&flagIgnoreConfigErrors, "ignore-config-errors", false, "warn about config files that don't parse and carry on without them")
+	RootCmd.PersistentFlags().BoolVar(&flagStrictWarnings, "strict-warnings", false, fmt.Sprintf("exit with status %d if the command succeeds but printed warnings", ExitWarnings))
+	RootCmd.PersistentFlags().BoolVarP(&flagYes, "yes", "y", false, "answer yes to confirmations and run root on_sync hooks without asking for approval, for automation")
+	RootCmd.PersistentFlags().BoolVar(&flagJSON, "json", false, "print results as JSON, for scripts and editors")
+	RootCmd.PersistentFlags().StringVar(&flagDockerContext, "context", "", "docker context to run sandboxes on (default: docker.context from the global config)")
+	RootCmd.PersistentFlags().BoolVar(&flagOffline, "offline", false, "don't look up firewall domains, use the IPs they last resolved to")
 	RootCmd.PersistentFlags().BoolVar(&flagHere, "here", false, "use the exact path as the sandbox root (don't search parent directories)")
 }
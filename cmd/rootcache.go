@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// rootCacheLimit caps how many directories the root cache remembers; the
+// least recently used are dropped first.
+const rootCacheLimit = 500
+
+// rootCacheEntry is the sandbox root found from one directory, with the
+// mtime of every directory the search looked in. Adding or removing a
+// .sandbox directory changes its parent's mtime, so the entry is valid as
+// long as none of them changed.
+type rootCacheEntry struct {
+	Root   string           `json:"root"`   // "" when no sandbox root was found
+	Mtimes map[string]int64 `json:"mtimes"` // directory to mtime in ns
+	Used   int64            `json:"used"`   // unix seconds
+}
+
+// rootCachePath returns the file the root cache is kept in; a variable so
+// tests can keep it out of the user's cache directory.
+var rootCachePath = func() (string, error) {
+	l, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Cache, "roots.json"), nil
+}
+
+// cachedSandboxRoot is findWorkspaceRoot with a cache that survives between
+// invocations, so editors running many commands from deep in a monorepo
+// don't repeat the search each time. The cache is best-effort: if it can't
+// be read or written, the search just runs.
+func cachedSandboxRoot(startPath string) string {
+	path, err := rootCachePath()
+	if err != nil {
+		root, _ := findWorkspaceRoot(startPath)
+		return root
+	}
+	cache := make(map[string]rootCacheEntry)
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	if e, ok := cache[startPath]; ok && e.valid() {
+		return e.Root
+	}
+
+	root, searched := findWorkspaceRoot(startPath)
+	e := rootCacheEntry{Root: root, Mtimes: make(map[string]int64), Used: time.Now().Unix()}
+	for _, dir := range searched {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return root
+		}
+		e.Mtimes[dir] = info.ModTime().UnixNano()
+	}
+	cache[startPath] = e
+	pruneRootCache(cache)
+	writeRootCache(path, cache)
+	return root
+}
+
+// valid reports whether none of the directories e was found through have
+// changed since.
+func (e rootCacheEntry) valid() bool {
+	if len(e.Mtimes) == 0 {
+		return false
+	}
+	for dir, mtime := range e.Mtimes {
+		info, err := os.Stat(dir)
+		if err != nil || info.ModTime().UnixNano() != mtime {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneRootCache drops the least recently used entries over rootCacheLimit.
+func pruneRootCache(cache map[string]rootCacheEntry) {
+	if len(cache) <= rootCacheLimit {
+		return
+	}
+	dirs := make([]string, 0, len(cache))
+	for dir := range cache {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return cache[dirs[i]].Used < cache[dirs[j]].Used })
+	for _, dir := range dirs[:len(dirs)-rootCacheLimit] {
+		delete(cache, dir)
+	}
+}
+
+// writeRootCache replaces the cache file atomically, so concurrent
+// invocations never read a partial file. The cache directory isn't created:
+// until sandbox has other files there the cache is simply not kept, so it
+// never makes a layout look in use.
+func writeRootCache(path string, cache map[string]rootCacheEntry) {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "roots-*.json")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// isolateRootCache points the root cache at a file in a temp directory for
+// the rest of the test, returning its path.
+func isolateRootCache(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "roots.json")
+	orig := rootCachePath
+	rootCachePath = func() (string, error) { return path, nil }
+	t.Cleanup(func() { rootCachePath = orig })
+	return path
+}
+
+func TestCachedSandboxRoot(t *testing.T) {
+	cachePath := isolateRootCache(t)
+	parent := t.TempDir()
+	os.MkdirAll(filepath.Join(parent, ".sandbox"), 0755)
+	child := filepath.Join(parent, "a", "b")
+	os.MkdirAll(child, 0755)
+
+	if got := cachedSandboxRoot(child); got != parent {
+		t.Fatalf("first lookup = %q, want %q", got, parent)
+	}
+
+	// A valid entry is answered from the cache without searching.
+	cache := make(map[string]rootCacheEntry)
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("cache not written: %v", err)
+	}
+	json.Unmarshal(data, &cache)
+	e := cache[child]
+	if len(e.Mtimes) != 3 {
+		t.Errorf("cached mtimes for %d dirs, want 3 (b, a and the root): %v", len(e.Mtimes), e.Mtimes)
+	}
+	e.Root = "/from-cache"
+	cache[child] = e
+	data, _ = json.Marshal(cache)
+	os.WriteFile(cachePath, data, 0644)
+	if got := cachedSandboxRoot(child); got != "/from-cache" {
+		t.Errorf("second lookup = %q, want the cached root", got)
+	}
+
+	// A new .sandbox on the way up changes its parent's mtime, so the
+	// entry is searched again.
+	os.MkdirAll(filepath.Join(parent, "a", ".sandbox"), 0755)
+	if got, want := cachedSandboxRoot(child), filepath.Join(parent, "a"); got != want {
+		t.Errorf("after adding .sandbox = %q, want %q", got, want)
+	}
+}
+
+func TestCachedSandboxRootNoCacheDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "roots.json")
+	orig := rootCachePath
+	rootCachePath = func() (string, error) { return path, nil }
+	defer func() { rootCachePath = orig }()
+
+	dir := t.TempDir()
+	if got := cachedSandboxRoot(dir); got != "" {
+		t.Errorf("cachedSandboxRoot = %q, want no root", got)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Errorf("cache directory was created")
+	}
+}
+
+func TestPruneRootCache(t *testing.T) {
+	cache := make(map[string]rootCacheEntry)
+	for i := 0; i < rootCacheLimit+10; i++ {
+		cache[fmt.Sprintf("/d/%d", i)] = rootCacheEntry{Used: int64(i)}
+	}
+	pruneRootCache(cache)
+	if len(cache) != rootCacheLimit {
+		t.Fatalf("len = %d, want %d", len(cache), rootCacheLimit)
+	}
+	for _, e := range cache {
+		if e.Used < 10 {
+			t.Errorf("kept entry used at %d; the oldest should go first", e.Used)
+		}
+	}
+}
+
+func TestCachedSandboxRootFallsBackToGitRoot(t *testing.T) {
+	isolateRootCache(t)
+	repo := t.TempDir()
+	os.Mkdir(filepath.Join(repo, ".git"), 0755)
+	child := filepath.Join(repo, "src", "pkg")
+	os.MkdirAll(child, 0755)
+
+	if got := cachedSandboxRoot(child); got != repo {
+		t.Errorf("without .sandbox = %q, want the git root %q", got, repo)
+	}
+
+	// A .sandbox below the repository root still wins.
+	os.Mkdir(filepath.Join(repo, "src", ".sandbox"), 0755)
+	if got, want := cachedSandboxRoot(child), filepath.Join(repo, "src"); got != want {
+		t.Errorf("with .sandbox = %q, want %q", got, want)
+	}
+
+	// A repository at home, such as for dotfiles, is never a root.
+	t.Setenv("HOME", repo)
+	if got := gitWorkTreeRoot(child); got != "" {
+		t.Errorf("gitWorkTreeRoot under a home repository = %q, want none", got)
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// scopeScriptPath is where sandbox-scope is synced in the container.
+const scopeScriptPath = "/usr/local/bin/sandbox-scope"
+
+// scopedArgs wraps args so they run as user inside a cgroup scope named name
+// with the given ceilings. The result must be exec'd as root; sandbox-scope
+// drops to user itself.
+func scopedArgs(name, user string, limits ResourceLimits, args []string) []string {
+	wrapped := []string{scopeScriptPath, "--name", name, "--user", user}
+	if limits.Memory != "" {
+		wrapped = append(wrapped, "--memory", limits.Memory)
+	}
+	if limits.CPUs > 0 {
+		wrapped = append(wrapped, "--cpus", strconv.FormatFloat(limits.CPUs, 'f', -1, 64))
+	}
+	wrapped = append(wrapped, "--")
+	return append(wrapped, args...)
+}
+
+// killScope kills every process in the named scope. It is best effort: a
+// container without writable cgroups has no scope to kill.
+func killScope(container, name string) {
+	exec.Command("docker", "exec", "-u", "root", container, scopeScriptPath, "--kill", name).Run()
+}
+
+// hookScopeName names the scope a hook runs in, stable across runs so a
+// leftover scope from a timed-out run is reused rather than accumulating.
+func hookScopeName(hook OnSyncHook) string {
+	return fmt.Sprintf("hook-%s", hook.stateKey())
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SecretPattern is a named regular expression for a known credential format.
+// Extra patterns can be added under secret_patterns in config.
+type SecretPattern struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+}
+
+// SecretFinding is a likely credential found in a synced file.
+type SecretFinding struct {
+	Source string // host path the item came from
+	Dest   string // container path it was headed for
+	Line   int    // 0 when the file's path alone marks it
+	Kind   string // pattern name, or "high-entropy string"
+}
+
+// secretDetector reports the kind of secret on a line, or "" if none.
+type secretDetector func(line string) string
+
+// builtinSecretPatterns covers common token formats with distinctive prefixes.
+var builtinSecretPatterns = []SecretPattern{
+	{Name: "Anthropic API key", Regex: `sk-ant-[A-Za-z0-9_-]{20,}`},
+	{Name: "OpenAI API key", Regex: `sk-(proj-)?[A-Za-z0-9_-]{32,}`},
+	{Name: "GitHub token", Regex: `\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b|\bgithub_pat_[A-Za-z0-9_]{40,}\b`},
+	{Name: "AWS access key", Regex: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "Google API key", Regex: `\bAIza[0-9A-Za-z_-]{35}\b`},
+	{Name: "Slack token", Regex: `\bxox[abprs]-[A-Za-z0-9-]{10,}`},
+	{Name: "GitLab token", Regex: `\bglpat-[A-Za-z0-9_-]{20,}`},
+	{Name: "npm token", Regex: `\bnpm_[A-Za-z0-9]{36}\b`},
+	{Name: "Hugging Face token", Regex: `\bhf_[A-Za-z0-9]{34,}\b`},
+	{Name: "PyPI token", Regex: `\bpypi-AgEIcHlwaS5vcmc[A-Za-z0-9_-]{50,}`},
+	{Name: "Stripe key", Regex: `\b[rs]k_live_[A-Za-z0-9]{24,}`},
+	{Name: "DigitalOcean token", Regex: `\bdo[opr]_v1_[a-f0-9]{64}\b`},
+	{Name: "AWS secret key", Regex: `(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`},
+	{Name: "Azure storage key", Regex: `AccountKey=[A-Za-z0-9+/=]{40,}`},
+	{Name: "Google service account key", Regex: `"type"\s*:\s*"service_account"`},
+	{Name: "password in URL", Regex: `\b[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s:@$]+@`},
+	{Name: "private key", Regex: `-----BEGIN ([A-Z]+ )?PRIVATE KEY( BLOCK)?-----`},
+}
+
+// secretFilePatterns match the paths of files that hold private keys or
+// cloud credentials whatever their contents, including binary ones.
+var secretFilePatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"SSH private key", regexp.MustCompile(`(^|/)id_(rsa|dsa|ecdsa|ed25519)(_sk)?$`)},
+	{"AWS credentials", regexp.MustCompile(`(^|/)\.aws/credentials$`)},
+	{"Google Cloud credentials", regexp.MustCompile(`(^|/)\.config/gcloud/(application_default_credentials\.json|credentials\.db|access_tokens\.db|legacy_credentials/)`)},
+	{"Azure credentials", regexp.MustCompile(`(^|/)\.azure/(accessTokens\.json|msal_token_cache\.(json|bin))$`)},
+	{"Kubernetes config", regexp.MustCompile(`(^|/)\.kube/config$`)},
+	{"git credentials", regexp.MustCompile(`(^|/)\.git-credentials$`)},
+	{"netrc", regexp.MustCompile(`(^|/)[._]netrc$`)},
+	{"PyPI credentials", regexp.MustCompile(`(^|/)\.pypirc$`)},
+	{"Terraform credentials", regexp.MustCompile(`(^|/)\.terraform\.d/credentials\.tfrc\.json$`)},
+	{"key store", regexp.MustCompile(`\.(p12|pfx|jks|keystore)$`)},
+}
+
+// SecretAllow exempts findings from secret scanning. Every field that is set
+// must match: Path a glob for the host or container path (just the file name
+// if it has no "/"), Kind the finding's kind, and Line a regular expression
+// for the line it is on.
+type SecretAllow struct {
+	Path string `yaml:"path,omitempty"`
+	Kind string `yaml:"kind,omitempty"`
+	Line string `yaml:"line,omitempty"`
+}
+
+func validateSecretAllow(a SecretAllow) error {
+	if a.Path == "" && a.Kind == "" && a.Line == "" {
+		return fmt.Errorf("secret_allow entry needs a path, kind or line")
+	}
+	if _, err := filepath.Match(a.Path, ""); err != nil {
+		return fmt.Errorf("secret_allow path %q: %w", a.Path, err)
+	}
+	if _, err := regexp.Compile(a.Line); err != nil {
+		return fmt.Errorf("secret_allow line %q: %w", a.Line, err)
+	}
+	return nil
+}
+
+// secretAllowed reports whether an entry of allow covers finding f on line.
+// Entries are validated when the config loads.
+func secretAllowed(allow []SecretAllow, f SecretFinding, line string) bool {
+	for _, a := range allow {
+		if a.Kind != "" && !strings.EqualFold(a.Kind, f.Kind) {
+			continue
+		}
+		if a.Line != "" {
+			if re, err := regexp.Compile(a.Line); err != nil || !re.MatchString(line) {
+				continue
+			}
+		}
+		if a.Path != "" && !secretAllowPathMatch(expandTilde(a.Path), f.Source) && !secretAllowPathMatch(a.Path, f.Dest) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// secretAllowPathMatch matches p against a SecretAllow path: the file name
+// for a bare glob, otherwise the whole path or a directory containing it.
+func secretAllowPathMatch(pattern, p string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, filepath.Base(p))
+		return ok
+	}
+	if ok, _ := filepath.Match(pattern, p); ok {
+		return true
+	}
+	_, inside := pathWithin(p, pattern)
+	return inside
+}
+
+// sanctionedSecretDirs are container paths where credentials are expected.
+var sanctionedSecretDirs = []string{"/home/agent/.claude/"}
+
+// secretDetectors builds the pattern detectors for cfg plus the entropy check.
+// Invalid config patterns are reported and skipped.
+func secretDetectors(cfg *SandboxConfig) []secretDetector {
+	patterns := append([]SecretPattern{}, builtinSecretPatterns...)
+	if cfg != nil {
+		patterns = append(patterns, cfg.SecretPatterns...)
+	}
+	var detectors []secretDetector
+	for _, p := range patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			Warnf(WarnConfig, "secret pattern %q: %v, skipping", p.Name, err)
+			continue
+		}
+		name := p.Name
+		detectors = append(detectors, func(line string) string {
+			if re.MatchString(line) {
+				return name
+			}
+			return ""
+		})
+	}
+	return append(detectors, detectHighEntropy)
+}
+
+// assignedValueRe captures values assigned to secret-sounding names, e.g.
+// `API_TOKEN=...` or `"password": "..."`.
+var assignedValueRe = regexp.MustCompile(`(?i)(key|token|secret|passw(or)?d|credential)[A-Za-z0-9_]*["']?\s*[:=]\s*["']?([A-Za-z0-9+/_=.-]{20,})`)
+
+// detectHighEntropy flags long random-looking values assigned to names that
+// suggest a credential. Requiring both keeps hashes and IDs in ordinary code
+// from tripping it.
+func detectHighEntropy(line string) string {
+	for _, m := range assignedValueRe.FindAllStringSubmatch(line, -1) {
+		if shannonEntropy(m[3]) >= 4.0 {
+			return "high-entropy string"
+		}
+	}
+	return ""
+}
+
+// shannonEntropy returns the entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	n := float64(len([]rune(s)))
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// scanSyncItems looks for likely credentials in items copied from the host.
+// Generated items (env file, key files, settings) and items bound for the
+// sanctioned credentials directory are skipped, as are binary files.
+func scanSyncItems(cfg *SandboxConfig, items []SyncItem) []SecretFinding {
+	detectors := secretDetectors(cfg)
+	var allow []SecretAllow
+	if cfg != nil {
+		allow = cfg.SecretAllow
+	}
+	var findings []SecretFinding
+	add := func(f SecretFinding, line string) {
+		if !secretAllowed(allow, f, line) {
+			findings = append(findings, f)
+		}
+	}
+	for _, item := range items {
+		if item.Source == "" || item.Source == keyProvidersSource || sanctionedSecretPath(item.Dest) {
+			continue
+		}
+		if kind := secretFileKind(item); kind != "" {
+			add(SecretFinding{Source: item.Source, Dest: item.Dest, Kind: kind}, "")
+			continue
+		}
+		if bytes.IndexByte(item.Data, 0) >= 0 {
+			continue
+		}
+		for i, line := range strings.Split(string(item.Data), "\n") {
+			for _, detect := range detectors {
+				if kind := detect(line); kind != "" {
+					add(SecretFinding{Source: item.Source, Dest: item.Dest, Line: i + 1, Kind: kind}, line)
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// secretFileKind returns the kind of credential file item's host or
+// container path marks it as, or "" if neither does.
+func secretFileKind(item SyncItem) string {
+	for _, p := range secretFilePatterns {
+		if p.re.MatchString(filepath.ToSlash(item.Source)) || p.re.MatchString(item.Dest) {
+			return p.kind
+		}
+	}
+	return ""
+}
+
+// envFileScanItems returns cfg's env_files as items to scan. Their values
+// end up in the container's ~/.sandbox-env, and dotenv files copied from
+// elsewhere often hold more credentials than the sandbox needs.
+func envFileScanItems(cfg *SandboxConfig, wsPath string) []SyncItem {
+	var items []SyncItem
+	for _, f := range cfg.EnvFiles {
+		path := expandTilde(f)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(wsPath, path)
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			items = append(items, SyncItem{Source: path, Dest: "/home/agent/.sandbox-env", Data: data})
+		}
+	}
+	return items
+}
+
+func sanctionedSecretPath(dest string) bool {
+	for _, dir := range sanctionedSecretDirs {
+		if strings.HasPrefix(dest, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSyncSecrets reports findings as warnings, or as an error when strict
+// or the config sets strict_secrets.
+func checkSyncSecrets(cfg *SandboxConfig, items []SyncItem, strict bool) error {
+	findings := scanSyncItems(cfg, items)
+	if len(findings) == 0 {
+		return nil
+	}
+	for _, f := range findings {
+		if f.Line == 0 {
+			Warnf(WarnSecrets, "possible %s file %s (syncs to %s)", f.Kind, f.Source, f.Dest)
+			continue
+		}
+		Warnf(WarnSecrets, "possible %s in %s:%d (syncs to %s)", f.Kind, f.Source, f.Line, f.Dest)
+	}
+	if strict || (cfg != nil && cfg.StrictSecrets) {
+		return fmt.Errorf("sync blocked: %d possible secret(s) in synced files; move credentials to set-key or env", len(findings))
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// seccompProfile is the bundled seccomp profile used unless the config picks
+// another. It denies every call Docker's default profile blocks for an
+// unprivileged container (module loading, mounts, namespaces, keyrings,
+// bpf, perf and the like), and also io_uring, process_vm_readv/writev,
+// kcmp, the new mount API and fanotify, none of which agents need.
+//
+//go:embed image/seccomp.json
+var seccompProfile []byte
+
+// LabelSecurity records the security options a container was created with,
+// so a changed config can be pointed out.
+const LabelSecurity = "sandbox.security"
+
+// SecurityConfig picks the seccomp and AppArmor profiles, capabilities and
+// privilege settings the container is created with.
+type SecurityConfig struct {
+	Seccomp  string `yaml:"seccomp,omitempty"`  // "sandbox" (default), "docker", "unconfined" or a profile's path
+	AppArmor string `yaml:"apparmor,omitempty"` // a profile loaded on the Docker host, or "unconfined"; default Docker's
+	// CapAdd grants capabilities beyond baseCaps, e.g. SYS_PTRACE for
+	// debuggers, or ALL for Docker's full set and more.
+	CapAdd []string `yaml:"cap_add,omitempty"`
+	// NewPrivileges lets setuid programs gain privileges, turning off
+	// no-new-privileges.
+	NewPrivileges bool `yaml:"new_privileges,omitempty"`
+}
+
+// HardeningConfig holds settings that only ever tighten the container, so
+// either config may turn them on.
+type HardeningConfig struct {
+	// ReadonlyRootfs mounts the image read-only, so a compromised agent
+	// can't persist changes outside the workspace and readonlyVolumes.
+	ReadonlyRootfs bool `yaml:"readonly_rootfs,omitempty"`
+}
+
+// baseCaps are the only capabilities the container keeps, all for what
+// sandbox itself does as root: NET_ADMIN and NET_RAW for the firewall,
+// CHOWN, DAC_OVERRIDE, FOWNER and FSETID for sync, SETUID and SETGID for
+// dropping to other users, and KILL for ending sessions. The agent user
+// has none of them.
+var baseCaps = []string{"CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "NET_ADMIN", "NET_RAW", "SETGID", "SETUID"}
+
+// sshCaps are also kept with ssh.enabled, for sshd's privilege separation
+// and login records.
+var sshCaps = []string{"AUDIT_WRITE", "SYS_CHROOT"}
+
+// readonlyTmpfs are mounted fresh on every start with readonly_rootfs, for
+// the scratch space programs expect to write to and /opt, where sandbox
+// keeps the firewall script and rules and its other state. As /opt starts
+// out empty, the firewall script is copied in before the firewall is
+// initialised, and the next sync writes the rest.
+var readonlyTmpfs = []string{
+	"/tmp:rw,exec,nosuid,nodev,mode=1777",
+	"/var/tmp:rw,exec,nosuid,nodev,mode=1777",
+	"/run:rw,nosuid,nodev,mode=755",
+	"/var/log:rw,noexec,nosuid,nodev",
+	"/opt:rw,exec,nosuid,nodev,mode=755",
+}
+
+// readonlyVolumes stay writable with readonly_rootfs, as anonymous volumes
+// Docker fills from the image. Unlike tmpfs they last until the container
+// is removed, so only the agent's home is one: a restart keeps the agent's
+// state but nothing sandbox runs as root. sandbox's scripts in
+// /usr/local/bin are the image's.
+var readonlyVolumes = []string{"/home/agent"}
+
+// readonlyGitConfigPath takes the place of gitConfigPath with
+// readonly_rootfs, as /etc can't be written. GIT_CONFIG_SYSTEM points git
+// at it.
+const readonlyGitConfigPath = "/opt/sandbox-gitconfig"
+
+// readonlyRootfs reports whether cfg asks for a read-only root filesystem.
+func readonlyRootfs(cfg *SandboxConfig) bool {
+	return cfg != nil && cfg.Hardening.ReadonlyRootfs
+}
+
+// capNameRe matches a capability name without its CAP_ prefix.
+var capNameRe = regexp.MustCompile(`^[A-Z][A-Z_]*$`)
+
+// normalizeCap returns the capability name Docker expects: upper case,
+// without the CAP_ prefix.
+func normalizeCap(c string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(c)), "CAP_")
+}
+
+// containerCaps returns the capabilities added back after dropping all of
+// them, sorted and without duplicates.
+func containerCaps(cfg *SandboxConfig) []string {
+	caps := slices.Clone(baseCaps)
+	if cfg != nil {
+		if cfg.SSH.Enabled {
+			caps = append(caps, sshCaps...)
+		}
+		for _, c := range cfg.Security.CapAdd {
+			caps = append(caps, normalizeCap(c))
+		}
+	}
+	slices.Sort(caps)
+	return slices.Compact(caps)
+}
+
+// Special security.seccomp values.
+const (
+	SeccompSandbox    = "sandbox"    // the bundled profile
+	SeccompDocker     = "docker"     // Docker's default profile
+	SeccompUnconfined = "unconfined" // no seccomp filtering
+)
+
+func validateSecurity(s SecurityConfig) error {
+	switch s.Seccomp {
+	case "", SeccompSandbox, SeccompDocker, SeccompUnconfined:
+	default:
+		if !filepath.IsAbs(expandTilde(s.Seccomp)) {
+			return fmt.Errorf("invalid security.seccomp %q, want %q, %q, %q or an absolute path", s.Seccomp, SeccompSandbox, SeccompDocker, SeccompUnconfined)
+		}
+	}
+	if strings.ContainsAny(s.AppArmor, " ,=") {
+		return fmt.Errorf("invalid security.apparmor %q", s.AppArmor)
+	}
+	for _, c := range s.CapAdd {
+		if !capNameRe.MatchString(normalizeCap(c)) {
+			return fmt.Errorf("invalid capability %q in security.cap_add", c)
+		}
+	}
+	return nil
+}
+
+// workspaceSecurity returns s, from a workspace config, without the
+// settings that could loosen the container's confinement, warning about
+// each. The workspace config is writable by the agent, so it may only
+// tighten: it may pick the bundled seccomp profile when global, the global
+// config's settings, leave seccomp unset or Docker's, but no other profile,
+// and the AppArmor profile, capabilities and new privileges are the global
+// config's. So is ssh.enabled, which adds sshCaps; LoadConfig drops it.
+func workspaceSecurity(s, global SecurityConfig) SecurityConfig {
+	if s.Seccomp != "" && (s.Seccomp != SeccompSandbox || (global.Seccomp != "" && global.Seccomp != SeccompDocker)) {
+		Warnf(WarnConfig, "security.seccomp can only be %q over Docker's default in a workspace config, ignoring %q", SeccompSandbox, s.Seccomp)
+		s.Seccomp = ""
+	}
+	if s.AppArmor != "" {
+		Warnf(WarnConfig, "security.apparmor is only read from the global config, ignoring the workspace's")
+		s.AppArmor = ""
+	}
+	if len(s.CapAdd) > 0 {
+		Warnf(WarnConfig, "security.cap_add is only read from the global config, ignoring the workspace's")
+		s.CapAdd = nil
+	}
+	if s.NewPrivileges {
+		Warnf(WarnConfig, "security.new_privileges is only read from the global config, ignoring the workspace's")
+		s.NewPrivileges = false
+	}
+	return s
+}
+
+// seccompSetting returns the configured seccomp profile, defaulting to the
+// bundled one.
+func seccompSetting(cfg *SandboxConfig) string {
+	if cfg == nil || cfg.Security.Seccomp == "" {
+		return SeccompSandbox
+	}
+	return cfg.Security.Seccomp
+}
+
+// securityLabel describes cfg's security options for LabelSecurity.
+func securityLabel(cfg *SandboxConfig) string {
+	label := "seccomp=" + seccompSetting(cfg)
+	if cfg != nil && cfg.Security.AppArmor != "" {
+		label += ",apparmor=" + cfg.Security.AppArmor
+	}
+	label += ",caps=" + strings.Join(containerCaps(cfg), "+")
+	if cfg != nil && cfg.Security.NewPrivileges {
+		label += ",new-privileges"
+	}
+	if readonlyRootfs(cfg) {
+		label += ",readonly-rootfs"
+	}
+	return label
+}
+
+// securityArgs returns the docker run arguments that confine the container:
+// all capabilities but containerCaps dropped, no-new-privileges unless
+// security.new_privileges, the seccomp and AppArmor profiles, and with
+// hardening.readonly_rootfs a read-only root plus its writable mounts. The Docker
+// CLI reads a seccomp profile from the file it is given, so the bundled
+// profile is written to a temporary file, which the returned cleanup func
+// removes once the container is created.
+func securityArgs(cfg *SandboxConfig) ([]string, func(), error) {
+	opts, cleanup, err := securityOpts(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	args := []string{"--cap-drop", "ALL"}
+	for _, c := range containerCaps(cfg) {
+		args = append(args, "--cap-add", c)
+	}
+	if cfg == nil || !cfg.Security.NewPrivileges {
+		args = append(args, "--security-opt", "no-new-privileges")
+	}
+	for _, opt := range opts {
+		args = append(args, "--security-opt", opt)
+	}
+	if readonlyRootfs(cfg) {
+		args = append(args, "--read-only")
+		for _, t := range readonlyTmpfs {
+			args = append(args, "--tmpfs", t)
+		}
+		for _, v := range readonlyVolumes {
+			args = append(args, "-v", v)
+		}
+		args = append(args, "-e", "GIT_CONFIG_SYSTEM="+readonlyGitConfigPath)
+	}
+	return args, cleanup, nil
+}
+
+// securityOpts returns the docker run --security-opt values for cfg's
+// seccomp and AppArmor profiles.
+func securityOpts(cfg *SandboxConfig) ([]string, func(), error) {
+	cleanup := func() {}
+	var opts []string
+	switch s := seccompSetting(cfg); s {
+	case SeccompDocker:
+	case SeccompUnconfined:
+		opts = append(opts, "seccomp=unconfined")
+	case SeccompSandbox:
+		f, err := os.CreateTemp("", "sandbox-seccomp-*.json")
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() { os.Remove(f.Name()) }
+		_, err = f.Write(seccompProfile)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("write seccomp profile: %w", err)
+		}
+		opts = append(opts, "seccomp="+f.Name())
+	default:
+		path := expandTilde(s)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("security.seccomp: %w", err)
+		}
+		if !json.Valid(data) {
+			return nil, nil, fmt.Errorf("security.seccomp: %s isn't a JSON seccomp profile", path)
+		}
+		opts = append(opts, "seccomp="+path)
+	}
+	if cfg != nil && cfg.Security.AppArmor != "" {
+		opts = append(opts, "apparmor="+cfg.Security.AppArmor)
+	}
+	return opts, cleanup, nil
+}
+
+// warnIfSecurityChanged warns if the container was created with different
+// security options than the config now asks for. They can only be set when a
+// container is created. Containers from before the label existed aren't
+// warned about.
+func warnIfSecurityChanged(container, want string) {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelSecurity+`"}}`, container).Output()
+	if err != nil {
+		return
+	}
+	if have := strings.TrimSpace(string(out)); have != "" && have != want {
+		Warnf(WarnContainer, "security has changed since this sandbox was created. To apply it, run `sandbox rm <folder>` and then restart.")
+	}
+}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSeccompProfile(t *testing.T) {
+	var p struct {
+		DefaultAction string `json:"defaultAction"`
+		Syscalls      []struct {
+			Names  []string `json:"names"`
+			Action string   `json:"action"`
+			Args   []struct {
+				Value uint64 `json:"value"`
+			} `json:"args"`
+		} `json:"syscalls"`
+	}
+	if err := json.Unmarshal(seccompProfile, &p); err != nil {
+		t.Fatalf("bundled profile: %v", err)
+	}
+	denied := make(map[string]bool)
+	var cloneFlags uint64
+	for _, s := range p.Syscalls {
+		if s.Action != "SCMP_ACT_ERRNO" {
+			t.Errorf("%v: action %s, want SCMP_ACT_ERRNO", s.Names, s.Action)
+		}
+		for _, n := range s.Names {
+			if n == "clone" {
+				for _, a := range s.Args {
+					cloneFlags |= a.Value
+				}
+				continue
+			}
+			denied[n] = true
+		}
+	}
+	for _, n := range []string{"mount", "unshare", "setns", "bpf", "keyctl", "init_module",
+		"io_uring_setup", "process_vm_writev", "userfaultfd", "perf_event_open", "clone3"} {
+		if !denied[n] {
+			t.Errorf("%s isn't denied", n)
+		}
+	}
+	// CLONE_NEWNS | CLONE_NEWCGROUP | CLONE_NEWUTS | CLONE_NEWIPC |
+	// CLONE_NEWUSER | CLONE_NEWPID | CLONE_NEWNET, as Docker masks them.
+	if cloneFlags != 0x7E020000 {
+		t.Errorf("clone namespace flags = %#x, want %#x", cloneFlags, 0x7E020000)
+	}
+}
+
+func TestValidateSecurity(t *testing.T) {
+	for _, s := range []SecurityConfig{{}, {Seccomp: "docker"}, {Seccomp: "unconfined"}, {Seccomp: "/etc/seccomp.json"}, {Seccomp: "~/seccomp.json", AppArmor: "sandbox-agent"}} {
+		if err := validateSecurity(s); err != nil {
+			t.Errorf("validateSecurity(%+v): %v", s, err)
+		}
+	}
+	for _, s := range []SecurityConfig{{Seccomp: "strict"}, {Seccomp: "profiles/seccomp.json"}, {AppArmor: "a,b"}} {
+		if err := validateSecurity(s); err == nil {
+			t.Errorf("validateSecurity(%+v) succeeded, want error", s)
+		}
+	}
+}
+
+func TestSecurityOpts(t *testing.T) {
+	opts, cleanup, err := securityOpts(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts) != 1 || !strings.HasPrefix(opts[0], "seccomp=") {
+		t.Fatalf("default opts = %q, want the bundled profile", opts)
+	}
+	path := strings.TrimPrefix(opts[0], "seccomp=")
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(seccompProfile) {
+		t.Errorf("bundled profile file not written: %v", err)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("cleanup left %s behind", path)
+	}
+
+	opts, cleanup, _ = securityOpts(&SandboxConfig{Security: SecurityConfig{Seccomp: "docker", AppArmor: "sandbox-agent"}})
+	cleanup()
+	if !slices.Equal(opts, []string{"apparmor=sandbox-agent"}) {
+		t.Errorf("docker seccomp opts = %q", opts)
+	}
+
+	custom := filepath.Join(t.TempDir(), "profile.json")
+	os.WriteFile(custom, []byte(`{"defaultAction": "SCMP_ACT_ALLOW"}`), 0644)
+	opts, cleanup, _ = securityOpts(&SandboxConfig{Security: SecurityConfig{Seccomp: custom}})
+	cleanup()
+	if !slices.Equal(opts, []string{"seccomp=" + custom}) {
+		t.Errorf("custom seccomp opts = %q", opts)
+	}
+
+	os.WriteFile(custom, []byte("not json"), 0644)
+	if _, _, err := securityOpts(&SandboxConfig{Security: SecurityConfig{Seccomp: custom}}); err == nil {
+		t.Error("a profile that isn't JSON should fail")
+	}
+
+	if got, want := securityLabel(&SandboxConfig{Security: SecurityConfig{AppArmor: "x"}}), "seccomp=sandbox,apparmor=x,caps="+strings.Join(baseCaps, "+"); got != want {
+		t.Errorf("securityLabel = %q, want %q", got, want)
+	}
+}
+
+func TestSecurityArgs(t *testing.T) {
+	args, cleanup, err := securityArgs(&SandboxConfig{Security: SecurityConfig{Seccomp: "docker"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	got := strings.Join(args, " ")
+	want := "--cap-drop ALL --cap-add CHOWN --cap-add DAC_OVERRIDE --cap-add FOWNER --cap-add FSETID --cap-add KILL " +
+		"--cap-add NET_ADMIN --cap-add NET_RAW --cap-add SETGID --cap-add SETUID --security-opt no-new-privileges"
+	if got != want {
+		t.Errorf("securityArgs =\n  %s\nwant\n  %s", got, want)
+	}
+
+	cfg := &SandboxConfig{
+		SSH:      SSHConfig{Enabled: true},
+		Security: SecurityConfig{Seccomp: "docker", CapAdd: []string{"cap_sys_ptrace", "KILL"}, NewPrivileges: true},
+	}
+	args, cleanup, _ = securityArgs(cfg)
+	cleanup()
+	got = strings.Join(args, " ")
+	for _, want := range []string{"--cap-add SYS_PTRACE", "--cap-add SYS_CHROOT", "--cap-add AUDIT_WRITE"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("securityArgs = %s, missing %s", got, want)
+		}
+	}
+	if strings.Count(got, "KILL") != 1 || strings.Contains(got, "no-new-privileges") {
+		t.Errorf("securityArgs = %s, want KILL once and new privileges allowed", got)
+	}
+	if err := validateSecurity(SecurityConfig{CapAdd: []string{"sys-admin"}}); err == nil {
+		t.Error("an invalid capability name should fail validation")
+	}
+}
+
+func TestReadonlyRootfs(t *testing.T) {
+	cfg := &SandboxConfig{Security: SecurityConfig{Seccomp: "docker"}, Hardening: HardeningConfig{ReadonlyRootfs: true}}
+	args, cleanup, err := securityArgs(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	got := strings.Join(args, " ")
+	for _, want := range []string{"--read-only", "--tmpfs /tmp:", "--tmpfs /run:", "--tmpfs /opt:", "-v /home/agent",
+		"-e GIT_CONFIG_SYSTEM=" + readonlyGitConfigPath} {
+		if !strings.Contains(got, want) {
+			t.Errorf("securityArgs = %s, missing %s", got, want)
+		}
+	}
+	// Nothing but the agent's home may outlive a restart.
+	for _, bad := range []string{"-v /opt", "-v /usr/local/bin"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("securityArgs = %s, want no %s", got, bad)
+		}
+	}
+	if !strings.HasSuffix(securityLabel(cfg), ",readonly-rootfs") {
+		t.Errorf("securityLabel = %q, want readonly-rootfs recorded", securityLabel(cfg))
+	}
+
+	// Everything sync writes outside the home directory must land on a
+	// writable mount.
+	cfg.Git = GitConfig{Source: GitSourceConfig, Name: "A", Email: "a@example.com"}
+	cfg.HostTools = []HostTool{{Name: "t", Cmd: "true"}}
+	items, err := buildSyncManifest(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(items, func(it SyncItem) bool { return it.Dest == readonlyGitConfigPath }) {
+		t.Errorf("git config not synced to %s", readonlyGitConfigPath)
+	}
+	for _, it := range items {
+		if !strings.HasPrefix(it.Dest, "/home/agent/") && !strings.HasPrefix(it.Dest, "/opt/") {
+			t.Errorf("sync writes %s, which is read-only", it.Dest)
+		}
+	}
+}
+
+func TestWorkspaceSecurity(t *testing.T) {
+	useRecordingUI(t)
+	for _, s := range []SecurityConfig{{}, {Seccomp: "sandbox"}} {
+		resetWarnings(t)
+		if got := workspaceSecurity(s, SecurityConfig{}); !reflect.DeepEqual(got, s) {
+			t.Errorf("workspaceSecurity(%+v) = %+v, want it unchanged", s, got)
+		}
+		if warningCount() != 0 {
+			t.Errorf("workspaceSecurity(%+v) warned", s)
+		}
+	}
+	for _, s := range []SecurityConfig{{Seccomp: "docker"}, {Seccomp: "unconfined"}, {Seccomp: "/workspace/seccomp.json"}, {AppArmor: "unconfined"},
+		{CapAdd: []string{"SYS_ADMIN"}}, {NewPrivileges: true}} {
+		resetWarnings(t)
+		if got := workspaceSecurity(s, SecurityConfig{}); !reflect.DeepEqual(got, SecurityConfig{}) {
+			t.Errorf("workspaceSecurity(%+v) = %+v, want it all dropped", s, got)
+		}
+		if warningCount() != 1 {
+			t.Errorf("workspaceSecurity(%+v): %d warnings, want 1", s, warningCount())
+		}
+	}
+	for global, want := range map[string]string{"docker": "sandbox", "unconfined": "", "/etc/seccomp.json": ""} {
+		if got := workspaceSecurity(SecurityConfig{Seccomp: "sandbox"}, SecurityConfig{Seccomp: global}); got.Seccomp != want {
+			t.Errorf("workspace sandbox over global %q: got %q, want %q", global, got.Seccomp, want)
+		}
+	}
+}
+
+func TestWorkspaceSeccompCantLoosen(t *testing.T) {
+	custom := filepath.Join(t.TempDir(), "seccomp.json")
+	for _, global := range []string{"sandbox", custom} {
+		tmpHome := t.TempDir()
+		t.Setenv("HOME", tmpHome)
+		t.Setenv("ZSH_THEME", "")
+		useRecordingUI(t)
+		os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+		os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte("security:\n  seccomp: "+global+"\n"), 0644)
+		ws := t.TempDir()
+		os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+		os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("security:\n  seccomp: docker\n"), 0644)
+
+		cfg, err := LoadConfig(ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := seccompSetting(cfg); got != global {
+			t.Errorf("global %q with workspace docker: got %q", global, got)
+		}
+	}
+}
+
+func TestWorkspaceSSHKeepsCaps(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("ZSH_THEME", "")
+	useRecordingUI(t)
+	resetWarnings(t)
+	os.MkdirAll(filepath.Join(tmpHome, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".sandbox", "config.yaml"), []byte("env:\n  EDITOR: vim\n"), 0644)
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("ssh:\n  enabled: true\n"), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := containerCaps(cfg); !slices.Equal(got, baseCaps) {
+		t.Errorf("caps = %v, want %v", got, baseCaps)
+	}
+	if got, want := securityLabel(cfg), securityLabel(nil); got != want {
+		t.Errorf("label = %q, want %q", got, want)
+	}
+	if warningCount() != 1 {
+		t.Errorf("want one warning for the ignored ssh.enabled, got %d", warningCount())
+	}
+}
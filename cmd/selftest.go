@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed image/Dockerfile.selftest
+var selftestDockerfile []byte
+
+const selftestImage = "sandbox-selftest"
+
+// Probe targets for the firewall check: the first is allowlisted in the
+// selftest config, the second must be blocked.
+var (
+	selftestAllowed = "github.com"
+	selftestBlocked = "example.com"
+)
+
+// Selftest creates a disposable sandbox from a small test image and checks
+// that exec, sync and the firewall work, then removes it. Each step is
+// reported to out as it finishes. It returns an error naming the first step
+// that failed; later steps are skipped, but cleanup always runs.
+func Selftest(out io.Writer) (err error) {
+	step := func(what string, err error) error {
+		if err != nil {
+			fmt.Fprintf(out, "FAIL  %s: %v\n", what, err)
+			return fmt.Errorf("selftest failed at %s", what)
+		}
+		fmt.Fprintf(out, "ok    %s\n", what)
+		return nil
+	}
+
+	if err := step("docker", DockerAvailable()); err != nil {
+		return err
+	}
+	if err := step("test image", ensureSelftestImage()); err != nil {
+		return err
+	}
+
+	ws, err := os.MkdirTemp("", "sandbox-selftest-*")
+	if err != nil {
+		return err
+	}
+	// On macOS the temp dir is reached through the /var symlink, which
+	// Docker Desktop doesn't share; mount the resolved path.
+	if resolved, err := filepath.EvalSymlinks(ws); err == nil {
+		ws = resolved
+	}
+	name := filepath.Base(ws)
+	defer func() {
+		exec.Command("docker", "rm", "-f", name).Run()
+		os.RemoveAll(ws)
+		var cleanupErr error
+		if ContainerExists(name) {
+			cleanupErr = fmt.Errorf("container %s still exists", name)
+		}
+		if stepErr := step("cleanup", cleanupErr); err == nil {
+			err = stepErr
+		}
+	}()
+
+	if err := step("create sandbox", startSelftestContainer(name, ws)); err != nil {
+		return err
+	}
+	if err := step("exec", selftestExec(name, ws)); err != nil {
+		return err
+	}
+	token := "selftest-" + filepath.Base(ws)
+	cfg := &SandboxConfig{
+		Env:      map[string]string{"SANDBOX_SELFTEST": token},
+		Firewall: FirewallConfig{Allow: []FirewallEntry{{Domain: selftestAllowed}}},
+	}
+	if err := step("sync", selftestSync(name, ws, cfg, token)); err != nil {
+		return err
+	}
+	if err := step("firewall allows "+selftestAllowed, selftestProbe(name, selftestAllowed)); err != nil {
+		return err
+	}
+	var leak error
+	if selftestProbe(name, selftestBlocked) == nil {
+		leak = fmt.Errorf("%s was reachable", selftestBlocked)
+	}
+	return step("firewall blocks "+selftestBlocked, leak)
+}
+
+// ensureSelftestImage builds the test image unless it is already built from
+// the current Dockerfile.
+func ensureSelftestImage() error {
+	hash := sha256Hex(selftestDockerfile)
+	have, err := exec.Command("docker", "inspect", "-f",
+		`{{index .Config.Labels "sandbox.image.hash"}}`, selftestImage).Output()
+	if err == nil && strings.TrimSpace(string(have)) == hash {
+		return nil
+	}
+	build := exec.Command("docker", "build", "-q",
+		"--label", "sandbox.image.hash="+hash, "-t", selftestImage, "-")
+	build.Stdin = strings.NewReader(string(selftestDockerfile))
+	if out, err := build.CombinedOutput(); err != nil {
+		return fmt.Errorf("docker build: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// startSelftestContainer starts the disposable sandbox with the same
+// privileges real sandboxes get by default.
+func startSelftestContainer(name, ws string) error {
+	secArgs, cleanup, err := securityArgs(nil)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	args := append([]string{"run", "-d", "--name", name, "--hostname", name}, secArgs...)
+	args = append(args, "-v", ws+":"+ws, "-w", ws, selftestImage)
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker run: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// selftestExec checks commands run as the agent user and can see the
+// mounted workspace.
+func selftestExec(name, ws string) error {
+	if err := os.WriteFile(filepath.Join(ws, "probe"), []byte("mounted\n"), 0644); err != nil {
+		return err
+	}
+	out, err := exec.Command("docker", "exec", name, "sh", "-c", "id -un && cat probe").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if got := string(out); got != "agent\nmounted\n" {
+		return fmt.Errorf("unexpected output %q", got)
+	}
+	return nil
+}
+
+// selftestSync runs a real sync with cfg and checks its env var arrived.
+func selftestSync(name, ws string, cfg *SandboxConfig, token string) error {
+	if err := syncContainer(name, ws, cfg, SyncOptions{Force: true}); err != nil {
+		return err
+	}
+	out, err := exec.Command("docker", "exec", name, "cat", "/home/agent/.sandbox-env").Output()
+	if err != nil {
+		return fmt.Errorf("read synced env file: %w", err)
+	}
+	if !strings.Contains(string(out), token) {
+		return fmt.Errorf("synced env file is missing SANDBOX_SELFTEST")
+	}
+	return nil
+}
+
+// selftestProbe makes an HTTPS request to host from inside the sandbox.
+func selftestProbe(name, host string) error {
+	out, err := exec.Command("docker", "exec", name,
+		"curl", "-sS", "-o", "/dev/null", "--max-time", "10", "https://"+host).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
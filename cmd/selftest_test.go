@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSelftestWithoutDocker(t *testing.T) {
+	fakeTools(t, map[string]string{})
+	var out bytes.Buffer
+	err := Selftest(&out)
+	if err == nil || !strings.Contains(err.Error(), "docker") {
+		t.Errorf("err = %v, want a failure at the docker step", err)
+	}
+	if !strings.HasPrefix(out.String(), "FAIL  docker: docker is not installed") {
+		t.Errorf("output = %q", out.String())
+	}
+	if strings.Contains(out.String(), "cleanup") {
+		t.Error("nothing was created, so there should be no cleanup step")
+	}
+}
+
+func TestSelftest(t *testing.T) {
+	requireDocker(t)
+	useTestConfig(t)
+	var out bytes.Buffer
+	if err := Selftest(&out); err != nil {
+		if strings.Contains(out.String(), "FAIL  firewall allows") {
+			t.Skipf("no network access from the test container:\n%s", out.String())
+		}
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	for _, step := range []string{"exec", "sync", "firewall blocks", "cleanup"} {
+		if !strings.Contains(out.String(), "ok    "+step) {
+			t.Errorf("step %q not reported ok:\n%s", step, out.String())
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ClaudeSession is a Claude Code conversation stored in the sandbox, which
+// `claude --resume <id>` picks back up.
+type ClaudeSession struct {
+	ID       string    `json:"id"`
+	Modified time.Time `json:"modified"`
+	Summary  string    `json:"summary"` // Claude's summary, or the first prompt
+}
+
+// claudeProjectDir returns where Claude Code keeps the sessions it ran in
+// workDir: one directory per working directory, named after its path with
+// every character other than a letter or digit replaced by "-".
+func claudeProjectDir(workDir string) string {
+	return "/home/agent/.claude/projects/" + nonAlnum.ReplaceAllString(workDir, "-")
+}
+
+var nonAlnum = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// sessionListScript prints one tab-separated line per session file in $1:
+// its name, mtime, and its first summary and first user message records.
+// Records are single-line JSON, so hold no raw tabs or newlines.
+const sessionListScript = `cd "$1" 2>/dev/null || exit 0
+for f in *.jsonl; do
+	[ -f "$f" ] || continue
+	printf '%s\t%s\t%s\t%s\n' "$f" "$(stat -c %Y "$f")" \
+		"$(grep -m1 '"type":"summary"' "$f" | head -c 65536)" \
+		"$(grep -m1 '"type":"user"' "$f" | head -c 65536)"
+done`
+
+// ClaudeSessions lists the Claude sessions started in workDir in the
+// running container, most recently used first.
+func ClaudeSessions(container, workDir string) ([]ClaudeSession, error) {
+	out, err := exec.Command("docker", "exec", "-u", "agent", container,
+		"sh", "-c", sessionListScript, "sh", claudeProjectDir(workDir)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("list Claude sessions: %w", err)
+	}
+	return parseSessionListing(string(out)), nil
+}
+
+// parseSessionListing parses the output of sessionListScript.
+func parseSessionListing(listing string) []ClaudeSession {
+	var sessions []ClaudeSession
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) < 4 || !strings.HasSuffix(fields[0], ".jsonl") {
+			continue
+		}
+		s := ClaudeSession{ID: strings.TrimSuffix(fields[0], ".jsonl")}
+		if secs, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			s.Modified = time.Unix(secs, 0)
+		}
+		s.Summary = sessionSummary(fields[2], fields[3])
+		sessions = append(sessions, s)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Modified.After(sessions[j].Modified)
+	})
+	return sessions
+}
+
+// sessionSummary returns the summary record's text, or failing that the
+// text of the first user message, on one line.
+func sessionSummary(summaryRecord, userRecord string) string {
+	var summary struct {
+		Summary string `json:"summary"`
+	}
+	if json.Unmarshal([]byte(summaryRecord), &summary) == nil && summary.Summary != "" {
+		return strings.Join(strings.Fields(summary.Summary), " ")
+	}
+	var user struct {
+		Message struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"message"`
+	}
+	if json.Unmarshal([]byte(userRecord), &user) != nil {
+		return ""
+	}
+	// Content is either a string or a list of blocks.
+	var text string
+	if json.Unmarshal(user.Message.Content, &text) != nil {
+		var blocks []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		json.Unmarshal(user.Message.Content, &blocks)
+		for _, b := range blocks {
+			if b.Type == "text" {
+				text = b.Text
+				break
+			}
+		}
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// FindClaudeSession returns the session in sessions whose ID is id or
+// starts with it, so IDs can be shortened like commit hashes.
+func FindClaudeSession(sessions []ClaudeSession, id string) (ClaudeSession, error) {
+	var matches []ClaudeSession
+	for _, s := range sessions {
+		if s.ID == id {
+			return s, nil
+		}
+		if strings.HasPrefix(s.ID, id) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return ClaudeSession{}, fmt.Errorf("no Claude session %q in this workspace (see `sandbox sessions ls`)", id)
+	case 1:
+		return matches[0], nil
+	}
+	return ClaudeSession{}, fmt.Errorf("session ID %q is ambiguous: it matches %d sessions", id, len(matches))
+}
+
+// FormatSessionTable renders sessions as a table, with summaries cut to
+// fit on one line.
+func FormatSessionTable(sessions []ClaudeSession, now time.Time) string {
+	const summaryWidth = 60
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSED\tSUMMARY")
+	for _, s := range sessions {
+		summary := s.Summary
+		if r := []rune(summary); len(r) > summaryWidth {
+			summary = string(r[:summaryWidth-1]) + "…"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.ID, formatAge(now.Sub(s.Modified)), summary)
+	}
+	w.Flush()
+	return sb.String()
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClaudeProjectDir(t *testing.T) {
+	got := claudeProjectDir("/Users/me/my.proj/sub_dir")
+	want := "/home/agent/.claude/projects/-Users-me-my-proj-sub-dir"
+	if got != want {
+		t.Errorf("claudeProjectDir = %q, want %q", got, want)
+	}
+}
+
+func TestParseSessionListing(t *testing.T) {
+	listing := strings.Join([]string{
+		"old.jsonl\t1000\t\t" + `{"type":"user","message":{"role":"user","content":"fix   the\ntests"}}`,
+		"new.jsonl\t3000\t" + `{"type":"summary","summary":"Add retries"}` + "\t" + `{"type":"user","message":{"content":"x"}}`,
+		"blocks.jsonl\t2000\t\t" + `{"type":"user","message":{"content":[{"type":"image"},{"type":"text","text":"what is this"}]}}`,
+		"empty.jsonl\t500\t\t",
+		"",
+	}, "\n")
+	got := parseSessionListing(listing)
+
+	want := []ClaudeSession{
+		{ID: "new", Modified: time.Unix(3000, 0), Summary: "Add retries"},
+		{ID: "blocks", Modified: time.Unix(2000, 0), Summary: "what is this"},
+		{ID: "old", Modified: time.Unix(1000, 0), Summary: "fix the tests"},
+		{ID: "empty", Modified: time.Unix(500, 0)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d sessions, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("session %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFindClaudeSession(t *testing.T) {
+	sessions := []ClaudeSession{{ID: "3f2a91"}, {ID: "3f2b07"}, {ID: "3f2"}}
+	tests := []struct {
+		id, want, err string
+	}{
+		{id: "3f2a", want: "3f2a91"},
+		{id: "3f2", want: "3f2"}, // exact match wins over prefixes
+		{id: "3f", err: "ambiguous"},
+		{id: "ff", err: "no Claude session"},
+	}
+	for _, tt := range tests {
+		s, err := FindClaudeSession(sessions, tt.id)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("FindClaudeSession(%q) error = %v, want %q", tt.id, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || s.ID != tt.want {
+			t.Errorf("FindClaudeSession(%q) = %q, %v; want %q", tt.id, s.ID, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// shareProxyScript connects stdin and stdout to a TCP port on the container's
+// loopback interface, using bash's /dev/tcp so the image needs no extra tools.
+const shareProxyScript = `exec 3<>/dev/tcp/127.0.0.1/"$1" || exit 1; cat <&3 & cat >&3; wait`
+
+// Share exposes the container's share.ports to teammates until ctx is done,
+// printing where each port can be reached to out.
+//
+// With via: tailscale it listens on the host's tailnet address and relays
+// each connection into the container. With via: tunnel the container opens
+// SSH reverse forwards on the tunnel host, which sync allowlists in the
+// firewall.
+func Share(ctx context.Context, container string, share ShareConfig, out io.Writer) error {
+	switch share.Via {
+	case ShareTailscale:
+		return shareTailscale(ctx, container, share.Ports, out)
+	case ShareTunnel:
+		return shareTunnel(ctx, container, share, out)
+	}
+	return fmt.Errorf("sharing is off; set share.via in the sandbox config")
+}
+
+// tailscaleIP returns the host's IPv4 address on the tailnet.
+func tailscaleIP() (string, error) {
+	if _, err := lookPath("tailscale"); err != nil {
+		return "", fmt.Errorf("share via tailscale needs the tailscale CLI on the host: %w", err)
+	}
+	out, err := exec.Command("tailscale", "ip", "-4").Output()
+	if err != nil {
+		return "", fmt.Errorf("tailscale ip: %w (is tailscale up?)", err)
+	}
+	ip, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("tailscale ip: unexpected output %q", out)
+	}
+	return ip, nil
+}
+
+func shareTailscale(ctx context.Context, container string, ports []int, out io.Writer) error {
+	ip, err := tailscaleIP()
+	if err != nil {
+		return err
+	}
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	for _, port := range ports {
+		addr := net.JoinHostPort(ip, strconv.Itoa(port))
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, l)
+		fmt.Fprintf(out, "Sharing port %d at %s\n", port, addr)
+	}
+	relayListeners(ctx, container, listeners, ports)
+	return nil
+}
+
+// relayListeners relays connections accepted on each listener to the
+// matching container port until ctx is done, then closes the listeners.
+func relayListeners(ctx context.Context, container string, listeners []net.Listener, ports []int) {
+	var wg sync.WaitGroup
+	for i, l := range listeners {
+		wg.Add(1)
+		go func(l net.Listener, port int) {
+			defer wg.Done()
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go relayToContainer(ctx, container, port, conn)
+			}
+		}(l, ports[i])
+	}
+	<-ctx.Done()
+	for _, l := range listeners {
+		l.Close()
+	}
+	wg.Wait()
+}
+
+// relayToContainer copies conn to and from port on the container's
+// loopback interface, closing conn when either side finishes.
+func relayToContainer(ctx context.Context, container string, port int, conn net.Conn) {
+	defer conn.Close()
+	c := exec.CommandContext(ctx, "docker", "exec", "-i", container,
+		"bash", "-c", shareProxyScript, "share", strconv.Itoa(port))
+	c.Stdin = conn
+	c.Stdout = conn
+	if err := c.Run(); err != nil && ctx.Err() == nil {
+		Warnf(WarnShare, "share %s to port %d: %v", conn.RemoteAddr(), port, err)
+	}
+}
+
+// tunnelSSHArgs returns the ssh arguments that open a reverse forward on the
+// tunnel host for each shared port.
+func tunnelSSHArgs(share ShareConfig) []string {
+	t := share.Tunnel
+	args := []string{"ssh", "-N",
+		"-o", "BatchMode=yes",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ServerAliveInterval=30",
+		"-o", "StrictHostKeyChecking=accept-new",
+		"-p", strconv.Itoa(t.SSHPort()),
+	}
+	for _, p := range share.Ports {
+		args = append(args, "-R", fmt.Sprintf("%d:127.0.0.1:%d", p, p))
+	}
+	dest := t.Host
+	if t.User != "" {
+		dest = t.User + "@" + t.Host
+	}
+	return append(args, dest)
+}
+
+func shareTunnel(ctx context.Context, container string, share ShareConfig, out io.Writer) error {
+	for _, p := range share.Ports {
+		fmt.Fprintf(out, "Sharing port %d at %s\n", p, net.JoinHostPort(share.Tunnel.Host, strconv.Itoa(p)))
+	}
+	c := exec.CommandContext(ctx, "docker", append([]string{"exec", container}, tunnelSSHArgs(share)...)...)
+	c.Stdout = out
+	c.Stderr = Frontend.Stderr()
+	if err := c.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("tunnel to %s: %w", share.Tunnel.Host, err)
+	}
+	return nil
+}
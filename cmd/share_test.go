@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTunnelSSHArgs(t *testing.T) {
+	share := ShareConfig{
+		Via:    ShareTunnel,
+		Ports:  []int{3000, 8080},
+		Tunnel: &TunnelConfig{Host: "pair.example.com", User: "pair"},
+	}
+	want := []string{"ssh", "-N",
+		"-o", "BatchMode=yes",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ServerAliveInterval=30",
+		"-o", "StrictHostKeyChecking=accept-new",
+		"-p", "22",
+		"-R", "3000:127.0.0.1:3000",
+		"-R", "8080:127.0.0.1:8080",
+		"pair@pair.example.com",
+	}
+	if got := tunnelSSHArgs(share); !reflect.DeepEqual(got, want) {
+		t.Errorf("tunnelSSHArgs =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestShareOff(t *testing.T) {
+	if err := Share(context.Background(), "c", ShareConfig{}, nil); err == nil {
+		t.Error("Share with no via should fail")
+	}
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LabelSSH records the port mapping a container was created with for
+// ssh.enabled, so a changed config can be pointed out.
+const LabelSSH = "sandbox.ssh"
+
+// sshPublishSpec returns the docker run -p value that publishes the
+// container's sshd on the host's loopback interface, or "" when ssh is off.
+func sshPublishSpec(cfg *SandboxConfig) string {
+	if cfg == nil || !cfg.SSH.Enabled {
+		return ""
+	}
+	port := ""
+	if cfg.SSH.Port != 0 {
+		port = strconv.Itoa(cfg.SSH.Port)
+	}
+	return "127.0.0.1:" + port + ":22"
+}
+
+// warnIfSSHChanged warns if the container was created with a different ssh
+// setting than the config now asks for. Ports can only be published when a
+// container is created.
+func warnIfSSHChanged(container, want string) {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelSSH+`"}}`, container).Output()
+	if err != nil {
+		return
+	}
+	if have := strings.TrimSpace(string(out)); have != want {
+		Warnf(WarnContainer, "ssh has changed since this sandbox was created. To apply it, run `sandbox rm <folder>` and then restart.")
+	}
+}
+
+// readonlySSHHostKey is the container's host key with a read-only root,
+// where /etc/ssh can't be written. /opt is a tmpfs then, so the key is
+// generated afresh on each start.
+const readonlySSHHostKey = "/opt/sandbox-ssh-host-key"
+
+// startSSHD starts the container's sshd if it isn't running, first
+// generating the container's own host keys, which the image leaves out.
+// With a read-only root, the key is kept in /opt and sessions are pointed at
+// the relocated git config, as sshd doesn't pass on the container's env.
+func startSSHD(container string, readonly bool) error {
+	script := `mkdir -p /run/sshd && ssh-keygen -A >/dev/null && { pgrep -x sshd >/dev/null || /usr/sbin/sshd; }`
+	if readonly {
+		script = `mkdir -p /run/sshd && { [ -f ` + readonlySSHHostKey + ` ] || ssh-keygen -q -t ed25519 -N "" -f ` + readonlySSHHostKey + `; } && ` +
+			`{ pgrep -x sshd >/dev/null || /usr/sbin/sshd -h ` + readonlySSHHostKey + ` -o SetEnv=GIT_CONFIG_SYSTEM=` + readonlyGitConfigPath + `; }`
+	}
+	if out, err := exec.Command("docker", "exec", "-u", "root", container, "sh", "-c", script).CombinedOutput(); err != nil {
+		return fmt.Errorf("start sshd: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// SSHKeyPath returns the private key sandbox logs in to sandboxes with,
+// kept with the config and created on first use. A key of its own keeps
+// the user's keys out of the authorized_keys synced into every sandbox.
+func SSHKeyPath() (string, error) {
+	layout, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	key := filepath.Join(layout.Config, "ssh", "id_ed25519")
+	if _, err := os.Stat(key); err == nil {
+		return key, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(key), 0700); err != nil {
+		return "", err
+	}
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "sandbox", "-f", key).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("create SSH key: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return key, nil
+}
+
+// sshAuthorizedKeys returns the sync item authorizing SSHKeyPath's key for
+// the agent user, or nil when ssh is off.
+func sshAuthorizedKeys(cfg *SandboxConfig) []SyncItem {
+	if !cfg.SSH.Enabled {
+		return nil
+	}
+	key, err := SSHKeyPath()
+	if err != nil {
+		Warnf(WarnSync, "%v; sandbox ssh won't be able to log in", err)
+		return nil
+	}
+	pub, err := os.ReadFile(key + ".pub")
+	if err != nil {
+		Warnf(WarnSync, "%v; sandbox ssh won't be able to log in", err)
+		return nil
+	}
+	return []SyncItem{{Data: pub, Dest: "/home/agent/.ssh/authorized_keys", Mode: "0600", Owner: "agent:agent"}}
+}
+
+// SSHAddr returns the host address the container's sshd is published on.
+func SSHAddr(container string) (string, error) {
+	out, err := exec.Command("docker", "port", container, "22/tcp").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s doesn't publish an SSH port; set ssh.enabled: true in the sandbox config, then run `sandbox rm` and restart", container)
+	}
+	// One line per address family; the first is the loopback mapping.
+	addr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", fmt.Errorf("unexpected docker port output %q", out)
+	}
+	return addr, nil
+}
+
+// sshOptions skip host key checks: the server is only reachable from this
+// machine and its key changes whenever the sandbox is recreated.
+var sshOptions = []string{
+	"-o", "StrictHostKeyChecking=no",
+	"-o", "UserKnownHostsFile=/dev/null",
+	"-o", "LogLevel=ERROR",
+	"-o", "IdentitiesOnly=yes",
+}
+
+// SSHArgs returns the ssh command line that logs in to the sandbox at addr
+// as the agent user and runs command in workDir, or a login shell there if
+// command is empty.
+func SSHArgs(addr, key, workDir string, command []string) []string {
+	host, port, _ := net.SplitHostPort(addr)
+	out := append([]string{"ssh", "-i", key, "-p", port}, sshOptions...)
+	remote := "cd " + shellQuote(workDir) + " && "
+	if len(command) == 0 {
+		out = append(out, "-t")
+		remote += `exec "$SHELL" -l`
+	} else {
+		quoted := make([]string, len(command))
+		for i, a := range command {
+			quoted[i] = shellQuote(a)
+		}
+		remote += strings.Join(quoted, " ")
+	}
+	return append(out, "agent@"+host, remote)
+}
+
+// FormatSSHConfig renders a Host entry for ~/.ssh/config, so editors that
+// connect over SSH can reach the sandbox by alias.
+func FormatSSHConfig(alias, addr, key string) string {
+	host, port, _ := net.SplitHostPort(addr)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Host %s\n", alias)
+	fmt.Fprintf(&sb, "  HostName %s\n", host)
+	fmt.Fprintf(&sb, "  Port %s\n", port)
+	fmt.Fprintf(&sb, "  User agent\n")
+	fmt.Fprintf(&sb, "  IdentityFile %s\n", key)
+	for i := 1; i < len(sshOptions); i += 2 {
+		k, v, _ := strings.Cut(sshOptions[i], "=")
+		fmt.Fprintf(&sb, "  %s %s\n", k, v)
+	}
+	return sb.String()
+}
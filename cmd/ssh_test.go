@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSSHPublishSpec(t *testing.T) {
+	tests := []struct {
+		cfg  *SandboxConfig
+		want string
+	}{
+		{nil, ""},
+		{&SandboxConfig{}, ""},
+		{&SandboxConfig{SSH: SSHConfig{Enabled: true}}, "127.0.0.1::22"},
+		{&SandboxConfig{SSH: SSHConfig{Enabled: true, Port: 2222}}, "127.0.0.1:2222:22"},
+		{&SandboxConfig{SSH: SSHConfig{Port: 2222}}, ""},
+	}
+	for _, tt := range tests {
+		if got := sshPublishSpec(tt.cfg); got != tt.want {
+			t.Errorf("sshPublishSpec(%+v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestSSHArgs(t *testing.T) {
+	login := strings.Join(SSHArgs("127.0.0.1:49153", "/k", "/ws/it's", nil), " ")
+	for _, want := range []string{"-i /k -p 49153", "-t agent@127.0.0.1", `cd '/ws/it'"'"'s' && exec "$SHELL" -l`} {
+		if !strings.Contains(login, want) {
+			t.Errorf("login args %q missing %q", login, want)
+		}
+	}
+	run := SSHArgs("127.0.0.1:49153", "/k", "/ws", []string{"go", "test", "./..."})
+	if got, want := run[len(run)-1], `cd '/ws' && 'go' 'test' './...'`; got != want {
+		t.Errorf("remote command = %q, want %q", got, want)
+	}
+	if strings.Contains(strings.Join(run, " "), " -t ") {
+		t.Error("a command shouldn't get a TTY")
+	}
+}
+
+func TestFormatSSHConfig(t *testing.T) {
+	got := FormatSSHConfig("sandbox-myapp", "127.0.0.1:2222", "/home/me/.sandbox/ssh/id_ed25519")
+	for _, want := range []string{"Host sandbox-myapp\n", "  Port 2222\n", "  User agent\n", "  StrictHostKeyChecking no\n", "  IdentityFile /home/me/.sandbox/ssh/id_ed25519\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("config missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestMergeSSH(t *testing.T) {
+	base := &SandboxConfig{SSH: SSHConfig{Enabled: true, Port: 2222}}
+	merged := mergeConfig(base, &SandboxConfig{SSH: SSHConfig{Port: 2223}})
+	if !merged.SSH.Enabled || merged.SSH.Port != 2223 {
+		t.Errorf("merged ssh = %+v, want enabled on 2223", merged.SSH)
+	}
+	merged = mergeConfig(&SandboxConfig{}, &SandboxConfig{SSH: SSHConfig{Enabled: true}})
+	if merged.SSH.Enabled {
+		t.Error("a workspace config enabled ssh")
+	}
+}
@@ -1,15 +1,24 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	_ "embed"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 //go:embed image/init-firewall.sh
@@ -18,18 +27,32 @@ var firewallScript []byte
 //go:embed image/hosttool-mcp
 var hosttoolMCPScript []byte
 
-// syncStatus prints a status line that overwrites itself.
+//go:embed image/sandbox-scope
+var scopeScript []byte
+
+//go:embed image/gpg-agent-relay
+var gpgRelayScript []byte
+
+// syncStatus shows a status line that overwrites itself.
 func syncStatus(msg string) {
-	fmt.Fprintf(os.Stderr, "\r\033[K  \033[2m%s\033[0m", msg)
+	Frontend.Status(msg)
 }
 
 // syncStatusDone clears the status line.
 func syncStatusDone() {
-	fmt.Fprintf(os.Stderr, "\r\033[K")
+	Frontend.Status("")
 }
 
 // copyToContainer writes data to a host temp file and docker-cp's it into the container.
 func copyToContainer(container string, data []byte, dest string) error {
+	// docker cp can't write to tmpfs mounts, which /opt is with
+	// hardening.readonly_rootfs, so files there are streamed in. Only root
+	// can write to /opt, so nothing there can redirect the write.
+	if strings.HasPrefix(dest, "/opt/") {
+		cmd := exec.Command("docker", "exec", "-i", "-u", "root", container, "sh", "-c", `cat > "$1" && chmod 755 "$1"`, "sh", dest)
+		cmd.Stdin = bytes.NewReader(data)
+		return cmd.Run()
+	}
 	tmp, err := os.CreateTemp("", "sandbox-sync-*")
 	if err != nil {
 		return err
@@ -48,7 +71,8 @@ func copyToContainer(container string, data []byte, dest string) error {
 }
 
 // syncItems copies each SyncItem into the container and sets ownership/permissions.
-func syncItems(container string, items []SyncItem) error {
+// Large items are sent with transferLarge using t.
+func syncItems(container string, items []SyncItem, t TransferConfig) error {
 	for _, item := range items {
 		syncStatus(item.Dest)
 		dir := filepath.Dir(item.Dest)
@@ -56,7 +80,11 @@ func syncItems(container string, items []SyncItem) error {
 			syncStatusDone()
 			return fmt.Errorf("mkdir %s: %w", dir, err)
 		}
-		if err := copyToContainer(container, item.Data, item.Dest); err != nil {
+		send := func() error { return copyToContainer(container, item.Data, item.Dest) }
+		if item.Large {
+			send = func() error { return transferLarge(container, item, t) }
+		}
+		if err := send(); err != nil {
 			syncStatusDone()
 			return fmt.Errorf("sync %s: %w", item.Dest, err)
 		}
@@ -73,9 +101,15 @@ func syncItems(container string, items []SyncItem) error {
 	return nil
 }
 
+// SyncManifest returns what a sync writes into a sandbox with cfg; see
+// buildSyncManifest.
+func SyncManifest(cfg *SandboxConfig) ([]SyncItem, error) {
+	return buildSyncManifest(cfg)
+}
+
 // buildSyncManifest builds the list of non-firewall items to sync into the
-// container. Firewall rules are resolved and synced separately (in parallel)
-// by SyncContainer.
+// container, sorted by dest with one item per dest. Firewall rules are
+// resolved and synced separately (in parallel) by SyncContainer.
 func buildSyncManifest(cfg *SandboxConfig) ([]SyncItem, error) {
 	var items []SyncItem
 
@@ -87,8 +121,23 @@ func buildSyncManifest(cfg *SandboxConfig) ([]SyncItem, error) {
 		Owner: "root:root",
 	})
 
+	// 2. Embedded cgroup scope helper for resource-limited hooks and
+	// sessions; with a read-only root, the image's copy
+	if !readonlyRootfs(cfg) {
+		items = append(items, SyncItem{
+			Data:  scopeScript,
+			Dest:  scopeScriptPath,
+			Mode:  "0755",
+			Owner: "root:root",
+		})
+	}
+
 	// 3. Generated env file
-	if envData := generateEnvFile(cfg.Env); envData != nil {
+	envData, err := generateEnvFile(cfg.Env, cfg.EnvStrict)
+	if err != nil {
+		return nil, err
+	}
+	if envData != nil {
 		items = append(items, SyncItem{
 			Data:  envData,
 			Dest:  "/home/agent/.sandbox-env",
@@ -97,10 +146,25 @@ func buildSyncManifest(cfg *SandboxConfig) ([]SyncItem, error) {
 		})
 	}
 
-	// 4. Home directory files from ~/.sandbox/home/
-	home, err := os.UserHomeDir()
+	// 3b. Stored API keys for providers with a key_file
+	items = append(items, storedKeyFiles(cfg)...)
+
+	// 3c. The key sandbox ssh logs in with, with ssh.enabled
+	items = append(items, sshAuthorizedKeys(cfg)...)
+
+	// 3d. The gpg-agent relay and the host's public keys, with gpg.forward
+	items = append(items, gpgSyncItems(cfg)...)
+
+	// 3e. The git identity and safe credential helpers, as the system config
+	items = append(items, gitConfigItems(cfg)...)
+
+	// 3f. Extra CA certificates to trust
+	items = append(items, caCertItems(cfg)...)
+
+	// 4. Home directory files from ~/.sandbox/home/ (or the active layout's
+	// equivalent)
+	homeDir, err := HomeFilesDir()
 	if err == nil {
-		homeDir := filepath.Join(home, ".sandbox", "home")
 		if info, statErr := os.Stat(homeDir); statErr == nil && info.IsDir() {
 			walkErr := filepath.Walk(homeDir, func(path string, info os.FileInfo, err error) error {
 				if err != nil {
@@ -122,10 +186,11 @@ func buildSyncManifest(cfg *SandboxConfig) ([]SyncItem, error) {
 					mode = "0755"
 				}
 				items = append(items, SyncItem{
-					Data:  data,
-					Dest:  "/home/agent/" + rel,
-					Mode:  mode,
-					Owner: "agent:agent",
+					Data:   data,
+					Dest:   "/home/agent/" + rel,
+					Mode:   mode,
+					Owner:  "agent:agent",
+					Source: path,
 				})
 				return nil
 			})
@@ -135,6 +200,20 @@ func buildSyncManifest(cfg *SandboxConfig) ([]SyncItem, error) {
 		}
 	}
 
+	// 4b. The host's CLAUDE.md and slash commands, with host_claude. Home
+	// directory files take precedence.
+	if cfg.HostClaude {
+		have := make(map[string]bool, len(items))
+		for _, item := range items {
+			have[item.Dest] = true
+		}
+		hostItems, err := hostClaudeItems(have)
+		if err != nil {
+			return nil, fmt.Errorf("read host Claude files: %w", err)
+		}
+		items = append(items, hostItems...)
+	}
+
 	// 5. Host tool files (only when host_tools are configured)
 	if len(cfg.HostTools) > 0 {
 		// 5a. Tool definitions JSON for the MCP server
@@ -154,18 +233,20 @@ func buildSyncManifest(cfg *SandboxConfig) ([]SyncItem, error) {
 			Owner: "root:root",
 		})
 
-		// 5b. MCP server script
-		items = append(items, SyncItem{
-			Data:  hosttoolMCPScript,
-			Dest:  "/usr/local/bin/hosttool-mcp",
-			Mode:  "0755",
-			Owner: "root:root",
-		})
+		// 5b. MCP server script; with a read-only root, the image's copy
+		if !readonlyRootfs(cfg) {
+			items = append(items, SyncItem{
+				Data:  hosttoolMCPScript,
+				Dest:  "/usr/local/bin/hosttool-mcp",
+				Mode:  "0755",
+				Owner: "root:root",
+			})
+		}
 
 	}
 
 	// 6a. Claude settings.json (always synced — sandbox defaults + user overrides)
-	settingsData, err := buildClaudeSettings()
+	settingsData, err := buildClaudeSettings(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("build claude settings: %w", err)
 	}
@@ -177,6 +258,7 @@ func buildSyncManifest(cfg *SandboxConfig) ([]SyncItem, error) {
 	})
 
 	// 7. Explicit sync rules from config
+	largeFile, _, _ := cfg.Transfer.sizes()
 	for _, rule := range cfg.Sync {
 		mode := rule.Mode
 		if mode == "" {
@@ -190,46 +272,164 @@ func buildSyncManifest(cfg *SandboxConfig) ([]SyncItem, error) {
 		src := expandTilde(rule.Src)
 		dest := expandContainerTilde(rule.Dest)
 
-		matches, err := filepath.Glob(src)
+		base, matches, err := expandSyncSource(src, rule.Exclude)
 		if err != nil {
 			return nil, fmt.Errorf("glob %q: %w", rule.Src, err)
 		}
-		if matches == nil {
-			matches = []string{src}
-		}
 
 		for _, m := range matches {
+			d := dest
+			if len(matches) > 1 || strings.HasSuffix(dest, "/") {
+				rel, err := filepath.Rel(base, m)
+				if err != nil {
+					rel = filepath.Base(m)
+				}
+				d = filepath.Join(dest, rel)
+			}
+			if err := checkSyncDest(d); err != nil {
+				return nil, fmt.Errorf("sync rule for %s: %w", rule.Src, err)
+			}
+			// Large files are streamed at transfer time rather than read
+			// into memory; their size and mtime stand in for content.
+			if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() && info.Size() >= largeFile {
+				items = append(items, SyncItem{
+					Dest:       d,
+					Mode:       mode,
+					Owner:      owner,
+					Source:     m,
+					CreateUser: rule.CreateUser,
+					Large:      true,
+					Size:       info.Size(),
+					Stamp:      fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()),
+				})
+				continue
+			}
 			data, err := os.ReadFile(m)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "warning: cannot read %s: %v\n", m, err)
+				Warnf(WarnSync, "cannot read %s: %v", m, err)
 				continue
 			}
-			d := dest
-			if len(matches) > 1 {
-				d = filepath.Join(dest, filepath.Base(m))
-			}
 			items = append(items, SyncItem{
-				Data:  data,
-				Dest:  d,
-				Mode:  mode,
-				Owner: owner,
+				Data:       data,
+				Dest:       d,
+				Mode:       mode,
+				Owner:      owner,
+				Source:     m,
+				CreateUser: rule.CreateUser,
 			})
 		}
 	}
 
-	return items, nil
+	return normalizeManifest(items), nil
+}
+
+// expandSyncSource expands a sync rule's src pattern into host file paths.
+// Patterns use doublestar syntax: "*" and "?" match within a path segment,
+// "**" matches any number of directories, and "{a,b}" matches either
+// alternative. Matches that hit any exclude pattern are dropped; a leading
+// "!" on an exclude pattern is accepted and ignored. Relative exclude
+// patterns are matched against the path below base, the static directory
+// prefix of src, which callers use to preserve subdirectory structure under
+// dest. A src without glob characters (or with no matches) is returned as-is
+// so the caller can report it as unreadable.
+func expandSyncSource(src string, exclude []string) (base string, matches []string, err error) {
+	base, _ = doublestar.SplitPattern(filepath.ToSlash(src))
+	base = filepath.FromSlash(base)
+
+	found, err := doublestar.FilepathGlob(src, doublestar.WithFilesOnly())
+	if err != nil {
+		return "", nil, err
+	}
+	if len(found) == 0 {
+		return filepath.Dir(src), []string{src}, nil
+	}
+
+	for _, m := range found {
+		rel, err := filepath.Rel(base, m)
+		if err != nil {
+			rel = m
+		}
+		excluded := false
+		for _, ex := range exclude {
+			ex = expandTilde(strings.TrimPrefix(ex, "!"))
+			target := rel
+			if filepath.IsAbs(ex) {
+				target = m
+			}
+			ok, err := doublestar.PathMatch(ex, target)
+			if err != nil {
+				return "", nil, fmt.Errorf("exclude %q: %w", ex, err)
+			}
+			if ok {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			matches = append(matches, m)
+		}
+	}
+	return base, matches, nil
+}
+
+// normalizeManifest collapses items that share a destination and sorts the
+// result by dest. When several items target the same dest the last one wins,
+// matching the pipeline order; a warning is printed if the losing item had
+// different content so the overwrite isn't silent. Sorting uses plain byte
+// comparison so the order (and therefore the sync hash) is identical on every
+// machine regardless of locale.
+func normalizeManifest(items []SyncItem) []SyncItem {
+	last := make(map[string]int, len(items))
+	for i, item := range items {
+		if prev, ok := last[item.Dest]; ok && !sameSyncItem(items[prev], item) {
+			Warnf(WarnSync, "%s and %s both sync to %s; using %s",
+				itemSource(items[prev]), itemSource(item), item.Dest, itemSource(item))
+		}
+		last[item.Dest] = i
+	}
+
+	result := make([]SyncItem, 0, len(last))
+	for i, item := range items {
+		if last[item.Dest] == i {
+			result = append(result, item)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Dest < result[j].Dest
+	})
+	return result
+}
+
+func sameSyncItem(a, b SyncItem) bool {
+	return bytes.Equal(a.Data, b.Data) && a.Stamp == b.Stamp && a.Mode == b.Mode && a.Owner == b.Owner
+}
+
+// itemSource describes where an item came from for conflict warnings.
+func itemSource(item SyncItem) string {
+	if item.Source == "" {
+		return "built-in"
+	}
+	return item.Source
 }
 
 // buildClaudeSettings reads the user's Claude settings from ~/.sandbox/home/.claude/settings.json
-// (if it exists), merges in sandbox defaults, and returns the result.
-func buildClaudeSettings() ([]byte, error) {
+// (if it exists), merges in sandbox defaults, and returns the result. With
+// host_claude the host's own settings are merged underneath.
+func buildClaudeSettings(cfg *SandboxConfig) ([]byte, error) {
 	settings := make(map[string]interface{})
+	if cfg.HostClaude {
+		if host := hostClaudeSettings(); host != nil {
+			settings = host
+		}
+	}
 
-	home, err := os.UserHomeDir()
+	homeDir, err := HomeFilesDir()
 	if err == nil {
-		userSettings := filepath.Join(home, ".sandbox", "home", ".claude", "settings.json")
+		userSettings := filepath.Join(homeDir, ".claude", "settings.json")
 		if data, err := os.ReadFile(userSettings); err == nil {
-			json.Unmarshal(data, &settings)
+			var sandboxSettings map[string]interface{}
+			json.Unmarshal(data, &sandboxSettings)
+			mergeSettings(settings, sandboxSettings)
 		}
 	}
 
@@ -274,24 +474,69 @@ func mergeClaudeJSON(container string) error {
 	return nil
 }
 
-// SyncContainer builds the sync manifest and resolves firewall DNS in parallel,
-// then pushes all items into the container and applies firewall rules.
-func SyncContainer(name, wsPath string, force bool) error {
-	cfg, err := LoadConfig(wsPath)
-	if err != nil {
-		return err
-	}
+// ensureOwners checks that the user and group of every item's owner exist in
+// the container. Missing accounts are created for items with CreateUser set;
+// otherwise an error names the offending destination. The built-in agent and
+// root accounts are assumed to exist.
+func ensureOwners(container string, items []SyncItem) error {
+	checked := make(map[string]bool)
+	for _, item := range items {
+		if checked[item.Owner] || item.Owner == "agent:agent" || item.Owner == "root:root" {
+			continue
+		}
+		checked[item.Owner] = true
 
-	items, err := buildSyncManifest(cfg)
-	if err != nil {
-		return fmt.Errorf("build sync manifest: %w", err)
+		user, group, err := splitOwner(item.Owner)
+		if err != nil {
+			return fmt.Errorf("%s: %w", item.Dest, err)
+		}
+		if group != "" && !containerAccountExists(container, "group", group) {
+			if !item.CreateUser {
+				return fmt.Errorf("owner %q for %s: group %q does not exist in the container\n"+
+					"Set create_user: true on the sync rule to create it, or use an existing group", item.Owner, item.Dest, group)
+			}
+			syncStatus("creating group " + group)
+			if out, err := exec.Command("docker", "exec", "-u", "root", container, "groupadd", "--system", group).CombinedOutput(); err != nil {
+				syncStatusDone()
+				return fmt.Errorf("create group %q: %w\n%s", group, err, out)
+			}
+		}
+		if !containerAccountExists(container, "passwd", user) {
+			if !item.CreateUser {
+				return fmt.Errorf("owner %q for %s: user %q does not exist in the container\n"+
+					"Set create_user: true on the sync rule to create it, or use an existing user", item.Owner, item.Dest, user)
+			}
+			args := []string{"exec", "-u", "root", container, "useradd", "--system", "--no-create-home"}
+			if group != "" {
+				args = append(args, "--gid", group)
+			} else {
+				args = append(args, "--user-group")
+			}
+			args = append(args, user)
+			syncStatus("creating user " + user)
+			if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+				syncStatusDone()
+				return fmt.Errorf("create user %q: %w\n%s", user, err, out)
+			}
+		}
 	}
+	syncStatusDone()
+	return nil
+}
+
+// containerAccountExists reports whether name exists in the container's
+// account database (db is "passwd" or "group").
+func containerAccountExists(container, db, name string) bool {
+	return exec.Command("docker", "exec", container, "getent", db, name).Run() == nil
+}
 
-	// Compute hash over sync items + firewall config + on_sync hooks.
-	// This lets us skip sync without DNS when nothing has changed.
+// syncHash computes a hash over sync items + firewall config + on_sync hooks.
+// This lets us skip sync without DNS when nothing has changed.
+func syncHash(cfg *SandboxConfig, wsPath string, items []SyncItem) string {
 	h := sha256.New()
 	for _, item := range items {
 		h.Write(item.Data)
+		h.Write([]byte(item.Stamp))
 		h.Write([]byte(item.Dest))
 	}
 	h.Write(firewallConfigHash(cfg))
@@ -301,17 +546,159 @@ func SyncContainer(name, wsPath string, force bool) error {
 		if hook.Root {
 			h.Write([]byte("root"))
 		}
+		h.Write([]byte(hook.Timeout))
+		h.Write([]byte(hook.OnFailure))
+		h.Write([]byte(hook.Memory))
+		h.Write([]byte(fmt.Sprint(hook.CPUs)))
+		// Conditional hooks watch files outside the manifest, so fold their
+		// contents in to make edits to them trigger a sync.
+		if len(hook.WhenChanged) > 0 {
+			h.Write([]byte(hookInputsHash(hook, wsPath, items)))
+		}
 	}
-	hash := hex.EncodeToString(h.Sum(nil))
+	if hooks := cfg.Command("hooks"); len(cfg.OnSync) > 0 {
+		h.Write([]byte(hooks.Workdir))
+		for _, k := range sortedKeys(hooks.Env) {
+			h.Write([]byte(k + "=" + hooks.Env[k]))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
 
-	if !force {
-		out, err := exec.Command("docker", "exec", name, "cat", "/opt/sandbox-sync.sha256").Output()
-		if err == nil && strings.TrimSpace(string(out)) == hash {
-			return nil
+// syncStatePath records the last successful sync: its hash, the identity
+// of the container it was made in, then its syncSourceStamp.
+const syncStatePath = "/opt/sandbox-sync.sha256"
+
+// containerIdentity returns container's ID and start time as Docker reports
+// them. A recreated container, even one from an image that has a sync
+// state baked in, has a new ID; a restarted one a new start time, so its
+// firewall domains are resolved afresh. Both are compared as strings, never
+// against a clock, so clock skew between host and container doesn't matter.
+func containerIdentity(container string) string {
+	out, err := exec.Command("docker", "inspect", "-f", "{{.Id}} {{.State.StartedAt}}", container).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// storedSyncState returns the hash and source stamp recorded by the last
+// successful sync, or "" for both if the container has never been synced or
+// the sync was made in another container.
+func storedSyncState(container string) (hash, stamp string) {
+	out, err := exec.Command("docker", "exec", container, "cat", syncStatePath).Output()
+	if err != nil {
+		return "", ""
+	}
+	return parseSyncState(string(out), containerIdentity(container))
+}
+
+// parseSyncState returns the hash and source stamp in a sync state record if
+// it was written in the container with identity. Records from older
+// releases hold only the hash, so never match; those without a stamp have
+// an empty one.
+func parseSyncState(record, identity string) (hash, stamp string) {
+	lines := strings.Split(strings.TrimSpace(record), "\n")
+	if identity == "" || len(lines) < 2 || strings.TrimSpace(lines[1]) != identity {
+		return "", ""
+	}
+	if len(lines) > 2 {
+		stamp = strings.TrimSpace(lines[2])
+	}
+	return lines[0], stamp
+}
+
+// writeSyncState records a successful sync with hash and stamp in the
+// container, tied to it.
+func writeSyncState(container, hash, stamp string) error {
+	if err := exec.Command("docker", "exec", "-u", "root", container, "sh", "-c",
+		`printf '%s\n%s\n%s\n' "$1" "$2" "$3" > "$4"`, "sh", hash, containerIdentity(container), stamp, syncStatePath).Run(); err != nil {
+		return fmt.Errorf("write sync hash: %w", err)
+	}
+	return nil
+}
+
+// syncedNow maps the containers this process has synced to the source stamp
+// they were synced with, so a session banner shown straight after a sync
+// needn't ask the container.
+var syncedNow sync.Map
+
+// SyncPending reports whether the container's last sync is out of date with
+// respect to the current config and synced files, by syncSourceStamp.
+func SyncPending(container, wsPath string, cfg *SandboxConfig) bool {
+	want := syncSourceStamp(cfg, wsPath)
+	if stamp, ok := syncedNow.Load(container); ok && stamp == want {
+		return false
+	}
+	hash, stamp := storedSyncState(container)
+	return hash == "" || stamp != want
+}
+
+// SyncOptions controls a SyncContainer run.
+type SyncOptions struct {
+	Force         bool // sync even if nothing changed, and re-run conditional hooks
+	StrictSecrets bool // refuse to sync files that look like they contain credentials
+
+	// Every sync checks for synced files edited in the container since the
+	// last sync. Clobber overwrites them regardless; otherwise each needs
+	// ConfirmOverwrite's approval. Without it, any edit fails a forced sync,
+	// and other syncs leave the edited files be and stay pending.
+	Clobber          bool
+	ConfirmOverwrite func(SyncConflict) bool
+}
+
+// SyncContainer builds the sync manifest and resolves firewall DNS in parallel,
+// then pushes all items into the container and applies firewall rules.
+func SyncContainer(name, wsPath string, opts SyncOptions) error {
+	cfg, err := LoadConfig(wsPath)
+	if err != nil {
+		return err
+	}
+	return syncContainer(name, wsPath, cfg, opts)
+}
+
+// syncContainer is SyncContainer with an already loaded config.
+func syncContainer(name, wsPath string, cfg *SandboxConfig, opts SyncOptions) (err error) {
+	items, err := buildSyncManifest(cfg)
+	if err != nil {
+		return fmt.Errorf("build sync manifest: %w", err)
+	}
+
+	hash := syncHash(cfg, wsPath, items)
+	stamp := syncSourceStamp(cfg, wsPath)
+	if stored, storedStamp := storedSyncState(name); !opts.Force && stored == hash {
+		syncedNow.Store(name, stamp)
+		// A file touched without changing still makes the stamp differ,
+		// so the record catches up for SyncPending.
+		if storedStamp != stamp {
+			return writeSyncState(name, hash, stamp)
 		}
+		return nil
 	}
 
-	fmt.Println("Syncing sandbox...")
+	start := time.Now()
+	defer func() {
+		reportMetric(cfg, metricEvent{Kind: metricSync, Sandbox: name, Seconds: time.Since(start).Seconds(), Failed: err != nil})
+		if err == nil {
+			runPlugins(PluginEvent{Event: PluginPostSync, Workspace: wsPath, Container: name})
+		} else {
+			err = withKind(KindSync, err)
+			notifyWebhooks(cfg, WebhookEvent{Event: EventSyncFailed, Sandbox: name, Workspace: wsPath, Error: err.Error()})
+		}
+	}()
+
+	if err := checkSyncSecrets(cfg, slices.Concat(items, envFileScanItems(cfg, wsPath)), opts.StrictSecrets); err != nil {
+		return err
+	}
+
+	synced := readSyncedHashes(name)
+	all := len(items)
+	if items, err = resolveSyncConflicts(name, items, synced, opts); err != nil {
+		return err
+	}
+	keptBack := len(items) < all
+
+	Frontend.Info(Msg("sandbox.syncing"))
 
 	// Start DNS resolution in background while we sync files
 	resultCh, progressCh := resolveFirewallEntriesAsync(cfg)
@@ -320,8 +707,14 @@ func SyncContainer(name, wsPath string, force bool) error {
 	oldV4, _ := exec.Command("docker", "exec", name, "cat", "/opt/sandbox-firewall-rules.sh").Output()
 	oldV6, _ := exec.Command("docker", "exec", name, "cat", "/opt/sandbox-firewall-rules6.sh").Output()
 
+	// Make sure every owner exists before copying anything, so a typo'd
+	// owner fails up front rather than halfway through the sync.
+	if err := ensureOwners(name, items); err != nil {
+		return err
+	}
+
 	// Sync non-firewall items (runs in parallel with DNS resolution)
-	if err := syncItems(name, items); err != nil {
+	if err := syncItems(name, items, cfg.Transfer); err != nil {
 		return err
 	}
 
@@ -341,21 +734,21 @@ func SyncContainer(name, wsPath string, force bool) error {
 
 	// Resolve host gateway from inside the container for host tool firewall rules.
 	// host.docker.internal only resolves inside containers, not on the host.
-	if len(cfg.HostTools) > 0 {
+	if cfg.UsesHostToolDaemon() {
 		if gw := resolveHostGateway(name, cfg.EffectiveHostToolPort()); gw != nil {
 			resolved.domains = append(resolved.domains, *gw)
 		}
 	}
 
 	// Generate firewall rules from resolved entries
-	v4Rules, v6Rules := buildFirewallRules(resolved.domains, resolved.cidrs)
+	v4Rules, v6Rules := buildFirewallRules(resolved.domains, resolved.cidrs, cfg.DNS.Servers)
 
 	// Sync firewall rules files
 	fwItems := []SyncItem{
 		{Data: v4Rules, Dest: "/opt/sandbox-firewall-rules.sh", Mode: "0755", Owner: "root:root"},
 		{Data: v6Rules, Dest: "/opt/sandbox-firewall-rules6.sh", Mode: "0755", Owner: "root:root"},
 	}
-	if err := syncItems(name, fwItems); err != nil {
+	if err := syncItems(name, fwItems, cfg.Transfer); err != nil {
 		return err
 	}
 
@@ -364,7 +757,8 @@ func SyncContainer(name, wsPath string, force bool) error {
 		syncStatus("applying firewall rules...")
 		if err := exec.Command("docker", "exec", "-u", "root", name, "/opt/init-firewall.sh").Run(); err != nil {
 			syncStatusDone()
-			fmt.Fprintf(os.Stderr, "warning: firewall update failed: %v\n", err)
+			Warnf(WarnFirewall, "firewall update failed: %v", err)
+			notifyWebhooks(cfg, WebhookEvent{Event: EventFirewallFailed, Sandbox: name, Workspace: wsPath, Error: err.Error()})
 		}
 		syncStatusDone()
 	}
@@ -380,39 +774,281 @@ func SyncContainer(name, wsPath string, force bool) error {
 		syncStatusDone()
 	}
 
-	// Run on_sync hooks
-	if err := runOnSyncHooks(name, "/home/agent", cfg.OnSync); err != nil {
-		return err
+	if !readonlyRootfs(cfg) {
+		if err := updateCATrust(name, items); err != nil {
+			Warnf(WarnSync, "%v", err)
+		}
 	}
 
-	// Write sync hash
-	if err := exec.Command("docker", "exec", "-u", "root", name, "sh", "-c", fmt.Sprintf("echo %s > /opt/sandbox-sync.sha256", hash)).Run(); err != nil {
-		return fmt.Errorf("write sync hash: %w", err)
+	if cfg.GPG.Forward {
+		if err := importGPGKeyring(name); err != nil {
+			Warnf(WarnSync, "%v", err)
+		}
 	}
 
+	// Run on_sync hooks. Conditional hooks are skipped when their watched
+	// files haven't changed since they last succeeded, unless forced.
+	state := make(hookState)
+	if !opts.Force {
+		state = readHookState(name)
+	}
+	inputs := make([]string, len(cfg.OnSync))
+	for i, hook := range cfg.OnSync {
+		if len(hook.WhenChanged) > 0 {
+			inputs[i] = hookInputsHash(hook, wsPath, items)
+		}
+	}
+	hooks := cfg.Command("hooks")
+	hookDir := "/home/agent"
+	if hooks.Workdir != "" {
+		hookDir = hooks.Dir(wsPath, wsPath)
+	}
+	hookEnv, hookEnvKeys, err := resolveEnv(hooks.Env, cfg.EnvStrict)
+	if err != nil {
+		return fmt.Errorf("commands.hooks env: %w", err)
+	}
+	var env []string
+	for _, k := range hookEnvKeys {
+		env = append(env, k+"="+hookEnv[k])
+	}
+	hookErr := runOnSyncHooks(name, wsPath, hookDir, env, cfg.OnSync, inputs, state)
+	if len(state) > 0 {
+		if err := writeHookState(name, state); err != nil {
+			return err
+		}
+	}
+	if hookErr != nil {
+		return withKind(KindHook, hookErr)
+	}
+
+	if err := writeSyncedHashes(name, synced, items); err != nil {
+		return err
+	}
+
+	// Files left unsynced keep the sync pending, so the next one tries again.
+	if keptBack {
+		return nil
+	}
+	if err := writeSyncState(name, hash, stamp); err != nil {
+		return err
+	}
+	syncedNow.Store(name, stamp)
 	return nil
 }
 
+// hookRetryAttempts is the total number of attempts for hooks with
+// on_failure: retry.
+const hookRetryAttempts = 3
+
 // runOnSyncHooks executes on_sync hooks sequentially inside the container.
-func runOnSyncHooks(container, workdir string, hooks []OnSyncHook) error {
-	for _, hook := range hooks {
+// Each hook's on_failure policy decides whether a failure aborts the sync,
+// is reported as a warning, or is retried.
+//
+// inputs holds each hook's when_changed hash ("" for unconditional hooks). A
+// conditional hook is skipped when its hash matches the one recorded in state,
+// and state is updated whenever a conditional hook succeeds.
+//
+// Root hooks only run once approved for the workspace at wsPath; see
+// approveRootHook. One that isn't approved fails under its on_failure policy
+// without being retried.
+//
+// env holds KEY=value pairs set for every hook.
+func runOnSyncHooks(container, wsPath, workdir string, env []string, hooks []OnSyncHook, inputs []string, state hookState) error {
+	for i, hook := range hooks {
 		label := hook.Name
 		if label == "" {
 			label = hook.Cmd
 		}
-		syncStatus("hook: " + label)
-		user := "agent"
+		key := hook.stateKey()
+		if inputs[i] != "" && state[key] == inputs[i] {
+			continue
+		}
+		attempts := 1
+		if hook.FailurePolicy() == HookFailureRetry {
+			attempts = hookRetryAttempts
+		}
+
+		var err error
 		if hook.Root {
-			user = "root"
+			if err = approveRootHook(wsPath, hook); err != nil {
+				attempts = 0
+			}
 		}
-		cmd := exec.Command("docker", "exec", "-u", user, "-w", workdir,
-			container, "sh", "-c", hook.Cmd)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			syncStatusDone()
-			return fmt.Errorf("on_sync hook %q failed: %w\n%s", label, err, string(output))
+		for attempt := 1; attempt <= attempts; attempt++ {
+			if attempt == 1 {
+				syncStatus("hook: " + label)
+			} else {
+				syncStatus(fmt.Sprintf("hook: %s (attempt %d/%d)", label, attempt, attempts))
+			}
+			if err = runOnSyncHook(container, workdir, env, hook); err == nil {
+				break
+			}
 		}
+		if err == nil {
+			if inputs[i] != "" {
+				state[key] = inputs[i]
+			}
+			continue
+		}
+
+		syncStatusDone()
+		if hook.FailurePolicy() == HookFailureWarn {
+			Warnf(WarnSync, "on_sync hook %q failed: %v", label, err)
+			continue
+		}
+		return fmt.Errorf("on_sync hook %q failed: %w", label, err)
 	}
 	syncStatusDone()
 	return nil
 }
+
+// runOnSyncHook runs a single hook, killing it if it exceeds its timeout.
+// The returned error includes the hook's combined output.
+func runOnSyncHook(container, workdir string, env []string, hook OnSyncHook) error {
+	// Ending the docker client would leave the hook running in the
+	// container, so timeout(1) stops it there. The client is only ended
+	// if that fails to, well after.
+	ctx := context.Background()
+	d := hook.TimeoutDuration()
+	if d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d+2*sessionKillGrace)
+		defer cancel()
+	}
+
+	user := "agent"
+	if hook.Root {
+		user = "root"
+	}
+	hookArgs := []string{"sh", "-c", hook.Cmd}
+	entry := AuditEntry{Kind: AuditHook, User: user, Workdir: workdir, Command: hookArgs}
+	if d > 0 {
+		hookArgs = killAfterTimeout(d, hookArgs)
+	}
+	limits := ResourceLimits{Memory: hook.Memory, CPUs: hook.CPUs}
+	scope := ""
+	if !limits.IsZero() {
+		scope = hookScopeName(hook)
+		hookArgs = scopedArgs(scope, user, limits, hookArgs)
+		user = "root"
+	}
+	args := []string{"exec", "-u", user, "-w", workdir}
+	// As in DockerExec, values go through the docker client's environment
+	// to keep them off its command line.
+	for _, kv := range env {
+		k, _, _ := strings.Cut(kv, "=")
+		args = append(args, "-e", k)
+	}
+	args = append(append(args, container), hookArgs...)
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = append(os.Environ(), env...)
+	entry.Time = time.Now()
+	output, err := cmd.CombinedOutput()
+	timedOut := ctx.Err() == context.DeadlineExceeded || d > 0 && hookTimedOut(err, time.Since(entry.Time), d)
+	if timedOut {
+		auditResult(&entry, err, context.DeadlineExceeded)
+	} else {
+		auditResult(&entry, err, ctx.Err())
+	}
+	recordAudit(container, entry)
+	if timedOut {
+		// A scoped hook's leftover children are stopped along with it.
+		if scope != "" {
+			killScope(container, scope)
+		}
+		return fmt.Errorf("timed out after %s\n%s", hook.Timeout, string(output))
+	}
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, string(output))
+	}
+	return nil
+}
+
+// hookTimedOut reports whether a hook that ran for elapsed, under a
+// timeout(1) of d, ended with err because it was stopped: timeout(1) exits
+// 124, or 137 if the hook had to be killed, once d has passed.
+func hookTimedOut(err error, elapsed, d time.Duration) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	code := exitErr.ExitCode()
+	return (code == 124 || code == 137) && elapsed >= d
+}
+
+// hookState maps a hook's identity to the when_changed hash it last
+// succeeded with. It is persisted in the container between syncs.
+type hookState map[string]string
+
+const hookStatePath = "/opt/sandbox-hook-state.json"
+
+// stateKey identifies a hook across syncs. Editing the hook definition
+// changes the key, so the edited hook runs again.
+func (h OnSyncHook) stateKey() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		h.Cmd, h.Name, fmt.Sprint(h.Root), strings.Join(h.WhenChanged, "\x00"),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// hookInputsHash hashes the files a conditional hook watches. Patterns
+// starting with "/" or "~/" match container destinations in the sync
+// manifest; all other patterns are globs relative to the workspace root.
+// Both use doublestar syntax, like sync sources.
+func hookInputsHash(hook OnSyncHook, wsPath string, items []SyncItem) string {
+	h := sha256.New()
+	for _, pattern := range hook.WhenChanged {
+		h.Write([]byte(pattern))
+		if strings.HasPrefix(pattern, "/") || strings.HasPrefix(pattern, "~/") {
+			pattern = expandContainerTilde(pattern)
+			for _, item := range items {
+				if ok, _ := doublestar.Match(pattern, item.Dest); ok {
+					h.Write([]byte(item.Dest))
+					h.Write(item.Data)
+					h.Write([]byte(item.Stamp))
+				}
+			}
+			continue
+		}
+		for _, m := range workspaceGlob(wsPath, pattern) {
+			data, err := os.ReadFile(m)
+			if err != nil {
+				continue
+			}
+			h.Write([]byte(m))
+			h.Write(data)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// workspaceGlob returns the files matching pattern, a doublestar glob
+// relative to wsPath, sorted.
+func workspaceGlob(wsPath, pattern string) []string {
+	matches, _ := doublestar.FilepathGlob(filepath.Join(wsPath, pattern), doublestar.WithFilesOnly())
+	sort.Strings(matches)
+	return matches
+}
+
+// readHookState loads the hook state from the container. A missing or
+// unreadable file yields empty state, so every conditional hook runs.
+func readHookState(container string) hookState {
+	state := make(hookState)
+	out, err := exec.Command("docker", "exec", container, "cat", hookStatePath).Output()
+	if err == nil {
+		json.Unmarshal(out, &state)
+	}
+	return state
+}
+
+// writeHookState persists the hook state into the container.
+func writeHookState(container string, state hookState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal hook state: %w", err)
+	}
+	if err := copyToContainer(container, data, hookStatePath); err != nil {
+		return fmt.Errorf("write hook state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookInputsHash(t *testing.T) {
+	t.Run("workspace file change alters hash", func(t *testing.T) {
+		ws := t.TempDir()
+		os.WriteFile(filepath.Join(ws, "package.json"), []byte(`{"v":1}`), 0644)
+		hook := OnSyncHook{Cmd: "npm install", WhenChanged: []string{"package.json"}}
+
+		before := hookInputsHash(hook, ws, nil)
+		if again := hookInputsHash(hook, ws, nil); again != before {
+			t.Fatal("hash should be stable when nothing changes")
+		}
+		os.WriteFile(filepath.Join(ws, "package.json"), []byte(`{"v":2}`), 0644)
+		if after := hookInputsHash(hook, ws, nil); after == before {
+			t.Error("hash should change when a watched file changes")
+		}
+	})
+
+	t.Run("unwatched file change ignored", func(t *testing.T) {
+		ws := t.TempDir()
+		os.WriteFile(filepath.Join(ws, "go.mod"), []byte("module a"), 0644)
+		hook := OnSyncHook{Cmd: "go mod download", WhenChanged: []string{"go.mod"}}
+
+		before := hookInputsHash(hook, ws, nil)
+		os.WriteFile(filepath.Join(ws, "README.md"), []byte("hi"), 0644)
+		if after := hookInputsHash(hook, ws, nil); after != before {
+			t.Error("hash should not change for unwatched files")
+		}
+	})
+
+	t.Run("doublestar matches nested files", func(t *testing.T) {
+		ws := t.TempDir()
+		nested := filepath.Join(ws, "src", "a", "b", "main.go")
+		os.MkdirAll(filepath.Dir(nested), 0755)
+		os.WriteFile(nested, []byte("package b"), 0644)
+		hook := OnSyncHook{Cmd: "go build ./...", WhenChanged: []string{"src/**/*.go"}}
+
+		before := hookInputsHash(hook, ws, nil)
+		os.WriteFile(nested, []byte("package b // edited"), 0644)
+		if after := hookInputsHash(hook, ws, nil); after == before {
+			t.Error("hash should change when a nested watched file changes")
+		}
+	})
+
+	t.Run("container patterns match manifest items", func(t *testing.T) {
+		hook := OnSyncHook{Cmd: "reload", WhenChanged: []string{"~/.config/*.toml"}}
+		items := []SyncItem{{Data: []byte("a"), Dest: "/home/agent/.config/app.toml"}}
+
+		before := hookInputsHash(hook, "/nonexistent", items)
+		items[0].Data = []byte("b")
+		if after := hookInputsHash(hook, "/nonexistent", items); after == before {
+			t.Error("hash should change when a watched manifest item changes")
+		}
+	})
+}
+
+func TestHookStateKey(t *testing.T) {
+	a := OnSyncHook{Cmd: "npm install", WhenChanged: []string{"package.json"}}
+	b := OnSyncHook{Cmd: "npm install", WhenChanged: []string{"package.json", "yarn.lock"}}
+	if a.stateKey() == b.stateKey() {
+		t.Error("different when_changed lists should have different keys")
+	}
+	if a.stateKey() != a.stateKey() {
+		t.Error("key should be stable")
+	}
+}
+
+func TestHookTimedOut(t *testing.T) {
+	exit := func(code string) error { return exec.Command("sh", "-c", "exit "+code).Run() }
+	tests := []struct {
+		name    string
+		err     error
+		elapsed time.Duration
+		want    bool
+	}{
+		{"stopped", exit("124"), time.Minute, true},
+		{"killed", exit("137"), time.Minute, true},
+		{"exits 124 itself", exit("124"), time.Second, false},
+		{"failed", exit("1"), time.Minute, false},
+		{"succeeded", nil, time.Minute, false},
+	}
+	for _, tt := range tests {
+		if got := hookTimedOut(tt.err, tt.elapsed, 30*time.Second); got != tt.want {
+			t.Errorf("%s: hookTimedOut = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeManifest(t *testing.T) {
+	items := []SyncItem{
+		{Dest: "/opt/b", Data: []byte("1")},
+		{Dest: "/home/agent/Z", Data: []byte("2")},
+		{Dest: "/home/agent/a", Data: []byte("3")},
+		{Dest: "/opt/b", Data: []byte("4")},
+	}
+	got := normalizeManifest(items)
+	want := []string{"/home/agent/Z", "/home/agent/a", "/opt/b"}
+	if len(got) != len(want) {
+		t.Fatalf("len = %d, want %d", len(got), len(want))
+	}
+	for i, dest := range want {
+		if got[i].Dest != dest {
+			t.Errorf("item %d dest = %q, want %q", i, got[i].Dest, dest)
+		}
+	}
+	if string(got[2].Data) != "4" {
+		t.Errorf("duplicate dest kept %q, want last item %q", got[2].Data, "4")
+	}
+}
+
+func TestExpandSyncSource(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"init.lua", "lua/a.lua", "lua/deep/b.lua", "lua/skip/c.lua", "notes.md", "x.vim"} {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(f), 0644)
+	}
+
+	rels := func(t *testing.T, base string, matches []string) map[string]bool {
+		t.Helper()
+		got := make(map[string]bool)
+		for _, m := range matches {
+			rel, err := filepath.Rel(base, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[rel] = true
+		}
+		return got
+	}
+
+	t.Run("double star recursion", func(t *testing.T) {
+		base, matches, err := expandSyncSource(filepath.Join(dir, "**", "*.lua"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if base != dir {
+			t.Errorf("base = %q, want %q", base, dir)
+		}
+		got := rels(t, base, matches)
+		for _, want := range []string{"init.lua", "lua/a.lua", "lua/deep/b.lua", "lua/skip/c.lua"} {
+			if !got[want] {
+				t.Errorf("missing match %s (got %v)", want, got)
+			}
+		}
+		if got["notes.md"] {
+			t.Error("notes.md should not match *.lua")
+		}
+	})
+
+	t.Run("brace sets", func(t *testing.T) {
+		base, matches, err := expandSyncSource(filepath.Join(dir, "*.{md,vim}"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := rels(t, base, matches)
+		if len(got) != 2 || !got["notes.md"] || !got["x.vim"] {
+			t.Errorf("matches = %v, want notes.md and x.vim", got)
+		}
+	})
+
+	t.Run("exclusions", func(t *testing.T) {
+		base, matches, err := expandSyncSource(filepath.Join(dir, "**", "*.lua"), []string{"!lua/skip/**", "init.lua"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := rels(t, base, matches)
+		if got["lua/skip/c.lua"] || got["init.lua"] {
+			t.Errorf("excluded files still matched: %v", got)
+		}
+		if !got["lua/deep/b.lua"] {
+			t.Errorf("missing lua/deep/b.lua: %v", got)
+		}
+	})
+
+	t.Run("no matches returns src", func(t *testing.T) {
+		src := filepath.Join(dir, "missing", "*.txt")
+		_, matches, err := expandSyncSource(src, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 1 || matches[0] != src {
+			t.Errorf("matches = %v, want [%s]", matches, src)
+		}
+	})
+}
+
+func TestScopedArgs(t *testing.T) {
+	got := scopedArgs("hook-abc", "agent", ResourceLimits{Memory: "512M", CPUs: 0.5}, []string{"sh", "-c", "npm test"})
+	want := []string{scopeScriptPath, "--name", "hook-abc", "--user", "agent", "--memory", "512M", "--cpus", "0.5", "--", "sh", "-c", "npm test"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got = scopedArgs("s", "root", ResourceLimits{CPUs: 2}, []string{"true"})
+	if strings.Contains(strings.Join(got, " "), "--memory") {
+		t.Errorf("got %q, memory flag should be omitted when unset", got)
+	}
+}
+
+func TestParseSyncState(t *testing.T) {
+	const id = "3f2a9c 2026-10-16T09:00:00.123456789Z"
+	for _, tt := range []struct {
+		name, record, identity, want, wantStamp string
+	}{
+		{"same container", "abc123\n" + id + "\nf00d\n", id, "abc123", "f00d"},
+		{"no stamp", "abc123\n" + id + "\n", id, "abc123", ""},
+		{"recreated container", "abc123\n9e8d7c 2026-10-16T09:00:00.123456789Z\n", id, "", ""},
+		{"restarted container", "abc123\n3f2a9c 2026-10-16T10:30:00Z\n", id, "", ""},
+		{"hash only, from an older release", "abc123\n", id, "", ""},
+		{"identity unknown", "abc123\n" + id + "\n", "", "", ""},
+	} {
+		got, stamp := parseSyncState(tt.record, tt.identity)
+		if got != tt.want || stamp != tt.wantStamp {
+			t.Errorf("%s: parseSyncState = %q, %q, want %q, %q", tt.name, got, stamp, tt.want, tt.wantStamp)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// systemDests are container files whose replacement could leave the
+// container unusable or hand the agent root.
+var systemDests = []string{
+	"/etc/passwd", "/etc/shadow", "/etc/group", "/etc/gshadow",
+	"/etc/sudoers", "/etc/hosts", "/etc/resolv.conf", "/etc/nsswitch.conf",
+}
+
+// systemDirs are container directories sync rules may not write into.
+var systemDirs = []string{
+	"/bin", "/sbin", "/lib", "/lib64", "/usr/bin", "/usr/sbin", "/usr/lib",
+	"/etc/sudoers.d", "/proc", "/sys", "/dev",
+}
+
+// managedDests are files sandbox itself writes from embedded sources or
+// its own state. Letting a sync rule replace them would, for the firewall
+// script, let a workspace config open the firewall.
+var managedDests = []string{
+	"/opt/init-firewall.sh",
+	scopeScriptPath,
+	"/usr/local/bin/hosttool-mcp",
+}
+
+// managedPrefix covers sandbox's generated files in /opt: firewall rules,
+// the sync hash, hook state and the like.
+const managedPrefix = "/opt/sandbox-"
+
+// checkSyncDest returns an error if a sync rule may not write dest, an
+// absolute container path.
+func checkSyncDest(dest string) error {
+	dest = path.Clean(dest)
+	if dest == "/" {
+		return fmt.Errorf("can't sync to /")
+	}
+	for _, p := range managedDests {
+		if dest == p {
+			return fmt.Errorf("can't sync to %s: sandbox manages it", dest)
+		}
+	}
+	if strings.HasPrefix(dest, managedPrefix) {
+		return fmt.Errorf("can't sync to %s: sandbox manages %s* files", dest, managedPrefix)
+	}
+	for _, p := range systemDests {
+		if dest == p {
+			return fmt.Errorf("can't sync to %s: replacing it could break the container", dest)
+		}
+	}
+	for _, dir := range systemDirs {
+		if dest == dir || strings.HasPrefix(dest, dir+"/") {
+			return fmt.Errorf("can't sync into %s: it holds system files", dir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckSyncDest(t *testing.T) {
+	for _, tt := range []struct {
+		dest string
+		want string // substring of the error; "" for allowed
+	}{
+		{"/home/agent/.npmrc", ""},
+		{"/usr/local/bin/tool", ""},
+		{"/etc/npmrc", ""},
+		{"/opt/app/config.json", ""},
+		{"/", "can't sync to /"},
+		{"/etc/passwd", "could break the container"},
+		{"/etc//sudoers", "could break the container"},
+		{"/usr/bin", "/usr/bin"},
+		{"/usr/bin/git", "/usr/bin"},
+		{"/usr/binary", ""},
+		{"/opt/init-firewall.sh", "sandbox manages it"},
+		{scopeScriptPath, "sandbox manages it"},
+		{"/opt/sandbox-sync.sha256", "sandbox manages"},
+	} {
+		err := checkSyncDest(tt.dest)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("checkSyncDest(%q) = %v, want allowed", tt.dest, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("checkSyncDest(%q) = %v, want error containing %q", tt.dest, err, tt.want)
+		}
+	}
+}
+
+func TestBuildSyncManifestRejectsSystemDests(t *testing.T) {
+	t.Setenv("HOME", "/nonexistent-test-home")
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "passwd"), []byte("root::0:0::/:/bin/sh\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "npmrc"), []byte("x"), 0644)
+
+	// The rule's dest is fine; one of the files it expands to isn't.
+	cfg := &SandboxConfig{Sync: []SyncRule{{Src: filepath.Join(dir, "*"), Dest: "/etc/"}}}
+	if err := validateSyncRule(cfg.Sync[0]); err != nil {
+		t.Fatalf("validateSyncRule: %v", err)
+	}
+	_, err := buildSyncManifest(cfg)
+	if err == nil || !strings.Contains(err.Error(), "/etc/passwd") {
+		t.Errorf("err = %v, want a refusal naming /etc/passwd", err)
+	}
+}
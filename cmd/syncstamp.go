@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// syncSourceStamp identifies what a sync with cfg would read, cheaply: the
+// config as written, secret references unresolved, and the size and mtime
+// of each host file the manifest is built from. Unlike syncHash it never
+// resolves secrets, reads the keychain or runs gpg and git, so `sandbox ls`
+// and session banners can afford it. A secret changed at its source isn't
+// noticed until the next sync.
+func syncSourceStamp(cfg *SandboxConfig, wsPath string) string {
+	h := sha256.New()
+	raw, _ := yaml.Marshal(cfg)
+	h.Write(raw)
+	for _, embedded := range [][]byte{firewallScript, scopeScript, hosttoolMCPScript, gpgRelayScript} {
+		h.Write(embedded)
+	}
+	stamp := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(h, "\n%s %d %d", path, info.Size(), info.ModTime().UnixNano())
+		} else {
+			fmt.Fprintf(h, "\n%s missing", path)
+		}
+	}
+	walk := func(dir string) {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				stamp(path)
+			}
+			return nil
+		})
+	}
+
+	if dir, err := HomeFilesDir(); err == nil {
+		walk(dir)
+	}
+	if dir, err := hostClaudeDir(); err == nil {
+		stamp(filepath.Join(dir, "settings.json"))
+		if cfg.HostClaude {
+			stamp(filepath.Join(dir, "CLAUDE.md"))
+			walk(filepath.Join(dir, "commands"))
+		}
+	}
+	if path, err := keyMetaPath(); err == nil {
+		stamp(path)
+	}
+	if cfg.SSH.Enabled {
+		if key, err := SSHKeyPath(); err == nil {
+			stamp(key + ".pub")
+		}
+	}
+	if cfg.GPG.Forward {
+		gnupg := os.Getenv("GNUPGHOME")
+		if gnupg == "" {
+			gnupg = expandTilde("~/.gnupg")
+		}
+		for _, f := range []string{"pubring.kbx", "pubring.gpg", "trustdb.gpg"} {
+			stamp(filepath.Join(gnupg, f))
+		}
+	}
+	if cfg.Git.Source != GitSourceConfig {
+		fmt.Fprintf(h, "\nGIT_CONFIG_GLOBAL=%s", os.Getenv("GIT_CONFIG_GLOBAL"))
+		stamp(expandTilde("~/.gitconfig"))
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			stamp(filepath.Join(xdg, "git", "config"))
+		} else {
+			stamp(expandTilde("~/.config/git/config"))
+		}
+	}
+	for _, path := range cfg.CACertificates {
+		stamp(expandTilde(path))
+	}
+	for _, f := range cfg.EnvFiles {
+		path := expandTilde(f)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(wsPath, path)
+		}
+		stamp(path)
+	}
+	for _, rule := range cfg.Sync {
+		if _, matches, err := expandSyncSource(expandTilde(rule.Src), rule.Exclude); err == nil {
+			for _, m := range matches {
+				stamp(m)
+			}
+		}
+	}
+	for _, hook := range cfg.OnSync {
+		for _, pattern := range hook.WhenChanged {
+			for _, m := range workspaceGlob(wsPath, pattern) {
+				stamp(m)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
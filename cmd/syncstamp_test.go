@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncSourceStamp(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	src := filepath.Join(ws, "notes.md")
+	os.WriteFile(src, []byte("a"), 0644)
+	// An unresolvable secret reference shows it is never resolved.
+	cfg := &SandboxConfig{
+		Env:  map[string]string{"TOKEN": "op://nowhere/item/field"},
+		Sync: []SyncRule{{Src: src, Dest: "~/notes.md"}},
+	}
+
+	stamp := syncSourceStamp(cfg, ws)
+	if syncSourceStamp(cfg, ws) != stamp {
+		t.Fatal("stamp should be stable")
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(src, later, later)
+	touched := syncSourceStamp(cfg, ws)
+	if touched == stamp {
+		t.Error("touching a synced file should change the stamp")
+	}
+	cfg.Env["TOKEN"] = "op://nowhere/item/other"
+	if syncSourceStamp(cfg, ws) == touched {
+		t.Error("changing the config should change the stamp")
+	}
+}
+
+func TestSyncPendingAfterSync(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	cfg := &SandboxConfig{Env: map[string]string{"A": "1"}}
+	syncedNow.Store("sandbox-stamp-test", syncSourceStamp(cfg, ws))
+	t.Cleanup(func() { syncedNow.Delete("sandbox-stamp-test") })
+	if SyncPending("sandbox-stamp-test", ws, cfg) {
+		t.Error("a sandbox this process just synced shouldn't be pending")
+	}
+	cfg.Env["A"] = "2"
+	if !SyncPending("sandbox-stamp-test", ws, cfg) {
+		t.Error("a config changed since should be pending")
+	}
+}
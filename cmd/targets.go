@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PackageScripts returns the script names in dir's package.json, sorted.
+func PackageScripts(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, err
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("parse package.json: %w", err)
+	}
+	names := make([]string, 0, len(pkg.Scripts))
+	for n := range pkg.Scripts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// makefileNames are the files make reads by default, in its order.
+var makefileNames = []string{"GNUmakefile", "makefile", "Makefile"}
+
+// makeRuleRe matches a rule line's targets, but not variable assignments
+// such as "X := y" or "X ::= y".
+var makeRuleRe = regexp.MustCompile(`^([^:#=\t][^:#=]*?)\s*::?(?:[^:=]|$)`)
+
+// MakeTargets returns the explicit targets in dir's Makefile, sorted.
+// Pattern rules, special targets such as .PHONY and targets containing
+// variable references are left out.
+func MakeTargets(dir string) ([]string, error) {
+	var data []byte
+	var err error
+	for _, name := range makefileNames {
+		if data, err = os.ReadFile(filepath.Join(dir, name)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var targets []string
+	for _, line := range strings.Split(string(data), "\n") {
+		m := makeRuleRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, t := range strings.Fields(m[1]) {
+			if strings.HasPrefix(t, ".") || strings.ContainsAny(t, "%$") || seen[t] {
+				continue
+			}
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackageScripts(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"scripts": {"test": "jest", "build": "tsc"}}`), 0644)
+	got, err := PackageScripts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "build,test" {
+		t.Errorf("scripts = %v, want [build test]", got)
+	}
+}
+
+func TestMakeTargets(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "Makefile"), []byte(`CC := gcc
+VERSION ::= 1
+.PHONY: all test
+all: build
+
+build lint: deps
+	$(CC) -o app main.c
+	echo "not: a target"
+%.o: %.c
+	$(CC) -c $<
+$(OUT)/x: y
+test::
+install: ## Install the binary
+`), 0644)
+	got, err := MakeTargets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "all,build,install,lint,test"; strings.Join(got, ",") != want {
+		t.Errorf("targets = %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// taskfileNames are the file names task(1) looks for, in its order of
+// preference.
+var taskfileNames = []string{
+	"Taskfile.yml", "taskfile.yml", "Taskfile.yaml", "taskfile.yaml",
+	"Taskfile.dist.yml", "taskfile.dist.yml", "Taskfile.dist.yaml", "taskfile.dist.yaml",
+}
+
+// FindTaskfile returns the Taskfile task would use when run in dir: the
+// first one found in dir or its parents, stopping at root. It returns "" if
+// there is none.
+func FindTaskfile(dir, root string) string {
+	for {
+		if path := taskfileIn(dir); path != "" {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// taskfileIn returns the Taskfile directly inside dir, or "".
+func taskfileIn(dir string) string {
+	for _, name := range taskfileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// TaskTarget is a task defined in a Taskfile.
+type TaskTarget struct {
+	Name string
+	Desc string
+}
+
+// taskfile is the subset of the Taskfile schema needed to list targets.
+type taskfile struct {
+	Includes map[string]taskInclude `yaml:"includes"`
+	Tasks    map[string]struct {
+		Desc     string `yaml:"desc"`
+		Internal bool   `yaml:"internal"`
+	} `yaml:"tasks"`
+}
+
+// taskInclude is an includes entry, written either as a bare path or as a
+// mapping with a taskfile key.
+type taskInclude struct {
+	Taskfile string `yaml:"taskfile"`
+	Internal bool   `yaml:"internal"`
+}
+
+func (i *taskInclude) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		i.Taskfile = n.Value
+		return nil
+	}
+	type plain taskInclude
+	return n.Decode((*plain)(i))
+}
+
+// maxTaskIncludeDepth bounds nested includes, guarding against cycles.
+const maxTaskIncludeDepth = 5
+
+// TaskfileTargets lists the public tasks in the Taskfile at path, including
+// those from included Taskfiles as "namespace:task", sorted by name.
+func TaskfileTargets(path string) ([]TaskTarget, error) {
+	targets, err := taskfileTargets(path, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
+
+func taskfileTargets(path, prefix string, depth int) ([]TaskTarget, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read Taskfile: %w", err)
+	}
+	var tf taskfile
+	if err := yaml.Unmarshal(data, &tf); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	var targets []TaskTarget
+	for name, t := range tf.Tasks {
+		if !t.Internal {
+			targets = append(targets, TaskTarget{Name: prefix + name, Desc: t.Desc})
+		}
+	}
+	if depth >= maxTaskIncludeDepth {
+		return targets, nil
+	}
+	for ns, inc := range tf.Includes {
+		// Includes with template variables can't be resolved without task.
+		if inc.Internal || inc.Taskfile == "" || strings.Contains(inc.Taskfile, "{{") {
+			continue
+		}
+		incPath := expandTilde(inc.Taskfile)
+		if !filepath.IsAbs(incPath) {
+			incPath = filepath.Join(filepath.Dir(path), incPath)
+		}
+		if info, err := os.Stat(incPath); err == nil && info.IsDir() {
+			incPath = taskfileIn(incPath)
+		}
+		if incPath == "" {
+			continue
+		}
+		sub, err := taskfileTargets(incPath, prefix+ns+":", depth+1)
+		if err != nil {
+			Warnf(WarnConfig, "Taskfile include %q: %v", ns, err)
+			continue
+		}
+		targets = append(targets, sub...)
+	}
+	return targets, nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindTaskfile(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	os.MkdirAll(sub, 0755)
+
+	if got := FindTaskfile(sub, root); got != "" {
+		t.Errorf("FindTaskfile = %q, want none", got)
+	}
+	os.WriteFile(filepath.Join(root, "Taskfile.yaml"), []byte("version: '3'\n"), 0644)
+	if got, want := FindTaskfile(sub, root), filepath.Join(root, "Taskfile.yaml"); got != want {
+		t.Errorf("FindTaskfile = %q, want %q", got, want)
+	}
+	if got := FindTaskfile(sub, filepath.Join(root, "a")); got != "" {
+		t.Errorf("FindTaskfile = %q, should not search above root", got)
+	}
+}
+
+func TestTaskfileTargets(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	os.WriteFile(filepath.Join(dir, "Taskfile.yml"), []byte(`version: '3'
+includes:
+  docs: ./docs
+  lib:
+    taskfile: ./lib.yml
+  tpl: ./{{.OS}}.yml
+tasks:
+  build:
+    desc: Build everything
+    cmds: [go build ./...]
+  test:
+    cmds: [go test ./...]
+  helper:
+    internal: true
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "docs", "Taskfile.yml"), []byte("tasks:\n  serve:\n    desc: Serve docs\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "lib.yml"), []byte("tasks:\n  gen: {}\n"), 0644)
+
+	targets, err := TaskfileTargets(filepath.Join(dir, "Taskfile.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TaskTarget{
+		{Name: "build", Desc: "Build everything"},
+		{Name: "docs:serve", Desc: "Serve docs"},
+		{Name: "lib:gen"},
+		{Name: "test"},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("targets = %v, want %v", targets, want)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("target %d = %v, want %v", i, targets[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ToolchainsConfig pins the versions of the image's toolchains. Unlike
+// image, a workspace or profile can set it: a sandbox whose versions
+// differ from the defaults gets an image of its own.
+type ToolchainsConfig struct {
+	// Node is an nvm version, e.g. "18" or "lts/hydrogen". Default the
+	// latest LTS release.
+	Node string `yaml:"node,omitempty"`
+
+	// Go is a full Go release, e.g. "1.22.5".
+	Go string `yaml:"go,omitempty"`
+
+	// Rust is a rustup toolchain, e.g. "1.79.0". Default stable.
+	Rust string `yaml:"rust,omitempty"`
+
+	// Python is a CPython release installed with uv, e.g. "3.12". Default
+	// Debian's python3.
+	Python string `yaml:"python,omitempty"`
+
+	// Ruby is a release built with ruby-build, e.g. "3.3.4". Default
+	// Debian's ruby.
+	Ruby string `yaml:"ruby,omitempty"`
+}
+
+var toolchainVersionRe = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._*/-]*$`)
+
+// versions returns t's fields by name, in a fixed order.
+func (t ToolchainsConfig) versions() []struct{ name, arg, version string } {
+	return []struct{ name, arg, version string }{
+		{"node", "NODE_VERSION", t.Node},
+		{"go", "GO_VERSION", t.Go},
+		{"rust", "RUST_VERSION", t.Rust},
+		{"python", "PYTHON_VERSION", t.Python},
+		{"ruby", "RUBY_VERSION", t.Ruby},
+	}
+}
+
+func validateToolchains(t ToolchainsConfig) error {
+	for _, v := range t.versions() {
+		if v.version != "" && !toolchainVersionRe.MatchString(v.version) {
+			return fmt.Errorf("invalid toolchains.%s %q", v.name, v.version)
+		}
+	}
+	return nil
+}
+
+// buildArgs returns the Dockerfile build args t sets, as NAME=version.
+func (t ToolchainsConfig) buildArgs() map[string]string {
+	args := make(map[string]string)
+	for _, v := range t.versions() {
+		if v.version != "" {
+			args[v.arg] = v.version
+		}
+	}
+	return args
+}
+
+// tag returns what imageRef appends for t: "" for the default versions,
+// else a short hash of the pinned ones.
+func (t ToolchainsConfig) tag() string {
+	if t == (ToolchainsConfig{}) {
+		return ""
+	}
+	var b strings.Builder
+	for _, v := range t.versions() {
+		fmt.Fprintf(&b, "%s=%s\n", v.name, v.version)
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// configToolchains returns cfg's toolchains, or none for a nil cfg.
+func configToolchains(cfg *SandboxConfig) ToolchainsConfig {
+	if cfg == nil {
+		return ToolchainsConfig{}
+	}
+	return cfg.Toolchains
+}
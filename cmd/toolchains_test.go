@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestToolchainsMerge(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(home, ".sandbox", "config.yaml"), []byte(`
+image:
+  build_args:
+    GO_VERSION: "1.23.0"
+toolchains:
+  go: 1.24.1
+  rust: 1.79.0
+`), 0644)
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("toolchains:\n  node: \"18\"\n  go: 1.22.5\n"), 0644)
+
+	cfg, err := LoadConfig(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ToolchainsConfig{Node: "18", Go: "1.22.5", Rust: "1.79.0"}
+	if cfg.Toolchains != want {
+		t.Errorf("toolchains = %+v, want %+v", cfg.Toolchains, want)
+	}
+	args := imageBuildArgs(cfg.Toolchains)
+	wantArgs := []string{"GO_VERSION=1.22.5", "NODE_VERSION=18", "RUST_VERSION=1.79.0"}
+	if !slices.Equal(args, wantArgs) {
+		t.Errorf("imageBuildArgs = %q, want %q with toolchains over image.build_args", args, wantArgs)
+	}
+}
+
+func TestToolchainsImageRef(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	plain := imageRef(VariantFull, "linux/amd64", ToolchainsConfig{})
+	if plain != "sandbox:full-amd64" {
+		t.Errorf("imageRef = %q, want sandbox:full-amd64 with no versions pinned", plain)
+	}
+	node18 := imageRef(VariantFull, "linux/amd64", ToolchainsConfig{Node: "18"})
+	node20 := imageRef(VariantFull, "linux/amd64", ToolchainsConfig{Node: "20"})
+	if !strings.HasPrefix(node18, plain+"-") || node18 == node20 {
+		t.Errorf("imageRef = %q and %q, want a tag of their own for each pin", node18, node20)
+	}
+	if imageHash(VariantFull, "linux/amd64", ToolchainsConfig{Node: "18"}) == imageHash(VariantFull, "linux/amd64", ToolchainsConfig{}) {
+		t.Error("pinning a version should change the image hash")
+	}
+}
+
+func TestValidateToolchains(t *testing.T) {
+	if err := validateToolchains(ToolchainsConfig{Node: "lts/hydrogen", Go: "1.22.5", Rust: "nightly-2024-06-01", Python: "3.12", Ruby: "3.3.4"}); err != nil {
+		t.Errorf("validateToolchains = %v", err)
+	}
+	for _, bad := range []ToolchainsConfig{{Node: "18; rm -rf /"}, {Python: "$(id)"}, {Go: "-1"}} {
+		if validateToolchains(bad) == nil {
+			t.Errorf("validateToolchains(%+v) should fail", bad)
+		}
+	}
+}
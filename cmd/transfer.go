@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partialSuffix marks an unfinished large transfer next to its destination.
+const partialSuffix = ".sandbox-partial-"
+
+// transferLarge copies item.Source to item.Dest in the container in chunks,
+// at no more than t's max rate. Chunks are appended to a partial file named
+// after the source's stamp, so a transfer that was interrupted resumes where
+// it stopped at the next sync as long as the source hasn't changed. The file
+// is renamed into place once complete.
+func transferLarge(container string, item SyncItem, t TransferConfig) error {
+	_, chunkSize, maxRate := t.sizes()
+
+	f, err := os.Open(item.Source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if fmt.Sprintf("%d:%d", size, info.ModTime().UnixNano()) != item.Stamp {
+		return fmt.Errorf("%s changed during sync; sync again", item.Source)
+	}
+
+	partial := item.Dest + partialSuffix + sha256Hex([]byte(item.Stamp))[:12]
+	offset, err := partialOffset(container, item.Dest, partial, size)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var r io.Reader = f
+	if maxRate > 0 {
+		r = &rateLimitedReader{r: f, rate: maxRate, start: time.Now()}
+	}
+	progress := &transferProgress{dest: item.Dest, size: size, done: offset, start: time.Now()}
+	for offset < size {
+		n := min(chunkSize, size-offset)
+		c := exec.Command("docker", "exec", "-i", "-u", "root", container,
+			"sh", "-c", `cat >> "$1"`, "sh", partial)
+		c.Stdin = &countingReader{r: io.LimitReader(r, n), progress: progress}
+		if out, err := c.CombinedOutput(); err != nil {
+			return fmt.Errorf("chunk at %s: %v %s (sync again to resume)", formatSize(offset), err, strings.TrimSpace(string(out)))
+		}
+		offset += n
+	}
+
+	got, err := partialOffset(container, item.Dest, partial, size)
+	if err != nil {
+		return err
+	}
+	if got != size {
+		exec.Command("docker", "exec", "-u", "root", container, "rm", "-f", partial).Run()
+		return fmt.Errorf("transferred %d bytes, want %d; sync again to retry", got, size)
+	}
+	if out, err := exec.Command("docker", "exec", "-u", "root", container, "mv", "-f", partial, item.Dest).CombinedOutput(); err != nil {
+		return fmt.Errorf("move into place: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// partialOffset returns how much of partial the container already has,
+// removing partial files for dest left by other versions of the source and
+// any partial file that is longer than size.
+func partialOffset(container, dest, partial string, size int64) (int64, error) {
+	const script = `for f in "$1"*; do [ "$f" = "$2" ] || rm -f -- "$f"; done
+n=$(stat -c %s -- "$2" 2>/dev/null || echo 0)
+if [ "$n" -gt "$3" ]; then rm -f -- "$2"; n=0; fi
+echo "$n"`
+	out, err := exec.Command("docker", "exec", "-u", "root", container, "sh", "-c", script,
+		"sh", dest+partialSuffix, partial, strconv.FormatInt(size, 10)).Output()
+	if err != nil {
+		return 0, fmt.Errorf("check partial transfer: %w", err)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// rateLimitedReader delays reads so that on average no more than rate
+// bytes per second pass through it.
+type rateLimitedReader struct {
+	r     io.Reader
+	rate  int64
+	start time.Time
+	n     int64
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	// Cap reads at a tenth of a second's worth so the rate is smooth.
+	if limit := max(l.rate/10, 1); int64(len(p)) > limit {
+		p = p[:limit]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	due := time.Duration(float64(l.n) / float64(l.rate) * float64(time.Second))
+	if wait := due - time.Since(l.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+// transferProgress reports a large transfer on the sync status line.
+type transferProgress struct {
+	dest        string
+	size, done  int64
+	start       time.Time
+	sent        int64 // bytes sent by this run, for the rate
+	lastPrinted time.Time
+}
+
+func (p *transferProgress) add(n int) {
+	p.done += int64(n)
+	p.sent += int64(n)
+	if time.Since(p.lastPrinted) < 250*time.Millisecond && p.done < p.size {
+		return
+	}
+	p.lastPrinted = time.Now()
+	msg := fmt.Sprintf("%s %s/%s", p.dest, formatSize(p.done), formatSize(p.size))
+	if secs := time.Since(p.start).Seconds(); secs > 0 {
+		msg += fmt.Sprintf(" (%s/s)", formatSize(int64(float64(p.sent)/secs)))
+	}
+	syncStatus(msg)
+}
+
+// countingReader reports bytes read from r to progress.
+type countingReader struct {
+	r        io.Reader
+	progress *transferProgress
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.progress.add(n)
+	return n, err
+}
+
+// formatSize renders a byte count with a binary unit, e.g. "1.5G".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"", 0, true},
+		{"512", 512, true},
+		{"8K", 8 << 10, true},
+		{"64m", 64 << 20, true},
+		{"2G", 2 << 30, true},
+		{"1.5G", 0, false},
+		{"lots", 0, false},
+		{"99999999999G", 0, false},
+	} {
+		got, err := parseSize(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d, ok=%v", tc.in, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		100:      "100B",
+		1536:     "1.5K",
+		64 << 20: "64.0M",
+		3 << 30:  "3.0G",
+		5 << 40:  "5.0T",
+	} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestLargeSyncItems(t *testing.T) {
+	t.Setenv("HOME", "/nonexistent-test-home")
+	t.Setenv("ZSH_THEME", "")
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "small.txt"), []byte("hi"), 0644)
+	os.WriteFile(filepath.Join(dir, "weights.bin"), bytes.Repeat([]byte{1}, 2048), 0644)
+
+	cfg := &SandboxConfig{
+		Sync:     []SyncRule{{Src: filepath.Join(dir, "*"), Dest: "/data/"}},
+		Transfer: TransferConfig{LargeFile: "1K"},
+	}
+	items, err := buildSyncManifest(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byDest := make(map[string]SyncItem)
+	for _, item := range items {
+		byDest[item.Dest] = item
+	}
+	if small := byDest["/data/small.txt"]; small.Large || string(small.Data) != "hi" {
+		t.Errorf("small file = %+v, want inline data", small)
+	}
+	large := byDest["/data/weights.bin"]
+	if !large.Large || large.Data != nil || large.Size != 2048 || large.Stamp == "" {
+		t.Errorf("large file = %+v, want streamed with a stamp", large)
+	}
+
+	// A new version of the large file must change the sync hash even
+	// though its data isn't in the manifest.
+	before := syncHash(cfg, dir, items)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "weights.bin"), later, later)
+	items, _ = buildSyncManifest(cfg)
+	if syncHash(cfg, dir, items) == before {
+		t.Error("touching a large file should change the sync hash")
+	}
+}
+
+func TestRateLimitedReader(t *testing.T) {
+	data := bytes.Repeat([]byte{1}, 3000)
+	start := time.Now()
+	r := &rateLimitedReader{r: bytes.NewReader(data), rate: 10000, start: start}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(data) {
+		t.Fatalf("read %d bytes, want %d", len(got), len(data))
+	}
+	// 3000 bytes at 10000 B/s takes about 300ms.
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("read took %v, want at least 250ms", elapsed)
+	}
+}
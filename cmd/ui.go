@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// UI is where the core logic reports what it is doing. The CLI uses a
+// TerminalUI; embedders, tests and other frontends install their own in
+// Frontend so the same sync and session code can drive them.
+type UI interface {
+	// Info reports progress, such as starting or syncing a sandbox.
+	Info(msg string)
+	// Note is an aside, such as a hint to migrate old files.
+	Note(msg string)
+	// Warn reports a problem that doesn't stop the command. Use Warnf,
+	// which also records the warning for the summary.
+	Warn(msg string)
+	// Alert reports something the user must not miss, such as a session
+	// about to time out or a tampered script. It may arrive while a
+	// session holds the terminal in raw mode.
+	Alert(msg string)
+	// Status replaces the transient status line; "" clears it.
+	Status(msg string)
+	// Stdout and Stderr receive the output of docker and other child
+	// processes, and multi-line reports such as the warning summary.
+	Stdout() io.Writer
+	Stderr() io.Writer
+}
+
+// Frontend is the UI that the core logic reports to.
+var Frontend UI = NewTerminalUI(os.Stdout, os.Stderr)
+
+// TerminalUI writes to a terminal: progress to out, everything else to err,
+// with ANSI styling for the status line and alerts. Known secrets are
+// redacted from its messages; see Redact.
+type TerminalUI struct {
+	out, err io.Writer
+}
+
+func NewTerminalUI(out, err io.Writer) *TerminalUI {
+	return &TerminalUI{out: out, err: err}
+}
+
+func (t *TerminalUI) Info(msg string) { fmt.Fprintln(t.out, Redact(msg)) }
+
+func (t *TerminalUI) Note(msg string) { fmt.Fprintln(t.err, Redact(strings.TrimSuffix(msg, "\n"))) }
+
+func (t *TerminalUI) Warn(msg string) { fmt.Fprintf(t.err, "warning: %s\n", Redact(msg)) }
+
+// Alert rings the bell and uses \r\n, which a raw-mode terminal needs to
+// start the next line at the left margin.
+func (t *TerminalUI) Alert(msg string) {
+	fmt.Fprintf(t.err, "\a\r\n\033[1;31msandbox: %s\033[0m\r\n", Redact(msg))
+}
+
+func (t *TerminalUI) Status(msg string) {
+	if msg == "" {
+		fmt.Fprint(t.err, "\r\033[K")
+		return
+	}
+	fmt.Fprintf(t.err, "\r\033[K  \033[2m%s\033[0m", Redact(msg))
+}
+
+func (t *TerminalUI) Stdout() io.Writer { return t.out }
+
+func (t *TerminalUI) Stderr() io.Writer { return t.err }
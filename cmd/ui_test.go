@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// recordingUI keeps what was reported to it, by kind.
+type recordingUI struct {
+	infos, notes, warnings, alerts, statuses []string
+	out, err                                 bytes.Buffer
+}
+
+func (r *recordingUI) Info(msg string)   { r.infos = append(r.infos, msg) }
+func (r *recordingUI) Note(msg string)   { r.notes = append(r.notes, msg) }
+func (r *recordingUI) Warn(msg string)   { r.warnings = append(r.warnings, msg) }
+func (r *recordingUI) Alert(msg string)  { r.alerts = append(r.alerts, msg) }
+func (r *recordingUI) Status(msg string) { r.statuses = append(r.statuses, msg) }
+func (r *recordingUI) Stdout() io.Writer { return &r.out }
+func (r *recordingUI) Stderr() io.Writer { return &r.err }
+
+// useRecordingUI installs a recordingUI as the Frontend for the test.
+func useRecordingUI(t *testing.T) *recordingUI {
+	t.Helper()
+	prev := Frontend
+	r := &recordingUI{}
+	Frontend = r
+	t.Cleanup(func() { Frontend = prev })
+	return r
+}
+
+func TestWarnfReportsToFrontend(t *testing.T) {
+	resetWarnings(t)
+	r := useRecordingUI(t)
+
+	Warnf(WarnSync, "cannot read %s\n", "x")
+	if len(r.warnings) != 1 || r.warnings[0] != "cannot read x" {
+		t.Errorf("warnings = %q, want [\"cannot read x\"]", r.warnings)
+	}
+
+	syncStatus("copying")
+	syncStatusDone()
+	if len(r.statuses) != 2 || r.statuses[0] != "copying" || r.statuses[1] != "" {
+		t.Errorf("statuses = %q, want [\"copying\" \"\"]", r.statuses)
+	}
+}
+
+func TestTerminalUI(t *testing.T) {
+	var out, errOut bytes.Buffer
+	ui := NewTerminalUI(&out, &errOut)
+
+	ui.Info("Starting sandbox")
+	ui.Note("note: hint\n")
+	ui.Warn("careful")
+	if got, want := out.String(), "Starting sandbox\n"; got != want {
+		t.Errorf("out = %q, want %q", got, want)
+	}
+	if got, want := errOut.String(), "note: hint\nwarning: careful\n"; got != want {
+		t.Errorf("err = %q, want %q", got, want)
+	}
+
+	errOut.Reset()
+	ui.Status("copying")
+	ui.Status("")
+	if got, want := errOut.String(), "\r\033[K  \033[2mcopying\033[0m\r\033[K"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// LabelFirewallHash records the sha256 of the firewall script a container was
+// created with, so the expected content is visible in `docker inspect`.
+const LabelFirewallHash = "sandbox.firewall-script.sha256"
+
+// protectedScript is an embedded script whose in-container copy must match.
+type protectedScript struct {
+	Path     string
+	Data     []byte
+	Required bool // missing counts as tampering; optional scripts are only synced with host tools or gpg.forward
+}
+
+// protectedScripts lists the embedded scripts checked by VerifyScripts.
+func protectedScripts() []protectedScript {
+	return []protectedScript{
+		{Path: "/opt/init-firewall.sh", Data: firewallScript, Required: true},
+		{Path: scopeScriptPath, Data: scopeScript, Required: true},
+		{Path: "/usr/local/bin/hosttool-mcp", Data: hosttoolMCPScript},
+		{Path: gpgRelayPath, Data: gpgRelayScript},
+	}
+}
+
+// ScriptCheck is the result of comparing one in-container script with the
+// copy embedded in this binary.
+type ScriptCheck struct {
+	Path string
+	Want string // expected sha256
+	Got  string // in-container sha256, "" if missing
+}
+
+// OK reports whether the in-container copy matches.
+func (c ScriptCheck) OK() bool { return c.Got == c.Want }
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyScripts hashes the protected scripts inside the container and returns
+// a check for each one that is present or required.
+func VerifyScripts(container string) ([]ScriptCheck, error) {
+	scripts := protectedScripts()
+	args := []string{"exec", "-u", "root", container, "sha256sum"}
+	for _, s := range scripts {
+		args = append(args, s.Path)
+	}
+	// sha256sum exits non-zero when a file is missing but still reports the
+	// others, so only fail if it produced nothing.
+	out, err := exec.Command("docker", args...).Output()
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("hash scripts in %s: %w", container, err)
+	}
+	got := parseSha256sum(string(out))
+
+	var checks []ScriptCheck
+	for _, s := range scripts {
+		h, present := got[s.Path]
+		if !present && !s.Required {
+			continue
+		}
+		checks = append(checks, ScriptCheck{Path: s.Path, Want: sha256Hex(s.Data), Got: h})
+	}
+	return checks, nil
+}
+
+// parseSha256sum maps paths to hashes from sha256sum output.
+func parseSha256sum(out string) map[string]string {
+	hashes := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			hashes[strings.TrimPrefix(fields[1], "*")] = fields[0]
+		}
+	}
+	return hashes
+}
+
+// RestoreScripts replaces tampered scripts with the embedded copies and, if
+// the firewall script was among them, re-applies the firewall.
+func RestoreScripts(container string, checks []ScriptCheck) error {
+	var items []SyncItem
+	firewall := false
+	for _, c := range checks {
+		if c.OK() {
+			continue
+		}
+		for _, s := range protectedScripts() {
+			if s.Path == c.Path {
+				items = append(items, SyncItem{Data: s.Data, Dest: s.Path, Mode: "0755", Owner: "root:root"})
+			}
+		}
+		firewall = firewall || c.Path == "/opt/init-firewall.sh"
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	if err := syncItems(container, items, TransferConfig{}); err != nil {
+		return fmt.Errorf("restore scripts: %w", err)
+	}
+	if firewall {
+		if err := exec.Command("docker", "exec", "-u", "root", container, "/opt/init-firewall.sh").Run(); err != nil {
+			return fmt.Errorf("re-apply firewall: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifyAndRestore checks the container's scripts, warning about and
+// restoring any that were modified. Errors are reported, not returned, so a
+// broken check never blocks a session.
+func verifyAndRestore(container string) {
+	checks, err := VerifyScripts(container)
+	if err != nil {
+		Warnf(WarnContainer, "cannot verify sandbox scripts: %v", err)
+		return
+	}
+	tampered := false
+	for _, c := range checks {
+		if !c.OK() {
+			tampered = true
+			Frontend.Alert(fmt.Sprintf("%s in %s was modified inside the sandbox; restoring it", c.Path, container))
+		}
+	}
+	if tampered {
+		if err := RestoreScripts(container, checks); err != nil {
+			Warnf(WarnContainer, "%v", err)
+		}
+	}
+}
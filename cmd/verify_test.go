@@ -0,0 +1,27 @@
+package cmd
+
+import "testing"
+
+func TestParseSha256sum(t *testing.T) {
+	out := "abc123  /opt/init-firewall.sh\ndef456 */usr/local/bin/hosttool-mcp\nsha256sum: /missing: No such file or directory\n"
+	got := parseSha256sum(out)
+	if got["/opt/init-firewall.sh"] != "abc123" {
+		t.Errorf("firewall hash = %q, want abc123", got["/opt/init-firewall.sh"])
+	}
+	if got["/usr/local/bin/hosttool-mcp"] != "def456" {
+		t.Errorf("binary-mode hash = %q, want def456", got["/usr/local/bin/hosttool-mcp"])
+	}
+	if len(got) != 2 {
+		t.Errorf("got %v, want 2 entries", got)
+	}
+}
+
+func TestScriptCheck(t *testing.T) {
+	want := sha256Hex(firewallScript)
+	if !(ScriptCheck{Want: want, Got: want}).OK() {
+		t.Error("matching hashes should be OK")
+	}
+	if (ScriptCheck{Want: want, Got: ""}).OK() {
+		t.Error("missing script should not be OK")
+	}
+}
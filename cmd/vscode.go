@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// VSCodeConfig sets up the VSCode that `sandbox code` attaches to the
+// sandbox.
+type VSCodeConfig struct {
+	// Extensions are marketplace IDs such as "golang.go", installed in the
+	// container's VSCode server.
+	Extensions []string `yaml:"extensions,omitempty"`
+}
+
+var extensionIDRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*\.[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func validateExtensionID(id string) error {
+	if !extensionIDRe.MatchString(id) {
+		return fmt.Errorf("invalid vscode extension %q, want publisher.name", id)
+	}
+	return nil
+}
+
+// VSCodeExtensions returns the configured extensions without duplicates,
+// which merging global and workspace lists can leave. IDs are compared
+// without case, as the marketplace does.
+func (c *SandboxConfig) VSCodeExtensions() []string {
+	var exts []string
+	seen := make(map[string]bool)
+	for _, id := range c.VSCode.Extensions {
+		if key := strings.ToLower(id); !seen[key] {
+			seen[key] = true
+			exts = append(exts, id)
+		}
+	}
+	return exts
+}
+
+// vscodeFirewallEntries allows the container's VSCode server to download
+// the configured extensions: the marketplace, and the asset hosts of each
+// extension's publisher.
+func vscodeFirewallEntries(cfg *SandboxConfig) []FirewallEntry {
+	exts := cfg.VSCodeExtensions()
+	if len(exts) == 0 {
+		return nil
+	}
+	entries := []FirewallEntry{{Domain: "marketplace.visualstudio.com"}}
+	seen := make(map[string]bool)
+	for _, id := range exts {
+		publisher := strings.ToLower(strings.SplitN(id, ".", 2)[0])
+		if seen[publisher] {
+			continue
+		}
+		seen[publisher] = true
+		entries = append(entries,
+			FirewallEntry{Domain: publisher + ".gallery.vsassets.io"},
+			FirewallEntry{Domain: publisher + ".gallerycdn.vsassets.io"})
+	}
+	return entries
+}
+
+// attachedConfigPath returns VSCode's attached container configuration for
+// container, which lists extensions VSCode installs whenever it attaches to
+// a container of that name.
+func attachedConfigPath(container string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "Code", "User", "globalStorage", "ms-vscode-remote.remote-containers",
+		"nameConfigs", container+".json"), nil
+}
+
+// writeAttachedConfig adds exts to the extensions in container's attached
+// container configuration, keeping anything else the user put there.
+func writeAttachedConfig(container string, exts []string) error {
+	path, err := attachedConfigPath(container)
+	if err != nil {
+		return err
+	}
+	conf := make(map[string]any)
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &conf); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	var have []string
+	if list, ok := conf["extensions"].([]any); ok {
+		for _, e := range list {
+			if s, ok := e.(string); ok {
+				have = append(have, s)
+			}
+		}
+	}
+	merged := append([]string{}, have...)
+	for _, id := range exts {
+		if !slices.ContainsFunc(have, func(h string) bool { return strings.EqualFold(h, id) }) {
+			merged = append(merged, id)
+		}
+	}
+	if len(merged) == len(have) && len(have) > 0 {
+		return nil
+	}
+	conf["extensions"] = merged
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// installExtensionsScript installs the extensions given as arguments with
+// the newest VSCode server in the container that doesn't already have them,
+// printing each one installed. It does nothing if VSCode has never attached,
+// as there is no server yet.
+const installExtensionsScript = `server=$(ls -td "$HOME"/.vscode-server/bin/*/bin/code-server \
+	"$HOME"/.vscode-server/cli/servers/*/server/bin/code-server 2>/dev/null | head -n 1)
+[ -n "$server" ] || exit 0
+have=$("$server" --list-extensions 2>/dev/null)
+status=0
+for ext; do
+	printf '%s\n' "$have" | grep -qix -- "$ext" && continue
+	"$server" --install-extension "$ext" >/dev/null 2>&1 && echo "$ext" || { echo "failed to install $ext" >&2; status=1; }
+done
+exit $status`
+
+// PrepareVSCode gets the configured extensions into container's VSCode:
+// installed straight away with the VSCode server if it is already in the
+// container, and listed in the attached container configuration so VSCode
+// installs any that are missing when it attaches.
+func PrepareVSCode(container string, cfg *SandboxConfig) error {
+	exts := cfg.VSCodeExtensions()
+	if len(exts) == 0 {
+		return nil
+	}
+	if err := writeAttachedConfig(container, exts); err != nil {
+		Warnf(WarnContainer, "cannot write VSCode's attached container config: %v", err)
+	}
+	syncStatus("installing VSCode extensions")
+	c := exec.Command("docker", append([]string{"exec", "-u", "agent", container,
+		"bash", "-c", installExtensionsScript, "install"}, exts...)...)
+	var stderr strings.Builder
+	c.Stderr = &stderr
+	out, err := c.Output()
+	syncStatusDone()
+	if installed := strings.Fields(string(out)); len(installed) > 0 {
+		Frontend.Info(fmt.Sprintf("Installed VSCode extensions: %s", strings.Join(installed, ", ")))
+	}
+	if err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestValidateExtensionID(t *testing.T) {
+	for _, id := range []string{"golang.go", "ms-python.python", "esbenp.prettier-vscode", "ms-vscode.cpptools-extension-pack"} {
+		if err := validateExtensionID(id); err != nil {
+			t.Errorf("validateExtensionID(%q): %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "golang", ".go", "golang.", "ms python.python", "a.b;rm -rf"} {
+		if err := validateExtensionID(id); err == nil {
+			t.Errorf("validateExtensionID(%q) succeeded, want error", id)
+		}
+	}
+}
+
+func TestVSCodeExtensionsAndFirewall(t *testing.T) {
+	cfg := mergeConfig(
+		&SandboxConfig{VSCode: VSCodeConfig{Extensions: []string{"golang.go", "ms-python.python"}}},
+		&SandboxConfig{VSCode: VSCodeConfig{Extensions: []string{"Golang.Go", "ms-python.vscode-pylance"}}},
+	)
+	if got, want := cfg.VSCodeExtensions(), []string{"golang.go", "ms-python.python", "ms-python.vscode-pylance"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("VSCodeExtensions = %q, want %q", got, want)
+	}
+
+	var domains []string
+	for _, e := range vscodeFirewallEntries(cfg) {
+		domains = append(domains, e.Domain)
+	}
+	want := []string{"marketplace.visualstudio.com",
+		"golang.gallery.vsassets.io", "golang.gallerycdn.vsassets.io",
+		"ms-python.gallery.vsassets.io", "ms-python.gallerycdn.vsassets.io"}
+	if !reflect.DeepEqual(domains, want) {
+		t.Errorf("firewall domains = %q, want %q", domains, want)
+	}
+	if got := vscodeFirewallEntries(&SandboxConfig{}); got != nil {
+		t.Errorf("no extensions should allow nothing, got %v", got)
+	}
+}
+
+func TestWriteAttachedConfig(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("relies on XDG_CONFIG_HOME for os.UserConfigDir")
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, err := attachedConfigPath("sandbox-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte(`{"workspaceFolder": "/ws", "extensions": ["golang.go"]}`), 0644)
+
+	if err := writeAttachedConfig("sandbox-app", []string{"Golang.go", "ms-python.python"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	var conf struct {
+		WorkspaceFolder string   `json:"workspaceFolder"`
+		Extensions      []string `json:"extensions"`
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.WorkspaceFolder != "/ws" {
+		t.Errorf("workspaceFolder = %q, want it kept", conf.WorkspaceFolder)
+	}
+	if want := []string{"golang.go", "ms-python.python"}; !reflect.DeepEqual(conf.Extensions, want) {
+		t.Errorf("extensions = %q, want %q", conf.Extensions, want)
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Warning categories, which group the summary printed when a command ends.
+const (
+	WarnConfig    = "config"
+	WarnEnv       = "env"
+	WarnFirewall  = "firewall"
+	WarnSync      = "sync"
+	WarnSecrets   = "secrets"
+	WarnKeys      = "keys"
+	WarnContainer = "container"
+	WarnShare     = "share"
+	WarnNotify    = "notifications"
+)
+
+// ExitWarnings is the exit status of a command that succeeded with
+// warnings when --strict-warnings is set.
+const ExitWarnings = 3
+
+var flagStrictWarnings bool
+
+// warningLog collects the warnings a command printed, by category.
+var warningLog struct {
+	mu     sync.Mutex
+	counts map[string]map[string]int // category to message to count
+	order  map[string][]string       // category to messages in first-seen order
+}
+
+// Warnf shows a warning on the Frontend straight away, as it is relevant to
+// what is happening, and records it for the summary at exit.
+func Warnf(category, format string, args ...any) {
+	msg := Redact(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+	Frontend.Warn(msg)
+
+	warningLog.mu.Lock()
+	defer warningLog.mu.Unlock()
+	if warningLog.counts == nil {
+		warningLog.counts = make(map[string]map[string]int)
+		warningLog.order = make(map[string][]string)
+	}
+	if warningLog.counts[category] == nil {
+		warningLog.counts[category] = make(map[string]int)
+	}
+	if warningLog.counts[category][msg] == 0 {
+		warningLog.order[category] = append(warningLog.order[category], msg)
+	}
+	warningLog.counts[category][msg]++
+}
+
+// warningCount returns how many distinct warnings were recorded.
+func warningCount() int {
+	warningLog.mu.Lock()
+	defer warningLog.mu.Unlock()
+	n := 0
+	for _, msgs := range warningLog.order {
+		n += len(msgs)
+	}
+	return n
+}
+
+// writeWarningSummary writes the recorded warnings grouped by category,
+// or nothing if there were none. Repeated warnings are listed once.
+func writeWarningSummary(w io.Writer) {
+	n := warningCount()
+	if n == 0 {
+		return
+	}
+	warningLog.mu.Lock()
+	defer warningLog.mu.Unlock()
+	categories := make([]string, 0, len(warningLog.order))
+	for c := range warningLog.order {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+
+	plural := "s"
+	if n == 1 {
+		plural = ""
+	}
+	fmt.Fprintf(w, "\nsandbox: %d warning%s\n", n, plural)
+	for _, c := range categories {
+		fmt.Fprintf(w, "  %s:\n", c)
+		for _, msg := range warningLog.order[c] {
+			if times := warningLog.counts[c][msg]; times > 1 {
+				fmt.Fprintf(w, "    %s (x%d)\n", msg, times)
+			} else {
+				fmt.Fprintf(w, "    %s\n", msg)
+			}
+		}
+	}
+}
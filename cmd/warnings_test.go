@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// resetWarnings clears recorded warnings before and after the test.
+func resetWarnings(t *testing.T) {
+	t.Helper()
+	clear := func() {
+		warningLog.mu.Lock()
+		warningLog.counts, warningLog.order = nil, nil
+		warningLog.mu.Unlock()
+	}
+	clear()
+	t.Cleanup(clear)
+}
+
+func TestWarningSummary(t *testing.T) {
+	resetWarnings(t)
+
+	var buf bytes.Buffer
+	writeWarningSummary(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("summary with no warnings = %q, want nothing", buf.String())
+	}
+
+	Warnf(WarnFirewall, "cannot resolve %s: %v", "a.example", "no such host")
+	Warnf(WarnSync, "cannot read %s\n", "/x")
+	Warnf(WarnFirewall, "cannot resolve %s: %v", "a.example", "no such host")
+	Warnf(WarnConfig, "duplicate host_tool %q, skipping", "t")
+
+	if n := warningCount(); n != 3 {
+		t.Errorf("warningCount = %d, want 3 distinct", n)
+	}
+	writeWarningSummary(&buf)
+	want := `
+sandbox: 3 warnings
+  config:
+    duplicate host_tool "t", skipping
+  firewall:
+    cannot resolve a.example: no such host (x2)
+  sync:
+    cannot read /x
+`
+	if got := buf.String(); got != want {
+		t.Errorf("summary =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(buf.String(), "warning: ") {
+		t.Error("summary lines shouldn't repeat the warning: prefix")
+	}
+}
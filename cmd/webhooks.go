@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotificationsConfig reports sandbox events, so unattended agents can be
+// followed from elsewhere.
+type NotificationsConfig struct {
+	Webhooks []Webhook `yaml:"webhooks,omitempty"`
+}
+
+// Webhook is a URL that events are POSTed to as JSON. The payload's text
+// field makes it work as a Slack incoming webhook.
+type Webhook struct {
+	// URL may be read from a host variable or secret manager like an env
+	// value, e.g. $SLACK_WEBHOOK or op://vault/item/url.
+	URL    string   `yaml:"url"`
+	Events []string `yaml:"events,omitempty"` // default every event
+}
+
+// Webhook events.
+const (
+	EventStarted        = "started"         // a sandbox was created or restarted
+	EventSyncFailed     = "sync-failed"     // a sync failed
+	EventFirewallFailed = "firewall-failed" // the firewall couldn't be set up or updated
+	EventSessionEnded   = "session-ended"   // an agent session ended
+)
+
+// webhookEvents lists every event, in the order they are documented.
+var webhookEvents = []string{EventStarted, EventSyncFailed, EventFirewallFailed, EventSessionEnded}
+
+func validateWebhook(w Webhook) error {
+	if w.URL == "" {
+		return fmt.Errorf("notifications webhook has no url")
+	}
+	// Resolved URLs are checked when they are used.
+	if !isSecretRef(w.URL) && !strings.Contains(w.URL, "$") {
+		if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid notifications webhook url, want an http(s) URL")
+		}
+	}
+	for _, e := range w.Events {
+		if !slices.Contains(webhookEvents, e) {
+			return fmt.Errorf("unknown notifications event %q (want one of %s)", e, strings.Join(webhookEvents, ", "))
+		}
+	}
+	return nil
+}
+
+// WebhookEvent is the JSON POSTed to webhooks.
+type WebhookEvent struct {
+	Text      string    `json:"text"` // a summary, for Slack and chat tools like it
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Host      string    `json:"host,omitempty"`
+	Sandbox   string    `json:"sandbox"`
+	Workspace string    `json:"workspace,omitempty"`
+	Command   string    `json:"command,omitempty"` // session-ended: the agent CLI
+	ExitCode  int       `json:"exit_code,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// webhookClient sends webhook events. A variable so tests can stand in for
+// the network.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// notifyWebhooks POSTs e to each of cfg's webhooks subscribed to it, at the
+// same time, returning once all have answered. It is best-effort: a webhook
+// that fails is warned about and the event dropped.
+func notifyWebhooks(cfg *SandboxConfig, e WebhookEvent) {
+	if cfg == nil || len(cfg.Notifications.Webhooks) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	e.Host, _ = os.Hostname()
+	e.Text = Redact(webhookText(e))
+	e.Error = Redact(e.Error)
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, w := range cfg.Notifications.Webhooks {
+		if len(w.Events) > 0 && !slices.Contains(w.Events, e.Event) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := postWebhook(w, payload); err != nil {
+				Warnf(WarnNotify, "notifications webhook for %s: %v", e.Event, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// postWebhook resolves w's URL and POSTs payload to it. The URL is treated
+// as a secret, as chat webhooks carry their credentials in it.
+func postWebhook(w Webhook, payload []byte) error {
+	u, ok := resolveEnvValue(w.URL)
+	if !ok || u == "" {
+		return fmt.Errorf("cannot resolve url %s", w.URL)
+	}
+	addRedaction(u)
+	resp, err := webhookClient.Post(u, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// webhookText summarises e in a sentence.
+func webhookText(e WebhookEvent) string {
+	where := e.Sandbox
+	if e.Host != "" {
+		where += " on " + e.Host
+	}
+	switch e.Event {
+	case EventStarted:
+		return fmt.Sprintf("Sandbox %s started for %s", where, e.Workspace)
+	case EventSyncFailed:
+		return fmt.Sprintf("Sync failed in %s: %s", where, e.Error)
+	case EventFirewallFailed:
+		return fmt.Sprintf("Firewall setup failed in %s: %s", where, e.Error)
+	case EventSessionEnded:
+		switch {
+		case e.Error != "":
+			return fmt.Sprintf("%s session in %s ended: %s", e.Command, where, e.Error)
+		case e.ExitCode != 0:
+			return fmt.Sprintf("%s session in %s ended with exit status %d", e.Command, where, e.ExitCode)
+		}
+		return fmt.Sprintf("%s session in %s ended", e.Command, where)
+	}
+	return fmt.Sprintf("%s in %s", e.Event, where)
+}
+
+// NotifySessionEnded tells cfg's webhooks that an agent session running
+// command in container ended with err.
+func NotifySessionEnded(cfg *SandboxConfig, container, wsPath, command string, err error) {
+	e := WebhookEvent{Event: EventSessionEnded, Sandbox: container, Workspace: wsPath, Command: command}
+	if exitErr, ok := err.(*ExitError); ok {
+		e.ExitCode = exitErr.Code
+	} else if err != nil {
+		e.Error = err.Error()
+	}
+	notifyWebhooks(cfg, e)
+}
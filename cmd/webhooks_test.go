@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidateWebhook(t *testing.T) {
+	for _, w := range []Webhook{
+		{URL: "https://hooks.slack.com/services/T0/B0/x"},
+		{URL: "$SLACK_WEBHOOK", Events: []string{EventSyncFailed, EventSessionEnded}},
+		{URL: "op://vault/slack/url"},
+	} {
+		if err := validateWebhook(w); err != nil {
+			t.Errorf("validateWebhook(%+v): %v", w, err)
+		}
+	}
+	for _, w := range []Webhook{{}, {URL: "hooks.slack.com/x"}, {URL: "ftp://example.com"}, {URL: "https://x.example", Events: []string{"stopped"}}} {
+		if err := validateWebhook(w); err == nil {
+			t.Errorf("validateWebhook(%+v) succeeded, want error", w)
+		}
+	}
+}
+
+func TestNotifyWebhooks(t *testing.T) {
+	isolateRedactions(t)
+	resetWarnings(t)
+	r := useRecordingUI(t)
+	var mu sync.Mutex
+	got := map[string][]WebhookEvent{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/broken" {
+			http.Error(w, "no", http.StatusInternalServerError)
+			return
+		}
+		var e WebhookEvent
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &e)
+		mu.Lock()
+		got[req.URL.Path] = append(got[req.URL.Path], e)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	t.Setenv("TEST_WEBHOOK", srv.URL+"/all")
+
+	cfg := &SandboxConfig{Notifications: NotificationsConfig{Webhooks: []Webhook{
+		{URL: "$TEST_WEBHOOK"},
+		{URL: srv.URL + "/failures", Events: []string{EventSyncFailed}},
+		{URL: srv.URL + "/broken", Events: []string{EventSessionEnded}},
+	}}}
+	notifyWebhooks(cfg, WebhookEvent{Event: EventStarted, Sandbox: "sandbox-ws", Workspace: "/ws"})
+	notifyWebhooks(cfg, WebhookEvent{Event: EventSyncFailed, Sandbox: "sandbox-ws", Workspace: "/ws", Error: "copy failed"})
+	NotifySessionEnded(cfg, "sandbox-ws", "/ws", "claude", &ExitError{Code: 2})
+
+	if len(got["/all"]) != 3 || len(got["/failures"]) != 1 {
+		t.Fatalf("received %v", got)
+	}
+	e := got["/all"][2]
+	if e.Event != EventSessionEnded || e.Command != "claude" || e.ExitCode != 2 || e.Time.IsZero() {
+		t.Errorf("session-ended payload = %+v", e)
+	}
+	if !strings.Contains(e.Text, "claude session in sandbox-ws") || !strings.Contains(e.Text, "exit status 2") {
+		t.Errorf("text = %q", e.Text)
+	}
+	if f := got["/failures"][0]; f.Event != EventSyncFailed || !strings.Contains(f.Text, "copy failed") {
+		t.Errorf("sync-failed payload = %+v", f)
+	}
+	if len(r.warnings) != 1 || !strings.Contains(r.warnings[0], "500") {
+		t.Errorf("warnings = %q, want the broken webhook reported", r.warnings)
+	}
+
+	// A URL from a variable is a secret, kept out of errors.
+	Warnf(WarnNotify, "%v", errors.New("Post "+srv.URL+"/all: refused"))
+	if strings.Contains(r.warnings[1], srv.URL) {
+		t.Errorf("webhook URL not redacted: %q", r.warnings[1])
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Mount is a bind mount or volume in a container, as reported by docker
+// inspect.
+type Mount struct {
+	Type        string `json:"Type"` // "bind" or "volume"
+	Name        string `json:"Name"` // volume name; empty for bind mounts
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+}
+
+// ContainerMounts returns the mounts of container.
+func ContainerMounts(container string) ([]Mount, error) {
+	out, err := exec.Command("docker", "inspect", "-f", "{{json .Mounts}}", container).Output()
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", container, err)
+	}
+	var mounts []Mount
+	if err := json.Unmarshal(out, &mounts); err != nil {
+		return nil, fmt.Errorf("parse mounts of %s: %w", container, err)
+	}
+	return mounts, nil
+}
+
+// HostToContainer maps a host path to where the container sees it, through
+// the bind mount with the deepest source containing it. ok is false when no
+// bind mount covers the path.
+func HostToContainer(mounts []Mount, hostPath string) (string, bool) {
+	return mapPath(mounts, hostPath, func(m Mount) (string, string) { return m.Source, m.Destination })
+}
+
+// ContainerToHost maps a path in the container back to the host, through
+// the bind mount with the deepest destination containing it. ok is false
+// when the path is in a volume or not mounted from the host at all.
+func ContainerToHost(mounts []Mount, containerPath string) (string, bool) {
+	return mapPath(mounts, containerPath, func(m Mount) (string, string) { return m.Destination, m.Source })
+}
+
+// VolumeFor returns the volume mounted at or above containerPath, if any.
+func VolumeFor(mounts []Mount, containerPath string) (Mount, bool) {
+	var best Mount
+	found := false
+	for _, m := range mounts {
+		if m.Type != "volume" {
+			continue
+		}
+		if _, ok := pathWithin(containerPath, m.Destination); ok && (!found || len(m.Destination) > len(best.Destination)) {
+			best, found = m, true
+		}
+	}
+	return best, found
+}
+
+func mapPath(mounts []Mount, p string, ends func(Mount) (from, to string)) (string, bool) {
+	p = filepath.Clean(p)
+	bestFrom, bestTo := "", ""
+	found := false
+	for _, m := range mounts {
+		if m.Type != "bind" {
+			continue
+		}
+		from, to := ends(m)
+		if _, ok := pathWithin(p, from); ok && (!found || len(from) > len(bestFrom)) {
+			bestFrom, bestTo, found = from, to, true
+		}
+	}
+	if !found {
+		return "", false
+	}
+	rel, _ := pathWithin(p, bestFrom)
+	return filepath.Join(bestTo, rel), true
+}
+
+// pathWithin reports whether p is dir or below it, returning p relative to
+// dir.
+func pathWithin(p, dir string) (string, bool) {
+	dir = filepath.Clean(dir)
+	if p == dir {
+		return ".", true
+	}
+	prefix := dir
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if !strings.HasPrefix(p, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(p, prefix), true
+}
+
+// ExistsInContainer reports whether path exists in the running container.
+func ExistsInContainer(container, path string) bool {
+	return exec.Command("docker", "exec", "-u", "root", container, "test", "-e", path).Run() == nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import "testing"
+
+func TestMapPaths(t *testing.T) {
+	mounts := []Mount{
+		{Type: "bind", Source: "/Users/me/proj", Destination: "/Users/me/proj"},
+		{Type: "bind", Source: "/Users/me/cache", Destination: "/Users/me/proj/.cache"},
+		{Type: "volume", Name: "sandbox-creds", Source: "/var/lib/docker/volumes/sandbox-creds/_data", Destination: "/home/agent/.claude"},
+	}
+
+	toContainer := []struct{ in, want string }{
+		{"/Users/me/proj", "/Users/me/proj"},
+		{"/Users/me/proj/src/main.go", "/Users/me/proj/src/main.go"},
+		{"/Users/me/cache/x", "/Users/me/proj/.cache/x"},
+		{"/Users/me/projects/other", ""}, // prefix of a mount, not inside it
+		{"/var/lib/docker/volumes/sandbox-creds/_data/x", ""},
+	}
+	for _, tt := range toContainer {
+		got, ok := HostToContainer(mounts, tt.in)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("HostToContainer(%q) = %q, %v; want %q", tt.in, got, ok, tt.want)
+		}
+	}
+
+	toHost := []struct{ in, want string }{
+		{"/Users/me/proj/go.mod", "/Users/me/proj/go.mod"},
+		{"/Users/me/proj/.cache/x", "/Users/me/cache/x"}, // deepest mount wins
+		{"/Users/me/proj/.cache", "/Users/me/cache"},
+		{"/home/agent/.claude/settings.json", ""},
+		{"/etc/passwd", ""},
+	}
+	for _, tt := range toHost {
+		got, ok := ContainerToHost(mounts, tt.in)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("ContainerToHost(%q) = %q, %v; want %q", tt.in, got, ok, tt.want)
+		}
+	}
+
+	if v, ok := VolumeFor(mounts, "/home/agent/.claude/codex"); !ok || v.Name != "sandbox-creds" {
+		t.Errorf("VolumeFor = %+v, %v; want sandbox-creds", v, ok)
+	}
+	if _, ok := VolumeFor(mounts, "/home/agent/.claudex"); ok {
+		t.Error("VolumeFor matched a sibling of the mount point")
+	}
+}
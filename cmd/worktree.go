@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// LabelWorktrees records the linked git worktrees a container was created
+// with mounts for, joined with ":", so worktrees added or removed since can
+// be pointed out.
+const LabelWorktrees = "sandbox.worktrees"
+
+// worktreeMain returns the main working tree of the linked git worktree
+// whose top is dir, read from the gitdir pointer in dir/.git, or "" if dir
+// isn't the top of a linked worktree. Submodules, whose .git files point
+// into .git/modules, aren't worktrees.
+func worktreeMain(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, ".git"))
+	if err != nil {
+		return ""
+	}
+	gitdir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return ""
+	}
+	if !filepath.IsAbs(gitdir) {
+		gitdir = filepath.Join(dir, gitdir)
+	}
+	// gitdir is <main>/.git/worktrees/<name>.
+	worktrees := filepath.Dir(filepath.Clean(gitdir))
+	if filepath.Base(worktrees) != "worktrees" || filepath.Base(filepath.Dir(worktrees)) != ".git" {
+		return ""
+	}
+	return filepath.Dir(filepath.Dir(worktrees))
+}
+
+// linkedWorktrees returns the linked worktrees of the git repository at
+// root that lie outside it, sorted. Worktrees inside root are already
+// visible through its mount, and ones whose directories are gone are
+// skipped.
+func linkedWorktrees(root string) []string {
+	admin := filepath.Join(root, ".git", "worktrees")
+	entries, err := os.ReadDir(admin)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(admin, e.Name(), "gitdir"))
+		if err != nil {
+			continue
+		}
+		// gitdir holds the path of the worktree's .git file.
+		dir := filepath.Dir(strings.TrimSpace(string(data)))
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		if _, inside := pathWithin(dir, root); inside {
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if strings.Contains(dir, ":") {
+			Warnf(WarnContainer, "can't mount git worktree %s: Docker doesn't allow ':' in mount paths", dir)
+			continue
+		}
+		paths = append(paths, dir)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// mountedWorktrees returns the worktrees container was created with mounts
+// for.
+func mountedWorktrees(container string) ([]string, error) {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelWorktrees+`"}}`, container).Output()
+	if err != nil {
+		return nil, err
+	}
+	if s := strings.TrimSpace(string(out)); s != "" {
+		return strings.Split(s, ":"), nil
+	}
+	return nil, nil
+}
+
+// warnIfWorktreesChanged warns about linked worktrees in want that the
+// container doesn't mount. Mounts can only be added when a container is
+// created.
+func warnIfWorktreesChanged(container string, want []string) {
+	have, err := mountedWorktrees(container)
+	if err != nil {
+		return
+	}
+	var missing []string
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			missing = append(missing, w)
+		}
+	}
+	if len(missing) > 0 {
+		Warnf(WarnContainer, "git worktree(s) %s aren't mounted in this sandbox. To mount them, run `sandbox rm <folder>` and then restart.",
+			strings.Join(missing, ", "))
+	}
+}
+
+// checkWorktreeMounts fails if a worktree the container mounts no longer
+// exists, which would stop it from restarting or leave Docker to create an
+// empty directory in its place.
+func checkWorktreeMounts(container string) error {
+	have, err := mountedWorktrees(container)
+	if err != nil {
+		return nil
+	}
+	for _, w := range have {
+		if _, err := os.Stat(w); err != nil {
+			return fmt.Errorf("git worktree %s is mounted in this sandbox but no longer exists; run `sandbox rm <folder>` and then restart", w)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// gitWorktrees creates a repository at base/repo with a .sandbox directory,
+// a linked worktree beside it and one inside it, returning their paths.
+func gitWorktrees(t *testing.T) (repo, sibling, nested string) {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	repo = filepath.Join(base, "repo")
+	sibling = filepath.Join(base, "repo-feature")
+	nested = filepath.Join(repo, "wt", "fix")
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	git("init", "-q", repo)
+	git("-C", repo, "-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "init")
+	git("-C", repo, "worktree", "add", "-q", "-b", "feature", sibling)
+	git("-C", repo, "worktree", "add", "-q", "-b", "fix", nested)
+	os.Mkdir(filepath.Join(repo, ".sandbox"), 0755)
+	return repo, sibling, nested
+}
+
+func TestLinkedWorktrees(t *testing.T) {
+	repo, sibling, _ := gitWorktrees(t)
+
+	if got, want := linkedWorktrees(repo), []string{sibling}; !reflect.DeepEqual(got, want) {
+		t.Errorf("linkedWorktrees = %q, want %q", got, want)
+	}
+	if got := worktreeMain(sibling); got != repo {
+		t.Errorf("worktreeMain(%s) = %q, want %q", sibling, got, repo)
+	}
+	if got := worktreeMain(repo); got != "" {
+		t.Errorf("worktreeMain of the main tree = %q, want none", got)
+	}
+
+	os.RemoveAll(sibling)
+	if got := linkedWorktrees(repo); len(got) != 0 {
+		t.Errorf("removed worktree still listed: %q", got)
+	}
+}
+
+func TestFindSandboxRootFollowsWorktrees(t *testing.T) {
+	repo, sibling, nested := gitWorktrees(t)
+	sub := filepath.Join(sibling, "pkg")
+	os.Mkdir(sub, 0755)
+
+	for _, dir := range []string{repo, sibling, sub, nested} {
+		if got := FindSandboxRoot(dir); got != repo {
+			t.Errorf("FindSandboxRoot(%s) = %q, want %q", dir, got, repo)
+		}
+	}
+
+	// A worktree with its own .sandbox is its own root.
+	os.Mkdir(filepath.Join(sibling, ".sandbox"), 0755)
+	if got := FindSandboxRoot(sub); got != sibling {
+		t.Errorf("FindSandboxRoot(%s) = %q, want %q", sub, got, sibling)
+	}
+}
+
+func TestWorktreeMainIgnoresSubmodules(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".git"), []byte("gitdir: ../.git/modules/lib\n"), 0644)
+	if got := worktreeMain(dir); got != "" {
+		t.Errorf("worktreeMain of a submodule = %q, want none", got)
+	}
+}
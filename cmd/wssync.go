@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// LabelWorkspaceSync records the engine syncing a container's workspace,
+// or "" when it is bind-mounted. Which it is can only be chosen when the
+// container is created.
+const LabelWorkspaceSync = "sandbox.workspace-sync"
+
+// Workspace sync engines.
+const (
+	SyncEngineMutagen = "mutagen"
+	SyncEngineBuiltin = "builtin"
+)
+
+// What wins when a file has changed on both sides since it was last
+// synced.
+const (
+	SyncConflictsManual    = "manual"    // neither: the conflict is left to resolve by hand
+	SyncConflictsHost      = "host"      // the host's copy
+	SyncConflictsContainer = "container" // the container's copy
+)
+
+// WorkspaceSyncConfig keeps a copy of the workspace in the container in
+// step with the host's, in place of the bind mount, for where bind mounts
+// are slow (Docker Desktop on macOS) or impossible (a remote daemon).
+type WorkspaceSyncConfig struct {
+	Engine    string   `yaml:"engine,omitempty"`    // "mutagen" or "builtin"; empty bind-mounts the workspace
+	Ignore    []string `yaml:"ignore,omitempty"`    // gitignore-style patterns synced neither way, e.g. "node_modules/"
+	Conflicts string   `yaml:"conflicts,omitempty"` // "manual" (the default), "host" or "container"
+}
+
+func validateWorkspaceSync(s WorkspaceSyncConfig) error {
+	if s.Engine != "" && s.Engine != SyncEngineMutagen && s.Engine != SyncEngineBuiltin {
+		return fmt.Errorf("invalid workspace.sync.engine %q (want mutagen or builtin)", s.Engine)
+	}
+	switch s.Conflicts {
+	case "", SyncConflictsManual, SyncConflictsHost, SyncConflictsContainer:
+	default:
+		return fmt.Errorf("invalid workspace.sync.conflicts %q (want manual, host or container)", s.Conflicts)
+	}
+	for _, p := range s.Ignore {
+		clean := strings.Trim(p, "/")
+		if clean == "" || strings.HasPrefix(p, "!") || slices.Contains(strings.Split(clean, "/"), "..") {
+			return fmt.Errorf("invalid workspace.sync.ignore pattern %q", p)
+		}
+		if _, err := path.Match(clean, ""); err != nil {
+			return fmt.Errorf("invalid workspace.sync.ignore pattern %q: %v", p, err)
+		}
+	}
+	return nil
+}
+
+// workspaceSyncEngine returns the engine syncing cfg's workspace, or ""
+// when it is bind-mounted.
+func workspaceSyncEngine(cfg *SandboxConfig) string {
+	if cfg == nil {
+		return ""
+	}
+	return cfg.Workspace.Sync.Engine
+}
+
+// conflicts returns the conflict policy, defaulting to manual.
+func (s WorkspaceSyncConfig) conflicts() string {
+	if s.Conflicts == "" {
+		return SyncConflictsManual
+	}
+	return s.Conflicts
+}
+
+// workspaceSyncIgnores returns the patterns cfg's workspace sync leaves
+// out: workspace.sync.ignore, workspace.mask anchored to the root, as
+// masked paths must never reach the container, and the workspace's
+// cache_volumes, which stay in the container.
+func workspaceSyncIgnores(cfg *SandboxConfig) []string {
+	ignores := slices.Clone(cfg.Workspace.Sync.Ignore)
+	for _, m := range cfg.Workspace.Mask {
+		ignores = append(ignores, "/"+strings.TrimPrefix(m, "./"))
+	}
+	for _, c := range cfg.CacheVolumes {
+		if !strings.HasPrefix(c, "~/") {
+			ignores = append(ignores, "/"+path.Clean(c))
+		}
+	}
+	return ignores
+}
+
+// syncIgnored reports whether rel, a slash-separated path relative to the
+// workspace root, matches one of patterns. As in .gitignore, a pattern with
+// a "/" other than a trailing one is matched against the path from the
+// root, any other against each name along it, and a trailing "/" only
+// matches directories. isDir says whether rel itself is a directory.
+func syncIgnored(patterns []string, rel string, isDir bool) bool {
+	names := strings.Split(rel, "/")
+	for _, p := range patterns {
+		dirOnly := strings.HasSuffix(p, "/")
+		p = strings.TrimSuffix(p, "/")
+		anchored := strings.Contains(strings.TrimPrefix(p, "/"), "/") || strings.HasPrefix(p, "/")
+		p = strings.TrimPrefix(p, "/")
+		for i, name := range names {
+			if dirOnly && i == len(names)-1 && !isDir {
+				continue
+			}
+			subject := name
+			if anchored {
+				subject = strings.Join(names[:i+1], "/")
+			}
+			if ok, _ := path.Match(p, subject); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// workspaceSyncHash identifies the settings cfg's workspace sync runs
+// with, so a session started with others is replaced.
+func workspaceSyncHash(cfg *SandboxConfig) string {
+	return sha256Hex([]byte(cfg.Workspace.Sync.conflicts() + "\n" + strings.Join(workspaceSyncIgnores(cfg), "\n")))[:12]
+}
+
+// containerSyncEngine returns the engine the named container's workspace
+// was created to be synced with, from its label.
+func containerSyncEngine(container string) string {
+	out, err := exec.Command("docker", "inspect", "-f", `{{index .Config.Labels "`+LabelWorkspaceSync+`"}}`, container).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// warnIfWorkspaceSyncChanged prints a warning if the container was created
+// with another workspace.sync.engine than the config now asks for. Whether
+// the workspace is bind-mounted can only be chosen when a container is
+// created.
+func warnIfWorkspaceSyncChanged(container, want string) {
+	if containerSyncEngine(container) != want {
+		Warnf(WarnContainer, "workspace.sync.engine has changed since this sandbox was created. To apply it, run `sandbox rm <folder>` and then restart.")
+	}
+}
+
+// workspaceSyncArgs returns the docker run arguments giving the container
+// its workspace: a bind mount of the host's, or with a sync engine an
+// anonymous volume at the same path for the engine to fill.
+func workspaceSyncArgs(cfg *SandboxConfig, wsPath string) []string {
+	if workspaceSyncEngine(cfg) != "" {
+		return []string{"--label", LabelWorkspaceSync + "=" + workspaceSyncEngine(cfg), "-v", wsPath}
+	}
+	return []string{"--label", LabelWorkspaceSync + "=", "-v", wsPath + ":" + wsPath}
+}
+
+// ensureWorkspaceSync starts syncing the workspace into the container
+// named, unless it already is, and waits until the container has it. A
+// container created with another engine, or bind-mounting the workspace,
+// is left as it is. A new workspace volume belongs to root, so it is
+// handed to the agent user first.
+func ensureWorkspaceSync(cfg *SandboxConfig, name, wsPath string) error {
+	engine := workspaceSyncEngine(cfg)
+	if engine == "" || containerSyncEngine(name) != engine {
+		return nil
+	}
+	if out, err := exec.Command("docker", "exec", "-u", "root", name,
+		"chown", "agent:agent", wsPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("prepare workspace: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	if engine == SyncEngineMutagen {
+		return ensureMutagenSync(cfg, name, wsPath)
+	}
+	return ensureBuiltinSync(cfg, name, wsPath)
+}
+
+// stopWorkspaceSync brings the host up to date with the container's
+// workspace and stops syncing it, before the container stops.
+func stopWorkspaceSync(name string) {
+	switch containerSyncEngine(name) {
+	case SyncEngineMutagen:
+		session := mutagenSessionName(name)
+		mutagen("sync", "flush", session)
+		mutagen("sync", "pause", session)
+	case SyncEngineBuiltin:
+		stopBuiltinSync(name)
+	}
+}
+
+// endWorkspaceSync forgets the sync of a removed container's workspace,
+// which was synced with engine.
+func endWorkspaceSync(name, engine string) {
+	switch engine {
+	case SyncEngineMutagen:
+		mutagen("sync", "terminate", mutagenSessionName(name))
+	case SyncEngineBuiltin:
+		removeBuiltinSyncState(name)
+	}
+}
+
+// mutagen runs the mutagen CLI, returning what it printed. A variable so
+// tests can stand in for it.
+var mutagen = func(args ...string) (string, error) {
+	out, err := exec.Command("mutagen", args...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// mutagenNameRe matches what mutagen session names can't contain.
+var mutagenNameRe = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// mutagenSessionName returns the name of the mutagen session syncing the
+// named container's workspace.
+func mutagenSessionName(container string) string {
+	return mutagenNameRe.ReplaceAllString(container, "-")
+}
+
+// mutagenCreateArgs returns the arguments creating the mutagen session
+// syncing wsPath with the container named. The side that wins conflicts
+// is mutagen's alpha, in two-way-resolved mode; with manual conflicts the
+// host is alpha and two-way-safe mode leaves conflicts alone.
+func mutagenCreateArgs(cfg *SandboxConfig, container, wsPath string) []string {
+	host, ctr := wsPath, "docker://agent@"+container+wsPath
+	args := []string{"sync", "create", "--name", mutagenSessionName(container),
+		"--label", "sandbox-config=" + workspaceSyncHash(cfg)}
+	switch cfg.Workspace.Sync.conflicts() {
+	case SyncConflictsHost:
+		args = append(args, "--sync-mode", "two-way-resolved")
+	case SyncConflictsContainer:
+		args = append(args, "--sync-mode", "two-way-resolved")
+		host, ctr = ctr, host
+	default:
+		args = append(args, "--sync-mode", "two-way-safe")
+	}
+	for _, p := range workspaceSyncIgnores(cfg) {
+		args = append(args, "--ignore", p)
+	}
+	return append(args, host, ctr)
+}
+
+// ensureMutagenSync creates or resumes the container's mutagen session,
+// replacing one created with other settings, and waits for a sync.
+func ensureMutagenSync(cfg *SandboxConfig, name, wsPath string) error {
+	if _, err := lookPath("mutagen"); err != nil {
+		return fmt.Errorf("workspace.sync.engine is mutagen, but it isn't installed on the host: %w", err)
+	}
+	session := mutagenSessionName(name)
+	have, err := mutagen("sync", "list", "--template", `{{range .}}{{index .Labels "sandbox-config"}}{{end}}`, session)
+	exists := err == nil
+	if exists && have != workspaceSyncHash(cfg) {
+		mutagen("sync", "flush", session)
+		if out, err := mutagen("sync", "terminate", session); err != nil {
+			return fmt.Errorf("replace mutagen session: %v %s", err, out)
+		}
+		exists = false
+	}
+	syncStatus("syncing workspace...")
+	defer syncStatusDone()
+	if exists {
+		if out, err := mutagen("sync", "resume", session); err != nil {
+			return fmt.Errorf("resume mutagen session: %v %s", err, out)
+		}
+	} else if out, err := mutagen(mutagenCreateArgs(cfg, name, wsPath)...); err != nil {
+		return fmt.Errorf("create mutagen session: %v %s", err, out)
+	}
+	if out, err := mutagen("sync", "flush", session); err != nil {
+		return fmt.Errorf("sync workspace: %v %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestValidateWorkspaceSync(t *testing.T) {
+	good := WorkspaceSyncConfig{Engine: SyncEngineMutagen, Conflicts: SyncConflictsHost, Ignore: []string{"node_modules/", "/build", "*.log"}}
+	if err := validateWorkspaceSync(good); err != nil {
+		t.Errorf("validateWorkspaceSync = %v", err)
+	}
+	for _, bad := range []WorkspaceSyncConfig{
+		{Engine: "rsync"},
+		{Engine: SyncEngineBuiltin, Conflicts: "newest"},
+		{Engine: SyncEngineBuiltin, Ignore: []string{"/"}},
+		{Engine: SyncEngineBuiltin, Ignore: []string{"!keep.log"}},
+		{Engine: SyncEngineBuiltin, Ignore: []string{"../up"}},
+		{Engine: SyncEngineBuiltin, Ignore: []string{"[a"}},
+	} {
+		if validateWorkspaceSync(bad) == nil {
+			t.Errorf("validateWorkspaceSync(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestSyncIgnored(t *testing.T) {
+	patterns := []string{"node_modules/", "*.log", "/build", "docs/*.pdf"}
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules", true, true},
+		{"web/node_modules/react/index.js", false, true},
+		{"node_modules", false, false}, // a file, not the directory
+		{"debug.log", false, true},
+		{"logs/today.log", false, true},
+		{"build", true, true},
+		{"build/out.bin", false, true},
+		{"src/build/out.bin", false, false}, // anchored to the root
+		{"docs/guide.pdf", false, true},
+		{"docs/old/guide.pdf", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := syncIgnored(patterns, tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("syncIgnored(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestWorkspaceSyncIgnoresMasks(t *testing.T) {
+	cfg := &SandboxConfig{Workspace: WorkspaceConfig{
+		Mask: []string{".env", "secrets/"},
+		Sync: WorkspaceSyncConfig{Engine: SyncEngineBuiltin, Ignore: []string{"node_modules/"}},
+	}}
+	want := []string{"node_modules/", "/.env", "/secrets/"}
+	if got := workspaceSyncIgnores(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("workspaceSyncIgnores = %v, want %v", got, want)
+	}
+	if !syncIgnored(want, "secrets/key.pem", false) || syncIgnored(want, "app/.env.example", false) {
+		t.Error("masked paths should be ignored at the root only")
+	}
+}
+
+func TestMutagenCreateArgs(t *testing.T) {
+	cfg := &SandboxConfig{Workspace: WorkspaceConfig{Sync: WorkspaceSyncConfig{Engine: SyncEngineMutagen, Ignore: []string{"node_modules/"}}}}
+	args := mutagenCreateArgs(cfg, "sandbox-my.app", "/src/my.app")
+	if !slices.Contains(args, "two-way-safe") || !slices.Contains(args, "node_modules/") {
+		t.Errorf("args = %v, want two-way-safe with the ignore", args)
+	}
+	if got := args[len(args)-2:]; !reflect.DeepEqual(got, []string{"/src/my.app", "docker://agent@sandbox-my.app/src/my.app"}) {
+		t.Errorf("endpoints = %v, want the host first", got)
+	}
+	if i := slices.Index(args, "--name"); args[i+1] != "sandbox-my-app" {
+		t.Errorf("name = %s, want one mutagen accepts", args[i+1])
+	}
+
+	cfg.Workspace.Sync.Conflicts = SyncConflictsContainer
+	args = mutagenCreateArgs(cfg, "sandbox-app", "/src/app")
+	if !slices.Contains(args, "two-way-resolved") || args[len(args)-2] != "docker://agent@sandbox-app/src/app" {
+		t.Errorf("args = %v, want the container first, winning conflicts", args)
+	}
+}
+
+func TestWorkspaceSyncArgs(t *testing.T) {
+	if got := workspaceSyncArgs(&SandboxConfig{}, "/src/app"); !slices.Contains(got, "/src/app:/src/app") {
+		t.Errorf("args = %v, want a bind mount", got)
+	}
+	cfg := &SandboxConfig{Workspace: WorkspaceConfig{Sync: WorkspaceSyncConfig{Engine: SyncEngineBuiltin}}}
+	want := []string{"--label", LabelWorkspaceSync + "=builtin", "-v", "/src/app"}
+	if got := workspaceSyncArgs(cfg, "/src/app"); !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %v, want %v", got, want)
+	}
+}
+
+func TestMergeWorkspaceSync(t *testing.T) {
+	base := &SandboxConfig{Workspace: WorkspaceConfig{Sync: WorkspaceSyncConfig{Engine: SyncEngineMutagen, Conflicts: SyncConflictsHost, Ignore: []string{"*.log"}}}}
+	override := &SandboxConfig{Workspace: WorkspaceConfig{Sync: WorkspaceSyncConfig{Engine: SyncEngineBuiltin, Ignore: []string{"target/"}}}}
+	want := WorkspaceSyncConfig{Engine: SyncEngineBuiltin, Conflicts: SyncConflictsHost, Ignore: []string{"*.log", "target/"}}
+	if got := mergeConfig(base, override).Workspace.Sync; !reflect.DeepEqual(got, want) {
+		t.Errorf("workspace.sync = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,780 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// builtinSyncInterval is how often the built-in engine looks for changes
+// on either side.
+const builtinSyncInterval = 2 * time.Second
+
+// builtinSyncStopTimeout bounds how long stopping the built-in engine
+// waits for its last sync.
+const builtinSyncStopTimeout = 30 * time.Second
+
+// fileStamp is how the built-in engine tells a file has changed: by its
+// size and modification time, as each side reports them. Stamps are only
+// compared with earlier ones from the same side.
+type fileStamp struct {
+	Size int64  `json:"size"`
+	Mod  string `json:"mod"`
+}
+
+// syncBaseline is what the built-in engine knows of the last sync: each
+// side's stamps then, and the files left in conflict. It belongs to one
+// container; a new container with the same name starts afresh.
+type syncBaseline struct {
+	ContainerID string               `json:"container_id"`
+	Host        map[string]fileStamp `json:"host"`
+	Container   map[string]fileStamp `json:"container"`
+	Conflicts   []string             `json:"conflicts,omitempty"`
+}
+
+// syncPlan is what one round of the built-in engine does, by slash-separated
+// path relative to the workspace root.
+type syncPlan struct {
+	ToContainer       []string
+	ToHost            []string
+	DeleteInContainer []string
+	DeleteOnHost      []string
+	Settled           []string // alike on both sides, or gone from both
+	Conflicts         []string // changed on both sides
+}
+
+// planWorkspaceSync compares both sides' files with base, the last sync, and
+// plans to copy or delete what changed on one side only. Files changed on
+// both sides are conflicts, for resolveConflicts.
+func planWorkspaceSync(base syncBaseline, host, ctr map[string]fileStamp) syncPlan {
+	paths := make(map[string]bool)
+	for _, m := range []map[string]fileStamp{host, ctr, base.Host, base.Container} {
+		for p := range m {
+			paths[p] = true
+		}
+	}
+	var plan syncPlan
+	for _, p := range slices.Sorted(maps.Keys(paths)) {
+		h, inHost := host[p]
+		c, inCtr := ctr[p]
+		bh, wasInHost := base.Host[p]
+		bc, wasInCtr := base.Container[p]
+		hostChanged := inHost != wasInHost || h != bh
+		ctrChanged := inCtr != wasInCtr || c != bc
+		switch {
+		case !hostChanged && !ctrChanged:
+		case !inHost && !inCtr:
+			plan.Settled = append(plan.Settled, p)
+		case !ctrChanged && inHost:
+			plan.ToContainer = append(plan.ToContainer, p)
+		case !ctrChanged:
+			plan.DeleteInContainer = append(plan.DeleteInContainer, p)
+		case !hostChanged && inCtr:
+			plan.ToHost = append(plan.ToHost, p)
+		case !hostChanged:
+			plan.DeleteOnHost = append(plan.DeleteOnHost, p)
+		default:
+			plan.Conflicts = append(plan.Conflicts, p)
+		}
+	}
+	return plan
+}
+
+// resolveConflicts settles plan's conflicts by policy: the host's or the
+// container's side wins, deletions included. Under manual, a deletion never
+// wins over a change, so deleting one side's copy resolves a conflict in
+// favour of the other; a file on both sides is left alone.
+func (plan *syncPlan) resolveConflicts(policy string, host, ctr map[string]fileStamp) {
+	var left []string
+	for _, p := range plan.Conflicts {
+		_, inHost := host[p]
+		_, inCtr := ctr[p]
+		hostWins := policy == SyncConflictsHost || policy == SyncConflictsManual && !inCtr
+		ctrWins := policy == SyncConflictsContainer || policy == SyncConflictsManual && !inHost
+		switch {
+		case hostWins && inHost:
+			plan.ToContainer = append(plan.ToContainer, p)
+		case hostWins:
+			plan.DeleteInContainer = append(plan.DeleteInContainer, p)
+		case ctrWins && inCtr:
+			plan.ToHost = append(plan.ToHost, p)
+		case ctrWins:
+			plan.DeleteOnHost = append(plan.DeleteOnHost, p)
+		default:
+			left = append(left, p)
+		}
+	}
+	plan.Conflicts = left
+}
+
+// builtinSync is the built-in engine for one container's workspace.
+type builtinSync struct {
+	container, wsPath string
+	ignores           []string
+	policy            string
+	base              syncBaseline
+	log               *log.Logger
+}
+
+func newBuiltinSync(cfg *SandboxConfig, container, wsPath string, logger *log.Logger) *builtinSync {
+	s := &builtinSync{
+		container: container,
+		wsPath:    wsPath,
+		ignores:   workspaceSyncIgnores(cfg),
+		policy:    cfg.Workspace.Sync.conflicts(),
+		log:       logger,
+	}
+	s.base = loadSyncBaseline(container)
+	if id := containerID(container); s.base.ContainerID != id {
+		// Another container's baseline would have its files all deleted.
+		s.base = syncBaseline{ContainerID: id}
+	}
+	return s
+}
+
+// containerID returns the ID of the container named, or "".
+func containerID(container string) string {
+	out, err := exec.Command("docker", "inspect", "-f", "{{.Id}}", container).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// builtinSyncPath returns where the built-in engine keeps the named
+// container's file with extension ext: its baseline, pid or log.
+func builtinSyncPath(container, ext string) (string, error) {
+	l, err := ActiveLayout()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Cache, "workspace-sync", container+ext), nil
+}
+
+func loadSyncBaseline(container string) syncBaseline {
+	var base syncBaseline
+	if path, err := builtinSyncPath(container, ".json"); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &base)
+		}
+	}
+	return base
+}
+
+func (b syncBaseline) save(container string) error {
+	path, err := builtinSyncPath(container, ".json")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// removeBuiltinSyncState forgets a removed container's workspace sync.
+func removeBuiltinSyncState(container string) {
+	for _, ext := range []string{".json", ".log"} {
+		if path, err := builtinSyncPath(container, ext); err == nil {
+			os.Remove(path)
+		}
+	}
+}
+
+// sync runs one round: it compares both sides with the last sync, copies
+// and deletes what changed, and records the result.
+func (s *builtinSync) sync() error {
+	host, err := scanHostWorkspace(s.wsPath, s.ignores)
+	if err != nil {
+		return fmt.Errorf("scan workspace: %w", err)
+	}
+	ctr, err := scanContainerWorkspace(s.container, s.wsPath, s.ignores)
+	if err != nil {
+		return fmt.Errorf("scan sandbox workspace: %w", err)
+	}
+	plan := planWorkspaceSync(s.base, host, ctr)
+	if len(plan.Conflicts) > 0 {
+		// Files both sides have with the same content aren't conflicts,
+		// as when a baseline is lost.
+		same := s.identical(plan.Conflicts, host, ctr)
+		plan.Conflicts = slices.DeleteFunc(plan.Conflicts, func(p string) bool { return same[p] })
+		for p := range same {
+			plan.Settled = append(plan.Settled, p)
+		}
+		plan.resolveConflicts(s.policy, host, ctr)
+	}
+
+	base := s.base
+	if base.Host == nil {
+		base.Host = make(map[string]fileStamp)
+	}
+	if base.Container == nil {
+		base.Container = make(map[string]fileStamp)
+	}
+	record := func(p string, h, c map[string]fileStamp) {
+		if st, ok := h[p]; ok {
+			base.Host[p] = st
+		} else {
+			delete(base.Host, p)
+		}
+		if st, ok := c[p]; ok {
+			base.Container[p] = st
+		} else {
+			delete(base.Container, p)
+		}
+	}
+	for _, p := range plan.Settled {
+		record(p, host, ctr)
+	}
+
+	var errs []error
+	if len(plan.ToContainer) > 0 {
+		if err := s.copyToContainer(plan.ToContainer); err != nil {
+			errs = append(errs, err)
+		} else if after, err := scanContainerWorkspace(s.container, s.wsPath, s.ignores); err != nil {
+			errs = append(errs, err)
+		} else {
+			for _, p := range plan.ToContainer {
+				record(p, host, after)
+			}
+		}
+	}
+	if len(plan.ToHost) > 0 {
+		written, err := s.copyToHost(plan.ToHost)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		after := make(map[string]fileStamp, len(written))
+		for _, p := range written {
+			if info, err := os.Lstat(filepath.Join(s.wsPath, filepath.FromSlash(p))); err == nil {
+				after[p] = hostStamp(info)
+			}
+		}
+		for _, p := range written {
+			record(p, after, ctr)
+		}
+	}
+	if len(plan.DeleteInContainer) > 0 {
+		if err := s.deleteInContainer(plan.DeleteInContainer); err != nil {
+			errs = append(errs, err)
+		} else {
+			for _, p := range plan.DeleteInContainer {
+				record(p, host, nil)
+			}
+		}
+	}
+	for _, p := range plan.DeleteOnHost {
+		if err := deleteOnHost(s.wsPath, p); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		record(p, nil, ctr)
+	}
+
+	for _, p := range plan.Conflicts {
+		if !slices.Contains(s.base.Conflicts, p) && s.log != nil {
+			s.log.Printf("conflict: %s changed on both sides", p)
+		}
+	}
+	base.Conflicts = plan.Conflicts
+	if s.log != nil {
+		if n := len(plan.ToContainer) + len(plan.ToHost) + len(plan.DeleteInContainer) + len(plan.DeleteOnHost); n > 0 {
+			s.log.Printf("synced %d to the sandbox, %d to the host, deleted %d in the sandbox and %d on the host",
+				len(plan.ToContainer), len(plan.ToHost), len(plan.DeleteInContainer), len(plan.DeleteOnHost))
+		}
+	}
+	s.base = base
+	if err := base.save(s.container); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func hostStamp(info fs.FileInfo) fileStamp {
+	return fileStamp{Size: info.Size(), Mod: strconv.FormatInt(info.ModTime().UnixNano(), 10)}
+}
+
+// scanHostWorkspace returns the stamps of the workspace's regular files
+// that ignores don't leave out. Symlinks aren't synced.
+func scanHostWorkspace(wsPath string, ignores []string) (map[string]fileStamp, error) {
+	files := make(map[string]fileStamp)
+	err := filepath.WalkDir(wsPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == wsPath {
+			return nil
+		}
+		rel, err := filepath.Rel(wsPath, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if syncIgnored(ignores, rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // gone since it was listed
+		}
+		files[rel] = hostStamp(info)
+		return nil
+	})
+	return files, err
+}
+
+// containerFindArgs returns the find arguments listing the container's
+// workspace files, pruning directories ignored wherever they are; the rest
+// of ignores is applied to what it lists.
+func containerFindArgs(wsPath string, ignores []string) []string {
+	args := []string{"find", wsPath, "-mindepth", "1"}
+	var prune []string
+	for _, p := range ignores {
+		name := strings.TrimSuffix(p, "/")
+		if strings.Contains(name, "/") {
+			continue
+		}
+		if len(prune) > 0 {
+			prune = append(prune, "-o")
+		}
+		if strings.HasSuffix(p, "/") {
+			prune = append(prune, "(", "-type", "d", "-name", name, ")")
+		} else {
+			prune = append(prune, "-name", name)
+		}
+	}
+	if len(prune) > 0 {
+		args = append(append(append(args, "("), prune...), ")", "-prune", "-o")
+	}
+	return append(args, "-type", "f", "-printf", `%s %T@ %P\0`)
+}
+
+// scanContainerWorkspace returns the stamps of the container's workspace
+// files that ignores don't leave out.
+func scanContainerWorkspace(container, wsPath string, ignores []string) (map[string]fileStamp, error) {
+	out, err := exec.Command("docker", append([]string{"exec", "-u", "agent", container},
+		containerFindArgs(wsPath, ignores)...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseContainerScan(out, ignores), nil
+}
+
+// parseContainerScan reads the output of containerFindArgs.
+func parseContainerScan(out []byte, ignores []string) map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	for _, entry := range strings.Split(string(out), "\x00") {
+		f := strings.SplitN(entry, " ", 3)
+		if len(f) != 3 || f[2] == "" {
+			continue
+		}
+		size, err := strconv.ParseInt(f[0], 10, 64)
+		if err != nil || syncIgnored(ignores, f[2], false) {
+			continue
+		}
+		files[f[2]] = fileStamp{Size: size, Mod: f[1]}
+	}
+	return files
+}
+
+// identical returns which of paths, on both sides at the same size, have
+// the same content.
+func (s *builtinSync) identical(paths []string, host, ctr map[string]fileStamp) map[string]bool {
+	var check []string
+	for _, p := range paths {
+		h, inHost := host[p]
+		c, inCtr := ctr[p]
+		if inHost && inCtr && h.Size == c.Size {
+			check = append(check, p)
+		}
+	}
+	same := make(map[string]bool)
+	if len(check) == 0 {
+		return same
+	}
+	c := exec.Command("docker", "exec", "-i", "-u", "agent", s.container,
+		"sh", "-c", `cd "$1" && xargs -0 sha256sum --`, "sh", s.wsPath)
+	c.Stdin = strings.NewReader(strings.Join(check, "\x00"))
+	out, _ := c.Output() // files gone since the scan are left as conflicts
+	sums := make(map[string]string)
+	sc := bufio.NewScanner(strings.NewReader(string(out)))
+	for sc.Scan() {
+		if sum, p, ok := strings.Cut(sc.Text(), "  "); ok {
+			sums[p] = sum
+		}
+	}
+	for _, p := range check {
+		data, err := os.ReadFile(filepath.Join(s.wsPath, filepath.FromSlash(p)))
+		if err == nil && sums[p] == sha256Hex(data) {
+			same[p] = true
+		}
+	}
+	return same
+}
+
+// copyToContainer sends the host's copies of paths to the container in a
+// tar stream. Files gone since the scan are left for the next round.
+func (s *builtinSync) copyToContainer(paths []string) error {
+	c := exec.Command("docker", "exec", "-i", "-u", "agent", s.container, "tar", "-C", s.wsPath, "-xf", "-")
+	pr, pw := io.Pipe()
+	c.Stdin = pr
+	var stderr strings.Builder
+	c.Stderr = &stderr
+	if err := c.Start(); err != nil {
+		return err
+	}
+	pw.CloseWithError(writeWorkspaceTar(pw, s.wsPath, paths))
+	if err := c.Wait(); err != nil {
+		return fmt.Errorf("copy to sandbox: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// writeWorkspaceTar writes the host's regular files at paths under wsPath
+// to w as a tar stream.
+func writeWorkspaceTar(w io.Writer, wsPath string, paths []string) error {
+	tw := tar.NewWriter(w)
+	for _, p := range paths {
+		f, err := os.Open(filepath.Join(wsPath, filepath.FromSlash(p)))
+		if err != nil {
+			continue
+		}
+		info, err := f.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			f.Close()
+			continue
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			f.Close()
+			return err
+		}
+		hdr.Name = p
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		hdr.Format = tar.FormatPAX
+		if err := tw.WriteHeader(hdr); err != nil {
+			f.Close()
+			return err
+		}
+		_, err = io.CopyN(tw, f, hdr.Size)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s changed while being copied: %w", p, err)
+		}
+	}
+	return tw.Close()
+}
+
+// copyToHost fetches the container's copies of paths in a tar stream and
+// writes them into the workspace, returning those written.
+func (s *builtinSync) copyToHost(paths []string) ([]string, error) {
+	c := exec.Command("docker", "exec", "-i", "-u", "agent", s.container,
+		"tar", "-C", s.wsPath, "--null", "-T", "-", "-cf", "-")
+	c.Stdin = strings.NewReader(strings.Join(paths, "\x00"))
+	var stderr strings.Builder
+	c.Stderr = &stderr
+	out, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	written, extractErr := extractWorkspaceTar(out, s.wsPath, paths)
+	io.Copy(io.Discard, out)
+	if err := c.Wait(); err != nil && extractErr == nil {
+		// Files gone since the scan make tar fail, but the rest arrive.
+		s.logf("copy to host: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return written, extractErr
+}
+
+func (s *builtinSync) logf(format string, args ...any) {
+	if s.log != nil {
+		s.log.Printf(format, args...)
+	}
+}
+
+// extractWorkspaceTar writes the regular files in the tar stream r into
+// wsPath, returning the paths written. The container is not trusted: only
+// the paths asked for are written, and never through a symlink.
+func extractWorkspaceTar(r io.Reader, wsPath string, want []string) ([]string, error) {
+	var written []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if hdr.Typeflag != tar.TypeReg || !slices.Contains(want, name) {
+			continue
+		}
+		dest, err := hostSyncDest(wsPath, name)
+		if err != nil {
+			return written, err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return written, err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(dest), ".sandbox-sync-*")
+		if err != nil {
+			return written, err
+		}
+		_, err = io.Copy(tmp, tr)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), fs.FileMode(hdr.Mode).Perm())
+		}
+		if err == nil {
+			os.Chtimes(tmp.Name(), hdr.ModTime, hdr.ModTime)
+			err = os.Rename(tmp.Name(), dest)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return written, err
+		}
+		written = append(written, name)
+	}
+}
+
+// hostSyncDest returns where the file at rel goes in wsPath, refusing
+// paths that leave it or lead through a symlink or a file.
+func hostSyncDest(wsPath, rel string) (string, error) {
+	local := filepath.FromSlash(rel)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("refusing to write %q outside the workspace", rel)
+	}
+	dir := wsPath
+	parts := strings.Split(local, string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("refusing to write %q: %s isn't a directory", rel, dir)
+		}
+	}
+	return filepath.Join(wsPath, local), nil
+}
+
+// deleteInContainer deletes paths from the container's workspace, with
+// the directories that leaves empty.
+func (s *builtinSync) deleteInContainer(paths []string) error {
+	const script = `cd "$1" && xargs -0 sh -c 'for f; do
+	rm -f -- "$f"; d=${f%/*}
+	while [ "$d" != "$f" ] && rmdir -- "$d" 2>/dev/null; do f=$d; d=${d%/*}; done
+done' sh`
+	c := exec.Command("docker", "exec", "-i", "-u", "agent", s.container, "sh", "-c", script, "sh", s.wsPath)
+	c.Stdin = strings.NewReader(strings.Join(paths, "\x00"))
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("delete in sandbox: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// deleteOnHost deletes rel from the workspace, with the directories that
+// leaves empty.
+func deleteOnHost(wsPath, rel string) error {
+	dest, err := hostSyncDest(wsPath, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dest); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(dest); dir != wsPath && strings.HasPrefix(dir, wsPath); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// builtinSyncProcess returns the pid of the built-in engine's process for
+// the container named, and the settings it runs with, if it is running.
+func builtinSyncProcess(container string) (int, string, bool) {
+	path, err := builtinSyncPath(container, ".pid")
+	if err != nil {
+		return 0, "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, "", false
+	}
+	pidStr, hash, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return 0, "", false
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil || proc.Signal(syscall.Signal(0)) != nil {
+		return 0, "", false
+	}
+	return pid, hash, true
+}
+
+// ensureBuiltinSync makes sure the built-in engine runs for the container
+// named with cfg's settings. Starting it, the workspace is synced once
+// first, so the container has it straight away.
+func ensureBuiltinSync(cfg *SandboxConfig, name, wsPath string) error {
+	defer warnWorkspaceConflicts(name)
+	hash := workspaceSyncHash(cfg)
+	if _, have, running := builtinSyncProcess(name); running {
+		if have == hash {
+			return nil
+		}
+		stopBuiltinSync(name)
+	}
+	syncStatus("syncing workspace...")
+	err := newBuiltinSync(cfg, name, wsPath, nil).sync()
+	syncStatusDone()
+	if err != nil {
+		return fmt.Errorf("sync workspace: %w", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("find executable: %w", err)
+	}
+	args := []string{"workspace-sync", name}
+	if p := ActiveProfile(); p != "" {
+		args = append(args, "--profile", p)
+	}
+	c := exec.Command(exe, args...)
+	setSysProcAttr(c)
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("start workspace sync: %w", err)
+	}
+	c.Process.Release()
+	return nil
+}
+
+// warnWorkspaceConflicts warns about files the built-in engine has left
+// in conflict.
+func warnWorkspaceConflicts(name string) {
+	if c := loadSyncBaseline(name).Conflicts; len(c) > 0 {
+		Warnf(WarnSync, "workspace files changed both in the sandbox and on the host: %s. Delete the copy to drop on either side to keep the other.",
+			strings.Join(c, ", "))
+	}
+}
+
+// stopBuiltinSync asks the built-in engine for the container named to sync
+// one last time and stop, and waits for it.
+func stopBuiltinSync(name string) {
+	pid, _, running := builtinSyncProcess(name)
+	if !running {
+		return
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+	proc.Signal(os.Interrupt)
+	for deadline := time.Now().Add(builtinSyncStopTimeout); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if _, _, running := builtinSyncProcess(name); !running {
+			return
+		}
+	}
+	proc.Kill()
+}
+
+// RunBuiltinSync is the built-in engine's process for the container named:
+// it keeps the container's workspace and the host's in step until the
+// container stops, or until ctx is done, when it syncs one last time.
+func RunBuiltinSync(ctx context.Context, container string) error {
+	wsPath := containerWorkspace(container)
+	if wsPath == "" {
+		return fmt.Errorf("no sandbox named %s", container)
+	}
+	cfg, err := LoadConfig(wsPath)
+	if err != nil {
+		return err
+	}
+	if workspaceSyncEngine(cfg) != SyncEngineBuiltin {
+		return fmt.Errorf("%s doesn't use the built-in workspace sync", wsPath)
+	}
+	if _, _, running := builtinSyncProcess(container); running {
+		return fmt.Errorf("workspace sync for %s is already running", container)
+	}
+
+	logPath, err := builtinSyncPath(container, ".log")
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(logPath), 0755)
+	if info, err := os.Stat(logPath); err == nil && info.Size() > 1<<20 {
+		os.Truncate(logPath, 0)
+	}
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	defer f.Close()
+	logger := log.New(f, "", log.LstdFlags)
+
+	pidPath, _ := builtinSyncPath(container, ".pid")
+	os.WriteFile(pidPath, []byte(fmt.Sprintf("%d\n%s", os.Getpid(), workspaceSyncHash(cfg))), 0644)
+	defer os.Remove(pidPath)
+	logger.Printf("syncing %s with %s (pid %d)", wsPath, container, os.Getpid())
+
+	s := newBuiltinSync(cfg, container, wsPath, logger)
+	ticker := time.NewTicker(builtinSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if IsRunning(container) {
+				if err := s.sync(); err != nil {
+					logger.Printf("last sync: %v", err)
+				}
+			}
+			logger.Println("stopped")
+			return nil
+		case <-ticker.C:
+			if !IsRunning(container) {
+				logger.Println("sandbox stopped")
+				return nil
+			}
+			if err := s.sync(); err != nil {
+				logger.Printf("sync: %v", err)
+			}
+		}
+	}
+}
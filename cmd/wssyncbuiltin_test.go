@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestPlanWorkspaceSync(t *testing.T) {
+	a, b := fileStamp{Size: 1, Mod: "1"}, fileStamp{Size: 2, Mod: "2"}
+	base := syncBaseline{
+		Host:      map[string]fileStamp{"same": a, "host-edit": a, "ctr-edit": a, "host-del": a, "ctr-del": a, "both-edit": a, "both-del": a, "edit-del": a},
+		Container: map[string]fileStamp{"same": a, "host-edit": a, "ctr-edit": a, "host-del": a, "ctr-del": a, "both-edit": a, "both-del": a, "edit-del": a},
+	}
+	host := map[string]fileStamp{"same": a, "host-edit": b, "ctr-edit": a, "ctr-del": a, "both-edit": b, "edit-del": b, "host-new": a}
+	ctr := map[string]fileStamp{"same": a, "host-edit": a, "ctr-edit": b, "host-del": a, "both-edit": b, "ctr-new": a}
+
+	plan := planWorkspaceSync(base, host, ctr)
+	want := syncPlan{
+		ToContainer:       []string{"host-edit", "host-new"},
+		ToHost:            []string{"ctr-edit", "ctr-new"},
+		DeleteInContainer: []string{"host-del"},
+		DeleteOnHost:      []string{"ctr-del"},
+		Settled:           []string{"both-del"},
+		Conflicts:         []string{"both-edit", "edit-del"},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("plan = %+v\nwant %+v", plan, want)
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	a := fileStamp{Size: 1, Mod: "1"}
+	host := map[string]fileStamp{"both": a, "host-only": a}
+	ctr := map[string]fileStamp{"both": a, "ctr-only": a}
+	conflicts := []string{"both", "host-only", "ctr-only"}
+
+	tests := []struct {
+		policy string
+		want   syncPlan
+	}{
+		{SyncConflictsManual, syncPlan{ToContainer: []string{"host-only"}, ToHost: []string{"ctr-only"}, Conflicts: []string{"both"}}},
+		{SyncConflictsHost, syncPlan{ToContainer: []string{"both", "host-only"}, DeleteInContainer: []string{"ctr-only"}}},
+		{SyncConflictsContainer, syncPlan{ToHost: []string{"both", "ctr-only"}, DeleteOnHost: []string{"host-only"}}},
+	}
+	for _, tt := range tests {
+		plan := syncPlan{Conflicts: slices.Clone(conflicts)}
+		plan.resolveConflicts(tt.policy, host, ctr)
+		if !reflect.DeepEqual(plan, tt.want) {
+			t.Errorf("%s: plan = %+v, want %+v", tt.policy, plan, tt.want)
+		}
+	}
+}
+
+func TestContainerFindArgs(t *testing.T) {
+	got := containerFindArgs("/src/app", []string{"node_modules/", "*.log", "/build"})
+	want := []string{"find", "/src/app", "-mindepth", "1",
+		"(", "(", "-type", "d", "-name", "node_modules", ")", "-o", "-name", "*.log", ")", "-prune", "-o",
+		"-type", "f", "-printf", `%s %T@ %P\0`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %q\nwant %q", got, want)
+	}
+}
+
+func TestParseContainerScan(t *testing.T) {
+	out := []byte("12 1700000000.5 src/main.go\x003 1700000001.0 build/out bin\x00")
+	got := parseContainerScan(out, []string{"/build"})
+	want := map[string]fileStamp{"src/main.go": {Size: 12, Mod: "1700000000.5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scan = %v, want %v", got, want)
+	}
+}
+
+func TestScanHostWorkspace(t *testing.T) {
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, "src"), 0755)
+	os.MkdirAll(filepath.Join(ws, "node_modules", "x"), 0755)
+	os.WriteFile(filepath.Join(ws, "src", "main.go"), []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(ws, "node_modules", "x", "index.js"), []byte("x"), 0644)
+	os.Symlink("src/main.go", filepath.Join(ws, "link.go"))
+
+	got, err := scanHostWorkspace(ws, []string{"node_modules/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["src/main.go"].Size != 12 {
+		t.Errorf("scan = %v, want just src/main.go", got)
+	}
+}
+
+func TestWorkspaceTarRoundTrip(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(src, "a", "b"), 0755)
+	os.WriteFile(filepath.Join(src, "a", "b", "run.sh"), []byte("#!/bin/sh\n"), 0755)
+	os.WriteFile(filepath.Join(src, "top.txt"), []byte("top"), 0644)
+
+	var buf bytes.Buffer
+	if err := writeWorkspaceTar(&buf, src, []string{"a/b/run.sh", "top.txt", "gone.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	written, err := extractWorkspaceTar(&buf, dst, []string{"a/b/run.sh", "top.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(written, []string{"a/b/run.sh", "top.txt"}) {
+		t.Errorf("written = %v", written)
+	}
+	info, err := os.Stat(filepath.Join(dst, "a", "b", "run.sh"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("run.sh = %v, %v, want it executable", info, err)
+	}
+}
+
+func TestExtractWorkspaceTarRefusesEscapes(t *testing.T) {
+	ws, outside := t.TempDir(), t.TempDir()
+	os.Symlink(outside, filepath.Join(ws, "link"))
+
+	tarOf := func(name string) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+		tw.Write([]byte("x"))
+		tw.Close()
+		return &buf
+	}
+	if _, err := extractWorkspaceTar(tarOf("link/evil"), ws, []string{"link/evil"}); err == nil {
+		t.Error("writing through a symlink should fail")
+	}
+	if _, err := extractWorkspaceTar(tarOf("../evil"), ws, []string{"../evil"}); err == nil {
+		t.Error("writing outside the workspace should fail")
+	}
+	if written, _ := extractWorkspaceTar(tarOf("unasked"), ws, []string{"other"}); len(written) != 0 {
+		t.Errorf("written = %v, want nothing not asked for", written)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("files written outside the workspace: %v", entries)
+	}
+}
+
+func TestDeleteOnHostRemovesEmptyDirs(t *testing.T) {
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, "a", "b"), 0755)
+	os.WriteFile(filepath.Join(ws, "a", "b", "f"), nil, 0644)
+	os.WriteFile(filepath.Join(ws, "a", "keep"), nil, 0644)
+	if err := deleteOnHost(ws, "a/b/f"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "a", "b")); !os.IsNotExist(err) {
+		t.Errorf("a/b should be removed once empty: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "a", "keep")); err != nil {
+		t.Errorf("a/keep should stay: %v", err)
+	}
+}
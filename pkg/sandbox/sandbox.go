@@ -0,0 +1,298 @@
+// Package sandbox manages sandboxes from Go: loading their config, starting
+// them, syncing files and firewall rules into them and running commands in
+// them, as the sandbox CLI does, for tools that embed sandbox management
+// rather than shelling out to it.
+//
+// It is a facade over package cmd, the CLI's core: its types are aliases of
+// cmd's and its methods forward to cmd's functions. Importing it links in
+// all of cmd, cobra and the CLI's root command and flags included; moving
+// the CLI out of cmd is left for later. cmd keeps process-wide settings,
+// the UI, the root hook prompt and the docker context, so each Manager
+// keeps its own and installs them for the length of each call. Calls are
+// serialised across Managers for that, so a long Exec holds up the rest.
+// The secrets cmd redacts from output are shared by every Manager.
+//
+// Apart from Exec, which ends its docker client when ctx is done, methods
+// only check ctx before starting; work under way runs to completion.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/franklin-ross/sandbox/cmd"
+)
+
+// Config is a workspace's merged sandbox config.
+type Config = cmd.SandboxConfig
+
+// SyncItem is a file a sync writes into the container.
+type SyncItem = cmd.SyncItem
+
+// SyncOptions controls a Sync.
+type SyncOptions = cmd.SyncOptions
+
+// OnSyncHook is a command run in the container after a sync.
+type OnSyncHook = cmd.OnSyncHook
+
+// Status describes a running sandbox.
+type Status = cmd.SandboxStatus
+
+// UI receives progress, warnings and the output of commands run in a
+// sandbox.
+type UI = cmd.UI
+
+// ExitError is returned by Exec when the command exits with a non-zero
+// status.
+type ExitError = cmd.ExitError
+
+// Error is a failure of a known kind; ErrorKindOf finds it in a chain.
+type Error = cmd.Error
+
+// ErrorKind classifies an Error.
+type ErrorKind = cmd.ErrorKind
+
+// Error kinds.
+const (
+	KindConfig      = cmd.KindConfig
+	KindDocker      = cmd.KindDocker
+	KindNoContainer = cmd.KindNoContainer
+	KindSync        = cmd.KindSync
+	KindHook        = cmd.KindHook
+	KindFirewall    = cmd.KindFirewall
+)
+
+// ErrorKindOf returns the kind of err, or "" if it has none.
+func ErrorKindOf(err error) ErrorKind { return cmd.ErrorKindOf(err) }
+
+// Options configures a Manager.
+type Options struct {
+	// UI receives progress and warnings, and Exec's output. Nil discards
+	// them.
+	UI UI
+	// RootHookPrompt is asked whether a root on_sync hook not yet approved
+	// for the workspace may run. Nil refuses them.
+	RootHookPrompt func(wsPath string, hook OnSyncHook) bool
+	// DockerContext is the docker context sandboxes live on, over
+	// DOCKER_HOST. Empty uses docker.context from the global config, like
+	// the CLI, or failing that Docker's own default.
+	DockerContext string
+}
+
+// Manager starts, syncs and runs commands in sandboxes.
+type Manager struct {
+	ui             UI
+	rootHookPrompt func(wsPath string, hook OnSyncHook) bool
+	dockerContext  string
+}
+
+// New returns a Manager reporting to opts.UI.
+func New(opts Options) *Manager {
+	m := &Manager{ui: opts.UI, rootHookPrompt: opts.RootHookPrompt, dockerContext: opts.DockerContext}
+	if m.ui == nil {
+		m.ui = cmd.NewTerminalUI(io.Discard, io.Discard)
+	}
+	if m.dockerContext == "" {
+		m.dockerContext = cmd.DockerContext()
+	}
+	return m
+}
+
+// callMu serialises Manager calls, as each installs its Manager's settings
+// in cmd's process-wide ones.
+var callMu sync.Mutex
+
+// use installs m's settings in cmd for a call, returning a func that puts
+// back the ones before it.
+func (m *Manager) use() (restore func()) {
+	callMu.Lock()
+	ui, prompt := cmd.Frontend, cmd.RootHookPrompt
+	dockerContext, hadContext := os.LookupEnv("DOCKER_CONTEXT")
+	dockerHost, hadHost := os.LookupEnv("DOCKER_HOST")
+	cmd.Frontend, cmd.RootHookPrompt = m.ui, m.rootHookPrompt
+	if m.dockerContext != "" {
+		os.Unsetenv("DOCKER_HOST")
+		os.Setenv("DOCKER_CONTEXT", m.dockerContext)
+	}
+	return func() {
+		cmd.Frontend, cmd.RootHookPrompt = ui, prompt
+		restoreEnv("DOCKER_CONTEXT", dockerContext, hadContext)
+		restoreEnv("DOCKER_HOST", dockerHost, hadHost)
+		callMu.Unlock()
+	}
+}
+
+func restoreEnv(key, value string, set bool) {
+	if set {
+		os.Setenv(key, value)
+	} else {
+		os.Unsetenv(key)
+	}
+}
+
+// SyncPlan is what syncing a workspace's sandbox would write.
+type SyncPlan struct {
+	Container string
+	// Items are sorted by destination. Firewall rules, resolved from DNS
+	// at sync time, aren't included.
+	Items []SyncItem
+	// Pending is whether the container's last sync is out of date, or it
+	// isn't running.
+	Pending bool
+}
+
+// ExecOptions controls an Exec.
+type ExecOptions struct {
+	Workdir string            // in the container; default the workspace root
+	Env     map[string]string // on top of the config's env
+	// Timeout above zero ends the command in the container after that
+	// long, with exit status 124.
+	Timeout time.Duration
+}
+
+// ErrNotRunning is returned by Exec when the workspace's sandbox isn't
+// running.
+var ErrNotRunning = errors.New("sandbox isn't running")
+
+func notRunning(ws string) error {
+	return &Error{Kind: KindNoContainer, Err: fmt.Errorf("%s: %w", ws, ErrNotRunning)}
+}
+
+// Workspace returns the workspace root whose sandbox path belongs to, as the
+// CLI picks it: the nearest parent with a .sandbox directory, then the
+// enclosing git repository, then path itself.
+func (m *Manager) Workspace(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return cmd.SandboxRootFor(abs), nil
+}
+
+// Container returns the name of ws's sandbox container.
+func (m *Manager) Container(ws string) string {
+	defer m.use()()
+	return cmd.SandboxContainer(ws)
+}
+
+// Config loads ws's config, merged over the global one. ctx is checked
+// before loading.
+func (m *Manager) Config(ctx context.Context, ws string) (*Config, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer m.use()()
+	return cmd.LoadConfig(ws)
+}
+
+// Start makes sure ws's sandbox is running and synced, creating it, and
+// building the image, if needed. It returns the container's name. ctx is
+// checked before starting; a start under way runs to completion.
+func (m *Manager) Start(ctx context.Context, ws string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	defer m.use()()
+	return cmd.EnsureRunning(ws)
+}
+
+// Plan returns what syncing ws's sandbox would write, without writing it.
+// ctx is checked before starting.
+func (m *Manager) Plan(ctx context.Context, ws string) (*SyncPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer m.use()()
+	cfg, err := cmd.LoadConfig(ws)
+	if err != nil {
+		return nil, err
+	}
+	items, err := cmd.SyncManifest(cfg)
+	if err != nil {
+		return nil, err
+	}
+	name := cmd.SandboxContainer(ws)
+	pending := !cmd.IsRunning(name) || cmd.SyncPending(name, ws, cfg)
+	return &SyncPlan{Container: name, Items: items, Pending: pending}, nil
+}
+
+// Sync syncs files and firewall rules into ws's running sandbox. ctx is
+// checked before starting; a sync under way runs to completion.
+func (m *Manager) Sync(ctx context.Context, ws string, opts SyncOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer m.use()()
+	name := cmd.SandboxContainer(ws)
+	if !cmd.IsRunning(name) {
+		return notRunning(ws)
+	}
+	return cmd.SyncContainer(name, ws, opts)
+}
+
+// Exec runs args in ws's running sandbox as the agent user, without a TTY,
+// with the config's env. Its output goes to the Manager's UI. When ctx is
+// done the docker client is ended, but not the command in the container;
+// use opts.Timeout for that.
+func (m *Manager) Exec(ctx context.Context, ws string, opts ExecOptions, args ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer m.use()()
+	cfg, err := cmd.LoadConfig(ws)
+	if err != nil {
+		return err
+	}
+	name := cmd.SandboxContainer(ws)
+	if !cmd.IsRunning(name) {
+		return notRunning(ws)
+	}
+	workdir := opts.Workdir
+	if workdir == "" {
+		workdir = ws
+	}
+	return cmd.DockerExecContext(ctx, name, workdir, cfg, opts.Env, opts.Timeout, args...)
+}
+
+// Stop stops ws's sandbox, keeping the container to start again. ctx is
+// checked before starting; a stop under way runs to completion.
+func (m *Manager) Stop(ctx context.Context, ws string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer m.use()()
+	name := cmd.SandboxContainer(ws)
+	if !cmd.IsRunning(name) {
+		return nil
+	}
+	return cmd.StopContainer(name)
+}
+
+// Remove stops and removes ws's sandbox. Named volumes, like the
+// credentials volume, are kept. ctx is checked before starting.
+func (m *Manager) Remove(ctx context.Context, ws string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer m.use()()
+	name := cmd.SandboxContainer(ws)
+	if !cmd.ContainerExists(name) {
+		return nil
+	}
+	return cmd.RemoveContainer(name)
+}
+
+// List returns the running sandboxes. ctx is checked before starting.
+func (m *Manager) List(ctx context.Context) ([]Status, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer m.use()()
+	return cmd.ListSandboxes()
+}
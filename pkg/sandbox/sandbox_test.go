@@ -0,0 +1,103 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/franklin-ross/sandbox/cmd"
+)
+
+func TestCanceledContext(t *testing.T) {
+	m := New(Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ws := t.TempDir()
+	if _, err := m.Config(ctx, ws); !errors.Is(err, context.Canceled) {
+		t.Errorf("Config = %v, want canceled", err)
+	}
+	if _, err := m.Start(ctx, ws); !errors.Is(err, context.Canceled) {
+		t.Errorf("Start = %v, want canceled", err)
+	}
+	if err := m.Sync(ctx, ws, SyncOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sync = %v, want canceled", err)
+	}
+	if err := m.Exec(ctx, ws, ExecOptions{}, "true"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Exec = %v, want canceled", err)
+	}
+	if _, err := m.List(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("List = %v, want canceled", err)
+	}
+}
+
+func TestWorkspaceAndPlan(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("workspace:\n  mask: [.env]\n"), 0644)
+	sub := filepath.Join(ws, "src", "pkg")
+	os.MkdirAll(sub, 0755)
+
+	m := New(Options{})
+	got, err := m.Workspace(sub)
+	if err != nil || got != ws {
+		t.Fatalf("Workspace(%s) = %q, %v, want %q", sub, got, err, ws)
+	}
+
+	ctx := context.Background()
+	cfg, err := m.Config(ctx, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.Workspace.Mask, []string{".env"}) {
+		t.Errorf("workspace.mask = %q", cfg.Workspace.Mask)
+	}
+	plan, err := m.Plan(ctx, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Container != m.Container(ws) || !plan.Pending {
+		t.Errorf("plan for a sandbox that isn't running = %+v", plan)
+	}
+	if !slices.ContainsFunc(plan.Items, func(it SyncItem) bool { return it.Dest == "/opt/init-firewall.sh" }) {
+		t.Error("plan doesn't write the firewall script")
+	}
+}
+
+func TestManagersKeepOwnUI(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, ".sandbox"), 0755)
+	os.WriteFile(filepath.Join(ws, ".sandbox", "config.yaml"), []byte("docker:\n  context: other\n"), 0644)
+
+	before := cmd.Frontend
+	var out1, out2 bytes.Buffer
+	m1 := New(Options{UI: cmd.NewTerminalUI(&out1, &out1)})
+	m2 := New(Options{UI: cmd.NewTerminalUI(&out2, &out2)})
+	if cmd.Frontend != before {
+		t.Error("New replaced cmd.Frontend")
+	}
+
+	ctx := context.Background()
+	if _, err := m1.Config(ctx, ws); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out1.String(), "docker is only read") || out2.Len() != 0 {
+		t.Errorf("after m1.Config: m1 got %q, m2 got %q", out1.String(), out2.String())
+	}
+	out1.Reset()
+	if _, err := m2.Config(ctx, ws); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out2.String(), "docker is only read") || out1.Len() != 0 {
+		t.Errorf("after m2.Config: m1 got %q, m2 got %q", out1.String(), out2.String())
+	}
+	if cmd.Frontend != before {
+		t.Error("Config left its UI in cmd.Frontend")
+	}
+}